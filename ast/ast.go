@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -13,17 +14,67 @@ import (
 
 type Statement interface {
 	Position() token.Pos
+	// EndPosition is the position just past the node's last character, for
+	// tools such as the language server that need a node's full extent.
+	// Nodes the parser did not build (the implicit trailing return of a
+	// function body, for one) report the zero Pos.
+	EndPosition() token.Pos
 	IsStatement()
 }
 
 type statementMixin struct {
-	Pos token.Pos
+	Pos    token.Pos
+	EndPos token.Pos
 }
 
 func (statementMixin) IsStatement() {}
 func (s statementMixin) Position() token.Pos {
 	return s.Pos
 }
+func (s statementMixin) EndPosition() token.Pos {
+	return s.EndPos
+}
+
+// TokenEnd returns the position just past tok, advancing line and column the
+// way the lexer does.
+func TokenEnd(tok *token.Token) token.Pos {
+	end := tok.Pos
+	end.Offset += len(tok.Lit)
+	for _, r := range string(tok.Lit) {
+		switch r {
+		case '\n':
+			end.Line++
+			end.Column = 1
+		case '\r':
+			end.Column = 1
+		case '\t':
+			end.Column += 4
+		default:
+			end.Column++
+		}
+	}
+	return end
+}
+
+// Block is a braced statement list. The parser hands it to the constructors
+// of the statements that own a body, which keep the statements and take the
+// closing brace as their end.
+type Block struct {
+	Body   []Statement
+	EndPos token.Pos
+}
+
+func NewBlock(x, rbrace any) (any, error) {
+	var body []Statement
+	if x != nil {
+		body = x.([]Statement)
+	}
+	return &Block{Body: body, EndPos: TokenEnd(rbrace.(*token.Token))}, nil
+}
+
+func blockOf(x any) *Block {
+	return x.(*Block)
+}
 
 type StatementList []Statement
 
@@ -148,13 +199,13 @@ type CallExpression struct {
 	Args   []CallArgument
 }
 
-func NewCallExpression(callee, args any) (any, error) {
+func NewCallExpression(callee, args, rparen any) (any, error) {
 	var argList []CallArgument
 	if args != nil {
 		argList = args.([]CallArgument)
 	}
 	return &CallExpression{
-		expressionMixin: expressionMixin{statementMixin{Pos: PositionOf(callee)}},
+		expressionMixin: expressionMixin{statementMixin{Pos: PositionOf(callee), EndPos: TokenEnd(rparen.(*token.Token))}},
 		Callee:          callee.(Expression),
 		Args:            argList,
 	}, nil
@@ -171,7 +222,7 @@ func NewDeclare(x, y any) (any, error) {
 	name := string(tok.Lit)
 	value := y.(Expression)
 	return &Declare{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: value.EndPosition()},
 		Name:           name,
 		Value:          value,
 	}, nil
@@ -186,7 +237,7 @@ func NewIdentifier(x any) (any, error) {
 	tok := x.(*token.Token)
 	s := string(tok.Lit)
 	return &Identifier{
-		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}},
 		Name:            s,
 	}, nil
 }
@@ -202,7 +253,7 @@ func NewAssign(x, y any) (any, error) {
 	name := string(tok.Lit)
 	value := y.(Expression)
 	return &Assign{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: value.EndPosition()},
 		Target:         name,
 		Value:          value,
 	}, nil
@@ -231,14 +282,14 @@ func NewSetAssign(target, value any) (any, error) {
 	switch t := target.(type) {
 	case *IndexExpression:
 		return &SetIndex{
-			statementMixin: statementMixin{Pos: t.Position()},
+			statementMixin: statementMixin{Pos: t.Position(), EndPos: v.EndPosition()},
 			Object:         t.Object,
 			Index:          t.Index,
 			Value:          v,
 		}, nil
 	case *MemberExpression:
 		return &SetAttr{
-			statementMixin: statementMixin{Pos: t.Position()},
+			statementMixin: statementMixin{Pos: t.Position(), EndPos: v.EndPosition()},
 			Object:         t.Object,
 			Property:       t.Property,
 			Value:          v,
@@ -257,17 +308,20 @@ type IfElse struct {
 
 func NewIf(x, y, z any) (any, error) {
 	condition := x.(Expression)
-	ifBody := y.([]Statement)
+	ifBlock := blockOf(y)
+	end := ifBlock.EndPos
 	var elseBody []Statement = nil
 	if ifElse, ok := z.(*IfElse); ok {
 		elseBody = []Statement{ifElse}
+		end = ifElse.EndPos
 	} else if z != nil {
-		elseBody = z.([]Statement)
+		elseBlock := blockOf(z)
+		elseBody, end = elseBlock.Body, elseBlock.EndPos
 	}
 	return &IfElse{
-		statementMixin: statementMixin{Pos: PositionOf(x)},
+		statementMixin: statementMixin{Pos: PositionOf(x), EndPos: end},
 		Condition:      condition,
-		IfBody:         ifBody,
+		IfBody:         ifBlock.Body,
 		ElseBody:       elseBody,
 	}, nil
 }
@@ -280,11 +334,11 @@ type While struct {
 
 func NewWhile(x, y any) (any, error) {
 	condition := x.(Expression)
-	body := y.([]Statement)
+	body := blockOf(y)
 	return &While{
-		statementMixin: statementMixin{Pos: PositionOf(x)},
+		statementMixin: statementMixin{Pos: PositionOf(x), EndPos: body.EndPos},
 		Condition:      condition,
-		Body:           body,
+		Body:           body.Body,
 	}, nil
 }
 
@@ -299,12 +353,12 @@ func NewFor(x, y, z any) (any, error) {
 	tok := x.(*token.Token)
 	variable := string(tok.Lit)
 	iterator := y.(Expression)
-	body := z.([]Statement)
+	body := blockOf(z)
 	return &For{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: body.EndPos},
 		Variable:       variable,
 		Iterator:       iterator,
-		Body:           body,
+		Body:           body.Body,
 	}, nil
 }
 
@@ -312,16 +366,18 @@ type Break struct {
 	statementMixin
 }
 
-func NewBreak() (any, error) {
-	return &Break{}, nil
+func NewBreak(x any) (any, error) {
+	tok := x.(*token.Token)
+	return &Break{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}}, nil
 }
 
 type Continue struct {
 	statementMixin
 }
 
-func NewContinue() (any, error) {
-	return &Continue{}, nil
+func NewContinue(x any) (any, error) {
+	tok := x.(*token.Token)
+	return &Continue{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}}, nil
 }
 
 type Module struct {
@@ -330,9 +386,14 @@ type Module struct {
 }
 
 func NewModule(x any) (any, error) {
+	// An empty file, or one holding only comments, has no statements.
+	var body []Statement
+	if x != nil {
+		body = x.([]Statement)
+	}
 	return &Module{
 		Name: "main",
-		Body: x.([]Statement),
+		Body: body,
 	}, nil
 }
 
@@ -343,13 +404,19 @@ type Literal struct {
 
 func NewIntegerLiteral(x any) (any, error) {
 	tok := x.(*token.Token)
-	d, err := strconv.Atoi(string(tok.Lit))
-	if err != nil {
+	// A literal too large for int64 becomes a BigInt rather than a parse
+	// error; the lexer only ever hands over decimal digits.
+	var value object.Object
+	if d, err := strconv.ParseInt(string(tok.Lit), 10, 64); err == nil {
+		value = object.Integer(d)
+	} else if n, ok := new(big.Int).SetString(string(tok.Lit), 10); ok {
+		value = object.NewInt(n)
+	} else {
 		return nil, err
 	}
 	return &Literal{
-		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos}},
-		Value:           object.Integer(d),
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}},
+		Value:           value,
 	}, nil
 }
 
@@ -360,7 +427,7 @@ func NewFloatLiteral(x any) (any, error) {
 		return nil, err
 	}
 	return &Literal{
-		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}},
 		Value:           object.Float(f),
 	}, nil
 }
@@ -370,7 +437,7 @@ func NewStringLiteral(x any) (any, error) {
 	s := string(tok.Lit)
 	s = unescapeString(s[1 : len(s)-1])
 	return &Literal{
-		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}},
 		Value:           object.String(s),
 	}, nil
 }
@@ -409,16 +476,24 @@ func unescapeString(s string) string {
 	return b.String()
 }
 
-func NewTrueLiteral() (any, error) {
-	return &Literal{Value: object.True}, nil
+func NewTrueLiteral(x any) (any, error) {
+	return newKeywordLiteral(x, object.True), nil
+}
+
+func NewFalseLiteral(x any) (any, error) {
+	return newKeywordLiteral(x, object.False), nil
 }
 
-func NewFalseLiteral() (any, error) {
-	return &Literal{Value: object.False}, nil
+func NewNilLiteral(x any) (any, error) {
+	return newKeywordLiteral(x, object.Nil), nil
 }
 
-func NewNilLiteral() (any, error) {
-	return &Literal{Value: object.Nil}, nil
+func newKeywordLiteral(x any, value object.Object) *Literal {
+	tok := x.(*token.Token)
+	return &Literal{
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)}},
+		Value:           value,
+	}
 }
 
 type ListLiteral struct {
@@ -426,17 +501,19 @@ type ListLiteral struct {
 	Elements []Expression
 }
 
-func NewListLiteral(x any) (any, error) {
+func NewListLiteral(lbracket, x, rbracket any) (any, error) {
 	var elements []Expression
 	if x != nil {
 		elements = x.([]Expression)
 	}
-	pos := token.Pos{}
+	// A literal reports its first element's position, which is where
+	// evaluation errors point; only an empty one falls back to the bracket.
+	pos := lbracket.(*token.Token).Pos
 	if len(elements) > 0 {
 		pos = elements[0].Position()
 	}
 	return &ListLiteral{
-		expressionMixin: expressionMixin{statementMixin{Pos: pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: pos, EndPos: TokenEnd(rbracket.(*token.Token))}},
 		Elements:        elements,
 	}, nil
 }
@@ -466,17 +543,18 @@ type DictLiteral struct {
 	Elements []*DictElement
 }
 
-func NewDictLiteral(x any) (any, error) {
+func NewDictLiteral(lbrace, x, rbrace any) (any, error) {
 	var elements []*DictElement
 	if x != nil {
 		elements = x.([]*DictElement)
 	}
-	pos := token.Pos{}
+	// As with lists, an empty literal falls back to the brace.
+	pos := lbrace.(*token.Token).Pos
 	if len(elements) > 0 {
 		pos = elements[0].Key.Position()
 	}
 	return &DictLiteral{
-		expressionMixin: expressionMixin{statementMixin{Pos: pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: pos, EndPos: TokenEnd(rbrace.(*token.Token))}},
 		Elements:        elements,
 	}, nil
 }
@@ -486,6 +564,9 @@ type FunctionDefine struct {
 	Name       string
 	Parameters []*Parameter
 	Body       []Statement
+	// Doc is the comment directly above the definition, without its #
+	// markers. The parser leaves it empty; doc.Attach fills it in.
+	Doc string
 }
 
 type TypeField struct {
@@ -597,14 +678,11 @@ func NewFunctionDefine(x, params, y any) (any, error) {
 	if params != nil {
 		parameters = params.([]*Parameter)
 	}
-	var body []Statement
-	if y != nil {
-		body = y.([]Statement)
-	}
+	block := blockOf(y)
 	// Always insert a return block at the end of function define.
-	body = append(body, &Return{Value: &Literal{Value: object.Nil}})
+	body := append(block.Body, &Return{Value: &Literal{Value: object.Nil}})
 	return &FunctionDefine{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: block.EndPos},
 		Name:           name,
 		Parameters:     parameters,
 		Body:           body,
@@ -625,14 +703,11 @@ func NewFunctionLiteral(fn, params, y any) (any, error) {
 	if params != nil {
 		parameters = params.([]*Parameter)
 	}
-	var body []Statement
-	if y != nil {
-		body = y.([]Statement)
-	}
+	block := blockOf(y)
 	// Always insert a return block at the end, mirroring NewFunctionDefine.
-	body = append(body, &Return{Value: &Literal{Value: object.Nil}})
+	body := append(block.Body, &Return{Value: &Literal{Value: object.Nil}})
 	return &FunctionLiteral{
-		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: tok.Pos, EndPos: block.EndPos}},
 		Parameters:      parameters,
 		Body:            body,
 	}, nil
@@ -643,6 +718,7 @@ type TypeDefine struct {
 	Name    string
 	Fields  []*TypeField
 	Methods []*FunctionDefine
+	Doc     string // as for FunctionDefine
 }
 
 func NewTypeMethodList(x any) (any, error) {
@@ -653,7 +729,7 @@ func AppendTypeMethodList(l any, x any) (any, error) {
 	return append(l.([]*FunctionDefine), x.(*FunctionDefine)), nil
 }
 
-func NewTypeDefine(name, fields, methods any) (any, error) {
+func NewTypeDefine(name, fields, methods, rbrace any) (any, error) {
 	tok := name.(*token.Token)
 
 	var typeFields []*TypeField
@@ -667,7 +743,7 @@ func NewTypeDefine(name, fields, methods any) (any, error) {
 	}
 
 	return &TypeDefine{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: TokenEnd(rbrace.(*token.Token))},
 		Name:           string(tok.Lit),
 		Fields:         typeFields,
 		Methods:        typeMethods,
@@ -681,7 +757,7 @@ type Return struct {
 
 func NewReturn(x any) (any, error) {
 	return &Return{
-		statementMixin: statementMixin{Pos: PositionOf(x)},
+		statementMixin: statementMixin{Pos: PositionOf(x), EndPos: x.(Expression).EndPosition()},
 		Value:          x.(Expression),
 	}, nil
 }
@@ -692,7 +768,7 @@ func NewReturn(x any) (any, error) {
 func NewReturnNil(x any) (any, error) {
 	tok := x.(*token.Token)
 	return &Return{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)},
 		Value:          &Literal{Value: object.Nil},
 	}, nil
 }
@@ -704,26 +780,29 @@ type Raise struct {
 
 func NewRaise(x any) (any, error) {
 	return &Raise{
-		statementMixin: statementMixin{Pos: PositionOf(x)},
+		statementMixin: statementMixin{Pos: PositionOf(x), EndPos: x.(Expression).EndPosition()},
 		Value:          x.(Expression),
 	}, nil
 }
 
 type TryCatch struct {
 	statementMixin
-	TryBody   []Statement
-	CatchVar  string
-	CatchBody []Statement
+	TryBody     []Statement
+	CatchVar    string
+	CatchVarPos token.Pos
+	CatchBody   []Statement
 }
 
 func NewTryCatch(tryTok, tryBody, catchVar, catchBody any) (any, error) {
 	tok := tryTok.(*token.Token)
 	varTok := catchVar.(*token.Token)
+	catchBlock := blockOf(catchBody)
 	return &TryCatch{
-		statementMixin: statementMixin{Pos: tok.Pos},
-		TryBody:        tryBody.([]Statement),
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: catchBlock.EndPos},
+		TryBody:        blockOf(tryBody).Body,
 		CatchVar:       string(varTok.Lit),
-		CatchBody:      catchBody.([]Statement),
+		CatchVarPos:    varTok.Pos,
+		CatchBody:      catchBlock.Body,
 	}, nil
 }
 
@@ -758,7 +837,7 @@ func NewBinaryOperation(lhs, operator, rhs any) (any, error) {
 		return nil, fmt.Errorf("invalid operator: '%s'", operator)
 	}
 	return &BinaryOperation{
-		expressionMixin: expressionMixin{statementMixin{Pos: lhs.(Expression).Position()}},
+		expressionMixin: expressionMixin{statementMixin{Pos: lhs.(Expression).Position(), EndPos: rhs.(Expression).EndPosition()}},
 		LHS:             lhs.(Expression),
 		RHS:             rhs.(Expression),
 		Operator:        operator.(string),
@@ -778,7 +857,7 @@ func NewUnaryOperation(operator, operand any) (any, error) {
 		return nil, fmt.Errorf("invalid unary operator: '%s'", operator)
 	}
 	return &UnaryOperation{
-		expressionMixin: expressionMixin{statementMixin{Pos: operand.(Expression).Position()}},
+		expressionMixin: expressionMixin{statementMixin{Pos: operand.(Expression).Position(), EndPos: operand.(Expression).EndPosition()}},
 		Operand:         operand.(Expression),
 		Operator:        operator.(string),
 	}, nil
@@ -790,9 +869,9 @@ type IndexExpression struct {
 	Index  Expression
 }
 
-func NewIndexExpression(obj, idx any) (any, error) {
+func NewIndexExpression(obj, idx, rbracket any) (any, error) {
 	return &IndexExpression{
-		expressionMixin: expressionMixin{statementMixin{Pos: obj.(Expression).Position()}},
+		expressionMixin: expressionMixin{statementMixin{Pos: obj.(Expression).Position(), EndPos: TokenEnd(rbracket.(*token.Token))}},
 		Object:          obj.(Expression),
 		Index:           idx.(Expression),
 	}, nil
@@ -807,7 +886,7 @@ type MemberExpression struct {
 func NewMemberExpression(obj, prop any) (any, error) {
 	propTok := prop.(*token.Token)
 	return &MemberExpression{
-		expressionMixin: expressionMixin{statementMixin{Pos: propTok.Pos}},
+		expressionMixin: expressionMixin{statementMixin{Pos: propTok.Pos, EndPos: TokenEnd(propTok)}},
 		Object:          obj.(Expression),
 		Property:        string(propTok.Lit),
 	}, nil
@@ -816,13 +895,14 @@ func NewMemberExpression(obj, prop any) (any, error) {
 type Export struct {
 	statementMixin
 	Name string
+	Doc  string // as for FunctionDefine
 }
 
 func NewExport(x any) (any, error) {
 	tok := x.(*token.Token)
 	name := string(tok.Lit)
 	return &Export{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)},
 		Name:           name,
 	}, nil
 }
@@ -840,7 +920,7 @@ func NewImport(x any) (any, error) {
 	parts := strings.Split(path, "/")
 	name := parts[len(parts)-1]
 	return &Import{
-		statementMixin: statementMixin{Pos: tok.Pos},
+		statementMixin: statementMixin{Pos: tok.Pos, EndPos: TokenEnd(tok)},
 		Name:           name,
 		Path:           path,
 	}, nil
@@ -71,8 +71,8 @@ done
 
 echo "## Results (best of $REPEATS, milliseconds)"
 echo
-echo "| benchmark | go | node | lua | python | goblin build-exe | goblin run |"
-echo "|---|---|---|---|---|---|---|"
+echo "| benchmark | go | node | lua | python | goblin build-exe | goblin run | goblin run --vm |"
+echo "|---|---|---|---|---|---|---|---|"
 for b in "${BENCHES[@]}"; do
     row="| $b "
     if have go; then row+="| $(best_ms "$WORK/$b.go.exe") "; else row+="| - "; fi
@@ -82,8 +82,9 @@ for b in "${BENCHES[@]}"; do
     if have "$GOBLIN"; then
         row+="| $(best_ms "$WORK/$b.goblin.exe") "
         row+="| $(best_ms "$GOBLIN" run "./$b/$b.goblin") "
+        row+="| $(best_ms "$GOBLIN" run --vm "./$b/$b.goblin") "
     else
-        row+="| - | - "
+        row+="| - | - | - "
     fi
     echo "$row|"
 done
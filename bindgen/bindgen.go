@@ -0,0 +1,712 @@
+// Package bindgen generates Goblin modules that call Go packages, for goblin
+// bindgen. It inspects a package's exported functions, types, methods and
+// constants with go list and go/types and writes an extension module that follows
+// STDLIB_DESIGN.md: snake_case names, UPPER_CASE constants, arguments parsed
+// with object.ArgParser, errors wrapped with object.WrapNativeError, and
+// struct types bound as opaque values whose methods and fields are
+// attributes. What it cannot bind is listed in the module's package comment,
+// for review, rather than bound half-faithfully.
+package bindgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dave/jennifer/jen"
+)
+
+const (
+	pathObject = "github.com/aisk/goblin/object"
+
+	// generatedMarker starts the first line of every file bindgen writes.
+	generatedMarker = "// Code generated by goblin bindgen"
+)
+
+// Options adjust Generate.
+type Options struct {
+	// Dir is the directory the package is resolved from, as by go list:
+	// its go.mod must provide the package. Empty means the current
+	// directory.
+	Dir string
+	// ImportPath is the import path of the generated package, which
+	// registers the module under it. Empty means the package's directory
+	// in GobindPath.
+	ImportPath string
+}
+
+// Binding is a generated module.
+type Binding struct {
+	// Name is the module's name in Goblin, and of the Go package
+	// implementing it.
+	Name string
+	// GoPackage is the import path of the Go package it binds.
+	GoPackage string
+	// Source is the Go source of the module's package, formatted.
+	Source []byte
+	// Skipped lists the exported names that were left out, each with why.
+	Skipped []string
+}
+
+// Generate binds the Go package goPackage as the Goblin module name, which
+// defaults to the package's name.
+func Generate(goPackage, name string, opts Options) (*Binding, error) {
+	pkg, err := load(goPackage, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Name() == "main" {
+		return nil, fmt.Errorf("%s is a command, not a package", pkg.Path())
+	}
+	if isInternal(pkg.Path()) {
+		return nil, fmt.Errorf("%s is an internal package, which only its own module can import", pkg.Path())
+	}
+	if name == "" {
+		name = strings.ToLower(pkg.Name())
+	}
+	if !ValidName(name) {
+		return nil, fmt.Errorf("invalid module name %q: want lowercase letters, digits and underscores, starting with a letter", name)
+	}
+
+	importPath := opts.ImportPath
+	if importPath == "" {
+		importPath = GobindPath + "/" + name
+	}
+	g := &generator{pkg: pkg, name: name, importPath: importPath, wrapped: map[*types.TypeName]bool{}}
+	src, err := g.generate()
+	if err != nil {
+		return nil, err
+	}
+	return &Binding{Name: name, GoPackage: pkg.Path(), Source: src, Skipped: g.skipped}, nil
+}
+
+// ValidName reports whether name can name a generated module: a lowercase
+// identifier, as the modules of the standard library are, that is not a Go
+// keyword, since it names the module's Go package too.
+func ValidName(name string) bool {
+	if token.IsKeyword(name) {
+		return false
+	}
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' && i > 0 || r >= '0' && r <= '9' && i > 0) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+const (
+	// GoblinModule is the module path of the Goblin checkout bindings are
+	// generated into.
+	GoblinModule = "github.com/aisk/goblin"
+	// GobindPath is the package holding the generated modules, one
+	// subpackage each.
+	GobindPath = GoblinModule + "/extension/gobind"
+)
+
+// FindCheckout returns the root of the Goblin checkout that contains dir: the
+// nearest directory above it whose go.mod declares GoblinModule.
+func FindCheckout(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		data, err := os.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if f := strings.Fields(line); len(f) == 2 && f[0] == "module" && strings.Trim(f[1], `"`) == GoblinModule {
+					return d, nil
+				}
+			}
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("%s is not inside a Goblin checkout: bindings are compiled into goblin, so run bindgen from a clone of %s", dir, GoblinModule)
+		}
+	}
+}
+
+func isInternal(path string) bool {
+	return path == "internal" || strings.HasPrefix(path, "internal/") ||
+		strings.HasSuffix(path, "/internal") || strings.Contains(path, "/internal/")
+}
+
+type generator struct {
+	pkg        *types.Package
+	name       string
+	importPath string                   // of the generated package
+	wrapped    map[*types.TypeName]bool // struct types bound as opaque values
+	skipped    []string
+}
+
+func (g *generator) skip(name, format string, a ...any) {
+	g.skipped = append(g.skipped, name+": "+fmt.Sprintf(format, a...))
+}
+
+// member is a module member: its Goblin name and value.
+type member struct {
+	name  string
+	value jen.Code
+}
+
+func (g *generator) generate() ([]byte, error) {
+	scope := g.pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Struct); ok && name != "Execute" {
+			g.wrapped[tn] = true
+		}
+	}
+
+	f := jen.NewFile(g.name)
+	f.HeaderComment(fmt.Sprintf("%s from %s. DO NOT EDIT.", generatedMarker, g.pkg.Path()))
+
+	var members []member
+	var decls []jen.Code
+	taken := map[string]string{}
+	add := func(goName, name string, value jen.Code) bool {
+		if other, ok := taken[name]; ok {
+			g.skip(goName, "its Goblin name %s is taken by %s", name, other)
+			return false
+		}
+		taken[name] = goName
+		members = append(members, member{name, value})
+		return true
+	}
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			if sig.TypeParams().Len() > 0 {
+				g.skip(name, "generic functions cannot be bound")
+				continue
+			}
+			goblinName := snakeCase(name)
+			body, reason := g.callBody(goblinName, name, jen.Qual(g.pkg.Path(), name), sig)
+			if reason != "" {
+				g.skip(name, "%s", reason)
+				continue
+			}
+			if add(name, goblinName, jen.Op("&").Qual(pathObject, "Function").Values(jen.Dict{
+				jen.Id("Name"): jen.Lit(goblinName),
+				jen.Id("Fn"):   jen.Id("fn" + name),
+			})) {
+				decls = append(decls, jen.Func().Id("fn"+name).Params(jen.Id("args").Qual(pathObject, "CallArgs")).Parens(jen.List(jen.Qual(pathObject, "Object"), jen.Error())).Block(body...))
+			}
+		case *types.TypeName:
+			if g.wrapped[obj] {
+				decls = append(decls, g.wrapper(obj)...)
+				continue
+			}
+			switch obj.Type().Underlying().(type) {
+			case *types.Basic:
+				// Usable as a parameter or result type, and through its
+				// constants.
+			case *types.Interface:
+				g.skip(name, "interface types cannot be bound")
+			default:
+				if obj.IsAlias() {
+					g.skip(name, "type aliases are not bound")
+				} else if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+					g.skip(name, "generic types cannot be bound")
+				} else if _, ok := obj.Type().Underlying().(*types.Struct); ok {
+					g.skip(name, "its name is the module's Execute function")
+				} else {
+					g.skip(name, "only struct types are bound as Goblin types")
+				}
+			}
+		case *types.Const:
+			value, reason := g.constant(obj)
+			if reason != "" {
+				g.skip(name, "%s", reason)
+				continue
+			}
+			add(name, strings.ToUpper(snakeCase(name)), value)
+		case *types.Var:
+			g.skip(name, "package variables are not bound")
+		}
+	}
+
+	f.PackageComment(g.packageComment())
+	values := jen.Dict{}
+	for _, m := range members {
+		values[jen.Lit(m.name)] = m.value
+	}
+	f.Func().Id("init").Params().Block(
+		jen.Qual(pathObject, "RegisterModule").Call(jen.Lit(g.name), jen.Lit(g.importPath), jen.Id("Execute")),
+	)
+	f.Comment(fmt.Sprintf("Execute runs the %s module.", g.name))
+	f.Func().Id("Execute").Params().Parens(jen.List(jen.Qual(pathObject, "Object"), jen.Error())).Block(
+		jen.Return(jen.Op("&").Qual(pathObject, "Module").Values(jen.Dict{
+			jen.Id("Name"):    jen.Lit(g.name),
+			jen.Id("Members"): jen.Map(jen.String()).Qual(pathObject, "Object").Values(values),
+		}), jen.Nil()),
+	)
+	for _, d := range decls {
+		f.Add(d)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Render(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *generator) packageComment() string {
+	text := fmt.Sprintf("Package %s is the Goblin module %s, which calls the Go package %s.", g.name, g.name, g.pkg.Path())
+	if len(g.skipped) > 0 {
+		text += "\n\nNot bound:\n"
+		for _, s := range g.skipped {
+			text += "\n  - " + s
+		}
+	}
+	return text
+}
+
+// constant returns the Goblin value of an exported constant, or why it has
+// none.
+func (g *generator) constant(c *types.Const) (jen.Code, string) {
+	ref := jen.Qual(g.pkg.Path(), c.Name())
+	switch c.Val().Kind() {
+	case constant.Bool:
+		return jen.Qual(pathObject, "Bool").Call(ref), ""
+	case constant.String:
+		return jen.Qual(pathObject, "String").Call(ref), ""
+	case constant.Int:
+		if _, exact := constant.Int64Val(c.Val()); exact {
+			return jen.Qual(pathObject, "Integer").Call(ref), ""
+		}
+		return jen.Qual(pathObject, "MustParseInt").Call(jen.Lit(c.Val().ExactString())), ""
+	case constant.Float:
+		if basic, ok := c.Type().Underlying().(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 {
+			if f, _ := constant.Float64Val(c.Val()); f == 0 && constant.Sign(c.Val()) != 0 {
+				return nil, "its value underflows float64"
+			}
+		}
+		return jen.Qual(pathObject, "Float").Call(ref), ""
+	}
+	return nil, "complex constants have no Goblin type"
+}
+
+// kind is how a Go type crosses into Goblin.
+type kind int
+
+const (
+	kindBool kind = iota
+	kindString
+	kindInt
+	kindUint
+	kindFloat
+	kindBytes
+	kindStrings
+	kindWrapped
+)
+
+// conv is a Go type with a Goblin counterpart.
+type conv struct {
+	kind    kind
+	typ     types.Type
+	wrapper string // the wrapper type, for kindWrapped
+	pointer bool   // typ is a pointer to the wrapped struct
+}
+
+// convFor returns how values of t cross into Goblin, and false for types
+// with no Goblin counterpart.
+func (g *generator) convFor(t types.Type) (conv, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsUntyped != 0:
+		case info&types.IsBoolean != 0:
+			return conv{kind: kindBool, typ: t}, true
+		case info&types.IsString != 0:
+			return conv{kind: kindString, typ: t}, true
+		case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+			return conv{kind: kindUint, typ: t}, true
+		case info&types.IsInteger != 0:
+			return conv{kind: kindInt, typ: t}, true
+		case info&types.IsFloat != 0:
+			return conv{kind: kindFloat, typ: t}, true
+		}
+	case *types.Slice:
+		if types.Identical(u.Elem(), types.Typ[types.Byte]) {
+			return conv{kind: kindBytes, typ: t}, true
+		}
+		if types.Identical(u.Elem(), types.Typ[types.String]) {
+			return conv{kind: kindStrings, typ: t}, true
+		}
+	case *types.Pointer:
+		if named, ok := u.Elem().(*types.Named); ok && g.wrapped[named.Obj()] {
+			return conv{kind: kindWrapped, typ: t, wrapper: named.Obj().Name(), pointer: true}, true
+		}
+	case *types.Struct:
+		if named, ok := t.(*types.Named); ok && g.wrapped[named.Obj()] {
+			return conv{kind: kindWrapped, typ: t, wrapper: named.Obj().Name()}, true
+		}
+	}
+	return conv{}, false
+}
+
+// describe names t for the reasons in the package comment.
+func (g *generator) describe(t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(g.pkg))
+}
+
+// argument returns the ArgParser call reading a parameter of type c called
+// name, and the expression converting the variable v it is stored in to the
+// Go type.
+func (c conv) argument(ap *jen.Statement, name, v string) (jen.Code, *jen.Statement) {
+	switch c.kind {
+	case kindBool:
+		return ap.Dot("Bool").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	case kindString:
+		return ap.Dot("Str").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	case kindInt, kindUint:
+		return ap.Dot("Int").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	case kindFloat:
+		return ap.Dot("Float64").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	case kindBytes:
+		return ap.Dot("BytesLike").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	case kindStrings:
+		return ap.Dot("StrList").Call(jen.Lit(name)), typeCode(c.typ).Call(jen.Id(v))
+	}
+	read := jen.Qual(pathObject, "ArgOf").Types(jen.Op("*").Id(c.wrapper)).Call(ap, jen.Lit(name), jen.Lit(c.wrapper))
+	if c.pointer {
+		return read, jen.Id(v).Dot("Value")
+	}
+	return read, jen.Op("*").Id(v).Dot("Value")
+}
+
+// result returns the Goblin value of the Go expression e of type c. e must
+// be addressable when c is a wrapped struct.
+func (c conv) result(e *jen.Statement) *jen.Statement {
+	switch c.kind {
+	case kindBool:
+		return jen.Qual(pathObject, "Bool").Call(e)
+	case kindString:
+		return jen.Qual(pathObject, "String").Call(e)
+	case kindInt:
+		return jen.Qual(pathObject, "Integer").Call(e)
+	case kindUint:
+		switch c.typ.Underlying().(*types.Basic).Kind() {
+		case types.Uint, types.Uint64, types.Uintptr:
+			return jen.Qual(pathObject, "NewInt").Call(jen.New(jen.Qual("math/big", "Int")).Dot("SetUint64").Call(jen.Uint64().Call(e)))
+		}
+		return jen.Qual(pathObject, "Integer").Call(e)
+	case kindFloat:
+		return jen.Qual(pathObject, "Float").Call(e)
+	case kindBytes:
+		return jen.Qual(pathObject, "NewBytes").Call(jen.Index().Byte().Call(e))
+	case kindStrings:
+		return jen.Qual(pathObject, "StringList").Call(jen.Index().String().Call(e))
+	}
+	if c.pointer {
+		return jen.Id("new" + c.wrapper).Call(e)
+	}
+	return jen.Id("new" + c.wrapper).Call(jen.Op("&").Add(e))
+}
+
+// typeCode renders t, a type convFor accepted.
+func typeCode(t types.Type) *jen.Statement {
+	switch t := t.(type) {
+	case *types.Basic:
+		return jen.Id(t.Name())
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() == nil {
+			return jen.Id(obj.Name())
+		}
+		return jen.Qual(obj.Pkg().Path(), obj.Name())
+	case *types.Pointer:
+		return jen.Op("*").Add(typeCode(t.Elem()))
+	case *types.Slice:
+		return jen.Index().Add(typeCode(t.Elem()))
+	}
+	panic(fmt.Sprintf("bindgen: unexpected type %s", t))
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// callBody returns the body of a Goblin function called name that calls
+// callee, a Go function or method called goName with signature sig, or why
+// it cannot be bound.
+func (g *generator) callBody(name, goName string, callee *jen.Statement, sig *types.Signature) ([]jen.Code, string) {
+	ap := jen.Id("ap")
+	body := []jen.Code{ap.Clone().Op(":=").Qual(pathObject, "NewArgParser").Call(jen.Lit(name), jen.Id("args"))}
+	var callArgs []jen.Code
+	var variadic []jen.Code
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		p := params.At(i)
+		pname := snakeCase(p.Name())
+		if pname == "" || pname == "_" {
+			pname = fmt.Sprintf("arg%d", i+1)
+		}
+		v := fmt.Sprintf("a%d", i)
+		t := p.Type()
+		last := sig.Variadic() && i == params.Len()-1
+		if last {
+			t = t.(*types.Slice).Elem()
+		}
+		c, ok := g.convFor(t)
+		if !ok {
+			return nil, fmt.Sprintf("parameter %s has type %s, which has no Goblin counterpart", p.Name(), g.describe(p.Type()))
+		}
+		if !last {
+			read, arg := c.argument(ap.Clone(), pname, v)
+			body = append(body, jen.Id(v).Op(":=").Add(read))
+			callArgs = append(callArgs, arg)
+			continue
+		}
+		// Each variadic argument is parsed on its own, so it is checked
+		// as a single one would be.
+		body = append(body, jen.Id("rest").Op(":=").Add(ap.Clone()).Dot("Rest").Call())
+		read, arg := c.argument(jen.Id("p"), pname, "v")
+		variadic = []jen.Code{
+			jen.Id(v).Op(":=").Make(jen.Index().Add(typeCode(t)), jen.Len(jen.Id("rest"))),
+			jen.For(jen.List(jen.Id("i"), jen.Id("arg")).Op(":=").Range().Id("rest")).Block(
+				jen.Id("p").Op(":=").Qual(pathObject, "NewArgParser").Call(jen.Lit(name), jen.Qual(pathObject, "CallArgs").Values(jen.Dict{
+					jen.Id("Positional"): jen.Qual(pathObject, "Args").Values(jen.Id("arg")),
+				})),
+				jen.Id("v").Op(":=").Add(read),
+				jen.If(jen.Err().Op(":=").Id("p").Dot("Finish").Call(), jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err())),
+				jen.Id(v).Index(jen.Id("i")).Op("=").Add(arg),
+			),
+		}
+		callArgs = append(callArgs, jen.Id(v).Op("..."))
+	}
+	body = append(body, jen.If(jen.Err().Op(":=").Add(ap.Clone()).Dot("Finish").Call(), jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err())))
+	body = append(body, variadic...)
+
+	results := sig.Results()
+	n := results.Len()
+	hasErr := n > 0 && types.Identical(results.At(n-1).Type(), errorType)
+	if hasErr {
+		n--
+	}
+	var convs []conv
+	var names []jen.Code
+	for i := 0; i < n; i++ {
+		c, ok := g.convFor(results.At(i).Type())
+		if !ok {
+			return nil, fmt.Sprintf("it returns %s, which has no Goblin counterpart", g.describe(results.At(i).Type()))
+		}
+		convs = append(convs, c)
+		names = append(names, jen.Id(fmt.Sprintf("r%d", i)))
+	}
+	if hasErr {
+		names = append(names, jen.Err())
+	}
+	call := callee.Call(callArgs...)
+	if len(names) == 0 {
+		body = append(body, call)
+	} else {
+		body = append(body, jen.List(names...).Op(":=").Add(call))
+	}
+	if hasErr {
+		body = append(body, jen.If(jen.Err().Op("!=").Nil()).Block(
+			jen.Return(jen.Nil(), jen.Qual(pathObject, "WrapNativeError").Call(
+				jen.Qual(pathObject, "BaseError"), jen.Lit(fmt.Sprintf("%s() %s failed", name, goName)), jen.Err(),
+			)),
+		))
+	}
+	switch len(convs) {
+	case 0:
+		body = append(body, jen.Return(jen.Qual(pathObject, "Nil"), jen.Nil()))
+	case 1:
+		body = append(body, jen.Return(convs[0].result(jen.Id("r0")), jen.Nil()))
+	default:
+		// Several results come back as a list, as a tuple would in
+		// languages that have them.
+		elements := make([]jen.Code, len(convs))
+		for i, c := range convs {
+			elements[i] = c.result(jen.Id(fmt.Sprintf("r%d", i)))
+		}
+		body = append(body, jen.Return(jen.Op("&").Qual(pathObject, "List").Values(jen.Dict{
+			jen.Id("Elements"): jen.Index().Qual(pathObject, "Object").Values(elements...),
+		}), jen.Nil()))
+	}
+	return body, ""
+}
+
+// wrapper returns the opaque type binding the struct type tn: its exported
+// fields are read-only attributes and its exported methods, of *T, are
+// methods.
+func (g *generator) wrapper(tn *types.TypeName) []jen.Code {
+	name := tn.Name()
+	typ := jen.Qual(g.pkg.Path(), name)
+	recv := jen.Id("w").Op("*").Id(name)
+	objectResult := jen.Parens(jen.List(jen.Qual(pathObject, "Object"), jen.Error()))
+
+	type attr struct {
+		name  string
+		value jen.Code
+	}
+	var attrs []attr
+	taken := map[string]string{"attributes": ""}
+	st := tn.Type().Underlying().(*types.Struct)
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if !field.Exported() || field.Embedded() {
+			continue
+		}
+		goName := name + "." + field.Name()
+		c, ok := g.convFor(field.Type())
+		if !ok {
+			g.skip(goName, "the field has type %s, which has no Goblin counterpart", g.describe(field.Type()))
+			continue
+		}
+		attrName := snakeCase(field.Name())
+		if other, ok := taken[attrName]; ok {
+			g.skip(goName, "its Goblin name %s is taken by %s", attrName, other)
+			continue
+		}
+		taken[attrName] = goName
+		attrs = append(attrs, attr{attrName, c.result(jen.Id("w").Dot("Value").Dot(field.Name()))})
+	}
+
+	var methods []jen.Code
+	stringer := false
+	mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+	for i := 0; i < mset.Len(); i++ {
+		fn := mset.At(i).Obj().(*types.Func)
+		if !fn.Exported() {
+			continue
+		}
+		goName := name + "." + fn.Name()
+		sig := fn.Type().(*types.Signature)
+		if fn.Name() == "String" && sig.Params().Len() == 0 && sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), types.Typ[types.String]) {
+			stringer = true
+		}
+		methodName := snakeCase(fn.Name())
+		if other, ok := taken[methodName]; ok {
+			g.skip(goName, "its Goblin name %s is taken by %s", methodName, other)
+			continue
+		}
+		body, reason := g.callBody(methodName, goName, jen.Id("w").Dot("Value").Dot(fn.Name()), sig)
+		if reason != "" {
+			g.skip(goName, "%s", reason)
+			continue
+		}
+		taken[methodName] = goName
+		fnName := "call" + fn.Name()
+		attrs = append(attrs, attr{methodName, jen.Op("&").Qual(pathObject, "Function").Values(jen.Dict{
+			jen.Id("Name"): jen.Lit(methodName),
+			jen.Id("Fn"):   jen.Id("w").Dot(fnName),
+		})})
+		methods = append(methods, jen.Func().Params(recv.Clone()).Id(fnName).Params(jen.Id("args").Qual(pathObject, "CallArgs")).Add(objectResult.Clone()).Block(body...))
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].name < attrs[j].name })
+
+	cases := []jen.Code{jen.Case(jen.Lit("attributes")).Block(jen.Return(jen.Qual(pathObject, "AttributesFunction").Call(jen.Id("w")), jen.Nil()))}
+	names := []jen.Code{jen.Lit("attributes")}
+	for _, a := range attrs {
+		cases = append(cases, jen.Case(jen.Lit(a.name)).Block(jen.Return(a.value, jen.Nil())))
+		names = append(names, jen.Lit(a.name))
+	}
+
+	str := jen.Return(jen.Qual("fmt", "Sprintf").Call(jen.Lit("<"+name+" %p>"), jen.Id("w").Dot("Value")))
+	if stringer {
+		str = jen.Return(jen.Id("w").Dot("Value").Dot("String").Call())
+	}
+
+	code := []jen.Code{
+		jen.Comment(fmt.Sprintf("%s is a *%s.%s in Goblin.", name, g.pkg.Name(), name)),
+		jen.Type().Id(name).Struct(
+			jen.Qual(pathObject, "OpaqueBase"),
+			jen.Id("Value").Op("*").Add(typ.Clone()),
+		),
+		jen.Comment(fmt.Sprintf("new%s returns v as a Goblin value, and nil as nil.", name)),
+		jen.Func().Id("new"+name).Params(jen.Id("v").Op("*").Add(typ.Clone())).Qual(pathObject, "Object").Block(
+			jen.If(jen.Id("v").Op("==").Nil()).Block(jen.Return(jen.Qual(pathObject, "Nil"))),
+			jen.Return(jen.Op("&").Id(name).Values(jen.Dict{
+				jen.Id("OpaqueBase"): jen.Qual(pathObject, "MakeOpaqueBase").Call(jen.Lit(name)),
+				jen.Id("Value"):      jen.Id("v"),
+			})),
+		),
+		jen.Func().Params(recv.Clone()).Id("String").Params().String().Block(str),
+		jen.Func().Params(recv.Clone()).Id("ToString").Params().Parens(jen.List(jen.String(), jen.Error())).Block(
+			jen.Return(jen.Id("w").Dot("String").Call(), jen.Nil()),
+		),
+		jen.Comment("Equals reports whether other wraps the same Go value."),
+		jen.Func().Params(recv.Clone()).Id("Equals").Params(jen.Id("other").Qual(pathObject, "Object")).Parens(jen.List(jen.Bool(), jen.Error())).Block(
+			jen.List(jen.Id("o"), jen.Id("ok")).Op(":=").Id("other").Assert(jen.Op("*").Id(name)),
+			jen.Return(jen.Id("ok").Op("&&").Id("o").Dot("Value").Op("==").Id("w").Dot("Value"), jen.Nil()),
+		),
+		jen.Func().Params(recv.Clone()).Id("GetAttr").Params(jen.Id("name").String()).Add(objectResult.Clone()).Block(
+			jen.Switch(jen.Id("name")).Block(cases...),
+			jen.Return(jen.Nil(), jen.Qual(pathObject, "NewAttributeError").Call(jen.Lit(name+" has no attribute '%s'"), jen.Id("name"))),
+		),
+		jen.Func().Params(recv.Clone()).Id("Attributes").Params().Index().String().Block(
+			jen.Return(jen.Index().String().Values(names...)),
+		),
+	}
+	return append(code, methods...)
+}
+
+// snakeCase turns a Go name into a Goblin one: ReadAll into read_all,
+// HTTPServer into http_server.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Registry returns the Go source of modules.go in dir, the package that
+// imports the modules bindgen generated into its subdirectories, whose Go
+// packages are importBase/<name>, so that they register themselves.
+func Registry(dir, importBase string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), e.Name()+".go"))
+		if err != nil || !bytes.HasPrefix(data, []byte(generatedMarker)) {
+			continue
+		}
+		paths = append(paths, importBase+"/"+e.Name())
+	}
+	f := jen.NewFilePath(importBase)
+	f.HeaderComment(generatedMarker + ". DO NOT EDIT.")
+	f.Anon(paths...)
+	var buf bytes.Buffer
+	if err := f.Render(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,228 @@
+package bindgen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const shapesPackage = "github.com/aisk/goblin/bindgen/testdata/shapes"
+
+func TestSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"Read":        "read",
+		"ReadAll":     "read_all",
+		"HTTPServer":  "http_server",
+		"ParseURL":    "parse_url",
+		"ToValidUTF8": "to_valid_utf8",
+		"Atoi":        "atoi",
+		"MD5Sum":      "md5_sum",
+	}
+	for in, want := range cases {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"shapes", "go_json", "sha3"} {
+		if !ValidName(name) {
+			t.Errorf("ValidName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"", "Shapes", "3d", "_x", "go-json", "func", "select"} {
+		if ValidName(name) {
+			t.Errorf("ValidName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	b, err := Generate(shapesPackage, "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Name != "shapes" || b.GoPackage != shapesPackage {
+		t.Errorf("got module %s for %s", b.Name, b.GoPackage)
+	}
+	src := string(b.Source)
+	if !strings.HasPrefix(src, generatedMarker) {
+		t.Errorf("source does not start with %q", generatedMarker)
+	}
+	for _, want := range []string{
+		`"SIDES":`, `"MAX_SCALE":`, `"UNIT":`, `"new_rect":`, `"sum":`, `"join":`,
+		`case "area":`, `case "scale":`, `case "width":`,
+		`object.WrapNativeError(object.BaseError, "new_rect() NewRect failed", err)`,
+		`object.RegisterModule("shapes", "github.com/aisk/goblin/extension/gobind/shapes", Execute)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("source lacks %s", want)
+		}
+	}
+	if strings.Contains(src, `"hidden"`) {
+		t.Error("source binds an unexported field")
+	}
+	skipped := strings.Join(b.Skipped, "\n")
+	for _, want := range []string{"Apply: parameter f", "Copy: parameter w", "Count: package variables"} {
+		if !strings.Contains(skipped, want) {
+			t.Errorf("Skipped lacks %q:\n%s", want, skipped)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if _, err := Generate(shapesPackage, "Shapes", Options{}); err == nil || !strings.Contains(err.Error(), "invalid module name") {
+		t.Errorf("bad name: got %v", err)
+	}
+	if _, err := Generate("github.com/aisk/goblin", "", Options{}); err == nil || !strings.Contains(err.Error(), "is a command") {
+		t.Errorf("main package: got %v", err)
+	}
+	if _, err := Generate("example.com/no/such/package", "", Options{}); err == nil {
+		t.Error("missing package: got no error")
+	}
+}
+
+// TestGeneratedModule builds the binding of the shapes package with a
+// program that calls it the way Goblin code does.
+func TestGeneratedModule(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	// Inside testdata, so the package is in this module but not in ./... .
+	dir, err := os.MkdirTemp("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	pkgDir := filepath.Join(dir, "shapes")
+	b, err := Generate(shapesPackage, "", Options{ImportPath: GoblinModule + "/bindgen/" + filepath.ToSlash(pkgDir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "shapes.go"), b.Source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	program := `package main
+
+import (
+	"fmt"
+
+	_ "github.com/aisk/goblin/bindgen/` + filepath.ToSlash(pkgDir) + `"
+	"github.com/aisk/goblin/object"
+)
+
+func call(m object.Object, name string, args ...object.Object) object.Object {
+	fn, err := m.GetAttr(name)
+	if err != nil {
+		panic(err)
+	}
+	result, err := object.Call(fn, object.CallArgs{Positional: args})
+	if err != nil {
+		return object.String("error: " + err.Error())
+	}
+	return result
+}
+
+func main() {
+	info, ok := object.LookupModule("shapes")
+	if !ok {
+		panic("shapes is not registered")
+	}
+	m, err := info.Execute()
+	if err != nil {
+		panic(err)
+	}
+	sides, _ := m.GetAttr("SIDES")
+	fmt.Println(sides)
+	r := call(m, "new_rect", object.Float(2), object.Integer(3))
+	fmt.Println(r, call(r, "area"))
+	call(r, "scale", object.Integer(2))
+	width, _ := r.GetAttr("width")
+	fmt.Println(r, width)
+	fmt.Println(call(m, "new_rect", object.Float(-1), object.Float(1)))
+	fmt.Println(call(m, "sum", object.Integer(1), object.Integer(2), object.Integer(3)))
+	fmt.Println(call(m, "join", object.StringList([]string{"a", "b"}), object.String("-")))
+	fmt.Println(call(m, "join", object.String("ab"), object.String("-")))
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("go", "run", "./"+dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	want := `4
+2x3 6
+4x6 4
+error: new_rect() NewRect failed: negative side
+6
+a-b
+error: join() argument 'parts' must be list, got String
+`
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestVendor(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	// A checkout whose go.mod requires a third-party module, here a local
+	// one it is replaced by, as go get would leave it.
+	dir := t.TempDir()
+	files := map[string]string{
+		"root/go.mod":          "module example.com/root\n\ngo 1.20\n\nrequire example.com/lib v1.0.0\n\nreplace example.com/lib => ../lib\n",
+		"lib/go.mod":           "module example.com/lib\n\ngo 1.20\n",
+		"lib/LICENSE":          "license\n",
+		"lib/lib.go":           "package lib\n\nimport \"example.com/lib/util\"\n\nfunc Twice(n int) int { return util.Add(n, n) }\n",
+		"lib/lib_test.go":      "package lib\n",
+		"lib/util/util.go":     "package util\n\nfunc Add(a, b int) int { return a + b }\n",
+		"lib/unused/unused.go": "package unused\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(dir, "root")
+	// A file a previous version of the package had.
+	stale := filepath.Join(root, VendorDir, "example.com", "lib", "old.go")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modules, err := Vendor("example.com/lib", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 || modules[0] != "example.com/lib" {
+		t.Fatalf("modules = %v", modules)
+	}
+	var got []string
+	filepath.WalkDir(filepath.Join(root, VendorDir), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	want := []string{"_vendor/example.com/lib/LICENSE", "_vendor/example.com/lib/go.mod", "_vendor/example.com/lib/lib.go", "_vendor/example.com/lib/util/util.go"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("vendored %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package bindgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listedPackage is the part of go list's JSON output load reads.
+type listedPackage struct {
+	ImportPath      string
+	Name            string
+	Dir             string
+	CompiledGoFiles []string
+	ImportMap       map[string]string
+	DepOnly         bool
+	Error           *listError
+	DepsErrors      []*listError
+}
+
+type listError struct {
+	Err string
+}
+
+// load type-checks the package pattern names, resolved from dir, and the
+// packages it imports. go list picks the files for the build context and
+// runs cgo; the declarations are then checked from source with go/types,
+// rather than read from the compiler's export data, whose format changes
+// with the Go release. Function bodies are skipped: bindgen only reads
+// declarations.
+func load(pattern, dir string) (*types.Package, error) {
+	cmd := exec.Command("go", "list", "-e", "-deps", "-compiled",
+		"-json=ImportPath,Name,Dir,CompiledGoFiles,ImportMap,DepOnly,Error,DepsErrors", "--", pattern)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v\n%s", pattern, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	// -deps lists every package after the packages it imports.
+	var listed, matched []*listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		p := new(listedPackage)
+		if err := dec.Decode(p); err != nil {
+			return nil, fmt.Errorf("go list %s: %v", pattern, err)
+		}
+		listed = append(listed, p)
+		if !p.DepOnly {
+			matched = append(matched, p)
+		}
+	}
+	if len(matched) != 1 {
+		return nil, fmt.Errorf("%s matches %d packages, want one", pattern, len(matched))
+	}
+	target := matched[0]
+	if target.Error != nil {
+		return nil, fmt.Errorf("cannot load %s: %s", pattern, strings.TrimSpace(target.Error.Err))
+	}
+	if len(target.DepsErrors) > 0 {
+		return nil, fmt.Errorf("cannot load %s: %s", pattern, strings.TrimSpace(target.DepsErrors[0].Err))
+	}
+
+	fset := token.NewFileSet()
+	checked := map[string]*types.Package{"unsafe": types.Unsafe}
+	sizes := types.SizesFor("gc", build.Default.GOARCH)
+	for _, p := range listed {
+		if checked[p.ImportPath] != nil {
+			continue
+		}
+		files := make([]*ast.File, 0, len(p.CompiledGoFiles))
+		for _, name := range p.CompiledGoFiles {
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(p.Dir, name)
+			}
+			f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load %s: %v", p.ImportPath, err)
+			}
+			files = append(files, f)
+		}
+		importMap := p.ImportMap
+		conf := types.Config{
+			IgnoreFuncBodies: true,
+			Sizes:            sizes,
+			Importer: importerFunc(func(path string) (*types.Package, error) {
+				if mapped, ok := importMap[path]; ok {
+					path = mapped
+				}
+				if pkg := checked[path]; pkg != nil {
+					return pkg, nil
+				}
+				return nil, fmt.Errorf("go list did not report %s", path)
+			}),
+		}
+		pkg, err := conf.Check(p.ImportPath, fset, files, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load %s: %v", p.ImportPath, err)
+		}
+		checked[p.ImportPath] = pkg
+	}
+	return checked[target.ImportPath], nil
+}
+
+// importerFunc is a types.Importer.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
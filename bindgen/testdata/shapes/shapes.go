@@ -0,0 +1,70 @@
+// Package shapes is a package for bindgen's tests to bind.
+package shapes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sides is the number of sides of a square.
+const Sides = 4
+
+// MaxScale is the largest factor Scale accepts.
+const MaxScale = 10.5
+
+// Unit names the unit of lengths.
+const Unit = "cm"
+
+// Rect is a rectangle.
+type Rect struct {
+	Width, Height float64
+	Name          string
+	hidden        int
+}
+
+// NewRect returns a rectangle, or an error if a side is negative.
+func NewRect(width, height float64) (*Rect, error) {
+	if width < 0 || height < 0 {
+		return nil, errors.New("negative side")
+	}
+	return &Rect{Width: width, Height: height}, nil
+}
+
+// Area returns the area of r.
+func (r *Rect) Area() float64 { return r.Width * r.Height }
+
+// Scale multiplies the sides of r by f.
+func (r *Rect) Scale(f float64) { r.Width *= f; r.Height *= f }
+
+func (r *Rect) String() string { return fmt.Sprintf("%gx%g", r.Width, r.Height) }
+
+// Sum adds its arguments.
+func Sum(xs ...int) int {
+	n := 0
+	for _, x := range xs {
+		n += x
+	}
+	return n
+}
+
+// Join joins parts with sep.
+func Join(parts []string, sep string) string {
+	s := ""
+	for i, p := range parts {
+		if i > 0 {
+			s += sep
+		}
+		s += p
+	}
+	return s
+}
+
+// Apply calls f, which bindgen cannot bind.
+func Apply(f func(int) int, x int) int { return f(x) }
+
+// Copy copies r to w, which bindgen cannot bind.
+func Copy(w io.Writer, r io.Reader) error { _, err := io.Copy(w, r); return err }
+
+// Count is a package variable, which bindgen does not bind.
+var Count int
@@ -0,0 +1,137 @@
+package bindgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VendorDir is the directory of a Goblin checkout holding the third-party
+// packages the runtime imports, which goblin embeds so that build-exe can
+// build with -mod=vendor.
+const VendorDir = "_vendor"
+
+// vendoredPackage is the part of go list's JSON output Vendor reads.
+type vendoredPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *struct {
+		Path  string
+		Dir   string
+		GoMod string
+		Main  bool
+	}
+}
+
+// Vendor copies the packages of other modules that goPackage needs, itself
+// included, from where the go command keeps them into the _vendor directory
+// of the checkout at root, so executables build-exe compiles with the module
+// can be built offline. It copies the source files of each package, and the
+// LICENSE and go.mod of each module, and returns the paths of the modules.
+// go.mod must already require them, as `go get` leaves it.
+func Vendor(goPackage, root string) ([]string, error) {
+	cmd := exec.Command("go", "list", "-deps", "-json=ImportPath,Dir,Standard,Module", "--", goPackage)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v\n%s", goPackage, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	modules := map[string]bool{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var p vendoredPackage
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("go list %s: %v", goPackage, err)
+		}
+		if p.Standard || p.Module == nil || p.Module.Main {
+			continue
+		}
+		if err := copyPackage(p.Dir, filepath.Join(root, VendorDir, filepath.FromSlash(p.ImportPath))); err != nil {
+			return nil, err
+		}
+		if modules[p.Module.Path] {
+			continue
+		}
+		modules[p.Module.Path] = true
+		modDir := filepath.Join(root, VendorDir, filepath.FromSlash(p.Module.Path))
+		if err := copyFile(filepath.Join(p.Module.Dir, "LICENSE"), filepath.Join(modDir, "LICENSE")); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		// The runtime reads the go version of the module from it.
+		if p.Module.GoMod != "" {
+			if err := copyFile(p.Module.GoMod, filepath.Join(modDir, "go.mod")); err != nil {
+				return nil, err
+			}
+		}
+	}
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// copyPackage replaces the source files in dst with those of the package in
+// src: its Go files but tests, and its assembly and C files.
+func copyPackage(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	old, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, e := range old {
+		if e.Type().IsRegular() && isSource(e.Name()) {
+			if err := os.Remove(filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && isSource(e.Name()) {
+			if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isSource(name string) bool {
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".go", ".s", ".c", ".h":
+		return true
+	}
+	return false
+}
+
+// copyFile copies src to dst, which is writable even when src, in the
+// module cache, is not.
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
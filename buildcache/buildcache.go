@@ -0,0 +1,328 @@
+// Package buildcache keeps what `goblin build-exe` produces between runs: a
+// generated Go module per program, rewritten in place so Go's own build
+// cache can reuse the compiled runtime, and the executables built from
+// them, addressed by everything that went into the build.
+package buildcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staleLock is how old a lock must be before a build may assume whoever took
+// it died without releasing it.
+const staleLock = 10 * time.Minute
+
+// ErrBusy is returned by Lock when another build holds the lock.
+var ErrBusy = errors.New("build cache entry is in use")
+
+// Cache is a build cache directory:
+//
+//	modules/<id>/       the generated module of one program
+//	modules/<id>.lock   held while a build uses that module
+//	bin/<key>           an executable, named by its Key
+type Cache struct {
+	Dir string
+}
+
+// Open returns the cache in $GOBLIN_CACHE, or else in goblin under the user
+// cache directory. It returns nil, with no error, when GOBLIN_CACHE is off.
+func Open() (*Cache, error) {
+	dir := os.Getenv("GOBLIN_CACHE")
+	switch dir {
+	case "off":
+		return nil, nil
+	case "":
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot locate the build cache: %v; set GOBLIN_CACHE", err)
+		}
+		dir = filepath.Join(base, "goblin")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// ModuleDir returns the directory of the generated module for the program
+// whose entry file is source, creating it if needed. Each program gets its
+// own directory, at a path that stays the same from build to build.
+func (c *Cache) ModuleDir(source string) (string, error) {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.TrimSuffix(filepath.Base(abs), ".goblin") + "-" + hex.EncodeToString(sum[:8])
+	dir := filepath.Join(c.Dir, "modules", name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Lock takes the lock of a module directory and returns the function
+// releasing it, or ErrBusy when another build holds it. A lock older than
+// ten minutes is taken over, as its build must have died.
+func (c *Cache) Lock(moduleDir string) (unlock func(), err error) {
+	path := moduleDir + ".lock"
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < staleLock {
+			break
+		}
+		os.Remove(path)
+	}
+	return nil, ErrBusy
+}
+
+// Binary returns the cached executable with this key, marking it used.
+func (c *Cache) Binary(key string) (string, bool) {
+	path := filepath.Join(c.Dir, "bin", key)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// BinaryPath is where a build should write the executable it will Put under
+// key: a temporary name next to it, so a failed build leaves nothing behind.
+func (c *Cache) BinaryPath(key string) (string, error) {
+	dir := filepath.Join(c.Dir, "bin")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.tmp-%d", key, os.Getpid())), nil
+}
+
+// Put moves the executable a build wrote to BinaryPath into the cache and
+// returns its final path.
+func (c *Cache) Put(key, built string) (string, error) {
+	path := filepath.Join(c.Dir, "bin", key)
+	if err := os.Rename(built, path); err != nil {
+		os.Remove(built)
+		return "", err
+	}
+	return path, nil
+}
+
+// CleanStats counts what Clean removed.
+type CleanStats struct {
+	Binaries int
+	Modules  int
+	Bytes    int64
+}
+
+// Clean removes executables and modules not used for olderThan, or all of
+// them when olderThan is zero. Modules locked by a running build are kept.
+func (c *Cache) Clean(olderThan time.Duration) (CleanStats, error) {
+	var stats CleanStats
+	cutoff := time.Now().Add(-olderThan)
+	old := func(info fs.FileInfo) bool {
+		return olderThan == 0 || info.ModTime().Before(cutoff)
+	}
+
+	bins, err := os.ReadDir(filepath.Join(c.Dir, "bin"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return stats, err
+	}
+	for _, e := range bins {
+		info, err := e.Info()
+		if err != nil || !old(info) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, "bin", e.Name())); err != nil {
+			return stats, err
+		}
+		stats.Binaries++
+		stats.Bytes += info.Size()
+	}
+
+	modulesDir := filepath.Join(c.Dir, "modules")
+	modules, err := os.ReadDir(modulesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return stats, err
+	}
+	for _, e := range modules {
+		dir := filepath.Join(modulesDir, e.Name())
+		if !e.IsDir() {
+			// A lock whose module is gone, or left by a build that died.
+			if info, err := e.Info(); err == nil && strings.HasSuffix(e.Name(), ".lock") {
+				if _, err := os.Stat(strings.TrimSuffix(dir, ".lock")); err != nil || time.Since(info.ModTime()) > staleLock {
+					os.Remove(dir)
+				}
+			}
+			continue
+		}
+		// Every build rewrites go.mod, so its age is the module's.
+		info, err := os.Stat(filepath.Join(dir, "go.mod"))
+		if err == nil && !old(info) {
+			continue
+		}
+		unlock, err := c.Lock(dir)
+		if err != nil {
+			continue
+		}
+		size := dirSize(dir)
+		err = os.RemoveAll(dir)
+		unlock()
+		if err != nil {
+			return stats, err
+		}
+		stats.Modules++
+		stats.Bytes += size
+	}
+	return stats, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// Key addresses the executable built from the generated module in
+// moduleDir: it hashes the module's files, leaving out vendor/, whose
+// content the goblin executable determines, and parts, which must name
+// everything else the build depends on.
+func Key(moduleDir string, parts ...string) (string, error) {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:%s\n", len(p), p)
+	}
+	var files []string
+	err := filepath.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == filepath.Join(moduleDir, "vendor") {
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		rel, _ := filepath.Rel(moduleDir, path)
+		fmt.Fprintf(h, "file %s\n", filepath.ToSlash(rel))
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "\n%d\n", n)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var (
+	executableOnce sync.Once
+	executableHash string
+	executableErr  error
+)
+
+// ExecutableHash hashes the running goblin executable, which fixes the
+// transpiler and the embedded runtime a build used.
+func ExecutableHash() (string, error) {
+	executableOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			executableErr = err
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			executableErr = err
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			executableErr = err
+			return
+		}
+		executableHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return executableHash, executableErr
+}
+
+// toolchainVars are the go env settings that change what go build produces.
+var toolchainVars = []string{
+	"GOVERSION", "GOROOT", "GOOS", "GOARCH", "GOAMD64", "GOARM", "GOARM64", "GO386",
+	"GOWASM", "GOEXPERIMENT", "GOFLAGS", "CGO_ENABLED", "CC", "CGO_CFLAGS", "CGO_LDFLAGS",
+}
+
+// Toolchain describes the Go toolchain go build would use with env added to
+// the environment, as `go env` reports it.
+func Toolchain(env []string) (string, error) {
+	cmd := exec.Command("go", append([]string{"env"}, toolchainVars...)...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go env failed: %v", err)
+	}
+	return string(out), nil
+}
+
+// CopyFile copies the executable at src to dst.
+func CopyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,139 @@
+package buildcache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module prog\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	key := func(parts ...string) string {
+		t.Helper()
+		k, err := Key(dir, parts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	base := key("a")
+	if key("a") != base {
+		t.Fatal("Key is not deterministic")
+	}
+	if key("b") == base || key("a", "") == base {
+		t.Fatal("Key ignores its parts")
+	}
+	writeFile(t, filepath.Join(dir, "vendor", "modules.txt"), "# anything\n")
+	if key("a") != base {
+		t.Fatal("Key depends on vendor/")
+	}
+	writeFile(t, filepath.Join(dir, "main.go"), "package main // changed\n")
+	if key("a") == base {
+		t.Fatal("Key ignores file contents")
+	}
+}
+
+func TestLock(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	dir, err := c.ModuleDir("prog.goblin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := c.Lock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lock(dir); !errors.Is(err, ErrBusy) {
+		t.Fatalf("second Lock = %v, want ErrBusy", err)
+	}
+	unlock()
+	unlock, err = c.Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+
+	// A lock left by a build that died is taken over.
+	old := time.Now().Add(-2 * staleLock)
+	if err := os.Chtimes(dir+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lock(dir); err != nil {
+		t.Fatalf("Lock over a stale lock: %v", err)
+	}
+	unlock()
+}
+
+func TestPutAndClean(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	for _, key := range []string{"old", "new"} {
+		tmp, err := c.BinaryPath(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, tmp, key)
+		if _, err := c.Put(key, tmp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.Binary("missing"); ok {
+		t.Fatal("Binary found a missing key")
+	}
+	path, ok := c.Binary("new")
+	if !ok {
+		t.Fatal("Binary did not find a key that was Put")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Fatalf("cached binary = %q", data)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(c.Dir, "bin", "old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	oldModule, _ := c.ModuleDir("old.goblin")
+	writeFile(t, filepath.Join(oldModule, "go.mod"), "module old\n")
+	os.Chtimes(filepath.Join(oldModule, "go.mod"), old, old)
+	busyModule, _ := c.ModuleDir("busy.goblin")
+	unlock, err := c.Lock(busyModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	stats, err := c.Clean(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Binaries != 1 || stats.Modules != 1 {
+		t.Fatalf("Clean(24h) = %+v, want 1 executable and 1 module", stats)
+	}
+	if _, ok := c.Binary("new"); !ok {
+		t.Fatal("Clean removed a recent executable")
+	}
+	if _, err := os.Stat(busyModule); err != nil {
+		t.Fatal("Clean removed a locked module")
+	}
+
+	stats, err = c.Clean(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Binaries != 1 || stats.Modules != 0 {
+		t.Fatalf("Clean(0) = %+v, want 1 executable and no modules", stats)
+	}
+}
@@ -0,0 +1,1863 @@
+// Package cli implements the goblin command. The goblin command is a main
+// package that calls Main; so is every custom distribution goblin
+// build-distribution makes, with the packages of its extra modules imported
+// for their registrations.
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode"
+	"sync"
+	"time"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/bindgen"
+	"github.com/aisk/goblin/buildcache"
+	"github.com/aisk/goblin/coverage"
+	"github.com/aisk/goblin/dap"
+	"github.com/aisk/goblin/doc"
+	"github.com/aisk/goblin/debugger"
+	"github.com/aisk/goblin/distribution"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/format"
+	"github.com/aisk/goblin/interpreter"
+	"github.com/aisk/goblin/lint"
+	"github.com/aisk/goblin/lsp"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/profiler"
+	"github.com/aisk/goblin/semantic"
+	"github.com/aisk/goblin/testrunner"
+	"github.com/aisk/goblin/transpiler"
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:           "goblin",
+	Short:         "Goblin is a programming language that transpiles to Go",
+	Long:          "Goblin is a programming language that transpiles to Go.",
+	SilenceErrors: true,
+}
+
+var buildExeCmd = &cobra.Command{
+	Use:   "build-exe <source.goblin>",
+	Short: "Compile a Goblin source file to a native executable",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		sourceFile := args[0]
+
+		l, err := source.NewLexerFile(sourceFile)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", sourceFile, err)
+		}
+
+		p := parser.NewParser()
+		st, err := p.Parse(l)
+		if err != nil {
+			return err
+		}
+		m, ok := st.(*ast.Module)
+		if !ok {
+			return fmt.Errorf("internal error: unexpected AST type")
+		}
+		if err := semantic.CheckModule(m); err != nil {
+			return err
+		}
+
+		opts, err := buildExeOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		cache, buildDir, release, err := buildDirFor(cmd, sourceFile)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		profile, _ := cmd.Flags().GetBool("profile")
+		result, err := transpiler.TranspileToDirOptions(m, sourceFile, buildDir, transpiler.Options{Profile: profile})
+		if err != nil {
+			return err
+		}
+
+		// Determine output path. It must be absolute: go build runs in the
+		// build directory, where a relative -o would land.
+		out, _ := cmd.Flags().GetString("output")
+		if out == "" {
+			base := filepath.Base(sourceFile)
+			out = strings.TrimSuffix(base, ".goblin")
+			switch opts.goos {
+			case "windows":
+				out += ".exe"
+			case "wasip1", "js":
+				out += ".wasm"
+			}
+		}
+		if !filepath.IsAbs(out) {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+			out = filepath.Join(cwd, out)
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "build dir: %s\n", buildDir)
+		}
+
+		// A checkout's runtime can change without the key noticing, so
+		// only executables built against the embedded runtime are kept.
+		var key string
+		if cache != nil && result.Vendored {
+			key, err = binaryKey(buildDir, opts)
+			if err != nil {
+				return err
+			}
+			if bin, ok := cache.Binary(key); ok {
+				if verbose {
+					fmt.Fprintf(os.Stderr, "cached: %s\n", bin)
+				}
+				if err := buildcache.CopyFile(out, bin); err != nil {
+					return err
+				}
+				return finishBuild(cmd, sourceFile, out, opts, result)
+			}
+		}
+
+		target := out
+		if key != "" {
+			if target, err = cache.BinaryPath(key); err != nil {
+				return err
+			}
+		}
+		buildArgs := append([]string{"build", transpiler.ModFlag(buildDir)}, opts.args...)
+		buildArgs = append(buildArgs, "-o", target, ".")
+		goBuild := exec.Command("go", buildArgs...)
+		goBuild.Dir = buildDir
+		goBuild.Env = append(os.Environ(), opts.env...)
+		goBuild.Stdout = os.Stdout
+		goBuild.Stderr = os.Stderr
+		if verbose {
+			fmt.Fprintf(os.Stderr, "run: (cd %s && %s)\n", buildDir, shellCommand(append(opts.env, goBuild.Args...)))
+		}
+		if err = goBuild.Run(); err != nil {
+			os.Remove(target)
+			return fmt.Errorf("go build failed: %w", err)
+		}
+		if key != "" {
+			bin, err := cache.Put(key, target)
+			if err != nil {
+				return err
+			}
+			if err := buildcache.CopyFile(out, bin); err != nil {
+				return err
+			}
+		}
+		return finishBuild(cmd, sourceFile, out, opts, result)
+	},
+}
+
+// buildDirFor picks the directory build-exe generates the Go module in: the
+// program's module directory in the build cache, so Go reuses what it
+// compiled last time, or a temporary directory when the cache is off, is
+// bypassed with --no-cache, or is in use by another build of the program.
+// release removes or unlocks the directory. cache is nil unless the
+// directory is in the cache.
+func buildDirFor(cmd *cobra.Command, sourceFile string) (cache *buildcache.Cache, dir string, release func(), err error) {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); !noCache {
+		if cache, err = buildcache.Open(); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	if cache != nil {
+		if dir, err = cache.ModuleDir(sourceFile); err != nil {
+			return nil, "", nil, err
+		}
+		unlock, err := cache.Lock(dir)
+		if err == nil {
+			// Start from an empty module, so files of path imports the
+			// program dropped do not linger; vendor/ is rewritten anyway.
+			if err := clearModuleDir(dir); err != nil {
+				unlock()
+				return nil, "", nil, err
+			}
+			return cache, dir, unlock, nil
+		}
+		if !errors.Is(err, buildcache.ErrBusy) {
+			return nil, "", nil, err
+		}
+	}
+	dir, err = os.MkdirTemp("", "goblin-*")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	return nil, dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// clearModuleDir removes everything but vendor/ from a cached module.
+func clearModuleDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == "vendor" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// binaryKey names the executable go build would make of the module in dir
+// with opts: besides the module's own files, it covers the goblin
+// executable, which fixes the vendored runtime, the Go toolchain, and the
+// build flags.
+func binaryKey(dir string, opts *buildOptions) (string, error) {
+	goblin, err := buildcache.ExecutableHash()
+	if err != nil {
+		return "", err
+	}
+	toolchain, err := buildcache.Toolchain(opts.env)
+	if err != nil {
+		return "", err
+	}
+	return buildcache.Key(dir, goblin, toolchain, strings.Join(opts.args, "\x00"))
+}
+
+// finishBuild writes the manifest build-exe was asked for and reports the
+// executable.
+func finishBuild(cmd *cobra.Command, sourceFile, out string, opts *buildOptions, result *transpiler.Result) error {
+	if manifest, _ := cmd.Flags().GetString("manifest"); manifest != "" {
+		if err := writeBuildManifest(manifest, sourceFile, out, opts, result); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "built: %s\n", out)
+	return nil
+}
+
+// buildOptions are the go build settings build-exe's flags select.
+type buildOptions struct {
+	goos, goarch string
+	static       bool
+	strip        bool
+	trimpath     bool
+	race         bool
+	tags         []string
+	constants    map[string]string
+	args         []string // go build flags, before -o
+	env          []string // environment for go build
+}
+
+// buildExeOptions turns build-exe's flags into go build flags and
+// environment.
+func buildExeOptions(cmd *cobra.Command) (*buildOptions, error) {
+	flags := cmd.Flags()
+	opts := &buildOptions{goos: runtime.GOOS, goarch: runtime.GOARCH}
+	if target, _ := flags.GetString("target"); target != "" {
+		goos, goarch, ok := strings.Cut(target, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return nil, fmt.Errorf("invalid --target %q: want GOOS/GOARCH, such as linux/arm64 or wasip1/wasm", target)
+		}
+		opts.goos, opts.goarch = goos, goarch
+		opts.env = append(opts.env, "GOOS="+goos, "GOARCH="+goarch)
+	}
+	opts.static, _ = flags.GetBool("static")
+	opts.strip, _ = flags.GetBool("strip")
+	opts.trimpath, _ = flags.GetBool("trimpath")
+	opts.race, _ = flags.GetBool("race")
+	if opts.static {
+		opts.env = append(opts.env, "CGO_ENABLED=0")
+	}
+	if opts.race {
+		opts.args = append(opts.args, "-race")
+	}
+	if opts.trimpath {
+		opts.args = append(opts.args, "-trimpath")
+	}
+	if tags, _ := flags.GetString("tags"); tags != "" {
+		opts.tags = strings.Split(tags, ",")
+		opts.args = append(opts.args, "-tags", tags)
+	}
+	defines, _ := flags.GetStringArray("define")
+	constants, err := parseConstants(defines)
+	if err != nil {
+		return nil, err
+	}
+	opts.constants = constants
+
+	ldflags, _ := flags.GetString("ldflags")
+	if opts.strip {
+		ldflags = strings.TrimSpace(ldflags + " -s -w")
+	}
+	if len(constants) > 0 {
+		ldflags = strings.TrimSpace(ldflags + " -X " + extension.BuildConstantsVar + "=" + extension.EncodeBuildConstants(constants))
+	}
+	if ldflags != "" {
+		opts.args = append(opts.args, "-ldflags", ldflags)
+	}
+	return opts, nil
+}
+
+// parseConstants parses -X name=value flags into the constants the build
+// module reports.
+func parseConstants(defines []string) (map[string]string, error) {
+	constants := map[string]string{}
+	for _, d := range defines {
+		name, value, ok := strings.Cut(d, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid -X %q: want name=value", d)
+		}
+		constants[name] = value
+	}
+	return constants, nil
+}
+
+// shellCommand renders words as a command line a POSIX shell would split
+// back into them.
+func shellCommand(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		if w != "" && strings.Trim(w, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+,./:@") == "" {
+			quoted[i] = w
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(w, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// buildManifest is the JSON build-exe --manifest writes.
+type buildManifest struct {
+	Source    string            `json:"source"`
+	Output    string            `json:"output"`
+	Target    string            `json:"target"`
+	Static    bool              `json:"static"`
+	Strip     bool              `json:"strip"`
+	Trimpath  bool              `json:"trimpath"`
+	Race      bool              `json:"race"`
+	Tags      []string          `json:"tags"`
+	Constants map[string]string `json:"constants"`
+	Runtime   manifestRuntime   `json:"runtime"`
+	Modules   []manifestModule  `json:"modules"`
+}
+
+type manifestRuntime struct {
+	Version  string `json:"version"`
+	Vendored bool   `json:"vendored"`
+	Checkout string `json:"checkout,omitempty"`
+}
+
+type manifestModule struct {
+	Name   string `json:"name"`
+	Stdlib bool   `json:"stdlib"`
+	File   string `json:"file,omitempty"`
+}
+
+func writeBuildManifest(path, sourceFile, out string, opts *buildOptions, result *transpiler.Result) error {
+	source, err := filepath.Abs(sourceFile)
+	if err != nil {
+		return err
+	}
+	m := buildManifest{
+		Source:    source,
+		Output:    out,
+		Target:    opts.goos + "/" + opts.goarch,
+		Static:    opts.static,
+		Strip:     opts.strip,
+		Trimpath:  opts.trimpath,
+		Race:      opts.race,
+		Tags:      opts.tags,
+		Constants: opts.constants,
+		Runtime: manifestRuntime{
+			Version:  result.RuntimeVersion,
+			Vendored: result.Vendored,
+			Checkout: result.Checkout,
+		},
+		Modules: []manifestModule{},
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	for _, mod := range result.Modules {
+		m.Modules = append(m.Modules, manifestModule{Name: mod.Name, Stdlib: mod.File == "", File: mod.File})
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+var buildLibCmd = &cobra.Command{
+	Use:   "build-lib <module.goblin>",
+	Short: "Compile a Goblin module to a Go package or a C shared library",
+	Long: `Compile a Goblin module to a library other programs call.
+
+By default the module becomes a Go package, written as a Go module to the
+directory -o names (default: ./<package>). Each name the module exports gets
+a Go wrapper: a function takes and returns object.Object, a type gets
+New<Type>, and any other value a getter. object.FromGo and object.ToGo
+convert between Goblin and plain Go values.
+
+With --buildmode=c-shared or c-archive the package is compiled to a C
+library instead, with the C header go build writes next to it. Its exported
+functions, <package>_<name>, take their arguments as a JSON array and return
+the result as JSON.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		sourceFile := args[0]
+
+		m, err := parseChecked(sourceFile)
+		if err != nil {
+			return err
+		}
+		opts, err := buildExeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		flags := cmd.Flags()
+		verbose, _ := flags.GetBool("verbose")
+		module, _ := flags.GetString("module")
+		out, _ := flags.GetString("output")
+		pkg, _ := flags.GetString("package")
+		if pkg == "" {
+			pkg = libPackageName(sourceFile)
+		}
+		buildmode, _ := flags.GetString("buildmode")
+		var ext string
+		switch buildmode {
+		case "go":
+		case "c-shared":
+			ext = ".so"
+			switch opts.goos {
+			case "darwin", "ios":
+				ext = ".dylib"
+			case "windows":
+				ext = ".dll"
+			}
+		case "c-archive":
+			ext = ".a"
+		default:
+			return fmt.Errorf("invalid --buildmode %q: want go, c-shared or c-archive", buildmode)
+		}
+
+		if buildmode == "go" {
+			if out == "" {
+				out = pkg
+			}
+			_, err := transpiler.TranspileToDirOptions(m, sourceFile, out, transpiler.Options{
+				Package: pkg,
+				Module:  module,
+				Exports: true,
+			})
+			if err != nil {
+				return err
+			}
+			// Build the package, so errors in it show up now rather than
+			// in the program importing it.
+			if err := runGoBuild(out, opts, verbose, "./..."); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "built: %s\n", out)
+			return nil
+		}
+
+		if out == "" {
+			out = "lib" + pkg + ext
+		}
+		if out, err = filepath.Abs(out); err != nil {
+			return err
+		}
+		buildDir, err := os.MkdirTemp("", "goblin-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(buildDir)
+		_, err = transpiler.TranspileToDirOptions(m, sourceFile, buildDir, transpiler.Options{
+			Package:  pkg,
+			Module:   module,
+			CExports: true,
+		})
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "build dir: %s\n", buildDir)
+		}
+		opts.env = append(opts.env, "CGO_ENABLED=1")
+		if err := runGoBuild(buildDir, opts, verbose, "-buildmode="+buildmode, "-o", out, "./capi"); err != nil {
+			os.Remove(out)
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "built: %s\n", out)
+		return nil
+	},
+}
+
+// libPackageName is the Go package name build-lib gives a module by
+// default: the file name, with what a Go identifier cannot hold replaced.
+func libPackageName(sourceFile string) string {
+	name := []rune(strings.TrimSuffix(filepath.Base(sourceFile), ".goblin"))
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || string(name) == "main" {
+		return "lib"
+	}
+	return string(name)
+}
+
+// runGoBuild runs go build in the generated module in dir with the flags
+// opts selects, followed by args.
+func runGoBuild(dir string, opts *buildOptions, verbose bool, args ...string) error {
+	buildArgs := append([]string{"build", transpiler.ModFlag(dir)}, opts.args...)
+	goBuild := exec.Command("go", append(buildArgs, args...)...)
+	goBuild.Dir = dir
+	goBuild.Env = append(os.Environ(), opts.env...)
+	goBuild.Stdout = os.Stdout
+	goBuild.Stderr = os.Stderr
+	if verbose {
+		fmt.Fprintf(os.Stderr, "run: (cd %s && %s)\n", dir, shellCommand(append(opts.env, goBuild.Args...)))
+	}
+	if err := goBuild.Run(); err != nil {
+		return fmt.Errorf("go build failed: %w", err)
+	}
+	return nil
+}
+
+var buildDistributionCmd = &cobra.Command{
+	Use:   "build-distribution --with <package> [--with <package>...]",
+	Short: "Build a goblin command that includes the modules of extra Go packages",
+	Long: `Build a goblin command that includes the modules of extra Go packages.
+
+Each --with names a Go package that registers Goblin modules with
+object.RegisterModule in its init function: an import path, with an optional
+@version as go get takes it, or the directory of a local Go module, starting
+with . or /. The resulting command links them in, so "goblin run" imports
+their modules, and embeds their source, so "build-exe" builds programs that
+use them without network access. Building it needs a Go toolchain and, for
+packages not in the module cache, the network.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		with, _ := cmd.Flags().GetStringArray("with")
+		out, _ := cmd.Flags().GetString("output")
+		target, _ := cmd.Flags().GetString("target")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		if len(with) == 0 {
+			return fmt.Errorf("--with is required")
+		}
+
+		var env []string
+		goos := runtime.GOOS
+		if target != "" {
+			var goarch string
+			var ok bool
+			goos, goarch, ok = strings.Cut(target, "/")
+			if !ok || goos == "" || goarch == "" {
+				return fmt.Errorf("invalid --target %q: want OS/ARCH, such as linux/arm64", target)
+			}
+			env = append(env, "GOOS="+goos, "GOARCH="+goarch)
+		}
+		if out == "" {
+			out = "goblin"
+			if goos == "windows" {
+				out += ".exe"
+			}
+		}
+		out, err := filepath.Abs(out)
+		if err != nil {
+			return err
+		}
+
+		version, root := transpiler.RuntimeSource()
+		opts := distribution.Options{
+			With:          with,
+			Output:        out,
+			GoblinVersion: version,
+			GoblinRoot:    root,
+			Env:           env,
+		}
+		if verbose {
+			opts.Log = os.Stderr
+			if root != "" {
+				fmt.Fprintf(os.Stderr, "goblin: %s\n", root)
+			} else {
+				fmt.Fprintf(os.Stderr, "goblin: %s %s\n", bindgen.GoblinModule, version)
+			}
+		}
+		if err := distribution.Build(opts); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "built: %s\n", out)
+		return nil
+	},
+}
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove the modules and executables build-exe cached",
+	Long: `Remove the modules and executables build-exe cached.
+
+The cache lives in $GOBLIN_CACHE, or else in goblin under the user cache
+directory. With --older-than, only what no build used for that long is
+removed. Go's own build cache is left alone; "go clean -cache" empties it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cache, err := buildcache.Open()
+		if err != nil {
+			return err
+		}
+		if cache == nil {
+			fmt.Println("the build cache is off (GOBLIN_CACHE=off)")
+			return nil
+		}
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		stats, err := cache.Clean(olderThan)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d executables and %d modules, %.1f MB, from %s\n",
+			stats.Binaries, stats.Modules, float64(stats.Bytes)/(1<<20), cache.Dir)
+		return nil
+	},
+}
+
+var bindgenCmd = &cobra.Command{
+	Use:   "bindgen <go-package>",
+	Short: "Generate a module that calls a Go package, for both backends",
+	Long: `Generate a module that calls a Go package, for both backends.
+
+bindgen reads the exported functions, struct types and their methods, and
+constants of the package and writes a Goblin module calling them to
+extension/gobind/<name>/<name>.go, then lists it in extension/gobind/modules.go. It runs in a
+Goblin checkout, whose go.mod must provide the package ("go get" it first),
+and copies the sources of a third-party package and its dependencies into
+the checkout's _vendor directory, which build-exe builds executables from.
+The module is importable once goblin is rebuilt from it. What cannot be
+bound, such as functions taking callbacks or interfaces, is listed in the
+module's package comment.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		name, _ := cmd.Flags().GetString("name")
+		root, err := bindgen.FindCheckout(".")
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(root, "extension", "gobind")
+
+		b, err := bindgen.Generate(args[0], name, bindgen.Options{Dir: root})
+		if err != nil {
+			return err
+		}
+		if info, ok := object.LookupModule(b.Name); ok && info.Package != bindgen.GobindPath+"/"+b.Name || b.Name == "embed" {
+			return fmt.Errorf("module %s already exists; choose another name with --name", b.Name)
+		}
+
+		dir := filepath.Join(out, b.Name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		file := filepath.Join(dir, b.Name+".go")
+		if err := os.WriteFile(file, b.Source, 0644); err != nil {
+			return err
+		}
+		registry, err := bindgen.Registry(out, bindgen.GobindPath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(out, "modules.go"), registry, 0644); err != nil {
+			return err
+		}
+		// build-exe builds with -mod=vendor from the sources goblin embeds.
+		vendored, err := bindgen.Vendor(args[0], root)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "generated: %s (import \"%s\")\n", file, b.Name)
+		for _, s := range b.Skipped {
+			fmt.Fprintf(os.Stderr, "  not bound: %s\n", s)
+		}
+		for _, mod := range vendored {
+			fmt.Fprintf(os.Stderr, "vendored: %s\n", filepath.Join(root, bindgen.VendorDir, filepath.FromSlash(mod)))
+		}
+		fmt.Fprintf(os.Stderr, "rebuild goblin to use it: go install %s\n", root)
+		return nil
+	},
+}
+
+var transpileCmd = &cobra.Command{
+	Use:   "transpile <source.goblin> -o <dir>",
+	Short: "Write the Go module build-exe would compile, to read or build by hand",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		sourceFile := args[0]
+
+		m, err := parseChecked(sourceFile)
+		if err != nil {
+			return err
+		}
+
+		out, _ := cmd.Flags().GetString("output")
+		pkg, _ := cmd.Flags().GetString("package")
+		module, _ := cmd.Flags().GetString("module")
+		lineDirectives, _ := cmd.Flags().GetBool("line-directives")
+		readable, _ := cmd.Flags().GetBool("readable")
+		profile, _ := cmd.Flags().GetBool("profile")
+		_, err = transpiler.TranspileToDirOptions(m, sourceFile, out, transpiler.Options{
+			Package:          pkg,
+			Module:           module,
+			NoLineDirectives: !lineDirectives,
+			Readable:         readable,
+			Profile:          profile,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "transpiled: %s\n", out)
+		return nil
+	},
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run <source.goblin> [args...]",
+	Short: "Interpret a Goblin source file directly",
+	Long: `Interpret a Goblin source file directly, by walking its syntax tree or,
+with --vm, on a bytecode VM.
+
+The first argument must be the source file. All arguments after it are
+forwarded to the script as os.argv(), including flag-like values such as
+-h or --verbose. The only flags run takes are --coverage, --cpuprofile,
+--sandbox, --vm, and -X, which must come before the source file; other leading flags are rejected. CLI help is
+"goblin run -h" or "goblin help run" (alone, with no source file).
+
+--coverage=FILE records which lines run and writes the counts to FILE when
+the program ends: as JSON if FILE ends in .json, as an HTML report if it
+ends in .html, and otherwise in Go's coverprofile format.
+
+--cpuprofile=FILE samples the Goblin call stack while the program runs and
+writes a pprof profile of Goblin functions and lines to FILE, with call
+counts and cumulative time per function. Read it with "go tool pprof".
+Executables from "build-exe --profile" write the same profile when
+GOBLIN_CPUPROFILE names a file.
+
+--sandbox runs the program in a sandbox, for code that is not trusted: it
+cannot import exec, fs, os, http, or path, nor other .goblin files, and it
+stops with a limit error after 100000000 steps, 10 seconds, 1000 nested
+calls, 1000 goblins, or about 256 MiB of values. --sandbox=FILE reads the
+sandbox from a JSON file instead, whose keys override these defaults:
+"modules" (the modules it may import), "path_imports", "root" (a directory
+fs is confined to), "max_steps", "timeout" (such as "30s"), "max_depth",
+"max_goblins", and "max_alloc" (in bytes). A limit of 0 is no limit.
+
+--vm compiles the program, and the .goblin files it imports, to bytecode
+and runs it on a stack VM instead of walking its syntax tree, which is
+several times faster. Its output and tracebacks are the tree walker's; it
+does not support --sandbox yet.
+
+-X name=value sets a constant the build module's get() returns, as
+"goblin build-exe -X" does for executables. It may be repeated.`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if wantsHelp(args) {
+			return cmd.Help()
+		}
+		flags, args, err := takeRunFlags(args)
+		if err != nil {
+			return err
+		}
+		if err := requireSourceFirst(args); err != nil {
+			return err
+		}
+		sourceFile := args[0]
+		scriptArgs := args[1:]
+		m, err := parseChecked(sourceFile)
+		if err != nil {
+			return err
+		}
+		constants, err := parseConstants(flags.defines)
+		if err != nil {
+			return err
+		}
+		opts := interpreter.Options{Argv: append([]string{sourceFile}, scriptArgs...), Bytecode: flags.vm, BuildConstants: constants}
+		if flags.sandbox {
+			if opts.Sandbox, err = loadSandbox(flags.sandboxFile); err != nil {
+				return err
+			}
+		}
+
+		// Both outputs are written however the program ends, including
+		// through os.exit, which never returns here.
+		var finish []func() error
+		if flags.coverage != "" {
+			rec := coverage.NewRecorder()
+			opts.Coverage = rec
+			finish = append(finish, func() error { return writeCoverage(flags.coverage, rec.Profile()) })
+		}
+		if flags.cpuprofile != "" {
+			f, err := os.Create(flags.cpuprofile)
+			if err != nil {
+				return err
+			}
+			profile := profiler.New(f)
+			opts.Profile = profile
+			finish = append(finish, func() error {
+				err := profile.Stop()
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+				return err
+			})
+		}
+		var once sync.Once
+		var finishErr error
+		finishAll := func() error {
+			once.Do(func() {
+				for _, fn := range finish {
+					if err := fn(); err != nil && finishErr == nil {
+						finishErr = err
+					}
+				}
+			})
+			return finishErr
+		}
+		opts.AtExit = func(code int) {
+			if err := finishAll(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(code)
+		}
+		runErr := interpreter.RunWithOptions(m, sourceFile, opts)
+		if err := finishAll(); err != nil && runErr == nil {
+			return err
+		}
+		return runErr
+	},
+}
+
+// parseChecked parses a source file and runs the static semantic checks on
+// it, as run and debug do before interpreting it.
+func parseChecked(sourceFile string) (*ast.Module, error) {
+	s, err := source.NewScannerFile(sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", sourceFile, err)
+	}
+	st, err := parser.NewParser().Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := st.(*ast.Module)
+	if !ok {
+		return nil, fmt.Errorf("internal error: unexpected AST type")
+	}
+	doc.Attach(m, s)
+	if err := semantic.CheckModule(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var debugCmd = &cobra.Command{
+	Use:   "debug <source.goblin> [args...]",
+	Short: "Run a Goblin source file under the interactive debugger",
+	Long: `Run a Goblin source file under the interactive debugger.
+
+The program stops before its first statement. From there, set breakpoints
+by file:line or function name, optionally with a condition ("break
+fib if n == 2"), step in, over, and out of calls, print expressions and the
+local variables of any frame, and show the call stack. Type "help" at the
+(debug) prompt for the commands.
+
+As with run, all arguments after the source file are forwarded to the
+script as os.argv().`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if wantsHelp(args) {
+			return cmd.Help()
+		}
+		if err := requireSourceFirst(args); err != nil {
+			return err
+		}
+		sourceFile := args[0]
+		m, err := parseChecked(sourceFile)
+		if err != nil {
+			return err
+		}
+		d := debugger.New(true)
+		d.Start(m, sourceFile, args[1:]...)
+		return debugger.NewCLI(d, os.Stdin, os.Stdout).Run()
+	},
+}
+
+// runFlags are the flags run accepts before the source file.
+type runFlags struct {
+	coverage   string
+	cpuprofile string
+	defines    []string
+	// sandbox is set by --sandbox, and sandboxFile by --sandbox=FILE.
+	sandbox     bool
+	sandboxFile string
+	// vm is set by --vm.
+	vm bool
+}
+
+// takeRunFlags removes run's own leading flags from its arguments:
+// --coverage and --cpuprofile, each as --flag=FILE or --flag FILE,
+// --sandbox or --sandbox=FILE, --vm, and -X name=value.
+func takeRunFlags(args []string) (runFlags, []string, error) {
+	var flags runFlags
+	for len(args) > 0 {
+		if args[0] == "--vm" {
+			flags.vm = true
+			args = args[1:]
+			continue
+		}
+		if name, value, hasValue := strings.Cut(args[0], "="); name == "--sandbox" {
+			// A bare --sandbox takes no value: the next argument is the
+			// source file.
+			if hasValue && value == "" {
+				return flags, nil, fmt.Errorf("--sandbox= requires a file name")
+			}
+			flags.sandbox, flags.sandboxFile = true, value
+			args = args[1:]
+			continue
+		}
+		if args[0] == "-X" {
+			if len(args) < 2 {
+				return flags, nil, fmt.Errorf("-X requires name=value")
+			}
+			flags.defines = append(flags.defines, args[1])
+			args = args[2:]
+			continue
+		}
+		var target *string
+		name, value, hasValue := strings.Cut(args[0], "=")
+		switch name {
+		case "--coverage":
+			target = &flags.coverage
+		case "--cpuprofile":
+			target = &flags.cpuprofile
+		default:
+			return flags, args, nil
+		}
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return flags, nil, fmt.Errorf("%s requires a file name", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if value == "" {
+			return flags, nil, fmt.Errorf("%s requires a file name", name)
+		}
+		*target = value
+	}
+	return flags, args, nil
+}
+
+// sandboxConfig is the JSON form of a sandbox --sandbox=FILE reads. Absent
+// keys keep the values of interpreter.DefaultSandbox.
+type sandboxConfig struct {
+	Modules     *[]string `json:"modules"`
+	PathImports *bool     `json:"path_imports"`
+	Root        *string   `json:"root"`
+	MaxSteps    *int64    `json:"max_steps"`
+	Timeout     *string   `json:"timeout"`
+	MaxDepth    *int64    `json:"max_depth"`
+	MaxGoblins  *int64    `json:"max_goblins"`
+	MaxAlloc    *int64    `json:"max_alloc"`
+}
+
+// loadSandbox returns the sandbox file describes, or the default sandbox
+// when file is empty.
+func loadSandbox(file string) (*interpreter.Sandbox, error) {
+	sb := interpreter.DefaultSandbox()
+	if file == "" {
+		return sb, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config sandboxConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if config.Modules != nil {
+		sb.Modules = append([]string{}, *config.Modules...)
+	}
+	if config.PathImports != nil {
+		sb.PathImports = *config.PathImports
+	}
+	if config.Root != nil {
+		sb.Root = *config.Root
+	}
+	if config.Timeout != nil {
+		timeout, err := time.ParseDuration(*config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("%s: timeout: %v", file, err)
+		}
+		sb.Timeout = timeout
+	}
+	for _, limit := range []struct {
+		value  *int64
+		target *int64
+	}{
+		{config.MaxSteps, &sb.MaxSteps},
+		{config.MaxDepth, &sb.MaxDepth},
+		{config.MaxGoblins, &sb.MaxGoblins},
+		{config.MaxAlloc, &sb.MaxAlloc},
+	} {
+		if limit.value != nil {
+			*limit.target = *limit.value
+		}
+	}
+	return sb, nil
+}
+
+// writeCoverage writes a coverage profile in the format FILE's extension
+// names: .json, .html, or anything else for a Go coverprofile.
+func writeCoverage(file string, p *coverage.Profile) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = p.WriteJSON(f)
+	case ".html":
+		err = p.WriteHTML(f)
+	default:
+		err = p.WriteGo(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var coverCmd = &cobra.Command{
+	Use:   "cover [flags] <profile>",
+	Short: "Summarize a coverage profile or render it as HTML",
+	Long: `Summarize a coverage profile or render it as HTML.
+
+The profile is one written by "goblin run --coverage", in JSON or Go's
+coverprofile format. By default cover prints the share of lines run in each
+file and in total. --html writes a report showing each file's source with
+the lines that ran and those that did not.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		htmlFile, _ := cmd.Flags().GetString("html")
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		p, err := coverage.Read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if htmlFile != "" {
+			out, err := os.Create(htmlFile)
+			if err != nil {
+				return err
+			}
+			if err := p.WriteHTML(out); err != nil {
+				out.Close()
+				return err
+			}
+			return out.Close()
+		}
+		out := cmd.OutOrStdout()
+		for _, file := range p.Files {
+			covered, total := file.Covered()
+			fmt.Fprintf(out, "%s\t%d/%d\t%.1f%%\n", file.Path, covered, total, coverage.Percent(covered, total))
+		}
+		covered, total := p.Covered()
+		fmt.Fprintf(out, "total\t%d/%d\t%.1f%%\n", covered, total, coverage.Percent(covered, total))
+		return nil
+	},
+}
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive Goblin REPL",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runREPL()
+	},
+}
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run the Goblin language server over stdio",
+	Long: `Run the Goblin language server over stdio.
+
+Editors start it as a subprocess and talk the Language Server Protocol on its
+stdin and stdout. It reports parse and semantic errors as diagnostics and
+provides completion, hover, go-to-definition, find references, and document
+symbols.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return lsp.Serve(os.Stdin, os.Stdout)
+	},
+}
+
+var dapCmd = &cobra.Command{
+	Use:   "dap [flags]",
+	Short: "Run the Goblin Debug Adapter Protocol server",
+	Long: `Run the Goblin Debug Adapter Protocol server.
+
+Editors start it as a subprocess and talk the Debug Adapter Protocol on its
+stdin and stdout, or, with --listen, connect to it on a TCP address instead;
+it serves one session and exits. A launch request names the program and its
+arguments, which reach the script as os.argv(). The session supports line,
+function, and conditional breakpoints, stepping, pausing, a thread per
+goblin, stack traces, scopes and variables, and evaluate requests. The
+program's output is sent to the editor as output events.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		addr, _ := cmd.Flags().GetString("listen")
+		if addr == "" {
+			return dap.Serve(os.Stdin, os.Stdout)
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "listening on %s\n", ln.Addr())
+		conn, err := ln.Accept()
+		ln.Close()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return dap.Serve(conn, conn)
+	},
+}
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [flags] [path ...]",
+	Short: "Format Goblin source files in the canonical style",
+	Long: `Format Goblin source files in the canonical style.
+
+Each path is a .goblin file or a directory searched recursively for them.
+Without paths, fmt formats standard input. By default the formatted source
+is printed; -w writes it back to the files instead, -d prints a diff, and
+--check lists the files that are not formatted and fails if there are any.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		write, _ := cmd.Flags().GetBool("write")
+		diff, _ := cmd.Flags().GetBool("diff")
+		check, _ := cmd.Flags().GetBool("check")
+		out := cmd.OutOrStdout()
+
+		if len(args) == 0 {
+			if write {
+				return fmt.Errorf("cannot use -w with standard input")
+			}
+			src, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			formatted, err := format.Source("<stdin>", src)
+			if err != nil {
+				return err
+			}
+			if diff {
+				out.Write(format.Diff("<stdin>.orig", "<stdin>", src, formatted))
+			}
+			if check && !bytes.Equal(src, formatted) {
+				return fmt.Errorf("<stdin> is not formatted")
+			}
+			if !diff && !check {
+				out.Write(formatted)
+			}
+			return nil
+		}
+
+		files, err := goblinFiles(args)
+		if err != nil {
+			return err
+		}
+		failed, unformatted := 0, 0
+		for _, path := range files {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				failed++
+				continue
+			}
+			formatted, err := format.Source(path, src)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				failed++
+				continue
+			}
+			changed := !bytes.Equal(src, formatted)
+			if changed {
+				unformatted++
+			}
+			if check && changed {
+				fmt.Fprintln(out, path)
+			}
+			if diff {
+				out.Write(format.Diff(path+".orig", path, src, formatted))
+			}
+			if write && changed {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, formatted, info.Mode().Perm()); err != nil {
+					return err
+				}
+			}
+			if !write && !diff && !check {
+				out.Write(formatted)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d file(s) could not be formatted", failed)
+		}
+		if check && unformatted > 0 {
+			return fmt.Errorf("%d file(s) not formatted", unformatted)
+		}
+		return nil
+	},
+}
+
+var docCmd = &cobra.Command{
+	Use:   "doc [flags] <module.goblin> ...",
+	Short: "Show the documentation of Goblin modules",
+	Long: `Show the documentation of Goblin modules.
+
+A comment directly above a func, type, method, or export declaration is its
+doc comment; a comment at the top of the file, followed by a blank line,
+documents the module. doc prints the signatures of the module's functions
+and types, with default values, *args, and **kwargs, the fields and methods
+of each type, and the names it exports, each with its doc comment. A module
+that exports names is shown as importers see it, through those names only,
+unless --all is given.
+
+--markdown writes a page for an mdBook such as the one in docs/, and --html
+a standalone page. With --output, each module is written to a file named
+after it in that directory instead of to standard output.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		all, _ := cmd.Flags().GetBool("all")
+		markdown, _ := cmd.Flags().GetBool("markdown")
+		html, _ := cmd.Flags().GetBool("html")
+		outDir, _ := cmd.Flags().GetString("output")
+		render, ext := doc.Text, ".txt"
+		switch {
+		case markdown && html:
+			return fmt.Errorf("--markdown and --html cannot be used together")
+		case markdown:
+			render, ext = doc.Markdown, ".md"
+		case html:
+			render, ext = doc.HTML, ".html"
+			if outDir == "" && len(args) > 1 {
+				return fmt.Errorf("--html writes one page per module; use --output for several modules")
+			}
+		}
+
+		for i, path := range args {
+			m, err := doc.File(path, doc.Options{All: all})
+			if err != nil {
+				return err
+			}
+			if outDir == "" {
+				if i > 0 {
+					fmt.Fprintln(cmd.OutOrStdout())
+				}
+				if err := render(cmd.OutOrStdout(), m); err != nil {
+					return err
+				}
+				continue
+			}
+			var b bytes.Buffer
+			if err := render(&b, m); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(outDir, 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(outDir, m.Name+ext), b.Bytes(), 0o644); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:     "check [flags] [path ...]",
+	Aliases: []string{"lint"},
+	Short:   "Report errors and likely mistakes in Goblin source files",
+	Long: `Report errors and likely mistakes in Goblin source files.
+
+Each path is a .goblin file or a directory searched recursively for them.
+Without paths, check reads standard input. Every syntax and semantic error
+is reported, not only the first, along with lint warnings such as unused
+variables and unreachable code. A "# goblin:ignore" comment silences lint
+rules on its line, or on the next line when it stands alone; naming rules
+after it ("# goblin:ignore unused-variable") silences only those. check
+exits non-zero if anything is reported.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		asJSON, _ := cmd.Flags().GetBool("json")
+		out := cmd.OutOrStdout()
+
+		type fileDiagnostics struct {
+			path  string
+			diags []lint.Diagnostic
+		}
+		var results []fileDiagnostics
+		if len(args) == 0 {
+			src, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			results = append(results, fileDiagnostics{"<stdin>", lint.Source("<stdin>", src)})
+		} else {
+			files, err := goblinFiles(args)
+			if err != nil {
+				return err
+			}
+			for _, path := range files {
+				diags, err := lint.File(path)
+				if err != nil {
+					return err
+				}
+				results = append(results, fileDiagnostics{path, diags})
+			}
+		}
+
+		type jsonDiagnostic struct {
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+			Severity string `json:"severity"`
+			Rule     string `json:"rule"`
+			Message  string `json:"message"`
+		}
+		reported := []jsonDiagnostic{}
+		count := 0
+		for _, result := range results {
+			for _, diag := range result.diags {
+				count++
+				if !asJSON {
+					fmt.Fprintf(out, "%s:%d:%d: %s: %s (%s)\n", result.path, diag.Pos.Line, diag.Pos.Column, diag.Severity, diag.Message, diag.Rule)
+					continue
+				}
+				reported = append(reported, jsonDiagnostic{
+					File:     result.path,
+					Line:     diag.Pos.Line,
+					Column:   diag.Pos.Column,
+					Severity: string(diag.Severity),
+					Rule:     diag.Rule,
+					Message:  diag.Message,
+				})
+			}
+		}
+		if asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(reported); err != nil {
+				return err
+			}
+		}
+		if count > 0 {
+			return fmt.Errorf("%d problem(s) found", count)
+		}
+		return nil
+	},
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test [flags] [path ...]",
+	Short: "Run the tests in Goblin test files",
+	Long: `Run the tests in Goblin test files.
+
+Each path is a test file or a directory searched recursively for files
+named *_test.goblin; the default is the current directory. A test is a
+module-level function whose name starts with test_, called with no
+arguments. Each test runs in a fresh copy of its module: it passes if it
+returns, is skipped if it calls testing.skip, and fails if it raises. Tests
+run under the interpreter, or with --backend transpiler as a compiled
+binary per file. test exits non-zero if any test fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		run, _ := cmd.Flags().GetString("run")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		backend, _ := cmd.Flags().GetString("backend")
+		junit, _ := cmd.Flags().GetString("junit")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		out := cmd.OutOrStdout()
+
+		opts := testrunner.Options{Parallel: parallel, Backend: testrunner.Backend(backend), Stdout: out}
+		if opts.Backend != testrunner.Interpreter && opts.Backend != testrunner.Transpiler {
+			return fmt.Errorf("unknown backend %q: want interpreter or transpiler", backend)
+		}
+		if run != "" {
+			re, err := regexp.Compile(run)
+			if err != nil {
+				return fmt.Errorf("invalid --run pattern: %w", err)
+			}
+			opts.Run = re
+		}
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		var files []string
+		for _, path := range args {
+			found, err := goblinFiles([]string{path})
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files = append(files, found...)
+				continue
+			}
+			for _, file := range found {
+				if testrunner.IsTestFile(file) {
+					files = append(files, file)
+				}
+			}
+		}
+
+		start := time.Now()
+		passed, failed, skipped := 0, 0, 0
+		results := testrunner.Run(files, opts, func(result testrunner.Result) {
+			switch result.Status {
+			case testrunner.Pass:
+				passed++
+				if !verbose {
+					return
+				}
+			case testrunner.Fail:
+				failed++
+			case testrunner.Skip:
+				skipped++
+			}
+			fmt.Fprintf(out, "--- %s: %s: %s (%.2fs)\n", strings.ToUpper(string(result.Status)), result.File, result.Name, result.Duration.Seconds())
+			if result.Message != "" {
+				for _, line := range strings.Split(strings.TrimRight(result.Message, "\n"), "\n") {
+					fmt.Fprintf(out, "    %s\n", line)
+				}
+			}
+		})
+		status := "ok"
+		if failed > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(out, "%s: %d passed, %d failed, %d skipped (%.2fs)\n", status, passed, failed, skipped, time.Since(start).Seconds())
+
+		if junit != "" {
+			f, err := os.Create(junit)
+			if err != nil {
+				return err
+			}
+			if err := testrunner.WriteJUnit(f, results); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d test(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// goblinFiles expands directories in paths to the .goblin files below them,
+// skipping hidden directories. Files named explicitly are kept whatever
+// their extension.
+func goblinFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && filepath.Ext(p) == ".goblin" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// goblinHistoryPath returns the path to the persistent REPL history file,
+// defaulting to ~/.goblin_history.
+func goblinHistoryPath() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".goblin_history")
+	}
+	return ""
+}
+
+// runREPL drives a read-eval-print loop, accumulating lines until brackets are
+// balanced so multi-line constructs (functions, types, blocks) can be entered.
+// It uses readline for line editing, history, and Ctrl-C/Ctrl-D handling.
+func runREPL() error {
+	session := interpreter.NewSession(".")
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          ">>> ",
+		HistoryFile:     goblinHistoryPath(),
+		HistoryLimit:    1000,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+		AutoComplete:    &replCompleter{session: session},
+	})
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+
+	out := rl.Stdout()
+	fmt.Fprintln(out, "Goblin REPL. Press Ctrl-D to exit.")
+	var buf strings.Builder
+	for {
+		if buf.Len() == 0 {
+			rl.SetPrompt(">>> ")
+		} else {
+			rl.SetPrompt("... ")
+		}
+
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			// On Ctrl-C: drop any in-progress multi-line input; exit if buffer
+			// was already empty and the user confirms by sending EOF.
+			buf.Reset()
+			continue
+		}
+		if err == io.EOF {
+			if buf.Len() > 0 {
+				evalLine(out, session, buf.String())
+			}
+			fmt.Fprintln(out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		// Keep reading until brackets balance, unless the line is blank (which
+		// forces evaluation so the user can recover from a typo).
+		if !bracketsBalanced(buf.String()) && strings.TrimSpace(line) != "" {
+			continue
+		}
+
+		src := buf.String()
+		buf.Reset()
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
+		evalLine(out, session, src)
+	}
+}
+
+var replKeywords = []string{
+	"break", "catch", "continue", "else", "export", "false", "for", "func",
+	"if", "import", "in", "nil", "raise", "return", "true", "try", "type",
+	"var", "while",
+}
+
+type replCompleter struct {
+	session *interpreter.Session
+}
+
+// Do completes ASCII Goblin identifiers and side-effect-free member paths.
+// Readline expects each candidate to contain only the suffix that should be
+// inserted at the cursor.
+func (c *replCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	path, prefix, ok := completionPath(line, pos)
+	if !ok {
+		return nil, 0
+	}
+
+	names := c.session.CompletionCandidates(path)
+	if len(path) == 0 {
+		names = append(names, replKeywords...)
+		sort.Strings(names)
+	}
+
+	candidates := make([][]rune, 0, len(names))
+	previous := ""
+	for _, name := range names {
+		if name == previous || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		previous = name
+		candidates = append(candidates, []rune(name[len(prefix):]))
+	}
+	return candidates, len([]rune(prefix))
+}
+
+func completionPath(line []rune, pos int) (path []string, prefix string, ok bool) {
+	if pos < 0 || pos > len(line) {
+		return nil, "", false
+	}
+	start := pos
+	for start > 0 && (isIdentifierRune(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	fragment := string(line[start:pos])
+	parts := strings.Split(fragment, ".")
+	for i, part := range parts {
+		if part == "" && i != len(parts)-1 {
+			return nil, "", false
+		}
+		if part != "" && !isIdentifierStart(rune(part[0])) {
+			return nil, "", false
+		}
+	}
+	return parts[:len(parts)-1], parts[len(parts)-1], true
+}
+
+func isIdentifierRune(r rune) bool {
+	return isIdentifierStart(r) || r >= '0' && r <= '9'
+}
+
+func isIdentifierStart(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+}
+
+func evalLine(out io.Writer, session *interpreter.Session, src string) {
+	result, err := session.Eval(src)
+	var exit *extension.ExitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "%+v\n", err)
+		return
+	}
+	// Display the value of an expression, but stay quiet for statements and
+	// for `none` (e.g. the result of print()).
+	if result == nil {
+		return
+	}
+	if _, isUnit := result.(object.Unit); isUnit {
+		return
+	}
+	fmt.Fprintln(out, fmt.Sprint(result))
+}
+
+// bracketsBalanced reports whether all (), [], {} are closed, ignoring those
+// inside string literals and # comments.
+func bracketsBalanced(src string) bool {
+	depth := 0
+	inString := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// wantsHelp reports whether args are only -h/--help (goblin run's own help).
+// Flags after the source file are forwarded to the script; leading flags are
+// rejected by requireSourceFirst.
+func wantsHelp(args []string) bool {
+	return len(args) == 1 && (args[0] == "-h" || args[0] == "--help")
+}
+
+// requireSourceFirst requires the first argument to be a source path, not a flag.
+// Flag-like values belong after the source file so they reach os.argv().
+func requireSourceFirst(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("source file required")
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("source file required before script arguments; got %q (flag-like). Use \"goblin run -h\" or \"goblin help run\" for help", args[0])
+	}
+	return nil
+}
+
+func init() {
+	buildExeCmd.Flags().StringP("output", "o", "", "output binary path (default: <source_name> in current directory)")
+	buildExeCmd.Flags().BoolP("verbose", "v", false, "print the temporary build directory and go build command")
+	buildExeCmd.Flags().Bool("race", false, "build with the Go race detector enabled")
+	buildExeCmd.Flags().String("target", "", "GOOS/GOARCH to build for, such as linux/arm64 or wasip1/wasm (default: this machine)")
+	buildExeCmd.Flags().Bool("static", false, "build without cgo, for a binary that needs no C libraries")
+	buildExeCmd.Flags().Bool("strip", false, "leave the symbol table and DWARF debug information out of the binary")
+	buildExeCmd.Flags().Bool("trimpath", false, "remove file system paths from the binary")
+	buildExeCmd.Flags().String("tags", "", "comma-separated Go build tags")
+	buildExeCmd.Flags().String("ldflags", "", "extra flags for the Go linker")
+	buildExeCmd.Flags().StringArrayP("define", "X", nil, "set a build constant scripts read with build.get(), as name=value (repeatable)")
+	buildExeCmd.Flags().String("manifest", "", "write a JSON build manifest to this file")
+	buildExeCmd.Flags().Bool("no-cache", false, "build in a temporary directory, neither using nor filling the build cache")
+	buildExeCmd.Flags().Bool("profile", false, "instrument the executable for the Goblin profiler, which GOBLIN_CPUPROFILE=FILE then starts")
+	rootCmd.AddCommand(buildExeCmd)
+	buildLibCmd.Flags().StringP("output", "o", "", "output directory, or library file with --buildmode=c-shared or c-archive (default: <package>, or lib<package>.so)")
+	buildLibCmd.Flags().String("package", "", "Go package name (default: <source_name>)")
+	buildLibCmd.Flags().String("module", "", "Go module path for go.mod (default: <source_name>)")
+	buildLibCmd.Flags().String("buildmode", "go", "go for a Go package, or c-shared or c-archive for a C library")
+	buildLibCmd.Flags().BoolP("verbose", "v", false, "print the temporary build directory and go build command")
+	buildLibCmd.Flags().String("target", "", "GOOS/GOARCH to build for (default: this machine)")
+	buildLibCmd.Flags().Bool("trimpath", false, "remove file system paths from the library")
+	buildLibCmd.Flags().String("tags", "", "comma-separated Go build tags")
+	buildLibCmd.Flags().String("ldflags", "", "extra flags for the Go linker")
+	rootCmd.AddCommand(buildLibCmd)
+	buildDistributionCmd.Flags().StringArray("with", nil, "Go package or local module directory whose modules to include (repeatable)")
+	buildDistributionCmd.Flags().StringP("output", "o", "", "output binary path (default: goblin in the current directory)")
+	buildDistributionCmd.Flags().String("target", "", "GOOS/GOARCH to build for (default: this machine)")
+	buildDistributionCmd.Flags().BoolP("verbose", "v", false, "print the go commands as they run")
+	rootCmd.AddCommand(buildDistributionCmd)
+	cleanCmd.Flags().Duration("older-than", 0, "only remove entries unused for this long, such as 720h")
+	rootCmd.AddCommand(cleanCmd)
+	bindgenCmd.Flags().String("name", "", "module name in Goblin (default: the Go package name)")
+	rootCmd.AddCommand(bindgenCmd)
+	transpileCmd.Flags().StringP("output", "o", "", "directory to write the Go module to")
+	transpileCmd.MarkFlagRequired("output")
+	transpileCmd.Flags().String("package", "", "emit a library package with this name instead of package main")
+	transpileCmd.Flags().String("module", "", "Go module path for go.mod (default: <source_name>)")
+	transpileCmd.Flags().Bool("line-directives", true, "emit //line comments mapping Go positions back to the Goblin source")
+	transpileCmd.Flags().Bool("readable", false, "number temporaries from 0 in each function")
+	transpileCmd.Flags().Bool("profile", false, "instrument the generated code for the Goblin profiler, as build-exe --profile does")
+	rootCmd.AddCommand(transpileCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(debugCmd)
+	coverCmd.Flags().String("html", "", "write an HTML report to this file")
+	rootCmd.AddCommand(coverCmd)
+	rootCmd.AddCommand(replCmd)
+	rootCmd.AddCommand(lspCmd)
+	dapCmd.Flags().String("listen", "", "serve one session on this TCP address, such as 127.0.0.1:4711, instead of stdio")
+	rootCmd.AddCommand(dapCmd)
+	fmtCmd.Flags().BoolP("write", "w", false, "write the result to the source files instead of printing it")
+	fmtCmd.Flags().BoolP("diff", "d", false, "print a diff of the changes formatting would make")
+	fmtCmd.Flags().Bool("check", false, "list files that are not formatted and exit non-zero if any")
+	rootCmd.AddCommand(fmtCmd)
+	checkCmd.Flags().Bool("json", false, "print the diagnostics as a JSON array")
+	rootCmd.AddCommand(checkCmd)
+	docCmd.Flags().Bool("all", false, "document every top-level definition, not only the exported ones")
+	docCmd.Flags().Bool("markdown", false, "write Markdown for an mdBook")
+	docCmd.Flags().Bool("html", false, "write a standalone HTML page")
+	docCmd.Flags().StringP("output", "o", "", "write each module to a file in this directory")
+	rootCmd.AddCommand(docCmd)
+	testCmd.Flags().String("run", "", "run only the tests whose names match this regular expression")
+	testCmd.Flags().IntP("parallel", "p", 1, "number of tests to run at once")
+	testCmd.Flags().String("backend", "interpreter", "run tests with the interpreter or the transpiler")
+	testCmd.Flags().String("junit", "", "write a JUnit XML report to this file")
+	testCmd.Flags().BoolP("verbose", "v", false, "also list the tests that pass")
+	rootCmd.AddCommand(testCmd)
+}
+
+// goTestFlags rewrites the -run flag of goblin test, as go test spells it,
+// to --run, since single-dash flags are otherwise shorthands: -run would be
+// -r -u -n. Other commands' arguments, and those after --, are left alone.
+func goTestFlags(args []string) []string {
+	if cmd, _, err := rootCmd.Find(args); err != nil || cmd != testCmd {
+		return args
+	}
+	out := make([]string, len(args))
+	copy(out, args)
+	for i, arg := range out {
+		if arg == "--" {
+			break
+		}
+		if arg == "-run" || strings.HasPrefix(arg, "-run=") {
+			out[i] = "-" + arg
+		}
+	}
+	return out
+}
+
+// Main runs the goblin command with the arguments of the process and exits
+// when it fails.
+func Main() {
+	rootCmd.SetArgs(goTestFlags(os.Args[1:]))
+	if err := rootCmd.Execute(); err != nil {
+		// A program the command ran in-process, as debug does, called
+		// os.exit.
+		var exit *extension.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.Code)
+		}
+		fmt.Fprintf(os.Stderr, "%+v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aisk/goblin/interpreter"
+)
+
+func completionStrings(c *replCompleter, line string) ([]string, int) {
+	candidates, offset := c.Do([]rune(line), len([]rune(line)))
+	result := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		result[i] = string(candidate)
+	}
+	return result, offset
+}
+
+func TestREPLCompleter(t *testing.T) {
+	s := interpreter.NewSession(".")
+	if _, err := s.Eval(`type User(name) { func hello(self) { return self.name } }`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Eval(`var user = User("alice")`); err != nil {
+		t.Fatal(err)
+	}
+	c := &replCompleter{session: s}
+
+	tests := []struct {
+		line       string
+		candidates []string
+		offset     int
+	}{
+		{line: "pri", candidates: []string{"nt"}, offset: 3},
+		{line: "ret", candidates: []string{"urn"}, offset: 3},
+		{line: "user.na", candidates: []string{"me"}, offset: 2},
+		{line: "print(user.he", candidates: []string{"llo"}, offset: 2},
+		{line: "user.name.trim_s", candidates: []string{"uffix"}, offset: 6},
+		{line: "make().pu", candidates: []string{}, offset: 0},
+		{line: "missing.na", candidates: []string{}, offset: 2},
+	}
+
+	for _, tt := range tests {
+		got, offset := completionStrings(c, tt.line)
+		if !reflect.DeepEqual(got, tt.candidates) || offset != tt.offset {
+			t.Errorf("complete(%q) = (%v, %d), want (%v, %d)", tt.line, got, offset, tt.candidates, tt.offset)
+		}
+	}
+}
+
+func TestCompletionPathAtCursor(t *testing.T) {
+	path, prefix, ok := completionPath([]rune("user.na + value"), len([]rune("user.na")))
+	if !ok || !reflect.DeepEqual(path, []string{"user"}) || prefix != "na" {
+		t.Fatalf("completionPath = (%v, %q, %v)", path, prefix, ok)
+	}
+}
+
+func TestWantsHelp(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{"-h"}, want: true},
+		{args: []string{"--help"}, want: true},
+		{args: []string{"-h", "script.goblin"}, want: false},
+		{args: []string{"--help", "script.goblin"}, want: false},
+		{args: []string{"script.goblin", "-h"}, want: false},
+		{args: []string{"script.goblin"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := wantsHelp(tt.args); got != tt.want {
+			t.Errorf("wantsHelp(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRequireSourceFirst(t *testing.T) {
+	if err := requireSourceFirst([]string{"script.goblin", "-h"}); err != nil {
+		t.Fatalf("requireSourceFirst(script, -h) = %v, want nil", err)
+	}
+	if err := requireSourceFirst([]string{"-h", "script.goblin"}); err == nil {
+		t.Fatal("should not accept leading -h before source")
+	}
+	if err := requireSourceFirst([]string{"--verbose"}); err == nil {
+		t.Fatal("should not accept --verbose without source")
+	}
+}
+
+func TestGoTestFlags(t *testing.T) {
+	tests := []struct {
+		args, want []string
+	}{
+		{args: []string{"test", "-run", "ok$", "dir"}, want: []string{"test", "--run", "ok$", "dir"}},
+		{args: []string{"test", "-v", "-run=ok$"}, want: []string{"test", "-v", "--run=ok$"}},
+		{args: []string{"test", "--run", "ok$"}, want: []string{"test", "--run", "ok$"}},
+		{args: []string{"run", "script.goblin", "-run"}, want: []string{"run", "script.goblin", "-run"}},
+	}
+	for _, tt := range tests {
+		if got := goTestFlags(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("goTestFlags(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package coverage records which lines of a Goblin program run, for
+// `goblin run --coverage`, and writes the counts as JSON, as a Go
+// coverprofile, or as an HTML report of the annotated source.
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/token"
+)
+
+// Profile is the coverage of a set of source files.
+type Profile struct {
+	Files []*File `json:"files"`
+}
+
+// File is the coverage of one source file: every line on which a statement
+// starts, with how many times it ran.
+type File struct {
+	Path  string `json:"path"`
+	Lines []Line `json:"lines"`
+}
+
+// Line is one line holding statements. Hits is the most times any of them
+// ran, so a one-line loop counts its iterations rather than the sum of its
+// header and body.
+type Line struct {
+	Number     int `json:"line"`
+	Statements int `json:"statements"`
+	Hits       int `json:"hits"`
+}
+
+// Covered returns how many of the file's lines ran and how many there are.
+func (f *File) Covered() (covered, total int) {
+	for _, line := range f.Lines {
+		if line.Hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Lines)
+}
+
+// Covered returns how many lines ran and how many there are, over all files.
+func (p *Profile) Covered() (covered, total int) {
+	for _, f := range p.Files {
+		c, t := f.Covered()
+		covered += c
+		total += t
+	}
+	return covered, total
+}
+
+// Percent is covered as a percentage of total, 100 when there is nothing to
+// cover.
+func Percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+type statement struct {
+	line, hits int
+}
+
+// Recorder counts statement executions as a program runs. The interpreter
+// registers each module it loads with AddModule, so statements that never
+// run are reported with zero hits, and calls Hit for each statement it
+// executes. It is safe for concurrent use.
+type Recorder struct {
+	mu sync.Mutex
+	// files maps a source path to its statements, keyed by offset.
+	files map[string]map[int]*statement
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{files: map[string]map[int]*statement{}}
+}
+
+// AddModule registers every statement in mod, including those in function
+// bodies and methods, as belonging to the source file at path.
+func (r *Recorder) AddModule(path string, mod *ast.Module) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stmts := r.files[path]
+	if stmts == nil {
+		stmts = map[int]*statement{}
+		r.files[path] = stmts
+	}
+	w := walker{add: func(pos token.Pos) {
+		if pos.Line == 0 {
+			return
+		}
+		if _, ok := stmts[pos.Offset]; !ok {
+			stmts[pos.Offset] = &statement{line: pos.Line}
+		}
+	}}
+	w.statements(mod.Body)
+}
+
+// Hit counts one execution of the statement at pos. Statements from source
+// with no file name, such as REPL input, are ignored.
+func (r *Recorder) Hit(pos token.Pos) {
+	src, ok := pos.Context.(token.Sourcer)
+	if !ok || src == nil || pos.Line == 0 {
+		return
+	}
+	path := src.Source()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stmts := r.files[path]
+	if stmts == nil {
+		stmts = map[int]*statement{}
+		r.files[path] = stmts
+	}
+	s := stmts[pos.Offset]
+	if s == nil {
+		s = &statement{line: pos.Line}
+		stmts[pos.Offset] = s
+	}
+	s.hits++
+}
+
+// Profile returns the counts so far, with files sorted by path.
+func (r *Recorder) Profile() *Profile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &Profile{}
+	for path, stmts := range r.files {
+		lines := map[int]*Line{}
+		for _, s := range stmts {
+			line := lines[s.line]
+			if line == nil {
+				line = &Line{Number: s.line}
+				lines[s.line] = line
+			}
+			line.Statements++
+			if s.hits > line.Hits {
+				line.Hits = s.hits
+			}
+		}
+		f := &File{Path: path, Lines: []Line{}}
+		for _, line := range lines {
+			f.Lines = append(f.Lines, *line)
+		}
+		sort.Slice(f.Lines, func(i, j int) bool { return f.Lines[i].Number < f.Lines[j].Number })
+		p.Files = append(p.Files, f)
+	}
+	sort.Slice(p.Files, func(i, j int) bool { return p.Files[i].Path < p.Files[j].Path })
+	return p
+}
+
+// WriteJSON writes the profile as indented JSON.
+func (p *Profile) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(p)
+}
+
+// WriteGo writes the profile in the coverprofile format of `go test
+// -coverprofile`, one block per line, so tools that read Go coverage can read
+// Goblin's. A block spans its line's text without the indentation, which is
+// found by reading the source; when the file cannot be read the block spans
+// the whole line.
+func (p *Profile) WriteGo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "mode: count")
+	for _, f := range p.Files {
+		var lines [][]byte
+		if src, err := os.ReadFile(f.Path); err == nil {
+			lines = bytes.Split(src, []byte("\n"))
+		}
+		for _, line := range f.Lines {
+			start, end := 1, 1
+			if line.Number <= len(lines) {
+				text := bytes.TrimRight(lines[line.Number-1], " \t\r")
+				start = len(text) - len(bytes.TrimLeft(text, " \t")) + 1
+				end = len(text) + 1
+			}
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", f.Path, line.Number, start, line.Number, end, line.Statements, line.Hits)
+		}
+	}
+	return bw.Flush()
+}
+
+// Read reads a profile written by WriteJSON or WriteGo. A coverprofile's
+// blocks are merged by line, as WriteGo wrote them.
+func Read(r io.Reader) (*Profile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("mode:")) {
+		p := &Profile{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, fmt.Errorf("not a coverage profile: %w", err)
+		}
+		return p, nil
+	}
+	return readGo(data)
+}
+
+func readGo(data []byte) (*Profile, error) {
+	p := &Profile{}
+	files := map[string]*File{}
+	lines := map[string]map[int]int{}
+	for i, text := range strings.Split(string(data), "\n")[1:] {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		// path:line.col,line.col statements hits, where the path may hold
+		// colons and spaces.
+		malformed := fmt.Errorf("line %d: malformed coverprofile block: %q", i+2, text)
+		block, hitsText, ok1 := cutLastField(text)
+		block, statementsText, ok2 := cutLastField(block)
+		colon := strings.LastIndex(block, ":")
+		if !ok1 || !ok2 || colon < 0 {
+			return nil, malformed
+		}
+		path := block[:colon]
+		var line, column, endLine, endColumn int
+		if _, err := fmt.Sscanf(block[colon+1:], "%d.%d,%d.%d", &line, &column, &endLine, &endColumn); err != nil {
+			return nil, malformed
+		}
+		statements, err1 := strconv.Atoi(statementsText)
+		hits, err2 := strconv.Atoi(hitsText)
+		if err1 != nil || err2 != nil {
+			return nil, malformed
+		}
+		f := files[path]
+		if f == nil {
+			f = &File{Path: path}
+			files[path] = f
+			lines[path] = map[int]int{}
+			p.Files = append(p.Files, f)
+		}
+		if j, ok := lines[path][line]; ok {
+			l := &f.Lines[j]
+			l.Statements += statements
+			if hits > l.Hits {
+				l.Hits = hits
+			}
+			continue
+		}
+		lines[path][line] = len(f.Lines)
+		f.Lines = append(f.Lines, Line{Number: line, Statements: statements, Hits: hits})
+	}
+	for _, f := range p.Files {
+		sort.Slice(f.Lines, func(i, j int) bool { return f.Lines[i].Number < f.Lines[j].Number })
+	}
+	return p, nil
+}
+
+// cutLastField splits s at its last space into what comes before and after.
+func cutLastField(s string) (before, last string, ok bool) {
+	s = strings.TrimRight(s, " \t\r")
+	i := strings.LastIndexAny(s, " \t")
+	if i < 0 {
+		return s, "", false
+	}
+	return strings.TrimRight(s[:i], " \t"), s[i+1:], true
+}
+
+// walker finds every statement in a module, descending into the bodies of
+// functions, methods, and function literals.
+type walker struct {
+	add func(token.Pos)
+}
+
+func (w walker) statements(list []ast.Statement) {
+	for _, stmt := range list {
+		w.add(stmt.Position())
+		w.statement(stmt)
+	}
+}
+
+func (w walker) statement(stmt ast.Statement) {
+	switch v := stmt.(type) {
+	case *ast.Declare:
+		w.expr(v.Value)
+	case *ast.Assign:
+		w.expr(v.Value)
+	case *ast.SetIndex:
+		w.expr(v.Object)
+		w.expr(v.Index)
+		w.expr(v.Value)
+	case *ast.SetAttr:
+		w.expr(v.Object)
+		w.expr(v.Value)
+	case *ast.FunctionDefine:
+		w.function(v.Parameters, v.Body)
+	case *ast.TypeDefine:
+		for _, field := range v.Fields {
+			if field.HasDefault() {
+				w.expr(field.DefaultValue)
+			}
+		}
+		for _, method := range v.Methods {
+			w.function(method.Parameters, method.Body)
+		}
+	case *ast.IfElse:
+		w.expr(v.Condition)
+		w.statements(v.IfBody)
+		w.statements(v.ElseBody)
+	case *ast.While:
+		w.expr(v.Condition)
+		w.statements(v.Body)
+	case *ast.For:
+		w.expr(v.Iterator)
+		w.statements(v.Body)
+	case *ast.Return:
+		w.expr(v.Value)
+	case *ast.Raise:
+		w.expr(v.Value)
+	case *ast.TryCatch:
+		w.statements(v.TryBody)
+		w.statements(v.CatchBody)
+	case ast.Expression:
+		w.expr(v)
+	}
+}
+
+func (w walker) function(params []*ast.Parameter, body []ast.Statement) {
+	for _, param := range params {
+		if param.HasDefault() {
+			w.expr(param.Default)
+		}
+	}
+	w.statements(body)
+}
+
+func (w walker) expr(e ast.Expression) {
+	switch v := e.(type) {
+	case *ast.FunctionCall:
+		w.arguments(v.Args)
+	case *ast.CallExpression:
+		w.expr(v.Callee)
+		w.arguments(v.Args)
+	case *ast.BinaryOperation:
+		w.expr(v.LHS)
+		w.expr(v.RHS)
+	case *ast.UnaryOperation:
+		w.expr(v.Operand)
+	case *ast.ListLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem)
+		}
+	case *ast.DictLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem.Key)
+			w.expr(elem.Value)
+		}
+	case *ast.IndexExpression:
+		w.expr(v.Object)
+		w.expr(v.Index)
+	case *ast.MemberExpression:
+		w.expr(v.Object)
+	case *ast.FunctionLiteral:
+		w.function(v.Parameters, v.Body)
+	}
+}
+
+func (w walker) arguments(args []ast.CallArgument) {
+	for _, arg := range args {
+		w.expr(arg.Expr)
+	}
+}
@@ -0,0 +1,79 @@
+package coverage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleProfile(t *testing.T) *Profile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a b.goblin")
+	if err := os.WriteFile(path, []byte("var x = 1\nif x > 1 {\n    print(\"<big>\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &Profile{Files: []*File{{Path: path, Lines: []Line{
+		{Number: 1, Statements: 1, Hits: 1},
+		{Number: 2, Statements: 1, Hits: 1},
+		{Number: 3, Statements: 1, Hits: 0},
+	}}}}
+}
+
+func TestProfileRoundTrips(t *testing.T) {
+	p := sampleProfile(t)
+	for name, write := range map[string]func(*Profile, *bytes.Buffer) error{
+		"json": func(p *Profile, buf *bytes.Buffer) error { return p.WriteJSON(buf) },
+		"go":   func(p *Profile, buf *bytes.Buffer) error { return p.WriteGo(buf) },
+	} {
+		var buf bytes.Buffer
+		if err := write(p, &buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("%s: read %+v, want %+v", name, got.Files[0], p.Files[0])
+		}
+	}
+}
+
+func TestWriteGo(t *testing.T) {
+	p := sampleProfile(t)
+	var buf bytes.Buffer
+	if err := p.WriteGo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := p.Files[0].Path
+	want := "mode: count\n" +
+		path + ":1.1,1.10 1 1\n" +
+		path + ":2.1,2.11 1 1\n" +
+		path + ":3.5,3.19 1 0\n"
+	if buf.String() != want {
+		t.Errorf("WriteGo() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if covered, total := p.Covered(); covered != 2 || total != 3 {
+		t.Errorf("Covered() = %d, %d, want 2, 3", covered, total)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleProfile(t).WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<tr class="hit"><td class="num">1</td><td class="hits">1</td><td class="src">var x = 1</td></tr>`,
+		`<tr class="miss"><td class="num">3</td><td class="hits">0</td><td class="src">    print(&#34;&lt;big&gt;&#34;)</td></tr>`,
+		`<tr class=""><td class="num">4</td><td class="hits"></td><td class="src">}</td></tr>`,
+		`(66.7%)</option>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report lacks %s", want)
+		}
+	}
+}
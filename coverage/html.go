@@ -0,0 +1,110 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+type htmlLine struct {
+	Number int
+	Text   string
+	// Class is "hit", "miss", or empty for a line without statements.
+	Class string
+	Hits  string
+}
+
+type htmlFile struct {
+	ID      string
+	Path    string
+	Percent string
+	Lines   []htmlLine
+	Error   string
+}
+
+// WriteHTML writes a self-contained HTML page showing each file's source with
+// its lines marked as run or not and their hit counts, like `go tool cover
+// -html`. The sources are read from the paths in the profile.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	covered, total := p.Covered()
+	data := struct {
+		Percent string
+		Files   []htmlFile
+	}{Percent: fmt.Sprintf("%.1f%%", Percent(covered, total))}
+	for i, f := range p.Files {
+		covered, total := f.Covered()
+		hf := htmlFile{ID: fmt.Sprintf("file%d", i), Path: f.Path, Percent: fmt.Sprintf("%.1f%%", Percent(covered, total))}
+		src, err := os.ReadFile(f.Path)
+		if err != nil {
+			hf.Error = err.Error()
+			data.Files = append(data.Files, hf)
+			continue
+		}
+		hits := map[int]int{}
+		for _, line := range f.Lines {
+			hits[line.Number] = line.Hits
+		}
+		for n, text := range bytes.Split(bytes.TrimSuffix(src, []byte("\n")), []byte("\n")) {
+			line := htmlLine{Number: n + 1, Text: string(text)}
+			if count, ok := hits[n+1]; ok {
+				line.Class, line.Hits = "miss", "0"
+				if count > 0 {
+					line.Class, line.Hits = "hit", fmt.Sprint(count)
+				}
+			}
+			hf.Lines = append(hf.Lines, line)
+		}
+		data.Files = append(data.Files, hf)
+	}
+	return htmlTemplate.Execute(w, data)
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Goblin coverage</title>
+<style>
+body { margin: 0; font-family: sans-serif; background: #fff; color: #222; }
+#topbar { padding: 8px 12px; background: #eee; border-bottom: 1px solid #ccc; }
+#topbar select { font-size: 14px; }
+#topbar .legend span { margin-left: 12px; padding: 0 4px; }
+table { border-collapse: collapse; font-family: monospace; font-size: 13px; }
+td { padding: 0 8px; white-space: pre; vertical-align: top; }
+td.num, td.hits { color: #888; text-align: right; user-select: none; }
+tr.hit td.src { background: #dfd; }
+tr.miss td.src { background: #fdd; }
+.hit { background: #dfd; }
+.miss { background: #fdd; }
+.error { padding: 12px; color: #a00; }
+</style>
+</head>
+<body>
+<div id="topbar">
+<select id="files" onchange="show(this.value)">
+{{range .Files}}<option value="{{.ID}}">{{.Path}} ({{.Percent}})</option>
+{{end}}</select>
+<span class="legend">total {{.Percent}}<span class="hit">run</span><span class="miss">not run</span></span>
+</div>
+{{range .Files}}<div class="file" id="{{.ID}}" style="display: none">
+{{if .Error}}<div class="error">{{.Error}}</div>
+{{else}}<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="num">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="src">{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}</div>
+{{end}}<script>
+function show(id) {
+	for (const div of document.querySelectorAll(".file")) {
+		div.style.display = div.id === id ? "block" : "none";
+	}
+}
+const files = document.getElementById("files");
+if (files.value) {
+	show(files.value);
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,549 @@
+// Package dap implements the Debug Adapter Protocol server behind `goblin
+// dap`, so editors such as VS Code can debug Goblin programs. It launches the
+// program in the interpreter under a debugger.Debugger and maps the
+// protocol's breakpoints, stepping, threads, stack traces, scopes, variables,
+// and evaluate requests onto it.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/debugger"
+	"github.com/aisk/goblin/internal/framing"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/semantic"
+	"github.com/aisk/goblin/source"
+)
+
+// Serve runs one debug session, reading requests from in and writing
+// responses and events to out, until the client disconnects or closes in.
+//
+// While the program runs, os.Stdout and os.Stderr point at pipes whose
+// output reaches the client as output events, and os.Stdin is empty, so the
+// program cannot disturb a session carried over the process's own stdio.
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{out: out, handles: map[int]handle{}}
+	r := bufio.NewReader(in)
+	for {
+		body, err := framing.Read(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("dap: %w", err)
+		}
+		if s.handle(body) {
+			return nil
+		}
+	}
+}
+
+// handle is what a variablesReference stands for while the program is
+// stopped: a scope of a frame, or a value with parts.
+type handle struct {
+	scope *debugger.Scope
+	value object.Object
+}
+
+type server struct {
+	writeMu sync.Mutex
+	out     io.Writer
+	seq     int
+	// replyMu is held while a request is handled, so the response to a
+	// resume goes out before the stopped event it leads to.
+	replyMu sync.Mutex
+
+	launch *launchArguments
+	module *ast.Module
+	d      *debugger.Debugger
+	// lineBreakpoints are the IDs of the breakpoints set for each source
+	// path, which the next setBreakpoints for that path replaces, and
+	// functionBreakpoints those of setFunctionBreakpoints.
+	lineBreakpoints     map[string][]int
+	functionBreakpoints []int
+	ended               chan struct{}
+
+	// mu guards the stop and the handles handed out while stopped, which
+	// the event loop replaces and the request loop reads.
+	mu         sync.Mutex
+	stop       *debugger.Stop
+	handles    map[int]handle
+	nextHandle int
+}
+
+func (s *server) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	framing.Write(s.out, body)
+}
+
+// nextSeq numbers an outgoing message.
+func (s *server) nextSeq() int {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	return s.seq
+}
+
+func (s *server) event(name string, body any) {
+	s.write(event{message: message{Seq: s.nextSeq(), Type: "event"}, Event: name, Body: body})
+}
+
+// handle processes one request and reports whether the session is over.
+func (s *server) handle(body []byte) bool {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.event("output", map[string]any{"category": "console", "output": fmt.Sprintf("dap: malformed request: %v\n", err)})
+		return false
+	}
+	s.replyMu.Lock()
+	defer s.replyMu.Unlock()
+	result, err := s.dispatch(req)
+	resp := response{
+		message:    message{Seq: s.nextSeq(), Type: "response"},
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       result,
+	}
+	if err != nil {
+		resp.Message, resp.Body = err.Error(), nil
+	}
+	s.write(resp)
+	switch req.Command {
+	case "initialize":
+		if err == nil {
+			s.event("initialized", nil)
+		}
+	case "disconnect", "terminate":
+		s.event("terminated", nil)
+		return true
+	}
+	return false
+}
+
+func (s *server) dispatch(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launchProgram(&args)
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args)
+	case "setFunctionBreakpoints":
+		var args setFunctionBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setFunctionBreakpoints(args)
+	case "setExceptionBreakpoints":
+		return map[string]any{"breakpoints": []Breakpoint{}}, nil
+	case "configurationDone":
+		return nil, s.start()
+	case "threads":
+		var threads []Thread
+		if s.d == nil {
+			threads = []Thread{{ID: 1, Name: "main"}}
+		} else {
+			for _, t := range s.d.Threads() {
+				threads = append(threads, Thread{ID: t.ID, Name: t.Name})
+			}
+		}
+		return map[string]any{"threads": threads}, nil
+	case "stackTrace":
+		var args stackTraceArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.stackTrace(args), nil
+	case "scopes":
+		var args scopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args)
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args)
+	case "evaluate":
+		var args evaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return map[string]any{"allThreadsContinued": false}, s.resume(s.d.Continue)
+	case "next":
+		return nil, s.resume(s.d.StepOver)
+	case "stepIn":
+		return nil, s.resume(s.d.StepIn)
+	case "stepOut":
+		return nil, s.resume(s.d.StepOut)
+	case "pause":
+		if s.d == nil {
+			return nil, errNotRunning
+		}
+		s.d.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+var (
+	errNotRunning = errors.New("no program is running")
+	errNotStopped = errors.New("the program is not stopped")
+)
+
+// launchProgram parses the program and prepares its debugger. The program
+// starts at configurationDone, once the client has set its breakpoints.
+func (s *server) launchProgram(args *launchArguments) error {
+	if s.launch != nil {
+		return errors.New("a program has already been launched")
+	}
+	if args.Program == "" {
+		return errors.New("launch needs a program")
+	}
+	l, err := source.NewLexerFile(args.Program)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", args.Program, err)
+	}
+	st, err := parser.NewParser().Parse(l)
+	if err != nil {
+		return err
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return fmt.Errorf("internal error: unexpected AST type")
+	}
+	if err := semantic.CheckModule(mod); err != nil {
+		return err
+	}
+	s.launch, s.module = args, mod
+	s.d = debugger.New(args.StopOnEntry && !args.NoDebug)
+	s.lineBreakpoints = map[string][]int{}
+	return nil
+}
+
+func (s *server) setBreakpoints(args setBreakpointsArguments) (any, error) {
+	if s.d == nil {
+		return nil, errNotRunning
+	}
+	path := args.Source.Path
+	for _, id := range s.lineBreakpoints[path] {
+		s.d.ClearBreakpoint(id)
+	}
+	s.lineBreakpoints[path] = nil
+	result := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, b := range args.Breakpoints {
+		bp := s.d.SetBreakpoint(&debugger.Breakpoint{File: path, Line: b.Line, Condition: b.Condition})
+		s.lineBreakpoints[path] = append(s.lineBreakpoints[path], bp.ID)
+		result = append(result, Breakpoint{ID: bp.ID, Verified: true, Source: &args.Source, Line: b.Line})
+	}
+	return map[string]any{"breakpoints": result}, nil
+}
+
+func (s *server) setFunctionBreakpoints(args setFunctionBreakpointsArguments) (any, error) {
+	if s.d == nil {
+		return nil, errNotRunning
+	}
+	for _, id := range s.functionBreakpoints {
+		s.d.ClearBreakpoint(id)
+	}
+	s.functionBreakpoints = nil
+	result := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, b := range args.Breakpoints {
+		bp := s.d.SetBreakpoint(&debugger.Breakpoint{Function: b.Name, Condition: b.Condition})
+		s.functionBreakpoints = append(s.functionBreakpoints, bp.ID)
+		result = append(result, Breakpoint{ID: bp.ID, Verified: true})
+	}
+	return map[string]any{"breakpoints": result}, nil
+}
+
+// start runs the launched program and relays its stops, output, and end to
+// the client.
+func (s *server) start() error {
+	if s.d == nil {
+		return errNotRunning
+	}
+	if s.ended != nil {
+		return nil
+	}
+	s.ended = make(chan struct{})
+	restore, flush, err := s.captureOutput()
+	if err != nil {
+		return err
+	}
+	var once sync.Once
+	finish := func(code int, runErr error) {
+		once.Do(func() {
+			flush()
+			restore()
+			if runErr != nil {
+				s.event("output", map[string]any{"category": "stderr", "output": fmt.Sprintf("%+v\n", runErr)})
+			}
+			s.event("exited", map[string]any{"exitCode": code})
+			s.event("terminated", nil)
+			close(s.ended)
+		})
+	}
+	if s.launch.NoDebug {
+		for _, bp := range s.d.Breakpoints() {
+			s.d.ClearBreakpoint(bp.ID)
+		}
+	}
+	program, args := s.launch.Program, s.launch.Args
+	s.d.Start(s.module, program, args...)
+	go func() {
+		for {
+			ev := s.d.Wait()
+			s.replyMu.Lock()
+			s.replyMu.Unlock()
+			if ev.Exited {
+				code := ev.Code
+				if ev.Err != nil {
+					code = 1
+				}
+				finish(code, ev.Err)
+				return
+			}
+			s.mu.Lock()
+			s.stop = ev.Stop
+			s.handles, s.nextHandle = map[int]handle{}, 0
+			s.mu.Unlock()
+			body := map[string]any{
+				"reason":            string(ev.Stop.Reason),
+				"threadId":          ev.Stop.Thread,
+				"allThreadsStopped": false,
+			}
+			if ev.Stop.Reason == debugger.ReasonBreakpoint {
+				body["hitBreakpointIds"] = []int{ev.Stop.Breakpoint.ID}
+			}
+			if ev.Stop.ConditionErr != nil {
+				body["text"] = fmt.Sprintf("breakpoint condition failed: %v", ev.Stop.ConditionErr)
+			}
+			s.event("stopped", body)
+		}
+	}()
+	return nil
+}
+
+// captureOutput points os.Stdout and os.Stderr at pipes relayed to the
+// client as output events and empties os.Stdin. restore puts them back;
+// flush closes the pipes and waits until what was written has been sent.
+func (s *server) captureOutput() (restore, flush func(), err error) {
+	stdout, stderr, stdin := os.Stdout, os.Stderr, os.Stdin
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, nil, err
+	}
+	var wg sync.WaitGroup
+	relay := func(r *os.File, category string) {
+		defer wg.Done()
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				s.event("output", map[string]any{"category": category, "output": string(buf[:n])})
+			}
+			if err != nil {
+				r.Close()
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go relay(outR, "stdout")
+	go relay(errR, "stderr")
+	os.Stdout, os.Stderr, os.Stdin = outW, errW, null
+
+	restore = func() {
+		os.Stdout, os.Stderr, os.Stdin = stdout, stderr, stdin
+		null.Close()
+	}
+	flush = func() {
+		outW.Close()
+		errW.Close()
+		wg.Wait()
+	}
+	return restore, flush, nil
+}
+
+// stopped returns the current stop, or an error when the program is
+// running.
+func (s *server) stopped() (*debugger.Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+	return s.stop, nil
+}
+
+func (s *server) resume(step func() error) error {
+	if s.d == nil {
+		return errNotRunning
+	}
+	s.mu.Lock()
+	s.stop = nil
+	s.mu.Unlock()
+	return step()
+}
+
+// Frame IDs are the stopped thread's frames numbered from 1, innermost
+// first; only one thread is stopped at a time.
+
+func (s *server) frame(id int) (debugger.Frame, error) {
+	stop, err := s.stopped()
+	if err != nil {
+		return debugger.Frame{}, err
+	}
+	if id < 1 || id > len(stop.Frames) {
+		return debugger.Frame{}, fmt.Errorf("no frame %d", id)
+	}
+	return stop.Frames[id-1], nil
+}
+
+func (s *server) stackTrace(args stackTraceArguments) any {
+	frames := []StackFrame{}
+	stop, err := s.stopped()
+	if err != nil || stop.Thread != args.ThreadID {
+		return map[string]any{"stackFrames": frames, "totalFrames": 0}
+	}
+	for i, f := range stop.Frames {
+		if i < args.StartFrame || (args.Levels > 0 && len(frames) == args.Levels) {
+			continue
+		}
+		frame := StackFrame{ID: i + 1, Name: f.Function, Line: f.Line, Column: f.Column}
+		if f.File != "" {
+			frame.Source = &Source{Name: filepath.Base(f.File), Path: absPath(f.File)}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]any{"stackFrames": frames, "totalFrames": len(stop.Frames)}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func (s *server) scopes(args scopesArguments) (any, error) {
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{}
+	for _, scope := range f.Scopes() {
+		scope := scope
+		name, hint := "Locals", "locals"
+		if scope.Name == "globals" {
+			name, hint = "Globals", ""
+		}
+		scopes = append(scopes, Scope{Name: name, PresentationHint: hint, VariablesReference: s.newHandle(handle{scope: &scope})})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+// newHandle registers h for the current stop and returns its reference.
+func (s *server) newHandle(h handle) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextHandle++
+	s.handles[s.nextHandle] = h
+	return s.nextHandle
+}
+
+func (s *server) variables(args variablesArguments) (any, error) {
+	if _, err := s.stopped(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	h, ok := s.handles[args.VariablesReference]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no variables with reference %d", args.VariablesReference)
+	}
+	var vars []debugger.Variable
+	if h.scope != nil {
+		vars = h.scope.Vars
+	} else {
+		vars = debugger.Children(h.value)
+	}
+	result := make([]Variable, 0, len(vars))
+	for _, v := range vars {
+		result = append(result, s.variable(v.Name, v.Value))
+	}
+	return map[string]any{"variables": result}, nil
+}
+
+// variable describes a value, with a reference to its parts when it has
+// any.
+func (s *server) variable(name string, v object.Object) Variable {
+	variable := Variable{Name: name, Value: fmt.Sprint(v), Type: v.TypeName()}
+	if len(debugger.Children(v)) > 0 {
+		variable.VariablesReference = s.newHandle(handle{value: v})
+	}
+	return variable
+}
+
+func (s *server) evaluate(args evaluateArguments) (any, error) {
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.d.Eval(f, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		v = object.Unit{}
+	}
+	result := s.variable("", v)
+	return map[string]any{
+		"result":             result.Value,
+		"type":               result.Type,
+		"variablesReference": result.VariablesReference,
+	}, nil
+}
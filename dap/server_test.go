@@ -0,0 +1,265 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aisk/goblin/internal/framing"
+)
+
+const program = `import "os"
+
+func scale(items, factor) {
+    var out = []
+    for x in items {
+        out.push(x * factor)
+    }
+    return out
+}
+
+var result = scale([1, 2], 10)
+print(result, os.argv()[1])
+`
+
+// client drives a session one message at a time, since the adapter answers
+// some requests with events sent later, while the program runs.
+type client struct {
+	t      *testing.T
+	in     io.WriteCloser
+	out    *bufio.Reader
+	seq    int
+	output strings.Builder
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, out: bufio.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *client) request(command string, args any) {
+	c.seq++
+	body, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	framing.Write(c.in, body)
+}
+
+type reply struct {
+	Type    string          `json:"type"`
+	Event   string          `json:"event"`
+	Command string          `json:"command"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// expect reads messages until the response to command, or the event named
+// by an "event:" prefix, collecting output events along the way.
+func (c *client) expect(what string, body any) reply {
+	c.t.Helper()
+	for {
+		data, err := framing.Read(c.out)
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", what, err)
+		}
+		var r reply
+		if err := json.Unmarshal(data, &r); err != nil {
+			c.t.Fatal(err)
+		}
+		if r.Type == "event" && r.Event == "output" {
+			var out struct{ Output string }
+			json.Unmarshal(r.Body, &out)
+			c.output.WriteString(out.Output)
+		}
+		if (r.Type == "response" && r.Command == what) || (r.Type == "event" && "event:"+r.Event == what) {
+			if body != nil && len(r.Body) > 0 {
+				if err := json.Unmarshal(r.Body, body); err != nil {
+					c.t.Fatal(err)
+				}
+			}
+			return r
+		}
+	}
+}
+
+func (c *client) call(command string, args, body any) {
+	c.t.Helper()
+	c.request(command, args)
+	if r := c.expect(command, body); !r.Success {
+		c.t.Fatalf("%s failed: %s", command, r.Message)
+	}
+}
+
+func writeProgram(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.goblin")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSession(t *testing.T) {
+	path := writeProgram(t)
+	c := newClient(t)
+
+	var caps capabilities
+	c.call("initialize", map[string]any{"adapterID": "goblin"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest || !caps.SupportsConditionalBreakpoints {
+		t.Fatalf("capabilities = %+v", caps)
+	}
+	c.expect("event:initialized", nil)
+	c.call("launch", map[string]any{"program": path, "args": []string{"hello"}}, nil)
+
+	var bps struct{ Breakpoints []Breakpoint }
+	c.call("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 6, "condition": "x == 2"}},
+	}, &bps)
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified {
+		t.Fatalf("breakpoints = %+v", bps)
+	}
+	c.call("configurationDone", nil, nil)
+
+	var stopped struct {
+		Reason   string
+		ThreadID int
+	}
+	c.expect("event:stopped", &stopped)
+	if stopped.Reason != "breakpoint" || stopped.ThreadID != 1 {
+		t.Fatalf("stopped = %+v", stopped)
+	}
+
+	var threads struct{ Threads []Thread }
+	c.call("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0] != (Thread{ID: 1, Name: "main"}) {
+		t.Fatalf("threads = %+v", threads)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.call("stackTrace", map[string]any{"threadId": 1}, &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Name != "scale" || trace.StackFrames[0].Line != 6 ||
+		trace.StackFrames[1].Name != "<module>" || trace.StackFrames[1].Line != 11 ||
+		trace.StackFrames[0].Source == nil || trace.StackFrames[0].Source.Path != path {
+		t.Fatalf("stack = %+v", trace.StackFrames)
+	}
+
+	var scopes struct{ Scopes []Scope }
+	c.call("scopes", map[string]any{"frameId": 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v", scopes)
+	}
+	var locals struct{ Variables []Variable }
+	c.call("variables", map[string]any{"variablesReference": scopes.Scopes[0].VariablesReference}, &locals)
+	vars := map[string]Variable{}
+	for _, v := range locals.Variables {
+		vars[v.Name] = v
+	}
+	if vars["x"].Value != "2" || vars["factor"].Value != "10" || vars["out"].Value != "[10]" || vars["out"].VariablesReference == 0 {
+		t.Fatalf("locals = %+v", locals.Variables)
+	}
+	var items struct{ Variables []Variable }
+	c.call("variables", map[string]any{"variablesReference": vars["out"].VariablesReference}, &items)
+	if len(items.Variables) != 1 || items.Variables[0].Name != "[0]" || items.Variables[0].Value != "10" {
+		t.Fatalf("out's items = %+v", items.Variables)
+	}
+
+	var eval struct{ Result string }
+	c.call("evaluate", map[string]any{"expression": "x * factor", "frameId": 1}, &eval)
+	if eval.Result != "20" {
+		t.Fatalf("evaluate = %+v", eval)
+	}
+	c.request("evaluate", map[string]any{"expression": "nope", "frameId": 1})
+	if r := c.expect("evaluate", nil); r.Success || !strings.Contains(r.Message, "nope") {
+		t.Fatalf("evaluating an undefined name = %+v", r)
+	}
+
+	c.call("next", map[string]any{"threadId": 1}, nil)
+	c.expect("event:stopped", &stopped)
+	if stopped.Reason != "step" {
+		t.Fatalf("after next: %+v", stopped)
+	}
+	c.call("continue", map[string]any{"threadId": 1}, nil)
+
+	var exited struct{ ExitCode int }
+	c.expect("event:exited", &exited)
+	c.expect("event:terminated", nil)
+	if exited.ExitCode != 0 || c.output.String() != "[10, 20] hello\n" {
+		t.Fatalf("exit code %d, output %q", exited.ExitCode, c.output.String())
+	}
+	c.call("disconnect", nil, nil)
+	c.expect("event:terminated", nil)
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestsOutOfOrder(t *testing.T) {
+	c := newClient(t)
+	c.request("stackTrace", map[string]any{"threadId": 1})
+	if r := c.expect("stackTrace", nil); !r.Success {
+		t.Fatalf("stackTrace before launch = %+v", r)
+	}
+	for _, command := range []string{"scopes", "continue", "setBreakpoints", "frobnicate"} {
+		c.request(command, map[string]any{})
+		if r := c.expect(command, nil); r.Success {
+			t.Fatalf("%s before launch succeeded", command)
+		}
+	}
+	c.request("launch", map[string]any{"program": filepath.Join(t.TempDir(), "missing.goblin")})
+	if r := c.expect("launch", nil); r.Success {
+		t.Fatal("launching a missing file succeeded")
+	}
+}
+
+func TestGoblinThreads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.goblin")
+	src := "func work(n) {\n    return n * 2\n}\n\nvar g = Goblin(work, 21)\nprint(g.wait())\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	c.expect("event:initialized", nil)
+	c.call("launch", map[string]any{"program": path}, nil)
+	c.call("setFunctionBreakpoints", map[string]any{"breakpoints": []map[string]any{{"name": "work"}}}, nil)
+	c.call("configurationDone", nil, nil)
+
+	var stopped struct{ ThreadID int }
+	c.expect("event:stopped", &stopped)
+	var threads struct{ Threads []Thread }
+	c.call("threads", nil, &threads)
+	if stopped.ThreadID == 1 || len(threads.Threads) != 2 ||
+		threads.Threads[1] != (Thread{ID: stopped.ThreadID, Name: "goblin work"}) {
+		t.Fatalf("stopped in thread %d; threads = %+v", stopped.ThreadID, threads.Threads)
+	}
+	var trace struct{ StackFrames []StackFrame }
+	c.call("stackTrace", map[string]any{"threadId": 1}, &trace)
+	if len(trace.StackFrames) != 0 {
+		t.Fatalf("running thread has frames %+v", trace.StackFrames)
+	}
+	c.call("stackTrace", map[string]any{"threadId": stopped.ThreadID}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Name != "work" {
+		t.Fatalf("goblin stack = %+v", trace.StackFrames)
+	}
+	c.call("continue", map[string]any{"threadId": stopped.ThreadID}, nil)
+	c.expect("event:exited", nil)
+	if c.output.String() != "42\n" {
+		t.Fatalf("output = %q", c.output.String())
+	}
+}
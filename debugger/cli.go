@@ -0,0 +1,226 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/object"
+)
+
+const cliHelp = `Commands:
+  break, b LOCATION [if COND]  set a breakpoint at file:line or a function
+  delete, d ID                 delete a breakpoint
+  breakpoints                  list the breakpoints
+  continue, c                  run until a breakpoint or the end
+  step, s                      run to the next statement, entering calls
+  next, n                      run to the next statement in this function
+  out, o                       run until this function returns
+  print, p EXPR                evaluate EXPR in the selected frame
+  locals                       show the variables in the selected frame
+  backtrace, bt                show the call stack
+  frame, f N                   select frame N of the backtrace
+  list, l                      show the source around the selected frame
+  help, h                      show this help
+  quit, q                      end the session
+An empty line repeats the previous command.
+`
+
+// CLI is the interactive front end of `goblin debug`. It reads commands from
+// in and writes what it shows to out.
+type CLI struct {
+	d       *Debugger
+	in      *bufio.Scanner
+	out     io.Writer
+	sources map[string][]string
+}
+
+func NewCLI(d *Debugger, in io.Reader, out io.Writer) *CLI {
+	return &CLI{d: d, in: bufio.NewScanner(in), out: out, sources: map[string][]string{}}
+}
+
+// Run serves commands until the program ends, returning the error it ended
+// with, or until the input ends or a quit command, returning nil while the
+// program is still stopped.
+func (c *CLI) Run() error {
+	ev := c.d.Wait()
+	for {
+		if ev.Exited {
+			if ev.Err != nil {
+				return ev.Err
+			}
+			fmt.Fprintln(c.out, "program ended")
+			if ev.Code != 0 {
+				return &extension.ExitError{Code: ev.Code}
+			}
+			return nil
+		}
+		c.showStop(ev.Stop)
+		resumed, quit := c.commands(ev.Stop)
+		if quit {
+			return nil
+		}
+		if resumed {
+			ev = c.d.Wait()
+		}
+	}
+}
+
+func (c *CLI) showStop(stop *Stop) {
+	top := stop.Frames[0]
+	switch {
+	case stop.ConditionErr != nil:
+		fmt.Fprintf(c.out, "breakpoint %d: condition %q failed: %v\n", stop.Breakpoint.ID, stop.Breakpoint.Condition, stop.ConditionErr)
+		fmt.Fprintf(c.out, "stopped at %s\n", top)
+	case stop.Breakpoint != nil:
+		fmt.Fprintf(c.out, "breakpoint %d at %s\n", stop.Breakpoint.ID, top)
+	default:
+		fmt.Fprintf(c.out, "stopped at %s\n", top)
+	}
+	if text, ok := c.sourceLine(top.File, top.Line); ok {
+		fmt.Fprintf(c.out, "%5d  %s\n", top.Line, text)
+	}
+}
+
+// commands reads and runs commands while the program is stopped. It returns
+// when a command resumes the program or ends the session.
+func (c *CLI) commands(stop *Stop) (resumed, quit bool) {
+	selected := 0
+	last := ""
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return false, true
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = last
+		}
+		if line == "" {
+			continue
+		}
+		last = line
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		frame := stop.Frames[selected]
+
+		var err error
+		switch cmd {
+		case "continue", "c":
+			err = c.d.Continue()
+			resumed = err == nil
+		case "step", "s":
+			err = c.d.StepIn()
+			resumed = err == nil
+		case "next", "n":
+			err = c.d.StepOver()
+			resumed = err == nil
+		case "out", "o":
+			err = c.d.StepOut()
+			resumed = err == nil
+		case "break", "b":
+			var bp *Breakpoint
+			if bp, err = ParseBreakpoint(arg); err == nil {
+				c.d.SetBreakpoint(bp)
+				fmt.Fprintf(c.out, "breakpoint %d at %s\n", bp.ID, bp)
+			}
+		case "delete", "d":
+			var id int
+			if id, err = strconv.Atoi(arg); err != nil || !c.d.ClearBreakpoint(id) {
+				err = fmt.Errorf("no breakpoint %q", arg)
+			}
+		case "breakpoints":
+			bps := c.d.Breakpoints()
+			if len(bps) == 0 {
+				fmt.Fprintln(c.out, "no breakpoints")
+			}
+			for _, bp := range bps {
+				fmt.Fprintf(c.out, "%d  %s  (hits: %d)\n", bp.ID, bp, bp.Hits)
+			}
+		case "print", "p":
+			if arg == "" {
+				err = fmt.Errorf("usage: print EXPR")
+				break
+			}
+			var v object.Object
+			if v, err = c.d.Eval(frame, arg); err == nil && v != nil {
+				// Statements and `none` show nothing, as in the REPL.
+				if _, isUnit := v.(object.Unit); !isUnit {
+					fmt.Fprintln(c.out, v)
+				}
+			}
+		case "locals":
+			for _, scope := range frame.Scopes() {
+				fmt.Fprintf(c.out, "%s:\n", scope.Name)
+				for _, v := range scope.Vars {
+					fmt.Fprintf(c.out, "  %s = %v\n", v.Name, v.Value)
+				}
+			}
+		case "backtrace", "bt":
+			// Most recent call last, like a traceback.
+			for i := len(stop.Frames) - 1; i >= 0; i-- {
+				mark := " "
+				if i == selected {
+					mark = ">"
+				}
+				fmt.Fprintf(c.out, "%s #%d at %s\n", mark, i, stop.Frames[i])
+			}
+		case "frame", "f":
+			n, convErr := strconv.Atoi(arg)
+			if convErr != nil || n < 0 || n >= len(stop.Frames) {
+				err = fmt.Errorf("no frame %q; the backtrace has frames 0 to %d", arg, len(stop.Frames)-1)
+				break
+			}
+			selected = n
+			fmt.Fprintf(c.out, "#%d at %s\n", n, stop.Frames[n])
+		case "list", "l":
+			c.list(frame.File, frame.Line)
+		case "help", "h":
+			fmt.Fprint(c.out, cliHelp)
+		case "quit", "q":
+			return false, true
+		default:
+			err = fmt.Errorf("unknown command %q; try help", cmd)
+		}
+		if err != nil {
+			fmt.Fprintf(c.out, "%v\n", err)
+		}
+		if resumed {
+			return true, false
+		}
+	}
+}
+
+// list shows the lines around line, marking it.
+func (c *CLI) list(path string, line int) {
+	for n := line - 5; n <= line+5; n++ {
+		text, ok := c.sourceLine(path, n)
+		if !ok {
+			continue
+		}
+		mark := " "
+		if n == line {
+			mark = ">"
+		}
+		fmt.Fprintf(c.out, "%s%4d  %s\n", mark, n, text)
+	}
+}
+
+func (c *CLI) sourceLine(path string, line int) (string, bool) {
+	lines, ok := c.sources[path]
+	if !ok {
+		if data, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		c.sources[path] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
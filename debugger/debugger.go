@@ -0,0 +1,607 @@
+// Package debugger stops a program running in the interpreter at breakpoints
+// and steps, and inspects it while it is stopped: its Goblin-level call stack,
+// the variables in each frame's scopes, and expressions evaluated in a frame.
+//
+// A Debugger attaches to the program it runs through interpreter.Options and
+// keeps a shadow call stack per goroutine from the Enter and Exit calls. The
+// goroutine that stops blocks in the interpreter's statement loop and serves
+// the front end's requests, so evaluating an expression runs on the stopped
+// goroutine, in its frame, as the program itself would have.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/internal/goid"
+	"github.com/aisk/goblin/interpreter"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/token"
+)
+
+// Breakpoint stops the program at a line of a file or on entry to a
+// function, whenever its condition, if it has one, is true.
+type Breakpoint struct {
+	ID int
+	// File and Line are set for a line breakpoint. File is matched against
+	// the end of the source path, so "main.goblin" matches any file of that
+	// name.
+	File string
+	Line int
+	// Function is set for a function breakpoint: a function name such as
+	// "fib", a method such as "Point.sum", or either qualified by its
+	// module, as in "util.fib".
+	Function string
+	// Condition is a Goblin expression evaluated in the stopping frame.
+	Condition string
+	// Hits counts the times the breakpoint has stopped the program.
+	Hits int
+}
+
+func (b *Breakpoint) String() string {
+	where := b.Function
+	if where == "" {
+		where = fmt.Sprintf("%s:%d", b.File, b.Line)
+	}
+	if b.Condition != "" {
+		where += " if " + b.Condition
+	}
+	return where
+}
+
+// ParseBreakpoint parses a breakpoint location, "file:line" or a function
+// name, optionally followed by "if" and a condition.
+func ParseBreakpoint(spec string) (*Breakpoint, error) {
+	spec = strings.TrimSpace(spec)
+	bp := &Breakpoint{}
+	if loc, cond, ok := strings.Cut(spec, " if "); ok {
+		spec, bp.Condition = strings.TrimSpace(loc), strings.TrimSpace(cond)
+		if bp.Condition == "" {
+			return nil, fmt.Errorf("breakpoint condition is empty")
+		}
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("breakpoint location is empty")
+	}
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		line, err := strconv.Atoi(spec[i+1:])
+		if err != nil || line <= 0 || i == 0 {
+			return nil, fmt.Errorf("invalid breakpoint location %q: want file:line or a function name", spec)
+		}
+		bp.File, bp.Line = spec[:i], line
+		return bp, nil
+	}
+	if strings.ContainsAny(spec, " \t") {
+		return nil, fmt.Errorf("invalid breakpoint location %q: want file:line or a function name", spec)
+	}
+	bp.Function = spec
+	return bp, nil
+}
+
+// Frame is one frame of a stopped program's call stack. Its Line and Column
+// are those of the statement the frame is running, or about to run.
+type Frame struct {
+	object.Frame
+	env *interpreter.Environment
+}
+
+// Scope is one level of a frame's scope chain and the variables defined in
+// it.
+type Scope struct {
+	// Name is "locals" for the frame's own scopes, innermost first, and
+	// "globals" for its module's.
+	Name string
+	Vars []Variable
+}
+
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scopes lists the variables visible in the frame, innermost scope first:
+// the block and function scopes as "locals", then the module's "globals".
+// Built-in functions are not listed.
+func (f Frame) Scopes() []Scope {
+	var scopes []Scope
+	for env := f.env; env != nil; env = env.Parent() {
+		name := "locals"
+		if env.Parent() == nil {
+			name = "globals"
+		}
+		bindings := env.Bindings()
+		vars := make([]Variable, 0, len(bindings))
+		for _, n := range env.Names() {
+			if v, ok := bindings[n]; ok {
+				vars = append(vars, Variable{Name: n, Value: v})
+			}
+		}
+		if len(scopes) > 0 && name == "locals" && scopes[len(scopes)-1].Name == "locals" {
+			// A function's block scopes read as one list of locals; an
+			// inner binding shadows an outer one of the same name.
+			last := &scopes[len(scopes)-1]
+			seen := map[string]bool{}
+			for _, v := range last.Vars {
+				seen[v.Name] = true
+			}
+			for _, v := range vars {
+				if !seen[v.Name] {
+					last.Vars = append(last.Vars, v)
+				}
+			}
+			sort.Slice(last.Vars, func(i, j int) bool { return last.Vars[i].Name < last.Vars[j].Name })
+			continue
+		}
+		scopes = append(scopes, Scope{Name: name, Vars: vars})
+	}
+	return scopes
+}
+
+// Children lists the parts of a value a front end can expand: the elements
+// of a list, the entries of a dict, and the fields of an instance or other
+// object with attributes. Methods are left out.
+func Children(v object.Object) []Variable {
+	var children []Variable
+	switch v := v.(type) {
+	case *object.List:
+		for i, elem := range v.Snapshot() {
+			children = append(children, Variable{Name: fmt.Sprintf("[%d]", i), Value: elem})
+		}
+	case *object.Dict:
+		for _, entry := range v.Entries() {
+			children = append(children, Variable{Name: fmt.Sprint(entry.Key), Value: entry.Value})
+		}
+	default:
+		for _, name := range v.Attributes() {
+			if name == "attributes" {
+				continue
+			}
+			attr, err := v.GetAttr(name)
+			if err != nil {
+				continue
+			}
+			if _, isFunction := attr.(*object.Function); isFunction {
+				continue
+			}
+			children = append(children, Variable{Name: name, Value: attr})
+		}
+	}
+	return children
+}
+
+// Reason says why the program stopped.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+	ReasonPause      Reason = "pause"
+)
+
+// Stop describes the program stopped at a statement.
+type Stop struct {
+	Reason Reason
+	// Thread is the ID of the goroutine that stopped, as Threads lists it.
+	Thread int
+	// Breakpoint is the breakpoint that stopped the program, for
+	// ReasonBreakpoint.
+	Breakpoint *Breakpoint
+	// ConditionErr is set when the breakpoint's condition failed to
+	// evaluate; the program stops so the condition can be fixed.
+	ConditionErr error
+	// Frames is the call stack, innermost first.
+	Frames []Frame
+}
+
+// Event is what Wait returns: the program stopped, or it ended.
+type Event struct {
+	Stop *Stop
+	// Exited is set when the program has ended, with Err holding the error
+	// it ended with, if any, and Code the status it passed to os.exit.
+	Exited bool
+	Err    error
+	Code   int
+}
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+type frame struct {
+	object.Frame
+	env *interpreter.Environment
+	// fresh is set until the frame runs its first statement, where
+	// function breakpoints stop.
+	fresh bool
+	// stopLine and stopStmt record where the frame last stopped, so other
+	// statements on the same line do not stop it again.
+	stopLine int
+	stopStmt ast.Statement
+}
+
+// stack is the shadow call stack of one goroutine. Only that goroutine
+// touches it, except to read it while it is stopped.
+type stack struct {
+	// thread numbers the goroutine for the front end: 1 for the one the
+	// program starts on, then each goblin in the order it first runs Goblin
+	// code.
+	thread int
+	name   string
+	frames []*frame
+	// evaluating is set while the stopped goroutine runs code for the front
+	// end, which must not stop again.
+	evaluating bool
+}
+
+// request is work the front end hands the stopped goroutine. It reports
+// whether the goroutine should resume.
+type request func(s *stack) bool
+
+var errNotStopped = errors.New("the program is not stopped")
+
+// Debugger controls one program. Its methods are for the front end; the
+// interpreter calls Enter, Exit, and Statement.
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	nextID      int
+	stopOnEntry bool
+	pause       bool
+	step        stepMode
+	stepStack   *stack
+	stepDepth   int
+	stopped     bool
+
+	stacksMu   sync.Mutex
+	main       *stack
+	mainID     uint64
+	stacks     map[uint64]*stack
+	lastThread int
+
+	// stopMu lets one goroutine stop at a time.
+	stopMu   sync.Mutex
+	events   chan Event
+	requests chan request
+}
+
+// New returns a debugger that stops the program at its first statement when
+// stopOnEntry is set, and otherwise only at breakpoints.
+func New(stopOnEntry bool) *Debugger {
+	return &Debugger{
+		stopOnEntry: stopOnEntry,
+		main:        &stack{thread: 1},
+		stacks:      map[uint64]*stack{},
+		lastThread:  1,
+		events:      make(chan Event),
+		requests:    make(chan request),
+	}
+}
+
+// Start runs the program mod, whose source is at path, under the debugger, as
+// interpreter.Run would with args, on a goroutine of its own. Wait reports
+// its end.
+func (d *Debugger) Start(mod *ast.Module, path string, args ...string) {
+	argv := append([]string{path}, args...)
+	go func() {
+		d.mainID = goid.Current()
+		// os.exit ends the program, not the process debugging it.
+		err := interpreter.RunWithOptions(mod, path, interpreter.Options{Argv: argv, Debugger: d, AtExit: func(int) {}})
+		ev := Event{Exited: true, Err: err}
+		var exit *extension.ExitError
+		if errors.As(err, &exit) {
+			ev.Err, ev.Code = nil, exit.Code
+		}
+		d.events <- ev
+	}()
+}
+
+// Wait blocks until the program stops or ends.
+func (d *Debugger) Wait() Event {
+	return <-d.events
+}
+
+// SetBreakpoint adds bp, giving it an ID, and returns it.
+func (d *Debugger) SetBreakpoint(bp *Breakpoint) *Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	bp.ID = d.nextID
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp
+}
+
+// ClearBreakpoint removes the breakpoint with the given ID, reporting
+// whether there was one.
+func (d *Debugger) ClearBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the breakpoints, in the order they were set.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Breakpoint(nil), d.breakpoints...)
+}
+
+// Thread is a goroutine running Goblin code.
+type Thread struct {
+	ID   int
+	Name string
+}
+
+// Threads lists the goroutines running Goblin code: "main", then a "goblin"
+// for each goblin, named after the function it was started with.
+func (d *Debugger) Threads() []Thread {
+	d.stacksMu.Lock()
+	defer d.stacksMu.Unlock()
+	threads := []Thread{{ID: 1, Name: "main"}}
+	for _, s := range d.stacks {
+		threads = append(threads, Thread{ID: s.thread, Name: s.name})
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i].ID < threads[j].ID })
+	return threads
+}
+
+// Pause stops the program at the next statement any goroutine runs.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Continue resumes the stopped program until the next breakpoint.
+func (d *Debugger) Continue() error { return d.resume(stepNone) }
+
+// StepIn resumes the stopped program until the next statement, entering
+// any function called.
+func (d *Debugger) StepIn() error { return d.resume(stepIn) }
+
+// StepOver resumes the stopped program until the next statement of the
+// current function, or of its caller when it returns.
+func (d *Debugger) StepOver() error { return d.resume(stepOver) }
+
+// StepOut resumes the stopped program until its current function returns
+// to the caller.
+func (d *Debugger) StepOut() error { return d.resume(stepOut) }
+
+func (d *Debugger) resume(mode stepMode) error {
+	return d.do(func(s *stack) bool {
+		d.mu.Lock()
+		d.step, d.stepStack, d.stepDepth = mode, s, len(s.frames)
+		d.stopped = false
+		d.mu.Unlock()
+		return true
+	})
+}
+
+// Eval evaluates src in the scope of f, one of the stop's Frames, on the
+// stopped goroutine. Breakpoints do not stop the code it runs.
+func (d *Debugger) Eval(f Frame, src string) (object.Object, error) {
+	var result object.Object
+	var evalErr error
+	err := d.do(func(s *stack) bool {
+		s.evaluating = true
+		result, evalErr = interpreter.EvalIn(f.env, src)
+		s.evaluating = false
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, evalErr
+}
+
+// do runs req on the stopped goroutine.
+func (d *Debugger) do(req request) error {
+	d.mu.Lock()
+	stopped := d.stopped
+	d.mu.Unlock()
+	if !stopped {
+		return errNotStopped
+	}
+	done := make(chan struct{})
+	d.requests <- func(s *stack) bool {
+		defer close(done)
+		return req(s)
+	}
+	<-done
+	return nil
+}
+
+// Enter implements interpreter.Debugger.
+func (d *Debugger) Enter(f object.Frame) {
+	s := d.stack()
+	if len(s.frames) == 0 && s != d.main {
+		d.stacksMu.Lock()
+		s.name = "goblin " + f.Function
+		d.stacksMu.Unlock()
+	}
+	s.frames = append(s.frames, &frame{Frame: f, fresh: true})
+}
+
+// Exit implements interpreter.Debugger.
+func (d *Debugger) Exit() {
+	s := d.stack()
+	if n := len(s.frames); n > 0 {
+		s.frames = s.frames[:n-1]
+		if n == 1 && object.InConcurrentMode() {
+			d.stacksMu.Lock()
+			if id := goid.Current(); id != d.mainID && d.stacks[id] == s {
+				delete(d.stacks, id)
+			}
+			d.stacksMu.Unlock()
+		}
+	}
+}
+
+// Statement implements interpreter.Debugger. It stops the program when a
+// step ends or a breakpoint is hit.
+func (d *Debugger) Statement(stmt ast.Statement, env *interpreter.Environment) {
+	s := d.stack()
+	if s.evaluating || len(s.frames) == 0 {
+		return
+	}
+	top := s.frames[len(s.frames)-1]
+	pos := stmt.Position()
+	top.env, top.Line, top.Column = env, pos.Line, pos.Column
+	fresh := top.fresh
+	top.fresh = false
+	if top.stopLine == pos.Line && top.stopStmt != stmt {
+		// Another statement on the line the frame stopped on, such as the
+		// body of a one-line if.
+		return
+	}
+	top.stopLine, top.stopStmt = 0, nil
+
+	d.mu.Lock()
+	reason := Reason("")
+	switch {
+	case d.stopOnEntry && len(s.frames) == 1:
+		// The main module's first statement, not that of a module it
+		// imports.
+		reason = ReasonEntry
+	case d.stepStack == s && d.stepping(len(s.frames)):
+		reason = ReasonStep
+	case d.pause:
+		reason = ReasonPause
+	}
+	var candidates []*Breakpoint
+	for _, bp := range d.breakpoints {
+		if bp.matches(top, pos, fresh) {
+			candidates = append(candidates, bp)
+		}
+	}
+	d.mu.Unlock()
+
+	stop := &Stop{Reason: reason, Thread: s.thread}
+	for _, bp := range candidates {
+		if bp.Condition != "" {
+			s.evaluating = true
+			v, err := interpreter.EvalIn(env, bp.Condition)
+			s.evaluating = false
+			if err == nil {
+				var ok bool
+				if ok, err = v.ToBool(); err == nil && !ok {
+					continue
+				}
+			}
+			stop.ConditionErr = err
+		}
+		stop.Reason, stop.Breakpoint = ReasonBreakpoint, bp
+		break
+	}
+	if stop.Reason == "" {
+		return
+	}
+	d.stopHere(s, stmt, stop)
+}
+
+// stepping reports whether the step in progress ends at a statement of a
+// frame depth deep on the stepping goroutine. The caller holds mu.
+func (d *Debugger) stepping(depth int) bool {
+	switch d.step {
+	case stepIn:
+		return true
+	case stepOver:
+		return depth <= d.stepDepth
+	case stepOut:
+		return depth < d.stepDepth
+	}
+	return false
+}
+
+func (b *Breakpoint) matches(f *frame, pos token.Pos, fresh bool) bool {
+	if b.Function != "" {
+		return fresh && (b.Function == f.Function || b.Function == f.Module+"."+f.Function)
+	}
+	return b.Line == pos.Line && matchFile(b.File, f.File)
+}
+
+// matchFile reports whether the breakpoint file want names the source file
+// path: the same file, or a path ending in want.
+func matchFile(want, path string) bool {
+	if path == "" {
+		return false
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if abs, err := filepath.Abs(want); err == nil && abs == path {
+		return true
+	}
+	want = filepath.ToSlash(filepath.Clean(want))
+	path = filepath.ToSlash(path)
+	return path == want || strings.HasSuffix(path, "/"+strings.TrimPrefix(want, "./"))
+}
+
+// stopHere stops the program on the calling goroutine and serves requests
+// until one resumes it.
+func (d *Debugger) stopHere(s *stack, stmt ast.Statement, stop *Stop) {
+	d.stopMu.Lock()
+	defer d.stopMu.Unlock()
+
+	top := s.frames[len(s.frames)-1]
+	top.stopLine, top.stopStmt = stmt.Position().Line, stmt
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		f := s.frames[i]
+		stop.Frames = append(stop.Frames, Frame{Frame: f.Frame, env: f.env})
+	}
+
+	d.mu.Lock()
+	d.stopOnEntry, d.pause = false, false
+	d.step, d.stepStack = stepNone, nil
+	d.stopped = true
+	if stop.Breakpoint != nil {
+		stop.Breakpoint.Hits++
+	}
+	d.mu.Unlock()
+
+	d.events <- Event{Stop: stop}
+	for req := range d.requests {
+		if req(s) {
+			return
+		}
+	}
+}
+
+// stack returns the calling goroutine's shadow stack. Until the program
+// starts a goblin every call runs on the goroutine Start began.
+func (d *Debugger) stack() *stack {
+	if !object.InConcurrentMode() {
+		return d.main
+	}
+	id := goid.Current()
+	if id == d.mainID {
+		return d.main
+	}
+	d.stacksMu.Lock()
+	defer d.stacksMu.Unlock()
+	s := d.stacks[id]
+	if s == nil {
+		d.lastThread++
+		s = &stack{thread: d.lastThread}
+		d.stacks[id] = s
+	}
+	return s
+}
@@ -0,0 +1,243 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/source"
+)
+
+const script = `func fib(n) {
+    if n < 2 { return n }
+    var a = fib(n - 1)
+    return a + fib(n - 2)
+}
+
+type Point(x, y) {
+    func sum(self) {
+        return self.x + self.y
+    }
+}
+
+var total = 0
+for i in [1, 2, 3] {
+    total = total + fib(i)
+}
+var p = Point(1, 2)
+print(p.sum())
+`
+
+// start runs src under a new debugger and returns it with the script's path.
+func start(t *testing.T, src string, stopOnEntry bool) (*Debugger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.goblin")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := source.NewLexerFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := parser.NewParser().Parse(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(stopOnEntry)
+	d.Start(st.(*ast.Module), path)
+	return d, path
+}
+
+func waitStop(t *testing.T, d *Debugger) *Stop {
+	t.Helper()
+	ev := d.Wait()
+	if ev.Exited {
+		t.Fatalf("program ended (err=%v), want a stop", ev.Err)
+	}
+	return ev.Stop
+}
+
+func where(stop *Stop) string {
+	var names []string
+	for _, f := range stop.Frames {
+		names = append(names, f.Function+":"+strconv.Itoa(f.Line))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestParseBreakpoint(t *testing.T) {
+	for spec, want := range map[string]Breakpoint{
+		"main.goblin:12":        {File: "main.goblin", Line: 12},
+		"C:/src/a.goblin:3":     {File: "C:/src/a.goblin", Line: 3},
+		"fib":                   {Function: "fib"},
+		"Point.sum if x > 1":    {Function: "Point.sum", Condition: "x > 1"},
+		" a.goblin:4 if n == 2": {File: "a.goblin", Line: 4, Condition: "n == 2"},
+	} {
+		bp, err := ParseBreakpoint(spec)
+		if err != nil || *bp != want {
+			t.Errorf("ParseBreakpoint(%q) = %+v, %v; want %+v", spec, bp, err, want)
+		}
+	}
+	for _, spec := range []string{"", "a.goblin:x", "a.goblin:0", ":3", "fib if "} {
+		if _, err := ParseBreakpoint(spec); err == nil {
+			t.Errorf("ParseBreakpoint(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	d, path := start(t, script, true)
+	if stop := waitStop(t, d); stop.Reason != ReasonEntry || where(stop) != "<module>:1" {
+		t.Fatalf("entry stop = %s %s", stop.Reason, where(stop))
+	}
+	bp, _ := ParseBreakpoint("fib if n == 2")
+	d.SetBreakpoint(bp)
+	line, _ := ParseBreakpoint(filepath.Base(path) + ":9")
+	d.SetBreakpoint(line)
+
+	d.Continue()
+	stop := waitStop(t, d)
+	if stop.Breakpoint != bp || where(stop) != "fib:2 <module>:15" {
+		t.Fatalf("stop = %s at %s, want breakpoint 1 in fib", stop.Reason, where(stop))
+	}
+	if v, err := d.Eval(stop.Frames[0], "n * 10"); err != nil || fmt.Sprint(v) != "20" {
+		t.Fatalf("eval n * 10 = %v, %v", v, err)
+	}
+	if v, err := d.Eval(stop.Frames[1], "i"); err != nil || fmt.Sprint(v) != "2" {
+		t.Fatalf("eval i in the caller = %v, %v", v, err)
+	}
+
+	// Over the recursive call on line 3, which passes the breakpoint's
+	// function again with n == 1.
+	d.StepOver()
+	d.Wait()
+	d.StepOver()
+	stop = waitStop(t, d)
+	if stop.Reason != ReasonStep || where(stop) != "fib:4 <module>:15" {
+		t.Fatalf("after two steps over: %s at %s", stop.Reason, where(stop))
+	}
+	scopes := stop.Frames[0].Scopes()
+	if len(scopes) != 2 || scopes[0].Name != "locals" || len(scopes[0].Vars) != 2 ||
+		scopes[0].Vars[0].Name != "a" || scopes[0].Vars[1].Name != "n" || scopes[1].Name != "globals" {
+		t.Fatalf("scopes = %+v", scopes)
+	}
+
+	// Into the second recursive call, then back out of it.
+	d.StepIn()
+	if stop = waitStop(t, d); where(stop) != "fib:2 fib:4 <module>:15" {
+		t.Fatalf("after step in: %s", where(stop))
+	}
+	d.StepOut()
+	if stop = waitStop(t, d); stop.Reason != ReasonStep || where(stop) != "<module>:15" {
+		t.Fatalf("after step out: %s %s", stop.Reason, where(stop))
+	}
+
+	if !d.ClearBreakpoint(bp.ID) || d.ClearBreakpoint(bp.ID) {
+		t.Fatal("ClearBreakpoint did not remove the breakpoint exactly once")
+	}
+	d.Continue()
+	stop = waitStop(t, d)
+	if stop.Breakpoint != line || where(stop) != "Point.sum:9 <module>:18" {
+		t.Fatalf("stop = %s at %s, want the line breakpoint in Point.sum", stop.Reason, where(stop))
+	}
+	if v, err := d.Eval(stop.Frames[0], "self.x + self.y"); err != nil || fmt.Sprint(v) != "3" {
+		t.Fatalf("eval in a method = %v, %v", v, err)
+	}
+	d.Continue()
+	if ev := d.Wait(); !ev.Exited || ev.Err != nil {
+		t.Fatalf("end = %+v", ev)
+	}
+	if bp.Hits != 1 || line.Hits != 1 {
+		t.Fatalf("hits = %d, %d; want 1, 1", bp.Hits, line.Hits)
+	}
+	if err := d.Continue(); err != errNotStopped {
+		t.Fatalf("Continue after the end = %v", err)
+	}
+}
+
+func TestEvalChangesFrame(t *testing.T) {
+	d, _ := start(t, "var x = 1\nprint(x)\n", true)
+	stop := waitStop(t, d)
+	d.StepOver()
+	stop = waitStop(t, d)
+	if _, err := d.Eval(stop.Frames[0], "x = 42"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.Eval(stop.Frames[0], "x"); err != nil || fmt.Sprint(v) != "42" {
+		t.Fatalf("x after assigning it = %v, %v", v, err)
+	}
+	if _, err := d.Eval(stop.Frames[0], "undefined_name"); err == nil {
+		t.Fatal("evaluating an undefined name succeeded")
+	}
+	if _, err := d.Eval(stop.Frames[0], `import "os"`); err == nil {
+		t.Fatal("import in a paused frame succeeded")
+	}
+	d.Continue()
+	if ev := d.Wait(); !ev.Exited || ev.Err != nil {
+		t.Fatalf("end = %+v", ev)
+	}
+}
+
+func TestExit(t *testing.T) {
+	// os.exit ends the program, not the process debugging it.
+	d, _ := start(t, "import \"os\"\nos.exit(4)\nprint(1)\n", false)
+	if ev := d.Wait(); !ev.Exited || ev.Err != nil || ev.Code != 4 {
+		t.Fatalf("end = %+v, want code 4", ev)
+	}
+}
+
+func TestConditionError(t *testing.T) {
+	d, _ := start(t, "var x = 1\nprint(x)\n", false)
+	bp, _ := ParseBreakpoint("main.goblin:2 if nope > 1")
+	d.SetBreakpoint(bp)
+	stop := waitStop(t, d)
+	if stop.Breakpoint != bp || stop.ConditionErr == nil || !strings.Contains(stop.ConditionErr.Error(), "nope") {
+		t.Fatalf("stop = %+v", stop)
+	}
+	d.Continue()
+	d.Wait()
+}
+
+func TestCLI(t *testing.T) {
+	d, _ := start(t, script, true)
+	in := strings.Join([]string{
+		"b fib if n == 2",
+		"c",
+		"bt",
+		"p a = n + 1",
+		"locals",
+		"f 1",
+		"p i",
+		"f 7",
+		"frobnicate",
+		"delete 1",
+		"c",
+	}, "\n")
+	var out bytes.Buffer
+	if err := NewCLI(d, strings.NewReader(in), &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"stopped at <module> (",
+		"breakpoint 1 at fib if n == 2\n",
+		"breakpoint 1 at fib (",
+		"    2      if n < 2 { return n }\n",
+		"  #1 at <module> (",
+		"> #0 at fib (",
+		"locals:\n  a = 3\n  n = 2\nglobals:\n",
+		"(debug) 2\n",
+		`no frame "7"; the backtrace has frames 0 to 1`,
+		`unknown command "frobnicate"; try help`,
+		"program ended\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// Package distribution builds custom goblin commands for `goblin
+// build-distribution`: the goblin CLI with the packages of extra Go modules
+// linked in, so the Goblin modules they register with object.RegisterModule
+// can be imported by `goblin run` and by the programs `goblin build-exe`
+// builds. A distribution is a small Go module whose main package imports
+// the cli package and the extra packages, and which embeds a runtime, as
+// the goblin command does, with the extra packages and their dependencies
+// added to it.
+package distribution
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+const (
+	pathBase = "github.com/aisk/goblin"
+
+	// moduleName is the module path of the generated module.
+	moduleName = "goblin-distribution"
+
+	// runtimeArchive is the zip file of the generated module holding the
+	// runtime it embeds, laid out as transpiler.Runtime expects. It is an
+	// archive because a directory holding the runtime's go.mod would be a
+	// module of its own, which go:embed cannot reach into.
+	runtimeArchive = "runtime.zip"
+
+	// localVersion is the version a module replaced by a local directory
+	// is required at, as go mod edit would pick.
+	localVersion = "v0.0.0-00010101000000-000000000000"
+)
+
+// runtimePackages are the packages of the Goblin module that generated
+// programs may import, which the runtime must hold with their dependencies.
+var runtimePackages = []string{pathBase + "/object", pathBase + "/extension/...", pathBase + "/profiler"}
+
+// Options describe a distribution.
+type Options struct {
+	// With lists the extra packages: import paths, each with an optional
+	// @version as go get takes it, or local directories of Go modules,
+	// starting with . or /, whose root packages are linked in.
+	With []string
+	// Output is the path of the executable to write.
+	Output string
+	// GoblinVersion is the version of the Goblin module to build, and
+	// GoblinRoot, when not empty, a checkout that replaces it.
+	GoblinVersion, GoblinRoot string
+	// Dir is the directory to generate the module in; empty means a
+	// temporary one, removed afterwards.
+	Dir string
+	// Env is added to the environment of the go commands, such as GOOS
+	// and GOARCH to build for another platform.
+	Env []string
+	// Log, when not nil, receives the go commands as they run.
+	Log io.Writer
+}
+
+// extra is a package of Options.With.
+type extra struct {
+	importPath string
+	version    string // for go get; empty for a local module
+	dir        string // of a local module
+}
+
+func parseExtra(spec string) (extra, error) {
+	if strings.HasPrefix(spec, ".") || filepath.IsAbs(spec) {
+		dir, err := filepath.Abs(spec)
+		if err != nil {
+			return extra{}, err
+		}
+		out, err := exec.Command("go", "mod", "edit", "-json", filepath.Join(dir, "go.mod")).Output()
+		if err != nil {
+			return extra{}, fmt.Errorf("%s is not a Go module: %v", spec, err)
+		}
+		var mod struct{ Module struct{ Path string } }
+		if err := json.Unmarshal(out, &mod); err != nil {
+			return extra{}, fmt.Errorf("%s: %v", filepath.Join(dir, "go.mod"), err)
+		}
+		return extra{importPath: mod.Module.Path, dir: dir}, nil
+	}
+	path, version, _ := strings.Cut(spec, "@")
+	if path == "" {
+		return extra{}, fmt.Errorf("invalid package %q", spec)
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return extra{importPath: path, version: version}, nil
+}
+
+// Build writes the distribution opts describe to opts.Output.
+func Build(opts Options) (err error) {
+	if len(opts.With) == 0 {
+		return fmt.Errorf("a distribution needs at least one package to add")
+	}
+	var extras []extra
+	for _, spec := range opts.With {
+		x, err := parseExtra(spec)
+		if err != nil {
+			return err
+		}
+		extras = append(extras, x)
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "goblin-distribution-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	b := &builder{dir: dir, opts: opts}
+
+	// Start from a fresh go.mod, at the version of the go command.
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(filepath.Join(dir, "go.sum"))
+	if err := b.goCommand("mod", "init", moduleName); err != nil {
+		return err
+	}
+	if opts.GoblinRoot != "" {
+		err = b.goCommand("mod", "edit", "-require="+pathBase+"@"+opts.GoblinVersion, "-replace="+pathBase+"="+opts.GoblinRoot)
+	} else {
+		err = b.goCommand("get", pathBase+"/cli@"+opts.GoblinVersion)
+	}
+	if err != nil {
+		return err
+	}
+	for _, x := range extras {
+		if x.dir != "" {
+			err = b.goCommand("mod", "edit", "-require="+x.importPath+"@"+localVersion, "-replace="+x.importPath+"="+x.dir)
+		} else {
+			err = b.goCommand("get", x.importPath+"@"+x.version)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := b.writeMain(extras); err != nil {
+		return err
+	}
+	if err := b.writeRuntime(extras); err != nil {
+		return err
+	}
+	return b.goCommand("build", "-mod=mod", "-o", opts.Output, ".")
+}
+
+type builder struct {
+	dir  string
+	opts Options
+}
+
+func (b *builder) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.dir
+	cmd.Env = append(os.Environ(), b.opts.Env...)
+	if b.opts.Log != nil {
+		fmt.Fprintf(b.opts.Log, "run: (cd %s && go %s)\n", b.dir, strings.Join(args, " "))
+	}
+	return cmd
+}
+
+func (b *builder) goCommand(args ...string) error {
+	out, err := b.command(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go %s failed: %v\n%s", args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// writeMain writes main.go, which imports the extra packages, so that they
+// register their modules, and runs the CLI with the embedded runtime.
+func (b *builder) writeMain(extras []extra) error {
+	f := jen.NewFile("main")
+	f.HeaderComment("Code generated by goblin build-distribution. DO NOT EDIT.")
+	f.PackageComment("Command goblin is a distribution of goblin with the modules of the packages\nimported below.")
+	paths := make([]string, len(extras))
+	for i, x := range extras {
+		paths[i] = x.importPath
+	}
+	f.Anon(paths...)
+	f.Anon("embed")
+	f.Comment("//go:embed " + runtimeArchive)
+	f.Var().Id("runtimeZip").Index().Byte()
+	f.Func().Id("main").Params().Block(
+		jen.List(jen.Id("r"), jen.Err()).Op(":=").Qual("archive/zip", "NewReader").Call(
+			jen.Qual("bytes", "NewReader").Call(jen.Id("runtimeZip")),
+			jen.Int64().Call(jen.Len(jen.Id("runtimeZip"))),
+		),
+		jen.If(jen.Err().Op("!=").Nil()).Block(jen.Panic(jen.Err())),
+		jen.Qual(pathBase+"/transpiler", "Runtime").Op("=").Id("r"),
+		jen.Qual(pathBase+"/cli", "Main").Call(),
+	)
+	return f.Save(filepath.Join(b.dir, "main.go"))
+}
+
+// listedPackage is the part of go list -json output writeRuntime reads.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *struct {
+		Path    string
+		Version string
+		Dir     string
+		GoMod   string
+	}
+}
+
+// writeRuntime writes the runtime main.go embeds: the runtime packages of
+// the Goblin module, and under _vendor the packages of every other module
+// they or the extra packages import, with a go.mod requiring those modules
+// at the versions the build selected.
+func (b *builder) writeRuntime(extras []extra) error {
+	args := append([]string{"list", "-mod=mod", "-deps", "-json"}, runtimePackages...)
+	for _, x := range extras {
+		args = append(args, x.importPath)
+	}
+	cmd := b.command(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("go list failed: %v\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var buf bytes.Buffer
+	a := zip.NewWriter(&buf)
+	goblinDir := ""
+	versions := map[string]string{} // of the modules added under _vendor
+	moduleDirs := map[string]string{}
+	goMods := map[string]string{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return err
+		}
+		if pkg.Standard || pkg.Module == nil {
+			continue
+		}
+		if pkg.Module.Path == pathBase {
+			goblinDir = pkg.Module.Dir
+			continue
+		}
+		version := pkg.Module.Version
+		if version == "" {
+			version = localVersion
+		}
+		versions[pkg.Module.Path], moduleDirs[pkg.Module.Path] = version, pkg.Module.Dir
+		goMods[pkg.Module.Path] = pkg.Module.GoMod
+		if err := addPackage(a, pkg.Dir, "_vendor/"+pkg.ImportPath); err != nil {
+			return err
+		}
+	}
+	if goblinDir == "" {
+		return fmt.Errorf("go list did not find the Goblin module")
+	}
+	for _, p := range []string{"object", "extension", "profiler", "internal/goid"} {
+		if err := addTree(a, filepath.Join(goblinDir, p), p); err != nil {
+			return err
+		}
+	}
+	if err := addFile(a, filepath.Join(goblinDir, "LICENSE"), "LICENSE"); err != nil {
+		return err
+	}
+	mods := make([]string, 0, len(versions))
+	for mod := range versions {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+	for _, mod := range mods {
+		err := addFile(a, filepath.Join(moduleDirs[mod], "LICENSE"), "_vendor/"+mod+"/LICENSE")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// The runtime reads the go version of the module from it.
+		if goMods[mod] != "" {
+			if err := addFile(a, goMods[mod], "_vendor/"+mod+"/go.mod"); err != nil {
+				return err
+			}
+		}
+	}
+
+	goMod, err := os.ReadFile(filepath.Join(goblinDir, "go.mod"))
+	if err != nil {
+		return err
+	}
+	var content bytes.Buffer
+	content.Write(goMod)
+	content.WriteString("\n// The modules goblin build-distribution added, at the versions it built.\nrequire (\n")
+	for _, mod := range mods {
+		fmt.Fprintf(&content, "\t%s %s\n", mod, versions[mod])
+	}
+	content.WriteString(")\n")
+	w, err := a.Create("go.mod")
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content.Bytes()); err != nil {
+		return err
+	}
+	if err := a.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, runtimeArchive), buf.Bytes(), 0644)
+}
+
+// isSource reports whether the file name belongs in the runtime: Go files,
+// except tests, and the assembly and C files packages may build with.
+func isSource(name string) bool {
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".go", ".s", ".c", ".h":
+		return true
+	}
+	return false
+}
+
+// addPackage adds the source files of the package in dir to a under name.
+func addPackage(a *zip.Writer, dir, name string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && isSource(e.Name()) {
+			if err := addFile(a, filepath.Join(dir, e.Name()), name+"/"+e.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addTree adds the source files of the packages below dir to a under name,
+// leaving out testdata.
+func addTree(a *zip.Writer, dir, name string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "testdata" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isSource(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return addFile(a, p, name+"/"+filepath.ToSlash(rel))
+	})
+}
+
+// addFile adds the file at path to a as name.
+func addFile(a *zip.Writer, path, name string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	w, err := a.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,43 @@
+package distribution
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExtra(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/local\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec string
+		want extra
+	}{
+		{"github.com/acme/goblin-ext", extra{importPath: "github.com/acme/goblin-ext", version: "latest"}},
+		{"github.com/acme/goblin-ext/sql@v1.2.0", extra{importPath: "github.com/acme/goblin-ext/sql", version: "v1.2.0"}},
+		{dir, extra{importPath: "example.com/local", dir: dir}},
+	}
+	for _, tt := range tests {
+		got, err := parseExtra(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("parseExtra(%q) = %+v, %v; want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"@v1.0.0", filepath.Join(dir, "missing")} {
+		if _, err := parseExtra(spec); err == nil {
+			t.Errorf("parseExtra(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestIsSource(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.go": true, "a_test.go": false, "asm_amd64.s": true, "x.c": true, "x.h": true, "README.md": false,
+	} {
+		if got := isSource(name); got != want {
+			t.Errorf("isSource(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
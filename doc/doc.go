@@ -0,0 +1,363 @@
+// Package doc extracts the documentation behind `goblin doc` and help(): the
+// comments directly above func, type, and export declarations, and the
+// signatures of what they declare.
+package doc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/source"
+)
+
+// Attach sets the Doc of the module's top-level functions, types, methods,
+// and exports to the comment directly above each, using the comments s
+// collected while the module was parsed from it. A doc comment is a run of #
+// lines with nothing else on them, ending at the line before the
+// declaration; a blank line breaks the run. Directives such as
+// `# goblin:ignore` are left out. A function or type with no comment of its
+// own takes the one above its export, if that has one.
+//
+// Attach also returns the module's own doc: a comment run at the top of the
+// file that a blank line separates from what follows.
+func Attach(mod *ast.Module, s *source.Scanner) string {
+	// A comment is alone on its line when the token before it, if any, ends
+	// on an earlier line.
+	lines := map[int]string{}
+	tok := 0
+	for _, c := range s.Comments {
+		for tok < len(s.Tokens) && s.Tokens[tok].Pos.Offset < c.Pos.Offset {
+			tok++
+		}
+		if tok == 0 || ast.TokenEnd(s.Tokens[tok-1]).Line < c.Pos.Line {
+			lines[c.Pos.Line] = c.Text
+		}
+	}
+	above := func(line int) string {
+		var run []string
+		for line--; ; line-- {
+			text, ok := lines[line]
+			if !ok {
+				break
+			}
+			run = append(run, text)
+		}
+		for i, j := 0, len(run)-1; i < j; i, j = i+1, j-1 {
+			run[i], run[j] = run[j], run[i]
+		}
+		return commentText(run)
+	}
+
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefine:
+			s.Doc = above(s.Pos.Line)
+		case *ast.TypeDefine:
+			s.Doc = above(s.Pos.Line)
+			for _, m := range s.Methods {
+				m.Doc = above(m.Pos.Line)
+			}
+		case *ast.Export:
+			s.Doc = above(s.Pos.Line)
+		}
+	}
+	exportDocs := map[string]string{}
+	for _, stmt := range mod.Body {
+		if e, ok := stmt.(*ast.Export); ok && e.Doc != "" {
+			exportDocs[e.Name] = e.Doc
+		}
+	}
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefine:
+			if s.Doc == "" {
+				s.Doc = exportDocs[s.Name]
+			}
+		case *ast.TypeDefine:
+			if s.Doc == "" {
+				s.Doc = exportDocs[s.Name]
+			}
+		}
+	}
+
+	// The module doc starts on the first line, or after a #! line, and must
+	// not run into the first token.
+	line := 1
+	if strings.HasPrefix(lines[1], "#!") {
+		line = 2
+	}
+	var run []string
+	for ; lines[line] != ""; line++ {
+		run = append(run, lines[line])
+	}
+	if len(run) == 0 || (len(s.Tokens) > 0 && s.Tokens[0].Pos.Line == line) {
+		return ""
+	}
+	return commentText(run)
+}
+
+// commentText strips the # markers from a comment run, and one space after
+// each so indentation within the comment survives, and drops directives.
+func commentText(run []string) string {
+	var lines []string
+	for _, text := range run {
+		text = strings.TrimPrefix(text, "#")
+		text = strings.TrimPrefix(text, " ")
+		if strings.HasPrefix(text, "goblin:") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(text, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Module is the documentation of one Goblin file.
+type Module struct {
+	Name    string // file name without .goblin
+	Path    string
+	Doc     string
+	Funcs   []*Func
+	Types   []*Type
+	Exports []*Export
+}
+
+// Func documents a function or a method.
+type Func struct {
+	Name   string
+	Params []Param
+	Doc    string
+	Line   int
+}
+
+// Type documents a user-defined type.
+type Type struct {
+	Name    string
+	Fields  []Param
+	Methods []*Func
+	Doc     string
+	Line    int
+}
+
+// Export documents an exported name. Doc is the comment above the export,
+// or else the one above the definition it exports.
+type Export struct {
+	Name string
+	Kind string // "func", "type", "var", "import", or "" when not found
+	Doc  string
+}
+
+// Param is a parameter or a type field. Default is the default value as
+// Goblin source, or empty when there is none.
+type Param struct {
+	Name    string
+	Default string
+	VarArgs bool
+	KwArgs  bool
+}
+
+func (p Param) String() string {
+	switch {
+	case p.VarArgs:
+		return "*" + p.Name
+	case p.KwArgs:
+		return "**" + p.Name
+	case p.Default != "":
+		return p.Name + "=" + p.Default
+	}
+	return p.Name
+}
+
+// Signature is the function's declaration line without its body.
+func (f *Func) Signature() string {
+	return "func " + f.Name + "(" + paramList(f.Params) + ")"
+}
+
+// Signature is the type's declaration line without its body.
+func (t *Type) Signature() string {
+	return "type " + t.Name + "(" + paramList(t.Fields) + ")"
+}
+
+func paramList(params []Param) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Options select what New documents.
+type Options struct {
+	// All documents every top-level definition. By default a module that
+	// exports names documents only those, as they are all an importer sees.
+	All bool
+}
+
+// File parses the Goblin file at path and documents it.
+func File(path string, opts Options) (*Module, error) {
+	s, err := source.NewScannerFile(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := parser.NewParser().Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return nil, fmt.Errorf("internal error: unexpected AST type")
+	}
+	m := New(mod, Attach(mod, s), opts)
+	m.Path = path
+	m.Name = strings.TrimSuffix(filepath.Base(path), ".goblin")
+	return m, nil
+}
+
+// New documents a module whose doc comments Attach has filled in. doc is the
+// module's own doc.
+func New(mod *ast.Module, doc string, opts Options) *Module {
+	m := &Module{Doc: doc}
+	kinds := map[string]string{}
+	docs := map[string]string{}
+	exported := map[string]bool{}
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefine:
+			kinds[s.Name], docs[s.Name] = "func", s.Doc
+		case *ast.TypeDefine:
+			kinds[s.Name], docs[s.Name] = "type", s.Doc
+		case *ast.Declare:
+			kinds[s.Name] = "var"
+		case *ast.Import:
+			kinds[s.Name] = "import"
+		case *ast.Export:
+			exported[s.Name] = true
+		}
+	}
+	for _, stmt := range mod.Body {
+		if e, ok := stmt.(*ast.Export); ok {
+			d := e.Doc
+			if d == "" {
+				d = docs[e.Name]
+			}
+			m.Exports = append(m.Exports, &Export{Name: e.Name, Kind: kinds[e.Name], Doc: d})
+		}
+	}
+	documented := func(name string) bool {
+		return opts.All || len(exported) == 0 || exported[name]
+	}
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefine:
+			if documented(s.Name) {
+				m.Funcs = append(m.Funcs, NewFunc(s))
+			}
+		case *ast.TypeDefine:
+			if documented(s.Name) {
+				m.Types = append(m.Types, NewType(s))
+			}
+		}
+	}
+	return m
+}
+
+// NewFunc documents a function definition.
+func NewFunc(def *ast.FunctionDefine) *Func {
+	f := &Func{Name: def.Name, Doc: def.Doc, Line: def.Pos.Line}
+	for _, p := range def.Parameters {
+		param := Param{Name: p.Name, VarArgs: p.VarArgs, KwArgs: p.KwArgs}
+		if p.HasDefault() {
+			param.Default = Expr(p.Default)
+		}
+		f.Params = append(f.Params, param)
+	}
+	return f
+}
+
+// NewType documents a type definition and its methods.
+func NewType(def *ast.TypeDefine) *Type {
+	t := &Type{Name: def.Name, Doc: def.Doc, Line: def.Pos.Line}
+	for _, field := range def.Fields {
+		param := Param{Name: field.Name}
+		if field.HasDefault() {
+			param.Default = Expr(field.DefaultValue)
+		}
+		t.Fields = append(t.Fields, param)
+	}
+	for _, m := range def.Methods {
+		t.Methods = append(t.Methods, NewFunc(m))
+	}
+	return t
+}
+
+// Expr renders an expression as Goblin source, for default values. The
+// parser keeps no grouping, so nested operations get parentheses whether or
+// not the source had them; forms a default rarely takes show as "...".
+func Expr(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Literal:
+		if v, ok := e.Value.(object.String); ok {
+			return strconv.Quote(string(v))
+		}
+		return fmt.Sprint(e.Value)
+	case *ast.Identifier:
+		return e.Name
+	case *ast.MemberExpression:
+		return Expr(e.Object) + "." + e.Property
+	case *ast.IndexExpression:
+		return Expr(e.Object) + "[" + Expr(e.Index) + "]"
+	case *ast.UnaryOperation:
+		op := e.Operator
+		if op == ast.Not {
+			op += " "
+		}
+		return op + operand(e.Operand)
+	case *ast.BinaryOperation:
+		return operand(e.LHS) + " " + e.Operator + " " + operand(e.RHS)
+	case *ast.ListLiteral:
+		parts := make([]string, len(e.Elements))
+		for i, el := range e.Elements {
+			parts[i] = Expr(el)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *ast.DictLiteral:
+		parts := make([]string, len(e.Elements))
+		for i, el := range e.Elements {
+			parts[i] = Expr(el.Key) + ": " + Expr(el.Value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case *ast.FunctionCall:
+		return e.Name + "(" + args(e.Args) + ")"
+	case *ast.CallExpression:
+		return Expr(e.Callee) + "(" + args(e.Args) + ")"
+	}
+	return "..."
+}
+
+func operand(e ast.Expression) string {
+	if _, ok := e.(*ast.BinaryOperation); ok {
+		return "(" + Expr(e) + ")"
+	}
+	return Expr(e)
+}
+
+func args(list []ast.CallArgument) string {
+	parts := make([]string, len(list))
+	for i, a := range list {
+		switch a.Kind {
+		case ast.CallArgumentStarred:
+			parts[i] = "*" + Expr(a.Expr)
+		case ast.CallArgumentKeyword:
+			parts[i] = a.Name + "=" + Expr(a.Expr)
+		case ast.CallArgumentKeywordUnpack:
+			parts[i] = "**" + Expr(a.Expr)
+		default:
+			parts[i] = Expr(a.Expr)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
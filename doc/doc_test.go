@@ -0,0 +1,148 @@
+package doc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lib = `#!/usr/bin/env goblin
+# Helpers for greeting people.
+
+# Greets someone by name.
+#   greet("ada") is "hello ada!"
+func greet(name, punctuation="!", *rest, **opts) {
+    return "hello " + name + punctuation
+}
+
+# A registered user.
+type User(name, age=-1, tags=[1, 2], meta={"a": 1 + 2 * 3}) {
+    # The name to show.
+    func label(self) { # not a doc comment
+        return self.name
+    }
+}
+
+# Not exported.
+func helper() {}
+
+var greeting = greet("you") # not a doc comment either
+
+# goblin:ignore unused-variable
+export greet
+export User
+# A ready-made greeting.
+export greeting
+`
+
+func writeLib(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lib.goblin")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestText(t *testing.T) {
+	m, err := File(writeLib(t, lib), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := Text(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `module lib
+
+Helpers for greeting people.
+
+exports: greet, User, greeting
+
+type User(name, age=-1, tags=[1, 2], meta={"a": 1 + (2 * 3)})
+    A registered user.
+
+    func label(self)
+        The name to show.
+
+func greet(name, punctuation="!", *rest, **opts)
+    Greets someone by name.
+      greet("ada") is "hello ada!"
+
+var greeting
+    A ready-made greeting.
+`
+	if b.String() != want {
+		t.Fatalf("Text =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestAll(t *testing.T) {
+	m, err := File(writeLib(t, lib), Options{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Funcs) != 2 || m.Funcs[1].Name != "helper" || m.Funcs[1].Doc != "Not exported." {
+		t.Fatalf("funcs = %+v", m.Funcs)
+	}
+}
+
+func TestModuleDoc(t *testing.T) {
+	// A comment run touching the first declaration documents it, not the
+	// module.
+	m, err := File(writeLib(t, "# Adds.\nfunc add(a, b) { return a + b }\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Doc != "" || m.Funcs[0].Doc != "Adds." {
+		t.Fatalf("module doc %q, func doc %q", m.Doc, m.Funcs[0].Doc)
+	}
+}
+
+func TestExportDoc(t *testing.T) {
+	// An exported function with no comment of its own is documented by the
+	// comment above its export.
+	m, err := File(writeLib(t, "func add(a, b) { return a + b }\n\n# Adds.\nexport add\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Funcs) != 1 || m.Funcs[0].Doc != "Adds." {
+		t.Fatalf("funcs = %+v", m.Funcs)
+	}
+}
+
+func TestMarkdownAndHTML(t *testing.T) {
+	m, err := File(writeLib(t, lib), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md, html bytes.Buffer
+	if err := Markdown(&md, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := HTML(&html, m); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# lib\n\nHelpers for greeting people.\n",
+		"Exports: `greet`, `User`, `greeting`\n",
+		"## type User\n\n~~~goblin\ntype User(",
+		"### User.label\n\n~~~goblin\nfunc label(self)\n~~~\n\nThe name to show.\n",
+		"## var greeting\n",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+	for _, want := range []string{
+		`<h2 id="greet">func greet</h2>`,
+		`<pre><code>func greet(name, punctuation=&#34;!&#34;, *rest, **opts)</code></pre>`,
+		`<h3 id="User.label">User.label</h3>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("HTML lacks %q:\n%s", want, html.String())
+		}
+	}
+}
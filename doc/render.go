@@ -0,0 +1,159 @@
+package doc
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Text writes the module's documentation as plain text, the default output
+// of `goblin doc`.
+func Text(w io.Writer, m *Module) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "module %s\n", m.Name)
+	if m.Doc != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.Doc)
+	}
+	if len(m.Exports) > 0 {
+		names := make([]string, len(m.Exports))
+		for i, e := range m.Exports {
+			names[i] = e.Name
+		}
+		fmt.Fprintf(&b, "\nexports: %s\n", strings.Join(names, ", "))
+	}
+	for _, t := range m.Types {
+		b.WriteString("\n" + TypeHelp(t))
+	}
+	for _, f := range m.Funcs {
+		b.WriteString("\n" + FuncHelp(f))
+	}
+	for _, e := range m.Exports {
+		if e.Kind != "func" && e.Kind != "type" {
+			b.WriteString("\n" + exportHelp(e))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FuncHelp is the text help() shows for a function: its signature, then its
+// doc comment indented under it.
+func FuncHelp(f *Func) string {
+	return f.Signature() + "\n" + indent(f.Doc, "    ")
+}
+
+// TypeHelp is the text help() shows for a type: its signature and doc
+// comment, then those of its methods.
+func TypeHelp(t *Type) string {
+	text := t.Signature() + "\n" + indent(t.Doc, "    ")
+	for _, m := range t.Methods {
+		text += "\n" + indent(FuncHelp(m), "    ")
+	}
+	return text
+}
+
+// exportHelp describes an exported name that is not a function or a type.
+func exportHelp(e *Export) string {
+	kind := e.Kind
+	if kind == "" {
+		kind = "export"
+	}
+	return kind + " " + e.Name + "\n" + indent(e.Doc, "    ")
+}
+
+// indent prefixes the non-empty lines of text, ending each line with a line
+// break. Empty text stays empty.
+func indent(text, prefix string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line != "" {
+			b.WriteString(prefix)
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Markdown writes the module's documentation as a Markdown page that can sit
+// in an mdBook next to the language guide. Doc comments are copied as
+// Markdown; signatures go in goblin code blocks.
+func Markdown(w io.Writer, m *Module) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", m.Name)
+	if m.Doc != "" {
+		fmt.Fprintf(&b, "\n%s\n", m.Doc)
+	}
+	if len(m.Exports) > 0 {
+		names := make([]string, len(m.Exports))
+		for i, e := range m.Exports {
+			names[i] = "`" + e.Name + "`"
+		}
+		fmt.Fprintf(&b, "\nExports: %s\n", strings.Join(names, ", "))
+	}
+	section := func(level, title, code, doc string) {
+		fmt.Fprintf(&b, "\n%s %s\n\n~~~goblin\n%s\n~~~\n", level, title, code)
+		if doc != "" {
+			fmt.Fprintf(&b, "\n%s\n", doc)
+		}
+	}
+	for _, t := range m.Types {
+		section("##", "type "+t.Name, t.Signature(), t.Doc)
+		for _, f := range t.Methods {
+			section("###", t.Name+"."+f.Name, f.Signature(), f.Doc)
+		}
+	}
+	for _, f := range m.Funcs {
+		section("##", "func "+f.Name, f.Signature(), f.Doc)
+	}
+	for _, e := range m.Exports {
+		if e.Kind != "func" && e.Kind != "type" {
+			section("##", e.Kind+" "+e.Name, e.Kind+" "+e.Name, e.Doc)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlPage = template.Must(template.New("doc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+pre { background: #f6f7f6; padding: 0.5em 1em; overflow-x: auto; }
+.doc { white-space: pre-wrap; }
+.method { margin-left: 2em; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+{{with .Doc}}<p class="doc">{{.}}</p>
+{{end}}{{with .Exports}}<p>Exports:{{range $i, $e := .}}{{if $i}},{{end}} <a href="#{{$e.Name}}"><code>{{$e.Name}}</code></a>{{end}}</p>
+{{end}}{{range .Types}}<h2 id="{{.Name}}">type {{.Name}}</h2>
+<pre><code>{{.Signature}}</code></pre>
+{{with .Doc}}<p class="doc">{{.}}</p>
+{{end}}{{$type := .Name}}{{range .Methods}}<div class="method">
+<h3 id="{{$type}}.{{.Name}}">{{$type}}.{{.Name}}</h3>
+<pre><code>{{.Signature}}</code></pre>
+{{with .Doc}}<p class="doc">{{.}}</p>
+{{end}}</div>
+{{end}}{{end}}{{range .Funcs}}<h2 id="{{.Name}}">func {{.Name}}</h2>
+<pre><code>{{.Signature}}</code></pre>
+{{with .Doc}}<p class="doc">{{.}}</p>
+{{end}}{{end}}{{range .Exports}}{{if and (ne .Kind "func") (ne .Kind "type")}}<h2 id="{{.Name}}">{{.Kind}} {{.Name}}</h2>
+{{with .Doc}}<p class="doc">{{.}}</p>
+{{end}}{{end}}{{end}}</body>
+</html>
+`))
+
+// HTML writes the module's documentation as a standalone HTML page. Doc
+// comments are shown as written, with their line breaks.
+func HTML(w io.Writer, m *Module) error {
+	return htmlPage.Execute(w, m)
+}
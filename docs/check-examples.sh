@@ -16,3 +16,4 @@ run_example "$repo_dir/docs/examples/concurrency-results.goblin"
 run_example "$repo_dir/docs/examples/goblin-handle.goblin"
 run_example "$repo_dir/docs/examples/local-module/main.goblin"
 run_example "$repo_dir/docs/examples/reference-smoke.goblin"
+run_example "$repo_dir/docs/examples/task-group.goblin"
@@ -0,0 +1,151 @@
+// Package engine embeds the Goblin interpreter in Go programs, as a scripting
+// layer. A VM runs Goblin source with streams, globals, functions and modules
+// of its own, so several VMs run side by side in one process without sharing
+// state, and a context cancels the code a VM runs:
+//
+//	vm := engine.New(engine.Options{Stdout: &out})
+//	vm.RegisterFunction("greet", greet)
+//	if err := vm.Set("limit", 10); err != nil { ... }
+//	if _, err := vm.Eval(ctx, src); err != nil { ... }
+//	args, err := engine.Args("world", 3)
+//	result, err := vm.Call(ctx, "main", args)
+//
+// Values cross the boundary as object.Object. Set and Args convert plain Go
+// values with object.FromGo, and object.ToGo converts results back.
+package engine
+
+import (
+	"context"
+	"io"
+
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/interpreter"
+	"github.com/aisk/goblin/object"
+)
+
+// Options configure a VM. The zero value runs code relative to the current
+// directory, with the process's standard streams.
+type Options struct {
+	// Dir is the directory relative imports resolve against, and the one
+	// import "embed" reads; empty means the current directory.
+	Dir string
+	// Args is what os.argv() returns; nil means a single empty string, as
+	// in the REPL.
+	Args []string
+	// Stdin, Stdout and Stderr are the VM's standard streams: print and
+	// help write to Stdout, eprint and the reports of failed goblins to
+	// Stderr, and commands started with exec.INHERIT use all three. A nil
+	// stream is the process's.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Sandbox, when set, restricts what the VM's code may import and
+	// limits each Eval and Call, for running code that is not trusted.
+	Sandbox *Sandbox
+	// BuildConstants are what the build module's get() and constants()
+	// report to the VM's code.
+	BuildConstants map[string]string
+}
+
+// ExitError is the error Eval or Call returns when the code calls os.exit,
+// which ends the code but not the process. errors.As finds it, with the
+// status the code passed.
+type ExitError = extension.ExitError
+
+// Sandbox restricts what a VM's code may import and how much each Eval and
+// Call may run; see interpreter.Sandbox. An exceeded limit stops the code
+// with an error that errors.Is matches against the limit's kind, such as
+// object.StepLimitError.
+type Sandbox = interpreter.Sandbox
+
+// DefaultSandbox returns the sandbox of `goblin run --sandbox`.
+func DefaultSandbox() *Sandbox {
+	return interpreter.DefaultSandbox()
+}
+
+// VM is a Goblin interpreter with a persistent global scope: the names one
+// Eval declares are visible to the next, and to Get and Call. A VM is not
+// safe for concurrent use.
+type VM struct {
+	session *interpreter.Session
+}
+
+// New creates a VM configured by opts.
+func New(opts Options) *VM {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	return &VM{session: interpreter.NewSessionWithOptions(dir, interpreter.Options{
+		Stdin:          opts.Stdin,
+		Stdout:         opts.Stdout,
+		Stderr:         opts.Stderr,
+		Argv:           opts.Args,
+		Sandbox:        opts.Sandbox,
+		BuildConstants: opts.BuildConstants,
+	})}
+}
+
+// Eval runs src, a Goblin program or fragment, in the VM's global scope. When
+// its last statement is an expression, Eval returns its value, and nil
+// otherwise. Once ctx is done the code stops with ctx's error, which Goblin
+// code cannot catch; a Go function blocked in a call finishes first.
+func (vm *VM) Eval(ctx context.Context, src string) (object.Object, error) {
+	return vm.session.EvalContext(ctx, src)
+}
+
+// Set binds name in the VM's global scope to value, converted with
+// object.FromGo.
+func (vm *VM) Set(name string, value any) error {
+	v, err := object.FromGo(value)
+	if err != nil {
+		return err
+	}
+	vm.session.Define(name, v)
+	return nil
+}
+
+// Get returns the value of name in the VM's global scope. object.ToGo
+// converts it to a plain Go value.
+func (vm *VM) Get(name string) (object.Object, bool) {
+	return vm.session.Get(name)
+}
+
+// Call calls the global function name with args, stopping it once ctx is
+// done as Eval does.
+func (vm *VM) Call(ctx context.Context, name string, args object.CallArgs) (object.Object, error) {
+	fn, ok := vm.session.Get(name)
+	if !ok {
+		return nil, object.NewNameError("undefined: %s", name)
+	}
+	return vm.session.Call(ctx, fn, args)
+}
+
+// RegisterFunction makes fn a built-in function of the VM, callable as name
+// from every module the VM runs, imported ones included, unless the module
+// declares name itself. It overrides a standard built-in of the same name.
+// Call it before the code that uses the function runs.
+func (vm *VM) RegisterFunction(name string, fn func(args object.CallArgs) (object.Object, error)) {
+	vm.session.DefineBuiltin(name, &object.Function{Name: name, Fn: fn})
+}
+
+// RegisterModule makes the module execute returns importable as name in the
+// VM alone, ahead of the modules registered with object.RegisterModule. The
+// VM calls execute on the first import of name. Call it before the code that
+// imports the module runs.
+func (vm *VM) RegisterModule(name string, execute func() (object.Object, error)) {
+	vm.session.RegisterModule(name, execute)
+}
+
+// Args converts plain Go values with object.FromGo into the positional
+// arguments of a call.
+func Args(values ...any) (object.CallArgs, error) {
+	positional := make(object.Args, len(values))
+	for i, value := range values {
+		v, err := object.FromGo(value)
+		if err != nil {
+			return object.CallArgs{}, err
+		}
+		positional[i] = v
+	}
+	return object.CallArgs{Positional: positional}, nil
+}
@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aisk/goblin/object"
+)
+
+func TestStreams(t *testing.T) {
+	var stdout, stderr strings.Builder
+	vm := New(Options{Stdout: &stdout, Stderr: &stderr})
+	if _, err := vm.Eval(context.Background(), `
+print("hello", 1)
+eprint("oops")
+help(print)
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "hello 1\nfunc print\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "oops\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestExecInheritsStreams(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	var stdout strings.Builder
+	vm := New(Options{Stdin: strings.NewReader("from stdin\n"), Stdout: &stdout})
+	if _, err := vm.Eval(context.Background(), `
+import "exec"
+exec.Command("cat").run()
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "from stdin\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	vm := New(Options{})
+	if err := vm.Set("limits", map[string]any{"count": 3, "names": []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Eval(context.Background(), `var total = limits["count"] * 2`); err != nil {
+		t.Fatal(err)
+	}
+	total, ok := vm.Get("total")
+	if !ok {
+		t.Fatal("total is not defined")
+	}
+	if got, err := object.ToGo(total); err != nil || got != int64(6) {
+		t.Errorf("total = %v, %v, want 6", got, err)
+	}
+	if _, ok := vm.Get("print"); ok {
+		t.Error("Get returned a built-in")
+	}
+	if err := vm.Set("bad", make(chan int)); err == nil {
+		t.Error("Set of a channel succeeded")
+	}
+}
+
+func TestCall(t *testing.T) {
+	vm := New(Options{})
+	if _, err := vm.Eval(context.Background(), `
+func greet(name, times=1) {
+    return ("hello " + name) * times
+}
+`); err != nil {
+		t.Fatal(err)
+	}
+	args, err := Args("goblin", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := vm.Call(context.Background(), "greet", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != object.String("hello goblinhello goblin") {
+		t.Errorf("greet() = %v", result)
+	}
+	if _, err := vm.Call(context.Background(), "missing", object.CallArgs{}); err == nil || !strings.Contains(err.Error(), "undefined: missing") {
+		t.Errorf("Call of an undefined name = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var stdout strings.Builder
+	vm := New(Options{Stdout: &stdout})
+	vm.RegisterFunction("double", func(args object.CallArgs) (object.Object, error) {
+		p := object.NewArgParser("double", args)
+		n := p.Int("n")
+		if err := p.Finish(); err != nil {
+			return nil, err
+		}
+		return object.Integer(n * 2), nil
+	})
+	vm.RegisterModule("config", func() (object.Object, error) {
+		return &object.Module{Name: "config", Members: map[string]object.Object{"NAME": object.String("service")}}, nil
+	})
+	if _, err := vm.Eval(context.Background(), `
+import "config"
+print(config.NAME, double(21))
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "service 42\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+
+	// Another VM sees neither.
+	other := New(Options{})
+	if _, err := other.Eval(context.Background(), `double(1)`); err == nil {
+		t.Error("a function registered in one VM is defined in another")
+	}
+	if _, err := other.Eval(context.Background(), `import "config"`); err == nil || !strings.Contains(err.Error(), "unknown module: config") {
+		t.Errorf("import of a module registered in another VM = %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	vm := New(Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := vm.Eval(ctx, `
+var n = 0
+while true {
+    try {
+        n = n + 1
+    } catch e {
+        print("caught", e)
+    }
+}
+`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Eval = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The VM stays usable with a new context.
+	if _, err := vm.Eval(context.Background(), `func loop() { while true {} }`); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := vm.Call(ctx, "loop", object.CallArgs{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call = %v, want context.Canceled", err)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	sb := DefaultSandbox()
+	sb.MaxSteps = 1000
+	vm := New(Options{Sandbox: sb})
+	vm.RegisterModule("host", func() (object.Object, error) {
+		return &object.Module{Name: "host", Members: map[string]object.Object{}}, nil
+	})
+	if _, err := vm.Eval(context.Background(), `import "os"`); !errors.Is(err, object.ImportError) {
+		t.Errorf("import of os = %v, want ImportError", err)
+	}
+	if _, err := vm.Eval(context.Background(), `import "host"`); err != nil {
+		t.Errorf("import of a VM module = %v", err)
+	}
+	if _, err := vm.Eval(context.Background(), `func spin() { while true {} }`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Call(context.Background(), "spin", object.CallArgs{}); !errors.Is(err, object.StepLimitError) {
+		t.Errorf("Call = %v, want StepLimitError", err)
+	}
+}
+
+func TestExit(t *testing.T) {
+	vm := New(Options{})
+	_, err := vm.Eval(context.Background(), `
+import "os"
+var after = false
+try {
+    os.exit(3)
+} catch e {
+    after = true
+}
+after = true
+`)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("Eval = %v, want ExitError with code 3", err)
+	}
+	if after, _ := vm.Get("after"); after != object.Bool(false) {
+		t.Fatalf("after = %v, want the code to end at os.exit", after)
+	}
+	// The VM runs on.
+	if v, err := vm.Eval(context.Background(), `1 + 1`); err != nil || v != object.Integer(2) {
+		t.Fatalf("Eval after os.exit = %v, %v", v, err)
+	}
+}
+
+func TestBuildConstantsPerVM(t *testing.T) {
+	a := New(Options{BuildConstants: map[string]string{"name": "a"}})
+	b := New(Options{BuildConstants: map[string]string{"name": "b"}})
+	for vm, want := range map[*VM]object.Object{a: object.String("a"), b: object.String("b")} {
+		if v, err := vm.Eval(context.Background(), "import \"build\"\nbuild.get(\"name\")"); err != nil || v != want {
+			t.Errorf("build.get(name) = %v, %v, want %v", v, err, want)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/parser"
 	"github.com/aisk/goblin/semantic"
 	"github.com/aisk/goblin/source"
@@ -48,7 +49,7 @@ func TestExamples(t *testing.T) {
 // channels; any example using one is also run under the Go race detector.
 // Detection by content means a new concurrency example cannot silently skip
 // the race run.
-var concurrencyMarkers = regexp.MustCompile(`\bspawn\b|\bChan\(|\bGoblin\(`)
+var concurrencyMarkers = regexp.MustCompile(`\bspawn\b|\bChan\(|\bGoblin[(.]|\bTaskGroup\(`)
 
 // raceExamples lists the examples whose transpiled form is also run under the
 // race detector, guarding the coordination patterns the book recommends.
@@ -93,14 +94,20 @@ func TestExamplesRace(t *testing.T) {
 // parseAndTranspile reads a .goblin file, parses and transpiles it to Go code.
 func parseAndTranspile(t *testing.T, goblinFile string) string {
 	t.Helper()
+	return parseAndTranspileOptions(t, goblinFile, transpiler.Options{})
+}
+
+// parseAndTranspileOptions is parseAndTranspile with transpiler options.
+func parseAndTranspileOptions(t *testing.T, goblinFile string, opts transpiler.Options) string {
+	t.Helper()
 
-	l, err := source.NewLexerFile(goblinFile)
+	s, err := source.NewScannerFile(goblinFile)
 	if err != nil {
 		t.Fatalf("failed to read file: %v", err)
 	}
 
 	p := parser.NewParser()
-	st, err := p.Parse(l)
+	st, err := p.Parse(s)
 	if err != nil {
 		t.Fatalf("parse error: %v", err)
 	}
@@ -109,12 +116,13 @@ func parseAndTranspile(t *testing.T, goblinFile string) string {
 	if !ok {
 		t.Fatalf("failed to convert AST to Module")
 	}
+	doc.Attach(module, s)
 	if err := semantic.CheckModule(module); err != nil {
 		t.Fatalf("semantic error: %v", err)
 	}
 
 	var buf bytes.Buffer
-	if err := transpiler.Transpile(module, &buf); err != nil {
+	if err := transpiler.TranspileOptions(module, &buf, opts); err != nil {
 		t.Fatalf("transpile error: %v", err)
 	}
 	return buf.String()
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/interpreter"
 	"github.com/aisk/goblin/parser"
 	"github.com/aisk/goblin/semantic"
@@ -29,22 +30,40 @@ func TestInterpreterExamples(t *testing.T) {
 	for _, file := range files {
 		baseName := strings.TrimSuffix(filepath.Base(file), ".goblin")
 		t.Run(baseName, func(t *testing.T) {
-			stdout, stderr := runInterpreter(t, file)
+			stdout, stderr := runInterpreter(t, file, interpreter.Options{})
 			checkOutput(t, ".", baseName, ".stdout", stdout, true)
 			checkOutput(t, ".", baseName, ".stderr", stderr, false)
 		})
 	}
 }
 
-// runInterpreter parses and interprets a file, capturing os.Stdout and os.Stderr.
-func runInterpreter(t *testing.T, goblinFile string) (stdout, stderr string) {
+// TestBytecodeExamples runs each .goblin file on the bytecode VM against the
+// same golden files.
+func TestBytecodeExamples(t *testing.T) {
+	files, err := filepath.Glob("*.goblin")
+	if err != nil {
+		t.Fatalf("failed to find .goblin files: %v", err)
+	}
+	for _, file := range files {
+		baseName := strings.TrimSuffix(filepath.Base(file), ".goblin")
+		t.Run(baseName, func(t *testing.T) {
+			stdout, stderr := runInterpreter(t, file, interpreter.Options{Bytecode: true})
+			checkOutput(t, ".", baseName, ".stdout", stdout, true)
+			checkOutput(t, ".", baseName, ".stderr", stderr, false)
+		})
+	}
+}
+
+// runInterpreter parses and interprets a file with opts, capturing os.Stdout
+// and os.Stderr.
+func runInterpreter(t *testing.T, goblinFile string, opts interpreter.Options) (stdout, stderr string) {
 	t.Helper()
 
-	l, err := source.NewLexerFile(goblinFile)
+	s, err := source.NewScannerFile(goblinFile)
 	if err != nil {
 		t.Fatalf("failed to read file: %v", err)
 	}
-	st, err := parser.NewParser().Parse(l)
+	st, err := parser.NewParser().Parse(s)
 	if err != nil {
 		t.Fatalf("parse error: %v", err)
 	}
@@ -52,6 +71,7 @@ func runInterpreter(t *testing.T, goblinFile string) (stdout, stderr string) {
 	if !ok {
 		t.Fatalf("failed to convert AST to Module")
 	}
+	doc.Attach(module, s)
 	if err := semantic.CheckModule(module); err != nil {
 		t.Fatalf("semantic error: %v", err)
 	}
@@ -81,7 +101,7 @@ func runInterpreter(t *testing.T, goblinFile string) (stdout, stderr string) {
 		errDone <- string(data)
 	}()
 
-	runErr := interpreter.Run(module, goblinFile)
+	runErr := interpreter.RunWithOptions(module, goblinFile, opts)
 
 	outW.Close()
 	errW.Close()
@@ -0,0 +1,83 @@
+package examples_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aisk/goblin/transpiler"
+)
+
+// TestProfileFromEnv confirms that a program transpiled for profiling writes a
+// Goblin-level profile to the file GOBLIN_CPUPROFILE names, even when it ends
+// through os.exit, and that go tool pprof reads it.
+func TestProfileFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	goblinSrc := "import \"os\"\nfunc fib(n) {\n  if n < 2 {\n    return n\n  }\n  return fib(n - 1) + fib(n - 2)\n}\nprint(fib(10))\nos.exit(2)\n"
+	goblinPath := filepath.Join(dir, "fib.goblin")
+	if err := os.WriteFile(goblinPath, []byte(goblinSrc), 0644); err != nil {
+		t.Fatalf("write goblin: %v", err)
+	}
+	goPath := filepath.Join(dir, "fib.go")
+	if err := os.WriteFile(goPath, []byte(parseAndTranspileOptions(t, goblinPath, transpiler.Options{Profile: true})), 0644); err != nil {
+		t.Fatalf("write go: %v", err)
+	}
+
+	// go run would report the exit status as 1; build to see the real one.
+	bin := filepath.Join(dir, "fib")
+	if out, err := exec.Command("go", "build", "-o", bin, goPath).CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	profile := filepath.Join(dir, "prof.out")
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), "GOBLIN_CPUPROFILE="+profile)
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 2 || string(out) != "55\n" {
+		t.Fatalf("run: err=%v, output:\n%s", err, out)
+	}
+
+	top, err := exec.Command("go", "tool", "pprof", "-top", "-sample_index=calls", profile).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pprof: %v\n%s", err, top)
+	}
+	// fib(10) makes 177 calls.
+	if !strings.Contains(string(top), "177") || !strings.Contains(string(top), "fib.fib") {
+		t.Fatalf("pprof -top -sample_index=calls:\n%s", top)
+	}
+}
+
+// TestProfileFromEnvWithoutProfile confirms that a program transpiled without
+// Profile warns, rather than stays silent, when GOBLIN_CPUPROFILE asks it for
+// a profile it cannot record.
+func TestProfileFromEnvWithoutProfile(t *testing.T) {
+	dir := t.TempDir()
+	goblinPath := filepath.Join(dir, "hello.goblin")
+	if err := os.WriteFile(goblinPath, []byte("print(\"hello\")\n"), 0644); err != nil {
+		t.Fatalf("write goblin: %v", err)
+	}
+	goPath := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(goPath, []byte(parseAndTranspile(t, goblinPath)), 0644); err != nil {
+		t.Fatalf("write go: %v", err)
+	}
+
+	profile := filepath.Join(dir, "prof.out")
+	cmd := exec.Command("go", "run", goPath)
+	cmd.Env = append(os.Environ(), "GOBLIN_CPUPROFILE="+profile)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil || string(out) != "hello\n" {
+		t.Fatalf("run: err=%v, output:\n%s%s", err, out, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "built without --profile") {
+		t.Errorf("stderr = %q, want a warning that the build has no profiler", stderr.String())
+	}
+	if _, err := os.Stat(profile); !os.IsNotExist(err) {
+		t.Errorf("profile file exists: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package extension
+
+import (
+	"net/url"
+	"runtime"
+
+	"github.com/aisk/goblin/object"
+)
+
+// buildConstants holds the constants given to `goblin build-exe -X
+// name=value`, URL-query encoded. build-exe sets it with the Go linker's -X
+// flag, so a constant costs nothing at run time and needs no generated code.
+var buildConstants string
+
+// BuildConstantsVar is the linker symbol of buildConstants.
+const BuildConstantsVar = "github.com/aisk/goblin/extension.buildConstants"
+
+// EncodeBuildConstants encodes constants for BuildConstantsVar. The result
+// has no spaces or quotes, so it can go in -ldflags as is.
+func EncodeBuildConstants(constants map[string]string) string {
+	values := url.Values{}
+	for name, value := range constants {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+// ExecuteBuild builds the build module of a compiled program, with the
+// constants build-exe linked in.
+func ExecuteBuild() (object.Object, error) {
+	// The value only ever comes from EncodeBuildConstants.
+	values, _ := url.ParseQuery(buildConstants)
+	return newBuildModule(values), nil
+}
+
+// ExecuteBuildWith returns the executor of a build module reporting
+// constants, for `goblin run -X`. Each interpreted program has its own.
+func ExecuteBuildWith(constants map[string]string) object.ModuleExecutor {
+	return func() (object.Object, error) {
+		values := url.Values{}
+		for name, value := range constants {
+			values.Set(name, value)
+		}
+		return newBuildModule(values), nil
+	}
+}
+
+func newBuildModule(values url.Values) object.Object {
+	return &object.Module{Name: "build", Members: map[string]object.Object{
+		"get": &object.Function{Name: "get", Fn: func(args object.CallArgs) (object.Object, error) {
+			return buildGet(values, args)
+		}},
+		"constants": &object.Function{Name: "constants", Fn: func(args object.CallArgs) (object.Object, error) {
+			return buildConstantsDict(values, args)
+		}},
+		"OS":   object.String(runtime.GOOS),
+		"ARCH": object.String(runtime.GOARCH),
+	}}
+}
+
+func buildGet(values url.Values, args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("get", args)
+	name := p.Str("name")
+	def := p.AnyOr("default", object.Nil)
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	if !values.Has(string(name)) {
+		return def, nil
+	}
+	return object.String(values.Get(string(name))), nil
+}
+
+func buildConstantsDict(values url.Values, args object.CallArgs) (object.Object, error) {
+	if err := object.RequireNoArgs("constants", args); err != nil {
+		return nil, err
+	}
+	result := object.NewDict()
+	for name, vs := range values {
+		if err := result.Set(object.String(name), object.String(vs[0])); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package extension
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/aisk/goblin/object"
+)
+
+func TestBuildConstants(t *testing.T) {
+	mod, err := ExecuteBuildWith(map[string]string{"version": "1.2", "note": "a b&c=d"})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := mod.(*object.Module).Members
+	get, constants := members["get"].(*object.Function), members["constants"].(*object.Function)
+	got, err := get.Call(object.CallArgs{Positional: object.Args{object.String("note")}})
+	if err != nil || got != object.String("a b&c=d") {
+		t.Fatalf("get(note) = %v, %v", got, err)
+	}
+	got, err = get.Call(object.CallArgs{Positional: object.Args{object.String("missing"), object.String("dev")}})
+	if err != nil || got != object.String("dev") {
+		t.Fatalf("get(missing, dev) = %v, %v", got, err)
+	}
+	got, err = get.Call(object.CallArgs{Positional: object.Args{object.String("missing")}})
+	if err != nil || got != object.Nil {
+		t.Fatalf("get(missing) = %v, %v", got, err)
+	}
+	all, err := constants.Call(object.CallArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := all.(*object.Dict)
+	version, _, _ := d.Get(object.String("version"))
+	if d.Len() != 2 || version != object.String("1.2") {
+		t.Fatalf("constants() = %v", all)
+	}
+}
+
+func TestBuildPlatform(t *testing.T) {
+	mod, err := ExecuteBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := mod.(*object.Module).Members
+	if members["OS"] != object.String(runtime.GOOS) || members["ARCH"] != object.String(runtime.GOARCH) {
+		t.Fatalf("OS, ARCH = %v, %v", members["OS"], members["ARCH"])
+	}
+	if _, ok := members["os"]; ok {
+		t.Fatal("the build module still has a lowercase os constant")
+	}
+}
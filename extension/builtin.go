@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/aisk/goblin/object"
@@ -14,6 +15,7 @@ var BuiltinsModule = &object.Module{
 	Members: map[string]object.Object{
 		"print":               &object.Function{Name: "print", Fn: print},
 		"eprint":              &object.Function{Name: "eprint", Fn: eprint},
+		"help":                &object.Function{Name: "help", Fn: help},
 		"spawn":               &object.Function{Name: "spawn", Fn: spawn},
 		"range":               &object.Function{Name: "range", Fn: range_},
 		"max":                 &object.Function{Name: "max", Fn: max},
@@ -46,10 +48,32 @@ var BuiltinsModule = &object.Module{
 		"Dict":                object.DictConstructorFn,
 		"Chan":                object.ChanConstructorFn,
 		"Goblin":              object.GoblinConstructorFn,
+		"TaskGroup":           object.TaskGroupConstructorFn,
 		"Function":            object.FunctionConstructorFn,
 	},
 }
 
+// StreamBuiltins returns the built-in functions that use the standard
+// streams, bound to stdout and stderr instead: print, eprint, help, and spawn,
+// which reports a failed goblin on stderr. An interpreter running a program
+// with streams of its own resolves these names here before BuiltinsModule.
+func StreamBuiltins(stdout, stderr io.Writer) map[string]object.Object {
+	return map[string]object.Object{
+		"print": &object.Function{Name: "print", Fn: func(args object.CallArgs) (object.Object, error) {
+			return writeLine("print", stdout, args)
+		}},
+		"eprint": &object.Function{Name: "eprint", Fn: func(args object.CallArgs) (object.Object, error) {
+			return writeLine("eprint", stderr, args)
+		}},
+		"help": &object.Function{Name: "help", Fn: func(args object.CallArgs) (object.Object, error) {
+			return writeHelp(stdout, args)
+		}},
+		"spawn": &object.Function{Name: "spawn", Fn: func(args object.CallArgs) (object.Object, error) {
+			return spawnReporting(stderr, args)
+		}},
+	}
+}
+
 // print writes values to stdout, separated by spaces, ending with a newline.
 // Positional-only.
 func print(args object.CallArgs) (object.Object, error) {
@@ -90,12 +114,20 @@ func writeLine(name string, w io.Writer, args object.CallArgs) (object.Object, e
 // function's return value and error are discarded, mirroring Go's `go`
 // statement. Use a Chan to communicate results back.
 func spawn(args object.CallArgs) (object.Object, error) {
+	return spawnReporting(os.Stderr, args)
+}
+
+// spawnReporting is spawn, reporting a failed goblin on stderr.
+func spawnReporting(stderr io.Writer, args object.CallArgs) (object.Object, error) {
 	p := object.NewArgParser("spawn", args)
 	fn := p.Func("fn")
 	rest := p.Rest()
 	if err := p.Finish(); err != nil {
 		return nil, err
 	}
+	if err := fn.CheckGoblin(); err != nil {
+		return nil, err
+	}
 	object.EnterConcurrentMode()
 	go func() {
 		// A panic inside a goroutine would kill the whole process regardless
@@ -104,16 +136,74 @@ func spawn(args object.CallArgs) (object.Object, error) {
 		// undebuggable, so report both on stderr.
 		defer func() {
 			if r := recover(); r != nil {
-				fmt.Fprintf(os.Stderr, "internal error in spawned function: %v\n", r)
+				fmt.Fprintf(stderr, "internal error in spawned function: %v\n", r)
 			}
 		}()
 		if _, err := fn.Call(object.CallArgs{Positional: rest}); err != nil {
-			fmt.Fprintf(os.Stderr, "uncaught error in spawned function: %v\n", err)
+			fmt.Fprintf(stderr, "uncaught error in spawned function: %v\n", err)
 		}
 	}()
 	return object.Nil, nil
 }
 
+// help prints the documentation of a value: the signature and doc comment of
+// a Goblin function or type, the members of a module, or else the value's
+// type and attributes.
+func help(args object.CallArgs) (object.Object, error) {
+	return writeHelp(os.Stdout, args)
+}
+
+func writeHelp(w io.Writer, args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("help", args)
+	value := p.Any("value")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	fmt.Fprint(w, HelpText(value))
+	return object.Nil, nil
+}
+
+// HelpText is what help(value) prints.
+func HelpText(value object.Object) string {
+	switch v := value.(type) {
+	case *object.Function:
+		if d := v.Help(); d != "" {
+			return d
+		}
+		// Go functions, and Goblin ones built with build-exe, carry no
+		// doc.
+		return "func " + v.Name + "\n"
+	case *object.Module:
+		var b strings.Builder
+		fmt.Fprintf(&b, "module %s\n", v.Name)
+		names := make([]string, 0, len(v.Members))
+		for name := range v.Members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.WriteString("\n")
+			if fn, ok := v.Members[name].(*object.Function); ok && fn.Help() != "" {
+				b.WriteString(fn.Help())
+			} else {
+				fmt.Fprintf(&b, "%s: %s\n", name, v.Members[name].TypeName())
+			}
+		}
+		return b.String()
+	}
+	// An instance of a Goblin type is described by its type.
+	if c, err := value.GetAttr("constructor"); err == nil {
+		if fn, ok := c.(*object.Function); ok && fn.Help() != "" {
+			return fn.Help()
+		}
+	}
+	text := value.TypeName() + " value\n"
+	if attrs := value.Attributes(); len(attrs) > 0 {
+		text += "    attributes: " + strings.Join(attrs, ", ") + "\n"
+	}
+	return text
+}
+
 func range_(args object.CallArgs) (object.Object, error) {
 	p := object.NewArgParser("range", args)
 	start, end := p.Int("start"), p.Int("end")
@@ -176,6 +266,10 @@ func max(args object.CallArgs) (object.Object, error) {
 				if i == 0 || float64(v) > maxValue {
 					maxValue = float64(v)
 				}
+			case *object.BigInt:
+				if i == 0 || v.Float64() > maxValue {
+					maxValue = v.Float64()
+				}
 			default:
 				return nil, object.NewTypeError("max() argument %d: invalid type %s", i, arg.TypeName())
 			}
@@ -183,17 +277,26 @@ func max(args object.CallArgs) (object.Object, error) {
 		return object.Float(maxValue), nil
 	}
 
-	maxIntValue := int64(0)
+	// Integers may be BigInts, so they are ranked with Compare rather than
+	// as int64.
+	var maxIntValue object.Object
 	for i, arg := range nums {
-		if v, ok := arg.(object.Integer); ok {
-			if i == 0 || int64(v) > maxIntValue {
-				maxIntValue = int64(v)
-			}
-		} else {
+		switch arg.(type) {
+		case object.Integer, *object.BigInt:
+		default:
 			return nil, object.NewTypeError("max() argument %d: invalid type %s", i, arg.TypeName())
 		}
+		if i == 0 {
+			maxIntValue = arg
+			continue
+		}
+		if c, err := object.Compare(arg, maxIntValue); err != nil {
+			return nil, err
+		} else if c == 1 {
+			maxIntValue = arg
+		}
 	}
-	return object.Integer(maxIntValue), nil
+	return maxIntValue, nil
 }
 
 func min(args object.CallArgs) (object.Object, error) {
@@ -226,6 +329,10 @@ func min(args object.CallArgs) (object.Object, error) {
 				if i == 0 || float64(v) < minValue {
 					minValue = float64(v)
 				}
+			case *object.BigInt:
+				if i == 0 || v.Float64() < minValue {
+					minValue = v.Float64()
+				}
 			default:
 				return nil, object.NewTypeError("min() argument %d: invalid type %s", i, arg.TypeName())
 			}
@@ -233,15 +340,22 @@ func min(args object.CallArgs) (object.Object, error) {
 		return object.Float(minValue), nil
 	}
 
-	minIntValue := int64(0)
+	var minIntValue object.Object
 	for i, arg := range nums {
-		if v, ok := arg.(object.Integer); ok {
-			if i == 0 || int64(v) < minIntValue {
-				minIntValue = int64(v)
-			}
-		} else {
+		switch arg.(type) {
+		case object.Integer, *object.BigInt:
+		default:
 			return nil, object.NewTypeError("min() argument %d: invalid type %s", i, arg.TypeName())
 		}
+		if i == 0 {
+			minIntValue = arg
+			continue
+		}
+		if c, err := object.Compare(arg, minIntValue); err != nil {
+			return nil, err
+		} else if c == -1 {
+			minIntValue = arg
+		}
 	}
-	return object.Integer(minIntValue), nil
+	return minIntValue, nil
 }
@@ -124,3 +124,32 @@ func captureStdio(t *testing.T, fn func()) (stdout, stderr string) {
 	_ = errW.Close()
 	return <-outCh, <-errCh
 }
+
+func TestHelp(t *testing.T) {
+	documented := &object.Function{Name: "greet", Doc: "func greet(name)\n    Greets.\n"}
+	mod := &object.Module{Name: "lib", Members: map[string]object.Object{
+		"greet": documented,
+		"count": object.Integer(3),
+	}}
+	for _, tc := range []struct {
+		value object.Object
+		want  string
+	}{
+		{documented, "func greet(name)\n    Greets.\n"},
+		{BuiltinsModule.Members["print"], "func print\n"},
+		{mod, "module lib\n\ncount: Integer\n\nfunc greet(name)\n    Greets.\n"},
+		{object.Integer(1), "Integer value\n"},
+	} {
+		if got := HelpText(tc.value); !strings.HasPrefix(got, tc.want) {
+			t.Errorf("HelpText(%v) = %q, want prefix %q", tc.value, got, tc.want)
+		}
+	}
+	stdout, _ := captureStdio(t, func() {
+		if _, err := help(object.CallArgs{Positional: []object.Object{documented}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if stdout != documented.Doc {
+		t.Fatalf("help printed %q", stdout)
+	}
+}
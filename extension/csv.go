@@ -115,14 +115,16 @@ func csvObjectRecords(value object.Object) ([][]string, error) {
 	if !ok {
 		return nil, object.NewTypeError("write_all() argument 'records' must be a list, got %s", value.TypeName())
 	}
-	records := make([][]string, len(rows.Elements))
-	for i, rowObj := range rows.Elements {
+	rowObjs := rows.Snapshot()
+	records := make([][]string, len(rowObjs))
+	for i, rowObj := range rowObjs {
 		row, ok := rowObj.(*object.List)
 		if !ok {
 			return nil, object.NewTypeError("write_all() record %d must be a list, got %s", i, rowObj.TypeName())
 		}
-		records[i] = make([]string, len(row.Elements))
-		for j, fieldObj := range row.Elements {
+		fields := row.Snapshot()
+		records[i] = make([]string, len(fields))
+		for j, fieldObj := range fields {
 			field, ok := fieldObj.(object.String)
 			if !ok {
 				return nil, object.NewTypeError("write_all() field %d in record %d must be a string, got %s", j, i, fieldObj.TypeName())
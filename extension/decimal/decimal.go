@@ -0,0 +1,139 @@
+// Package decimal provides exact base-10 arithmetic for Goblin.
+//
+// Go's standard library has no decimal type, so the module is built on
+// math/big: a Decimal is an integer coefficient scaled by a power of ten.
+// Addition, subtraction, multiplication and modulo are exact; division rounds
+// to a number of significant digits, and quantize rounds to a fixed exponent,
+// both under an explicit rounding mode.
+package decimal
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/object"
+)
+
+// DefaultPrecision is the number of significant digits the / operator keeps,
+// the same default Python's decimal module uses.
+const DefaultPrecision = 28
+
+// maxExponent bounds the exponent of every Decimal. Without a bound, aligning
+// two operands such as 1e1000000000 and 1 would have to materialise a billion
+// digits.
+const maxExponent = 999999
+
+// The rounding modes. The names follow Python's decimal module; the values are
+// what the module's ROUND_* constants hold.
+const (
+	roundHalfEven = "half_even"
+	roundHalfUp   = "half_up"
+	roundHalfDown = "half_down"
+	roundUp       = "up"
+	roundDown     = "down"
+	roundCeiling  = "ceiling"
+	roundFloor    = "floor"
+)
+
+var decimalType = object.NewNativeConstructor("Decimal", decimalConstructor)
+
+func Execute() (object.Object, error) {
+	return &object.Module{Name: "decimal", Members: map[string]object.Object{
+		"Decimal":           decimalType.Function,
+		"DEFAULT_PRECISION": object.Integer(DefaultPrecision),
+		"ROUND_HALF_EVEN":   object.String(roundHalfEven),
+		"ROUND_HALF_UP":     object.String(roundHalfUp),
+		"ROUND_HALF_DOWN":   object.String(roundHalfDown),
+		"ROUND_UP":          object.String(roundUp),
+		"ROUND_DOWN":        object.String(roundDown),
+		"ROUND_CEILING":     object.String(roundCeiling),
+		"ROUND_FLOOR":       object.String(roundFloor),
+	}}, nil
+}
+
+func decimalConstructor(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("Decimal", args)
+	value := p.AnyOr("value", object.Integer(0))
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	if text, ok := value.(object.String); ok {
+		return parse("Decimal", string(text))
+	}
+	d, ok, err := fromNumber("Decimal", value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, object.NewTypeError("Decimal() argument 'value' must be Decimal, str or a number, got %s", value.TypeName())
+	}
+	return d, nil
+}
+
+// fromNumber converts a numeric operand to a Decimal and reports false for a
+// non-numeric one. A Float converts to the shortest decimal that reads back as
+// the same float, which is the number it prints as: Decimal(0.1) is 0.1, not
+// the binary approximation 0.1000000000000000055511151231257827...
+func fromNumber(fn string, value object.Object) (*Decimal, bool, error) {
+	switch v := value.(type) {
+	case *Decimal:
+		return v, true, nil
+	case object.Integer:
+		return &Decimal{OpaqueBase: object.MakeOpaqueBase("Decimal"), coef: big.NewInt(int64(v))}, true, nil
+	case *object.BigInt:
+		return &Decimal{OpaqueBase: object.MakeOpaqueBase("Decimal"), coef: v.Int()}, true, nil
+	case object.Float:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, true, object.NewValueError("%s() cannot convert %s to Decimal", fn, v.String())
+		}
+		d, err := parse(fn, strconv.FormatFloat(f, 'e', -1, 64))
+		return d, true, err
+	}
+	return nil, false, nil
+}
+
+// parse reads a decimal literal: an optional sign, digits with an optional
+// decimal point, and an optional exponent, as in "-12.50" or "1.5e-3". The
+// digits after the point set the exponent, so "1.50" keeps its two places.
+func parse(fn, text string) (*Decimal, error) {
+	invalid := func() error {
+		return object.NewValueError("%s() invalid decimal literal: %q", fn, text)
+	}
+	s := text
+	negative := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return nil, invalid()
+		}
+		exp = e
+		s = s[:i]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	digits := whole + frac
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return nil, invalid()
+	}
+	coef, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		coef.Neg(coef)
+	}
+	return newDecimal(coef, exp-int64(len(frac)))
+}
+
+// roundingArg validates a rounding mode argument.
+func roundingArg(fn, mode string) error {
+	switch mode {
+	case roundHalfEven, roundHalfUp, roundHalfDown, roundUp, roundDown, roundCeiling, roundFloor:
+		return nil
+	}
+	return object.NewValueError("%s() argument 'rounding' must be one of the decimal.ROUND_* modes, got %q", fn, mode)
+}
@@ -0,0 +1,139 @@
+package decimal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aisk/goblin/object"
+)
+
+func mustParse(t *testing.T, text string) *Decimal {
+	t.Helper()
+	d, err := parse("Decimal", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseAndString(t *testing.T) {
+	cases := map[string]string{
+		"1.50":    "1.50",
+		"-0.001":  "-0.001",
+		".5":      "0.5",
+		"5.":      "5",
+		"1.5e3":   "1500",
+		"1.5e-3":  "0.0015",
+		"+12":     "12",
+		"0e5":     "0",
+		"-123.45": "-123.45",
+	}
+	for input, want := range cases {
+		if got := mustParse(t, input).String(); got != want {
+			t.Errorf("Decimal(%q) = %s, want %s", input, got, want)
+		}
+	}
+	for _, input := range []string{"", "-", ".", "1.2.3", "abc", "1e", "NaN", "1_000"} {
+		if _, err := parse("Decimal", input); err == nil {
+			t.Errorf("Decimal(%q) succeeded", input)
+		}
+	}
+	if _, err := parse("Decimal", "1e9999999"); err == nil {
+		t.Error("out of range exponent accepted")
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	d, _, err := fromNumber("Decimal", object.Float(0.1))
+	if err != nil || d.String() != "0.1" {
+		t.Fatalf("Decimal(0.1) = %v, %v", d, err)
+	}
+	if _, _, err := fromNumber("Decimal", object.Float(math.NaN())); err == nil {
+		t.Fatal("Decimal(NaN) succeeded")
+	}
+}
+
+func TestDivideRounding(t *testing.T) {
+	cases := []struct {
+		a, b      string
+		precision int64
+		mode      string
+		want      string
+	}{
+		{"1", "3", 28, roundHalfEven, "0.3333333333333333333333333333"},
+		{"2", "3", 5, roundHalfEven, "0.66667"},
+		{"2", "3", 5, roundDown, "0.66666"},
+		{"-2", "3", 5, roundFloor, "-0.66667"},
+		{"-2", "3", 5, roundCeiling, "-0.66666"},
+		{"1.00", "4", 28, roundHalfEven, "0.25"},
+		{"10", "4", 28, roundHalfEven, "2.5"},
+		{"999", "1", 2, roundHalfEven, "1000"},
+		{"1", "8", 2, roundHalfEven, "0.12"},
+		{"3", "8", 2, roundHalfEven, "0.38"},
+		{"1", "8", 2, roundHalfUp, "0.13"},
+	}
+	for _, c := range cases {
+		got, err := mustParse(t, c.a).div(mustParse(t, c.b), c.precision, c.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := got.(*Decimal).String(); s != c.want {
+			t.Errorf("%s / %s (precision %d, %s) = %s, want %s", c.a, c.b, c.precision, c.mode, s, c.want)
+		}
+	}
+	// 999 to two digits rounds up to 100 × 10^1, which is renormalised to
+	// 10 × 10^2 to stay within the precision.
+	if got, _ := mustParse(t, "999").div(mustParse(t, "1"), 2, roundHalfEven); got.(*Decimal).exp != 2 {
+		t.Errorf("999 / 1 (precision 2) exponent = %d, want 2", got.(*Decimal).exp)
+	}
+	if _, err := mustParse(t, "1").div(mustParse(t, "0"), 28, roundHalfEven); err == nil {
+		t.Error("division by zero succeeded")
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	cases := []struct {
+		value, exp, mode, want string
+	}{
+		{"2.675", "0.01", roundHalfEven, "2.68"},
+		{"2.665", "0.01", roundHalfEven, "2.66"},
+		{"2.665", "0.01", roundHalfUp, "2.67"},
+		{"2.665", "0.01", roundHalfDown, "2.66"},
+		{"-2.661", "0.01", roundUp, "-2.67"},
+		{"-2.669", "0.01", roundDown, "-2.66"},
+		{"1.5", "0.001", roundHalfEven, "1.500"},
+		{"1234.5", "1e2", roundHalfEven, "1200"},
+	}
+	for _, c := range cases {
+		fn, _ := mustParse(t, c.value).GetAttr("quantize")
+		got, err := fn.(*object.Function).Call(object.CallArgs{
+			Positional: object.Args{object.String(c.exp)},
+			Keyword:    object.Kwargs{"rounding": object.String(c.mode)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := got.(*Decimal).String(); s != c.want {
+			t.Errorf("Decimal(%q).quantize(%q, %s) = %s, want %s", c.value, c.exp, c.mode, s, c.want)
+		}
+	}
+}
+
+func TestMixedOperands(t *testing.T) {
+	result, handled, err := mustParse(t, "0.1").RAdd(object.Integer(1))
+	if !handled || err != nil || result.(*Decimal).String() != "1.1" {
+		t.Fatalf("RAdd = %v, %v, %v", result, handled, err)
+	}
+	if _, handled, _ := mustParse(t, "0.1").RAdd(object.String("x")); handled {
+		t.Fatal("RAdd handled a String")
+	}
+	eq, _ := mustParse(t, "1.50").Equals(object.Float(1.5))
+	if !eq {
+		t.Fatal("Decimal(1.50) != 1.5")
+	}
+	h1, _ := mustParse(t, "2.0").Hash()
+	h2, _ := object.Integer(2).Hash()
+	if h1 != h2 {
+		t.Fatal("Decimal(2.0) and 2 hash differently")
+	}
+}
@@ -0,0 +1,446 @@
+package decimal
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/object"
+)
+
+// Decimal is coef × 10^exp. The exponent records how many places a value was
+// written or computed with, so Decimal("1.50") keeps printing as 1.50 even
+// though it equals 1.5. The coefficient is never mutated after construction.
+type Decimal struct {
+	object.OpaqueBase
+	coef *big.Int
+	exp  int64
+}
+
+var _ object.Object = &Decimal{}
+var _ object.Signed = &Decimal{}
+
+var ten = big.NewInt(10)
+
+// newDecimal builds a Decimal, rejecting an exponent out of range.
+func newDecimal(coef *big.Int, exp int64) (*Decimal, error) {
+	if exp > maxExponent || exp < -maxExponent {
+		return nil, object.NewValueError("decimal exponent out of range: %d", exp)
+	}
+	return &Decimal{OpaqueBase: object.MakeOpaqueBase("Decimal"), coef: coef, exp: exp}, nil
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(n), nil)
+}
+
+// numDigits counts the decimal digits of x's magnitude; zero has one.
+func numDigits(x *big.Int) int64 {
+	return int64(len(new(big.Int).Abs(x).Text(10)))
+}
+
+// align returns both coefficients scaled to the smaller of the two exponents.
+func align(a, b *Decimal) (x, y *big.Int, exp int64) {
+	switch {
+	case a.exp == b.exp:
+		return a.coef, b.coef, a.exp
+	case a.exp > b.exp:
+		return new(big.Int).Mul(a.coef, pow10(a.exp-b.exp)), b.coef, b.exp
+	default:
+		return a.coef, new(big.Int).Mul(b.coef, pow10(b.exp-a.exp)), a.exp
+	}
+}
+
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(d.coef).Text(10)
+	var b strings.Builder
+	if d.coef.Sign() < 0 {
+		b.WriteByte('-')
+	}
+	switch {
+	case d.exp >= 0:
+		b.WriteString(digits)
+		if d.coef.Sign() != 0 {
+			b.WriteString(strings.Repeat("0", int(d.exp)))
+		}
+	default:
+		places := int(-d.exp)
+		if len(digits) <= places {
+			digits = strings.Repeat("0", places-len(digits)+1) + digits
+		}
+		b.WriteString(digits[:len(digits)-places])
+		b.WriteByte('.')
+		b.WriteString(digits[len(digits)-places:])
+	}
+	return b.String()
+}
+
+func (d *Decimal) ToString() (string, error) { return d.String(), nil }
+
+func (d *Decimal) ToBool() (bool, error) { return d.coef.Sign() != 0, nil }
+
+func (d *Decimal) Not() (object.Object, error) { return object.Bool(d.coef.Sign() == 0), nil }
+
+// MarshalJSON writes the value as a JSON number with every digit kept, which
+// is how json.marshal encodes a Decimal.
+func (d *Decimal) MarshalJSON() ([]byte, error) { return []byte(d.String()), nil }
+
+// Hash goes through float64 like the built-in numbers', so a Decimal shares a
+// dict slot with every Integer or Float it equals.
+func (d *Decimal) Hash() (uint64, error) { return object.Float(d.float64()).Hash() }
+
+func (d *Decimal) float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d *Decimal) cmp(other *Decimal) int {
+	x, y, _ := align(d, other)
+	return x.Cmp(y)
+}
+
+// Equals compares by value across exponents and numeric types: Decimal("1.50")
+// equals Decimal("1.5") and 1.5.
+func (d *Decimal) Equals(other object.Object) (bool, error) {
+	rhs, ok, err := fromNumber("==", other)
+	if !ok || err != nil {
+		return false, nil
+	}
+	return d.cmp(rhs) == 0, nil
+}
+
+func (d *Decimal) Compare(other object.Object) (int, error) {
+	rhs, ok, err := fromNumber("compare", other)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, object.NewTypeError("cannot compare Decimal and %s", other.TypeName())
+	}
+	return d.cmp(rhs), nil
+}
+
+func (d *Decimal) add(other *Decimal) (object.Object, error) {
+	x, y, exp := align(d, other)
+	return newDecimal(new(big.Int).Add(x, y), exp)
+}
+
+func (d *Decimal) sub(other *Decimal) (object.Object, error) {
+	x, y, exp := align(d, other)
+	return newDecimal(new(big.Int).Sub(x, y), exp)
+}
+
+func (d *Decimal) mul(other *Decimal) (object.Object, error) {
+	return newDecimal(new(big.Int).Mul(d.coef, other.coef), d.exp+other.exp)
+}
+
+// mod is the remainder of truncating division, so like Integer's % it takes
+// the sign of the dividend. It is exact.
+func (d *Decimal) mod(other *Decimal) (object.Object, error) {
+	if other.coef.Sign() == 0 {
+		return nil, object.NewZeroDivisionError("modulo by zero")
+	}
+	x, y, exp := align(d, other)
+	return newDecimal(new(big.Int).Rem(x, y), exp)
+}
+
+// div divides to precision significant digits. An exact quotient keeps the
+// exponent the operands imply (Decimal("1.00") / 4 is 0.25), as long as that
+// fits the precision.
+func (d *Decimal) div(other *Decimal, precision int64, mode string) (object.Object, error) {
+	if other.coef.Sign() == 0 {
+		return nil, object.NewZeroDivisionError("division by zero")
+	}
+	ideal := d.exp - other.exp
+	if d.coef.Sign() == 0 {
+		return newDecimal(new(big.Int), ideal)
+	}
+	// Scale the dividend so the integer quotient has more digits than the
+	// precision; the remainder then only decides the rounding.
+	shift := precision + 1 + numDigits(other.coef) - numDigits(d.coef)
+	if shift < 0 {
+		shift = 0
+	}
+	q, r := new(big.Int).QuoRem(new(big.Int).Mul(d.coef, pow10(shift)), other.coef, new(big.Int))
+	exp := ideal - shift
+	if r.Sign() == 0 {
+		for exp < ideal {
+			next, digit := new(big.Int).QuoRem(q, ten, new(big.Int))
+			if digit.Sign() != 0 {
+				break
+			}
+			q, exp = next, exp+1
+		}
+	}
+	coef, exp := roundSignificant(q, exp, precision, mode, r.Sign() != 0)
+	return newDecimal(coef, exp)
+}
+
+// roundSignificant rounds coef × 10^exp to at most precision significant
+// digits. sticky reports that the true value has nonzero digits below coef,
+// as an inexact quotient does.
+func roundSignificant(coef *big.Int, exp, precision int64, mode string, sticky bool) (*big.Int, int64) {
+	drop := numDigits(coef) - precision
+	if drop <= 0 {
+		return coef, exp
+	}
+	coef = roundDigits(coef, drop, mode, sticky)
+	exp += drop
+	// Rounding 999... up gains a digit; the one dropped to restore the
+	// precision is a zero.
+	if numDigits(coef) > precision {
+		coef = new(big.Int).Quo(coef, ten)
+		exp++
+	}
+	return coef, exp
+}
+
+// roundDigits removes the drop lowest digits of coef, rounding the rest in
+// the given mode. The rounding applies to the magnitude, with the sign
+// deciding only between ceiling and floor.
+func roundDigits(coef *big.Int, drop int64, mode string, sticky bool) *big.Int {
+	divisor := pow10(drop)
+	negative := coef.Sign() < 0
+	q, rem := new(big.Int).QuoRem(new(big.Int).Abs(coef), divisor, new(big.Int))
+	if roundAway(q, rem, divisor, mode, negative, sticky) {
+		q.Add(q, big.NewInt(1))
+	}
+	if negative {
+		q.Neg(q)
+	}
+	return q
+}
+
+// roundAway reports whether a magnitude truncated to q, with rem out of
+// divisor discarded, has to be rounded away from zero.
+func roundAway(q, rem, divisor *big.Int, mode string, negative, sticky bool) bool {
+	inexact := rem.Sign() != 0 || sticky
+	if !inexact {
+		return false
+	}
+	half := new(big.Int).Lsh(rem, 1).Cmp(divisor)
+	if half == 0 && sticky {
+		half = 1
+	}
+	switch mode {
+	case roundUp:
+		return true
+	case roundDown:
+		return false
+	case roundCeiling:
+		return !negative
+	case roundFloor:
+		return negative
+	case roundHalfUp:
+		return half >= 0
+	case roundHalfDown:
+		return half > 0
+	default: // roundHalfEven
+		return half > 0 || (half == 0 && q.Bit(0) == 1)
+	}
+}
+
+func (d *Decimal) Add(other object.Object) (object.Object, error) {
+	rhs, ok, err := fromNumber("+", other)
+	if !ok {
+		return nil, object.NewTypeError("cannot add Decimal and %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.add(rhs)
+}
+
+func (d *Decimal) Minus(other object.Object) (object.Object, error) {
+	rhs, ok, err := fromNumber("-", other)
+	if !ok {
+		return nil, object.NewTypeError("cannot subtract Decimal and %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.sub(rhs)
+}
+
+func (d *Decimal) Multiply(other object.Object) (object.Object, error) {
+	rhs, ok, err := fromNumber("*", other)
+	if !ok {
+		return nil, object.NewTypeError("cannot multiply Decimal and %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.mul(rhs)
+}
+
+func (d *Decimal) Divide(other object.Object) (object.Object, error) {
+	rhs, ok, err := fromNumber("/", other)
+	if !ok {
+		return nil, object.NewTypeError("cannot divide Decimal and %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.div(rhs, DefaultPrecision, roundHalfEven)
+}
+
+func (d *Decimal) Modulo(other object.Object) (object.Object, error) {
+	rhs, ok, err := fromNumber("%", other)
+	if !ok {
+		return nil, object.NewTypeError("cannot modulo Decimal and %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.mod(rhs)
+}
+
+// The reflected operators let a number on the left combine with a Decimal on
+// the right: `1 + Decimal("0.1")` converts the 1 and adds.
+
+func (d *Decimal) reflected(left object.Object, op func(lhs *Decimal) (object.Object, error)) (object.Object, bool, error) {
+	lhs, ok, err := fromNumber("operator", left)
+	if !ok {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	result, err := op(lhs)
+	return result, true, err
+}
+
+func (d *Decimal) RAdd(left object.Object) (object.Object, bool, error) {
+	return d.reflected(left, func(lhs *Decimal) (object.Object, error) { return lhs.add(d) })
+}
+
+func (d *Decimal) RMinus(left object.Object) (object.Object, bool, error) {
+	return d.reflected(left, func(lhs *Decimal) (object.Object, error) { return lhs.sub(d) })
+}
+
+func (d *Decimal) RMultiply(left object.Object) (object.Object, bool, error) {
+	return d.reflected(left, func(lhs *Decimal) (object.Object, error) { return lhs.mul(d) })
+}
+
+func (d *Decimal) RDivide(left object.Object) (object.Object, bool, error) {
+	return d.reflected(left, func(lhs *Decimal) (object.Object, error) {
+		return lhs.div(d, DefaultPrecision, roundHalfEven)
+	})
+}
+
+func (d *Decimal) RModulo(left object.Object) (object.Object, bool, error) {
+	return d.reflected(left, func(lhs *Decimal) (object.Object, error) { return lhs.mod(d) })
+}
+
+func (d *Decimal) Negate() (object.Object, error) {
+	return newDecimal(new(big.Int).Neg(d.coef), d.exp)
+}
+
+func (d *Decimal) GetAttr(name string) (object.Object, error) {
+	if value, ok := decimalType.Attribute(name); ok {
+		return value, nil
+	}
+	switch name {
+	case "attributes":
+		return object.AttributesFunction(d), nil
+	case "exponent":
+		return object.Integer(d.exp), nil
+	case "quantize":
+		return &object.Function{Name: "quantize", Fn: d.quantize}, nil
+	case "div":
+		return &object.Function{Name: "div", Fn: d.divMethod}, nil
+	case "abs":
+		return &object.Function{Name: "abs", Fn: d.abs}, nil
+	case "to_int":
+		return &object.Function{Name: "to_int", Fn: d.toInt}, nil
+	case "to_float":
+		return &object.Function{Name: "to_float", Fn: d.toFloat}, nil
+	default:
+		return nil, object.NewAttributeError("Decimal has no attribute '%s'", name)
+	}
+}
+
+func (d *Decimal) Attributes() []string {
+	return decimalType.Attributes("attributes", "exponent", "quantize", "div", "abs", "to_int", "to_float")
+}
+
+// quantize rounds to the exponent of exp, which is a Decimal or a decimal
+// literal: d.quantize("0.01") rounds to cents. Gaining places is exact.
+func (d *Decimal) quantize(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("quantize", args)
+	expArg := p.Any("exp")
+	mode := p.StrOr("rounding", roundHalfEven)
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	if err := roundingArg("quantize", string(mode)); err != nil {
+		return nil, err
+	}
+	var target *Decimal
+	switch v := expArg.(type) {
+	case *Decimal:
+		target = v
+	case object.String:
+		parsed, err := parse("quantize", string(v))
+		if err != nil {
+			return nil, err
+		}
+		target = parsed
+	default:
+		return nil, object.NewTypeError("quantize() argument 'exp' must be Decimal or str, got %s", expArg.TypeName())
+	}
+	if target.exp <= d.exp {
+		return newDecimal(new(big.Int).Mul(d.coef, pow10(d.exp-target.exp)), target.exp)
+	}
+	return newDecimal(roundDigits(d.coef, target.exp-d.exp, string(mode), false), target.exp)
+}
+
+// divMethod is / with an explicit precision and rounding mode.
+func (d *Decimal) divMethod(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("div", args)
+	other := p.Any("other")
+	precision := p.IntOr("precision", DefaultPrecision)
+	mode := p.StrOr("rounding", roundHalfEven)
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	if precision < 1 {
+		return nil, object.NewValueError("div() argument 'precision' must be positive, got %d", int64(precision))
+	}
+	if err := roundingArg("div", string(mode)); err != nil {
+		return nil, err
+	}
+	rhs, ok, err := fromNumber("div", other)
+	if !ok {
+		return nil, object.NewTypeError("div() argument 'other' must be Decimal or a number, got %s", other.TypeName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.div(rhs, int64(precision), string(mode))
+}
+
+func (d *Decimal) abs(args object.CallArgs) (object.Object, error) {
+	if err := object.RequireNoArgs("abs", args); err != nil {
+		return nil, err
+	}
+	return newDecimal(new(big.Int).Abs(d.coef), d.exp)
+}
+
+// toInt truncates towards zero, like Int() does for a Float.
+func (d *Decimal) toInt(args object.CallArgs) (object.Object, error) {
+	if err := object.RequireNoArgs("to_int", args); err != nil {
+		return nil, err
+	}
+	if d.exp >= 0 {
+		return object.NewInt(new(big.Int).Mul(d.coef, pow10(d.exp))), nil
+	}
+	return object.NewInt(new(big.Int).Quo(d.coef, pow10(-d.exp))), nil
+}
+
+func (d *Decimal) toFloat(args object.CallArgs) (object.Object, error) {
+	if err := object.RequireNoArgs("to_float", args); err != nil {
+		return nil, err
+	}
+	return object.Float(d.float64()), nil
+}
@@ -0,0 +1,318 @@
+// Package embed implements the embed module, which gives a program the data
+// files it ships with: templates, configuration, static assets. Under `goblin
+// run` the files are read from disk, next to the source file; `goblin
+// build-exe` compiles them into the executable with Go's //go:embed, so the
+// program no longer needs them at run time. Either way the program sees the
+// same files through the same API.
+package embed
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aisk/goblin/object"
+)
+
+// Executor returns the executor of the embed module for the Goblin modules in
+// directory dir of fsys, against which their patterns are matched. The
+// interpreter passes the source directory on disk and ".", a built program
+// the directory of its embedded files holding the files of that source
+// directory.
+func Executor(fsys fs.FS, dir string) object.ModuleExecutor {
+	return func() (object.Object, error) {
+		if dir != "." {
+			sub, err := fs.Sub(fsys, dir)
+			if err != nil {
+				return nil, err
+			}
+			fsys = sub
+		}
+		files := &object.Function{Name: "files", Fn: func(args object.CallArgs) (object.Object, error) {
+			return filesFn(fsys, args)
+		}}
+		return &object.Module{Name: "embed", Members: map[string]object.Object{
+			"files": files,
+		}}, nil
+	}
+}
+
+// Rooted returns the files under directory dir on disk, as os.DirFS does,
+// except that a name whose symbolic links lead outside dir cannot be opened.
+// A sandboxed program reads its files through it.
+func Rooted(dir string) (fs.FS, error) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		real, err = filepath.Abs(real)
+	}
+	if err != nil {
+		return nil, object.WrapNativeError(object.IOError, "embed directory is not accessible", err)
+	}
+	return rootedFS(real), nil
+}
+
+// rootedFS is the directory Rooted confines names to.
+type rootedFS string
+
+func (root rootedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(string(root), filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	if real != string(root) && !strings.HasPrefix(real, string(root)+string(filepath.Separator)) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return os.Open(real)
+}
+
+func filesFn(fsys fs.FS, args object.CallArgs) (object.Object, error) {
+	ap := object.NewArgParser("files", args)
+	rest := ap.Rest()
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	if len(rest) == 0 {
+		return nil, object.NewTypeError("files() takes at least one pattern")
+	}
+	patterns := make([]string, len(rest))
+	for i, arg := range rest {
+		s, ok := arg.(object.String)
+		if !ok {
+			return nil, object.NewTypeError("files() argument 'patterns' must be str, got %s", arg.TypeName())
+		}
+		patterns[i] = string(s)
+	}
+	names, err := Expand(fsys, patterns)
+	if err != nil {
+		return nil, err
+	}
+	return newFiles(fsys, names), nil
+}
+
+// Expand returns the files of fsys that patterns select, sorted. A pattern is
+// a slash-separated path.Match pattern relative to the root of fsys; a
+// directory it matches selects every file below it. It is an error for a
+// pattern to leave the root or to select no file, so a typo fails where the
+// pattern is written rather than where a missing file is read. The
+// transpiler uses it to find the files a program must be built with.
+func Expand(fsys fs.FS, patterns []string) ([]string, error) {
+	selected := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if !fs.ValidPath(pattern) || pattern == "." {
+			return nil, object.NewValueError("files() invalid pattern %q: patterns are relative to the source file's directory and may not contain '.' or '..' elements", pattern)
+		}
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, object.WrapError(object.ParseError, "files() invalid pattern "+pattern, err)
+		}
+		found := false
+		for _, match := range matches {
+			err := fs.WalkDir(fsys, match, func(name string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					selected[name] = struct{}{}
+					found = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, object.WrapNativeError(object.IOError, "files() failed to list "+match, err)
+			}
+		}
+		if !found {
+			return nil, object.WrapError(object.NotExistError, "files() pattern "+pattern+" matches no files", fs.ErrNotExist)
+		}
+	}
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Files is the set of files a call of embed.files selected. Names are
+// slash-separated paths relative to the source file's directory, whatever the
+// operating system, as they are inside an executable.
+type Files struct {
+	object.OpaqueBase
+	fsys  fs.FS
+	names []string
+	files map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+func newFiles(fsys fs.FS, names []string) *Files {
+	f := &Files{
+		OpaqueBase: object.MakeOpaqueBase("Files"),
+		fsys:       fsys,
+		names:      names,
+		files:      make(map[string]struct{}, len(names)),
+		dirs:       map[string]struct{}{".": {}},
+	}
+	for _, name := range names {
+		f.files[name] = struct{}{}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			f.dirs[dir] = struct{}{}
+		}
+	}
+	return f
+}
+
+var _ object.Object = (*Files)(nil)
+
+func (f *Files) String() string {
+	return "<files " + strings.Join(f.names, " ") + ">"
+}
+
+func (f *Files) ToString() (string, error) { return f.String(), nil }
+
+// Iter yields the names of the files, so `for name in files` visits them all.
+func (f *Files) Iter() ([]object.Object, error) {
+	return stringList(f.names), nil
+}
+
+func (f *Files) GetAttr(name string) (object.Object, error) {
+	switch name {
+	case "attributes":
+		return object.AttributesFunction(f), nil
+	case "names":
+		return &object.List{Elements: stringList(f.names)}, nil
+	case "exists":
+		return &object.Function{Name: "exists", Fn: f.Exists}, nil
+	case "read_text":
+		return &object.Function{Name: "read_text", Fn: f.ReadText}, nil
+	case "read_bytes":
+		return &object.Function{Name: "read_bytes", Fn: f.ReadBytes}, nil
+	case "glob":
+		return &object.Function{Name: "glob", Fn: f.Glob}, nil
+	case "iterdir":
+		return &object.Function{Name: "iterdir", Fn: f.IterDir}, nil
+	}
+	return nil, object.NewAttributeError("Files has no attribute '%s'", name)
+}
+
+func (f *Files) Attributes() []string {
+	return []string{"attributes", "names", "exists", "read_text", "read_bytes", "glob", "iterdir"}
+}
+
+func stringList(names []string) []object.Object {
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = object.String(name)
+	}
+	return elements
+}
+
+// Exists reports whether name is one of the files, or a directory holding
+// some of them.
+func (f *Files) Exists(args object.CallArgs) (object.Object, error) {
+	ap := object.NewArgParser("exists", args)
+	name := ap.Str("name")
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	clean := path.Clean(string(name))
+	_, isFile := f.files[clean]
+	_, isDir := f.dirs[clean]
+	return object.Bool(isFile || isDir), nil
+}
+
+// read returns the content of the file called name. Only the selected files
+// can be read, even when others sit next to them on disk, since a built
+// program would not have those.
+func (f *Files) read(fnName string, args object.CallArgs) ([]byte, error) {
+	ap := object.NewArgParser(fnName, args)
+	name := ap.Str("name")
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	clean := path.Clean(string(name))
+	if _, ok := f.files[clean]; !ok {
+		err := &fs.PathError{Op: "open", Path: string(name), Err: fs.ErrNotExist}
+		return nil, object.WrapNativeError(object.IOError, fnName+"() "+string(name)+" is not one of the embedded files", err)
+	}
+	data, err := fs.ReadFile(f.fsys, clean)
+	if err != nil {
+		return nil, object.WrapNativeError(object.IOError, fnName+"() failed to read file", err)
+	}
+	return data, nil
+}
+
+func (f *Files) ReadText(args object.CallArgs) (object.Object, error) {
+	data, err := f.read("read_text", args)
+	if err != nil {
+		return nil, err
+	}
+	return object.String(data), nil
+}
+
+func (f *Files) ReadBytes(args object.CallArgs) (object.Object, error) {
+	data, err := f.read("read_bytes", args)
+	if err != nil {
+		return nil, err
+	}
+	return object.NewBytes(data), nil
+}
+
+// Glob returns the names of the files matching a path.Match pattern, sorted.
+func (f *Files) Glob(args object.CallArgs) (object.Object, error) {
+	ap := object.NewArgParser("glob", args)
+	pattern := ap.Str("pattern")
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(string(pattern), ""); err != nil {
+		return nil, object.WrapError(object.ParseError, "glob() invalid pattern", err)
+	}
+	var matches []string
+	for _, name := range f.names {
+		if ok, _ := path.Match(string(pattern), name); ok {
+			matches = append(matches, name)
+		}
+	}
+	return &object.List{Elements: stringList(matches)}, nil
+}
+
+// IterDir returns the names of the files and directories directly inside
+// dir, sorted.
+func (f *Files) IterDir(args object.CallArgs) (object.Object, error) {
+	ap := object.NewArgParser("iterdir", args)
+	dir := path.Clean(string(ap.StrOr("dir", ".")))
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	if _, ok := f.dirs[dir]; !ok {
+		err := &fs.PathError{Op: "open", Path: dir, Err: fs.ErrNotExist}
+		return nil, object.WrapNativeError(object.IOError, "iterdir() "+dir+" is not a directory of the embedded files", err)
+	}
+	seen := make(map[string]struct{})
+	var entries []string
+	for _, name := range f.names {
+		rel := name
+		if dir != "." {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		entry := name
+		if i := strings.IndexByte(rel, '/'); i >= 0 {
+			entry = strings.TrimSuffix(name, rel[i:])
+		}
+		if _, ok := seen[entry]; !ok {
+			seen[entry] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+	return &object.List{Elements: stringList(entries)}, nil
+}
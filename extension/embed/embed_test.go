@@ -0,0 +1,142 @@
+package embed
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aisk/goblin/object"
+)
+
+var testFS = fstest.MapFS{
+	"templates/index.html":    {Data: []byte("<h1>index</h1>")},
+	"templates/about.html":    {Data: []byte("<h1>about</h1>")},
+	"templates/partials/x.md": {Data: []byte("x")},
+	"config.json":             {Data: []byte("{}")},
+	"secret.txt":              {Data: []byte("secret")},
+}
+
+func call(t *testing.T, obj object.Object, name string, args ...object.Object) (object.Object, error) {
+	t.Helper()
+	fn, err := obj.GetAttr(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fn.(*object.Function).Fn(object.CallArgs{Positional: args})
+}
+
+func listStrings(t *testing.T, obj object.Object) []string {
+	t.Helper()
+	var result []string
+	for _, e := range obj.(*object.List).Elements {
+		result = append(result, string(e.(object.String)))
+	}
+	return result
+}
+
+func TestExpand(t *testing.T) {
+	names, err := Expand(testFS, []string{"templates", "*.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"config.json", "templates/about.html", "templates/index.html", "templates/partials/x.md"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expand = %v, want %v", names, want)
+	}
+
+	for _, pattern := range []string{"../x", "/etc/passwd", ".", "a/./b", "[", "missing/*"} {
+		if _, err := Expand(testFS, []string{pattern}); err == nil {
+			t.Errorf("Expand(%q) succeeded", pattern)
+		}
+	}
+	_, err = Expand(testFS, []string{"missing/*"})
+	if !errors.Is(err, object.NotExistError) {
+		t.Errorf("Expand(missing/*) = %v, want NotExistError", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	module, err := Executor(fstest.MapFS{"site/templates/index.html": testFS["templates/index.html"], "site/secret.txt": testFS["secret.txt"]}, "site")()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := call(t, module, "files", object.String("templates/*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := call(t, files, "read_text", object.String("templates/index.html"))
+	if err != nil || got != object.String("<h1>index</h1>") {
+		t.Fatalf("read_text = %v, %v", got, err)
+	}
+	// Files next to the selected ones are not readable, as a built program
+	// would not have them.
+	if _, err := call(t, files, "read_bytes", object.String("secret.txt")); !errors.Is(err, object.NotExistError) {
+		t.Fatalf("read_bytes(secret.txt) = %v, want NotExistError", err)
+	}
+
+	files = newFiles(testFS, []string{"config.json", "templates/about.html", "templates/index.html", "templates/partials/x.md"})
+	tests := []struct {
+		method string
+		args   []object.Object
+		want   []string
+	}{
+		{"glob", []object.Object{object.String("templates/*.html")}, []string{"templates/about.html", "templates/index.html"}},
+		{"glob", []object.Object{object.String("*")}, []string{"config.json"}},
+		{"iterdir", nil, []string{"config.json", "templates"}},
+		{"iterdir", []object.Object{object.String("templates")}, []string{"templates/about.html", "templates/index.html", "templates/partials"}},
+	}
+	for _, tt := range tests {
+		got, err := call(t, files, tt.method, tt.args...)
+		if err != nil {
+			t.Fatalf("%s(%v): %v", tt.method, tt.args, err)
+		}
+		if !reflect.DeepEqual(listStrings(t, got), tt.want) {
+			t.Errorf("%s(%v) = %v, want %v", tt.method, tt.args, listStrings(t, got), tt.want)
+		}
+	}
+	for name, want := range map[string]bool{"templates": true, "templates/partials/x.md": true, "secret.txt": false, "templ": false} {
+		if got, _ := call(t, files, "exists", object.String(name)); got != object.Bool(want) {
+			t.Errorf("exists(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if _, err := call(t, files, "iterdir", object.String("missing")); !errors.Is(err, object.NotExistError) {
+		t.Errorf("iterdir(missing) = %v, want NotExistError", err)
+	}
+}
+
+func TestRooted(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "data.txt"), filepath.Join(dir, "alias.txt")); err != nil {
+		t.Skip("symbolic links are not available:", err)
+	}
+	for link, target := range map[string]string{"dir": outside, "file.txt": filepath.Join(outside, "secret.txt")} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys, err := Rooted(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"data.txt", "alias.txt"} {
+		if data, err := fs.ReadFile(fsys, name); err != nil || string(data) != "inside" {
+			t.Errorf("ReadFile(%s) = %q, %v", name, data, err)
+		}
+	}
+	for _, name := range []string{"dir", "dir/secret.txt", "file.txt"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Open(%s) = %v, want ErrPermission", name, err)
+		}
+	}
+}
@@ -34,15 +34,41 @@ var (
 )
 
 func Execute() (object.Object, error) {
+	return module(commandType.Function), nil
+}
+
+// ExecuteWithStreams is like Execute, but INHERIT connects a command to
+// stdin, stdout and stderr rather than the process's streams, for an
+// interpreter that runs a program with streams of its own. A nil stream is
+// the process's.
+func ExecuteWithStreams(stdin io.Reader, stdout, stderr io.Writer) (object.Object, error) {
+	s := streams{stdin: stdin, stdout: stdout, stderr: stderr}
+	return module(&object.Function{Name: "Command", Fn: func(args object.CallArgs) (object.Object, error) {
+		return newCommand(s, args)
+	}}), nil
+}
+
+func module(command *object.Function) *object.Module {
 	return &object.Module{Name: "exec", Members: map[string]object.Object{
-		"Command": commandType.Function,
+		"Command": command,
 		"INHERIT": inherit,
 		"DISCARD": discard,
 		"CAPTURE": capture,
-	}}, nil
+	}}
+}
+
+// streams are what INHERIT connects a command to; nil fields are the
+// process's streams, read when the command is created.
+type streams struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
 }
 
 func command(args object.CallArgs) (object.Object, error) {
+	return newCommand(streams{}, args)
+}
+
+func newCommand(s streams, args object.CallArgs) (object.Object, error) {
 	ap := object.NewArgParser("Command", args)
 	name := ap.Str("name")
 	argsObj := ap.AnyOr("args", &object.List{})
@@ -59,8 +85,9 @@ func command(args object.CallArgs) (object.Object, error) {
 	if !ok {
 		return nil, object.NewTypeError("Command() argument 'args' must be a list, got %s", argsObj.TypeName())
 	}
-	argv := make([]string, len(list.Elements))
-	for i, arg := range list.Elements {
+	items := list.Snapshot()
+	argv := make([]string, len(items))
+	for i, arg := range items {
 		s, ok := arg.(object.String)
 		if !ok {
 			return nil, object.NewTypeError("Command() argument 'args' must contain only strings, got %s at index %d", arg.TypeName(), i)
@@ -93,24 +120,27 @@ func command(args object.CallArgs) (object.Object, error) {
 	}
 
 	c := &Cmd{OpaqueBase: object.MakeOpaqueBase("Command"), cmd: cmd, state: stateCreated}
-	if err := c.configureStdin(stdinObj); err != nil {
+	if err := c.configureStdin(stdinObj, s.stdin); err != nil {
 		return nil, err
 	}
-	if err := c.configureOutput("stdout", stdoutObj); err != nil {
+	if err := c.configureOutput("stdout", stdoutObj, s.stdout); err != nil {
 		return nil, err
 	}
-	if err := c.configureOutput("stderr", stderrObj); err != nil {
+	if err := c.configureOutput("stderr", stderrObj, s.stderr); err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
-func (c *Cmd) configureStdin(value object.Object) error {
+func (c *Cmd) configureStdin(value object.Object, stdin io.Reader) error {
 	switch v := value.(type) {
 	case *streamPolicy:
 		switch v {
 		case inherit:
-			c.cmd.Stdin = os.Stdin
+			if stdin == nil {
+				stdin = os.Stdin
+			}
+			c.cmd.Stdin = stdin
 		case discard:
 			c.cmd.Stdin = nil
 		default:
@@ -126,7 +156,7 @@ func (c *Cmd) configureStdin(value object.Object) error {
 	return nil
 }
 
-func (c *Cmd) configureOutput(name string, value object.Object) error {
+func (c *Cmd) configureOutput(name string, value object.Object, inherited io.Writer) error {
 	policy, ok := value.(*streamPolicy)
 	if !ok {
 		// Any object with a write(data) method (e.g. an fs.File) receives the
@@ -145,9 +175,12 @@ func (c *Cmd) configureOutput(name string, value object.Object) error {
 	var writer io.Writer
 	switch policy {
 	case inherit:
-		if name == "stdout" {
+		switch {
+		case inherited != nil:
+			writer = inherited
+		case name == "stdout":
 			writer = os.Stdout
-		} else {
+		default:
 			writer = os.Stderr
 		}
 	case discard:
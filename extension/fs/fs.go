@@ -1,46 +1,108 @@
 package fs
 
 import (
+	"errors"
 	"io"
+	iofs "io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	pathext "github.com/aisk/goblin/extension/path"
 	"github.com/aisk/goblin/object"
 )
 
 func Execute() (object.Object, error) {
+	return filesystem{}.module(), nil
+}
+
+// ExecuteRooted is like Execute, but confines the module to the directory
+// root, for a sandboxed program: paths are resolved against root as if it
+// were "/", and a path that leads outside it, through ".." or a symbolic
+// link, raises PermissionError. root must exist.
+func ExecuteRooted(root string) (object.Object, error) {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, object.WrapNativeError(object.IOError, "fs root is not accessible", err)
+	}
+	real, err = filepath.Abs(real)
+	if err != nil {
+		return nil, object.WrapNativeError(object.IOError, "fs root is not accessible", err)
+	}
+	return filesystem{root: real}.module(), nil
+}
+
+// filesystem is what the module's functions operate on: the host's file
+// system, or the tree under root when root is set.
+type filesystem struct {
+	root string
+}
+
+func (fsys filesystem) module() *object.Module {
 	return &object.Module{
 		Name: "fs",
 		Members: map[string]object.Object{
-			"open":     &object.Function{Name: "open", Fn: openFile},
-			"create":   &object.Function{Name: "create", Fn: createFile},
-			"read":     &object.Function{Name: "read", Fn: readFile},
-			"write":    &object.Function{Name: "write", Fn: writeFile},
-			"append":   &object.Function{Name: "append", Fn: appendFile},
-			"exists":   &object.Function{Name: "exists", Fn: exists},
-			"stat":     &object.Function{Name: "stat", Fn: stat},
-			"read_dir": &object.Function{Name: "read_dir", Fn: readDir},
-			"mkdir":    &object.Function{Name: "mkdir", Fn: mkdir},
-			"remove":   &object.Function{Name: "remove", Fn: remove},
+			"open":     &object.Function{Name: "open", Fn: fsys.openFile},
+			"create":   &object.Function{Name: "create", Fn: fsys.createFile},
+			"read":     &object.Function{Name: "read", Fn: fsys.readFile},
+			"write":    &object.Function{Name: "write", Fn: fsys.writeFile},
+			"append":   &object.Function{Name: "append", Fn: fsys.appendFile},
+			"exists":   &object.Function{Name: "exists", Fn: fsys.exists},
+			"stat":     &object.Function{Name: "stat", Fn: fsys.stat},
+			"read_dir": &object.Function{Name: "read_dir", Fn: fsys.readDir},
+			"mkdir":    &object.Function{Name: "mkdir", Fn: fsys.mkdir},
+			"remove":   &object.Function{Name: "remove", Fn: fsys.remove},
 		},
-	}, nil
+	}
 }
 
-func bindPathArg(funcName string, args object.CallArgs) (string, error) {
+// resolve returns the host path of name. Under a root, symbolic links are
+// followed as far as the path exists, so that the result is checked against
+// where it really leads. Creating a file or directory still follows the
+// link a last, missing element could become between the check and the
+// call; the root is a guard against programs, not against concurrent
+// changes to the tree.
+func (fsys filesystem) resolve(funcName, name string) (string, error) {
+	if fsys.root == "" {
+		return name, nil
+	}
+	full := filepath.Join(fsys.root, filepath.Clean(string(filepath.Separator)+name))
+	existing, rest := full, ""
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			full = filepath.Join(real, rest)
+			break
+		}
+		if !errors.Is(err, iofs.ErrNotExist) || existing == fsys.root {
+			return "", object.WrapNativeError(object.IOError, funcName+"() failed to resolve path", err)
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+	if full != fsys.root && !strings.HasPrefix(full, fsys.root+string(filepath.Separator)) {
+		return "", object.WrapNativeError(object.IOError, funcName+"() path is outside the root", &iofs.PathError{Op: funcName, Path: name, Err: iofs.ErrPermission})
+	}
+	return full, nil
+}
+
+// bindPathArg returns the path argument of a call, as given and resolved.
+func (fsys filesystem) bindPathArg(funcName string, args object.CallArgs) (string, string, error) {
 	ap := object.NewArgParser(funcName, args)
 	pathArg := ap.Any("path")
 	if err := ap.Finish(); err != nil {
-		return "", err
+		return "", "", err
 	}
-	path, ok := pathext.PathString(pathArg)
+	name, ok := pathext.PathString(pathArg)
 	if !ok {
-		return "", object.NewTypeError("%s() argument 'path' must be a string or Path, got %s", funcName, pathArg.TypeName())
+		return "", "", object.NewTypeError("%s() argument 'path' must be a string or Path, got %s", funcName, pathArg.TypeName())
 	}
-	return path, nil
+	path, err := fsys.resolve(funcName, name)
+	return name, path, err
 }
 
-func openFile(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("open", args)
+func (fsys filesystem) openFile(args object.CallArgs) (object.Object, error) {
+	name, path, err := fsys.bindPathArg("open", args)
 	if err != nil {
 		return nil, err
 	}
@@ -50,11 +112,11 @@ func openFile(args object.CallArgs) (object.Object, error) {
 		return nil, object.WrapNativeError(object.IOError, "open() failed to open file", err)
 	}
 
-	return NewFile(path, file), nil
+	return NewFile(name, file), nil
 }
 
-func createFile(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("create", args)
+func (fsys filesystem) createFile(args object.CallArgs) (object.Object, error) {
+	name, path, err := fsys.bindPathArg("create", args)
 	if err != nil {
 		return nil, err
 	}
@@ -63,11 +125,11 @@ func createFile(args object.CallArgs) (object.Object, error) {
 	if err != nil {
 		return nil, object.WrapNativeError(object.IOError, "create() failed to create file", err)
 	}
-	return NewFile(path, file), nil
+	return NewFile(name, file), nil
 }
 
-func readFile(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("read", args)
+func (fsys filesystem) readFile(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("read", args)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +147,7 @@ func readFile(args object.CallArgs) (object.Object, error) {
 	return object.String(data), nil
 }
 
-func bindPathContentArgs(funcName string, args object.CallArgs) (string, string, error) {
+func (fsys filesystem) bindPathContentArgs(funcName string, args object.CallArgs) (string, string, error) {
 	ap := object.NewArgParser(funcName, args)
 	pathArg := ap.Any("path")
 	contentArg := ap.Any("content")
@@ -100,11 +162,12 @@ func bindPathContentArgs(funcName string, args object.CallArgs) (string, string,
 	if !ok {
 		return "", "", object.NewTypeError("%s() argument 'content' must be a string, got %s", funcName, contentArg.TypeName())
 	}
-	return path, string(content), nil
+	path, err := fsys.resolve(funcName, path)
+	return path, string(content), err
 }
 
-func writeFile(args object.CallArgs) (object.Object, error) {
-	path, content, err := bindPathContentArgs("write", args)
+func (fsys filesystem) writeFile(args object.CallArgs) (object.Object, error) {
+	path, content, err := fsys.bindPathContentArgs("write", args)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +178,8 @@ func writeFile(args object.CallArgs) (object.Object, error) {
 	return object.Integer(len(content)), nil
 }
 
-func appendFile(args object.CallArgs) (object.Object, error) {
-	path, content, err := bindPathContentArgs("append", args)
+func (fsys filesystem) appendFile(args object.CallArgs) (object.Object, error) {
+	path, content, err := fsys.bindPathContentArgs("append", args)
 	if err != nil {
 		return nil, err
 	}
@@ -134,8 +197,8 @@ func appendFile(args object.CallArgs) (object.Object, error) {
 	return object.Integer(n), nil
 }
 
-func exists(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("exists", args)
+func (fsys filesystem) exists(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("exists", args)
 	if err != nil {
 		return nil, err
 	}
@@ -150,8 +213,8 @@ func exists(args object.CallArgs) (object.Object, error) {
 	return nil, object.WrapNativeError(object.IOError, "exists() failed to stat path", err)
 }
 
-func stat(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("stat", args)
+func (fsys filesystem) stat(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("stat", args)
 	if err != nil {
 		return nil, err
 	}
@@ -163,8 +226,8 @@ func stat(args object.CallArgs) (object.Object, error) {
 	return NewFileInfo(info), nil
 }
 
-func readDir(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("read_dir", args)
+func (fsys filesystem) readDir(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("read_dir", args)
 	if err != nil {
 		return nil, err
 	}
@@ -185,8 +248,8 @@ func readDir(args object.CallArgs) (object.Object, error) {
 	return &object.List{Elements: items}, nil
 }
 
-func mkdir(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("mkdir", args)
+func (fsys filesystem) mkdir(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("mkdir", args)
 	if err != nil {
 		return nil, err
 	}
@@ -197,8 +260,8 @@ func mkdir(args object.CallArgs) (object.Object, error) {
 	return object.Nil, nil
 }
 
-func remove(args object.CallArgs) (object.Object, error) {
-	path, err := bindPathArg("remove", args)
+func (fsys filesystem) remove(args object.CallArgs) (object.Object, error) {
+	_, path, err := fsys.bindPathArg("remove", args)
 	if err != nil {
 		return nil, err
 	}
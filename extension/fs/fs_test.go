@@ -2,6 +2,7 @@ package fs
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -339,3 +340,57 @@ func TestFileWriteAcceptsBytes(t *testing.T) {
 		t.Fatalf("file content = %v, want [1 2 255]", content)
 	}
 }
+
+func TestFsRooted(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	modObj, err := ExecuteRooted(root)
+	if err != nil {
+		t.Fatalf("ExecuteRooted() error = %v", err)
+	}
+	members := modObj.(*object.Module).Members
+	call := func(name string, args ...object.Object) (object.Object, error) {
+		return members[name].(*object.Function).Call(object.CallArgs{Positional: args})
+	}
+
+	if _, err := call("write", object.String("/notes.txt"), object.String("inside")); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "notes.txt"))
+	if err != nil || string(data) != "inside" {
+		t.Fatalf("notes.txt = %q, %v, want it written under the root", data, err)
+	}
+	for _, path := range []string{"notes.txt", "../notes.txt", "/a/../notes.txt"} {
+		got, err := call("read", object.String(path))
+		if err != nil || got != object.String("inside") {
+			t.Errorf("read(%q) = %v, %v, want the file under the root", path, got, err)
+		}
+	}
+	file, err := call("open", object.String("notes.txt"))
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	defer file.(*File).File.Close()
+	if name := file.(*File).Name; name != "notes.txt" {
+		t.Errorf("open().name = %q, want the path as given", name)
+	}
+
+	for _, path := range []string{"link/secret.txt", "link/new.txt"} {
+		_, err := call("read", object.String(path))
+		if err == nil || !errors.Is(err, object.PermissionError) {
+			t.Errorf("read(%q) error = %v, want PermissionError", path, err)
+		}
+	}
+	if _, err := call("write", object.String("link/new.txt"), object.String("x")); err == nil {
+		t.Error("write() through a link out of the root succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Error("write() created a file outside the root")
+	}
+}
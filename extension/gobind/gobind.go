@@ -0,0 +1,6 @@
+// Package gobind holds the modules goblin bindgen generated from Go packages,
+// one subpackage each. Each registers itself with object.RegisterModule, and
+// modules.go imports them all, so importing this package makes them
+// importable in both backends. bindgen rewrites modules.go whenever it adds a
+// module.
+package gobind
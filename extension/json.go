@@ -3,6 +3,7 @@ package extension
 import (
 	"bytes"
 	"encoding/json"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -55,6 +56,13 @@ func JSONToGoblin(v any, funcname string) (object.Object, error) {
 		if i, err := x.Int64(); err == nil {
 			return object.Integer(i), nil
 		}
+		// An integer literal beyond int64 stays an exact Integer; one with a
+		// fraction or exponent is a Float.
+		if !strings.ContainsAny(string(x), ".eE") {
+			if n, ok := new(big.Int).SetString(string(x), 10); ok {
+				return object.NewInt(n), nil
+			}
+		}
 		f, err := x.Float64()
 		if err != nil {
 			return nil, object.WrapError(object.ParseError, funcname+"() invalid JSON number", err)
@@ -104,6 +112,43 @@ func jsonMarshal(args object.CallArgs) (object.Object, error) {
 	return object.String(buf.String()), nil
 }
 
+// MarshalJSON encodes obj as json.marshal does.
+func MarshalJSON(obj object.Object) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := goblinToJSON(obj, &buf, 0, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CallJSON calls fn with the arguments in the JSON array args, or with none
+// when args is empty, and returns its result encoded as JSON. It is the
+// calling convention of the C functions goblin build-lib generates, as C has
+// no Goblin values to pass.
+func CallJSON(fn object.Object, args []byte) ([]byte, error) {
+	var positional object.Args
+	if len(bytes.TrimSpace(args)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(args))
+		dec.UseNumber()
+		var list []any
+		if err := dec.Decode(&list); err != nil {
+			return nil, object.WrapError(object.ParseError, "invalid JSON arguments: want an array", err)
+		}
+		for _, v := range list {
+			arg, err := JSONToGoblin(v, "unmarshal")
+			if err != nil {
+				return nil, err
+			}
+			positional = append(positional, arg)
+		}
+	}
+	result, err := object.Call(fn, object.CallArgs{Positional: positional})
+	if err != nil {
+		return nil, err
+	}
+	return MarshalJSON(result)
+}
+
 func goblinToJSON(obj object.Object, buf *bytes.Buffer, indent, level int) error {
 	switch v := obj.(type) {
 	case object.Unit:
@@ -119,6 +164,9 @@ func goblinToJSON(obj object.Object, buf *bytes.Buffer, indent, level int) error
 	case object.Integer:
 		buf.WriteString(strconv.FormatInt(int64(v), 10))
 		return nil
+	case *object.BigInt:
+		buf.WriteString(v.String())
+		return nil
 	case object.Float:
 		b, err := json.Marshal(float64(v))
 		if err != nil {
@@ -134,9 +182,18 @@ func goblinToJSON(obj object.Object, buf *bytes.Buffer, indent, level int) error
 		buf.Write(b)
 		return nil
 	case *object.List:
-		return goblinListToJSON(v.Elements, buf, indent, level)
+		return goblinListToJSON(v.Snapshot(), buf, indent, level)
 	case *object.Dict:
 		return goblinDictToJSON(v, buf, indent, level)
+	case json.Marshaler:
+		// Types defined outside this package, such as decimal.Decimal, encode
+		// themselves.
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return object.WrapError(object.ValueError, "marshal() cannot encode "+obj.TypeName(), err)
+		}
+		buf.Write(b)
+		return nil
 	default:
 		return object.NewTypeError("marshal() unsupported type: %s", obj.TypeName())
 	}
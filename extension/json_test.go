@@ -73,6 +73,30 @@ func TestJsonUnmarshalNumberTypes(t *testing.T) {
 	}
 }
 
+func TestJsonBigIntegers(t *testing.T) {
+	const text = `[123456789012345678901234567890, -9223372036854775809, 1.5e3]`
+	got, err := jsonFunction(t, "unmarshal").Call(object.CallArgs{Positional: object.Args{object.String(text)}})
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	items := got.(*object.List).Snapshot()
+	for _, item := range items[:2] {
+		if _, ok := item.(*object.BigInt); !ok {
+			t.Errorf("%v want BigInt, got %T", item, item)
+		}
+	}
+	if _, ok := items[2].(object.Float); !ok {
+		t.Errorf("1.5e3 want Float, got %T", items[2])
+	}
+	encoded, err := jsonFunction(t, "marshal").Call(object.CallArgs{Positional: object.Args{&object.List{Elements: items[:2]}}})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if want := `[123456789012345678901234567890,-9223372036854775809]`; fmt.Sprint(encoded) != want {
+		t.Errorf("marshal = %s, want %s", encoded, want)
+	}
+}
+
 func TestJsonUnmarshalRejectsBadInput(t *testing.T) {
 	if _, err := jsonFunction(t, "unmarshal").Call(object.CallArgs{Positional: object.Args{object.Integer(1)}}); err == nil {
 		t.Fatalf("expected error for non-string argument")
@@ -168,3 +192,35 @@ func TestJsonMarshalArgCount(t *testing.T) {
 		t.Fatalf("expected arg-count error, got %v", err)
 	}
 }
+
+func TestCallJSON(t *testing.T) {
+	sum := &object.Function{Name: "sum", Fn: func(args object.CallArgs) (object.Object, error) {
+		result := object.Object(object.Integer(0))
+		for _, arg := range args.Positional {
+			var err error
+			if result, err = object.Add(result, arg); err != nil {
+				return nil, err
+			}
+		}
+		return &object.List{Elements: []object.Object{result, object.String("done")}}, nil
+	}}
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`[1, 2, 39]`, `[42,"done"]`},
+		{`[0.5, 1]`, `[1.5,"done"]`},
+		{``, `[0,"done"]`},
+	}
+	for _, tt := range tests {
+		got, err := CallJSON(sum, []byte(tt.args))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("CallJSON(%q) = %s, %v, want %s", tt.args, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{`{"a": 1}`, `[1,`, `["a", 1]`} {
+		if _, err := CallJSON(sum, []byte(bad)); err == nil {
+			t.Errorf("CallJSON(%q) succeeded", bad)
+		}
+	}
+}
@@ -49,17 +49,17 @@ func ExecuteMath() (object.Object, error) {
 }
 
 // mathIntPreserving dispatches a function that preserves the int-ness of its
-// argument: an Integer input yields an Integer output via intFn, a Float input
-// yields a Float output via floatFn.
-func mathIntPreserving(name string, args object.CallArgs, intFn func(int64) int64, floatFn func(float64) float64) (object.Object, error) {
+// argument: an Integer input, of either size, yields an Integer output via
+// intFn, a Float input yields a Float output via floatFn.
+func mathIntPreserving(name string, args object.CallArgs, intFn func(object.Object) (object.Object, error), floatFn func(float64) float64) (object.Object, error) {
 	p := object.NewArgParser(name, args)
 	v := p.Number("x")
 	if err := p.Finish(); err != nil {
 		return nil, err
 	}
 	switch n := v.(type) {
-	case object.Integer:
-		return object.Integer(intFn(int64(n))), nil
+	case object.Integer, *object.BigInt:
+		return intFn(n)
 	case object.Float:
 		return object.Float(floatFn(float64(n))), nil
 	}
@@ -81,11 +81,11 @@ func mathUnary(name string, fn func(float64) float64) *object.Function {
 
 func mathAbs(args object.CallArgs) (object.Object, error) {
 	return mathIntPreserving("abs", args,
-		func(i int64) int64 {
-			if i < 0 {
-				return -i
+		func(i object.Object) (object.Object, error) {
+			if c, err := object.Compare(i, object.Integer(0)); err != nil || c >= 0 {
+				return i, err
 			}
-			return i
+			return object.Negate(i)
 		},
 		math.Abs,
 	)
@@ -93,32 +93,36 @@ func mathAbs(args object.CallArgs) (object.Object, error) {
 
 func mathCeil(args object.CallArgs) (object.Object, error) {
 	return mathIntPreserving("ceil", args,
-		func(i int64) int64 { return i },
+		intIdentity,
 		math.Ceil,
 	)
 }
 
 func mathFloor(args object.CallArgs) (object.Object, error) {
 	return mathIntPreserving("floor", args,
-		func(i int64) int64 { return i },
+		intIdentity,
 		math.Floor,
 	)
 }
 
 func mathRound(args object.CallArgs) (object.Object, error) {
 	return mathIntPreserving("round", args,
-		func(i int64) int64 { return i },
+		intIdentity,
 		math.Round,
 	)
 }
 
 func mathTrunc(args object.CallArgs) (object.Object, error) {
 	return mathIntPreserving("trunc", args,
-		func(i int64) int64 { return i },
+		intIdentity,
 		math.Trunc,
 	)
 }
 
+// intIdentity is the integer half of ceil, floor, round and trunc: an integer
+// is already whole.
+func intIdentity(i object.Object) (object.Object, error) { return i, nil }
+
 func mathPow(args object.CallArgs) (object.Object, error) {
 	p := object.NewArgParser("pow", args)
 	base := p.Float64("base")
@@ -214,6 +218,8 @@ func toFloat(funcName string, v object.Object) (float64, error) {
 		return float64(int64(n)), nil
 	case object.Float:
 		return float64(n), nil
+	case *object.BigInt:
+		return n.Float64(), nil
 	default:
 		return 0, object.NewTypeError("%s() argument must be a number, got %s", funcName, v.TypeName())
 	}
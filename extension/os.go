@@ -1,8 +1,10 @@
 package extension
 
 import (
+	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	pathext "github.com/aisk/goblin/extension/path"
 	"github.com/aisk/goblin/object"
@@ -11,7 +13,7 @@ import (
 // ExecuteOs builds the os module with live process arguments (os.Args).
 // Used by compiled programs from build-exe.
 func ExecuteOs() (object.Object, error) {
-	return newOsModule(func() []string { return os.Args }), nil
+	return newOsModule(func() []string { return os.Args }, exitProcess), nil
 }
 
 // ExecuteOsWithFrozenArgs builds the os module with a fixed Args snapshot.
@@ -20,17 +22,24 @@ func ExecuteOs() (object.Object, error) {
 // concurrent runs and spawned goroutines keep a stable view after the loader
 // returns.
 func ExecuteOsWithFrozenArgs(args []string) (object.Object, error) {
+	return ExecuteOsWithExit(args, exitProcess)
+}
+
+// ExecuteOsWithExit is ExecuteOsWithFrozenArgs with exit as what os.exit
+// calls with the status. The interpreter uses it to end a program without
+// ending the process, by returning an *ExitError.
+func ExecuteOsWithExit(args []string, exit func(code int) error) (object.Object, error) {
 	snapshot := append([]string(nil), args...)
-	return newOsModule(func() []string { return snapshot }), nil
+	return newOsModule(func() []string { return snapshot }, exit), nil
 }
 
-func newOsModule(argsFn func() []string) object.Object {
+func newOsModule(argsFn func() []string, exitFn func(code int) error) object.Object {
 	return &object.Module{
 		Name: "os",
 		Members: map[string]object.Object{
 			"argv":        &object.Function{Name: "argv", Fn: makeArgv(argsFn)},
 			"environ":     &object.Function{Name: "environ", Fn: environ},
-			"exit":        &object.Function{Name: "exit", Fn: exit},
+			"exit":        &object.Function{Name: "exit", Fn: makeExit(exitFn)},
 			"getegid":     intGetter("getegid", os.Getegid),
 			"getenv":      &object.Function{Name: "getenv", Fn: getenv},
 			"geteuid":     intGetter("geteuid", os.Geteuid),
@@ -65,14 +74,52 @@ func makeArgv(argsFn func() []string) func(object.CallArgs) (object.Object, erro
 	}
 }
 
-func exit(args object.CallArgs) (object.Object, error) {
-	p := object.NewArgParser("exit", args)
-	code := p.IntOr("code", 0)
-	if err := p.Finish(); err != nil {
-		return nil, err
+var (
+	exitHooksMu sync.Mutex
+	exitHooks   []func()
+)
+
+// AtExit registers fn to run when a compiled program calls os.exit, which
+// ends the process without returning to the caller. A build-exe executable
+// uses it to write its CPU profile. The interpreter's programs take their
+// exit hook from interpreter.Options instead.
+func AtExit(fn func()) {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+	exitHooks = append(exitHooks, fn)
+}
+
+// exitProcess runs the AtExit hooks and ends the process with code.
+func exitProcess(code int) error {
+	exitHooksMu.Lock()
+	hooks := exitHooks
+	exitHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	os.Exit(code)
+	return nil
+}
+
+// ExitError is the error os.exit ends a program with when it must not end
+// the process, as in an interpreter.Session or an engine.VM: the Eval or
+// Call running the program returns it, and try/catch does not catch it.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+// makeExit returns os.exit, which ends the program through exitFn.
+func makeExit(exitFn func(code int) error) func(object.CallArgs) (object.Object, error) {
+	return func(args object.CallArgs) (object.Object, error) {
+		p := object.NewArgParser("exit", args)
+		code := p.IntOr("code", 0)
+		if err := p.Finish(); err != nil {
+			return nil, err
+		}
+		return nil, exitFn(int(code))
 	}
-	os.Exit(int(code))
-	return nil, nil
 }
 
 func getenv(args object.CallArgs) (object.Object, error) {
@@ -116,11 +116,13 @@ func (r *Rand) randomShuffle(args object.CallArgs) (object.Object, error) {
 	if !ok {
 		return nil, object.NewTypeError("shuffle() argument 'list' must be a list, got %s", listObj.TypeName())
 	}
-	r.mu.Lock()
-	r.rng.Shuffle(len(list.Elements), func(i, j int) {
-		list.Elements[i], list.Elements[j] = list.Elements[j], list.Elements[i]
+	list.Mutate(func(elements []object.Object) {
+		r.mu.Lock()
+		r.rng.Shuffle(len(elements), func(i, j int) {
+			elements[i], elements[j] = elements[j], elements[i]
+		})
+		r.mu.Unlock()
 	})
-	r.mu.Unlock()
 	return object.Nil, nil
 }
 
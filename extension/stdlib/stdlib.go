@@ -0,0 +1,68 @@
+// Package stdlib registers the modules of the standard library, so that
+// importing it makes them importable in both backends. Add a module here
+// with one object.RegisterModule call.
+package stdlib
+
+import (
+	"github.com/aisk/goblin/extension"
+	decimalExt "github.com/aisk/goblin/extension/decimal"
+	execExt "github.com/aisk/goblin/extension/exec"
+	"github.com/aisk/goblin/extension/fs"
+	httpExt "github.com/aisk/goblin/extension/http"
+	mailExt "github.com/aisk/goblin/extension/mail"
+	netipExt "github.com/aisk/goblin/extension/netip"
+	pathExt "github.com/aisk/goblin/extension/path"
+	regexpExt "github.com/aisk/goblin/extension/regexp"
+	timeExt "github.com/aisk/goblin/extension/time"
+	urlExt "github.com/aisk/goblin/extension/url"
+	"github.com/aisk/goblin/object"
+)
+
+const pathExtension = "github.com/aisk/goblin/extension"
+
+func init() {
+	object.RegisterModule("os", pathExtension, extension.ExecuteOs)
+	object.RegisterModule("rand", pathExtension, extension.ExecuteRand)
+	object.RegisterModule("math", pathExtension, extension.ExecuteMath)
+	object.RegisterModule("base64", pathExtension, extension.ExecuteBase64)
+	object.RegisterModule("http", pathExtension+"/http", httpExt.Execute)
+	object.RegisterModule("fs", pathExtension+"/fs", fs.Execute)
+	object.RegisterModule("mime", pathExtension, extension.ExecuteMime)
+	object.RegisterModule("json", pathExtension, extension.ExecuteJson)
+	object.RegisterModule("uuid", pathExtension, extension.ExecuteUUID)
+	object.RegisterModule("path", pathExtension+"/path", pathExt.Execute)
+	object.RegisterModule("time", pathExtension+"/time", timeExt.Execute)
+	object.RegisterModule("exec", pathExtension+"/exec", execExt.Execute)
+	object.RegisterModule("regexp", pathExtension+"/regexp", regexpExt.Execute)
+	object.RegisterModule("hex", pathExtension, extension.ExecuteHex)
+	object.RegisterModule("sha256", pathExtension, extension.ExecuteSHA256)
+	object.RegisterModule("sha512", pathExtension, extension.ExecuteSHA512)
+	object.RegisterModule("url", pathExtension+"/url", urlExt.Execute)
+	object.RegisterModule("csv", pathExtension, extension.ExecuteCSV)
+	object.RegisterModule("gzip", pathExtension, extension.ExecuteGzip)
+	object.RegisterModule("zlib", pathExtension, extension.ExecuteZlib)
+	object.RegisterModule("tar", pathExtension, extension.ExecuteTar)
+	object.RegisterModule("zip", pathExtension, extension.ExecuteZip)
+	object.RegisterModule("base32", pathExtension, extension.ExecuteBase32)
+	object.RegisterModule("ascii85", pathExtension, extension.ExecuteASCII85)
+	object.RegisterModule("html", pathExtension, extension.ExecuteHTML)
+	object.RegisterModule("quotedprintable", pathExtension, extension.ExecuteQuotedPrintable)
+	object.RegisterModule("md5", pathExtension, extension.ExecuteMD5)
+	object.RegisterModule("sha1", pathExtension, extension.ExecuteSHA1)
+	object.RegisterModule("crc32", pathExtension, extension.ExecuteCRC32)
+	object.RegisterModule("adler32", pathExtension, extension.ExecuteAdler32)
+	object.RegisterModule("flate", pathExtension, extension.ExecuteFlate)
+	object.RegisterModule("bzip2", pathExtension, extension.ExecuteBzip2)
+	object.RegisterModule("mail", pathExtension+"/mail", mailExt.Execute)
+	object.RegisterModule("hmac", pathExtension, extension.ExecuteHMAC)
+	object.RegisterModule("crc64", pathExtension, extension.ExecuteCRC64)
+	object.RegisterModule("fnv", pathExtension, extension.ExecuteFNV)
+	object.RegisterModule("lzw", pathExtension, extension.ExecuteLZW)
+	object.RegisterModule("pem", pathExtension, extension.ExecutePEM)
+	object.RegisterModule("netip", pathExtension+"/netip", netipExt.Execute)
+	object.RegisterModule("utf8", pathExtension, extension.ExecuteUTF8)
+	object.RegisterModule("unicode", pathExtension, extension.ExecuteUnicode)
+	object.RegisterModule("decimal", pathExtension+"/decimal", decimalExt.Execute)
+	object.RegisterModule("testing", pathExtension, extension.ExecuteTesting)
+	object.RegisterModule("build", pathExtension, extension.ExecuteBuild)
+}
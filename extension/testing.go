@@ -0,0 +1,289 @@
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/aisk/goblin/object"
+)
+
+var (
+	// AssertionError is raised by the testing module's failed assertions.
+	AssertionError = object.NewSentinelError("AssertionError", object.BaseError)
+	// SkipTest is raised by testing.skip. The test runner reports a test that
+	// raises it as skipped rather than failed.
+	SkipTest = object.NewSentinelError("SkipTest", object.BaseError)
+)
+
+// Skipped reports whether err is a test skipping itself with testing.skip.
+func Skipped(err error) bool {
+	return errors.Is(err, SkipTest)
+}
+
+// Testing is one instance of the testing module. The test runner makes a
+// fresh one for every test and calls Cleanup once the test is over; a
+// program importing the module outside the runner keeps its temporary
+// directories.
+type Testing struct {
+	mu   sync.Mutex
+	dirs []string
+}
+
+func NewTesting() *Testing {
+	return &Testing{}
+}
+
+func ExecuteTesting() (object.Object, error) {
+	return NewTesting().Execute()
+}
+
+// Execute is the module executor for this instance.
+func (t *Testing) Execute() (object.Object, error) {
+	return &object.Module{
+		Name: "testing",
+		Members: map[string]object.Object{
+			"AssertionError":      AssertionError,
+			"SkipTest":            SkipTest,
+			"assert_equal":        &object.Function{Name: "assert_equal", Fn: testingAssertEqual},
+			"assert_not_equal":    &object.Function{Name: "assert_not_equal", Fn: testingAssertNotEqual},
+			"assert_true":         &object.Function{Name: "assert_true", Fn: testingAssertTrue},
+			"assert_false":        &object.Function{Name: "assert_false", Fn: testingAssertFalse},
+			"assert_almost_equal": &object.Function{Name: "assert_almost_equal", Fn: testingAssertAlmostEqual},
+			"is_close":            &object.Function{Name: "is_close", Fn: testingIsClose},
+			"assert_raises":       &object.Function{Name: "assert_raises", Fn: testingAssertRaises},
+			"fail":                &object.Function{Name: "fail", Fn: testingFail},
+			"skip":                &object.Function{Name: "skip", Fn: testingSkip},
+			"temp_dir":            &object.Function{Name: "temp_dir", Fn: t.tempDir},
+		},
+	}, nil
+}
+
+// Cleanup removes the directories temp_dir created.
+func (t *Testing) Cleanup() error {
+	t.mu.Lock()
+	dirs := t.dirs
+	t.dirs = nil
+	t.mu.Unlock()
+	var errs []error
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Testing) tempDir(args object.CallArgs) (object.Object, error) {
+	if err := object.RequireNoArgs("temp_dir", args); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "goblin-test-*")
+	if err != nil {
+		return nil, object.WrapNativeError(object.IOError, "temp_dir", err)
+	}
+	t.mu.Lock()
+	t.dirs = append(t.dirs, dir)
+	t.mu.Unlock()
+	return object.String(dir), nil
+}
+
+// assertionFailed builds the AssertionError for a failed check, led by the
+// caller's message when there is one.
+func assertionFailed(message object.String, format string, a ...any) error {
+	text := fmt.Sprintf(format, a...)
+	if message != "" {
+		text = string(message) + ": " + text
+	}
+	return &object.Error{Value: text, Wrapped: AssertionError}
+}
+
+// testingRepr shows a value in a failure message, quoting strings so that
+// "1" and 1 tell apart.
+func testingRepr(v object.Object) string {
+	if s, ok := v.(object.String); ok {
+		return s.Literal()
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return v.TypeName()
+}
+
+func testingAssertEqual(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("assert_equal", args)
+	actual, expected := p.Any("actual"), p.Any("expected")
+	message := p.StrOr("message", "")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	eq, err := object.Equals(actual, expected)
+	if err != nil {
+		return nil, err
+	}
+	if !eq {
+		return nil, assertionFailed(message, "expected %s, got %s", testingRepr(expected), testingRepr(actual))
+	}
+	return object.Nil, nil
+}
+
+func testingAssertNotEqual(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("assert_not_equal", args)
+	actual, unexpected := p.Any("actual"), p.Any("unexpected")
+	message := p.StrOr("message", "")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	eq, err := object.Equals(actual, unexpected)
+	if err != nil {
+		return nil, err
+	}
+	if eq {
+		return nil, assertionFailed(message, "expected a value other than %s", testingRepr(unexpected))
+	}
+	return object.Nil, nil
+}
+
+func testingAssertTrue(args object.CallArgs) (object.Object, error) {
+	return testingAssertTruth("assert_true", true, args)
+}
+
+func testingAssertFalse(args object.CallArgs) (object.Object, error) {
+	return testingAssertTruth("assert_false", false, args)
+}
+
+func testingAssertTruth(name string, want bool, args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser(name, args)
+	value := p.Any("value")
+	message := p.StrOr("message", "")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	truth, err := value.ToBool()
+	if err != nil {
+		return nil, err
+	}
+	if truth != want {
+		return nil, assertionFailed(message, "expected a %t value, got %s", want, testingRepr(value))
+	}
+	return object.Nil, nil
+}
+
+// closeEnough reports whether a and b are equal within the larger of a
+// tolerance relative to their magnitude and an absolute one, as Python's
+// math.isclose does. Equal infinities are close; NaN is close to nothing.
+func closeEnough(a, b, relTol, absTol float64) bool {
+	if a == b {
+		return true
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	diff := math.Abs(a - b)
+	return diff <= math.Max(relTol*math.Max(math.Abs(a), math.Abs(b)), absTol)
+}
+
+type closeArgs struct {
+	actual, expected object.Object
+	relTol, absTol   float64
+}
+
+func parseCloseArgs(p *object.ArgParser) closeArgs {
+	c := closeArgs{actual: p.Number("actual"), expected: p.Number("expected")}
+	c.relTol = float64(testingFloat(p.NumberOr("rel_tol", object.Float(1e-9))))
+	c.absTol = float64(testingFloat(p.NumberOr("abs_tol", object.Float(0))))
+	return c
+}
+
+func (c closeArgs) close() (bool, error) {
+	if c.relTol < 0 || c.absTol < 0 {
+		return false, object.NewValueError("tolerances must be non-negative")
+	}
+	return closeEnough(float64(testingFloat(c.actual)), float64(testingFloat(c.expected)), c.relTol, c.absTol), nil
+}
+
+func testingFloat(v object.Object) object.Float {
+	switch n := v.(type) {
+	case object.Integer:
+		return object.Float(n)
+	case object.Float:
+		return n
+	case *object.BigInt:
+		return object.Float(n.Float64())
+	}
+	return 0
+}
+
+func testingIsClose(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("is_close", args)
+	c := parseCloseArgs(p)
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	ok, err := c.close()
+	if err != nil {
+		return nil, err
+	}
+	return object.Bool(ok), nil
+}
+
+func testingAssertAlmostEqual(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("assert_almost_equal", args)
+	c := parseCloseArgs(p)
+	message := p.StrOr("message", "")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	ok, err := c.close()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, assertionFailed(message, "expected %s, got %s (rel_tol=%g, abs_tol=%g)", testingRepr(c.expected), testingRepr(c.actual), c.relTol, c.absTol)
+	}
+	return object.Nil, nil
+}
+
+// testingAssertRaises calls fn and returns the error it raises, which must
+// match kind. An error of another kind propagates unchanged, so its own
+// traceback explains the failure.
+func testingAssertRaises(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("assert_raises", args)
+	kindArg, fn := p.Any("kind"), p.Func("fn")
+	message := p.StrOr("message", "")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	kind, ok := kindArg.(*object.Error)
+	if !ok {
+		return nil, object.NewTypeError("assert_raises() argument 'kind' must be an Error, got %s", kindArg.TypeName())
+	}
+	_, err := fn.Call(object.CallArgs{})
+	if err == nil {
+		return nil, assertionFailed(message, "expected %s to be raised", kind.Value)
+	}
+	if !errors.Is(err, kind) {
+		return nil, err
+	}
+	return object.ErrorValue(err), nil
+}
+
+func testingFail(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("fail", args)
+	message := p.StrOr("message", "test failed")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	return nil, &object.Error{Value: string(message), Wrapped: AssertionError}
+}
+
+func testingSkip(args object.CallArgs) (object.Object, error) {
+	p := object.NewArgParser("skip", args)
+	reason := p.StrOr("reason", "skipped")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	return nil, &object.Error{Value: string(reason), Wrapped: SkipTest}
+}
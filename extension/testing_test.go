@@ -0,0 +1,75 @@
+package extension
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aisk/goblin/object"
+)
+
+func TestTestingAssertions(t *testing.T) {
+	call := func(fn func(object.CallArgs) (object.Object, error), args ...object.Object) error {
+		_, err := fn(object.CallArgs{Positional: object.Args(args)})
+		return err
+	}
+	if err := call(testingAssertEqual, object.Integer(1), object.Integer(1)); err != nil {
+		t.Fatalf("assert_equal(1, 1) = %v", err)
+	}
+	err := call(testingAssertEqual, object.String("1"), object.Integer(1), object.String("mixed"))
+	if !errors.Is(err, AssertionError) || object.ErrorValue(err).(*object.Error).Value != `mixed: expected 1, got "1"` {
+		t.Fatalf("assert_equal(\"1\", 1) = %v", err)
+	}
+	if err := call(testingAssertAlmostEqual, object.Float(0.1+0.2), object.Float(0.3)); err != nil {
+		t.Fatalf("assert_almost_equal(0.1 + 0.2, 0.3) = %v", err)
+	}
+	if err := call(testingAssertAlmostEqual, object.Integer(1), object.Float(1.1)); !errors.Is(err, AssertionError) {
+		t.Fatalf("assert_almost_equal(1, 1.1) = %v", err)
+	}
+	if !closeEnough(100, 101, 0, 1) || closeEnough(100, 102, 0.01, 0) {
+		t.Fatal("closeEnough ignores its tolerances")
+	}
+	if err := call(testingSkip, object.String("later")); !Skipped(err) {
+		t.Fatalf("skip() = %v, want a SkipTest", err)
+	}
+}
+
+func TestTestingAssertRaises(t *testing.T) {
+	raises := func(kind *object.Error) *object.Function {
+		return &object.Function{Name: "f", Fn: func(object.CallArgs) (object.Object, error) {
+			return nil, &object.Error{Value: "boom", Wrapped: kind}
+		}}
+	}
+	assertRaises := func(kind *object.Error, fn *object.Function) (object.Object, error) {
+		return testingAssertRaises(object.CallArgs{Positional: object.Args{kind, fn}})
+	}
+
+	got, err := assertRaises(object.ValueError, raises(object.ValueError))
+	if err != nil || got.(*object.Error).Value != "boom" {
+		t.Fatalf("matching kind: got %v, %v", got, err)
+	}
+	if _, err := assertRaises(object.ValueError, raises(object.TypeError)); !errors.Is(err, object.TypeError) {
+		t.Fatalf("other kind: got %v, want the TypeError itself", err)
+	}
+	quiet := &object.Function{Name: "f", Fn: func(object.CallArgs) (object.Object, error) { return object.Nil, nil }}
+	if _, err := assertRaises(object.ValueError, quiet); !errors.Is(err, AssertionError) {
+		t.Fatalf("nothing raised: got %v, want an AssertionError", err)
+	}
+}
+
+func TestTestingCleanupRemovesTempDirs(t *testing.T) {
+	tt := NewTesting()
+	dir, err := tt.tempDir(object.CallArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(string(dir.(object.String))); err != nil {
+		t.Fatal(err)
+	}
+	if err := tt.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(string(dir.(object.String))); !os.IsNotExist(err) {
+		t.Fatalf("temp dir survived Cleanup: %v", err)
+	}
+}
@@ -42,8 +42,9 @@ func joinPath(args object.CallArgs) (object.Object, error) {
 	if !ok {
 		return nil, object.NewTypeError("join_path() argument 'elements' must be a list, got %s", elementsObj.TypeName())
 	}
-	elements := make([]string, len(list.Elements))
-	for i, item := range list.Elements {
+	items := list.Snapshot()
+	elements := make([]string, len(items))
+	for i, item := range items {
 		value, ok := item.(object.String)
 		if !ok {
 			return nil, object.NewTypeError("join_path() argument 'elements' must contain strings, got %s at index %d", item.TypeName(), i)
@@ -0,0 +1,164 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Diff returns a unified diff turning a into b, with three lines of context,
+// or nil when they are equal. It is meant for `goblin fmt -d`, where the two
+// sides differ in layout only.
+func Diff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	x, y := splitLines(a), splitLines(b)
+	ops := diffLines(x, y)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	const context = 3
+	for i := 0; i < len(ops); {
+		// Find the next change, then grow the hunk while the unchanged runs
+		// between changes are short enough to share context.
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+		oldStart, newStart := ops[start].x+1, ops[start].y+1
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range names the line before it.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(src []byte) []string {
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: ' ' keeps it, '-' deletes it from
+// the old side, '+' inserts it from the new side. x and y are the line's
+// indexes on each side, or the index of the next line there.
+type diffOp struct {
+	kind byte
+	text string
+	x, y int
+}
+
+// diffLines computes a shortest edit script by longest common subsequence.
+// The common prefix and suffix are matched first, so the quadratic table
+// only covers the region formatting changed.
+func diffLines(x, y []string) []diffOp {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of mx[i:]
+	// and my[j:].
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', x[i], i, i})
+	}
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			ops = append(ops, diffOp{' ', mx[i], prefix + i, prefix + j})
+			i++
+			j++
+		case j == len(my) || i < len(mx) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', mx[i], prefix + i, prefix + j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', my[j], prefix + i, prefix + j})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, diffOp{' ', x[len(x)-suffix+k], len(x) - suffix + k, len(y) - suffix + k})
+	}
+	return ops
+}
@@ -0,0 +1,230 @@
+// Package format prints Goblin source in the canonical style enforced by
+// `goblin fmt`: four-space indentation, one statement per line, single spaces
+// around binary operators, and at most one blank line in a row. Comments stay
+// next to the code they were written beside, and literals keep the spelling
+// they have in the source.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/token"
+)
+
+// Source formats a Goblin file. The filename only appears in parse errors
+// and may be empty.
+func Source(filename string, src []byte) (out []byte, err error) {
+	m, s, err := parse(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &printer{tokens: s.Tokens, comments: s.Comments, first: true}
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	p.statements(m.Body)
+	p.flush(p.end())
+	out = p.buf.Bytes()
+
+	// Printing is driven by the AST, so a printer bug could change what the
+	// program means. Refuse to return output that no longer parses or that
+	// lost a comment rather than hand it to `fmt -w`.
+	if _, check, err := parse(filename, out); err != nil {
+		return nil, fmt.Errorf("format: internal error: output does not parse: %w", err)
+	} else if len(check.Comments) != len(s.Comments) {
+		return nil, fmt.Errorf("format: internal error: %d of %d comments kept", len(check.Comments), len(s.Comments))
+	}
+	return out, nil
+}
+
+func parse(filename string, src []byte) (*ast.Module, *source.Scanner, error) {
+	s := source.NewScanner(src)
+	if filename != "" {
+		s.SetFilename(filename)
+	}
+	st, err := parser.NewParser().Parse(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := st.(*ast.Module)
+	if !ok {
+		return nil, nil, fmt.Errorf("internal error: unexpected AST type")
+	}
+	return m, s, nil
+}
+
+// printer writes the AST back out while stepping through the source tokens
+// in the same order. Following along in the token stream is what lets it
+// copy literals verbatim, see where the author broke lines, and put each
+// comment back before the token it preceded. The AST drops grouping
+// parentheses, so source parentheses the printer does not ask for are
+// skipped, and the ones precedence requires are written without consuming
+// anything.
+type printer struct {
+	buf      bytes.Buffer
+	tokens   []*token.Token
+	comments []source.Comment
+	tok      int // next source token
+	com      int // next comment
+	indent   int
+	// cont indents the rest of a line a comment forced to break.
+	cont int
+	// line is the source line of the last token or comment printed.
+	line int
+	// bol is set at the beginning of an output line.
+	bol bool
+	// first is set until the first line of a file or block is printed, where
+	// blank lines are dropped.
+	first bool
+}
+
+func (p *printer) write(s string) {
+	if p.bol || p.buf.Len() == 0 {
+		p.buf.WriteString(strings.Repeat("    ", p.indent+p.cont))
+		p.bol = false
+	}
+	p.buf.WriteString(s)
+}
+
+// newline ends the output line, taking along a comment written after the
+// last token on its source line.
+func (p *printer) newline() {
+	if p.com < len(p.comments) {
+		c := p.comments[p.com]
+		if c.Pos.Line == p.line && c.Pos.Offset < p.peek().Offset {
+			p.write("  " + c.Text)
+			p.com++
+		}
+	}
+	p.buf.WriteByte('\n')
+	p.bol = true
+	p.cont = 0
+}
+
+// gap keeps one blank line before source line n if the author left any.
+func (p *printer) gap(n int) {
+	if !p.first && p.line > 0 && n > p.line+1 {
+		p.buf.WriteByte('\n')
+	}
+	p.first = false
+}
+
+// flush prints the comments before pos. At the start of a line they get
+// lines of their own; in the middle of one, the line breaks after them.
+func (p *printer) flush(pos token.Pos) {
+	for p.com < len(p.comments) && p.comments[p.com].Pos.Offset < pos.Offset {
+		c := p.comments[p.com]
+		p.com++
+		switch {
+		case p.bol || p.buf.Len() == 0:
+			p.gap(c.Pos.Line)
+			p.write(c.Text)
+		case c.Pos.Line == p.line:
+			p.buf.Truncate(len(bytes.TrimRight(p.buf.Bytes(), " ")))
+			p.write("  " + c.Text)
+			p.cont = 1
+		default:
+			p.buf.Truncate(len(bytes.TrimRight(p.buf.Bytes(), " ")))
+			p.buf.WriteByte('\n')
+			p.bol = true
+			p.cont = 1
+			p.write(c.Text)
+		}
+		p.buf.WriteByte('\n')
+		p.bol = true
+		p.line = c.Pos.Line
+	}
+}
+
+// peek returns the position of the next source token, skipping parentheses
+// the printer may never ask for. Past the last token it returns a position
+// after everything.
+func (p *printer) peek() token.Pos {
+	for i := p.tok; i < len(p.tokens); i++ {
+		if !isParen(p.tokens[i]) {
+			return p.tokens[i].Pos
+		}
+	}
+	return p.end()
+}
+
+func (p *printer) end() token.Pos {
+	return token.Pos{Offset: math.MaxInt, Line: math.MaxInt}
+}
+
+// peekLit returns the text of the next source token that is not a
+// parenthesis.
+func (p *printer) peekLit() string {
+	for i := p.tok; i < len(p.tokens); i++ {
+		if !isParen(p.tokens[i]) {
+			return string(p.tokens[i].Lit)
+		}
+	}
+	return ""
+}
+
+// token prints the next source token, which must read lit; an empty lit
+// takes whatever is next, for literals that are copied as written.
+func (p *printer) token(lit string) *token.Token {
+	for p.tok < len(p.tokens) && isParen(p.tokens[p.tok]) && string(p.tokens[p.tok].Lit) != lit {
+		p.tok++
+	}
+	if p.tok >= len(p.tokens) {
+		panic(fmt.Sprintf("format: internal error: ran out of tokens printing %q", lit))
+	}
+	t := p.tokens[p.tok]
+	if lit != "" && string(t.Lit) != lit {
+		panic(fmt.Sprintf("format: internal error: printing %q, found %q at %d:%d", lit, t.Lit, t.Pos.Line, t.Pos.Column))
+	}
+	p.flush(t.Pos)
+	p.write(string(t.Lit))
+	p.line = ast.TokenEnd(t).Line
+	p.tok++
+	return t
+}
+
+func isParen(t *token.Token) bool {
+	return len(t.Lit) == 1 && (t.Lit[0] == '(' || t.Lit[0] == ')')
+}
+
+// closing returns the source token closing the bracket just printed.
+func (p *printer) closing() *token.Token {
+	return p.matching(p.tok - 1)
+}
+
+// matching returns the source token closing the bracket at tokens[open].
+func (p *printer) matching(open int) *token.Token {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch string(p.tokens[i].Lit) {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return p.tokens[i]
+			}
+		}
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *printer) hasComment(from, to token.Pos) bool {
+	for _, c := range p.comments[p.com:] {
+		if c.Pos.Offset >= to.Offset {
+			break
+		}
+		if c.Pos.Offset > from.Offset {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "spacing",
+			src:  "var x=1+2*3\nprint( x,sep=\" \" )\nitems[0]=-x\nuser.name=\"a\"\n",
+			want: "var x = 1 + 2 * 3\nprint(x, sep=\" \")\nitems[0] = -x\nuser.name = \"a\"\n",
+		},
+		{
+			name: "literals keep their spelling",
+			src:  "print(1.50, 007, \"tab\\tquote\\\"\", 123456789012345678901234567890)",
+			want: "print(1.50, 007, \"tab\\tquote\\\"\", 123456789012345678901234567890)\n",
+		},
+		{
+			name: "indentation",
+			src:  "func f(a,b=2,*rest,**options){\nif a{\n\treturn b\n}else if b{\nreturn\n}else{\nraise Error(\"x\")\n}\n}\n",
+			want: "func f(a, b=2, *rest, **options) {\n    if a {\n        return b\n    } else if b {\n        return\n    } else {\n        raise Error(\"x\")\n    }\n}\n",
+		},
+		{
+			name: "else block holding an if",
+			src:  "if a {\n} else {\n    if b {\n        print(b)\n    }\n}\n",
+			want: "if a {} else {\n    if b {\n        print(b)\n    }\n}\n",
+		},
+		{
+			name: "blank lines",
+			src:  "\n\nimport \"json\"\n\n\n\nvar a = 1\nfunc f() {\n\n    print(a)\n\n\n    print(a)\n\n}\n\n",
+			want: "import \"json\"\n\nvar a = 1\nfunc f() {\n    print(a)\n\n    print(a)\n}\n",
+		},
+		{
+			name: "one-line bodies",
+			src:  "var inc = func(x) {   return x+1 }\nfor x in xs { print(x) }\nwhile true {\n}\ntry { f() } catch e { print(e) }\n",
+			want: "var inc = func(x) { return x + 1 }\nfor x in xs { print(x) }\nwhile true {}\ntry { f() } catch e { print(e) }\n",
+		},
+		{
+			name: "types",
+			src:  "type Point(x,y=0){\nfunc norm(self){return self.x}\n\n\nfunc scale(self, k) {\nreturn Point(self.x*k)\n}\n}\ntype Empty() {\n}\n",
+			want: "type Point(x, y=0) {\n    func norm(self) { return self.x }\n\n    func scale(self, k) {\n        return Point(self.x * k)\n    }\n}\ntype Empty() {}\n",
+		},
+		{
+			name: "parentheses",
+			src:  "print((a + b) * c, a + (b + c), (a && b) || c, ((x)), -(y), (f)(1), (a < b) == c)\n",
+			want: "print((a + b) * c, a + (b + c), (a && b) || c, ((x)), -(y), (f)(1), (a < b) == c)\n",
+		},
+		{
+			name: "multi-line lists",
+			src:  "var cmd = exec.Command(\n\"printf\",\n        stdout=exec.CAPTURE)\nvar d = {\n  \"a\": [1,\n 2],\n  \"b\": func() {\n    return 2\n  }\n}\n",
+			want: "var cmd = exec.Command(\n    \"printf\",\n    stdout=exec.CAPTURE\n)\nvar d = {\n    \"a\": [1, 2],\n    \"b\": func() {\n        return 2\n    }\n}\n",
+		},
+		{
+			name: "comments",
+			src:  "# header\n\n\nimport \"json\"   # why json\n\nfunc f() { # opens\n    # leading\n    print(1)  # trailing\n\n    # before the brace\n}\nvar items = [ # first\n  1,\n  # between\n  2\n]\nvar total = a +  # split\n    b\n# footer",
+			want: "# header\n\nimport \"json\"  # why json\n\nfunc f() {  # opens\n    # leading\n    print(1)  # trailing\n\n    # before the brace\n}\nvar items = [  # first\n    1,\n    # between\n    2\n]\nvar total = a +  # split\n    b\n# footer\n",
+		},
+		{
+			name: "comments only",
+			src:  "# one\n\n\n# two",
+			want: "# one\n\n# two\n",
+		},
+		{
+			name: "empty",
+			src:  "\n\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source("", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			again, err := Source("", got)
+			if err != nil || string(again) != string(got) {
+				t.Fatalf("not idempotent (%v):\n%s", err, again)
+			}
+		})
+	}
+}
+
+// Every example must format without losing comments and reach a fixed point
+// in one pass.
+func TestSourceExamples(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "examples", "*.goblin"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples: %v", err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := Source(path, src)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		twice, err := Source(path, once)
+		if err != nil || string(twice) != string(once) {
+			t.Errorf("%s: formatting is not idempotent (%v)", path, err)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source("broken.goblin", []byte("var x = \n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "broken.goblin:2:1: error:") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if d := Diff("a", "b", []byte("x\n"), []byte("x\n")); d != nil {
+		t.Fatalf("diff of equal inputs = %q", d)
+	}
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+\ No newline at end of file
+`
+	if got := string(Diff("a", "b", []byte(old), []byte(new))); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,414 @@
+package format
+
+import (
+	"github.com/aisk/goblin/ast"
+)
+
+// Operator precedence, loosest first, following the grammar's expression
+// levels.
+const (
+	precOr = iota + 1
+	precAnd
+	precCompare
+	precAdd
+	precMultiply
+	precUnary
+	precPostfix
+)
+
+var binaryPrecedence = map[string]int{
+	ast.Or:             precOr,
+	ast.And:            precAnd,
+	ast.Equal:          precCompare,
+	ast.NotEqual:       precCompare,
+	ast.LessThan:       precCompare,
+	ast.GreaterThan:    precCompare,
+	ast.LessOrEqual:    precCompare,
+	ast.GreaterOrEqual: precCompare,
+	ast.Add:            precAdd,
+	ast.Minus:          precAdd,
+	ast.Multiply:       precMultiply,
+	ast.Divide:         precMultiply,
+	ast.Modulo:         precMultiply,
+}
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.BinaryOperation:
+		return binaryPrecedence[e.Operator]
+	case *ast.UnaryOperation:
+		return precUnary
+	default:
+		return precPostfix
+	}
+}
+
+func (p *printer) statements(list []ast.Statement) {
+	for _, stmt := range list {
+		if implicit(stmt) {
+			continue
+		}
+		next := p.peek()
+		p.flush(next)
+		p.gap(next.Line)
+		p.statement(stmt)
+		p.newline()
+	}
+}
+
+// implicit reports whether stmt was added by the parser rather than written,
+// like the return closing every function body.
+func implicit(stmt ast.Statement) bool {
+	return stmt.Position().Line == 0
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.Declare:
+		p.token("var")
+		p.write(" ")
+		p.token(s.Name)
+		p.assignValue(s.Value)
+	case *ast.Assign:
+		p.token(s.Target)
+		p.assignValue(s.Value)
+	case *ast.SetIndex:
+		p.expr(s.Object, precPostfix)
+		p.token("[")
+		p.expr(s.Index, 0)
+		p.token("]")
+		p.assignValue(s.Value)
+	case *ast.SetAttr:
+		p.expr(s.Object, precPostfix)
+		p.token(".")
+		p.token(s.Property)
+		p.assignValue(s.Value)
+	case *ast.IfElse:
+		p.ifElse(s)
+	case *ast.While:
+		p.token("while")
+		p.write(" ")
+		p.expr(s.Condition, 0)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.For:
+		p.token("for")
+		p.write(" ")
+		p.token(s.Variable)
+		p.write(" ")
+		p.token("in")
+		p.write(" ")
+		p.expr(s.Iterator, 0)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.Break:
+		p.token("break")
+	case *ast.Continue:
+		p.token("continue")
+	case *ast.FunctionDefine:
+		p.function(s)
+	case *ast.TypeDefine:
+		p.typeDefine(s)
+	case *ast.Return:
+		p.token("return")
+		// A bare return carries a nil literal the parser made up.
+		if lit, ok := s.Value.(*ast.Literal); !ok || lit.Pos.Line != 0 {
+			p.write(" ")
+			p.expr(s.Value, 0)
+		}
+	case *ast.Raise:
+		p.token("raise")
+		p.write(" ")
+		p.expr(s.Value, 0)
+	case *ast.TryCatch:
+		p.token("try")
+		p.write(" ")
+		p.block(s.TryBody)
+		p.write(" ")
+		p.token("catch")
+		p.write(" ")
+		p.token(s.CatchVar)
+		p.write(" ")
+		p.block(s.CatchBody)
+	case *ast.Export:
+		p.token("export")
+		p.write(" ")
+		p.token(s.Name)
+	case *ast.Import:
+		p.token("import")
+		p.write(" ")
+		p.token("")
+	case ast.Expression:
+		p.expr(s, 0)
+	default:
+		panic("format: internal error: unknown statement")
+	}
+}
+
+func (p *printer) assignValue(value ast.Expression) {
+	p.write(" ")
+	p.token("=")
+	p.write(" ")
+	p.expr(value, 0)
+}
+
+func (p *printer) ifElse(s *ast.IfElse) {
+	p.token("if")
+	p.write(" ")
+	p.expr(s.Condition, 0)
+	p.write(" ")
+	p.block(s.IfBody)
+	// The AST cannot tell an empty else block from none, nor `else if` from
+	// an else block holding only an if, so the source decides.
+	if p.peekLit() != "else" {
+		return
+	}
+	p.write(" ")
+	p.token("else")
+	p.write(" ")
+	if p.peekLit() == "if" {
+		p.ifElse(s.ElseBody[0].(*ast.IfElse))
+		return
+	}
+	p.block(s.ElseBody)
+}
+
+// block prints a braced body: on one line when it is empty or, if the source
+// had it that way, holds a single simple statement; otherwise one statement
+// per line, indented.
+func (p *printer) block(body []ast.Statement) {
+	lbrace := p.token("{")
+	rbrace := p.closing()
+	body = written(body)
+	if !p.hasComment(lbrace.Pos, rbrace.Pos) {
+		if len(body) == 0 {
+			p.token("}")
+			return
+		}
+		if len(body) == 1 && simple(body[0]) && rbrace.Pos.Line == lbrace.Pos.Line {
+			p.write(" ")
+			p.statement(body[0])
+			p.write(" ")
+			p.token("}")
+			return
+		}
+	}
+	p.newline()
+	p.indent++
+	p.first = true
+	p.statements(body)
+	p.flush(rbrace.Pos)
+	p.indent--
+	p.first = false
+	p.token("}")
+}
+
+// simple reports whether stmt has no body of its own.
+func simple(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.IfElse, *ast.While, *ast.For, *ast.TryCatch, *ast.FunctionDefine, *ast.TypeDefine:
+		return false
+	}
+	return true
+}
+
+func written(body []ast.Statement) []ast.Statement {
+	if n := len(body); n > 0 && implicit(body[n-1]) {
+		return body[:n-1]
+	}
+	return body
+}
+
+func (p *printer) function(s *ast.FunctionDefine) {
+	p.token("func")
+	p.write(" ")
+	p.token(s.Name)
+	p.parameters(s.Parameters)
+	p.write(" ")
+	p.block(s.Body)
+}
+
+func (p *printer) parameters(params []*ast.Parameter) {
+	p.list("(", len(params), func(i int) {
+		param := params[i]
+		switch {
+		case param.VarArgs:
+			p.token("*")
+		case param.KwArgs:
+			p.token("**")
+		}
+		p.token(param.Name)
+		if param.Default != nil {
+			p.token("=")
+			p.expr(param.Default, 0)
+		}
+	})
+}
+
+func (p *printer) typeDefine(s *ast.TypeDefine) {
+	p.token("type")
+	p.write(" ")
+	p.token(s.Name)
+	p.list("(", len(s.Fields), func(i int) {
+		field := s.Fields[i]
+		p.token(field.Name)
+		if field.DefaultValue != nil {
+			p.token("=")
+			p.expr(field.DefaultValue, 0)
+		}
+	})
+	p.write(" ")
+	p.token("{")
+	rbrace := p.closing()
+	if !p.hasComment(p.tokens[p.tok-1].Pos, rbrace.Pos) && len(s.Methods) == 0 {
+		p.token("}")
+		return
+	}
+	p.newline()
+	p.indent++
+	p.first = true
+	for _, method := range s.Methods {
+		next := p.peek()
+		p.flush(next)
+		p.gap(next.Line)
+		p.function(method)
+		p.newline()
+	}
+	p.flush(rbrace.Pos)
+	p.indent--
+	p.first = false
+	p.token("}")
+}
+
+// list prints the n comma-separated items of a bracketed list, the opening
+// bracket included. They stay on one line unless the source started the
+// first item on a new line or put a comment among them; then each item gets
+// a line of its own.
+func (p *printer) list(open string, n int, item func(i int)) {
+	p.token(open)
+	lbracket := p.tokens[p.tok-1]
+	rbracket := p.closing()
+	close := string(rbracket.Lit)
+	if !p.hasComment(lbracket.Pos, rbracket.Pos) && (n == 0 || p.peek().Line == lbracket.Pos.Line) {
+		for i := 0; i < n; i++ {
+			item(i)
+			if i < n-1 {
+				p.token(",")
+				p.write(" ")
+			}
+		}
+		p.token(close)
+		return
+	}
+	p.newline()
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.first = true
+		p.flush(p.peek())
+		p.first = false
+		item(i)
+		if i < n-1 {
+			p.token(",")
+		}
+		p.newline()
+	}
+	p.first = true
+	p.flush(rbracket.Pos)
+	p.first = false
+	p.indent--
+	p.token(close)
+}
+
+func (p *printer) arguments(args []ast.CallArgument) {
+	p.list("(", len(args), func(i int) {
+		arg := args[i]
+		switch arg.Kind {
+		case ast.CallArgumentStarred:
+			p.token("*")
+		case ast.CallArgumentKeywordUnpack:
+			p.token("**")
+		case ast.CallArgumentKeyword:
+			p.token(arg.Name)
+			p.token("=")
+		}
+		p.expr(arg.Expr, 0)
+	})
+}
+
+// expr prints e, parenthesized if the source grouped it or if it binds more
+// loosely than its position requires.
+func (p *printer) expr(e ast.Expression, min int) {
+	if p.grouped(e) {
+		p.token("(")
+		p.expr(e, 0)
+		p.token(")")
+		return
+	}
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e, 0)
+		p.write(")")
+		return
+	}
+	switch e := e.(type) {
+	case *ast.BinaryOperation:
+		// Operators associate to the left, and comparisons not at all.
+		prec := precedence(e)
+		left := prec
+		if prec == precCompare {
+			left++
+		}
+		p.expr(e.LHS, left)
+		p.write(" ")
+		p.token(e.Operator)
+		p.write(" ")
+		p.expr(e.RHS, prec+1)
+	case *ast.UnaryOperation:
+		p.token(e.Operator)
+		p.expr(e.Operand, precUnary)
+	case *ast.CallExpression:
+		p.expr(e.Callee, precPostfix)
+		p.arguments(e.Args)
+	case *ast.IndexExpression:
+		p.expr(e.Object, precPostfix)
+		p.token("[")
+		p.expr(e.Index, 0)
+		p.token("]")
+	case *ast.MemberExpression:
+		p.expr(e.Object, precPostfix)
+		p.token(".")
+		p.token(e.Property)
+	case *ast.Identifier:
+		p.token(e.Name)
+	case *ast.Literal:
+		p.token("")
+	case *ast.ListLiteral:
+		p.list("[", len(e.Elements), func(i int) {
+			p.expr(e.Elements[i], 0)
+		})
+	case *ast.DictLiteral:
+		p.list("{", len(e.Elements), func(i int) {
+			p.expr(e.Elements[i].Key, 0)
+			p.token(":")
+			p.write(" ")
+			p.expr(e.Elements[i].Value, 0)
+		})
+	case *ast.FunctionLiteral:
+		p.token("func")
+		p.parameters(e.Parameters)
+		p.write(" ")
+		p.block(e.Body)
+	default:
+		panic("format: internal error: unknown expression")
+	}
+}
+
+// grouped reports whether the next source token is a parenthesis around e.
+// An expression shares its first token with its leftmost operand, so the
+// parenthesis belongs to e only if e also ends inside it.
+func (p *printer) grouped(e ast.Expression) bool {
+	if p.tok >= len(p.tokens) || string(p.tokens[p.tok].Lit) != "(" {
+		return false
+	}
+	return e.EndPosition().Offset <= p.matching(p.tok).Pos.Offset
+}
@@ -0,0 +1,39 @@
+// Package framing reads and writes the Content-Length framed messages of the
+// base protocol the Language Server Protocol and the Debug Adapter Protocol
+// share: headers, a blank line, and a body of Content-Length bytes.
+package framing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// Read reads the body of one message. It returns io.EOF, unwrapped, when r
+// ends before a message starts.
+func Read(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("missing or invalid Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return body, nil
+}
+
+// Write writes body as one message.
+func Write(w io.Writer, body []byte) error {
+	_, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
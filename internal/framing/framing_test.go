@@ -0,0 +1,49 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"seq":1}`, "", `{"text":"a\r\n\r\nb"}`} {
+		if err := Write(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, want := range []string{`{"seq":1}`, "", `{"text":"a\r\n\r\nb"}`} {
+		got, err := Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Read = %q, want %q", got, want)
+		}
+	}
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("Read at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Content-Type: json\r\n\r\n{}", "missing or invalid Content-Length"},
+		{"Content-Length: -1\r\n\r\n", "missing or invalid Content-Length"},
+		{"Content-Length: 10\r\n\r\n{}", "truncated message"},
+		{"no colon\r\n\r\n", "malformed header"},
+	}
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.in)))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Read(%q) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
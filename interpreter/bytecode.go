@@ -0,0 +1,161 @@
+package interpreter
+
+import (
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/token"
+)
+
+// opcode is one instruction of the bytecode VM. The VM is a stack machine:
+// instructions pop their operands off a frame's operand stack and push their
+// results. Statements leave the stack as they found it, empty.
+type opcode uint8
+
+const (
+	opConst opcode = iota // push consts[a]
+
+	opLoadLocal    // push locals[a]
+	opStoreLocal   // pop into locals[a]
+	opLoadCell     // push the value of cells[a]
+	opStoreCell    // pop into the value of cells[a]
+	opNewCell      // pop into a new cell, cells[a]
+	opMakeCell     // make cells[a] a new, empty cell
+	opLoadUpvalue  // push the value of upvalues[a]
+	opStoreUpvalue // pop into the value of upvalues[a]
+	opLoadGlobal   // push the global a, or the built-in of its name
+	opStoreGlobal  // pop into the global a
+	opPop          // pop and discard
+
+	opList    // pop the top a values, push a list of them
+	opDict    // push an empty dict
+	opDictSet // pop a value and a key into the dict under them
+
+	opAdd
+	opMinus
+	opMultiply
+	opDivide
+	opModulo
+	opEqual
+	opNotEqual
+	opLessThan
+	opGreaterThan
+	opLessOrEqual
+	opGreaterOrEqual
+	opNot
+	opPositive
+	opNegate
+	opToBool // replace the top with its truthiness
+
+	opJump        // continue at a
+	opJumpIfFalse // pop; continue at a when it is falsy
+	opJumpIfTrue  // pop; continue at a when it is truthy
+
+	opIndex    // pop an index and an object, push object[index]
+	opGetAttr  // replace the top with its attribute names[a]
+	opSetIndex // pop a value, an index and an object; object[index] = value
+	opSetAttr  // pop a value and an object; set its attribute names[a]
+
+	opClosure // push a function of protos[a] closing over this frame
+
+	opCall       // pop a positional arguments and the callee under them, push the result
+	opArgs       // start the arguments of a call on the frame's call stack
+	opArg        // pop a positional argument into them
+	opArgStar    // pop an iterable to spread into them
+	opArgKeyword // pop a keyword argument names[a] into them
+	opArgUnpack  // pop a dict to unpack into their keyword arguments
+	opCallArgs   // pop a callee, call it with the arguments started last
+
+	opReturn // pop the result of the call
+	opRaise  // pop a value and raise it
+
+	opIter    // pop an iterable into cursors[a]
+	opForNext // push the next item of cursors[a], or continue at b when there is none
+
+	opTry    // catch errors at a until the matching opPopTry
+	opPopTry // drop the innermost catch
+
+	opDefineType // define the type of types[a] as a global
+	opTrace      // record the statement starting here for coverage and the profiler
+)
+
+// instruction is an opcode with its operands, whose meaning depends on it.
+// Only opForNext uses b.
+type instruction struct {
+	op   opcode
+	a, b int32
+}
+
+// upvalue says where a new closure finds one of the cells it captures: the
+// cells of the frame creating it, or that frame's own upvalues.
+type upvalue struct {
+	local bool
+	index int
+}
+
+// proto is a compiled function body: a Goblin function, a function literal, a
+// default value or a module's top level. A closure pairs it with the cells it
+// captured.
+type proto struct {
+	name   string
+	module string
+	pos    token.Pos
+	doc    string
+
+	code []instruction
+	// positions holds, for each instruction, the position of the statement
+	// it belongs to, which a traceback frame points at when it fails.
+	positions []token.Pos
+	consts    []object.Object
+	names     []string
+	protos    []*proto
+	types     []*typeCode
+
+	// params are the fixed parameters, followed by the rest and keyword
+	// parameters when varArgs and kwArgs are set; they take locals 0 to
+	// len(params)+1 in that order. paramCells copies a parameter a closure
+	// captures into its cell.
+	params          []string
+	varArgs, kwArgs string
+	paramCells      []paramCell
+	// defaults are parallel to params: the default values, compiled in the
+	// scope defining the function, or nil for required parameters.
+	defaults []*proto
+
+	upvalues []upvalue
+	locals   int
+	cells    int
+	cursors  int
+	stack    int
+
+	// thunk marks a default value, whose errors belong to the call binding
+	// it and get no traceback frame of their own.
+	thunk bool
+	frame object.Frame
+}
+
+type paramCell struct {
+	local, cell int
+}
+
+// typeCode is a compiled type definition: its field defaults and methods,
+// which capture self from a scope of their own.
+type typeCode struct {
+	def      *ast.TypeDefine
+	global   int
+	defaults []*proto
+	methods  map[string]*proto
+}
+
+// moduleCode is a compiled module: its top level, and the definitions it
+// hoists.
+type moduleCode struct {
+	main    *proto
+	imports []*ast.Import
+	// globals numbers the module's globals, as the instructions that load
+	// and store them refer to them.
+	globals *globalSlots
+	// functions are the indexes in main.protos of the module-level
+	// functions, and types those in main.types of its types.
+	functions []int
+	types     []int
+}
@@ -0,0 +1,206 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/lexer"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/semantic"
+)
+
+// runBackend runs the program at path on the tree walker, or on the bytecode
+// VM when bytecode is set, and returns what it printed and its traceback.
+func runBackend(t *testing.T, path string, bytecode bool) (stdout, trace string) {
+	t.Helper()
+	l, err := lexer.NewLexerFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := parser.NewParser().Parse(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := node.(*ast.Module)
+	if err := semantic.CheckModule(mod); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := RunWithOptions(mod, path, Options{Stdout: &out, Bytecode: bytecode}); err != nil {
+		trace = fmt.Sprintf("%+v", err)
+	}
+	return out.String(), trace
+}
+
+func TestBytecodeMatchesTreeWalker(t *testing.T) {
+	dir := t.TempDir()
+	lib := `var calls = 0
+
+func twice(f, x) {
+    calls = calls + 1
+    return f(f(x))
+}
+
+func fail() {
+    return [1, 2][5]
+}
+
+export calls
+export twice
+export fail
+`
+	if err := os.WriteFile(filepath.Join(dir, "lib.goblin"), []byte(lib), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"closures", `var fs = []
+for i in range(0, 3) {
+    fs.push(func() { return i * 10 })
+}
+for f in fs {
+    print(f())
+}
+func counter() {
+    var n = 0
+    return func() {
+        n = n + 1
+        return n
+    }
+}
+var next = counter()
+next()
+print(next(), next())
+`},
+		{"try in loops", `var i = 0
+while i < 6 {
+    i = i + 1
+    try {
+        if i == 2 {
+            continue
+        }
+        if i == 5 {
+            break
+        }
+        print(i, 12 / (i - 3))
+    } catch e {
+        print("caught", e.message)
+    }
+}
+print("after", i)
+`},
+		{"types", `var fallback = 7
+type Point(x, y = fallback) {
+    func sum(self) {
+        return self.x + self.y
+    }
+    func scaled(self, k = 2) {
+        return Point(self.x * k, self.y * k)
+    }
+}
+var p = Point(1)
+print(p.x, p.y, p.sum(), p.scaled().sum(), p.scaled(k = 3).y)
+var sum = p.sum
+print(sum())
+`},
+		{"arguments", `func mix(a, b = 10, *rest, **options) {
+    print(a, b, rest, options.size())
+    return a + b
+}
+mix(1)
+mix(1, 2, 3, 4)
+mix(1, b = 5, color = "red")
+mix(*[1, 2, 3])
+mix(1, **{"size": 4})
+try {
+    mix()
+} catch e {
+    print(e.message)
+}
+`},
+		{"recursion", `func fib(n) {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+func outer(n) {
+    func inner(k) {
+        if k == 0 {
+            return 0
+        }
+        return n + inner(k - 1)
+    }
+    return inner(n)
+}
+print(fib(15), outer(4))
+`},
+		{"logic", `var xs = [0, 1, "", "a", nil, true]
+for x in xs {
+    print(x && "yes", x || "no", !x)
+}
+var d = {"a": 1}
+d["b"] = d["a"] + 1
+print(d["b"], -d["a"], 7 % 3, 1 <= 2, "a" < "b")
+`},
+		{"import", `import "./lib"
+print(lib.twice(func(x) { return x * 3 }, 2), lib.calls)
+lib.fail()
+`},
+		{"error in function", `func divide(a, b) {
+    var q = a / b
+    return q
+}
+func run() {
+    print(divide(4, 2))
+    return divide(1, 0)
+}
+run()
+`},
+		{"error in default", `func f(x = 1 / 0) {
+    return x
+}
+print(f(1))
+f()
+`},
+		{"raise", `func check(n) {
+    if n > 2 {
+        raise ValueError.wrap("too big")
+    }
+    return n
+}
+try {
+    check(3)
+} catch e {
+    print(e.is(ValueError), e.message)
+}
+check(4)
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".goblin")
+			if err := os.WriteFile(path, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			treeOut, treeTrace := runBackend(t, path, false)
+			vmOut, vmTrace := runBackend(t, path, true)
+			if vmOut != treeOut {
+				t.Errorf("stdout differs\nVM:\n%s\ntree walker:\n%s", vmOut, treeOut)
+			}
+			if vmTrace != treeTrace {
+				t.Errorf("traceback differs\nVM:\n%s\ntree walker:\n%s", vmTrace, treeTrace)
+			}
+			if treeOut == "" && treeTrace == "" {
+				t.Error("the program did nothing")
+			}
+		})
+	}
+}
@@ -0,0 +1,684 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/token"
+)
+
+// compiler translates a module's AST into bytecode. Names resolve as the
+// module compiles, by the scoping rules the tree walker follows at run time:
+// a block's variables take slots in its function's frame, the variables of
+// enclosing functions are upvalues, and the module's top level and whatever
+// is left are globals, numbered across the module. A global that is never
+// defined is looked up among the built-ins by name when it runs.
+//
+// A variable a closure captures lives in a cell instead of a slot, so the
+// closure and the frame share it. Which ones those are is only known once
+// the closures are compiled, after the variables, so the compiler runs over
+// a module twice: the first pass finds them and the second emits the code.
+type compiler struct {
+	// captured holds the declarations, keyed by their AST node, of the
+	// variables closures capture.
+	captured map[any]bool
+	// trace emits opTrace before each statement, for coverage and the
+	// profiler.
+	trace bool
+	err   error
+
+	// functions and types are the module's hoisted definitions.
+	functions, types []int
+	globals          *globalSlots
+}
+
+// globalSlots numbers the globals of a module by name.
+type globalSlots struct {
+	index map[string]int
+	names []string
+}
+
+func (g *globalSlots) slot(name string) int {
+	if i, ok := g.index[name]; ok {
+		return i
+	}
+	g.names = append(g.names, name)
+	g.index[name] = len(g.names) - 1
+	return len(g.names) - 1
+}
+
+type varKind int
+
+const (
+	varGlobal varKind = iota
+	varLocal
+	varCell
+	varUpvalue
+)
+
+// variable is a resolved name: a global, or the index of a slot, a cell or
+// an upvalue. decl identifies the declaration of a variable in a frame.
+type variable struct {
+	kind  varKind
+	index int
+	decl  any
+}
+
+// funcState is the compiler's state for the function it compiles.
+type funcState struct {
+	parent *funcState
+	p      *proto
+	// blocks are the scopes open in the function, innermost last. The first
+	// holds the parameters, or for a module its globals.
+	blocks   []map[string]variable
+	module   bool
+	upvalues map[any]int
+	names    map[string]int
+	globals  *globalSlots
+	loops    []*loop
+	// tries counts the try blocks open, and depth the values on the
+	// operand stack, at the instruction being emitted.
+	tries int
+	depth int
+	// pos is the position of the statement being compiled.
+	pos token.Pos
+}
+
+// loop collects the jumps of the break statements of a loop, to patch once
+// its end is known.
+type loop struct {
+	start  int
+	breaks []int
+	tries  int
+}
+
+// compileModule compiles a module whose source is at path, with opTrace
+// instructions when trace is set.
+func compileModule(mod *ast.Module, path string, trace bool) (*moduleCode, error) {
+	c := &compiler{captured: map[any]bool{}, trace: trace}
+	c.module(mod, path)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.module(mod, path), c.err
+}
+
+func (c *compiler) module(mod *ast.Module, path string) *moduleCode {
+	c.functions, c.types = nil, nil
+	c.globals = &globalSlots{index: map[string]int{}}
+	fs := c.newFunc(nil, "<module>", modulePosition(mod))
+	fs.p.module = moduleName(path)
+	fs.module = true
+	code := &moduleCode{main: fs.p}
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.Import:
+			code.imports = append(code.imports, s)
+			c.declare(fs, s.Name, s)
+			fs.globals.slot(s.Name)
+		case *ast.FunctionDefine:
+			c.declare(fs, s.Name, s)
+			fs.globals.slot(s.Name)
+		case *ast.TypeDefine:
+			c.declare(fs, s.Name, s)
+		}
+	}
+	c.statements(fs, mod.Body)
+	fs.emit(opConst, fs.constant(object.Nil))
+	fs.emit(opReturn, 0)
+	code.functions, code.types = c.functions, c.types
+	code.globals = c.globals
+	return code
+}
+
+func (c *compiler) fail(format string, args ...any) {
+	if c.err == nil {
+		c.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (c *compiler) newFunc(parent *funcState, name string, pos token.Pos) *funcState {
+	module := ""
+	if src, ok := pos.Context.(token.Sourcer); ok && src != nil {
+		module = moduleName(src.Source())
+	}
+	return &funcState{
+		parent:   parent,
+		p:        &proto{name: name, module: module, pos: pos, frame: stackFrame(module, name, pos)},
+		blocks:   []map[string]variable{{}},
+		upvalues: map[any]int{},
+		names:    map[string]int{},
+		globals:  c.globals,
+	}
+}
+
+// emit appends an instruction and returns its index.
+func (fs *funcState) emit(op opcode, a int) int {
+	fs.p.code = append(fs.p.code, instruction{op: op, a: int32(a)})
+	fs.p.positions = append(fs.p.positions, fs.pos)
+	fs.push(stackEffect(op, a))
+	return len(fs.p.code) - 1
+}
+
+// push records n more values on the operand stack, or fewer when n is
+// negative.
+func (fs *funcState) push(n int) {
+	fs.depth += n
+	if fs.depth > fs.p.stack {
+		fs.p.stack = fs.depth
+	}
+}
+
+// patch makes the jump at index continue at the next instruction emitted.
+func (fs *funcState) patch(at int) {
+	fs.p.code[at].a = int32(len(fs.p.code))
+}
+
+func stackEffect(op opcode, a int) int {
+	switch op {
+	case opConst, opLoadLocal, opLoadCell, opLoadUpvalue, opLoadGlobal, opDict, opClosure, opForNext:
+		return 1
+	case opStoreLocal, opStoreCell, opNewCell, opStoreUpvalue, opStoreGlobal, opPop,
+		opAdd, opMinus, opMultiply, opDivide, opModulo,
+		opEqual, opNotEqual, opLessThan, opGreaterThan, opLessOrEqual, opGreaterOrEqual,
+		opJumpIfFalse, opJumpIfTrue, opIndex,
+		opArg, opArgStar, opArgKeyword, opArgUnpack, opReturn, opRaise, opIter:
+		return -1
+	case opDictSet, opSetAttr:
+		return -2
+	case opSetIndex:
+		return -3
+	case opList:
+		return 1 - a
+	case opCall:
+		return -a
+	}
+	return 0
+}
+
+func (fs *funcState) constant(v object.Object) int {
+	fs.p.consts = append(fs.p.consts, v)
+	return len(fs.p.consts) - 1
+}
+
+func (fs *funcState) name(name string) int {
+	if i, ok := fs.names[name]; ok {
+		return i
+	}
+	fs.p.names = append(fs.p.names, name)
+	fs.names[name] = len(fs.p.names) - 1
+	return len(fs.p.names) - 1
+}
+
+// declare introduces a variable in the innermost block of fs.
+func (c *compiler) declare(fs *funcState, name string, decl any) variable {
+	var v variable
+	switch {
+	case fs.module && len(fs.blocks) == 1:
+		v = variable{kind: varGlobal, decl: decl}
+	case c.captured[decl]:
+		v = variable{kind: varCell, index: fs.p.cells, decl: decl}
+		fs.p.cells++
+	default:
+		v = variable{kind: varLocal, index: fs.p.locals, decl: decl}
+		fs.p.locals++
+	}
+	fs.blocks[len(fs.blocks)-1][name] = v
+	return v
+}
+
+// resolve finds the variable name refers to in fs, capturing it from an
+// enclosing function when it is declared there.
+func (c *compiler) resolve(fs *funcState, name string) variable {
+	for i := len(fs.blocks) - 1; i >= 0; i-- {
+		if v, ok := fs.blocks[i][name]; ok {
+			return v
+		}
+	}
+	if fs.parent == nil {
+		return variable{kind: varGlobal}
+	}
+	v := c.resolve(fs.parent, name)
+	switch v.kind {
+	case varLocal, varCell:
+		c.captured[v.decl] = true
+		return fs.upvalue(v, true)
+	case varUpvalue:
+		return fs.upvalue(v, false)
+	}
+	return v
+}
+
+func (fs *funcState) upvalue(v variable, local bool) variable {
+	i, ok := fs.upvalues[v.decl]
+	if !ok {
+		i = len(fs.p.upvalues)
+		fs.p.upvalues = append(fs.p.upvalues, upvalue{local: local, index: v.index})
+		fs.upvalues[v.decl] = i
+	}
+	return variable{kind: varUpvalue, index: i, decl: v.decl}
+}
+
+func (fs *funcState) load(v variable, name string) {
+	switch v.kind {
+	case varLocal:
+		fs.emit(opLoadLocal, v.index)
+	case varCell:
+		fs.emit(opLoadCell, v.index)
+	case varUpvalue:
+		fs.emit(opLoadUpvalue, v.index)
+	default:
+		fs.emit(opLoadGlobal, fs.globals.slot(name))
+	}
+}
+
+// store pops the top of the stack into v. define is set when the store
+// declares v, which gives a captured variable a new cell.
+func (fs *funcState) store(v variable, name string, define bool) {
+	switch v.kind {
+	case varLocal:
+		fs.emit(opStoreLocal, v.index)
+	case varCell:
+		if define {
+			fs.emit(opNewCell, v.index)
+		} else {
+			fs.emit(opStoreCell, v.index)
+		}
+	case varUpvalue:
+		fs.emit(opStoreUpvalue, v.index)
+	default:
+		fs.emit(opStoreGlobal, fs.globals.slot(name))
+	}
+}
+
+func (c *compiler) statements(fs *funcState, stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		c.statement(fs, stmt)
+	}
+}
+
+// block compiles stmts in a scope of their own.
+func (c *compiler) block(fs *funcState, stmts []ast.Statement) {
+	fs.blocks = append(fs.blocks, map[string]variable{})
+	c.statements(fs, stmts)
+	fs.blocks = fs.blocks[:len(fs.blocks)-1]
+}
+
+func (c *compiler) statement(fs *funcState, stmt ast.Statement) {
+	outer := fs.pos
+	fs.pos = stmt.Position()
+	defer func() { fs.pos = outer }()
+	if c.trace {
+		fs.emit(opTrace, 0)
+	}
+
+	switch s := stmt.(type) {
+	case *ast.Declare:
+		c.expr(fs, s.Value)
+		fs.store(c.declare(fs, s.Name, s), s.Name, true)
+
+	case *ast.Assign:
+		c.expr(fs, s.Value)
+		fs.store(c.resolve(fs, s.Target), s.Target, false)
+
+	case *ast.SetIndex:
+		c.expr(fs, s.Object)
+		c.expr(fs, s.Index)
+		c.expr(fs, s.Value)
+		fs.emit(opSetIndex, 0)
+
+	case *ast.SetAttr:
+		c.expr(fs, s.Object)
+		c.expr(fs, s.Value)
+		fs.emit(opSetAttr, fs.name(s.Property))
+
+	case *ast.IfElse:
+		c.expr(fs, s.Condition)
+		skip := fs.emit(opJumpIfFalse, 0)
+		c.block(fs, s.IfBody)
+		if s.ElseBody != nil {
+			end := fs.emit(opJump, 0)
+			fs.patch(skip)
+			c.block(fs, s.ElseBody)
+			fs.patch(end)
+		} else {
+			fs.patch(skip)
+		}
+
+	case *ast.While:
+		l := fs.openLoop()
+		c.expr(fs, s.Condition)
+		exit := fs.emit(opJumpIfFalse, 0)
+		c.block(fs, s.Body)
+		fs.emit(opJump, l.start)
+		fs.patch(exit)
+		fs.closeLoop()
+
+	case *ast.For:
+		c.expr(fs, s.Iterator)
+		cursor := fs.p.cursors
+		fs.p.cursors++
+		fs.emit(opIter, cursor)
+		l := fs.openLoop()
+		next := fs.emit(opForNext, cursor)
+		// Like a Go range clause, the iteration binding belongs to the loop,
+		// not the surrounding block, and each iteration has its own.
+		fs.blocks = append(fs.blocks, map[string]variable{})
+		fs.store(c.declare(fs, s.Variable, s), s.Variable, true)
+		c.block(fs, s.Body)
+		fs.blocks = fs.blocks[:len(fs.blocks)-1]
+		fs.emit(opJump, l.start)
+		fs.p.code[next].b = int32(len(fs.p.code))
+		fs.closeLoop()
+
+	case *ast.Break:
+		l := fs.loops[len(fs.loops)-1]
+		fs.leaveTries(l)
+		l.breaks = append(l.breaks, fs.emit(opJump, 0))
+
+	case *ast.Continue:
+		l := fs.loops[len(fs.loops)-1]
+		fs.leaveTries(l)
+		fs.emit(opJump, l.start)
+
+	case *ast.Return:
+		c.expr(fs, s.Value)
+		fs.emit(opReturn, 0)
+
+	case *ast.Raise:
+		c.expr(fs, s.Value)
+		fs.emit(opRaise, 0)
+
+	case *ast.TryCatch:
+		try := fs.emit(opTry, 0)
+		fs.tries++
+		c.block(fs, s.TryBody)
+		fs.tries--
+		fs.emit(opPopTry, 0)
+		end := fs.emit(opJump, 0)
+		// The catch starts with the error on the stack.
+		fs.patch(try)
+		fs.push(1)
+		fs.blocks = append(fs.blocks, map[string]variable{})
+		fs.store(c.declare(fs, s.CatchVar, s), s.CatchVar, true)
+		c.block(fs, s.CatchBody)
+		fs.blocks = fs.blocks[:len(fs.blocks)-1]
+		fs.patch(end)
+
+	case *ast.FunctionDefine:
+		help := doc.FuncHelp(doc.NewFunc(s))
+		if fs.module && len(fs.blocks) == 1 {
+			i := c.function(fs, s.Name, s.Position(), s.Parameters, s.Body, help)
+			c.functions = append(c.functions, i)
+			fs.emit(opClosure, i)
+			fs.store(variable{kind: varGlobal}, s.Name, true)
+			return
+		}
+		// The function is in scope in its own body, to call itself.
+		v := c.declare(fs, s.Name, s)
+		if v.kind == varCell {
+			fs.emit(opMakeCell, v.index)
+		}
+		fs.emit(opClosure, c.function(fs, s.Name, s.Position(), s.Parameters, s.Body, help))
+		fs.store(v, s.Name, false)
+
+	case *ast.TypeDefine:
+		i := c.typeDefine(fs, s)
+		c.types = append(c.types, i)
+		fs.emit(opDefineType, i)
+
+	case *ast.Import, *ast.Export:
+		// Imports are bound, and functions and types hoisted, before the
+		// module runs; exports are read once it has.
+
+	case ast.Expression:
+		c.expr(fs, s)
+		fs.emit(opPop, 0)
+
+	default:
+		c.fail("interpreter: unsupported statement %T", stmt)
+	}
+}
+
+func (fs *funcState) openLoop() *loop {
+	l := &loop{start: len(fs.p.code), tries: fs.tries}
+	fs.loops = append(fs.loops, l)
+	return l
+}
+
+// closeLoop makes the breaks of the innermost loop continue after it.
+func (fs *funcState) closeLoop() {
+	l := fs.loops[len(fs.loops)-1]
+	fs.loops = fs.loops[:len(fs.loops)-1]
+	for _, at := range l.breaks {
+		fs.patch(at)
+	}
+}
+
+// leaveTries drops the catches of the try blocks a break or continue jumps
+// out of.
+func (fs *funcState) leaveTries(l *loop) {
+	for i := l.tries; i < fs.tries; i++ {
+		fs.emit(opPopTry, 0)
+	}
+}
+
+func (c *compiler) expr(fs *funcState, expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.Literal:
+		fs.emit(opConst, fs.constant(e.Value))
+
+	case *ast.Identifier:
+		fs.load(c.resolve(fs, e.Name), e.Name)
+
+	case *ast.ListLiteral:
+		for _, el := range e.Elements {
+			c.expr(fs, el)
+		}
+		fs.emit(opList, len(e.Elements))
+
+	case *ast.DictLiteral:
+		fs.emit(opDict, 0)
+		for _, el := range e.Elements {
+			c.expr(fs, el.Key)
+			c.expr(fs, el.Value)
+			fs.emit(opDictSet, 0)
+		}
+
+	case *ast.BinaryOperation:
+		c.binary(fs, e)
+
+	case *ast.UnaryOperation:
+		c.expr(fs, e.Operand)
+		switch e.Operator {
+		case ast.Not:
+			fs.emit(opNot, 0)
+		case ast.Add:
+			fs.emit(opPositive, 0)
+		case ast.Minus:
+			fs.emit(opNegate, 0)
+		default:
+			c.fail("interpreter: unknown unary operator %q", e.Operator)
+		}
+
+	case *ast.IndexExpression:
+		c.expr(fs, e.Object)
+		c.expr(fs, e.Index)
+		fs.emit(opIndex, 0)
+
+	case *ast.MemberExpression:
+		c.expr(fs, e.Object)
+		fs.emit(opGetAttr, fs.name(e.Property))
+
+	case *ast.FunctionLiteral:
+		fs.emit(opClosure, c.function(fs, "<lambda>", e.Position(), e.Parameters, e.Body, ""))
+
+	case *ast.FunctionCall:
+		fs.load(c.resolve(fs, e.Name), e.Name)
+		c.call(fs, e.Args)
+
+	case *ast.CallExpression:
+		c.expr(fs, e.Callee)
+		c.call(fs, e.Args)
+
+	default:
+		c.fail("interpreter: unsupported expression %T", expr)
+	}
+}
+
+var binaryOps = map[string]opcode{
+	ast.Add:            opAdd,
+	ast.Minus:          opMinus,
+	ast.Multiply:       opMultiply,
+	ast.Divide:         opDivide,
+	ast.Modulo:         opModulo,
+	ast.Equal:          opEqual,
+	ast.NotEqual:       opNotEqual,
+	ast.LessThan:       opLessThan,
+	ast.GreaterThan:    opGreaterThan,
+	ast.LessOrEqual:    opLessOrEqual,
+	ast.GreaterOrEqual: opGreaterOrEqual,
+}
+
+func (c *compiler) binary(fs *funcState, e *ast.BinaryOperation) {
+	if e.Operator == ast.And || e.Operator == ast.Or {
+		// The right operand only runs when the left one does not decide
+		// the result, which is a Bool either way.
+		c.expr(fs, e.LHS)
+		jump := opJumpIfFalse
+		if e.Operator == ast.Or {
+			jump = opJumpIfTrue
+		}
+		short := fs.emit(jump, 0)
+		c.expr(fs, e.RHS)
+		fs.emit(opToBool, 0)
+		end := fs.emit(opJump, 0)
+		fs.patch(short)
+		fs.emit(opConst, fs.constant(object.Bool(e.Operator == ast.Or)))
+		fs.push(-1)
+		fs.patch(end)
+		return
+	}
+	op, ok := binaryOps[e.Operator]
+	if !ok {
+		c.fail("interpreter: unknown operator %q", e.Operator)
+		return
+	}
+	c.expr(fs, e.LHS)
+	c.expr(fs, e.RHS)
+	fs.emit(op, 0)
+}
+
+// call compiles the arguments of a call whose callee is on the stack, and
+// the call.
+func (c *compiler) call(fs *funcState, args []ast.CallArgument) {
+	positional := true
+	for _, arg := range args {
+		if arg.Kind != ast.CallArgumentPositional {
+			positional = false
+		}
+	}
+	if positional {
+		for _, arg := range args {
+			c.expr(fs, arg.Expr)
+		}
+		fs.emit(opCall, len(args))
+		return
+	}
+
+	fs.emit(opArgs, 0)
+	for _, arg := range args {
+		c.expr(fs, arg.Expr)
+		switch arg.Kind {
+		case ast.CallArgumentPositional:
+			fs.emit(opArg, 0)
+		case ast.CallArgumentStarred:
+			fs.emit(opArgStar, 0)
+		case ast.CallArgumentKeyword:
+			fs.emit(opArgKeyword, fs.name(arg.Name))
+		case ast.CallArgumentKeywordUnpack:
+			fs.emit(opArgUnpack, 0)
+		default:
+			c.fail("interpreter: unsupported call argument kind %v", arg.Kind)
+		}
+	}
+	fs.emit(opCallArgs, 0)
+}
+
+// function compiles a function defined in fs and returns its index in the
+// protos of fs.
+func (c *compiler) function(fs *funcState, name string, pos token.Pos, params []*ast.Parameter, body []ast.Statement, help string) int {
+	child := c.newFunc(fs, name, pos)
+	p := child.p
+	p.doc = help
+	var fixed, rest []*ast.Parameter
+	for _, param := range params {
+		switch {
+		case param.VarArgs:
+			p.varArgs = param.Name
+			rest = append(rest, param)
+		case param.KwArgs:
+			p.kwArgs = param.Name
+			rest = append(rest, param)
+		default:
+			p.params = append(p.params, param.Name)
+			fixed = append(fixed, param)
+			// Defaults are evaluated per call in the defining scope.
+			var d *proto
+			if param.HasDefault() {
+				d = c.thunk(fs, param.Default)
+			}
+			p.defaults = append(p.defaults, d)
+		}
+	}
+	// Arguments are bound into the first slots, in this order; those a
+	// closure captures move to cells as the call starts.
+	for i, param := range append(fixed, rest...) {
+		p.locals++
+		v := variable{kind: varLocal, index: i, decl: param}
+		if c.captured[param] {
+			v = variable{kind: varCell, index: p.cells, decl: param}
+			p.paramCells = append(p.paramCells, paramCell{local: i, cell: p.cells})
+			p.cells++
+		}
+		child.blocks[0][param.Name] = v
+	}
+	c.statements(child, body)
+	child.emit(opConst, child.constant(object.Nil))
+	child.emit(opReturn, 0)
+	fs.p.protos = append(fs.p.protos, p)
+	return len(fs.p.protos) - 1
+}
+
+// thunk compiles expr as a function of no parameters that returns its value,
+// a default evaluated in fs.
+func (c *compiler) thunk(fs *funcState, expr ast.Expression) *proto {
+	child := c.newFunc(fs, "<default>", expr.Position())
+	child.p.thunk = true
+	child.pos = expr.Position()
+	c.expr(child, expr)
+	child.emit(opReturn, 0)
+	return child.p
+}
+
+// typeDefine compiles a type defined in fs and returns its index in the
+// types of fs. Its methods are closures of a scope that holds self alone.
+func (c *compiler) typeDefine(fs *funcState, def *ast.TypeDefine) int {
+	t := &typeCode{def: def, global: fs.globals.slot(def.Name), defaults: make([]*proto, len(def.Fields)), methods: make(map[string]*proto, len(def.Methods))}
+	for i, f := range def.Fields {
+		if f.HasDefault() {
+			t.defaults[i] = c.thunk(fs, f.DefaultValue)
+		}
+	}
+	self := c.newFunc(fs, def.Name, def.Position())
+	self.blocks[0]["self"] = variable{kind: varCell, index: 0, decl: self}
+	self.p.cells = 1
+	for _, m := range def.Methods {
+		i := c.function(self, def.Name+"."+m.Name, m.Position(), m.Parameters[1:], m.Body, "")
+		t.methods[m.Name] = self.p.protos[i]
+	}
+	fs.p.types = append(fs.p.types, t)
+	return len(fs.p.types) - 1
+}
@@ -0,0 +1,38 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/aisk/goblin/object"
+)
+
+// TestSharedCollectionsFromGoblins drives one List and one Dict from many
+// goblins through the interpreter; run it with -race.
+func TestSharedCollectionsFromGoblins(t *testing.T) {
+	env := runScopeProgram(t, `
+var items = []
+var counts = {}
+func work(id) {
+    for n in range(0, 100) {
+        items.push(n)
+        counts[Str(id) + ":" + Str(n)] = n
+        for ignored in items.copy() { break }
+        items.pop()
+        items.push(id)
+    }
+}
+var handles = []
+for id in range(0, 8) {
+    handles.push(Goblin(work, id))
+}
+Goblin.all(handles)
+var item_count = items.size()
+var key_count = counts.size()
+`)
+	if got, _ := env.Get("item_count"); got != object.Integer(800) {
+		t.Errorf("item_count = %v, want 800", got)
+	}
+	if got, _ := env.Get("key_count"); got != object.Integer(800) {
+		t.Errorf("key_count = %v, want 800", got)
+	}
+}
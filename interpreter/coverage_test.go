@@ -0,0 +1,80 @@
+package interpreter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/coverage"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/source"
+)
+
+func TestCoverageCountsStatements(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.goblin")
+	main := filepath.Join(dir, "main.goblin")
+	files := map[string]string{
+		lib: "func half(x) {\n    if x % 2 == 0 {\n        return x / 2\n    }\n    raise ValueError(\"odd\")\n}\nexport half\n",
+		main: "import \"./lib\"\nvar total = 0\nfor i in [2, 4, 6] { total = total + lib.half(i) }\n" +
+			"if total > 100 {\n    print(total)\n}\nvar f = func() {\n    return 1\n}\n",
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := source.NewLexerFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := parser.NewParser().Parse(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mod := st.(*ast.Module)
+
+	want := map[string][]coverage.Line{
+		lib: {
+			{Number: 1, Statements: 1, Hits: 1},
+			{Number: 2, Statements: 1, Hits: 3},
+			{Number: 3, Statements: 1, Hits: 3},
+			{Number: 5, Statements: 1, Hits: 0},
+			{Number: 7, Statements: 1, Hits: 1},
+		},
+		main: {
+			{Number: 1, Statements: 1, Hits: 1},
+			{Number: 2, Statements: 1, Hits: 1},
+			// The loop and its one-line body count the body's runs.
+			{Number: 3, Statements: 2, Hits: 3},
+			{Number: 4, Statements: 1, Hits: 1},
+			{Number: 5, Statements: 1, Hits: 0},
+			{Number: 7, Statements: 1, Hits: 1},
+			{Number: 8, Statements: 1, Hits: 0},
+		},
+	}
+	// Each run records into its own recorder alone, on either backend.
+	var recs []*coverage.Recorder
+	for _, bytecode := range []bool{false, true} {
+		rec := coverage.NewRecorder()
+		recs = append(recs, rec)
+		if err := RunWithOptions(mod, main, Options{Coverage: rec, Bytecode: bytecode}); err != nil {
+			t.Fatal(err)
+		}
+		if err := RunWithOptions(mod, main, Options{Bytecode: bytecode}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, rec := range recs {
+		got := map[string][]coverage.Line{}
+		for _, f := range rec.Profile().Files {
+			got[f.Path] = f.Lines
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("coverage of run %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/source"
+)
+
+// Debugger is notified as the interpreter runs, so `goblin debug` can stop a
+// program and inspect it. Enter and Exit bracket every function and module
+// body, with the same frame tracebacks use; Statement comes before every
+// statement, with the scope it runs in, and may block to hold the program
+// there. The calls for one goroutine come from that goroutine.
+type Debugger interface {
+	Enter(frame object.Frame)
+	Exit()
+	Statement(stmt ast.Statement, env *Environment)
+}
+
+// Parent returns the enclosing scope, or nil for a module's global scope.
+func (e *Environment) Parent() *Environment {
+	return e.parent
+}
+
+// Bindings returns a copy of the names defined in this scope alone, not in
+// the scopes enclosing it.
+func (e *Environment) Bindings() map[string]object.Object {
+	if object.InConcurrentMode() {
+		envMu.RLock()
+		defer envMu.RUnlock()
+	}
+	bindings := make(map[string]object.Object, len(e.vars))
+	for name, v := range e.vars {
+		bindings[name] = v
+	}
+	return bindings
+}
+
+// Names returns the names defined in this scope alone, sorted.
+func (e *Environment) Names() []string {
+	bindings := e.Bindings()
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EvalIn evaluates a source fragment in env the way Session.Eval does: the
+// value of a trailing expression is returned, and a bare expression the
+// statement grammar rejects is evaluated on its own. Statements such as
+// assignments change env. A debugger uses it to evaluate code in a paused
+// frame, so imports, which bind into a module's scope as it loads, are
+// rejected.
+func EvalIn(env *Environment, src string) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, object.NewInternalError("internal error: %v", r)
+		}
+	}()
+
+	st, err := parser.NewParser().Parse(source.NewLexer([]byte(src)))
+	if err != nil {
+		if expr, ok := parseExpression(src); ok {
+			return evalExpr(expr, env)
+		}
+		return nil, err
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return nil, fmt.Errorf("internal error: unexpected AST type")
+	}
+	for _, stmt := range mod.Body {
+		if _, ok := stmt.(*ast.Import); ok {
+			return nil, object.NewImportError("import is not allowed here")
+		}
+	}
+	return evalFragment(mod.Body, env, "debug")
+}
@@ -2,84 +2,34 @@ package interpreter
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/extension"
+	embedExt "github.com/aisk/goblin/extension/embed"
 	execExt "github.com/aisk/goblin/extension/exec"
-	"github.com/aisk/goblin/extension/fs"
-	httpExt "github.com/aisk/goblin/extension/http"
-	mailExt "github.com/aisk/goblin/extension/mail"
-	netipExt "github.com/aisk/goblin/extension/netip"
-	pathExt "github.com/aisk/goblin/extension/path"
-	regexpExt "github.com/aisk/goblin/extension/regexp"
-	timeExt "github.com/aisk/goblin/extension/time"
-	urlExt "github.com/aisk/goblin/extension/url"
+	fsExt "github.com/aisk/goblin/extension/fs"
+	_ "github.com/aisk/goblin/extension/gobind"
+	_ "github.com/aisk/goblin/extension/stdlib"
 	"github.com/aisk/goblin/source"
 	"github.com/aisk/goblin/object"
 	"github.com/aisk/goblin/parser"
 	"github.com/aisk/goblin/semantic"
-	"github.com/aisk/goblin/token"
 )
 
-// builtinModules maps a built-in module name to its executor, mirroring the
-// transpiler's knownModules table. "os" is intentionally absent: the
-// interpreter binds it per run via ExecuteOsWithFrozenArgs so argv is scoped to the
-// script (or REPL) without process-global state.
-var builtinModules = map[string]object.ModuleExecutor{
-	"rand":            extension.ExecuteRand,
-	"math":            extension.ExecuteMath,
-	"base64":          extension.ExecuteBase64,
-	"http":            httpExt.Execute,
-	"fs":              fs.Execute,
-	"mime":            extension.ExecuteMime,
-	"json":            extension.ExecuteJson,
-	"uuid":            extension.ExecuteUUID,
-	"path":            pathExt.Execute,
-	"time":            timeExt.Execute,
-	"exec":            execExt.Execute,
-	"regexp":          regexpExt.Execute,
-	"hex":             extension.ExecuteHex,
-	"sha256":          extension.ExecuteSHA256,
-	"sha512":          extension.ExecuteSHA512,
-	"url":             urlExt.Execute,
-	"csv":             extension.ExecuteCSV,
-	"gzip":            extension.ExecuteGzip,
-	"zlib":            extension.ExecuteZlib,
-	"tar":             extension.ExecuteTar,
-	"zip":             extension.ExecuteZip,
-	"base32":          extension.ExecuteBase32,
-	"ascii85":         extension.ExecuteASCII85,
-	"html":            extension.ExecuteHTML,
-	"quotedprintable": extension.ExecuteQuotedPrintable,
-	"md5":             extension.ExecuteMD5,
-	"sha1":            extension.ExecuteSHA1,
-	"crc32":           extension.ExecuteCRC32,
-	"adler32":         extension.ExecuteAdler32,
-	"flate":           extension.ExecuteFlate,
-	"bzip2":           extension.ExecuteBzip2,
-	"mail":            mailExt.Execute,
-	"hmac":            extension.ExecuteHMAC,
-	"crc64":           extension.ExecuteCRC64,
-	"fnv":             extension.ExecuteFNV,
-	"lzw":             extension.ExecuteLZW,
-	"pem":             extension.ExecutePEM,
-	"netip":           netipExt.Execute,
-	"utf8":            extension.ExecuteUTF8,
-	"unicode":         extension.ExecuteUnicode,
-}
-
 func isPathImport(path string) bool {
 	return source.IsPathImport(path)
 }
 
 // loadInto resolves imports and hoists function/type definitions for a module
-// body into env, so references resolve regardless of source order. argv is the
-// script command line closed over by import "os".
-func loadInto(mod *ast.Module, env *Environment, baseDir string, reg *object.Registry, argv []string) error {
+// body into env, so references resolve regardless of source order. Imports
+// load into the registry of env's machine.
+func loadInto(mod *ast.Module, env *Environment, baseDir string) error {
 	for _, stmt := range mod.Body {
 		if imp, ok := stmt.(*ast.Import); ok {
-			m, err := resolveImport(imp, baseDir, reg, argv)
+			m, err := resolveImport(imp, baseDir, env.m)
 			if err != nil {
 				return err
 			}
@@ -97,33 +47,72 @@ func loadInto(mod *ast.Module, env *Environment, baseDir string, reg *object.Reg
 	return nil
 }
 
-func resolveImport(imp *ast.Import, baseDir string, reg *object.Registry, argv []string) (object.Object, error) {
+// resolveImport loads the module imp names into m's registry: a module of
+// m's own, a .goblin file next to the importing one, or a module registered
+// with object.RegisterModule, when m's sandbox allows it. "os" is bound per
+// run through ExecuteOsWithExit, so argv and exit are scoped to the script
+// (or REPL) without process-global state, "build" to m's constants, "exec"
+// to m's streams when they are not the process's, "fs" to the sandbox's root
+// when it has one, and "embed" per source directory, against which its
+// patterns are matched; in a sandbox its symbolic links may not lead out of
+// that directory.
+func resolveImport(imp *ast.Import, baseDir string, m *machine) (object.Object, error) {
+	reg := m.reg
+	if execute, ok := m.modules[imp.Path]; ok {
+		return reg.Load(imp.Path, execute)
+	}
+	if m.limits != nil {
+		if err := m.limits.checkImport(imp.Path); err != nil {
+			return nil, err
+		}
+	}
 	if isPathImport(imp.Path) {
 		full := filepath.Join(baseDir, imp.Path) + ".goblin"
 		return reg.Load(full, func() (object.Object, error) {
-			return loadModuleFile(full, reg, argv)
+			return loadModuleFile(full, m)
 		})
 	}
-	if imp.Path == "os" {
+	switch {
+	case imp.Path == "os":
+		return reg.Load(imp.Path, func() (object.Object, error) {
+			return extension.ExecuteOsWithExit(m.argv, m.osExit)
+		})
+	case imp.Path == "build" && m.buildConstants != nil:
+		return reg.Load(imp.Path, extension.ExecuteBuildWith(m.buildConstants))
+	case imp.Path == "exec" && m.stdout != nil:
 		return reg.Load(imp.Path, func() (object.Object, error) {
-			return extension.ExecuteOsWithFrozenArgs(argv)
+			return execExt.ExecuteWithStreams(m.stdin, m.stdout, m.stderr)
 		})
+	case imp.Path == "fs" && m.limits != nil && m.limits.sandbox.Root != "":
+		return reg.Load(imp.Path, func() (object.Object, error) {
+			return fsExt.ExecuteRooted(m.limits.sandbox.Root)
+		})
+	case imp.Path == "embed" && m.limits != nil:
+		return reg.Load("embed "+baseDir, func() (object.Object, error) {
+			fsys, err := embedExt.Rooted(baseDir)
+			if err != nil {
+				return nil, err
+			}
+			return embedExt.Executor(fsys, ".")()
+		})
+	case imp.Path == "embed":
+		return reg.Load("embed "+baseDir, embedExt.Executor(os.DirFS(baseDir), "."))
 	}
-	exec, ok := builtinModules[imp.Path]
+	info, ok := object.LookupModule(imp.Path)
 	if !ok {
 		return nil, object.NewImportError("unknown module: %s", imp.Path)
 	}
-	return reg.Load(imp.Path, exec)
+	return reg.Load(imp.Path, info.Execute)
 }
 
-// loadModuleFile interprets a Goblin source file as a module and returns its
-// exported members.
-func loadModuleFile(path string, reg *object.Registry, argv []string) (object.Object, error) {
-	l, err := source.NewLexerFile(path)
+// loadModuleFile interprets a Goblin source file as a module of the program m
+// runs and returns its exported members.
+func loadModuleFile(path string, m *machine) (object.Object, error) {
+	s, err := source.NewScannerFile(path)
 	if err != nil {
 		return nil, object.NewImportError("failed to read module %s: %v", path, err)
 	}
-	st, err := parser.NewParser().Parse(l)
+	st, err := parser.NewParser().Parse(s)
 	if err != nil {
 		return nil, err
 	}
@@ -131,21 +120,18 @@ func loadModuleFile(path string, reg *object.Registry, argv []string) (object.Ob
 	if !ok {
 		return nil, fmt.Errorf("internal error: unexpected AST type in module %s", path)
 	}
+	doc.Attach(mod, s)
 	if err := semantic.CheckModule(mod); err != nil {
 		return nil, err
 	}
 
-	env := NewEnvironment(nil)
-	if err := loadInto(mod, env, filepath.Dir(path), reg, argv); err != nil {
-		return nil, err
+	if m.observing() {
+		m.enterModule(mod, path)
+		defer m.exit()
 	}
-	if err := evalStatements(mod.Body, env); err != nil {
-		var pos token.Pos
-		if len(mod.Body) > 0 {
-			pos = mod.Body[0].Position()
-		}
-		err, pos = takePosition(err, pos)
-		return nil, object.WithFrame(err, stackFrame(moduleName(path), "<module>", pos))
+	env := newGlobal(m)
+	if err := execModule(mod, path, env); err != nil {
+		return nil, err
 	}
 
 	members := make(map[string]object.Object)
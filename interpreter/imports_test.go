@@ -3,27 +3,27 @@ package interpreter
 import (
 	"testing"
 
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
 	"github.com/aisk/goblin/transpiler"
 )
 
-// builtinModules and the transpiler's knownModules must stay in sync: a
-// module registered on only one side imports fine in one backend and raises
-// ImportError in the other. "os" is the single intentional asymmetry — the
-// interpreter binds it per run so argv stays scoped to the script (see the
-// builtinModules comment).
-func TestBuiltinModulesMatchTranspilerKnownModules(t *testing.T) {
-	interpreterNames := map[string]bool{"os": true}
-	for name := range builtinModules {
-		interpreterNames[name] = true
-	}
-
+// Both backends read the modules registered with object.RegisterModule, so
+// every module the transpiler can import must import here too, including os
+// and embed, which the interpreter binds per run and per source directory.
+func TestKnownModulesImport(t *testing.T) {
+	dir := t.TempDir()
 	for _, name := range transpiler.KnownModuleNames() {
-		if !interpreterNames[name] {
-			t.Errorf("module %q is known to the transpiler but missing from the interpreter's builtinModules", name)
+		m, err := resolveImport(&ast.Import{Path: name, Name: name}, dir, newMachine(object.NewRegistry(), nil))
+		if err != nil {
+			t.Errorf("import %q: %v", name, err)
+			continue
+		}
+		if _, ok := m.(*object.Module); !ok {
+			t.Errorf("import %q = %T, want a module", name, m)
 		}
-		delete(interpreterNames, name)
 	}
-	for name := range interpreterNames {
-		t.Errorf("module %q is registered in the interpreter but unknown to the transpiler", name)
+	if _, err := resolveImport(&ast.Import{Path: "no_such_module", Name: "no_such_module"}, dir, newMachine(object.NewRegistry(), nil)); err == nil {
+		t.Error("import of an unregistered module succeeded")
 	}
 }
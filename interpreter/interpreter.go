@@ -7,12 +7,14 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/aisk/goblin/ast"
-	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/object"
 	"github.com/aisk/goblin/token"
 )
@@ -21,6 +23,7 @@ import (
 type Environment struct {
 	vars   map[string]object.Object
 	parent *Environment
+	m      *machine
 }
 
 // envMu guards every Environment's vars map once user code runs concurrently:
@@ -36,7 +39,15 @@ func NewEnvironment(parent *Environment) *Environment {
 	// vars is allocated on first Define. Most block scopes (loop bodies, if
 	// branches) never declare anything, and a loop body allocating a map per
 	// iteration dominates the profile of tight loops.
-	return &Environment{parent: parent}
+	if parent == nil {
+		return &Environment{}
+	}
+	return &Environment{parent: parent, m: parent.m}
+}
+
+// newGlobal creates the global scope of a module of the program m runs.
+func newGlobal(m *machine) *Environment {
+	return &Environment{m: m}
 }
 
 // Get resolves a name, walking up the scope chain.
@@ -115,30 +126,114 @@ func Run(mod *ast.Module, sourcePath string, scriptArgs ...string) (err error) {
 	argv[0] = sourcePath
 	copy(argv[1:], scriptArgs)
 
-	global := NewEnvironment(nil)
-	reg := object.NewRegistry()
+	_, err = runModule(mod, sourcePath, newMachine(object.NewRegistry(), argv))
+	return err
+}
+
+// RunWithOptions interprets a parsed module as Run does, with the streams,
+// argv, sandbox and backend of opts. A nil opts.Argv is sourcePath alone.
+func RunWithOptions(mod *ast.Module, sourcePath string, opts Options) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = object.NewInternalError("internal error: %v", r)
+		}
+	}()
+	if opts.Bytecode && opts.Sandbox != nil {
+		return fmt.Errorf("the bytecode VM does not support sandboxes yet")
+	}
+	if opts.Bytecode && opts.Debugger != nil {
+		return fmt.Errorf("the bytecode VM does not support debuggers yet")
+	}
+
+	argv := opts.Argv
+	if argv == nil {
+		argv = []string{sourcePath}
+	}
+	m := newMachine(object.NewRegistry(), argv)
+	m.setStreams(opts.Stdin, opts.Stdout, opts.Stderr)
+	m.setSandbox(opts.Sandbox)
+	m.bytecode = opts.Bytecode
+	m.setObservers(opts)
+	m.buildConstants = opts.BuildConstants
+	m.atExit = opts.AtExit
+	if m.atExit == nil {
+		m.atExit = func(code int) { os.Exit(code) }
+	}
+	m.start(context.Background())
+	defer m.finish()
+	_, err = runModule(mod, sourcePath, m)
+	return err
+}
+
+// RunFunction runs a module's top level as Run does, with an empty argument
+// list, then calls the module-level function name with no arguments. reg
+// resolves the module's imports, so a caller can supply modules of its own
+// by loading them into it first. The test runner uses it to run each test in
+// a scope of its own.
+func RunFunction(mod *ast.Module, sourcePath, name string, reg *object.Registry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = object.NewInternalError("internal error: %v", r)
+		}
+	}()
+
+	global, err := runModule(mod, sourcePath, newMachine(reg, []string{sourcePath}))
+	if err != nil {
+		return err
+	}
+	fn, ok := global.Get(name)
+	if !ok {
+		return object.NewNameError("undefined identifier: %s", name)
+	}
+	_, err = object.Call(fn, object.CallArgs{})
+	return err
+}
+
+// runModule evaluates a module's top level in a fresh global scope of the
+// program m runs and returns the scope.
+func runModule(mod *ast.Module, sourcePath string, m *machine) (*Environment, error) {
+	global := newGlobal(m)
+	if m.observing() {
+		m.enterModule(mod, sourcePath)
+		defer m.exit()
+	}
+
+	if err := execModule(mod, sourcePath, global); err != nil {
+		return nil, err
+	}
+	return global, nil
+}
+
+// execModule runs a module's top level in global: on the bytecode VM when the
+// machine runs one, and by walking its AST otherwise.
+func execModule(mod *ast.Module, sourcePath string, global *Environment) error {
+	if global.m.bytecode {
+		return runCompiled(mod, sourcePath, global)
+	}
 
 	// Resolve imports and hoist top-level function/type definitions so
 	// references (including recursion and forward references) resolve
 	// regardless of source order.
-	if err := loadInto(mod, global, filepath.Dir(sourcePath), reg, argv); err != nil {
+	if err := loadInto(mod, global, filepath.Dir(sourcePath)); err != nil {
 		return err
 	}
 
-	err = evalStatements(mod.Body, global)
-	if err != nil {
-		var pos token.Pos
-		if len(mod.Body) > 0 {
-			pos = mod.Body[0].Position()
-		}
-		err, pos = takePosition(err, pos)
+	if err := evalStatements(mod.Body, global); err != nil {
+		err, pos := takePosition(err, modulePosition(mod))
 		return object.WithFrame(err, stackFrame(moduleName(sourcePath), "<module>", pos))
 	}
 	return nil
 }
 
 func evalStatements(stmts []ast.Statement, env *Environment) error {
+	if err := env.m.interrupted(); err != nil {
+		return err
+	}
+	m := env.m
 	for _, stmt := range stmts {
+		if m.observing() {
+			m.statement(stmt, env)
+		}
 		if err := evalStatement(stmt, env); err != nil {
 			return positionError(err, stmt.Position())
 		}
@@ -154,6 +249,11 @@ func evalBlock(stmts []ast.Statement, parent *Environment) error {
 }
 
 func evalStatement(stmt ast.Statement, env *Environment) error {
+	if l := env.m.limiter(); l != nil {
+		if err := l.step(); err != nil {
+			return err
+		}
+	}
 	switch s := stmt.(type) {
 	case *ast.Declare:
 		v, err := evalExpr(s.Value, env)
@@ -243,11 +343,11 @@ func evalStatement(stmt ast.Statement, env *Environment) error {
 		if err != nil {
 			return err
 		}
-		items, err := iter.Iter()
+		cursor, err := object.NewCursor(iter)
 		if err != nil {
 			return err
 		}
-		for _, item := range items {
+		for item, ok := cursor.Next(); ok; item, ok = cursor.Next() {
 			// Like a Go range clause, the iteration binding belongs to the loop,
 			// not the surrounding block. Give each iteration its own binding so
 			// closures do not all capture the final value.
@@ -263,7 +363,7 @@ func evalStatement(stmt ast.Statement, env *Environment) error {
 				return err
 			}
 		}
-		return nil
+		return cursor.Err()
 
 	case *ast.Break:
 		return breakSignal{}
@@ -291,11 +391,15 @@ func evalStatement(stmt ast.Statement, env *Environment) error {
 			return nil
 		}
 		// Control-flow signals must pass through untouched; only genuine
-		// errors (raised Errors and runtime errors) are caught.
+		// errors (raised Errors and runtime errors) are caught. Neither is
+		// the error of a cancelled program, which must not run on.
 		switch err.(type) {
 		case breakSignal, continueSignal, returnSignal:
 			return err
 		}
+		if env.m.interrupted() != nil || isExit(err) {
+			return err
+		}
 		err, _ = takePosition(err, token.Pos{})
 		catchEnv := NewEnvironment(env)
 		catchEnv.Define(s.CatchVar, object.ErrorValue(err))
@@ -326,6 +430,14 @@ func evalStatement(stmt ast.Statement, env *Environment) error {
 }
 
 func evalExpr(expr ast.Expression, env *Environment) (object.Object, error) {
+	if l := env.m.limiter(); l != nil {
+		return l.evalExpr(expr, env)
+	}
+	return evalNode(expr, env)
+}
+
+// evalNode evaluates expr; evalExpr is it, less the sandbox's accounting.
+func evalNode(expr ast.Expression, env *Environment) (object.Object, error) {
 	switch e := expr.(type) {
 	case *ast.Literal:
 		return e.Value, nil
@@ -334,7 +446,7 @@ func evalExpr(expr ast.Expression, env *Environment) (object.Object, error) {
 		if v, ok := env.Get(e.Name); ok {
 			return v, nil
 		}
-		if b, ok := extension.BuiltinsModule.Members[e.Name]; ok {
+		if b, ok := env.m.builtin(e.Name); ok {
 			return b, nil
 		}
 		return nil, object.NewNameError("undefined: %s", e.Name)
@@ -425,7 +537,7 @@ func resolveName(name string, env *Environment) (object.Object, error) {
 	if v, ok := env.Get(name); ok {
 		return v, nil
 	}
-	if b, ok := extension.BuiltinsModule.Members[name]; ok {
+	if b, ok := env.m.builtin(name); ok {
 		return b, nil
 	}
 	return nil, object.NewNameError("undefined: %s", name)
@@ -563,7 +675,9 @@ func evalArgs(args []ast.CallArgument, env *Environment) (object.CallArgs, error
 // makeFunction wraps a Goblin function definition as a callable object.Function,
 // capturing the defining environment for closures.
 func makeFunction(def *ast.FunctionDefine, env *Environment) *object.Function {
-	return makeClosure(def.Name, def.Position(), def.Parameters, def.Body, env)
+	fn := makeClosure(def.Name, def.Position(), def.Parameters, def.Body, env)
+	fn.LazyDoc = func() string { return doc.FuncHelp(doc.NewFunc(def)) }
+	return fn
 }
 
 // makeClosure builds a callable object.Function from parameters and a body,
@@ -598,10 +712,22 @@ func makeClosure(name string, pos token.Pos, params []*ast.Parameter, body []ast
 		module = moduleName(src.Source())
 	}
 	frame := stackFrame(module, name, pos)
+	limits := env.m.limiter()
+	m := env.m
 
-	return &object.Function{
+	fn := &object.Function{
 		Name: name,
 		Fn: func(args object.CallArgs) (object.Object, error) {
+			if limits != nil {
+				if err := limits.enter(); err != nil {
+					return nil, object.WithFrame(err, frame)
+				}
+				defer limits.exit()
+			}
+			if m.observing() {
+				m.enter(frame)
+				defer m.exit()
+			}
 			local := NewEnvironment(env)
 			if err := object.BindArgumentsInto(name, fixed, defaults, varArgs, kwArgs, args, local); err != nil {
 				return nil, object.WithFrame(err, frame)
@@ -622,4 +748,8 @@ func makeClosure(name string, pos token.Pos, params []*ast.Parameter, body []ast
 			return object.Nil, nil
 		},
 	}
+	if limits != nil {
+		fn.BeforeGoblin = limits.goblin
+	}
+	return fn
 }
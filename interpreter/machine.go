@@ -0,0 +1,262 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/coverage"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/profiler"
+	"github.com/aisk/goblin/token"
+)
+
+// Options configures a Session beyond its base directory. The zero value is
+// what NewSession uses: the process's standard streams and the REPL's argv.
+type Options struct {
+	// Stdin, Stdout and Stderr replace the process's standard streams for
+	// the program: print and help write to Stdout, eprint and the reports of
+	// failed goblins to Stderr, and exec.INHERIT connects commands to all
+	// three. A nil stream is the process's.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Argv is what os.argv() returns; nil means a single empty string, as in
+	// the REPL.
+	Argv []string
+	// Sandbox, when set, restricts the program's imports and limits its
+	// runs.
+	Sandbox *Sandbox
+	// Bytecode runs the program, and the .goblin modules it imports, on the
+	// bytecode VM instead of walking their AST. Only RunWithOptions honors
+	// it, without a Sandbox or a Debugger; sessions always walk the AST.
+	Bytecode bool
+	// Coverage, when set, counts the statements the program runs, in every
+	// module it runs or imports.
+	Coverage *coverage.Recorder
+	// Profile, when set, records where the program spends its time. The
+	// caller stops it once the program ends.
+	Profile *profiler.Profiler
+	// Debugger, when set, is told about every frame and statement of the
+	// program.
+	Debugger Debugger
+	// BuildConstants are what the build module's get() and constants()
+	// report, as `goblin run -X name=value` sets them.
+	BuildConstants map[string]string
+	// AtExit is called with the status when the program calls os.exit. If
+	// it returns, the program ends with an *extension.ExitError, which
+	// try/catch does not catch; a Session's Eval or Call returns it. Nil
+	// means ending the process for RunWithOptions, and nothing for a
+	// Session.
+	AtExit func(code int)
+}
+
+// machine is the state one program shares across every scope it runs in: the
+// modules it has loaded, its argv, its streams, the names its host defines,
+// and the context that cancels it. Each Environment points at the machine of
+// its program, so a session needs no process-global state. A nil machine,
+// as in a bare NewEnvironment(nil), behaves like a run of `goblin run`.
+type machine struct {
+	reg  *object.Registry
+	argv []string
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	// builtins are resolved after a scope's own names and before
+	// extension.BuiltinsModule: the stream builtins when the streams are
+	// not the process's, and the functions a host defines.
+	builtins map[string]object.Object
+	// modules are importable by this program only, ahead of the modules
+	// registered with object.RegisterModule.
+	modules map[string]func() (object.Object, error)
+
+	// ctx cancels the code running in the machine; it is the context of the
+	// latest Eval or Call, which goblins spawned earlier observe too.
+	ctx atomic.Pointer[context.Context]
+	// limits enforces the sandbox, if the program runs in one.
+	limits *limiter
+	// bytecode compiles the program's modules for the bytecode VM.
+	bytecode bool
+
+	// coverage, profile and debugger observe the program as it runs.
+	// observed is set when any of them is, so the statement loop, the
+	// hottest path in the interpreter, pays a single check without them.
+	coverage *coverage.Recorder
+	profile  *profiler.Profiler
+	debugger Debugger
+	observed bool
+
+	// buildConstants are what the program's build module reports.
+	buildConstants map[string]string
+	// atExit is called when the program calls os.exit.
+	atExit func(code int)
+}
+
+func newMachine(reg *object.Registry, argv []string) *machine {
+	return &machine{reg: reg, argv: argv, builtins: map[string]object.Object{}, modules: map[string]func() (object.Object, error){}}
+}
+
+// setStreams makes the program use the given streams; nil ones stay the
+// process's.
+func (m *machine) setStreams(stdin io.Reader, stdout, stderr io.Writer) {
+	if stdin == nil && stdout == nil && stderr == nil {
+		return
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	m.stdin, m.stdout, m.stderr = stdin, stdout, stderr
+	for name, fn := range extension.StreamBuiltins(stdout, stderr) {
+		m.builtins[name] = fn
+	}
+}
+
+// setSandbox makes the program run in sb.
+func (m *machine) setSandbox(sb *Sandbox) {
+	if sb != nil {
+		m.limits = &limiter{sandbox: sb}
+	}
+}
+
+// setObservers makes the program report to the coverage recorder, profile
+// and debugger of opts.
+func (m *machine) setObservers(opts Options) {
+	m.coverage, m.profile, m.debugger = opts.Coverage, opts.Profile, opts.Debugger
+	m.observed = m.coverage != nil || m.profile != nil || m.debugger != nil
+}
+
+// osExit is what the program's os.exit calls.
+func (m *machine) osExit(code int) error {
+	if m.atExit != nil {
+		m.atExit(code)
+	}
+	return &extension.ExitError{Code: code}
+}
+
+// isExit reports whether err is the end of a program that called os.exit,
+// which try/catch lets through.
+func isExit(err error) bool {
+	var exit *extension.ExitError
+	return errors.As(err, &exit)
+}
+
+// observing reports whether anything observes the program; the calls below
+// are only made when it does.
+func (m *machine) observing() bool {
+	return m != nil && m.observed
+}
+
+// enter and exit bracket every function and module body.
+func (m *machine) enter(frame object.Frame) {
+	if m.profile != nil {
+		m.profile.Enter(frame)
+	}
+	if m.debugger != nil {
+		m.debugger.Enter(frame)
+	}
+}
+
+func (m *machine) exit() {
+	if m.debugger != nil {
+		m.debugger.Exit()
+	}
+	if m.profile != nil {
+		m.profile.Exit()
+	}
+}
+
+// enterModule is enter for the top level of mod, whose source is at path.
+func (m *machine) enterModule(mod *ast.Module, path string) {
+	if m.coverage != nil {
+		m.coverage.AddModule(path, mod)
+	}
+	m.enter(stackFrame(moduleName(path), "<module>", modulePosition(mod)))
+}
+
+// statement comes before every statement the AST walker runs, in env.
+func (m *machine) statement(stmt ast.Statement, env *Environment) {
+	m.trace(stmt.Position())
+	if m.debugger != nil {
+		m.debugger.Statement(stmt, env)
+	}
+}
+
+// trace comes before every statement, at pos, the bytecode VM runs.
+func (m *machine) trace(pos token.Pos) {
+	if m.coverage != nil {
+		m.coverage.Hit(pos)
+	}
+	if m.profile != nil {
+		m.profile.Line(pos.Line)
+	}
+}
+
+// limiter returns the limits of the program's sandbox, or nil.
+func (m *machine) limiter() *limiter {
+	if m == nil {
+		return nil
+	}
+	return m.limits
+}
+
+// start and finish bracket each run of code in the machine: a Run, an Eval
+// or a Call.
+func (m *machine) start(ctx context.Context) {
+	m.setContext(ctx)
+	if m.limits != nil {
+		m.limits.start()
+	}
+}
+
+func (m *machine) finish() {
+	if m.limits != nil {
+		m.limits.finish()
+	}
+}
+
+// builtin resolves a name no scope defines.
+func (m *machine) builtin(name string) (object.Object, bool) {
+	if m != nil {
+		if v, ok := m.builtins[name]; ok {
+			return v, true
+		}
+	}
+	v, ok := extension.BuiltinsModule.Members[name]
+	return v, ok
+}
+
+// setContext makes ctx cancel the code running in the machine from now on.
+func (m *machine) setContext(ctx context.Context) {
+	if ctx.Done() == nil {
+		// ctx is never done; checking it would only cost time.
+		m.ctx.Store(nil)
+		return
+	}
+	m.ctx.Store(&ctx)
+}
+
+// interrupted returns the context's error once it is done, or the error of
+// the sandbox limit the program exceeded. It is checked as each block starts,
+// so a loop or a recursion stops within an iteration or a call; a Go function
+// blocked in a call is not interrupted.
+func (m *machine) interrupted() error {
+	if m == nil {
+		return nil
+	}
+	if m.limits != nil {
+		if err := m.limits.err(); err != nil {
+			return err
+		}
+	}
+	ctx := m.ctx.Load()
+	if ctx == nil {
+		return nil
+	}
+	return (*ctx).Err()
+}
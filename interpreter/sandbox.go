@@ -0,0 +1,230 @@
+package interpreter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
+)
+
+// Sandbox restricts what a program may import and how much it may run, for
+// running code that is not trusted. A limit of zero is no limit. Exceeding a
+// limit stops the program with the matching kind of object.LimitError, which
+// Goblin code cannot catch.
+type Sandbox struct {
+	// Modules are the modules the program may import. Nil allows every
+	// module but SandboxBlockedModules, and also allows fs when Root is set.
+	// Modules a Session registers itself are always importable.
+	Modules []string
+	// PathImports allows importing .goblin files by path.
+	PathImports bool
+	// Root, when set, confines the fs module to this directory, which the
+	// program sees as "/".
+	Root string
+
+	// MaxSteps caps the statements and expressions evaluated.
+	MaxSteps int64
+	// Timeout caps the wall time a run takes. A Go function blocked in a
+	// call, such as time.sleep(), returns before the program stops.
+	Timeout time.Duration
+	// MaxDepth caps the Goblin function calls in progress at once, across
+	// the program's goblins.
+	MaxDepth int64
+	// MaxGoblins caps the goblins the program starts.
+	MaxGoblins int64
+	// MaxAlloc caps, in bytes, the approximate size of the strings, bytes,
+	// lists and dicts the program's literals, operators and calls produce.
+	// It counts each value as it is produced, not what stays alive, and
+	// misses values native functions grow in place, such as list.push().
+	MaxAlloc int64
+}
+
+// SandboxBlockedModules are the modules a Sandbox with nil Modules does not
+// allow: the ones that reach the host's processes, files and network. embed
+// reads the files next to the source, and testing creates temporary
+// directories on the host.
+var SandboxBlockedModules = []string{"exec", "fs", "os", "http", "path", "embed", "testing"}
+
+// DefaultSandbox returns the sandbox of `goblin run --sandbox`: the default
+// modules, no path imports, and limits generous enough for small programs.
+func DefaultSandbox() *Sandbox {
+	return &Sandbox{
+		MaxSteps:   100_000_000,
+		Timeout:    10 * time.Second,
+		MaxDepth:   1000,
+		MaxGoblins: 1000,
+		MaxAlloc:   256 << 20,
+	}
+}
+
+// allows reports whether the sandbox lets the program import module name.
+func (s *Sandbox) allows(name string) bool {
+	if s.Modules == nil {
+		if name == "fs" && s.Root != "" {
+			return true
+		}
+		for _, blocked := range SandboxBlockedModules {
+			if name == blocked {
+				return false
+			}
+		}
+		return true
+	}
+	for _, allowed := range s.Modules {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter enforces a Sandbox's limits on the program of one machine. Its
+// counters are shared by the program's goblins, so they are atomic.
+type limiter struct {
+	sandbox *Sandbox
+
+	steps, depth, goblins, alloc atomic.Int64
+
+	// stopped holds the error of the first limit exceeded; every check
+	// returns it from then on, so the program cannot run on past it.
+	stopped atomic.Pointer[error]
+	mu      sync.Mutex
+	timer   *time.Timer
+}
+
+// start resets the limits for a new run, as each Eval and Call is one.
+func (l *limiter) start() {
+	l.steps.Store(0)
+	l.depth.Store(0)
+	l.goblins.Store(0)
+	l.alloc.Store(0)
+	l.stopped.Store(nil)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if timeout := l.sandbox.Timeout; timeout > 0 {
+		l.timer = time.AfterFunc(timeout, func() {
+			l.stop(object.NewLimitError(object.TimeLimitError, "time limit of %s exceeded", timeout))
+		})
+	}
+}
+
+// finish stops the clock of the run start began.
+func (l *limiter) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// stop records err as the error the program stops with, unless a limit
+// was exceeded before, and returns the one recorded.
+func (l *limiter) stop(err error) error {
+	l.stopped.CompareAndSwap(nil, &err)
+	return *l.stopped.Load()
+}
+
+// err returns the error the program stopped with, or nil.
+func (l *limiter) err() error {
+	if err := l.stopped.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// step counts one statement or expression.
+func (l *limiter) step() error {
+	if err := l.err(); err != nil {
+		return err
+	}
+	if max := l.sandbox.MaxSteps; max > 0 && l.steps.Add(1) > max {
+		return l.stop(object.NewLimitError(object.StepLimitError, "step limit of %d exceeded", max))
+	}
+	return nil
+}
+
+// evalExpr evaluates expr as a step, counting the size of the value it
+// produces when it is an expression that allocates one.
+func (l *limiter) evalExpr(expr ast.Expression, env *Environment) (object.Object, error) {
+	if err := l.step(); err != nil {
+		return nil, err
+	}
+	v, err := evalNode(expr, env)
+	if err != nil || l.sandbox.MaxAlloc <= 0 {
+		return v, err
+	}
+	switch expr.(type) {
+	case *ast.ListLiteral, *ast.DictLiteral, *ast.BinaryOperation, *ast.IndexExpression, *ast.FunctionCall, *ast.CallExpression:
+		if max := l.sandbox.MaxAlloc; l.alloc.Add(sizeOf(v)) > max {
+			return nil, l.stop(object.NewLimitError(object.MemoryLimitError, "allocation limit of %d bytes exceeded", max))
+		}
+	}
+	return v, nil
+}
+
+// sizeOf approximates the bytes v takes beyond the values it holds, which
+// were counted when they were produced.
+func sizeOf(v object.Object) int64 {
+	switch x := v.(type) {
+	case object.String:
+		return int64(len(x))
+	case object.Bytes:
+		return int64(len(x))
+	case *object.List:
+		return 16 * int64(x.Len())
+	case *object.Dict:
+		return 48 * int64(x.Len())
+	}
+	return 0
+}
+
+// enter counts a Goblin function call starting; exit, its end.
+func (l *limiter) enter() error {
+	if err := l.err(); err != nil {
+		return err
+	}
+	if max := l.sandbox.MaxDepth; max > 0 && l.depth.Add(1) > max {
+		l.depth.Add(-1)
+		return l.stop(object.NewLimitError(object.RecursionLimitError, "recursion depth limit of %d exceeded", max))
+	}
+	return nil
+}
+
+func (l *limiter) exit() {
+	if l.sandbox.MaxDepth > 0 {
+		l.depth.Add(-1)
+	}
+}
+
+// goblin counts a goblin starting; it is the BeforeGoblin of the program's
+// functions.
+func (l *limiter) goblin() error {
+	if err := l.err(); err != nil {
+		return err
+	}
+	if max := l.sandbox.MaxGoblins; max > 0 && l.goblins.Add(1) > max {
+		return l.stop(object.NewLimitError(object.GoblinLimitError, "goblin limit of %d exceeded", max))
+	}
+	return nil
+}
+
+// checkImport fails when the sandbox does not allow importing path.
+func (l *limiter) checkImport(path string) error {
+	if isPathImport(path) {
+		if !l.sandbox.PathImports {
+			return object.NewImportError("import of %s is not allowed in the sandbox", path)
+		}
+		return nil
+	}
+	if !l.sandbox.allows(path) {
+		return object.NewImportError("import of module %s is not allowed in the sandbox", path)
+	}
+	return nil
+}
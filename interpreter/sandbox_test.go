@@ -0,0 +1,155 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aisk/goblin/object"
+)
+
+func TestSandboxLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox Sandbox
+		src     string
+		want    *object.Error
+	}{
+		{"steps", Sandbox{MaxSteps: 1000}, `while true {}`, object.StepLimitError},
+		{"time", Sandbox{Timeout: 50 * time.Millisecond}, `while true {}`, object.TimeLimitError},
+		{"depth", Sandbox{MaxDepth: 50}, "func f(n) { return f(n + 1) }\nf(0)", object.RecursionLimitError},
+		{"goblins", Sandbox{MaxGoblins: 3}, "func f() {}\nwhile true { Goblin(f) }", object.GoblinLimitError},
+		{"spawn", Sandbox{MaxGoblins: 3}, "func f() {}\nwhile true { spawn(f) }", object.GoblinLimitError},
+		{"alloc", Sandbox{MaxAlloc: 1 << 20}, `var s = "x"
+while true { s = s + s }`, object.MemoryLimitError},
+		{"alloc list", Sandbox{MaxAlloc: 1 << 20}, `var l = [1]
+while true { l = l + l }`, object.MemoryLimitError},
+	}
+	for _, tt := range tests {
+		tt := tt // goblins of the session outlive the iteration
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSessionWithOptions(".", Options{Sandbox: &tt.sandbox})
+			_, err := s.Eval(tt.src)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Eval = %v, want %s", err, tt.want)
+			}
+			if !errors.Is(err, object.LimitError) {
+				t.Fatalf("Eval = %v, not a LimitError", err)
+			}
+			// The limits are counted afresh for each Eval.
+			if _, err := s.Eval(`var ok = 1`); err != nil {
+				t.Fatalf("Eval after the limit = %v", err)
+			}
+		})
+	}
+}
+
+func TestSandboxLimitUncatchable(t *testing.T) {
+	s := NewSessionWithOptions(".", Options{Sandbox: &Sandbox{MaxSteps: 1000}})
+	_, err := s.Eval(`
+var caught = 0
+while true {
+    try {
+        while true {}
+    } catch e {
+        caught = caught + 1
+    }
+}
+`)
+	if !errors.Is(err, object.StepLimitError) {
+		t.Fatalf("Eval = %v, want StepLimitError", err)
+	}
+	if v, _ := s.Get("caught"); v != object.Integer(0) {
+		t.Fatalf("caught = %v, want the limit error uncaught", v)
+	}
+}
+
+func TestSandboxImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.goblin"), []byte("var x = 1\nexport x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sandbox Sandbox
+		src     string
+		wantErr string
+	}{
+		{Sandbox{}, `import "json"`, ""},
+		{Sandbox{}, `import "os"`, "import of module os is not allowed in the sandbox"},
+		{Sandbox{}, `import "exec"`, "import of module exec is not allowed in the sandbox"},
+		{Sandbox{}, `import "fs"`, "import of module fs is not allowed in the sandbox"},
+		{Sandbox{}, `import "http"`, "import of module http is not allowed in the sandbox"},
+		{Sandbox{}, `import "path"`, "import of module path is not allowed in the sandbox"},
+		{Sandbox{}, `import "embed"`, "import of module embed is not allowed in the sandbox"},
+		{Sandbox{}, `import "testing"`, "import of module testing is not allowed in the sandbox"},
+		{Sandbox{}, `import "./lib"`, "import of ./lib is not allowed in the sandbox"},
+		{Sandbox{PathImports: true}, `import "./lib"`, ""},
+		{Sandbox{Modules: []string{"math"}}, `import "math"`, ""},
+		{Sandbox{Modules: []string{"math"}}, `import "json"`, "import of module json is not allowed in the sandbox"},
+		{Sandbox{Modules: []string{"os"}}, `import "os"`, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		s := NewSessionWithOptions(dir, Options{Sandbox: &tt.sandbox})
+		_, err := s.Eval(tt.src)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%+v: %s = %v", tt.sandbox, tt.src, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, object.ImportError) {
+			t.Errorf("%+v: %s = %v, want ImportError %q", tt.sandbox, tt.src, err, tt.wantErr)
+		}
+	}
+
+	// A module the session registers itself is importable whatever the
+	// sandbox allows.
+	s := NewSessionWithOptions(dir, Options{Sandbox: &Sandbox{Modules: []string{}}})
+	s.RegisterModule("host", func() (object.Object, error) {
+		return &object.Module{Name: "host", Members: map[string]object.Object{}}, nil
+	})
+	if _, err := s.Eval(`import "host"`); err != nil {
+		t.Errorf("import of a session module = %v", err)
+	}
+}
+
+func TestSandboxRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "data.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSessionWithOptions(".", Options{Sandbox: &Sandbox{Root: root}})
+	v, err := s.Eval("import \"fs\"\nfs.read(\"/data.txt\")")
+	if err != nil || v != object.String("inside") {
+		t.Fatalf("fs.read() = %v, %v", v, err)
+	}
+	if _, err := s.Eval(`fs.read("../../../../../../etc/hostname")`); err == nil || !errors.Is(err, object.NotExistError) {
+		t.Fatalf("fs.read() above the root = %v, want NotExistError under the root", err)
+	}
+}
+
+func TestSandboxEmbedStaysInSourceDir(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skip("symbolic links are not available:", err)
+	}
+	s := NewSessionWithOptions(dir, Options{Sandbox: &Sandbox{Modules: []string{"embed"}}})
+	v, err := s.Eval("import \"embed\"\nembed.files(\"data.txt\").read_text(\"data.txt\")")
+	if err != nil || v != object.String("inside") {
+		t.Fatalf("read_text() = %v, %v", v, err)
+	}
+	if v, err := s.Eval(`embed.files("escape").read_text("escape/secret.txt")`); err == nil {
+		t.Fatalf("read_text() through a link out of the directory = %v, want an error", v)
+	}
+}
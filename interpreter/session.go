@@ -1,10 +1,12 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/extension"
 	"github.com/aisk/goblin/source"
 	"github.com/aisk/goblin/object"
@@ -21,6 +23,9 @@ func (s *Session) CompletionCandidates(path []string) []string {
 		for name := range extension.BuiltinsModule.Members {
 			names[name] = struct{}{}
 		}
+		for name := range s.m.builtins {
+			names[name] = struct{}{}
+		}
 		if locked := object.InConcurrentMode(); locked {
 			envMu.RLock()
 			defer envMu.RUnlock()
@@ -31,21 +36,32 @@ func (s *Session) CompletionCandidates(path []string) []string {
 		return sortedNames(names)
 	}
 
+	value, ok := s.Lookup(path)
+	if !ok {
+		return nil
+	}
+	return value.Attributes()
+}
+
+// Lookup resolves a non-empty member path such as json.marshal against the
+// session globals and builtins, through GetAttr only, so it never runs Goblin
+// code.
+func (s *Session) Lookup(path []string) (object.Object, bool) {
 	value, ok := s.global.Get(path[0])
 	if !ok {
-		value, ok = extension.BuiltinsModule.Members[path[0]]
+		value, ok = s.m.builtin(path[0])
 	}
 	if !ok {
-		return nil
+		return nil, false
 	}
 	for _, name := range path[1:] {
 		var err error
 		value, err = value.GetAttr(name)
 		if err != nil {
-			return nil
+			return nil, false
 		}
 	}
-	return value.Attributes()
+	return value, true
 }
 
 func sortedNames(names map[string]struct{}) []string {
@@ -59,12 +75,13 @@ func sortedNames(names map[string]struct{}) []string {
 
 // Session is a persistent interpreter context. Unlike Run, it keeps its global
 // scope and module registry across calls, so successive Eval invocations share
-// state — the basis for a REPL.
+// state — the basis for a REPL, and for Go programs embedding Goblin. A
+// session is not safe for concurrent use, though goblins its code spawns run
+// concurrently with it.
 type Session struct {
 	global  *Environment
-	reg     *object.Registry
+	m       *machine
 	baseDir string
-	argv    []string
 }
 
 // replArgv0 is os.argv()[0] inside the REPL, so interactive sessions do not
@@ -74,26 +91,77 @@ const replArgv0 = ""
 
 // NewSession creates a session. baseDir is used to resolve relative imports.
 func NewSession(baseDir string) *Session {
-	return &Session{
-		global:  NewEnvironment(nil),
-		reg:     object.NewRegistry(),
-		baseDir: baseDir,
-		argv:    []string{replArgv0},
+	return NewSessionWithOptions(baseDir, Options{})
+}
+
+// NewSessionWithOptions creates a session configured by opts. baseDir is used
+// to resolve relative imports.
+func NewSessionWithOptions(baseDir string, opts Options) *Session {
+	argv := opts.Argv
+	if argv == nil {
+		argv = []string{replArgv0}
+	}
+	m := newMachine(object.NewRegistry(), argv)
+	m.setStreams(opts.Stdin, opts.Stdout, opts.Stderr)
+	m.setSandbox(opts.Sandbox)
+	m.setObservers(opts)
+	m.buildConstants, m.atExit = opts.BuildConstants, opts.AtExit
+	return &Session{global: newGlobal(m), m: m, baseDir: baseDir}
+}
+
+// Define binds name in the session's global scope, as a top-level let would.
+func (s *Session) Define(name string, v object.Object) {
+	s.global.Define(name, v)
+}
+
+// Get returns the value of name in the session's global scope.
+func (s *Session) Get(name string) (object.Object, bool) {
+	if object.InConcurrentMode() {
+		envMu.RLock()
+		defer envMu.RUnlock()
 	}
+	v, ok := s.global.vars[name]
+	return v, ok
+}
+
+// DefineBuiltin makes v a built-in name of the session: every module it runs,
+// the imported ones included, resolves name to v unless it declares name
+// itself. It overrides a built-in of the same name, and must not be called
+// while the session's code runs.
+func (s *Session) DefineBuiltin(name string, v object.Object) {
+	s.m.builtins[name] = v
+}
+
+// RegisterModule makes the module execute returns importable as name by the
+// session's code alone, ahead of the modules registered with
+// object.RegisterModule. Like them, it is loaded on first import. It must not
+// be called while the session's code runs.
+func (s *Session) RegisterModule(name string, execute func() (object.Object, error)) {
+	s.m.modules[name] = execute
 }
 
 // Eval parses and evaluates a source fragment against the session's scope. If
 // the fragment's last statement is an expression, its value is returned (for
 // REPL display); otherwise it returns nil.
 func (s *Session) Eval(src string) (result object.Object, err error) {
+	return s.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval, stopping the fragment with ctx's error once ctx is
+// done. Goblin code cannot catch that error. In a sandbox, each EvalContext
+// is a run of its own, with limits counted afresh.
+func (s *Session) EvalContext(ctx context.Context, src string) (result object.Object, err error) {
 	// Keep the REPL alive when a Go-side panic escapes the runtime.
 	defer func() {
 		if r := recover(); r != nil {
 			result, err = nil, object.NewInternalError("internal error: %v", r)
 		}
 	}()
+	s.m.start(ctx)
+	defer s.m.finish()
 
-	st, err := parser.NewParser().Parse(source.NewLexer([]byte(src)))
+	scanner := source.NewScanner([]byte(src))
+	st, err := parser.NewParser().Parse(scanner)
 	if err != nil {
 		// The grammar only accepts identifier-led expression statements, so a
 		// fragment like `1 + 2` fails to parse as a statement. Retry it as a
@@ -107,26 +175,49 @@ func (s *Session) Eval(src string) (result object.Object, err error) {
 	if !ok {
 		return nil, fmt.Errorf("internal error: unexpected AST type")
 	}
+	doc.Attach(mod, scanner)
 	// No static semantic check here: it analyses a fragment in isolation and
 	// would reject references to names declared on earlier REPL lines. The
 	// interpreter reports undefined names at runtime against the live scope.
 
 	// Resolve imports and hoist definitions into the persistent scope.
-	if err := loadInto(mod, s.global, s.baseDir, s.reg, s.argv); err != nil {
+	if err := loadInto(mod, s.global, s.baseDir); err != nil {
 		return nil, err
 	}
+	return evalFragment(mod.Body, s.global, "repl")
+}
+
+// Call calls fn, typically a function the session's code defined, with args,
+// stopping it with ctx's error once ctx is done, as EvalContext does.
+func (s *Session) Call(ctx context.Context, fn object.Object, args object.CallArgs) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, object.NewInternalError("internal error: %v", r)
+		}
+	}()
+	s.m.start(ctx)
+	defer s.m.finish()
+	if err := s.m.interrupted(); err != nil {
+		return nil, err
+	}
+	return object.Call(fn, args)
+}
 
-	for _, stmt := range mod.Body {
+// evalFragment runs the statements of a fragment in env, returning the value
+// of the last one if it is an expression. Errors carry a "<module>" frame of
+// the given module name.
+func evalFragment(stmts []ast.Statement, env *Environment, module string) (result object.Object, err error) {
+	for _, stmt := range stmts {
 		if expr, ok := stmt.(ast.Expression); ok {
-			v, err := evalExpr(expr, s.global)
+			v, err := evalExpr(expr, env)
 			if err != nil {
-				return nil, object.WithFrame(err, stackFrame("repl", "<module>", expr.Position()))
+				return nil, object.WithFrame(err, stackFrame(module, "<module>", expr.Position()))
 			}
 			result = v
 		} else {
-			if err := evalStatement(stmt, s.global); err != nil {
+			if err := evalStatement(stmt, env); err != nil {
 				inner, pos := takePosition(err, stmt.Position())
-				return nil, object.WithFrame(inner, stackFrame("repl", "<module>", pos))
+				return nil, object.WithFrame(inner, stackFrame(module, "<module>", pos))
 			}
 			result = nil
 		}
@@ -142,18 +233,28 @@ func (s *Session) Eval(src string) (result object.Object, err error) {
 // variable). The parsed return value reports whether the wrapped form parsed;
 // when false, callers should surface the original error instead.
 func (s *Session) evalAsExpression(src string) (value object.Object, evalErr error, parsed bool) {
+	expr, ok := parseExpression(src)
+	if !ok {
+		return nil, nil, false
+	}
+	v, evalErr := evalExpr(expr, s.global)
+	return v, evalErr, true
+}
+
+// parseExpression parses src as a bare expression, wrapped in a return
+// statement as evalAsExpression describes.
+func parseExpression(src string) (ast.Expression, bool) {
 	st, err := parser.NewParser().Parse(source.NewLexer([]byte("return " + src)))
 	if err != nil {
-		return nil, nil, false
+		return nil, false
 	}
 	mod, ok := st.(*ast.Module)
 	if !ok || len(mod.Body) != 1 {
-		return nil, nil, false
+		return nil, false
 	}
 	ret, ok := mod.Body[0].(*ast.Return)
 	if !ok {
-		return nil, nil, false
+		return nil, false
 	}
-	v, evalErr := evalExpr(ret.Value, s.global)
-	return v, evalErr, true
+	return ret.Value, true
 }
@@ -195,3 +195,28 @@ func TestSessionUserCanOverrideAttributes(t *testing.T) {
 		t.Fatalf("overridden user.attributes() = %q", got)
 	}
 }
+
+func TestSessionDocComments(t *testing.T) {
+	s := NewSession(".")
+	src := "# Adds two numbers.\n# Both default to zero.\nfunc add(a=0, b=0, *rest) { return a + b }\n\n" +
+		"# A point.\ntype Point(x, y=1) {\n    # The sum of the coordinates.\n    func sum(self) { return self.x + self.y }\n}\n\n" +
+		"func twice(n) { return 2 * n }\n\n# Doubles n.\nexport twice\n"
+	if _, err := s.Eval(src); err != nil {
+		t.Fatal(err)
+	}
+	for expr, want := range map[string]string{
+		"add":                  "func add(a=0, b=0, *rest)\n    Adds two numbers.\n    Both default to zero.\n",
+		"Point(1).sum":         "func sum(self)\n    The sum of the coordinates.\n",
+		"Point":                "type Point(x, y=1)\n    A point.\n\n    func sum(self)\n        The sum of the coordinates.\n",
+		"twice":                "func twice(n)\n    Doubles n.\n",
+		"func(n) { return n }": "",
+	} {
+		v, err := s.Eval(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.(*object.Function).Help(); got != want {
+			t.Errorf("doc of %s = %q, want %q", expr, got, want)
+		}
+	}
+}
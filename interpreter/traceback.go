@@ -1,6 +1,7 @@
 package interpreter
 
 import (
+	"github.com/aisk/goblin/ast"
 	"github.com/aisk/goblin/object"
 	"github.com/aisk/goblin/source"
 	"github.com/aisk/goblin/token"
@@ -14,6 +15,15 @@ func stackFrame(module, function string, pos token.Pos) object.Frame {
 	return frame
 }
 
+// modulePosition is where a module's frame points when nothing better is
+// known: its first statement.
+func modulePosition(mod *ast.Module) token.Pos {
+	if len(mod.Body) > 0 {
+		return mod.Body[0].Position()
+	}
+	return token.Pos{}
+}
+
 func moduleName(path string) string {
 	return source.ModuleName(path)
 }
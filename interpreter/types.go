@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/object"
 )
 
@@ -17,13 +18,31 @@ type goblinType struct {
 	params      []string
 	defaults    []object.ParamDefault
 	methods     map[string]*ast.FunctionDefine
+	methodDocs  map[string]string
 	attributes  []string
 	constructor *object.Function
-	env         *Environment
+	// method makes the callable of a method with self bound to in. Each
+	// backend closes it over the scope the type was defined in.
+	method func(in *instance, def *ast.FunctionDefine) *object.Function
 }
 
 // defineType registers a user type's constructor in the current scope.
 func defineType(def *ast.TypeDefine, env *Environment) {
+	t := newGoblinType(def, func(field int) object.ParamDefault {
+		expr := def.Fields[field].DefaultValue
+		return func() (object.Object, error) { return evalExpr(expr, env) }
+	}, func(in *instance, m *ast.FunctionDefine) *object.Function {
+		selfEnv := NewEnvironment(env)
+		selfEnv.Define("self", in)
+		return makeClosure(in.typ.name+"."+m.Name, m.Position(), m.Parameters[1:], m.Body, selfEnv)
+	})
+	env.Define(def.Name, t.constructor)
+}
+
+// newGoblinType builds the runtime type def declares. fieldDefault makes the
+// default of the field at an index, for the fields that have one, and method
+// is the goblinType's method.
+func newGoblinType(def *ast.TypeDefine, fieldDefault func(int) object.ParamDefault, method func(*instance, *ast.FunctionDefine) *object.Function) *goblinType {
 	methods := make(map[string]*ast.FunctionDefine, len(def.Methods))
 	attributes := make([]string, 0, len(def.Methods)+len(def.Fields)+2)
 	seen := make(map[string]bool, cap(attributes))
@@ -54,20 +73,25 @@ func defineType(def *ast.TypeDefine, env *Environment) {
 		defaults:   make([]object.ParamDefault, len(def.Fields)),
 		methods:    methods,
 		attributes: attributes,
-		env:        env,
+		method:     method,
 	}
 	for i, f := range def.Fields {
 		t.params[i] = f.Name
 		if f.HasDefault() {
-			expr := f.DefaultValue
-			t.defaults[i] = func() (object.Object, error) { return evalExpr(expr, t.env) }
+			t.defaults[i] = fieldDefault(i)
 		}
 	}
+	help := doc.NewType(def)
+	t.methodDocs = make(map[string]string, len(help.Methods))
+	for _, m := range help.Methods {
+		t.methodDocs[m.Name] = doc.FuncHelp(m)
+	}
 	t.constructor = &object.Function{
 		Name: def.Name,
 		Fn:   t.construct,
+		Doc:  doc.TypeHelp(help),
 	}
-	env.Define(def.Name, t.constructor)
+	return t
 }
 
 // construct binds call arguments to fields through the shared
@@ -102,12 +126,11 @@ var _ object.Object = (*instance)(nil)
 // parameters after self, so traceback frames and binding diagnostics (names
 // and argument counts alike) match the transpiled backend.
 func (in *instance) bindMethod(def *ast.FunctionDefine) *object.Function {
-	selfEnv := NewEnvironment(in.typ.env)
-	selfEnv.Define("self", in)
-	fn := makeClosure(in.typ.name+"."+def.Name, def.Position(), def.Parameters[1:], def.Body, selfEnv)
+	fn := in.typ.method(in, def)
 	return &object.Function{
 		Name: def.Name,
 		Fn:   fn.Fn,
+		Doc:  in.typ.methodDocs[def.Name],
 	}
 }
 
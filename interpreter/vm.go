@@ -0,0 +1,534 @@
+package interpreter
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/token"
+)
+
+// cell holds a variable closures capture, shared between them and the frame
+// declaring it. Like an Environment's, its value is guarded by envMu once
+// goblins run.
+type cell struct {
+	v object.Object
+}
+
+func (c *cell) get() object.Object {
+	if object.InConcurrentMode() {
+		envMu.RLock()
+		defer envMu.RUnlock()
+	}
+	return c.v
+}
+
+func (c *cell) set(v object.Object) {
+	if object.InConcurrentMode() {
+		envMu.Lock()
+		defer envMu.Unlock()
+	}
+	c.v = v
+}
+
+// globalScope holds the globals of a module the VM runs, in the slots the
+// compiler numbered them with; a global not defined yet is nil. It is the
+// VM's form of a module's global Environment, which gets its globals once
+// the module has run, for its exports.
+type globalScope struct {
+	m      *machine
+	names  []string
+	values []object.Object
+}
+
+// load returns the global i, or the built-in of its name when it is not
+// defined.
+func (g *globalScope) load(i int) (object.Object, error) {
+	if object.InConcurrentMode() {
+		envMu.RLock()
+		defer envMu.RUnlock()
+	}
+	if v := g.values[i]; v != nil {
+		return v, nil
+	}
+	if b, ok := g.m.builtin(g.names[i]); ok {
+		return b, nil
+	}
+	return nil, object.NewNameError("undefined: %s", g.names[i])
+}
+
+func (g *globalScope) store(i int, v object.Object) {
+	if object.InConcurrentMode() {
+		envMu.Lock()
+		defer envMu.Unlock()
+	}
+	g.values[i] = v
+}
+
+// closure is a compiled function with the cells it captured and the global
+// scope of its module.
+type closure struct {
+	p        *proto
+	upvalues []*cell
+	globals  *globalScope
+	defaults []object.ParamDefault
+}
+
+// newClosure closes p over the cells and upvalues of the frame creating it.
+func newClosure(p *proto, cells, upvalues []*cell, globals *globalScope) *closure {
+	c := &closure{p: p, globals: globals}
+	if len(p.upvalues) > 0 {
+		c.upvalues = make([]*cell, len(p.upvalues))
+		for i, u := range p.upvalues {
+			if u.local {
+				c.upvalues[i] = cells[u.index]
+			} else {
+				c.upvalues[i] = upvalues[u.index]
+			}
+		}
+	}
+	if p.defaults != nil {
+		c.defaults = make([]object.ParamDefault, len(p.defaults))
+		for i, d := range p.defaults {
+			if d != nil {
+				c.defaults[i] = newClosure(d, cells, upvalues, globals).evaluate
+			}
+		}
+	}
+	return c
+}
+
+func (c *closure) function() *object.Function {
+	return &object.Function{Name: c.p.name, Fn: c.call, Doc: c.p.doc, Code: c}
+}
+
+// thread holds the slots of the frames of one chain of calls the VM makes
+// directly, from a call through Fn on: a slab each frame takes its locals
+// and operand stack from, and gives back when it returns.
+type thread struct {
+	slots []object.Object
+	top   int
+}
+
+var threads = sync.Pool{New: func() any { return &thread{slots: make([]object.Object, 1024)} }}
+
+// frame takes the slots of a frame of p. The frame gives them back with
+// release(slots, top), from the values frame returned along with it.
+func (th *thread) frame(p *proto) (f frame, slots []object.Object, top int) {
+	slots, top = th.slots, th.top
+	n := p.locals + p.stack
+	if th.top+n > len(th.slots) {
+		// The frames running keep the slab they took their slots from.
+		size := 2 * len(th.slots)
+		if size < n {
+			size = n
+		}
+		th.slots, th.top = make([]object.Object, size), 0
+	}
+	f.locals = th.slots[th.top : th.top+p.locals : th.top+p.locals]
+	f.stack = th.slots[th.top+p.locals : th.top+n]
+	th.top += n
+	if p.cells > 0 {
+		f.cells = make([]*cell, p.cells)
+	}
+	if p.cursors > 0 {
+		f.cursors = make([]*object.Cursor, p.cursors)
+	}
+	return f, slots, top
+}
+
+func (th *thread) release(f *frame, slots []object.Object, top int) {
+	for i := range f.locals {
+		f.locals[i] = nil
+	}
+	for i := range f.stack {
+		f.stack[i] = nil
+	}
+	th.slots, th.top = slots, top
+}
+
+// call runs the function with args, as Fn: the entry of calls from outside
+// the VM.
+func (c *closure) call(args object.CallArgs) (object.Object, error) {
+	th := threads.Get().(*thread)
+	defer threads.Put(th)
+	return c.callArgs(th, args)
+}
+
+// callArgs runs the function with args on th. As with makeClosure, a failure
+// gets a traceback frame pointing at the statement that failed.
+func (c *closure) callArgs(th *thread, args object.CallArgs) (object.Object, error) {
+	p := c.p
+	if m := c.globals.m; m.observing() {
+		m.enter(p.frame)
+		defer m.exit()
+	}
+	f, slots, top := th.frame(p)
+	defer th.release(&f, slots, top)
+	if err := c.bind(&f, args); err != nil {
+		return nil, object.WithFrame(err, p.frame)
+	}
+	return c.enter(th, &f)
+}
+
+// callPositional runs the function with the positional arguments args, which
+// it must not keep, on th.
+func (c *closure) callPositional(th *thread, args []object.Object) (object.Object, error) {
+	p := c.p
+	if p.varArgs != "" || p.kwArgs != "" || len(args) != len(p.params) {
+		return c.callArgs(th, object.CallArgs{Positional: append(object.Args(nil), args...)})
+	}
+	if m := c.globals.m; m.observing() {
+		m.enter(p.frame)
+		defer m.exit()
+	}
+	f, slots, top := th.frame(p)
+	defer th.release(&f, slots, top)
+	copy(f.locals, args)
+	return c.enter(th, &f)
+}
+
+// enter runs f, whose arguments are bound.
+func (c *closure) enter(th *thread, f *frame) (object.Object, error) {
+	for _, pc := range c.p.paramCells {
+		f.cells[pc.cell] = &cell{v: f.locals[pc.local]}
+	}
+	v, pos, err := c.run(th, f)
+	if err != nil {
+		return nil, object.WithFrame(err, stackFrame(c.p.module, c.p.name, pos))
+	}
+	return v, nil
+}
+
+// evaluate runs a default value.
+func (c *closure) evaluate() (object.Object, error) {
+	th := threads.Get().(*thread)
+	defer threads.Put(th)
+	f, slots, top := th.frame(c.p)
+	defer th.release(&f, slots, top)
+	v, _, err := c.run(th, &f)
+	return v, err
+}
+
+// bind binds args to the parameters, in the frame's first slots.
+func (c *closure) bind(f *frame, args object.CallArgs) error {
+	p := c.p
+	if p.varArgs == "" && p.kwArgs == "" && len(args.Keyword) == 0 && len(args.Positional) == len(p.params) {
+		copy(f.locals, args.Positional)
+		return nil
+	}
+	return object.BindArgumentsInto(p.name, p.params, c.defaults, p.varArgs, p.kwArgs, args, paramScope{f, p})
+}
+
+// paramScope is the object.Scope arguments bind into: the parameter slots of
+// a frame.
+type paramScope struct {
+	f *frame
+	p *proto
+}
+
+func (s paramScope) Define(name string, v object.Object) {
+	for i, param := range s.p.params {
+		if param == name {
+			s.f.locals[i] = v
+			return
+		}
+	}
+	i := len(s.p.params)
+	if s.p.varArgs != "" {
+		if name == s.p.varArgs {
+			s.f.locals[i] = v
+			return
+		}
+		i++
+	}
+	if name == s.p.kwArgs {
+		s.f.locals[i] = v
+	}
+}
+
+// frame is the state of one run of a proto.
+type frame struct {
+	locals  []object.Object
+	stack   []object.Object
+	cells   []*cell
+	cursors []*object.Cursor
+	// calls holds the arguments of the calls being built with opArgs.
+	calls []object.CallArgs
+	// tries holds where each open try block catches, innermost last.
+	tries []int
+}
+
+// run executes the proto of c in f until it returns. On failure it returns
+// the position of the statement that failed.
+func (c *closure) run(th *thread, f *frame) (object.Object, token.Pos, error) {
+	p := c.p
+	code := p.code
+	stack, locals := f.stack, f.locals
+	sp, pc := 0, 0
+	for {
+		in := code[pc]
+		pc++
+		var err error
+		switch in.op {
+		case opConst:
+			stack[sp] = p.consts[in.a]
+			sp++
+		case opLoadLocal:
+			stack[sp] = locals[in.a]
+			sp++
+		case opStoreLocal:
+			sp--
+			locals[in.a] = stack[sp]
+		case opLoadCell:
+			stack[sp] = f.cells[in.a].get()
+			sp++
+		case opStoreCell:
+			sp--
+			f.cells[in.a].set(stack[sp])
+		case opNewCell:
+			sp--
+			f.cells[in.a] = &cell{v: stack[sp]}
+		case opMakeCell:
+			f.cells[in.a] = &cell{}
+		case opLoadUpvalue:
+			stack[sp] = c.upvalues[in.a].get()
+			sp++
+		case opStoreUpvalue:
+			sp--
+			c.upvalues[in.a].set(stack[sp])
+		case opLoadGlobal:
+			if stack[sp], err = c.globals.load(int(in.a)); err == nil {
+				sp++
+			}
+		case opStoreGlobal:
+			sp--
+			c.globals.store(int(in.a), stack[sp])
+		case opPop:
+			sp--
+
+		case opList:
+			n := int(in.a)
+			elements := make([]object.Object, n)
+			copy(elements, stack[sp-n:sp])
+			sp -= n
+			stack[sp] = &object.List{Elements: elements}
+			sp++
+		case opDict:
+			stack[sp] = object.NewDict()
+			sp++
+		case opDictSet:
+			sp -= 2
+			err = stack[sp-1].(*object.Dict).Set(stack[sp], stack[sp+1])
+
+		case opAdd:
+			sp--
+			stack[sp-1], err = object.Add(stack[sp-1], stack[sp])
+		case opMinus:
+			sp--
+			stack[sp-1], err = object.Minus(stack[sp-1], stack[sp])
+		case opMultiply:
+			sp--
+			stack[sp-1], err = object.Multiply(stack[sp-1], stack[sp])
+		case opDivide:
+			sp--
+			stack[sp-1], err = object.Divide(stack[sp-1], stack[sp])
+		case opModulo:
+			sp--
+			stack[sp-1], err = object.Modulo(stack[sp-1], stack[sp])
+		case opEqual, opNotEqual:
+			sp--
+			var eq bool
+			eq, err = object.Equals(stack[sp-1], stack[sp])
+			stack[sp-1] = object.Bool(eq == (in.op == opEqual))
+		case opLessThan, opGreaterThan, opLessOrEqual, opGreaterOrEqual:
+			sp--
+			var cmp int
+			cmp, err = object.Compare(stack[sp-1], stack[sp])
+			stack[sp-1] = object.Bool(compareOp(in.op, cmp))
+		case opNot:
+			stack[sp-1], err = stack[sp-1].Not()
+		case opPositive:
+			stack[sp-1], err = object.Positive(stack[sp-1])
+		case opNegate:
+			stack[sp-1], err = object.Negate(stack[sp-1])
+		case opToBool:
+			var truthy bool
+			truthy, err = stack[sp-1].ToBool()
+			stack[sp-1] = object.Bool(truthy)
+
+		case opJump:
+			pc = int(in.a)
+		case opJumpIfFalse, opJumpIfTrue:
+			sp--
+			var truthy bool
+			if truthy, err = stack[sp].ToBool(); err == nil && truthy == (in.op == opJumpIfTrue) {
+				pc = int(in.a)
+			}
+
+		case opIndex:
+			sp--
+			stack[sp-1], err = stack[sp-1].Index(stack[sp])
+		case opGetAttr:
+			stack[sp-1], err = stack[sp-1].GetAttr(p.names[in.a])
+		case opSetIndex:
+			sp -= 3
+			err = object.SetIndex(stack[sp], stack[sp+1], stack[sp+2])
+		case opSetAttr:
+			sp -= 2
+			err = object.SetAttr(stack[sp], p.names[in.a], stack[sp+1])
+
+		case opClosure:
+			stack[sp] = newClosure(p.protos[in.a], f.cells, c.upvalues, c.globals).function()
+			sp++
+
+		case opCall:
+			n := int(in.a)
+			if fn, ok := stack[sp-n-1].(*object.Function); ok && fn.Code != nil {
+				// A function the VM compiled runs on this thread,
+				// with its arguments copied from this stack.
+				stack[sp-n-1], err = fn.Code.(*closure).callPositional(th, stack[sp-n:sp])
+				sp -= n
+				break
+			}
+			var args object.Args
+			if n > 0 {
+				args = make(object.Args, n)
+				copy(args, stack[sp-n:sp])
+				sp -= n
+			}
+			stack[sp-1], err = object.Call(stack[sp-1], object.CallArgs{Positional: args})
+		case opArgs:
+			f.calls = append(f.calls, object.CallArgs{})
+		case opArg:
+			sp--
+			call := &f.calls[len(f.calls)-1]
+			call.Positional = append(call.Positional, stack[sp])
+		case opArgStar:
+			sp--
+			var items []object.Object
+			if items, err = stack[sp].Iter(); err == nil {
+				call := &f.calls[len(f.calls)-1]
+				call.Positional = append(call.Positional, items...)
+			}
+		case opArgKeyword:
+			sp--
+			err = f.calls[len(f.calls)-1].AddKeyword(p.names[in.a], stack[sp])
+		case opArgUnpack:
+			sp--
+			err = f.calls[len(f.calls)-1].UnpackKeywords(stack[sp])
+		case opCallArgs:
+			n := len(f.calls) - 1
+			args := f.calls[n]
+			f.calls = f.calls[:n]
+			stack[sp-1], err = object.Call(stack[sp-1], args)
+
+		case opReturn:
+			return stack[sp-1], token.Pos{}, nil
+		case opRaise:
+			sp--
+			err = object.Raise(stack[sp])
+
+		case opIter:
+			sp--
+			f.cursors[in.a], err = object.NewCursor(stack[sp])
+		case opForNext:
+			cursor := f.cursors[in.a]
+			if item, ok := cursor.Next(); ok {
+				stack[sp] = item
+				sp++
+			} else if err = cursor.Err(); err == nil {
+				pc = int(in.b)
+			}
+
+		case opTry:
+			f.tries = append(f.tries, int(in.a))
+		case opPopTry:
+			f.tries = f.tries[:len(f.tries)-1]
+
+		case opDefineType:
+			c.defineType(p.types[in.a], f.cells)
+		case opTrace:
+			c.globals.m.trace(p.positions[pc-1])
+		}
+
+		if err != nil {
+			// The innermost try catches the error, with the stack as its
+			// statement started, empty.
+			if n := len(f.tries); n > 0 && !isExit(err) {
+				pc = f.tries[n-1]
+				f.tries = f.tries[:n-1]
+				f.calls = f.calls[:0]
+				stack[0] = object.ErrorValue(err)
+				sp = 1
+				continue
+			}
+			return nil, p.positions[pc-1], err
+		}
+	}
+}
+
+func compareOp(op opcode, c int) bool {
+	switch op {
+	case opLessThan:
+		return c < 0
+	case opGreaterThan:
+		return c > 0
+	case opLessOrEqual:
+		return c <= 0
+	case opGreaterOrEqual:
+		return c >= 0
+	}
+	return false
+}
+
+// defineType defines the type t as a global; cells are those of the
+// frame defining it.
+func (c *closure) defineType(t *typeCode, cells []*cell) {
+	typ := newGoblinType(t.def, func(field int) object.ParamDefault {
+		return newClosure(t.defaults[field], cells, c.upvalues, c.globals).evaluate
+	}, func(in *instance, m *ast.FunctionDefine) *object.Function {
+		return newClosure(t.methods[m.Name], []*cell{{v: in}}, nil, c.globals).function()
+	})
+	c.globals.store(t.global, typ.constructor)
+}
+
+// runCompiled compiles a module and runs its top level in global, as
+// loadInto and evalStatements do for the tree walker.
+func runCompiled(mod *ast.Module, path string, global *Environment) error {
+	code, err := compileModule(mod, path, global.m.observing())
+	if err != nil {
+		return err
+	}
+	globals := &globalScope{m: global.m, names: code.globals.names, values: make([]object.Object, len(code.globals.names))}
+	for _, imp := range code.imports {
+		m, err := resolveImport(imp, filepath.Dir(path), global.m)
+		if err != nil {
+			return err
+		}
+		globals.store(code.globals.index[imp.Name], m)
+	}
+	main := newClosure(code.main, nil, nil, globals)
+	for _, i := range code.functions {
+		fn := newClosure(code.main.protos[i], nil, nil, globals).function()
+		globals.store(code.globals.index[fn.Name], fn)
+	}
+	for _, i := range code.types {
+		main.defineType(code.main.types[i], nil)
+	}
+
+	th := threads.Get().(*thread)
+	defer threads.Put(th)
+	f, slots, top := th.frame(code.main)
+	defer th.release(&f, slots, top)
+	if _, pos, err := main.run(th, &f); err != nil {
+		return object.WithFrame(err, stackFrame(moduleName(path), "<module>", pos))
+	}
+	for i, v := range globals.values {
+		if v != nil {
+			global.Define(globals.names[i], v)
+		}
+	}
+	return nil
+}
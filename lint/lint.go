@@ -0,0 +1,169 @@
+// Package lint runs the static checks behind `goblin check`: every error the
+// semantic checker finds, rather than only the first, plus lint rules for
+// code that is legal but probably wrong.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	parseError "github.com/aisk/goblin/errors"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/semantic"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/token"
+)
+
+type Severity string
+
+const (
+	// Error marks code that fails to parse, fails the semantic check, or
+	// fails at run time when reached.
+	Error Severity = "error"
+	// Warning marks code that runs but is probably a mistake.
+	Warning Severity = "warning"
+)
+
+// Rule names, as shown in output and named in suppression comments.
+const (
+	RuleSyntax          = "syntax"
+	RuleSemantic        = "semantic"
+	RuleUnknownModule   = "unknown-module"
+	RuleCallArity       = "call-arity"
+	RuleUnusedVariable  = "unused-variable"
+	RuleUnusedImport    = "unused-import"
+	RuleUnreachable     = "unreachable-code"
+	RuleShadowedBuiltin = "shadowed-builtin"
+)
+
+type Diagnostic struct {
+	Pos      token.Pos
+	Severity Severity
+	Rule     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	pos := fmt.Sprintf("%d:%d", d.Pos.Line, d.Pos.Column)
+	if src, ok := d.Pos.Context.(token.Sourcer); ok {
+		pos = src.Source() + ":" + pos
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", pos, d.Severity, d.Message, d.Rule)
+}
+
+// File checks the Goblin file at path.
+func File(path string) ([]Diagnostic, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Source(path, src), nil
+}
+
+// Source checks a Goblin file's source. filename names the file in
+// positions and locates its path imports; it may be empty. A file that does
+// not parse yields its syntax error alone. The result is sorted by position.
+func Source(filename string, src []byte) []Diagnostic {
+	s := source.NewScanner(src)
+	if filename != "" {
+		s.SetFilename(filename)
+	}
+	st, err := parser.NewParser().Parse(s)
+	if err != nil {
+		return []Diagnostic{syntaxDiagnostic(err)}
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return []Diagnostic{{Severity: Error, Rule: RuleSyntax, Message: "internal error: unexpected AST type"}}
+	}
+
+	var diags []Diagnostic
+	for _, diag := range semantic.Check(mod) {
+		diags = append(diags, Diagnostic{Pos: diag.Pos, Severity: Error, Rule: RuleSemantic, Message: diag.Message})
+	}
+	diags = append(diags, check(filename, mod)...)
+	diags = suppress(diags, src, s.Comments)
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Pos, diags[j].Pos
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diags
+}
+
+func syntaxDiagnostic(err error) Diagnostic {
+	var perr *parseError.Error
+	if !errors.As(err, &perr) {
+		return Diagnostic{Severity: Error, Rule: RuleSyntax, Message: err.Error()}
+	}
+	message := perr.Error()
+	// Error() leads with the position, which the diagnostic carries.
+	if i := strings.Index(message, "error: "); i >= 0 {
+		message = message[i+len("error: "):]
+	}
+	return Diagnostic{Pos: perr.ErrorToken.Pos, Severity: Error, Rule: RuleSyntax, Message: message}
+}
+
+// ignoreDirective starts a suppression comment. Alone on a line it applies
+// to the next line; after code, to its own. It silences every lint rule, or
+// only the comma-separated rules that follow it. Syntax and semantic errors
+// cannot be silenced, since the program fails on them regardless.
+const ignoreDirective = "goblin:ignore"
+
+func suppress(diags []Diagnostic, src []byte, comments []source.Comment) []Diagnostic {
+	// ignored maps a line to the rules silenced on it; "*" stands for all of
+	// them.
+	ignored := map[int]map[string]bool{}
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "#"))
+		if !strings.HasPrefix(text, ignoreDirective) {
+			continue
+		}
+		rest := strings.TrimPrefix(text, ignoreDirective)
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			continue
+		}
+		line := c.Pos.Line
+		if ownLine(src, c) {
+			line++
+		}
+		rules := ignored[line]
+		if rules == nil {
+			rules = map[string]bool{}
+			ignored[line] = rules
+		}
+		names := strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(names) == 0 {
+			rules["*"] = true
+		}
+		for _, name := range names {
+			rules[name] = true
+		}
+	}
+	kept := diags[:0]
+	for _, diag := range diags {
+		rules := ignored[diag.Pos.Line]
+		if diag.Rule != RuleSyntax && diag.Rule != RuleSemantic && (rules["*"] || rules[diag.Rule]) {
+			continue
+		}
+		kept = append(kept, diag)
+	}
+	return kept
+}
+
+// ownLine reports whether only indentation comes before a comment on its
+// line.
+func ownLine(src []byte, c source.Comment) bool {
+	for i := c.Pos.Offset - 1; i >= 0 && src[i] != '\n'; i-- {
+		if src[i] != ' ' && src[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
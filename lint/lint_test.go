@@ -0,0 +1,128 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func messages(diags []Diagnostic) []string {
+	var out []string
+	for _, diag := range diags {
+		out = append(out, diag.String())
+	}
+	return out
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name, src string
+		want      []string
+	}{
+		{
+			name: "clean",
+			src:  "import \"json\"\nvar _scratch = 1\nfunc f(a, b=2) {\n    return json.dumps([a, b])\n}\nprint(f(1))\n",
+		},
+		{
+			name: "syntax error stands alone",
+			src:  "var x = \nvar y = 1\n",
+			want: []string{`2:1: error: expected one of id, "[", "(", string_lit, "+", "-", "!", func, int_lit, float_lit, true, false, nil, or "{"; got: "var" (syntax)`},
+		},
+		{
+			name: "every semantic error",
+			src:  "print(a)\nb = 1\nexport c\n",
+			want: []string{
+				"1:7: error: undefined identifier: a (semantic)",
+				"2:1: error: assignment to undefined identifier: b (semantic)",
+				"3:8: error: export of undefined identifier: c (semantic)",
+			},
+		},
+		{
+			name: "unused",
+			src:  "import \"json\"\nvar x = 1\nfunc f(p) {\n    var y = 2\n    y = 3\n}\nf(x)\n",
+			want: []string{
+				"1:8: warning: json imported and not used (unused-import)",
+				"4:9: warning: y declared and not used (unused-variable)",
+			},
+		},
+		{
+			name: "exported names are used",
+			src:  "var x = 1\nexport x\n",
+		},
+		{
+			name: "unreachable",
+			src:  "func f(x) {\n    if x {\n        return 1\n    } else {\n        raise Error(\"no\")\n    }\n    print(1)\n    print(2)\n}\nfor i in [1] {\n    break\n    print(i)\n}\nprint(f(1))\n",
+			want: []string{
+				"7:5: warning: unreachable code (unreachable-code)",
+				"12:5: warning: unreachable code (unreachable-code)",
+			},
+		},
+		{
+			name: "shadowed builtins",
+			src:  "var max = 1\nfunc f(print) {\n    return print\n}\nf(max)\n",
+			want: []string{
+				"1:5: warning: max shadows the builtin max (shadowed-builtin)",
+				"2:8: warning: print shadows the builtin print (shadowed-builtin)",
+			},
+		},
+		{
+			name: "arity",
+			src:  "func f(a, b=1, **kw) {}\ntype P(x, y) {}\nf()\nf(1, 2, 3)\nf(1, a=2)\nf(1, c=3)\nP(1)\nP(1, z=2)\nP(*[1, 2])\n",
+			want: []string{
+				"3:1: error: f() missing required positional argument: 'a' (call-arity)",
+				"4:1: error: f() takes 2 positional arguments, got 3 (call-arity)",
+				"5:1: error: f() got multiple values for argument 'a' (call-arity)",
+				"7:1: error: P() missing required positional argument: 'y' (call-arity)",
+				"8:1: error: P() got an unexpected keyword argument 'z' (call-arity)",
+			},
+		},
+		{
+			name: "reassigned functions are not checked",
+			src:  "func f() {}\nf = func(x) {}\nf(1)\n",
+		},
+		{
+			name: "unknown modules",
+			src:  "import \"jsn\"\nimport \"./missing\"\nprint(jsn, missing)\n",
+			want: []string{
+				"1:8: error: unknown module: jsn (unknown-module)",
+				"2:8: error: cannot find module file missing.goblin (unknown-module)",
+			},
+		},
+		{
+			name: "suppressions",
+			src:  "var a = 1  # goblin:ignore\n# goblin:ignore unused-variable\nvar b = 2\n# goblin:ignore shadowed-builtin\nvar min = 3\nprint(c)  # goblin:ignore\n",
+			want: []string{
+				"5:5: warning: min declared and not used (unused-variable)",
+				"6:7: error: undefined identifier: c (semantic)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := messages(Source("", []byte(tt.src)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Source() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestFileResolvesPathImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.goblin"), []byte("var x = 1\nexport x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.goblin")
+	if err := os.WriteFile(main, []byte("import \"./lib\"\nprint(lib.x)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	diags, err := File(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("File() = %v, want no diagnostics", messages(diags))
+	}
+}
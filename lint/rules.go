@@ -0,0 +1,428 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/token"
+	"github.com/aisk/goblin/transpiler"
+)
+
+type bindingKind int
+
+const (
+	bindVariable bindingKind = iota
+	bindImport
+	bindFunction
+	bindType
+	bindParameter
+)
+
+type binding struct {
+	name string
+	kind bindingKind
+	pos  token.Pos
+	used bool
+	// assigned is set once the name is rebound, after which calls through it
+	// can no longer be checked against the definition.
+	assigned bool
+	fn       *ast.FunctionDefine
+	typ      *ast.TypeDefine
+}
+
+type scope struct {
+	parent   *scope
+	bindings map[string]*binding
+	order    []*binding
+}
+
+func (s *scope) lookup(name string) *binding {
+	for cur := s; cur != nil; cur = cur.parent {
+		if b, ok := cur.bindings[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type call struct {
+	pos    token.Pos
+	target *binding
+	args   []ast.CallArgument
+}
+
+// walker applies the lint rules in one pass over a module that passed
+// parsing. Its scopes follow the semantic checker's, so a name resolves to
+// the declaration the backends would bind it to.
+type walker struct {
+	filename string
+	scope    *scope
+	calls    []call
+	diags    []Diagnostic
+}
+
+func check(filename string, mod *ast.Module) []Diagnostic {
+	w := &walker{filename: filename}
+	w.push()
+	// Module-level imports, functions, and types are hoisted, as in the
+	// semantic checker.
+	hoisted := map[ast.Statement]*binding{}
+	for _, stmt := range mod.Body {
+		switch v := stmt.(type) {
+		case *ast.Import:
+			hoisted[stmt] = w.declare(v.Name, bindImport, v.Position())
+		case *ast.FunctionDefine:
+			b := w.declare(v.Name, bindFunction, v.Position())
+			b.fn = v
+			hoisted[stmt] = b
+		case *ast.TypeDefine:
+			b := w.declare(v.Name, bindType, v.Position())
+			b.typ = v
+			hoisted[stmt] = b
+		}
+	}
+	w.statements(mod.Body, hoisted)
+	w.pop()
+	// Calls are checked last, once every reassignment has been seen.
+	for _, c := range w.calls {
+		if !c.target.assigned {
+			w.checkArity(c)
+		}
+	}
+	return w.diags
+}
+
+func (w *walker) report(pos token.Pos, severity Severity, rule, format string, args ...any) {
+	w.diags = append(w.diags, Diagnostic{Pos: pos, Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (w *walker) push() {
+	w.scope = &scope{parent: w.scope, bindings: map[string]*binding{}}
+}
+
+// pop closes the current scope, reporting the variables and imports in it
+// that were never read. A leading underscore marks a name as deliberately
+// unused.
+func (w *walker) pop() {
+	for _, b := range w.scope.order {
+		if b.used || strings.HasPrefix(b.name, "_") {
+			continue
+		}
+		switch b.kind {
+		case bindVariable:
+			w.report(b.pos, Warning, RuleUnusedVariable, "%s declared and not used", b.name)
+		case bindImport:
+			w.report(b.pos, Warning, RuleUnusedImport, "%s imported and not used", b.name)
+		}
+	}
+	w.scope = w.scope.parent
+}
+
+func (w *walker) declare(name string, kind bindingKind, pos token.Pos) *binding {
+	if _, ok := extension.BuiltinsModule.Members[name]; ok {
+		w.report(pos, Warning, RuleShadowedBuiltin, "%s shadows the builtin %s", name, name)
+	}
+	b := &binding{name: name, kind: kind, pos: pos}
+	w.scope.bindings[name] = b
+	w.scope.order = append(w.scope.order, b)
+	return b
+}
+
+// statements walks a statement list. hoisted holds the bindings made before
+// the walk for statements in the list; it is nil except at module scope.
+func (w *walker) statements(list []ast.Statement, hoisted map[ast.Statement]*binding) {
+	dead, reported := false, false
+	for _, stmt := range list {
+		// Only the first statement of a dead stretch is reported. Implicit
+		// statements, like the return closing a function body, were never
+		// written.
+		if dead && !reported && stmt.Position().Line != 0 {
+			w.report(stmt.Position(), Warning, RuleUnreachable, "unreachable code")
+			reported = true
+		}
+		w.statement(stmt, hoisted)
+		dead = dead || terminates(stmt)
+	}
+}
+
+// terminates reports whether control never continues past stmt.
+func terminates(stmt ast.Statement) bool {
+	switch v := stmt.(type) {
+	case *ast.Return, *ast.Raise, *ast.Break, *ast.Continue:
+		return true
+	case *ast.IfElse:
+		return v.ElseBody != nil && blockTerminates(v.IfBody) && blockTerminates(v.ElseBody)
+	case *ast.TryCatch:
+		return blockTerminates(v.TryBody) && blockTerminates(v.CatchBody)
+	}
+	return false
+}
+
+func blockTerminates(body []ast.Statement) bool {
+	for _, stmt := range body {
+		if terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walker) block(body []ast.Statement) {
+	w.push()
+	w.statements(body, nil)
+	w.pop()
+}
+
+func (w *walker) statement(stmt ast.Statement, hoisted map[ast.Statement]*binding) {
+	switch v := stmt.(type) {
+	case *ast.Import:
+		if hoisted[stmt] == nil {
+			w.declare(v.Name, bindImport, v.Position())
+		}
+		w.checkImport(v)
+	case *ast.Declare:
+		w.expr(v.Value)
+		w.declare(v.Name, bindVariable, v.Position())
+	case *ast.Assign:
+		if b := w.scope.lookup(v.Target); b != nil {
+			b.assigned = true
+		}
+		w.expr(v.Value)
+	case *ast.SetIndex:
+		w.expr(v.Object)
+		w.expr(v.Index)
+		w.expr(v.Value)
+	case *ast.SetAttr:
+		w.expr(v.Object)
+		w.expr(v.Value)
+	case *ast.FunctionDefine:
+		if hoisted[stmt] == nil {
+			b := w.declare(v.Name, bindFunction, v.Position())
+			b.fn = v
+		}
+		w.function(v.Parameters, v.Body)
+	case *ast.TypeDefine:
+		if hoisted[stmt] == nil {
+			b := w.declare(v.Name, bindType, v.Position())
+			b.typ = v
+		}
+		for _, field := range v.Fields {
+			if field.HasDefault() {
+				w.expr(field.DefaultValue)
+			}
+		}
+		for _, method := range v.Methods {
+			w.function(method.Parameters, method.Body)
+		}
+	case *ast.IfElse:
+		w.expr(v.Condition)
+		w.block(v.IfBody)
+		w.block(v.ElseBody)
+	case *ast.While:
+		w.expr(v.Condition)
+		w.block(v.Body)
+	case *ast.For:
+		w.expr(v.Iterator)
+		w.push()
+		w.declare(v.Variable, bindParameter, v.Position())
+		w.block(v.Body)
+		w.pop()
+	case *ast.Return:
+		w.expr(v.Value)
+	case *ast.Raise:
+		w.expr(v.Value)
+	case *ast.TryCatch:
+		w.block(v.TryBody)
+		w.push()
+		w.declare(v.CatchVar, bindParameter, v.CatchVarPos)
+		w.statements(v.CatchBody, nil)
+		w.pop()
+	case *ast.Export:
+		if b := w.scope.lookup(v.Name); b != nil {
+			b.used = true
+		}
+	case ast.Expression:
+		w.expr(v)
+	}
+}
+
+// function walks a function body. Parameter defaults are evaluated in the
+// defining scope; the parameters belong to the body's.
+func (w *walker) function(params []*ast.Parameter, body []ast.Statement) {
+	for _, param := range params {
+		if param.HasDefault() {
+			w.expr(param.Default)
+		}
+	}
+	w.push()
+	for _, param := range params {
+		w.declare(param.Name, bindParameter, param.Pos)
+	}
+	w.statements(body, nil)
+	w.pop()
+}
+
+func (w *walker) expr(e ast.Expression) {
+	switch v := e.(type) {
+	case *ast.Identifier:
+		if b := w.scope.lookup(v.Name); b != nil {
+			b.used = true
+		}
+	case *ast.FunctionCall:
+		if b := w.scope.lookup(v.Name); b != nil {
+			b.used = true
+			w.call(v.Position(), b, v.Args)
+		}
+		w.arguments(v.Args)
+	case *ast.CallExpression:
+		w.expr(v.Callee)
+		if id, ok := v.Callee.(*ast.Identifier); ok {
+			if b := w.scope.lookup(id.Name); b != nil {
+				w.call(v.Position(), b, v.Args)
+			}
+		}
+		w.arguments(v.Args)
+	case *ast.BinaryOperation:
+		w.expr(v.LHS)
+		w.expr(v.RHS)
+	case *ast.UnaryOperation:
+		w.expr(v.Operand)
+	case *ast.ListLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem)
+		}
+	case *ast.DictLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem.Key)
+			w.expr(elem.Value)
+		}
+	case *ast.IndexExpression:
+		w.expr(v.Object)
+		w.expr(v.Index)
+	case *ast.MemberExpression:
+		w.expr(v.Object)
+	case *ast.FunctionLiteral:
+		w.function(v.Parameters, v.Body)
+	}
+}
+
+func (w *walker) arguments(args []ast.CallArgument) {
+	for _, arg := range args {
+		w.expr(arg.Expr)
+	}
+}
+
+// call records a call to a function or type defined in this file, to check
+// its arguments once the walk is over. Unpacked arguments make the count
+// unknowable, so such calls are left alone.
+func (w *walker) call(pos token.Pos, target *binding, args []ast.CallArgument) {
+	if target.fn == nil && target.typ == nil {
+		return
+	}
+	for _, arg := range args {
+		if arg.Kind == ast.CallArgumentStarred || arg.Kind == ast.CallArgumentKeywordUnpack {
+			return
+		}
+	}
+	w.calls = append(w.calls, call{pos: pos, target: target, args: args})
+}
+
+// checkArity binds a call's arguments the way object.BindArguments does at
+// run time, and reports the error it would raise in the same words.
+func (w *walker) checkArity(c call) {
+	var params []string
+	var defaults []bool
+	varArgs, kwArgs := false, false
+	if fn := c.target.fn; fn != nil {
+		for _, param := range fn.Parameters {
+			switch {
+			case param.VarArgs:
+				varArgs = true
+			case param.KwArgs:
+				kwArgs = true
+			default:
+				params = append(params, param.Name)
+				defaults = append(defaults, param.HasDefault())
+			}
+		}
+	} else {
+		for _, field := range c.target.typ.Fields {
+			params = append(params, field.Name)
+			defaults = append(defaults, field.HasDefault())
+		}
+	}
+	name := c.target.name
+
+	bound := map[string]bool{}
+	positional := 0
+	for _, arg := range c.args {
+		if arg.Kind == ast.CallArgumentPositional {
+			if positional < len(params) {
+				bound[params[positional]] = true
+			}
+			positional++
+		}
+	}
+	if !varArgs && positional > len(params) {
+		w.report(c.pos, Error, RuleCallArity, "%s() takes %d positional arguments, got %d", name, len(params), positional)
+		return
+	}
+	for _, arg := range c.args {
+		if arg.Kind != ast.CallArgumentKeyword {
+			continue
+		}
+		known := false
+		for _, param := range params {
+			known = known || param == arg.Name
+		}
+		switch {
+		case known && bound[arg.Name]:
+			w.report(c.pos, Error, RuleCallArity, "%s() got multiple values for argument '%s'", name, arg.Name)
+			return
+		case !known && !kwArgs:
+			w.report(c.pos, Error, RuleCallArity, "%s() got an unexpected keyword argument '%s'", name, arg.Name)
+			return
+		}
+		bound[arg.Name] = true
+	}
+	for i, param := range params {
+		if !bound[param] && !defaults[i] {
+			w.report(c.pos, Error, RuleCallArity, "%s() missing required positional argument: '%s'", name, param)
+			return
+		}
+	}
+}
+
+// checkImport reports an import neither backend could load.
+func (w *walker) checkImport(imp *ast.Import) {
+	if source.IsPathImport(imp.Path) {
+		path := filepath.Join(filepath.Dir(w.filename), imp.Path) + ".goblin"
+		if _, err := os.Stat(path); err != nil {
+			w.report(imp.Position(), Error, RuleUnknownModule, "cannot find module file %s", path)
+		}
+		return
+	}
+	if !isStdlibModule(imp.Path) {
+		w.report(imp.Position(), Error, RuleUnknownModule, "unknown module: %s", imp.Path)
+	}
+}
+
+// isStdlibModule reports whether import can name a module: one in the
+// transpiler's table, which a test keeps in step with the interpreter's, or
+// os, which both backends bind per program.
+func isStdlibModule(name string) bool {
+	if name == "os" {
+		return true
+	}
+	for _, known := range transpiler.KnownModuleNames() {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
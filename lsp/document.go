@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is the text of one source file. LSP positions count UTF-16 code
+// units within a line while the AST records byte offsets, so every position
+// crossing the protocol boundary is converted here.
+type document struct {
+	uri        string
+	path       string
+	text       string
+	lineStarts []int
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, path: uriToPath(uri), text: text, lineStarts: []int{0}}
+	if d.path == "" {
+		// An unsaved buffer has no file; its URI still identifies it.
+		d.path = uri
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	return d
+}
+
+// position converts a byte offset to an LSP position.
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	line := 0
+	for line+1 < len(d.lineStarts) && d.lineStarts[line+1] <= offset {
+		line++
+	}
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16.RuneLen(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset converts an LSP position to a byte offset, clamping positions past
+// the end of a line or of the text.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// wordEnd returns the end of the identifier starting at offset, or offset+1
+// when no identifier starts there, so a diagnostic always spans something.
+func (d *document) wordEnd(offset int) int {
+	end := offset
+	for end < len(d.text) && isIdentifierByte(d.text[end]) {
+		end++
+	}
+	if end == offset && end < len(d.text) {
+		end++
+	}
+	return end
+}
+
+func isIdentifierByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
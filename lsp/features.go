@@ -0,0 +1,447 @@
+package lsp
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/interpreter"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/source"
+)
+
+var keywords = []string{
+	"break", "catch", "continue", "else", "export", "false", "for", "func",
+	"if", "import", "in", "nil", "raise", "return", "true", "try", "type",
+	"var", "while",
+}
+
+// importPrefix matches a line whose cursor sits inside an import's string.
+var importPrefix = regexp.MustCompile(`^\s*import\s+"([^"]*)$`)
+
+func (s *server) completion(params textDocumentPositionParams) []CompletionItem {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return []CompletionItem{}
+	}
+	offset := doc.offset(params.Position)
+	line := doc.text[doc.lineStarts[doc.position(offset).Line]:offset]
+
+	items := []CompletionItem{}
+	add := func(label string, kind int, detail string) {
+		items = append(items, CompletionItem{Label: label, Kind: kind, Detail: detail})
+	}
+	if m := importPrefix.FindStringSubmatch(line); m != nil {
+		for _, name := range stdlibModules() {
+			if strings.HasPrefix(name, m[1]) {
+				add(name, completionModule, "module")
+			}
+		}
+		return sortItems(items)
+	}
+	path, prefix, ok := completionPath(line)
+	if !ok {
+		return items
+	}
+
+	idx := s.indexFor(doc.uri)
+	if idx == nil || idx.module == nil {
+		idx = s.lastGood[doc.uri]
+	}
+	var visible []*definition
+	if idx != nil {
+		visible = idx.visible(offset)
+	}
+
+	if len(path) > 0 {
+		for _, item := range s.memberItems(visible, path) {
+			if strings.HasPrefix(item.Label, prefix) {
+				items = append(items, item)
+			}
+		}
+		return sortItems(items)
+	}
+
+	seen := map[string]bool{}
+	for _, def := range visible {
+		if strings.HasPrefix(def.name, prefix) {
+			seen[def.name] = true
+			add(def.name, completionKind(def), "")
+		}
+	}
+	for _, name := range s.builtins.CompletionCandidates(nil) {
+		if !seen[name] && strings.HasPrefix(name, prefix) {
+			seen[name] = true
+			value, _ := s.builtins.Lookup([]string{name})
+			add(name, nativeKind(value), "builtin")
+		}
+	}
+	for _, name := range keywords {
+		if !seen[name] && strings.HasPrefix(name, prefix) {
+			add(name, completionKeyword, "")
+		}
+	}
+	return sortItems(items)
+}
+
+// memberItems lists the members of the object a member path starts from.
+func (s *server) memberItems(visible []*definition, path []string) []CompletionItem {
+	var def *definition
+	for _, candidate := range visible {
+		if candidate.name == path[0] {
+			def = candidate
+			break
+		}
+	}
+	var items []CompletionItem
+	switch {
+	case def == nil:
+		items = nativeItems(s.builtins, path)
+	case def.kind == defImport && source.IsPathImport(def.imp.Path):
+		if len(path) > 1 {
+			return nil
+		}
+		if target := s.ws.index(importPath(def.doc.path, def.imp)); target != nil {
+			for name, exported := range target.exports {
+				items = append(items, CompletionItem{Label: name, Kind: completionKind(exported)})
+			}
+		}
+	case def.kind == defImport:
+		if s.importModule(def.imp.Path) {
+			items = nativeItems(s.modules, path)
+		}
+	case def.kind == defParameter && def.owner != nil && len(path) == 1:
+		for _, member := range memberDefs(def.owner, def.doc) {
+			items = append(items, CompletionItem{Label: member.name, Kind: completionKind(member)})
+		}
+	}
+	return items
+}
+
+func nativeItems(session *interpreter.Session, path []string) []CompletionItem {
+	var items []CompletionItem
+	for _, name := range session.CompletionCandidates(path) {
+		value, _ := session.Lookup(append(append([]string{}, path...), name))
+		items = append(items, CompletionItem{Label: name, Kind: nativeKind(value)})
+	}
+	return items
+}
+
+// memberDefs lists a type's fields and methods in declaration order.
+func memberDefs(t *ast.TypeDefine, doc *document) []*definition {
+	var defs []*definition
+	for _, field := range t.Fields {
+		defs = append(defs, &definition{name: field.Name, kind: defField, doc: doc, field: field, owner: t})
+	}
+	for _, method := range t.Methods {
+		defs = append(defs, &definition{name: method.Name, kind: defMethod, doc: doc, function: method, owner: t})
+	}
+	return defs
+}
+
+func completionKind(def *definition) int {
+	switch def.kind {
+	case defFunction:
+		return completionFunction
+	case defType:
+		return completionClass
+	case defImport:
+		return completionModule
+	case defField:
+		return completionField
+	case defMethod:
+		return completionMethod
+	default:
+		return completionVariable
+	}
+}
+
+func nativeKind(value object.Object) int {
+	switch value.(type) {
+	case *object.Function:
+		return completionFunction
+	case *object.Module:
+		return completionModule
+	default:
+		return completionVariable
+	}
+}
+
+func sortItems(items []CompletionItem) []CompletionItem {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// completionPath splits the identifier path ending at the cursor, as in
+// `user.na`, into its complete segments and the prefix being typed.
+func completionPath(line string) (path []string, prefix string, ok bool) {
+	start := len(line)
+	for start > 0 && (isIdentifierByte(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	parts := strings.Split(line[start:], ".")
+	for i, part := range parts {
+		if part == "" && i != len(parts)-1 {
+			return nil, "", false
+		}
+		if part != "" && part[0] >= '0' && part[0] <= '9' {
+			return nil, "", false
+		}
+	}
+	return parts[:len(parts)-1], parts[len(parts)-1], true
+}
+
+func (s *server) hover(params textDocumentPositionParams) *Hover {
+	idx := s.indexFor(params.TextDocument.URI)
+	if idx == nil || idx.module == nil {
+		return nil
+	}
+	ref, ok := idx.referenceAt(idx.doc.offset(params.Position))
+	if !ok {
+		return nil
+	}
+	var text string
+	switch {
+	case ref.def != nil:
+		text = describe(ref.def)
+	case ref.native != nil:
+		text = s.describeNative(ref.native)
+	}
+	if text == "" {
+		return nil
+	}
+	rng := idx.doc.rangeOf(ref.start, ref.end)
+	return &Hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: &rng}
+}
+
+// describe renders a definition's signature, followed by its doc comment.
+func describe(def *definition) string {
+	var code string
+	switch def.kind {
+	case defFunction:
+		code = "func " + def.name + "(" + signature(def.doc, def.function.Parameters) + ")"
+	case defMethod:
+		code = "func " + def.owner.Name + "." + def.name + "(" + signature(def.doc, def.function.Parameters) + ")"
+	case defType:
+		code = "type " + def.name + "(" + fieldList(def.doc, def.typ.Fields) + ")"
+	case defField:
+		code = "(field) " + def.owner.Name + "." + def.name
+	case defParameter:
+		code = "(parameter) " + def.name
+	case defImport:
+		code = "import " + strconv.Quote(def.imp.Path)
+	default:
+		code = "var " + def.name
+	}
+	text := "```goblin\n" + code + "\n```"
+	if comment := docOf(def); comment != "" {
+		text += "\n\n" + comment
+	}
+	return text
+}
+
+// docOf returns the doc comment of a definition, as doc.Attach found it.
+func docOf(def *definition) string {
+	switch def.kind {
+	case defFunction, defMethod:
+		return def.function.Doc
+	case defType:
+		return def.typ.Doc
+	case defVariable:
+		return def.exportDoc
+	}
+	return ""
+}
+
+func (s *server) describeNative(path []string) string {
+	session := s.builtins
+	if len(path) > 1 && isStdlibModule(path[0]) && s.importModule(path[0]) {
+		session = s.modules
+	}
+	value, ok := session.Lookup(path)
+	if !ok {
+		return ""
+	}
+	return "```goblin\n" + strings.Join(path, ".") + ": " + value.TypeName() + "\n```"
+}
+
+// signature formats a parameter list, copying default values from the
+// source as written.
+func signature(doc *document, params []*ast.Parameter) string {
+	parts := make([]string, len(params))
+	for i, param := range params {
+		switch {
+		case param.VarArgs:
+			parts[i] = "*" + param.Name
+		case param.KwArgs:
+			parts[i] = "**" + param.Name
+		case param.HasDefault():
+			parts[i] = param.Name + "=" + defaultText(doc, param.Pos.Offset+len(param.Name), param.Default)
+		default:
+			parts[i] = param.Name
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func fieldList(doc *document, fields []*ast.TypeField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Name
+		if field.HasDefault() {
+			parts[i] += "=" + defaultText(doc, field.Pos.Offset+len(field.Name), field.DefaultValue)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// defaultText is the source of a default value: whatever follows the
+// parameter name and its "=" up to the end of the expression.
+func defaultText(doc *document, after int, value ast.Expression) string {
+	end := value.EndPosition().Offset
+	if end <= after || end > len(doc.text) {
+		return "..."
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(doc.text[after:end]), "="))
+}
+
+func (s *server) definition(params textDocumentPositionParams) []Location {
+	idx := s.indexFor(params.TextDocument.URI)
+	if idx == nil || idx.module == nil {
+		return []Location{}
+	}
+	ref, ok := idx.referenceAt(idx.doc.offset(params.Position))
+	if !ok || ref.def == nil {
+		return []Location{}
+	}
+	return []Location{locationOf(ref.def.doc, ref.def.start, ref.def.end)}
+}
+
+func locationOf(doc *document, start, end int) Location {
+	return Location{URI: doc.uri, Range: doc.rangeOf(start, end)}
+}
+
+// references searches the open documents and every .goblin file under the
+// workspace root, which covers all files that can import the definition.
+func (s *server) references(params referenceParams) []Location {
+	locations := []Location{}
+	idx := s.indexFor(params.TextDocument.URI)
+	if idx == nil || idx.module == nil {
+		return locations
+	}
+	ref, ok := idx.referenceAt(idx.doc.offset(params.Position))
+	if !ok || ref.def == nil {
+		return locations
+	}
+	for _, path := range s.workspaceFiles() {
+		file := s.ws.index(path)
+		if file == nil {
+			continue
+		}
+		for _, other := range file.refs {
+			if other.def == ref.def && (params.Context.IncludeDeclaration || !other.decl) {
+				locations = append(locations, locationOf(file.doc, other.start, other.end))
+			}
+		}
+	}
+	return locations
+}
+
+// workspaceFiles lists the paths of the open documents and of the .goblin
+// files under the root, skipping hidden directories.
+func (s *server) workspaceFiles() []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, doc := range s.docs {
+		seen[doc.path] = true
+		paths = append(paths, doc.path)
+	}
+	if s.root != "" {
+		filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if entry.IsDir() && path != s.root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && strings.HasSuffix(path, ".goblin") && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+			return nil
+		})
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *server) documentSymbols(uri string) []DocumentSymbol {
+	idx := s.indexFor(uri)
+	if idx == nil || idx.module == nil {
+		return []DocumentSymbol{}
+	}
+	return nonNilSymbols(symbolsOf(idx.doc, idx.module.Body))
+}
+
+// symbolsOf lists the functions and types declared in stmts, with functions
+// nested in blocks and bodies as children of their enclosing declaration.
+func symbolsOf(doc *document, stmts []ast.Statement) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, stmt := range stmts {
+		switch v := stmt.(type) {
+		case *ast.FunctionDefine:
+			symbols = append(symbols, functionSymbol(doc, v, symbolFunction))
+		case *ast.TypeDefine:
+			sym := declSymbol(doc, v, v.Name, symbolClass)
+			sym.Detail = "(" + fieldList(doc, v.Fields) + ")"
+			for _, field := range v.Fields {
+				rng := doc.rangeOf(field.Pos.Offset, field.Pos.Offset+len(field.Name))
+				sym.Children = append(sym.Children, DocumentSymbol{Name: field.Name, Kind: symbolField, Range: rng, SelectionRange: rng})
+			}
+			for _, method := range v.Methods {
+				sym.Children = append(sym.Children, functionSymbol(doc, method, symbolMethod))
+			}
+			symbols = append(symbols, sym)
+		case *ast.IfElse:
+			symbols = append(symbols, symbolsOf(doc, v.IfBody)...)
+			symbols = append(symbols, symbolsOf(doc, v.ElseBody)...)
+		case *ast.While:
+			symbols = append(symbols, symbolsOf(doc, v.Body)...)
+		case *ast.For:
+			symbols = append(symbols, symbolsOf(doc, v.Body)...)
+		case *ast.TryCatch:
+			symbols = append(symbols, symbolsOf(doc, v.TryBody)...)
+			symbols = append(symbols, symbolsOf(doc, v.CatchBody)...)
+		}
+	}
+	return symbols
+}
+
+func functionSymbol(doc *document, fn *ast.FunctionDefine, kind int) DocumentSymbol {
+	sym := declSymbol(doc, fn, fn.Name, kind)
+	sym.Detail = "(" + signature(doc, fn.Parameters) + ")"
+	sym.Children = symbolsOf(doc, fn.Body)
+	return sym
+}
+
+// declSymbol spans a declaration from its name to its closing brace.
+func declSymbol(doc *document, stmt ast.Statement, name string, kind int) DocumentSymbol {
+	start := stmt.Position().Offset
+	return DocumentSymbol{
+		Name:           name,
+		Kind:           kind,
+		Range:          doc.rangeOf(start, stmt.EndPosition().Offset),
+		SelectionRange: doc.rangeOf(start, start+len(name)),
+	}
+}
+
+func nonNilSymbols(symbols []DocumentSymbol) []DocumentSymbol {
+	if symbols == nil {
+		return []DocumentSymbol{}
+	}
+	return symbols
+}
@@ -0,0 +1,552 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	goblindoc "github.com/aisk/goblin/doc"
+	parseError "github.com/aisk/goblin/errors"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/semantic"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/transpiler"
+)
+
+type defKind int
+
+const (
+	defVariable defKind = iota
+	defFunction
+	defType
+	defParameter
+	defImport
+	defField
+	defMethod
+)
+
+// definition is a name a Goblin source declares: a variable, function, type,
+// parameter, import, or a type's field or method.
+type definition struct {
+	name       string
+	kind       defKind
+	doc        *document
+	start, end int // the name, as byte offsets into doc
+	// hoisted definitions are visible before the statement declaring them,
+	// as module-level imports, functions, and types are.
+	hoisted bool
+	// Exactly one of these is set, depending on kind.
+	function *ast.FunctionDefine
+	typ      *ast.TypeDefine
+	param    *ast.Parameter
+	field    *ast.TypeField
+	imp      *ast.Import
+	// owner is the type a field or method belongs to, or the type whose
+	// method declares a `self` parameter.
+	owner *ast.TypeDefine
+	// exportDoc documents a module-level variable: the comment above its
+	// export, as goblin doc shows it.
+	exportDoc string
+}
+
+// reference is one occurrence of a name in a file. def is nil for names that
+// resolve outside Goblin source — builtins and stdlib module members — whose
+// path native records instead.
+type reference struct {
+	start, end int
+	def        *definition
+	decl       bool
+	native     []string
+}
+
+// scopeRange is a scope's extent in the file with the definitions made in
+// it, in declaration order, for completion at a cursor.
+type scopeRange struct {
+	start, end int
+	defs       []*definition
+}
+
+// fileIndex is everything the server knows about one parsed file.
+type fileIndex struct {
+	doc         *document
+	module      *ast.Module // nil when the file does not parse
+	diagnostics []Diagnostic
+	refs        []reference
+	scopes      []*scopeRange
+	exports     map[string]*definition
+	// members maps each type to its fields and methods by name.
+	members map[*ast.TypeDefine]map[string]*definition
+}
+
+// workspace builds and caches file indexes. Open documents take precedence
+// over the files on disk. The cache is dropped whenever a document changes,
+// so the indexes of files importing each other always agree.
+type workspace struct {
+	open  map[string]*document // by path
+	cache map[string]*fileIndex
+}
+
+func newWorkspace() *workspace {
+	return &workspace{open: map[string]*document{}, cache: map[string]*fileIndex{}}
+}
+
+func (w *workspace) invalidate() {
+	w.cache = map[string]*fileIndex{}
+}
+
+// index returns the index of the file at path, or nil when it cannot be read.
+func (w *workspace) index(path string) *fileIndex {
+	if idx, ok := w.cache[path]; ok {
+		return idx
+	}
+	doc, ok := w.open[path]
+	if !ok {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		doc = newDocument(pathToURI(path), string(src))
+	}
+	idx := &fileIndex{doc: doc, exports: map[string]*definition{}, members: map[*ast.TypeDefine]map[string]*definition{}}
+	// Cache before walking so an import cycle finds this partial index rather
+	// than recursing.
+	w.cache[path] = idx
+	idx.build(w)
+	return idx
+}
+
+func (idx *fileIndex) build(w *workspace) {
+	doc := idx.doc
+	scanner := source.NewScanner([]byte(doc.text))
+	scanner.SetFilename(doc.path)
+	st, err := parser.NewParser().Parse(scanner)
+	if err != nil {
+		idx.diagnostics = append(idx.diagnostics, parseDiagnostic(doc, err))
+		return
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return
+	}
+	idx.module = mod
+	// Hover shows the doc comments help() and goblin doc do.
+	goblindoc.Attach(mod, scanner)
+	if err := semantic.CheckModule(mod); err != nil {
+		if semErr, ok := err.(*semantic.Error); ok {
+			start := semErr.Diagnostic.Pos.Offset
+			idx.diagnostics = append(idx.diagnostics, Diagnostic{
+				Range:    doc.rangeOf(start, doc.wordEnd(start)),
+				Severity: severityError,
+				Source:   "goblin",
+				Message:  semErr.Diagnostic.Message,
+			})
+		}
+	}
+	wk := &walker{ws: w, idx: idx, predeclared: map[any]*definition{}}
+	wk.module(mod)
+}
+
+func parseDiagnostic(doc *document, err error) Diagnostic {
+	diag := Diagnostic{Severity: severityError, Source: "goblin", Message: err.Error()}
+	if perr, ok := err.(*parseError.Error); ok && perr.ErrorToken != nil {
+		start := perr.ErrorToken.Pos.Offset
+		diag.Range = doc.rangeOf(start, start+len(perr.ErrorToken.Lit))
+		// Error() prefixes the file position, which the range already carries.
+		diag.Message = messageAfterPosition(diag.Message)
+	}
+	return diag
+}
+
+func messageAfterPosition(message string) string {
+	const marker = "error: "
+	if i := strings.Index(message, marker); i >= 0 {
+		return message[i+len(marker):]
+	}
+	return message
+}
+
+// importPath resolves a path import relative to the importing file, the way
+// both backends do.
+func importPath(from string, imp *ast.Import) string {
+	return filepath.Join(filepath.Dir(from), imp.Path) + ".goblin"
+}
+
+// scope is a lexical scope during the walk.
+type scope struct {
+	parent *scope
+	names  map[string]*definition
+	rng    *scopeRange
+}
+
+func (s *scope) lookup(name string) *definition {
+	for cur := s; cur != nil; cur = cur.parent {
+		if def, ok := cur.names[name]; ok {
+			return def
+		}
+	}
+	return nil
+}
+
+// walker resolves every name in a module, mirroring the scoping rules of the
+// semantic checker.
+type walker struct {
+	ws    *workspace
+	idx   *fileIndex
+	scope *scope
+	// predeclared holds the module-level definitions, created before the
+	// walk so exports resolve even through an import cycle.
+	predeclared map[any]*definition
+}
+
+func (w *walker) push(start, end int) {
+	rng := &scopeRange{start: start, end: end}
+	w.idx.scopes = append(w.idx.scopes, rng)
+	w.scope = &scope{parent: w.scope, names: map[string]*definition{}, rng: rng}
+}
+
+func (w *walker) pop() {
+	w.scope = w.scope.parent
+}
+
+func (w *walker) declare(def *definition) {
+	w.scope.names[def.name] = def
+	w.scope.rng.defs = append(w.scope.rng.defs, def)
+	w.idx.refs = append(w.idx.refs, reference{start: def.start, end: def.end, def: def, decl: true})
+}
+
+func (w *walker) refer(start int, name string, def *definition) {
+	w.idx.refs = append(w.idx.refs, reference{start: start, end: start + len(name), def: def})
+}
+
+func (w *walker) newDef(name string, kind defKind, start int) *definition {
+	return &definition{name: name, kind: kind, doc: w.idx.doc, start: start, end: start + len(name)}
+}
+
+func (w *walker) module(mod *ast.Module) {
+	w.push(0, len(w.idx.doc.text))
+	exportDocs := map[string]string{}
+	for _, stmt := range mod.Body {
+		if e, ok := stmt.(*ast.Export); ok {
+			exportDocs[e.Name] = e.Doc
+		}
+	}
+	topLevel := map[string]*definition{}
+	for _, stmt := range mod.Body {
+		var def *definition
+		switch v := stmt.(type) {
+		case *ast.Import:
+			def = &definition{name: v.Name, kind: defImport, doc: w.idx.doc, start: v.Position().Offset, end: v.EndPosition().Offset, imp: v, hoisted: true}
+		case *ast.FunctionDefine:
+			def = w.functionDef(v, defFunction)
+			def.hoisted = true
+		case *ast.TypeDefine:
+			def = w.typeDef(v)
+			def.hoisted = true
+		case *ast.Declare:
+			def = w.newDef(v.Name, defVariable, v.Position().Offset)
+			def.exportDoc = exportDocs[v.Name]
+		default:
+			continue
+		}
+		w.predeclared[stmt] = def
+		topLevel[def.name] = def
+		if def.hoisted {
+			w.scope.names[def.name] = def
+		}
+	}
+	for _, stmt := range mod.Body {
+		if exp, ok := stmt.(*ast.Export); ok {
+			if def := topLevel[exp.Name]; def != nil {
+				w.idx.exports[exp.Name] = def
+			}
+		}
+	}
+	w.statements(mod.Body)
+	w.pop()
+}
+
+func (w *walker) functionDef(fn *ast.FunctionDefine, kind defKind) *definition {
+	def := w.newDef(fn.Name, kind, fn.Position().Offset)
+	def.function = fn
+	return def
+}
+
+func (w *walker) typeDef(t *ast.TypeDefine) *definition {
+	def := w.newDef(t.Name, defType, t.Position().Offset)
+	def.typ = t
+	members := map[string]*definition{}
+	for _, field := range t.Fields {
+		fieldDef := w.newDef(field.Name, defField, field.Pos.Offset)
+		fieldDef.field, fieldDef.owner = field, t
+		members[field.Name] = fieldDef
+	}
+	for _, method := range t.Methods {
+		methodDef := w.functionDef(method, defMethod)
+		methodDef.owner = t
+		members[method.Name] = methodDef
+	}
+	w.idx.members[t] = members
+	return def
+}
+
+func (w *walker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		w.statement(stmt)
+	}
+}
+
+// block walks stmts in a nested scope spanning the statement that owns them.
+func (w *walker) block(owner ast.Statement, stmts []ast.Statement) {
+	w.push(owner.Position().Offset, owner.EndPosition().Offset)
+	w.statements(stmts)
+	w.pop()
+}
+
+func (w *walker) statement(stmt ast.Statement) {
+	switch v := stmt.(type) {
+	case *ast.Import:
+		if def := w.predeclared[stmt]; def != nil {
+			w.declare(def)
+		}
+		w.checkImport(v)
+	case *ast.Declare:
+		w.expr(v.Value)
+		def := w.predeclared[stmt]
+		if def == nil {
+			def = w.newDef(v.Name, defVariable, v.Position().Offset)
+		}
+		w.declare(def)
+	case *ast.Assign:
+		w.refer(v.Position().Offset, v.Target, w.scope.lookup(v.Target))
+		w.expr(v.Value)
+	case *ast.SetIndex:
+		w.expr(v.Object)
+		w.expr(v.Index)
+		w.expr(v.Value)
+	case *ast.SetAttr:
+		w.member(v.Object, v.Property, v.Position().Offset)
+		w.expr(v.Value)
+	case *ast.FunctionDefine:
+		def := w.predeclared[stmt]
+		if def == nil {
+			def = w.functionDef(v, defFunction)
+		}
+		w.declare(def)
+		w.function(v.Parameters, v.Body, v, nil)
+	case *ast.TypeDefine:
+		def := w.predeclared[stmt]
+		if def == nil {
+			def = w.typeDef(v)
+		}
+		w.declare(def)
+		members := w.idx.members[v]
+		for _, field := range v.Fields {
+			w.idx.refs = append(w.idx.refs, reference{start: field.Pos.Offset, end: field.Pos.Offset + len(field.Name), def: members[field.Name], decl: true})
+			if field.HasDefault() {
+				w.expr(field.DefaultValue)
+			}
+		}
+		for _, method := range v.Methods {
+			methodDef := members[method.Name]
+			w.idx.refs = append(w.idx.refs, reference{start: methodDef.start, end: methodDef.end, def: methodDef, decl: true})
+			w.function(method.Parameters, method.Body, method, v)
+		}
+	case *ast.IfElse:
+		w.expr(v.Condition)
+		w.block(v, v.IfBody)
+		w.block(v, v.ElseBody)
+	case *ast.While:
+		w.expr(v.Condition)
+		w.block(v, v.Body)
+	case *ast.For:
+		w.expr(v.Iterator)
+		w.push(v.Position().Offset, v.EndPosition().Offset)
+		w.declare(w.newDef(v.Variable, defVariable, v.Position().Offset))
+		w.block(v, v.Body)
+		w.pop()
+	case *ast.Return:
+		w.expr(v.Value)
+	case *ast.Raise:
+		w.expr(v.Value)
+	case *ast.TryCatch:
+		w.block(v, v.TryBody)
+		w.push(v.CatchVarPos.Offset, v.EndPosition().Offset)
+		w.declare(w.newDef(v.CatchVar, defVariable, v.CatchVarPos.Offset))
+		w.statements(v.CatchBody)
+		w.pop()
+	case *ast.Export:
+		w.refer(v.Position().Offset, v.Name, w.scope.lookup(v.Name))
+	case ast.Expression:
+		w.expr(v)
+	}
+}
+
+// checkImport reports an import neither backend could load. The semantic
+// checker leaves imports to run time.
+func (w *walker) checkImport(imp *ast.Import) {
+	var message string
+	if source.IsPathImport(imp.Path) {
+		path := importPath(w.idx.doc.path, imp)
+		if _, err := os.Stat(path); err != nil && w.ws.open[path] == nil {
+			message = fmt.Sprintf("cannot find module file %s", path)
+		}
+	} else if !isStdlibModule(imp.Path) {
+		message = fmt.Sprintf("unknown module: %s", imp.Path)
+	}
+	if message != "" {
+		w.idx.diagnostics = append(w.idx.diagnostics, Diagnostic{
+			Range:    w.idx.doc.rangeOf(imp.Position().Offset, imp.EndPosition().Offset),
+			Severity: severityError,
+			Source:   "goblin",
+			Message:  message,
+		})
+	}
+}
+
+// stdlibModules lists the modules import can name: the transpiler's table,
+// which a test keeps in step with the interpreter's, plus os, which both
+// backends bind per program.
+func stdlibModules() []string {
+	return append(transpiler.KnownModuleNames(), "os")
+}
+
+func isStdlibModule(name string) bool {
+	for _, known := range stdlibModules() {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// function walks a function body. Parameter defaults belong to the enclosing
+// scope; the parameters to the body's. owner is the type a method belongs to.
+func (w *walker) function(params []*ast.Parameter, body []ast.Statement, node ast.Statement, owner *ast.TypeDefine) {
+	for _, param := range params {
+		if param.HasDefault() {
+			w.expr(param.Default)
+		}
+	}
+	w.push(node.Position().Offset, node.EndPosition().Offset)
+	for i, param := range params {
+		def := w.newDef(param.Name, defParameter, param.Pos.Offset)
+		def.param = param
+		if i == 0 && owner != nil {
+			def.owner = owner
+		}
+		w.declare(def)
+	}
+	w.statements(body)
+	w.pop()
+}
+
+// expr walks an expression and returns the definition an identifier
+// resolves to, so member expressions can follow imports and self.
+func (w *walker) expr(expr ast.Expression) *definition {
+	switch v := expr.(type) {
+	case *ast.Identifier:
+		def := w.scope.lookup(v.Name)
+		if def == nil {
+			if _, ok := extension.BuiltinsModule.Members[v.Name]; ok {
+				w.idx.refs = append(w.idx.refs, reference{start: v.Position().Offset, end: v.Position().Offset + len(v.Name), native: []string{v.Name}})
+			}
+			return nil
+		}
+		w.refer(v.Position().Offset, v.Name, def)
+		return def
+	case *ast.FunctionCall:
+		w.refer(v.Position().Offset, v.Name, w.scope.lookup(v.Name))
+		w.arguments(v.Args)
+	case *ast.CallExpression:
+		w.expr(v.Callee)
+		w.arguments(v.Args)
+	case *ast.FunctionLiteral:
+		w.function(v.Parameters, v.Body, v, nil)
+	case *ast.BinaryOperation:
+		w.expr(v.LHS)
+		w.expr(v.RHS)
+	case *ast.UnaryOperation:
+		w.expr(v.Operand)
+	case *ast.ListLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem)
+		}
+	case *ast.DictLiteral:
+		for _, elem := range v.Elements {
+			w.expr(elem.Key)
+			w.expr(elem.Value)
+		}
+	case *ast.IndexExpression:
+		w.expr(v.Object)
+		w.expr(v.Index)
+	case *ast.MemberExpression:
+		w.member(v.Object, v.Property, v.Position().Offset)
+	}
+	return nil
+}
+
+func (w *walker) arguments(args []ast.CallArgument) {
+	for _, arg := range args {
+		w.expr(arg.Expr)
+	}
+}
+
+// member resolves obj.property where obj names a path import, a stdlib
+// module or builtin, or a method's self. Other objects are only known at
+// runtime.
+func (w *walker) member(obj ast.Expression, property string, start int) {
+	def := w.expr(obj)
+	id, isIdent := obj.(*ast.Identifier)
+	switch {
+	case def == nil && isIdent:
+		if _, ok := extension.BuiltinsModule.Members[id.Name]; ok && w.scope.lookup(id.Name) == nil {
+			w.idx.refs = append(w.idx.refs, reference{start: start, end: start + len(property), native: []string{id.Name, property}})
+		}
+	case def == nil:
+	case def.kind == defImport && source.IsPathImport(def.imp.Path):
+		if target := w.ws.index(importPath(w.idx.doc.path, def.imp)); target != nil {
+			if exported := target.exports[property]; exported != nil {
+				w.refer(start, property, exported)
+			}
+		}
+	case def.kind == defImport:
+		w.idx.refs = append(w.idx.refs, reference{start: start, end: start + len(property), native: []string{def.imp.Path, property}})
+	case def.kind == defParameter && def.owner != nil:
+		if member := w.idx.members[def.owner][property]; member != nil {
+			w.refer(start, property, member)
+		}
+	}
+}
+
+// referenceAt returns the reference under offset, if any.
+func (idx *fileIndex) referenceAt(offset int) (reference, bool) {
+	for _, ref := range idx.refs {
+		if ref.start <= offset && offset <= ref.end {
+			return ref, true
+		}
+	}
+	return reference{}, false
+}
+
+// visible returns the definitions in scope at offset, innermost first.
+func (idx *fileIndex) visible(offset int) []*definition {
+	var result []*definition
+	seen := map[string]bool{}
+	for i := len(idx.scopes) - 1; i >= 0; i-- {
+		rng := idx.scopes[i]
+		if offset < rng.start || offset > rng.end {
+			continue
+		}
+		for _, def := range rng.defs {
+			if seen[def.name] || !def.hoisted && def.start > offset {
+				continue
+			}
+			seen[def.name] = true
+			result = append(result, def)
+		}
+	}
+	return result
+}
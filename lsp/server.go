@@ -0,0 +1,271 @@
+// Package lsp implements the Goblin language server behind `goblin lsp`. It
+// speaks the Language Server Protocol over stdio with full-text document
+// sync and answers diagnostics, completion, hover, go-to-definition, find
+// references, and document symbols from the same parser and semantic checker
+// the backends use.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aisk/goblin/internal/framing"
+	"github.com/aisk/goblin/interpreter"
+)
+
+// Serve runs the language server until the client sends exit or closes in.
+// It returns an error when the client exits without shutting the server down
+// first, which the specification treats as abnormal.
+func Serve(in io.Reader, out io.Writer) error {
+	s := newServer(out)
+	r := bufio.NewReader(in)
+	for {
+		body, err := framing.Read(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("lsp: %w", err)
+		}
+		if done, err := s.handle(body); done {
+			return err
+		}
+	}
+}
+
+type server struct {
+	out  io.Writer
+	ws   *workspace
+	docs map[string]*document // open documents by URI
+	// lastGood keeps each open document's last index that parsed, so
+	// completion still knows the local names while an edit is incomplete.
+	lastGood map[string]*fileIndex
+	root     string
+	// builtins is a pristine session for builtin names; modules has the
+	// stdlib modules documents import bound as globals. Path imports are
+	// never evaluated: running a user's code to answer an editor request
+	// would be unsafe.
+	builtins    *interpreter.Session
+	modules     *interpreter.Session
+	imported    map[string]bool
+	initialized bool
+	shutdown    bool
+}
+
+func newServer(out io.Writer) *server {
+	return &server{
+		out:      out,
+		ws:       newWorkspace(),
+		docs:     map[string]*document{},
+		lastGood: map[string]*fileIndex{},
+		builtins: interpreter.NewSession("."),
+		modules:  interpreter.NewSession("."),
+		imported: map[string]bool{},
+	}
+}
+
+func (s *server) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	framing.Write(s.out, body)
+}
+
+func (s *server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle processes one message and reports whether the server should stop.
+func (s *server) handle(body []byte) (bool, error) {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.write(response{JSONRPC: "2.0", Error: &responseError{Code: codeParseError, Message: err.Error()}})
+		return false, nil
+	}
+	if req.Method == "exit" {
+		if !s.shutdown {
+			return true, errors.New("lsp: exit without shutdown")
+		}
+		return true, nil
+	}
+	result, rerr := s.dispatch(req)
+	if req.ID == nil {
+		return false, nil
+	}
+	resp := response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if rerr != nil {
+		resp.Result, resp.Error = nil, rerr
+	}
+	s.write(resp)
+	return false, nil
+}
+
+func (s *server) dispatch(req request) (any, *responseError) {
+	if !s.initialized && req.Method != "initialize" {
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.root = uriToPath(params.RootURI)
+		if s.root == "" && len(params.WorkspaceFolders) > 0 {
+			s.root = uriToPath(params.WorkspaceFolders[0].URI)
+		}
+		s.initialized = true
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // full text on every change
+				"completionProvider":     map[string]any{"triggerCharacters": []string{"."}},
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "goblin"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.close(params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.completion(params), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params), nil
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.references(params), nil
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.documentSymbols(params.TextDocument.URI), nil
+	}
+	if strings.HasPrefix(req.Method, "$/") || req.ID == nil {
+		// Notifications the server has no use for, such as initialized.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// update replaces an open document's text and republishes diagnostics for
+// every open document, since an edit can fix or break an import elsewhere.
+func (s *server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.ws.open[doc.path] = doc
+	s.ws.invalidate()
+	uris := make([]string, 0, len(s.docs))
+	for open := range s.docs {
+		uris = append(uris, open)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		open := s.docs[uri]
+		idx := s.ws.index(open.path)
+		if idx.module != nil {
+			s.lastGood[open.uri] = idx
+		}
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: open.uri, Diagnostics: nonNil(idx.diagnostics)})
+	}
+}
+
+func (s *server) close(uri string) {
+	if doc, ok := s.docs[uri]; ok {
+		delete(s.ws.open, doc.path)
+		delete(s.docs, uri)
+		delete(s.lastGood, uri)
+		s.ws.invalidate()
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: []Diagnostic{}})
+}
+
+// nonNil keeps an empty list from marshalling as null, which clients read as
+// "no change" rather than "no diagnostics".
+func nonNil(diags []Diagnostic) []Diagnostic {
+	if diags == nil {
+		return []Diagnostic{}
+	}
+	return diags
+}
+
+// indexFor returns the index of the document at uri, reading it from disk
+// when it is not open.
+func (s *server) indexFor(uri string) *fileIndex {
+	if doc, ok := s.docs[uri]; ok {
+		return s.ws.index(doc.path)
+	}
+	if path := uriToPath(uri); path != "" {
+		return s.ws.index(path)
+	}
+	return nil
+}
+
+// importModule binds a stdlib module in the modules session, so its members
+// can be listed and looked up.
+func (s *server) importModule(name string) bool {
+	if s.imported[name] {
+		return true
+	}
+	if !isStdlibModule(name) {
+		return false
+	}
+	if _, err := s.modules.Eval("import " + strconv.Quote(name)); err != nil {
+		return false
+	}
+	s.imported[name] = true
+	return true
+}
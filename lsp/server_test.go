@@ -0,0 +1,384 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aisk/goblin/internal/framing"
+)
+
+const libSource = `# Greets someone by name.
+func greet(name, punctuation="!") {
+    return "hello " + name + punctuation
+}
+export greet
+`
+
+const mainSource = `import "./lib"
+import "json"
+
+type User(name, age=0) {
+    func label(self) {
+        return self.name
+    }
+}
+
+var user = User("ada")
+print(lib.greet(user.label()))
+print(json.marshal([1]))
+`
+
+// client scripts a session: messages are queued, then Serve runs over all of
+// them at once and the replies are collected.
+type client struct {
+	t      *testing.T
+	in     bytes.Buffer
+	nextID int
+}
+
+func newClient(t *testing.T, root string) *client {
+	c := &client{t: t}
+	c.request("initialize", map[string]any{"rootUri": pathToURI(root)})
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	framing.Write(&c.in, body)
+}
+
+func (c *client) request(method string, params any) int {
+	c.nextID++
+	c.send(map[string]any{"id": c.nextID, "method": method, "params": params})
+	return c.nextID
+}
+
+func (c *client) notify(method string, params any) {
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+func (c *client) open(path, text string) {
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": pathToURI(path), "languageId": "goblin", "version": 1, "text": text},
+	})
+}
+
+func (c *client) at(method, path, text, marker string, delta int, extra map[string]any) int {
+	offset := strings.Index(text, marker)
+	if offset < 0 {
+		c.t.Fatalf("marker %q not in source", marker)
+	}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": pathToURI(path)},
+		"position":     newDocument("", text).position(offset + delta),
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return c.request(method, params)
+}
+
+type transcript struct {
+	results     map[int]json.RawMessage
+	errors      map[int]*responseError
+	diagnostics map[string][]Diagnostic
+}
+
+// run shuts the server down, serves the whole script, and parses the output.
+func (c *client) run() transcript {
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	var out bytes.Buffer
+	if err := Serve(&c.in, &out); err != nil {
+		c.t.Fatal(err)
+	}
+	tr := transcript{results: map[int]json.RawMessage{}, errors: map[int]*responseError{}, diagnostics: map[string][]Diagnostic{}}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := framing.Read(r)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *responseError  `json:"error"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		switch {
+		case msg.ID != nil:
+			tr.results[*msg.ID] = msg.Result
+			tr.errors[*msg.ID] = msg.Error
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params publishDiagnosticsParams
+			json.Unmarshal(msg.Params, &params)
+			tr.diagnostics[params.URI] = params.Diagnostics
+		}
+	}
+	return tr
+}
+
+func (tr transcript) decode(t *testing.T, id int, v any) {
+	t.Helper()
+	if tr.errors[id] != nil {
+		t.Fatalf("request %d failed: %s", id, tr.errors[id].Message)
+	}
+	if err := json.Unmarshal(tr.results[id], v); err != nil {
+		t.Fatalf("request %d: %v in %s", id, err, tr.results[id])
+	}
+}
+
+func project(t *testing.T) (root, libPath, mainPath string) {
+	root = t.TempDir()
+	libPath = filepath.Join(root, "lib.goblin")
+	mainPath = filepath.Join(root, "main.goblin")
+	if err := os.WriteFile(libPath, []byte(libSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainPath, []byte(mainSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root, libPath, mainPath
+}
+
+func TestDiagnostics(t *testing.T) {
+	root, _, mainPath := project(t)
+	c := newClient(t, root)
+	c.open(mainPath, mainSource)
+	broken := filepath.Join(root, "broken.goblin")
+	c.open(broken, "var x = \n")
+	undefined := filepath.Join(root, "undefined.goblin")
+	c.open(undefined, "print(1)\nprint(missing)\nimport \"nosuch\"\n")
+	tr := c.run()
+
+	if diags := tr.diagnostics[pathToURI(mainPath)]; len(diags) != 0 {
+		t.Fatalf("main diagnostics = %+v", diags)
+	}
+	if diags := tr.diagnostics[pathToURI(broken)]; len(diags) != 1 || !strings.Contains(diags[0].Message, "expected") {
+		t.Fatalf("broken diagnostics = %+v", diags)
+	}
+	diags := tr.diagnostics[pathToURI(undefined)]
+	if len(diags) != 2 {
+		t.Fatalf("undefined diagnostics = %+v", diags)
+	}
+	want := Range{Start: Position{Line: 1, Character: 6}, End: Position{Line: 1, Character: 13}}
+	if diags[0].Message != "undefined identifier: missing" || diags[0].Range != want {
+		t.Errorf("semantic diagnostic = %+v", diags[0])
+	}
+	if diags[1].Message != "unknown module: nosuch" || diags[1].Range.Start.Line != 2 {
+		t.Errorf("import diagnostic = %+v", diags[1])
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	root, libPath, mainPath := project(t)
+	c := newClient(t, root)
+	c.open(mainPath, mainSource)
+	definition := c.at("textDocument/definition", mainPath, mainSource, "greet(user", 2, nil)
+	selfName := c.at("textDocument/definition", mainPath, mainSource, "name\n", 1, nil)
+	references := c.at("textDocument/references", libPath, libSource, "greet(", 0, map[string]any{"context": map[string]any{"includeDeclaration": true}})
+	usages := c.at("textDocument/references", mainPath, mainSource, "user.label", 0, map[string]any{"context": map[string]any{"includeDeclaration": false}})
+	tr := c.run()
+
+	var locations []Location
+	tr.decode(t, definition, &locations)
+	want := Location{URI: pathToURI(libPath), Range: Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 10}}}
+	if len(locations) != 1 || locations[0] != want {
+		t.Fatalf("definition = %+v, want %+v", locations, want)
+	}
+
+	tr.decode(t, selfName, &locations)
+	if len(locations) != 1 || locations[0].Range.Start != (Position{Line: 3, Character: 10}) {
+		t.Fatalf("self.name definition = %+v", locations)
+	}
+
+	tr.decode(t, references, &locations)
+	var got []string
+	for _, loc := range locations {
+		got = append(got, filepath.Base(uriToPath(loc.URI))+":"+strconv.Itoa(loc.Range.Start.Line))
+	}
+	if strings.Join(got, " ") != "lib.goblin:1 lib.goblin:4 main.goblin:10" {
+		t.Fatalf("references = %v", got)
+	}
+
+	tr.decode(t, usages, &locations)
+	if len(locations) != 1 || locations[0].Range.Start != (Position{Line: 10, Character: 16}) {
+		t.Fatalf("user references = %+v", locations)
+	}
+}
+
+func TestHover(t *testing.T) {
+	root, _, mainPath := project(t)
+	c := newClient(t, root)
+	c.open(mainPath, mainSource)
+	greet := c.at("textDocument/hover", mainPath, mainSource, "greet(user", 0, nil)
+	marshal := c.at("textDocument/hover", mainPath, mainSource, "marshal", 0, nil)
+	method := c.at("textDocument/hover", mainPath, mainSource, "label(self)", 0, nil)
+	builtin := c.at("textDocument/hover", mainPath, mainSource, "print(lib", 0, nil)
+	tr := c.run()
+
+	for id, want := range map[int][]string{
+		greet:   {"func greet(name, punctuation=\"!\")", "Greets someone by name."},
+		marshal: {"json.marshal: Function"},
+		method:  {"func User.label(self)"},
+		builtin: {"print: Function"},
+	} {
+		var hover Hover
+		tr.decode(t, id, &hover)
+		for _, fragment := range want {
+			if !strings.Contains(hover.Contents.Value, fragment) {
+				t.Errorf("hover %d = %q, want it to contain %q", id, hover.Contents.Value, fragment)
+			}
+		}
+	}
+}
+
+func TestHoverDocs(t *testing.T) {
+	// Hover shows the doc comments help() and goblin doc show.
+	root := t.TempDir()
+	path := filepath.Join(root, "docs.goblin")
+	src := "func add(a, b) { return a + b }\n\n# Adds.\nexport add\n\n" +
+		"# goblin:ignore unused-variable\n# Scales.\nfunc scale(n) { return n * 2 }\n\n" +
+		"var text = \"\n# not a comment\"\nfunc plain() {}\n\n" +
+		"var limit = 3\n# The limit.\nexport limit\n"
+	c := newClient(t, root)
+	c.open(path, src)
+	add := c.at("textDocument/hover", path, src, "add(a", 0, nil)
+	scale := c.at("textDocument/hover", path, src, "scale(n", 0, nil)
+	plain := c.at("textDocument/hover", path, src, "plain(", 0, nil)
+	limit := c.at("textDocument/hover", path, src, "limit = 3", 0, nil)
+	tr := c.run()
+
+	for id, want := range map[int]string{
+		add:   "```goblin\nfunc add(a, b)\n```\n\nAdds.",
+		scale: "```goblin\nfunc scale(n)\n```\n\nScales.",
+		plain: "```goblin\nfunc plain()\n```",
+		limit: "```goblin\nvar limit\n```\n\nThe limit.",
+	} {
+		var hover Hover
+		tr.decode(t, id, &hover)
+		if hover.Contents.Value != want {
+			t.Errorf("hover %d = %q, want %q", id, hover.Contents.Value, want)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	root, _, mainPath := project(t)
+	c := newClient(t, root)
+	c.open(mainPath, mainSource)
+	labels := func(tr transcript, id int) []string {
+		var items []CompletionItem
+		tr.decode(t, id, &items)
+		var result []string
+		for _, item := range items {
+			result = append(result, item.Label)
+		}
+		return result
+	}
+	self := c.at("textDocument/completion", mainPath, mainSource, "self.name", 5, nil)
+	module := c.at("textDocument/completion", mainPath, mainSource, "json.marshal", 5, nil)
+	pathModule := c.at("textDocument/completion", mainPath, mainSource, "lib.greet", 4, nil)
+	global := c.at("textDocument/completion", mainPath, mainSource, "user.label", 2, nil)
+	imports := c.at("textDocument/completion", mainPath, mainSource, `json"`, 1, nil)
+	tr := c.run()
+
+	if got := strings.Join(labels(tr, self), " "); got != "age label name" {
+		t.Errorf("self. = %s", got)
+	}
+	if got := labels(tr, module); len(got) == 0 || !contains(got, "marshal") || !contains(got, "unmarshal") {
+		t.Errorf("json. = %v", got)
+	}
+	if got := strings.Join(labels(tr, pathModule), " "); got != "greet" {
+		t.Errorf("lib. = %s", got)
+	}
+	if got := strings.Join(labels(tr, global), " "); got != "user" {
+		t.Errorf("us = %s", got)
+	}
+	if got := labels(tr, imports); !contains(got, "json") || contains(got, "math") {
+		t.Errorf("import \"j = %v", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	root, _, mainPath := project(t)
+	c := newClient(t, root)
+	c.open(mainPath, mainSource)
+	id := c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": pathToURI(mainPath)}})
+	tr := c.run()
+
+	var symbols []DocumentSymbol
+	tr.decode(t, id, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "User" || symbols[0].Kind != symbolClass {
+		t.Fatalf("symbols = %+v", symbols)
+	}
+	user := symbols[0]
+	// The range runs to just past the type's closing brace.
+	if user.Range.End != (Position{Line: 7, Character: 1}) {
+		t.Errorf("User range = %+v", user.Range)
+	}
+	var children []string
+	for _, child := range user.Children {
+		children = append(children, child.Name)
+	}
+	if strings.Join(children, " ") != "name age label" {
+		t.Errorf("User children = %v", children)
+	}
+	label := user.Children[2]
+	if label.Kind != symbolMethod || label.Range.End != (Position{Line: 6, Character: 5}) {
+		t.Errorf("label symbol = %+v", label)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := &client{t: t}
+	c.notify("exit", nil)
+	if err := Serve(&c.in, &bytes.Buffer{}); err == nil {
+		t.Fatal("exit without shutdown succeeded")
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := newClient(t, t.TempDir())
+	id := c.request("workspace/symbol", map[string]any{"query": ""})
+	tr := c.run()
+	if tr.errors[id] == nil || tr.errors[id].Code != codeMethodNotFound {
+		t.Fatalf("workspace/symbol error = %+v", tr.errors[id])
+	}
+}
+
+func TestPositionsCountUTF16(t *testing.T) {
+	doc := newDocument("", "var s = \"é😀\"\nx\n")
+	offset := strings.Index(doc.text, "\"\n") // the closing quote
+	pos := doc.position(offset)
+	if pos != (Position{Line: 0, Character: 12}) {
+		t.Fatalf("position = %+v", pos)
+	}
+	if back := doc.offset(pos); back != offset {
+		t.Fatalf("offset = %d, want %d", back, offset)
+	}
+}
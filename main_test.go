@@ -1,97 +1,19 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
-
-	"github.com/aisk/goblin/interpreter"
 )
 
-func completionStrings(c *replCompleter, line string) ([]string, int) {
-	candidates, offset := c.Do([]rune(line), len([]rune(line)))
-	result := make([]string, len(candidates))
-	for i, candidate := range candidates {
-		result[i] = string(candidate)
-	}
-	return result, offset
-}
-
-func TestREPLCompleter(t *testing.T) {
-	s := interpreter.NewSession(".")
-	if _, err := s.Eval(`type User(name) { func hello(self) { return self.name } }`); err != nil {
-		t.Fatal(err)
-	}
-	if _, err := s.Eval(`var user = User("alice")`); err != nil {
-		t.Fatal(err)
-	}
-	c := &replCompleter{session: s}
-
-	tests := []struct {
-		line       string
-		candidates []string
-		offset     int
-	}{
-		{line: "pri", candidates: []string{"nt"}, offset: 3},
-		{line: "ret", candidates: []string{"urn"}, offset: 3},
-		{line: "user.na", candidates: []string{"me"}, offset: 2},
-		{line: "print(user.he", candidates: []string{"llo"}, offset: 2},
-		{line: "user.name.trim_s", candidates: []string{"uffix"}, offset: 6},
-		{line: "make().pu", candidates: []string{}, offset: 0},
-		{line: "missing.na", candidates: []string{}, offset: 2},
-	}
-
-	for _, tt := range tests {
-		got, offset := completionStrings(c, tt.line)
-		if !reflect.DeepEqual(got, tt.candidates) || offset != tt.offset {
-			t.Errorf("complete(%q) = (%v, %d), want (%v, %d)", tt.line, got, offset, tt.candidates, tt.offset)
-		}
-	}
-}
-
-func TestCompletionPathAtCursor(t *testing.T) {
-	path, prefix, ok := completionPath([]rune("user.na + value"), len([]rune("user.na")))
-	if !ok || !reflect.DeepEqual(path, []string{"user"}) || prefix != "na" {
-		t.Fatalf("completionPath = (%v, %q, %v)", path, prefix, ok)
-	}
-}
-
-func TestWantsHelp(t *testing.T) {
-	tests := []struct {
-		args []string
-		want bool
-	}{
-		{args: []string{"-h"}, want: true},
-		{args: []string{"--help"}, want: true},
-		{args: []string{"-h", "script.goblin"}, want: false},
-		{args: []string{"--help", "script.goblin"}, want: false},
-		{args: []string{"script.goblin", "-h"}, want: false},
-		{args: []string{"script.goblin"}, want: false},
-	}
-	for _, tt := range tests {
-		if got := wantsHelp(tt.args); got != tt.want {
-			t.Errorf("wantsHelp(%v) = %v, want %v", tt.args, got, tt.want)
-		}
-	}
-}
-
-func TestRequireSourceFirst(t *testing.T) {
-	if err := requireSourceFirst([]string{"script.goblin", "-h"}); err != nil {
-		t.Fatalf("requireSourceFirst(script, -h) = %v, want nil", err)
-	}
-	if err := requireSourceFirst([]string{"-h", "script.goblin"}); err == nil {
-		t.Fatal("should not accept leading -h before source")
-	}
-	if err := requireSourceFirst([]string{"--verbose"}); err == nil {
-		t.Fatal("should not accept --verbose without source")
-	}
-}
-
 func TestRunCLIForwardsScriptFlags(t *testing.T) {
 	bin := sharedGoblinBin(t)
 	script := filepath.Join(t.TempDir(), "argv.goblin")
@@ -178,6 +100,390 @@ print(hex.encode("a"))
 	}
 }
 
+// build-exe must not need the network or the module cache: the runtime is
+// copied from the CLI into the generated module's vendor directory.
+func TestBuiltExecutableOffline(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "offline.goblin")
+	if err := os.WriteFile(script, []byte("import \"uuid\"\nimport \"http\"\nprint(uuid.new().version)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	executable := filepath.Join(dir, "offline-program")
+	cmd := exec.Command(bin, "build-exe", "-o", executable, script)
+	cmd.Env = append(os.Environ(), "GOBLIN_ROOT=", "GOFLAGS=", "GOPROXY=off", "GOMODCACHE="+t.TempDir())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-exe: %v\n%s", err, out)
+	}
+	out, err := exec.Command(executable).CombinedOutput()
+	if err != nil {
+		t.Fatalf("compiled program: %v\n%s", err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != "4" {
+		t.Fatalf("compiled stdout = %q, want 4", got)
+	}
+}
+
+func TestBuildConstantsAndManifest(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "consts.goblin")
+	if err := os.WriteFile(script, []byte("import \"build\"\nimport \"json\"\nprint(build.get(\"version\", \"dev\"), build.get(\"note\"))\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(bin, "run", "-X", "version=1.0", script).CombinedOutput()
+	if err != nil || string(out) != "1.0 nil\n" {
+		t.Fatalf("goblin run -X: %v\n%s", err, out)
+	}
+
+	executable := filepath.Join(dir, "consts-program")
+	manifest := filepath.Join(dir, "manifest.json")
+	if out, err := exec.Command(bin, "build-exe", "-o", executable, "-X", "version=2.0", "-X", "note=it's a & b",
+		"--strip", "--static", "--trimpath", "--manifest", manifest, script).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-exe: %v\n%s", err, out)
+	}
+	out, err = exec.Command(executable).CombinedOutput()
+	if err != nil || string(out) != "2.0 it's a & b\n" {
+		t.Fatalf("compiled program: %v\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Target    string
+		Static    bool
+		Strip     bool
+		Constants map[string]string
+		Runtime   struct{ Version string }
+		Modules   []struct {
+			Name   string
+			Stdlib bool
+		}
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Target == "" || !m.Static || !m.Strip || m.Constants["version"] != "2.0" || m.Runtime.Version == "" ||
+		len(m.Modules) != 2 || m.Modules[0].Name != "build" || m.Modules[1].Name != "json" || !m.Modules[1].Stdlib {
+		t.Fatalf("manifest =\n%s", data)
+	}
+
+	if out, err := exec.Command(bin, "build-exe", "--target", "linux", script).CombinedOutput(); err == nil ||
+		!strings.Contains(string(out), "GOOS/GOARCH") {
+		t.Fatalf("build-exe --target linux: %v\n%s", err, out)
+	}
+}
+
+// A built program reads its embedded files from the executable, the same
+// files goblin run reads from disk.
+func TestEmbeddedFiles(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"templates/hello.txt":    "hello",
+		"templates/sub/deep.txt": "deep",
+		"main.goblin": `import "embed"
+var t = embed.files("templates")
+print(t.names)
+print(t.iterdir("templates"))
+print(t.read_text("templates/hello.txt"))
+`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(dir, "main.goblin")
+	want := "[\"templates/hello.txt\", \"templates/sub/deep.txt\"]\n[\"templates/hello.txt\", \"templates/sub\"]\nhello\n"
+
+	out, err := exec.Command(bin, "run", script).CombinedOutput()
+	if err != nil || string(out) != want {
+		t.Fatalf("goblin run: %v\n%s", err, out)
+	}
+	executable := filepath.Join(t.TempDir(), "embed-program")
+	if out, err := exec.Command(bin, "build-exe", "-o", executable, script).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-exe: %v\n%s", err, out)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "templates")); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command(executable).CombinedOutput()
+	if err != nil || string(out) != want {
+		t.Fatalf("compiled program: %v\n%s", err, out)
+	}
+}
+
+func TestBuildLib(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "pricing.goblin")
+	if err := os.WriteFile(script, []byte(`var currency = "EUR"
+type Order(total, country) {}
+func discount(order, percent = 10) {
+    return order.total * percent / 100
+}
+func total(*items) {
+    var sum = 0
+    for i in items {
+        sum = sum + i
+    }
+    return sum
+}
+export currency
+export Order
+export discount
+export total
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Go program in the generated module calls the wrappers.
+	out := filepath.Join(dir, "pricing")
+	if output, err := exec.Command(bin, "build-lib", "-o", out, script).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-lib: %v\n%s", err, output)
+	}
+	program := filepath.Join(out, "cmd", "try", "main.go")
+	if err := os.MkdirAll(filepath.Dir(program), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(program, []byte(`package main
+
+import (
+	"fmt"
+
+	"github.com/aisk/goblin/object"
+	"pricing"
+)
+
+func main() {
+	order, err := pricing.NewOrder(object.Integer(200), object.String("DE"))
+	if err != nil {
+		panic(err)
+	}
+	d, _ := pricing.Discount(order)
+	d2, _ := pricing.Discount(order, object.Integer(50))
+	items, _ := object.FromGo([]int{1, 2, 3})
+	sum, _ := pricing.Total(items.(*object.List).Elements...)
+	v, _ := object.ToGo(sum)
+	c, _ := pricing.Currency()
+	_, err = pricing.Discount(object.Integer(1))
+	fmt.Println(d, d2, v, c, err != nil)
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", "-mod=vendor", "./cmd/try")
+	cmd.Dir = out
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, output)
+	}
+	if got, want := strings.TrimSpace(string(output)), "20 100 6 EUR true"; got != want {
+		t.Fatalf("program output = %q, want %q", got, want)
+	}
+
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("no C compiler for -buildmode=c-shared")
+	}
+	lib := filepath.Join(dir, "libpricing.so")
+	if output, err := exec.Command(bin, "build-lib", "--buildmode=c-shared", "-o", lib, script).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-lib --buildmode=c-shared: %v\n%s", err, output)
+	}
+	header, err := os.ReadFile(filepath.Join(dir, "libpricing.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"pricing_discount(char* args, char** err)", "pricing_currency(char** err)", "pricing_free(char* s)"} {
+		if !strings.Contains(string(header), want) {
+			t.Errorf("libpricing.h lacks %q", want)
+		}
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	csrc := filepath.Join(dir, "main.c")
+	if err := os.WriteFile(csrc, []byte(`#include <stdio.h>
+#include "libpricing.h"
+
+int main(void) {
+	char *err = NULL;
+	char *sum = pricing_total("[1, 2, 3.5]", &err);
+	char *bad = pricing_discount("[1]", &err);
+	printf("%s %d %d\n", sum, bad == NULL, err != NULL);
+	pricing_free(sum);
+	pricing_free(err);
+	return 0;
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "cprogram")
+	if output, err := exec.Command("gcc", "-o", exe, csrc, "-L"+dir, "-lpricing").CombinedOutput(); err != nil {
+		t.Fatalf("gcc: %v\n%s", err, output)
+	}
+	run := exec.Command(exe)
+	run.Env = append(os.Environ(), "LD_LIBRARY_PATH="+dir)
+	output, err = run.CombinedOutput()
+	if err != nil {
+		t.Fatalf("C program: %v\n%s", err, output)
+	}
+	if got, want := strings.TrimSpace(string(output)), "6.5 1 1"; got != want {
+		t.Fatalf("C program output = %q, want %q", got, want)
+	}
+}
+
+func TestBuildDistribution(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	ext := filepath.Join(dir, "ext")
+	files := map[string]string{
+		"go.mod": "module example.com/greet\n\ngo 1.22\n\nrequire github.com/aisk/goblin v0.0.0-00010101000000-000000000000\n",
+		// A generic function needs the module's go version in the vendored
+		// runtime of programs build-exe builds.
+		"greet.go": `package greet
+
+import "github.com/aisk/goblin/object"
+
+func init() {
+	object.RegisterModule("greet", "example.com/greet", Execute)
+}
+
+func first[T any](xs []T) T { return xs[0] }
+
+func Execute() (object.Object, error) {
+	return &object.Module{Members: map[string]object.Object{
+		"hello": &object.Function{Name: "hello", Fn: func(args object.CallArgs) (object.Object, error) {
+			p := object.NewArgParser("hello", args)
+			name := p.Str("name")
+			if err := p.Finish(); err != nil {
+				return nil, err
+			}
+			return object.String("hello, " + first([]string{string(name)})), nil
+		}},
+	}}, nil
+}
+`,
+	}
+	if err := os.MkdirAll(ext, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(ext, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dist := filepath.Join(dir, "goblin-greet")
+	if output, err := exec.Command(bin, "build-distribution", "--with", ext, "-o", dist).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-distribution: %v\n%s", err, output)
+	}
+
+	script := filepath.Join(dir, "hi.goblin")
+	if err := os.WriteFile(script, []byte("import \"greet\"\nimport \"json\"\nprint(greet.hello(\"bob\"), json.marshal([1]))\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "hello, bob [1]\n"
+	if output, err := exec.Command(dist, "run", script).CombinedOutput(); err != nil || string(output) != want {
+		t.Fatalf("goblin run = %q, %v; want %q", output, err, want)
+	}
+	exe := filepath.Join(dir, "hi")
+	if output, err := exec.Command(dist, "build-exe", "-o", exe, script).CombinedOutput(); err != nil {
+		t.Fatalf("goblin build-exe: %v\n%s", err, output)
+	}
+	if output, err := exec.Command(exe).CombinedOutput(); err != nil || string(output) != want {
+		t.Fatalf("built program = %q, %v; want %q", output, err, want)
+	}
+	if output, err := exec.Command(bin, "run", script).CombinedOutput(); err == nil || !strings.Contains(string(output), "unknown module: greet") {
+		t.Fatalf("goblin without the module: %v\n%s", err, output)
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	cacheDir := t.TempDir()
+	helper := filepath.Join(dir, "helper.goblin")
+	script := filepath.Join(dir, "main.goblin")
+	if err := os.WriteFile(script, []byte("import \"./helper\"\nprint(helper.value)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := func(value string, args ...string) string {
+		t.Helper()
+		if err := os.WriteFile(helper, []byte("var value = "+value+"\nexport value\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		executable := filepath.Join(dir, "program")
+		cmd := exec.Command(bin, append([]string{"build-exe", "-v", "-o", executable}, append(args, script)...)...)
+		cmd.Env = append(os.Environ(), "GOBLIN_CACHE="+cacheDir)
+		log, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("goblin build-exe: %v\n%s", err, log)
+		}
+		out, err := exec.Command(executable).CombinedOutput()
+		if err != nil || strings.TrimSpace(string(out)) != value {
+			t.Fatalf("program printed %q, want %s: %v", out, value, err)
+		}
+		return string(log)
+	}
+
+	if log := build("1"); strings.Contains(log, "cached:") || !strings.Contains(log, cacheDir) {
+		t.Fatalf("first build:\n%s", log)
+	}
+	if log := build("1"); !strings.Contains(log, "cached:") {
+		t.Fatalf("unchanged program was rebuilt:\n%s", log)
+	}
+	// A change to a path import, or to the flags, is a new executable.
+	if log := build("2"); strings.Contains(log, "cached:") {
+		t.Fatalf("changed import was not rebuilt:\n%s", log)
+	}
+	if log := build("2", "--strip"); strings.Contains(log, "cached:") {
+		t.Fatalf("new flags were not rebuilt:\n%s", log)
+	}
+	if log := build("2", "--no-cache"); strings.Contains(log, cacheDir) {
+		t.Fatalf("--no-cache used the cache:\n%s", log)
+	}
+
+	cmd := exec.Command(bin, "clean")
+	cmd.Env = append(os.Environ(), "GOBLIN_CACHE="+cacheDir)
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.HasPrefix(string(out), "removed 3 executables and 1 modules") {
+		t.Fatalf("goblin clean: %v\n%s", err, out)
+	}
+}
+
+// Every package the runtime imports outside the standard library must be
+// embedded, or offline builds of programs that use it fail.
+func TestEmbeddedRuntimeIsComplete(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}",
+		"./object", "./extension/...", "./profiler").Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		dir, ok := strings.CutPrefix(pkg, "github.com/aisk/goblin/")
+		if !ok {
+			dir = "_vendor/" + pkg
+		}
+		entries, err := runtimeFS.ReadDir(dir)
+		if err != nil {
+			t.Errorf("%s is not embedded: %v", pkg, err)
+			continue
+		}
+		hasGo := false
+		for _, e := range entries {
+			hasGo = hasGo || strings.HasSuffix(e.Name(), ".go")
+		}
+		if !hasGo {
+			t.Errorf("%s is embedded without Go files", pkg)
+		}
+	}
+}
+
 func TestRunCLIRejectsLeadingFlag(t *testing.T) {
 	bin := sharedGoblinBin(t)
 	out, err := exec.Command(bin, "run", "-h", "script.goblin").CombinedOutput()
@@ -192,6 +498,268 @@ func TestRunCLIRejectsLeadingFlag(t *testing.T) {
 	}
 }
 
+func TestFmtCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.goblin")
+	tidy := filepath.Join(dir, "nested", "tidy.goblin")
+	if err := os.WriteFile(messy, []byte("var x=1  # one\nprint( x )\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(tidy), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tidy, []byte("print(1)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(bin, "fmt", "--check", dir).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), messy) || strings.Contains(string(out), tidy) {
+		t.Fatalf("fmt --check: err=%v, output:\n%s", err, out)
+	}
+	if out, err := exec.Command(bin, "fmt", "-w", dir).CombinedOutput(); err != nil {
+		t.Fatalf("fmt -w: %v\n%s", err, out)
+	}
+	got, err := os.ReadFile(messy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "var x = 1  # one\nprint(x)\n"; string(got) != want {
+		t.Fatalf("fmt -w wrote %q, want %q", got, want)
+	}
+	if out, err := exec.Command(bin, "fmt", "--check", dir).CombinedOutput(); err != nil {
+		t.Fatalf("fmt --check after -w: %v\n%s", err, out)
+	}
+}
+
+func TestCheckCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.goblin")
+	src := "import \"json\"\nvar unused = 1  # goblin:ignore\nprint(missing)\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(bin, "check", dir).Output()
+	want := path + ":1:8: warning: json imported and not used (unused-import)\n" +
+		path + ":3:7: error: undefined identifier: missing (semantic)\n"
+	if err == nil || string(out) != want {
+		t.Fatalf("check: err=%v, output:\n%s\nwant:\n%s", err, out, want)
+	}
+
+	out, err = exec.Command(bin, "lint", "--json", path).Output()
+	if err == nil {
+		t.Fatalf("lint --json succeeded, want failure")
+	}
+	var diags []map[string]any
+	if err := json.Unmarshal(out, &diags); err != nil {
+		t.Fatalf("lint --json output is not JSON: %v\n%s", err, out)
+	}
+	if len(diags) != 2 || diags[1]["rule"] != "semantic" || diags[1]["line"] != float64(3) || diags[1]["file"] != path {
+		t.Fatalf("lint --json = %v", diags)
+	}
+
+	if err := os.WriteFile(path, []byte("print(1)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(bin, "check", path).CombinedOutput(); err != nil || len(out) != 0 {
+		t.Fatalf("check on a clean file: err=%v, output:\n%s", err, out)
+	}
+}
+
+func TestTestCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	src := "import \"testing\"\nfunc test_ok() {\n    testing.assert_true(true)\n}\nfunc test_bad() {\n    testing.assert_equal(1, 2)\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "math_test.goblin"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Only *_test.goblin files are searched for tests.
+	if err := os.WriteFile(filepath.Join(dir, "main.goblin"), []byte("func test_never() {\n    raise Error(\"ran\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "math_test.goblin")
+	report := filepath.Join(dir, "report.xml")
+
+	cmd := exec.Command(bin, "test", "--junit", report)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err == nil || !strings.Contains(string(out), "--- FAIL: math_test.goblin: test_bad") ||
+		!strings.Contains(string(out), "expected 2, got 1") || strings.Contains(string(out), "test_ok") ||
+		!strings.Contains(string(out), "FAIL: 1 passed, 1 failed, 0 skipped") {
+		t.Fatalf("test: err=%v, output:\n%s", err, out)
+	}
+	if xml, err := os.ReadFile(report); err != nil || !strings.Contains(string(xml), `<testcase name="test_bad"`) {
+		t.Fatalf("junit report: err=%v\n%s", err, xml)
+	}
+
+	out, err = exec.Command(bin, "test", "-v", "--run", "ok$", path).Output()
+	if err != nil || !strings.Contains(string(out), "--- PASS: "+path+": test_ok") || !strings.Contains(string(out), "ok: 1 passed, 0 failed, 0 skipped") {
+		t.Fatalf("test --run: err=%v, output:\n%s", err, out)
+	}
+	// -run is spelled as go test spells it, too.
+	out, err = exec.Command(bin, "test", "-run", "ok$", path).Output()
+	if err != nil || !strings.Contains(string(out), "ok: 1 passed, 0 failed, 0 skipped") {
+		t.Fatalf("test -run: err=%v, output:\n%s", err, out)
+	}
+}
+
+func TestRunCoverageCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.goblin")
+	src := "import \"os\"\nprint(os.argv()[1])\nif false {\n    print(0)\n}\nos.exit(3)\nprint(1)\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profile := filepath.Join(dir, "cover.out")
+
+	// The profile is written even though the script ends with os.exit.
+	out, err := exec.Command(bin, "run", "--coverage", profile, script, "--coverage").Output()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 || string(out) != "--coverage\n" {
+		t.Fatalf("run --coverage: err=%v, output:\n%s", err, out)
+	}
+	data, err := os.ReadFile(profile)
+	want := "mode: count\n" +
+		script + ":1.1,1.12 1 1\n" +
+		script + ":2.1,2.20 1 1\n" +
+		script + ":3.1,3.11 1 1\n" +
+		script + ":4.5,4.13 1 0\n" +
+		script + ":6.1,6.11 1 1\n" +
+		script + ":7.1,7.9 1 0\n"
+	if err != nil || string(data) != want {
+		t.Fatalf("profile: err=%v\n%s\nwant\n%s", err, data, want)
+	}
+
+	out, err = exec.Command(bin, "cover", profile).Output()
+	if err != nil || string(out) != script+"\t4/6\t66.7%\ntotal\t4/6\t66.7%\n" {
+		t.Fatalf("cover: err=%v, output:\n%s", err, out)
+	}
+	report := filepath.Join(dir, "cover.html")
+	if out, err := exec.Command(bin, "cover", "--html", report, profile).CombinedOutput(); err != nil {
+		t.Fatalf("cover --html: %v\n%s", err, out)
+	}
+	if html, err := os.ReadFile(report); err != nil || !strings.Contains(string(html), `<tr class="miss"><td class="num">7</td>`) {
+		t.Fatalf("cover --html report: err=%v\n%s", err, html)
+	}
+}
+
+func TestRunCPUProfileCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.goblin")
+	src := "import \"os\"\nfunc twice(x) {\n    return x * 2\n}\nvar i = 0\nwhile i < 5 {\n    i = twice(i) + 1\n}\nprint(i)\nos.exit(3)\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profile := filepath.Join(dir, "prof.out")
+	cover := filepath.Join(dir, "cover.json")
+
+	// The flags combine in any order, and the profile is written even though
+	// the script ends with os.exit.
+	out, err := exec.Command(bin, "run", "--cpuprofile="+profile, "--coverage", cover, script).Output()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 || string(out) != "7\n" {
+		t.Fatalf("run --cpuprofile: err=%v, output:\n%s", err, out)
+	}
+	if _, err := os.Stat(cover); err != nil {
+		t.Fatalf("coverage profile: %v", err)
+	}
+	top, err := exec.Command("go", "tool", "pprof", "-top", "-sample_index=calls", profile).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pprof: %v\n%s", err, top)
+	}
+	if !regexp.MustCompile(`(?m)^\s+3 .*main\.twice$`).Match(top) {
+		t.Fatalf("pprof -top -sample_index=calls:\n%s", top)
+	}
+
+	if out, err := exec.Command(bin, "run", "--cpuprofile").CombinedOutput(); err == nil || !strings.Contains(string(out), "--cpuprofile requires a file name") {
+		t.Fatalf("run --cpuprofile without a file: err=%v, output:\n%s", err, out)
+	}
+}
+
+func TestRunSandboxCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.goblin")
+	if err := os.WriteFile(script, []byte("import \"os\"\nprint(os.argv())\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(bin, "run", "--sandbox", script).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "import of module os is not allowed in the sandbox") {
+		t.Fatalf("run --sandbox importing os: err=%v, output:\n%s", err, out)
+	}
+
+	config := filepath.Join(dir, "sandbox.json")
+	if err := os.WriteFile(config, []byte(`{"modules": ["os"], "max_steps": 100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command(bin, "run", "--sandbox="+config, script, "a").CombinedOutput()
+	if err != nil || string(out) != "[\""+script+"\", \"a\"]\n" {
+		t.Fatalf("run --sandbox=FILE: err=%v, output:\n%s", err, out)
+	}
+	loop := filepath.Join(dir, "loop.goblin")
+	if err := os.WriteFile(loop, []byte("while true {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command(bin, "run", "--sandbox="+config, loop).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "step limit of 100 exceeded") {
+		t.Fatalf("run --sandbox=FILE looping: err=%v, output:\n%s", err, out)
+	}
+
+	if err := os.WriteFile(config, []byte(`{"max_stpes": 100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command(bin, "run", "--sandbox="+config, loop).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), `unknown field "max_stpes"`) {
+		t.Fatalf("run --sandbox=FILE with a misspelt key: err=%v, output:\n%s", err, out)
+	}
+}
+
+func TestRunVMCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	script := filepath.Join(t.TempDir(), "main.goblin")
+	src := "import \"os\"\nfunc fail(n) {\n    return n / 0\n}\nprint(os.argv()[1])\nfail(1)\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tree, treeErr := exec.Command(bin, "run", script, "a").CombinedOutput()
+	vm, vmErr := exec.Command(bin, "run", "--vm", script, "a").CombinedOutput()
+	if treeErr == nil || vmErr == nil || string(vm) != string(tree) {
+		t.Fatalf("run --vm: err=%v, output:\n%s\nrun: err=%v, output:\n%s", vmErr, vm, treeErr, tree)
+	}
+	if !strings.HasPrefix(string(vm), "a\n") || !strings.Contains(string(vm), "at fail") {
+		t.Fatalf("run --vm output:\n%s", vm)
+	}
+
+	out, err := exec.Command(bin, "run", "--vm", "--sandbox", script).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "does not support sandboxes") {
+		t.Fatalf("run --vm --sandbox: err=%v, output:\n%s", err, out)
+	}
+}
+
+func TestDebugCLI(t *testing.T) {
+	bin := sharedGoblinBin(t)
+	script := filepath.Join(t.TempDir(), "main.goblin")
+	src := "import \"os\"\nfunc greet(name) {\n    print(\"hi \" + name)\n}\ngreet(os.argv()[1])\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bin, "debug", script, "--flag")
+	cmd.Stdin = strings.NewReader("b greet\nc\np name\nc\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("debug: %v\n%s", err, out)
+	}
+	for _, want := range []string{"breakpoint 1 at greet (", "(debug) --flag\n", "hi --flag\n", "program ended\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
 var (
 	goblinBinOnce sync.Once
 	goblinBinDir  string
@@ -200,10 +768,20 @@ var (
 )
 
 func TestMain(m *testing.M) {
+	// Keep build-exe's cache out of the user's.
+	cacheDir, err := os.MkdirTemp("", "goblin-cli-cache-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TestMain: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("GOBLIN_CACHE", cacheDir)
 	code := m.Run()
-	if goblinBinDir != "" {
-		if err := os.RemoveAll(goblinBinDir); err != nil {
-			fmt.Fprintf(os.Stderr, "TestMain: remove %s: %v\n", goblinBinDir, err)
+	for _, dir := range []string{goblinBinDir, cacheDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "TestMain: remove %s: %v\n", dir, err)
 			if code == 0 {
 				code = 1
 			}
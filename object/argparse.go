@@ -1,5 +1,7 @@
 package object
 
+import "time"
+
 // ArgParser is a fluent helper for extracting and type-checking the arguments
 // of a builtin function. It removes the boilerplate of manually asserting types
 // and constructing TypeErrors for every parameter.
@@ -73,11 +75,17 @@ func (p *ArgParser) required(name string) (Object, bool) {
 }
 
 // typeErr records a type mismatch error for the given argument, unless one is
-// already pending.
+// already pending. An int parameter handed a BigInt has the right type but
+// the wrong size, so that is reported as a ValueError instead.
 func (p *ArgParser) typeErr(name, want string, got Object) {
-	if p.err == nil {
-		p.err = NewTypeError("%s() argument '%s' must be %s, got %s", p.funcName, name, want, got.TypeName())
+	if p.err != nil {
+		return
+	}
+	if _, big := got.(*BigInt); big && want == "int" {
+		p.err = NewValueError("%s() argument '%s' is out of range: %s", p.funcName, name, got)
+		return
 	}
+	p.err = NewTypeError("%s() argument '%s' must be %s, got %s", p.funcName, name, want, got.TypeName())
 }
 
 // Any returns the raw Object bound to a required argument.
@@ -225,6 +233,28 @@ func (p *ArgParser) ListOr(name string, def *List) *List {
 	return argValueOr(p, name, "list", def)
 }
 
+// StrList returns a required list argument whose elements are all str, as
+// a []string.
+func (p *ArgParser) StrList(name string) []string {
+	list := p.List(name)
+	if list == nil {
+		return nil
+	}
+	elements := list.Snapshot()
+	values := make([]string, len(elements))
+	for i, e := range elements {
+		s, ok := e.(String)
+		if !ok {
+			if p.err == nil {
+				p.err = NewTypeError("%s() argument '%s' must be a list of str, got %s element", p.funcName, name, e.TypeName())
+			}
+			return nil
+		}
+		values[i] = string(s)
+	}
+	return values
+}
+
 // Dict returns a required dict argument.
 func (p *ArgParser) Dict(name string) *Dict { return argValue[*Dict](p, name, "dict") }
 
@@ -233,6 +263,13 @@ func (p *ArgParser) DictOr(name string, def *Dict) *Dict {
 	return argValueOr(p, name, "dict", def)
 }
 
+// ArgOf returns a required argument of the concrete type T, which type
+// errors call want. It is the accessor for types without one of their own,
+// such as the opaque values of extension modules.
+func ArgOf[T Object](p *ArgParser, name, want string) T {
+	return argValue[T](p, name, want)
+}
+
 // Func returns a required Function argument.
 func (p *ArgParser) Func(name string) *Function { return argValue[*Function](p, name, "function") }
 
@@ -247,7 +284,7 @@ func (p *ArgParser) Number(name string) Object {
 		return nil
 	}
 	switch v.(type) {
-	case Integer, Float:
+	case Integer, Float, *BigInt:
 		return v
 	default:
 		p.typeErr(name, "number", v)
@@ -262,7 +299,7 @@ func (p *ArgParser) NumberOr(name string, def Object) Object {
 		return def
 	}
 	switch v.(type) {
-	case Integer, Float:
+	case Integer, Float, *BigInt:
 		return v
 	default:
 		p.typeErr(name, "number", v)
@@ -281,10 +318,39 @@ func (p *ArgParser) Float64(name string) float64 {
 		return float64(int64(n))
 	case Float:
 		return float64(n)
+	case *BigInt:
+		return n.Float64()
 	}
 	return 0
 }
 
+// Timeout returns an optional timeout argument given in seconds, and whether
+// there is one: an omitted or nil timeout means wait forever. It backs the
+// timeout of Goblin.wait and of Chan.send and recv.
+func (p *ArgParser) Timeout(name string) (time.Duration, bool) {
+	v, ok := p.next(name)
+	if !ok || v == Nil {
+		return 0, false
+	}
+	var seconds float64
+	switch n := v.(type) {
+	case Integer:
+		seconds = float64(n)
+	case Float:
+		seconds = float64(n)
+	default:
+		p.typeErr(name, "number or nil", v)
+		return 0, false
+	}
+	if seconds < 0 {
+		if p.err == nil {
+			p.err = NewValueError("%s() %s must be non-negative, got %g", p.funcName, name, seconds)
+		}
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
 // Rest consumes and returns all remaining positional arguments. It should be
 // called after the fixed positional accessors and captures the variadic tail.
 func (p *ArgParser) Rest() Args {
@@ -3,6 +3,7 @@ package object
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestArgParserPositional(t *testing.T) {
@@ -118,6 +119,34 @@ func TestArgParserOptionalInt(t *testing.T) {
 	}
 }
 
+func TestArgParserTimeout(t *testing.T) {
+	for _, args := range []CallArgs{{}, {Positional: Args{Nil}}} {
+		p := NewArgParser("f", args)
+		if timeout, ok := p.Timeout("timeout"); ok || timeout != 0 {
+			t.Fatalf("Timeout of %v = (%v, %v), want (0, false)", args, timeout, ok)
+		}
+		if err := p.Finish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewArgParser("f", CallArgs{Keyword: Kwargs{"timeout": Float(1.5)}})
+	if timeout, ok := p.Timeout("timeout"); !ok || timeout != 1500*time.Millisecond {
+		t.Fatalf("Timeout(1.5) = (%v, %v), want (1.5s, true)", timeout, ok)
+	}
+
+	for arg, want := range map[Object]string{
+		String("1"): "f() argument 'timeout' must be number or nil, got String",
+		Integer(-1): "f() timeout must be non-negative, got -1",
+	} {
+		p := NewArgParser("f", CallArgs{Positional: Args{arg}})
+		p.Timeout("timeout")
+		if err := p.Finish(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Timeout(%v) error = %v, want %q", arg, err, want)
+		}
+	}
+}
+
 func TestArgParserKeywordOnly(t *testing.T) {
 	p := NewArgParser("f", CallArgs{Keyword: Kwargs{"a": Integer(1), "b": Integer(2)}})
 	a, b := p.Int("a"), p.Int("b")
@@ -289,3 +318,35 @@ func TestArgParserFloat64Coerces(t *testing.T) {
 		t.Fatalf("expected 2.5, got %v", got)
 	}
 }
+
+func TestArgParserStrList(t *testing.T) {
+	p := NewArgParser("f", CallArgs{Positional: Args{StringList([]string{"a", "b"})}})
+	got := p.StrList("parts")
+	if err := p.Finish(); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("unexpected values: %v", got)
+	}
+
+	p = NewArgParser("f", CallArgs{Positional: Args{&List{Elements: []Object{String("a"), Integer(1)}}}})
+	p.StrList("parts")
+	err := p.Finish()
+	if err == nil || !strings.Contains(err.Error(), "f() argument 'parts' must be a list of str, got Integer element") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestArgOf(t *testing.T) {
+	p := NewArgParser("f", CallArgs{Positional: Args{NewBytes([]byte("x"))}})
+	if b := ArgOf[Bytes](p, "data", "bytes"); string(b) != "x" || p.Finish() != nil {
+		t.Fatalf("unexpected result: %v, %v", b, p.Finish())
+	}
+
+	p = NewArgParser("f", CallArgs{Positional: Args{String("x")}})
+	ArgOf[Bytes](p, "data", "bytes")
+	err := p.Finish()
+	if err == nil || !strings.Contains(err.Error(), "f() argument 'data' must be bytes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
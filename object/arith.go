@@ -1,6 +1,10 @@
 package object
 
-import "errors"
+import (
+	"errors"
+	"math"
+	"math/big"
+)
 
 // Add, Minus, Multiply, Divide and Modulo are the entry points both backends
 // use for the arithmetic operators. They run the left operand's own method
@@ -100,12 +104,19 @@ func Modulo(a, b Object) (Object, error) {
 	return nil, err
 }
 
+// Signed is implemented by numeric types defined outside this package, such
+// as decimal.Decimal, so the unary operators apply to them too. Negate flips
+// the sign; unary + returns a Signed operand unchanged.
+type Signed interface {
+	Negate() (Object, error)
+}
+
 // Positive and Negate are the entry points both backends use for the unary
 // + and - operators. Only numbers have them: + returns the operand unchanged
 // and - flips its sign; anything else is a TypeError.
 func Positive(v Object) (Object, error) {
 	switch v.(type) {
-	case Integer, Float:
+	case Integer, Float, *BigInt, Signed:
 		return v, nil
 	}
 	return nil, NewTypeError("cannot apply unary + to %s", v.TypeName())
@@ -114,9 +125,16 @@ func Positive(v Object) (Object, error) {
 func Negate(v Object) (Object, error) {
 	switch n := v.(type) {
 	case Integer:
-		return Integer(-int64(n)), nil
+		if n != math.MinInt64 {
+			return -n, nil
+		}
+		return NewInt(new(big.Int).Neg(big.NewInt(int64(n)))), nil
 	case Float:
 		return Float(-float64(n)), nil
+	case *BigInt:
+		return NewInt(new(big.Int).Neg(n.v)), nil
+	case Signed:
+		return n.Negate()
 	}
 	return nil, NewTypeError("cannot negate %s", v.TypeName())
 }
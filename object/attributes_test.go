@@ -7,7 +7,7 @@ func TestAttributesMethodReturnsResolvableNames(t *testing.T) {
 		Nil, True, Integer(1), Float(1), String("x"), Bytes("x"),
 		&List{}, NewDict(), NewChan(0),
 		&Function{Name: "f", Fn: func(CallArgs) (Object, error) { return Nil, nil }},
-		NewError("boom"), &Module{Name: "m", Members: map[string]Object{"value": Integer(1)}},
+		NewError("boom"), GoblinConstructorFn, &TaskGroup{}, MustParseInt("99999999999999999999"), &Module{Name: "m", Members: map[string]Object{"value": Integer(1)}},
 	}
 	for _, obj := range objects {
 		for _, name := range obj.Attributes() {
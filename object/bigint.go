@@ -0,0 +1,186 @@
+package object
+
+import (
+	"math/big"
+)
+
+// BigInt is an Integer too large for int64. Goblin has one integer type: the
+// arithmetic helpers promote an Integer result that would overflow to a
+// BigInt and demote a BigInt result that fits back to a plain Integer, so a
+// BigInt never holds a value an Integer could. Code that only needs to handle
+// ordinary numbers can keep type-switching on Integer; a BigInt reaching it is
+// out of range by construction.
+//
+// The wrapped big.Int is never mutated after construction.
+type BigInt struct {
+	NoAssignment
+	NoReflectedOps
+	v *big.Int
+}
+
+var _ Object = &BigInt{}
+
+// NewInt returns v as an Integer when it fits in int64 and as a BigInt
+// otherwise. It takes ownership of v, which must not be modified afterwards.
+func NewInt(v *big.Int) Object {
+	if v.IsInt64() {
+		return Integer(v.Int64())
+	}
+	return &BigInt{v: v}
+}
+
+// MustParseInt parses a decimal integer literal of any size. It is for
+// literals the parser has already accepted, such as the ones the transpiler
+// emits, and panics on malformed input.
+func MustParseInt(s string) Object {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("object: malformed integer literal " + s)
+	}
+	return NewInt(n)
+}
+
+// Int returns the value as a big.Int. The result is shared and must not be
+// modified.
+func (b *BigInt) Int() *big.Int { return b.v }
+
+// Float64 returns the nearest float64, or ±Inf when the value is beyond the
+// float64 range.
+func (b *BigInt) Float64() float64 { return bigToFloat(b.v) }
+
+func (b *BigInt) ToBool() (bool, error) { return b.v.Sign() != 0, nil }
+
+func (b *BigInt) TypeName() string { return "Integer" }
+
+func (b *BigInt) String() string { return b.v.String() }
+
+func (b *BigInt) ToString() (string, error) { return b.String(), nil }
+
+func (b *BigInt) Equals(other Object) (bool, error) {
+	eq, _ := numericEquals(b, other)
+	return eq, nil
+}
+
+func (b *BigInt) Compare(other Object) (int, error) {
+	if c, ok := numericCompare(b, other); ok {
+		return c, nil
+	}
+	return 0, NewTypeError("cannot compare Integer and %s", other.TypeName())
+}
+
+func (b *BigInt) Add(other Object) (Object, error) {
+	if result, ok := numericAdd(b, other); ok {
+		return result, nil
+	}
+	return nil, NewTypeError("cannot add Integer and %s", other.TypeName())
+}
+
+func (b *BigInt) Minus(other Object) (Object, error) {
+	if result, ok := numericMinus(b, other); ok {
+		return result, nil
+	}
+	return nil, NewTypeError("cannot subtract Integer and %s", other.TypeName())
+}
+
+func (b *BigInt) Multiply(other Object) (Object, error) {
+	if result, ok := numericMultiply(b, other); ok {
+		return result, nil
+	}
+	return nil, NewTypeError("cannot multiply Integer and %s", other.TypeName())
+}
+
+func (b *BigInt) Divide(other Object) (Object, error) {
+	if result, ok, err := numericDivide(b, other); ok {
+		return result, err
+	}
+	return nil, NewTypeError("cannot divide Integer and %s", other.TypeName())
+}
+
+func (b *BigInt) Modulo(other Object) (Object, error) {
+	if result, ok, err := numericModulo(b, other); ok {
+		return result, err
+	}
+	return nil, NewTypeError("cannot modulo Integer and %s", other.TypeName())
+}
+
+// A BigInt is never zero, so NOT is always false; the check is kept so the
+// method does not depend on that invariant.
+func (b *BigInt) Not() (Object, error) { return Bool(b.v.Sign() == 0), nil }
+
+func (b *BigInt) Iter() ([]Object, error) {
+	return nil, NewTypeError("Integer does not support iteration")
+}
+
+func (b *BigInt) Index(index Object) (Object, error) {
+	return nil, NewTypeError("Integer is not indexable")
+}
+
+func (b *BigInt) GetAttr(name string) (Object, error) {
+	switch name {
+	case "attributes":
+		return AttributesFunction(b), nil
+	case "constructor":
+		return IntConstructorFn, nil
+	default:
+		return nil, NewAttributeError("Integer has no attribute '%s'", name)
+	}
+}
+
+func (b *BigInt) Attributes() []string { return []string{"attributes", "constructor"} }
+
+// Hash goes through float64 like Integer's, so a BigInt and the Float it
+// equals share a dict slot.
+func (b *BigInt) Hash() (uint64, error) { return hashNumber(b.Float64()), nil }
+
+// bigToFloat converts v to the nearest float64.
+func bigToFloat(v *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
+
+// isInteger reports whether v is an integer of either representation.
+func isInteger(v Object) bool {
+	switch v.(type) {
+	case Integer, *BigInt:
+		return true
+	}
+	return false
+}
+
+// bigOperand returns an integer operand, of either representation, as a
+// big.Int. The caller checks isInteger first; the result must not be modified.
+func bigOperand(v Object) *big.Int {
+	if n, ok := v.(*BigInt); ok {
+		return n.v
+	}
+	return big.NewInt(int64(v.(Integer)))
+}
+
+// bigFloatOperand returns the float64 value of a numeric operand of any kind.
+func bigFloatOperand(v Object) (Float, bool) {
+	switch n := v.(type) {
+	case Integer:
+		return Float(n), true
+	case Float:
+		return n, true
+	case *BigInt:
+		return Float(n.Float64()), true
+	}
+	return 0, false
+}
+
+// bigArith applies an operator to a numeric pair involving a BigInt, or to an
+// Integer pair whose int64 result overflowed. Two integers stay integral
+// through math/big; a Float on either side widens the pair to float, the same
+// rule Integer follows.
+func bigArith(a, b Object, intOp func(z, x, y *big.Int) *big.Int, floatOp func(x, y Float) Float) (Object, bool) {
+	if isInteger(a) && isInteger(b) {
+		return NewInt(intOp(new(big.Int), bigOperand(a), bigOperand(b))), true
+	}
+	fx, fxok := bigFloatOperand(a)
+	fy, fyok := bigFloatOperand(b)
+	if fxok && fyok {
+		return floatOp(fx, fy), true
+	}
+	return nil, false
+}
@@ -0,0 +1,176 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestIntegerOverflowPromotes(t *testing.T) {
+	cases := []struct {
+		name string
+		got  func() (Object, error)
+		want string
+	}{
+		{"max + 1", func() (Object, error) { return Add(Integer(math.MaxInt64), Integer(1)) }, "9223372036854775808"},
+		{"min - 1", func() (Object, error) { return Minus(Integer(math.MinInt64), Integer(1)) }, "-9223372036854775809"},
+		{"max * max", func() (Object, error) { return Multiply(Integer(math.MaxInt64), Integer(math.MaxInt64)) }, "85070591730234615847396907784232501249"},
+		{"min * -1", func() (Object, error) { return Multiply(Integer(math.MinInt64), Integer(-1)) }, "9223372036854775808"},
+		{"-1 * min", func() (Object, error) { return Multiply(Integer(-1), Integer(math.MinInt64)) }, "9223372036854775808"},
+		{"min / -1", func() (Object, error) { return Divide(Integer(math.MinInt64), Integer(-1)) }, "9223372036854775808"},
+		{"min % -1", func() (Object, error) { return Modulo(Integer(math.MinInt64), Integer(-1)) }, "0"},
+		{"-min", func() (Object, error) { return Negate(Integer(math.MinInt64)) }, "9223372036854775808"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.got()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != c.want {
+				t.Fatalf("got %s, want %s", got, c.want)
+			}
+			if got.TypeName() != "Integer" {
+				t.Fatalf("got type %s, want Integer", got.TypeName())
+			}
+		})
+	}
+}
+
+func TestBigIntDemotes(t *testing.T) {
+	big := MustParseInt("9223372036854775808")
+	if _, ok := big.(*BigInt); !ok {
+		t.Fatalf("expected a BigInt, got %T", big)
+	}
+	got, err := Minus(big, Integer(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Integer(math.MaxInt64) {
+		t.Fatalf("expected an Integer back, got %T %v", got, got)
+	}
+	got, err = Negate(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Integer(math.MinInt64) {
+		t.Fatalf("expected an Integer back, got %T %v", got, got)
+	}
+}
+
+func TestBigIntTruncatingDivision(t *testing.T) {
+	dividend := MustParseInt("-100000000000000000003")
+	quotient, err := Divide(dividend, Integer(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(quotient) != "-10000000000000000000" {
+		t.Fatalf("got %s", quotient)
+	}
+	remainder, err := Modulo(dividend, Integer(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remainder != Integer(-3) {
+		t.Fatalf("got %v", remainder)
+	}
+	if _, err := Divide(dividend, Integer(0)); !errors.Is(err, ZeroDivisionError) {
+		t.Fatalf("expected ZeroDivisionError, got %v", err)
+	}
+	if _, err := Modulo(dividend, Float(0)); !errors.Is(err, ZeroDivisionError) {
+		t.Fatalf("expected ZeroDivisionError, got %v", err)
+	}
+}
+
+func TestBigIntMixedWithFloat(t *testing.T) {
+	big := MustParseInt("18446744073709551616") // 2^64
+	sum, err := Add(big, Float(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != Float(18446744073709551616.5) {
+		t.Fatalf("got %T %v", sum, sum)
+	}
+	if eq, _ := Equals(big, Float(18446744073709551616)); !eq {
+		t.Fatal("expected 2^64 == 2^64 as a float")
+	}
+	if c, _ := Compare(Float(1e30), big); c != 1 {
+		t.Fatalf("got %d, want 1", c)
+	}
+	if c, _ := Compare(big, Integer(math.MaxInt64)); c != 1 {
+		t.Fatalf("got %d, want 1", c)
+	}
+	if eq, _ := Equals(big, Integer(0)); eq {
+		t.Fatal("a BigInt never equals an Integer")
+	}
+}
+
+func TestBigIntHashMatchesEquals(t *testing.T) {
+	big := MustParseInt("18446744073709551616")
+	d := NewDict()
+	if err := d.Set(big, String("big")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []Object{MustParseInt("18446744073709551616"), Float(18446744073709551616)} {
+		got, ok, err := d.Get(key)
+		if err != nil || !ok || got != String("big") {
+			t.Fatalf("lookup with %v: got %v %v %v", key, got, ok, err)
+		}
+	}
+}
+
+func TestIntConstructorBig(t *testing.T) {
+	got, err := IntConstructor(CallArgs{Positional: Args{String("-123456789012345678901234567890")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "-123456789012345678901234567890" {
+		t.Fatalf("got %s", got)
+	}
+	got, err = IntConstructor(CallArgs{Positional: Args{Float(1e20)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "100000000000000000000" {
+		t.Fatalf("got %s", got)
+	}
+	for _, bad := range []Object{String("12x"), String(""), Float(math.Inf(1)), Float(math.NaN())} {
+		if _, err := IntConstructor(CallArgs{Positional: Args{bad}}); !errors.Is(err, ValueError) {
+			t.Fatalf("Int(%v): expected ValueError, got %v", bad, err)
+		}
+	}
+}
+
+func TestBigIntArgumentOutOfRange(t *testing.T) {
+	_, err := ChanConstructor(CallArgs{Positional: Args{MustParseInt("99999999999999999999")}})
+	if !errors.Is(err, ValueError) {
+		t.Fatalf("expected ValueError, got %v", err)
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	var overflow bool
+	if got := CheckedAdd(1, 2, &overflow); got != 3 || overflow {
+		t.Fatalf("1 + 2: got %d, overflow %v", got, overflow)
+	}
+	CheckedMul(math.MaxInt64, 2, &overflow)
+	if !overflow {
+		t.Fatal("expected MaxInt64 * 2 to overflow")
+	}
+	overflow = false
+	CheckedSub(math.MinInt64, 1, &overflow)
+	if !overflow {
+		t.Fatal("expected MinInt64 - 1 to overflow")
+	}
+	overflow = false
+	CheckedDiv(math.MinInt64, -1, &overflow)
+	if !overflow {
+		t.Fatal("expected MinInt64 / -1 to overflow")
+	}
+	overflow = false
+	CheckedNeg(math.MinInt64, &overflow)
+	if !overflow {
+		t.Fatal("expected -MinInt64 to overflow")
+	}
+}
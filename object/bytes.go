@@ -71,6 +71,9 @@ func (b Bytes) Iter() ([]Object, error) {
 }
 
 func (b Bytes) Index(index Object) (Object, error) {
+	if big, ok := index.(*BigInt); ok {
+		return nil, NewIndexError("Bytes() index out of range: %s", big)
+	}
 	i, ok := index.(Integer)
 	if !ok {
 		return nil, NewTypeError("Bytes index must be an integer, got %s", index.TypeName())
@@ -258,8 +261,9 @@ func (b Bytes) Join(args CallArgs) (Object, error) {
 	if !ok {
 		return nil, NewTypeError("join() argument 'iterable' must be a List, got %s", iterable.TypeName())
 	}
-	parts := make([][]byte, len(list.Elements))
-	for i, elem := range list.Elements {
+	elements := list.Snapshot()
+	parts := make([][]byte, len(elements))
+	for i, elem := range elements {
 		part, err := bytesArg("join", "iterable", elem)
 		if err != nil {
 			return nil, NewTypeError("join() element %d must be Bytes or str, got %s", i, elem.TypeName())
@@ -543,8 +547,9 @@ func BytesConstructor(args CallArgs) (Object, error) {
 	case String:
 		return NewBytes([]byte(v)), nil
 	case *List:
-		result := make([]byte, len(v.Elements))
-		for i, elem := range v.Elements {
+		elements := v.Snapshot()
+		result := make([]byte, len(elements))
+		for i, elem := range elements {
 			n, ok := elem.(Integer)
 			if !ok || n < 0 || n > 255 {
 				return nil, NewValueError("Bytes() element %d must be an integer from 0 to 255", i)
@@ -1,10 +1,26 @@
 package object
 
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 // Chan wraps a Go channel of Objects, exposing send/recv/close to Goblin.
 // Element typing is dynamic: any Object can flow through the channel.
+//
+// A channel may be closed with an error. Once it is closed and drained,
+// every receive re-raises that error, the way Goblin.wait re-raises a
+// goblin's error on every call; a channel closed without one raises
+// ValueError instead.
 type Chan struct {
 	OpaqueBase
 	ch chan Object
+	// mu serialises close so closeErr is written exactly once, before the Go
+	// channel is closed; receivers read it only after observing the close.
+	mu       sync.Mutex
+	closed   atomic.Bool
+	closeErr error
 }
 
 var _ Object = &Chan{}
@@ -35,6 +51,20 @@ func (c *Chan) Compare(other Object) (int, error) {
 
 func (c *Chan) Not() (Object, error) { return nil, NewTypeError("cannot perform NOT on Chan") }
 
+// Iter receives every value until the channel is closed and returns them.
+// A for loop does not use it: NewCursor receives one value per iteration
+// instead, so the loop body runs while the stream is still being produced.
+func (c *Chan) Iter() ([]Object, error) {
+	var values []Object
+	for value := range c.ch {
+		values = append(values, value)
+	}
+	if c.closeErr != nil {
+		return nil, c.closeErr
+	}
+	return values, nil
+}
+
 func (c *Chan) GetAttr(name string) (Object, error) {
 	switch name {
 	case "attributes":
@@ -43,8 +73,18 @@ func (c *Chan) GetAttr(name string) (Object, error) {
 		return &Function{Name: "send", Fn: c.Send}, nil
 	case "recv":
 		return &Function{Name: "recv", Fn: c.Recv}, nil
+	case "try_send":
+		return &Function{Name: "try_send", Fn: c.TrySend}, nil
+	case "try_recv":
+		return &Function{Name: "try_recv", Fn: c.TryRecv}, nil
 	case "close":
 		return &Function{Name: "close", Fn: c.Close}, nil
+	case "closed":
+		return &Function{Name: "closed", Fn: c.Closed}, nil
+	case "len":
+		return &Function{Name: "len", Fn: c.Len}, nil
+	case "cap":
+		return &Function{Name: "cap", Fn: c.Cap}, nil
 	case "constructor":
 		return ChanConstructorFn, nil
 	default:
@@ -53,14 +93,16 @@ func (c *Chan) GetAttr(name string) (Object, error) {
 }
 
 func (c *Chan) Attributes() []string {
-	return []string{"attributes", "send", "recv", "close", "constructor"}
+	return []string{"attributes", "send", "recv", "try_send", "try_recv", "close", "closed", "len", "cap", "constructor"}
 }
 
 // Send blocks until the value is delivered or buffered. Sending on a closed
-// channel returns an error instead of panicking.
+// channel returns an error instead of panicking. An optional timeout in
+// seconds raises TimeoutError if the value could not be sent in time.
 func (c *Chan) Send(args CallArgs) (_ Object, err error) {
 	ap := NewArgParser("send", args)
 	value := ap.Any("value")
+	timeout, hasTimeout := ap.Timeout("timeout")
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
@@ -69,39 +111,151 @@ func (c *Chan) Send(args CallArgs) (_ Object, err error) {
 			err = NewValueError("send on closed channel")
 		}
 	}()
-	c.ch <- value
-	return Nil, nil
+	if !hasTimeout {
+		c.ch <- value
+		return Nil, nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.ch <- value:
+		return Nil, nil
+	case <-timer.C:
+		return nil, NewTimeoutError("send() timed out after %g seconds", timeout.Seconds())
+	}
+}
+
+// TrySend sends without blocking and reports whether the value was sent.
+// It returns false when the buffer is full or no receiver is waiting.
+func (c *Chan) TrySend(args CallArgs) (_ Object, err error) {
+	ap := NewArgParser("try_send", args)
+	value := ap.Any("value")
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if recover() != nil {
+			err = NewValueError("send on closed channel")
+		}
+	}()
+	select {
+	case c.ch <- value:
+		return True, nil
+	default:
+		return False, nil
+	}
 }
 
 // Recv blocks until a value is available. When the channel is closed and
 // drained it returns an error so the caller can distinguish it from a real
-// nil value being sent.
+// nil value being sent. An optional timeout in seconds raises TimeoutError if
+// no value arrives in time.
 func (c *Chan) Recv(args CallArgs) (Object, error) {
-	if err := RequireNoArgs("recv", args); err != nil {
+	ap := NewArgParser("recv", args)
+	timeout, hasTimeout := ap.Timeout("timeout")
+	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	value, ok := <-c.ch
-	if !ok {
-		return nil, NewValueError("recv on closed channel")
+	if !hasTimeout {
+		value, ok := <-c.ch
+		return c.received("recv", value, ok)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case value, ok := <-c.ch:
+		return c.received("recv", value, ok)
+	case <-timer.C:
+		return nil, NewTimeoutError("recv() timed out after %g seconds", timeout.Seconds())
 	}
-	return value, nil
 }
 
-// Close closes the channel. Closing an already-closed channel returns an error
-// instead of panicking.
-func (c *Chan) Close(args CallArgs) (_ Object, err error) {
-	if err := RequireNoArgs("close", args); err != nil {
+// TryRecv receives without blocking. It returns a two-element list of the
+// value and a status: "ok" when a value was received, "empty" when none is
+// ready yet, and "closed" when the channel is closed and drained. A channel
+// closed with an error re-raises it instead of reporting "closed".
+func (c *Chan) TryRecv(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("try_recv", args); err != nil {
 		return nil, err
 	}
-	defer func() {
-		if recover() != nil {
-			err = NewValueError("close of closed channel")
+	select {
+	case value, ok := <-c.ch:
+		if ok {
+			return &List{Elements: []Object{value, String("ok")}}, nil
 		}
-	}()
+		if c.closeErr != nil {
+			return nil, c.closeErr
+		}
+		return &List{Elements: []Object{Nil, String("closed")}}, nil
+	default:
+		return &List{Elements: []Object{Nil, String("empty")}}, nil
+	}
+}
+
+// received turns the result of a receive into recv's return value.
+func (c *Chan) received(fn string, value Object, ok bool) (Object, error) {
+	if ok {
+		return value, nil
+	}
+	if c.closeErr != nil {
+		return nil, c.closeErr
+	}
+	return nil, NewValueError("%s on closed channel", fn)
+}
+
+// Close closes the channel, optionally with an error that receivers re-raise
+// once the channel is drained. Closing an already-closed channel returns an
+// error instead of panicking.
+func (c *Chan) Close(args CallArgs) (Object, error) {
+	ap := NewArgParser("close", args)
+	ev := ap.AnyOr("error", Nil)
+	if err := ap.Finish(); err != nil {
+		return nil, err
+	}
+	var closeErr error
+	switch e := ev.(type) {
+	case *Error:
+		closeErr = e
+	case Unit:
+	default:
+		return nil, NewTypeError("close() argument 'error' must be Error or nil, got %s", ev.TypeName())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed.Load() {
+		return nil, NewValueError("close of closed channel")
+	}
+	c.closeErr = closeErr
+	c.closed.Store(true)
 	close(c.ch)
 	return Nil, nil
 }
 
+// Closed reports whether close() has been called. Buffered values may still
+// be waiting to be received.
+func (c *Chan) Closed(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("closed", args); err != nil {
+		return nil, err
+	}
+	return Bool(c.closed.Load()), nil
+}
+
+// Len returns the number of values waiting in the buffer.
+func (c *Chan) Len(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("len", args); err != nil {
+		return nil, err
+	}
+	return Integer(len(c.ch)), nil
+}
+
+// Cap returns the buffer size the channel was created with.
+func (c *Chan) Cap(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("cap", args); err != nil {
+		return nil, err
+	}
+	return Integer(cap(c.ch)), nil
+}
+
 var ChanConstructorFn = &Function{Name: "Chan", Fn: ChanConstructor}
 
 // ChanConstructor builds a Chan. With no argument it is unbuffered; with a
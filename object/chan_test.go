@@ -0,0 +1,129 @@
+package object
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChanTrySendAndTryRecv(t *testing.T) {
+	c := NewChan(1)
+	if got := callMethod(t, c, "try_send", CallArgs{Positional: Args{Integer(1)}}); got != True {
+		t.Fatalf("try_send into free buffer = %v, want true", got)
+	}
+	if got := callMethod(t, c, "try_send", CallArgs{Positional: Args{Integer(2)}}); got != False {
+		t.Fatalf("try_send into full buffer = %v, want false", got)
+	}
+	want := []string{`[1, "ok"]`, `[nil, "empty"]`}
+	for _, w := range want {
+		if got := callMethod(t, c, "try_recv", CallArgs{}); got.(*List).String() != w {
+			t.Fatalf("try_recv = %v, want %s", got, w)
+		}
+	}
+	callMethod(t, c, "close", CallArgs{})
+	if got := callMethod(t, c, "try_recv", CallArgs{}); got.(*List).String() != `[nil, "closed"]` {
+		t.Fatalf("try_recv after close = %v", got)
+	}
+	if _, err := c.TrySend(CallArgs{Positional: Args{Integer(1)}}); !errors.Is(err, ValueError) {
+		t.Fatalf("try_send after close error = %v, want ValueError", err)
+	}
+}
+
+func TestChanTimeouts(t *testing.T) {
+	c := NewChan(0)
+	timeout := Kwargs{"timeout": Float(0.01)}
+	if _, err := c.Recv(CallArgs{Keyword: timeout}); !errors.Is(err, TimeoutError) {
+		t.Fatalf("recv(timeout) error = %v, want TimeoutError", err)
+	}
+	if _, err := c.Send(CallArgs{Positional: Args{Integer(1)}, Keyword: timeout}); !errors.Is(err, TimeoutError) {
+		t.Fatalf("send(timeout) error = %v, want TimeoutError", err)
+	}
+	if _, err := c.Recv(CallArgs{Keyword: Kwargs{"timeout": Integer(-1)}}); !errors.Is(err, ValueError) {
+		t.Fatalf("recv(timeout=-1) error = %v, want ValueError", err)
+	}
+	buffered := NewChan(1)
+	callMethod(t, buffered, "send", CallArgs{Positional: Args{Integer(7)}, Keyword: timeout})
+	if got := callMethod(t, buffered, "recv", CallArgs{Keyword: timeout}); got != Integer(7) {
+		t.Fatalf("recv(timeout) = %v, want 7", got)
+	}
+}
+
+func TestChanLenCapClosed(t *testing.T) {
+	c := NewChan(3)
+	callMethod(t, c, "send", CallArgs{Positional: Args{Integer(1)}})
+	if got := callMethod(t, c, "len", CallArgs{}); got != Integer(1) {
+		t.Fatalf("len = %v, want 1", got)
+	}
+	if got := callMethod(t, c, "cap", CallArgs{}); got != Integer(3) {
+		t.Fatalf("cap = %v, want 3", got)
+	}
+	if got := callMethod(t, c, "closed", CallArgs{}); got != False {
+		t.Fatalf("closed before close = %v", got)
+	}
+	callMethod(t, c, "close", CallArgs{})
+	if got := callMethod(t, c, "closed", CallArgs{}); got != True {
+		t.Fatalf("closed after close = %v", got)
+	}
+	if _, err := c.Close(CallArgs{}); !errors.Is(err, ValueError) {
+		t.Fatalf("second close error = %v, want ValueError", err)
+	}
+}
+
+func TestChanCloseWithErrorIsReraisedAfterDrain(t *testing.T) {
+	c := NewChan(1)
+	cause := NewValueError("producer failed")
+	callMethod(t, c, "send", CallArgs{Positional: Args{Integer(1)}})
+	callMethod(t, c, "close", CallArgs{Keyword: Kwargs{"error": cause}})
+	if got := callMethod(t, c, "recv", CallArgs{}); got != Integer(1) {
+		t.Fatalf("recv before drain = %v, want 1", got)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Recv(CallArgs{}); err != cause {
+			t.Fatalf("recv #%d after drain error = %v, want the close error", i+1, err)
+		}
+	}
+	if _, err := NewChan(0).Close(CallArgs{Positional: Args{Integer(1)}}); !errors.Is(err, TypeError) {
+		t.Fatalf("close(1) error = %v, want TypeError", err)
+	}
+}
+
+func TestCursorStreamsChan(t *testing.T) {
+	c := NewChan(0)
+	cause := WrapError(IOError, "lost", errors.New("eof"))
+	go func() {
+		for i := 1; i <= 3; i++ {
+			c.Send(CallArgs{Positional: Args{Integer(i)}})
+		}
+		c.Close(CallArgs{Keyword: Kwargs{"error": cause}})
+	}()
+	cursor, err := NewCursor(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Object
+	for v, ok := cursor.Next(); ok; v, ok = cursor.Next() {
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[2] != Integer(3) {
+		t.Fatalf("cursor values = %v, want [1 2 3]", got)
+	}
+	if cursor.Err() != cause {
+		t.Fatalf("cursor.Err() = %v, want the close error", cursor.Err())
+	}
+}
+
+func TestCursorWalksIterables(t *testing.T) {
+	cursor, err := NewCursor(&List{Elements: []Object{Integer(1), Integer(2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, ok := cursor.Next(); ok; _, ok = cursor.Next() {
+		n++
+	}
+	if n != 2 || cursor.Err() != nil {
+		t.Fatalf("cursor visited %d values, err %v", n, cursor.Err())
+	}
+	if _, err := NewCursor(Integer(1)); err == nil {
+		t.Fatal("NewCursor(1) should fail like Iter")
+	}
+}
@@ -0,0 +1,167 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// These tests are meant for `go test -race`: each one has many goblins use
+// the same List or Dict at once, which must neither corrupt it nor trip the
+// race detector.
+
+const (
+	concurrentGoblins = 8
+	concurrentRounds  = 200
+)
+
+// runGoblins starts n goblins running fn(i) and waits for all of them.
+func runGoblins(t *testing.T, n int, fn func(i int) error) {
+	t.Helper()
+	handles := make([]*Goblin, n)
+	for i := range handles {
+		i := i
+		handles[i] = startGoblin(t, func(CallArgs) (Object, error) {
+			return Nil, fn(i)
+		})
+	}
+	if _, err := goblinAll(CallArgs{Positional: Args{handleList(handles...)}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListConcurrentPushPop(t *testing.T) {
+	list := &List{}
+	runGoblins(t, concurrentGoblins, func(i int) error {
+		for n := 0; n < concurrentRounds; n++ {
+			if _, err := list.Push(CallArgs{Positional: Args{Integer(n), Integer(n)}}); err != nil {
+				return err
+			}
+			if _, err := list.Pop(CallArgs{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if got := len(list.Elements); got != concurrentGoblins*concurrentRounds {
+		t.Fatalf("list has %d elements, want %d", got, concurrentGoblins*concurrentRounds)
+	}
+}
+
+func TestListConcurrentReadersAndWriters(t *testing.T) {
+	list := &List{Elements: []Object{Integer(0)}}
+	key := &Function{Name: "key", Fn: func(args CallArgs) (Object, error) { return args.Positional[0], nil }}
+	runGoblins(t, concurrentGoblins, func(i int) error {
+		for n := 0; n < concurrentRounds; n++ {
+			var err error
+			switch i % 4 {
+			case 0:
+				_, err = list.SetIndex(Integer(0), Integer(n))
+			case 1:
+				_, err = list.Index(Integer(0))
+			case 2:
+				var items []Object
+				if items, err = list.Iter(); err == nil && len(items) == 0 {
+					err = fmt.Errorf("iterated an empty list")
+				}
+			case 3:
+				if _, err = list.Insert(CallArgs{Positional: Args{Integer(1), Integer(n)}}); err == nil {
+					// A sort that another goblin's write overtook fails
+					// rather than lose that write.
+					_, err = list.sortMethod(CallArgs{Keyword: Kwargs{"key": key}})
+					if err != nil && strings.Contains(err.Error(), "list modified during sort") {
+						err = nil
+					}
+				}
+			}
+			if err != nil {
+				return err
+			}
+			_ = list.String()
+		}
+		return nil
+	})
+}
+
+func TestDictConcurrentSetGetDelete(t *testing.T) {
+	dict := NewDict()
+	runGoblins(t, concurrentGoblins, func(i int) error {
+		for n := 0; n < concurrentRounds; n++ {
+			key := String(fmt.Sprintf("%d-%d", i, n))
+			if err := dict.Set(key, Integer(n)); err != nil {
+				return err
+			}
+			if _, ok, err := dict.Get(key); err != nil || !ok {
+				return fmt.Errorf("get(%s) = %v, %v", key, ok, err)
+			}
+			if n%2 == 0 {
+				if _, err := dict.Pop(CallArgs{Positional: Args{key}}); err != nil {
+					return err
+				}
+			}
+			if _, err := dict.Iter(); err != nil {
+				return err
+			}
+			if _, err := dict.SetDefault(CallArgs{Positional: Args{String("shared"), Integer(i)}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	want := concurrentGoblins*concurrentRounds/2 + 1
+	if got := dict.Len(); got != want {
+		t.Fatalf("dict has %d entries, want %d", got, want)
+	}
+}
+
+// sizingObj stands in for a user type whose __cmp calls back into the list
+// being searched, as `items.size()` would.
+type sizingObj struct {
+	Unit
+	list *List
+}
+
+func (o *sizingObj) Equals(other Object) (bool, error) {
+	_ = o.list.Len()
+	return o == other, nil
+}
+
+func TestListRemoveReentrantEquals(t *testing.T) {
+	list := &List{}
+	target := &sizingObj{list: list}
+	list.Elements = []Object{&sizingObj{list: list}, target, Integer(1)}
+	nested := &List{}
+	nested.Elements = []Object{Integer(1), nested}
+	runGoblins(t, 1, func(int) error {
+		if removed, err := list.Remove(CallArgs{Positional: Args{target}}); err != nil || removed != True {
+			return fmt.Errorf("remove(target) = %v, %v", removed, err)
+		}
+		if removed, err := nested.Remove(CallArgs{Positional: Args{nested}}); err != nil || removed != True {
+			return fmt.Errorf("remove(itself) = %v, %v", removed, err)
+		}
+		return nil
+	})
+	if len(list.Elements) != 2 || list.Elements[1] != Integer(1) {
+		t.Fatalf("list = %v", list)
+	}
+	if len(nested.Elements) != 1 {
+		t.Fatalf("nested = %v", nested)
+	}
+}
+
+func TestListSortDetectsConcurrentChange(t *testing.T) {
+	list := &List{Elements: []Object{Integer(2), Integer(1)}}
+	pushing := &Function{Name: "key", Fn: func(args CallArgs) (Object, error) {
+		_, err := list.Push(CallArgs{Positional: Args{Integer(3)}})
+		return args.Positional[0], err
+	}}
+	runGoblins(t, 1, func(int) error {
+		if _, err := list.sortMethod(CallArgs{Keyword: Kwargs{"key": pushing}}); err == nil || !strings.Contains(err.Error(), "list modified during sort") {
+			return fmt.Errorf("sort with a key pushing to the list = %v", err)
+		}
+		return nil
+	})
+	if got := list.String(); got != "[2, 1, 3, 3]" {
+		t.Fatalf("list = %s, want the pushes kept and the order unchanged", got)
+	}
+}
@@ -0,0 +1,45 @@
+package object
+
+// Cursor steps through the values a for loop visits, in both backends. Most
+// iterables are materialised by Iter up front; a Chan is instead received
+// from one value per step until it is closed, so the loop body runs while the
+// stream is still being produced.
+type Cursor struct {
+	items []Object
+	pos   int
+	ch    *Chan
+	err   error
+}
+
+// NewCursor starts iterating over iterable.
+func NewCursor(iterable Object) (*Cursor, error) {
+	if ch, ok := iterable.(*Chan); ok {
+		return &Cursor{ch: ch}, nil
+	}
+	items, err := iterable.Iter()
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{items: items}, nil
+}
+
+// Next returns the next value, or false once the values are exhausted.
+func (c *Cursor) Next() (Object, bool) {
+	if c.ch != nil {
+		value, ok := <-c.ch.ch
+		if !ok {
+			c.err = c.ch.closeErr
+		}
+		return value, ok
+	}
+	if c.pos >= len(c.items) {
+		return nil, false
+	}
+	c.pos++
+	return c.items[c.pos-1], true
+}
+
+// Err returns the error a drained channel was closed with, if any. A loop
+// checks it after Next reports exhaustion, so `for v in ch` re-raises the
+// error the way recv() would.
+func (c *Cursor) Err() error { return c.err }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 )
 
 type DictEntry struct {
@@ -19,6 +20,38 @@ type Dict struct {
 	// mirroring Go's map semantics.
 	buckets map[uint64][]DictEntry
 	count   int
+	// mu guards buckets and count once user code runs concurrently, with
+	// the same conditional locking as List. Keys are hashed and compared
+	// under it, which is safe because only built-in scalars are hashable.
+	mu sync.RWMutex
+}
+
+func (d *Dict) lock() bool {
+	if !InConcurrentMode() {
+		return false
+	}
+	d.mu.Lock()
+	return true
+}
+
+func (d *Dict) unlock(locked bool) {
+	if locked {
+		d.mu.Unlock()
+	}
+}
+
+func (d *Dict) rlock() bool {
+	if !InConcurrentMode() {
+		return false
+	}
+	d.mu.RLock()
+	return true
+}
+
+func (d *Dict) runlock(locked bool) {
+	if locked {
+		d.mu.RUnlock()
+	}
 }
 
 // hashKey hashes a key for bucket placement. Only Hashable types can be dict
@@ -49,15 +82,16 @@ func (d *Dict) Size(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("size", args); err != nil {
 		return nil, err
 	}
-	return Integer(d.count), nil
+	return Integer(d.Len()), nil
 }
 
 func (d *Dict) Keys(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("keys", args); err != nil {
 		return nil, err
 	}
-	keys := make([]Object, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	keys := make([]Object, 0, len(entries))
+	for _, entry := range entries {
 		keys = append(keys, entry.Key)
 	}
 	return &List{Elements: keys}, nil
@@ -67,8 +101,9 @@ func (d *Dict) Values(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("values", args); err != nil {
 		return nil, err
 	}
-	values := make([]Object, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	values := make([]Object, 0, len(entries))
+	for _, entry := range entries {
 		values = append(values, entry.Value)
 	}
 	return &List{Elements: values}, nil
@@ -78,8 +113,9 @@ func (d *Dict) Items(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("items", args); err != nil {
 		return nil, err
 	}
-	items := make([]Object, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	items := make([]Object, 0, len(entries))
+	for _, entry := range entries {
 		items = append(items, &List{Elements: []Object{entry.Key, entry.Value}})
 	}
 	return &List{Elements: items}, nil
@@ -122,14 +158,15 @@ func (d *Dict) SetDefault(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	value, ok, err := d.Get(key)
+	defer d.unlock(d.lock())
+	value, ok, err := d.get(key)
 	if err != nil {
 		return nil, err
 	}
 	if ok {
 		return value, nil
 	}
-	if err := d.Set(key, def); err != nil {
+	if err := d.set(key, def); err != nil {
 		return nil, err
 	}
 	return def, nil
@@ -177,6 +214,7 @@ func (d *Dict) Clear(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("clear", args); err != nil {
 		return nil, err
 	}
+	defer d.unlock(d.lock())
 	d.buckets = make(map[uint64][]DictEntry)
 	d.count = 0
 	return d, nil
@@ -202,11 +240,15 @@ func NewDict() *Dict {
 }
 
 // Len returns the number of entries.
-func (d *Dict) Len() int { return d.count }
+func (d *Dict) Len() int {
+	defer d.runlock(d.rlock())
+	return d.count
+}
 
 // Entries returns the entries as a fresh slice callers may keep or reorder.
 // Iteration order is unspecified, mirroring Go's map semantics.
 func (d *Dict) Entries() []DictEntry {
+	defer d.runlock(d.rlock())
 	entries := make([]DictEntry, 0, d.count)
 	for _, bucket := range d.buckets {
 		entries = append(entries, bucket...)
@@ -215,6 +257,11 @@ func (d *Dict) Entries() []DictEntry {
 }
 
 func (d *Dict) Set(key, value Object) error {
+	defer d.unlock(d.lock())
+	return d.set(key, value)
+}
+
+func (d *Dict) set(key, value Object) error {
 	hash, err := hashKey(key)
 	if err != nil {
 		return err
@@ -241,6 +288,11 @@ func (d *Dict) Set(key, value Object) error {
 }
 
 func (d *Dict) Get(key Object) (Object, bool, error) {
+	defer d.runlock(d.rlock())
+	return d.get(key)
+}
+
+func (d *Dict) get(key Object) (Object, bool, error) {
 	hash, err := hashKey(key)
 	if err != nil {
 		return nil, false, err
@@ -259,6 +311,7 @@ func (d *Dict) Get(key Object) (Object, bool, error) {
 
 // remove deletes key's entry, reporting whether one existed and its value.
 func (d *Dict) remove(key Object) (Object, bool, error) {
+	defer d.unlock(d.lock())
 	hash, err := hashKey(key)
 	if err != nil {
 		return nil, false, err
@@ -288,16 +341,18 @@ func (d *Dict) remove(key Object) (Object, bool, error) {
 func (d *Dict) TypeName() string { return "Dict" }
 
 func (d *Dict) String() string {
-	elements := make([]string, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	elements := make([]string, 0, len(entries))
+	for _, entry := range entries {
 		elements = append(elements, fmt.Sprintf("%s: %s", literal(entry.Key), literal(entry.Value)))
 	}
 	return fmt.Sprintf("{%s}", strings.Join(elements, ", "))
 }
 
 func (d *Dict) ToString() (string, error) {
-	elements := make([]string, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	elements := make([]string, 0, len(entries))
+	for _, entry := range entries {
 		key, err := literalString(entry.Key)
 		if err != nil {
 			return "", err
@@ -311,14 +366,18 @@ func (d *Dict) ToString() (string, error) {
 	return fmt.Sprintf("{%s}", strings.Join(elements, ", ")), nil
 }
 
-func (d *Dict) ToBool() (bool, error) { return d.count > 0, nil }
+func (d *Dict) ToBool() (bool, error) { return d.Len() > 0, nil }
 
 func (d *Dict) Equals(other Object) (bool, error) {
 	v, ok := other.(*Dict)
-	if !ok || d.count != v.count {
+	if !ok {
 		return false, nil
 	}
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	if len(entries) != v.Len() {
+		return false, nil
+	}
+	for _, entry := range entries {
 		theirs, exists, err := v.Get(entry.Key)
 		if err != nil {
 			return false, err
@@ -359,12 +418,13 @@ func (d *Dict) Modulo(other Object) (Object, error) {
 }
 
 func (d *Dict) Not() (Object, error) {
-	return Bool(d.count == 0), nil
+	return Bool(d.Len() == 0), nil
 }
 
 func (d *Dict) Iter() ([]Object, error) {
-	keys := make([]Object, 0, d.count)
-	for _, entry := range d.Entries() {
+	entries := d.Entries()
+	keys := make([]Object, 0, len(entries))
+	for _, entry := range entries {
 		keys = append(keys, entry.Key)
 	}
 	return keys, nil
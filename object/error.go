@@ -19,6 +19,32 @@ type Frame struct {
 	Column   int
 }
 
+// String renders the frame as a traceback line shows it, without the
+// leading "at": the function, its module unless that is main, and the
+// position, as in "fib [util] (util.goblin:3:5)".
+func (f Frame) String() string {
+	var b strings.Builder
+	name := f.Function
+	if name == "" {
+		name = "<module>"
+	}
+	b.WriteString(name)
+	if f.Module != "" && f.Module != "main" {
+		fmt.Fprintf(&b, " [%s]", f.Module)
+	}
+	if f.File != "" {
+		fmt.Fprintf(&b, " (%s", f.File)
+		if f.Line > 0 {
+			fmt.Fprintf(&b, ":%d", f.Line)
+			if f.Column > 0 {
+				fmt.Fprintf(&b, ":%d", f.Column)
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
 type Error struct {
 	NoReflectedOps
 	NoAssignment
@@ -31,6 +57,9 @@ type Error struct {
 	// Frames are ordered from the point where the error was first observed to
 	// the outermost caller. WithFrame treats Error values as immutable.
 	Frames []Frame
+	// Suppressed holds the errors of sibling goblins that failed alongside
+	// this one, in the order they failed. See WithSuppressed.
+	Suppressed []error
 }
 
 var _ Object = (*Error)(nil)
@@ -137,42 +166,50 @@ func WithFrame(err error, frame Frame) error {
 	if e, ok := err.(*Error); ok {
 		frames := append([]Frame(nil), e.Frames...)
 		frames = append(frames, frame)
-		return &Error{Value: e.Value, Wrapped: e, Frames: frames}
+		return &Error{Value: e.Value, Wrapped: e, Frames: frames, Suppressed: e.Suppressed}
 	}
 	return &Error{Value: err.Error(), Wrapped: err, Frames: []Frame{frame}}
 }
 
+// WithSuppressed returns err carrying others as suppressed errors. It is how
+// a group of goblins reports every failure while raising only the first: the
+// result keeps err's message, frames and cause chain, so catch, .is() and the
+// traceback behave exactly as they would for err alone.
+func WithSuppressed(err error, others []error) error {
+	if err == nil || len(others) == 0 {
+		return err
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Value: err.Error(), Wrapped: err}
+	}
+	suppressed := append(append([]error(nil), e.Suppressed...), others...)
+	return &Error{Value: e.Value, Wrapped: e, Frames: e.Frames, Suppressed: suppressed}
+}
+
 // Traceback renders the language-level stack without exposing generated Go
 // functions or the interpreter's own implementation stack.
 func (e *Error) Traceback() string {
 	if len(e.Frames) == 0 {
-		return e.Value
+		return e.Value + e.suppressedNote()
 	}
 	var b strings.Builder
 	b.WriteString("Traceback (most recent call last):\n")
 	for i := len(e.Frames) - 1; i >= 0; i-- {
-		f := e.Frames[i]
-		name := f.Function
-		if name == "" {
-			name = "<module>"
-		}
-		fmt.Fprintf(&b, "  at %s", name)
-		if f.Module != "" && f.Module != "main" {
-			fmt.Fprintf(&b, " [%s]", f.Module)
-		}
-		if f.File != "" {
-			fmt.Fprintf(&b, " (%s", f.File)
-			if f.Line > 0 {
-				fmt.Fprintf(&b, ":%d", f.Line)
-				if f.Column > 0 {
-					fmt.Fprintf(&b, ":%d", f.Column)
-				}
-			}
-			b.WriteByte(')')
-		}
-		b.WriteByte('\n')
+		fmt.Fprintf(&b, "  at %s\n", e.Frames[i])
 	}
 	b.WriteString(e.Value)
+	b.WriteString(e.suppressedNote())
+	return b.String()
+}
+
+// suppressedNote lists suppressed sibling failures, one per line, so a
+// traceback printed at exit does not hide them.
+func (e *Error) suppressedNote() string {
+	var b strings.Builder
+	for _, err := range e.Suppressed {
+		fmt.Fprintf(&b, "\n  also failed: %s", err.Error())
+	}
 	return b.String()
 }
 
@@ -207,12 +244,14 @@ func (e *Error) GetAttr(name string) (Object, error) {
 		return ErrorConstructorFn, nil
 	case "traceback":
 		return &Function{Name: "traceback", Fn: e.TracebackValue}, nil
+	case "suppressed":
+		return &Function{Name: "suppressed", Fn: e.SuppressedValue}, nil
 	}
 	return nil, NewAttributeError("Error has no attribute '%s'", name)
 }
 
 func (e *Error) Attributes() []string {
-	return []string{"attributes", "message", "wrap", "unwrap", "is", "constructor", "traceback"}
+	return []string{"attributes", "message", "wrap", "unwrap", "is", "constructor", "traceback", "suppressed"}
 }
 
 // TracebackValue exposes traceback formatting to Goblin as err.traceback().
@@ -223,6 +262,19 @@ func (e *Error) TracebackValue(args CallArgs) (Object, error) {
 	return String(e.Traceback()), nil
 }
 
+// SuppressedValue returns the suppressed sibling failures as a List of
+// Error, empty when there are none. Usage: err.suppressed().
+func (e *Error) SuppressedValue(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("suppressed", args); err != nil {
+		return nil, err
+	}
+	elements := make([]Object, len(e.Suppressed))
+	for i, err := range e.Suppressed {
+		elements[i] = ErrorValue(err)
+	}
+	return &List{Elements: elements}, nil
+}
+
 // Wrap returns a new Error that carries message and wraps the receiver as its
 // cause, mirroring Go's fmt.Errorf("message: %w", err). Usage: err.wrap("msg").
 func (e *Error) Wrap(args CallArgs) (Object, error) {
@@ -300,6 +352,15 @@ var (
 
 	NotImplementedError = NewSentinelError("NotImplementedError", BaseError)
 	InternalError       = NewSentinelError("InternalError", BaseError)
+
+	// LimitError and its kinds stop a sandboxed program that exceeds one of
+	// its limits. Goblin code cannot catch them.
+	LimitError          = NewSentinelError("LimitError", BaseError)
+	StepLimitError      = NewSentinelError("StepLimitError", LimitError)
+	TimeLimitError      = NewSentinelError("TimeLimitError", LimitError)
+	RecursionLimitError = NewSentinelError("RecursionLimitError", LimitError)
+	GoblinLimitError    = NewSentinelError("GoblinLimitError", LimitError)
+	MemoryLimitError    = NewSentinelError("MemoryLimitError", LimitError)
 )
 
 // NewInternalError creates an InternalError-tagged error. It marks failures of
@@ -346,6 +407,11 @@ func NewTimeoutError(format string, a ...any) *Error {
 	return typedError(TimeoutError, format, a...)
 }
 
+// NewLimitError creates an error of kind, one of the kinds of LimitError.
+func NewLimitError(kind *Error, format string, a ...any) *Error {
+	return typedError(kind, format, a...)
+}
+
 func ErrorKind(err error, fallback *Error) *Error {
 	if errors.Is(err, fs.ErrNotExist) {
 		return NotExistError
@@ -113,6 +113,8 @@ func FloatConstructor(args CallArgs) (Object, error) {
 		return v, nil
 	case Integer:
 		return Float(float64(int64(v))), nil
+	case *BigInt:
+		return Float(v.Float64()), nil
 	case String:
 		n, err := strconv.ParseFloat(string(v), 64)
 		if err != nil {
@@ -1,18 +1,63 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
 type Function struct {
 	NoReflectedOps
 	NoAssignment
 	Name string
 	Fn   func(CallArgs) (Object, error)
+	// Members holds attributes a type object exposes beyond the ones every
+	// callable has, such as Goblin.all. It is nil for ordinary functions.
+	Members map[string]Object
+	// Doc is what help() shows for the function: its signature and doc
+	// comment. The interpreter sets it for Goblin types; read it through
+	// Help.
+	Doc string
+	// LazyDoc, when set, computes Doc the first time Help is called. The
+	// interpreter sets it for Goblin functions, whose definitions are
+	// evaluated far more often than they are asked for help.
+	LazyDoc func() string
+	docOnce sync.Once
+	// BeforeGoblin, when set, is called before a goblin starts running the
+	// function, which does not start if it fails. The interpreter sets it to
+	// cap the goblins a sandboxed program starts.
+	BeforeGoblin func() error
+	// Code, when set, is the interpreter's own form of the function, which
+	// its bytecode VM calls directly instead of through Fn. Everything else
+	// calls Fn, which behaves the same.
+	Code any
 }
 
 func (f *Function) Call(args CallArgs) (Object, error) {
 	return f.Fn(args)
 }
 
+// CheckGoblin is called by everything that starts a goblin running f, before
+// starting it.
+func (f *Function) CheckGoblin() error {
+	if f.BeforeGoblin == nil {
+		return nil
+	}
+	return f.BeforeGoblin()
+}
+
+// Help returns what help() shows for the function, computing it from LazyDoc
+// on first use.
+func (f *Function) Help() string {
+	f.docOnce.Do(func() {
+		if f.LazyDoc != nil {
+			f.Doc = f.LazyDoc()
+			f.LazyDoc = nil
+		}
+	})
+	return f.Doc
+}
+
 func (f *Function) TypeName() string { return "Function" }
 
 func (f *Function) String() string { return fmt.Sprintf("<function %s>", f.Name) }
@@ -54,12 +99,22 @@ func (f *Function) GetAttr(name string) (Object, error) {
 		return AttributesFunction(f), nil
 	case "constructor":
 		return FunctionConstructorFn, nil
-	default:
-		return nil, NewAttributeError("Function has no attribute '%s'", name)
 	}
+	if member, ok := f.Members[name]; ok {
+		return member, nil
+	}
+	return nil, NewAttributeError("Function has no attribute '%s'", name)
 }
 
-func (f *Function) Attributes() []string { return []string{"attributes", "constructor"} }
+func (f *Function) Attributes() []string {
+	names := []string{"attributes", "constructor"}
+	members := make([]string, 0, len(f.Members))
+	for name := range f.Members {
+		members = append(members, name)
+	}
+	sort.Strings(members)
+	return append(names, members...)
+}
 
 var _ Object = (*Function)(nil)
 
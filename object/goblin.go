@@ -1,6 +1,8 @@
 package object
 
 import (
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -68,6 +70,8 @@ func (g *Goblin) GetAttr(name string) (Object, error) {
 		return &Function{Name: "done", Fn: g.Done}, nil
 	case "wait":
 		return &Function{Name: "wait", Fn: g.Wait}, nil
+	case "then":
+		return &Function{Name: "then", Fn: g.Then}, nil
 	case "constructor":
 		return GoblinConstructorFn, nil
 	default:
@@ -76,7 +80,7 @@ func (g *Goblin) GetAttr(name string) (Object, error) {
 }
 
 func (g *Goblin) Attributes() []string {
-	return []string{"attributes", "done", "wait", "constructor"}
+	return []string{"attributes", "done", "wait", "then", "constructor"}
 }
 
 // Done reports without blocking whether the function has finished.
@@ -99,33 +103,27 @@ func (g *Goblin) Done(args CallArgs) (Object, error) {
 // still running when it expires; a nil timeout means wait forever.
 func (g *Goblin) Wait(args CallArgs) (Object, error) {
 	p := NewArgParser("wait", args)
-	tv, supplied := p.OptionalAny("timeout")
+	timeout, hasTimeout := p.Timeout("timeout")
 	if err := p.Finish(); err != nil {
 		return nil, err
 	}
-	if supplied && tv != Nil {
-		var seconds float64
-		switch n := tv.(type) {
-		case Integer:
-			seconds = float64(n)
-		case Float:
-			seconds = float64(n)
-		default:
-			return nil, NewTypeError("wait() argument 'timeout' must be number or nil, got %s", tv.TypeName())
-		}
-		if seconds < 0 {
-			return nil, NewValueError("wait() timeout must be non-negative, got %g", seconds)
-		}
-		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+	if hasTimeout {
+		timer := time.NewTimer(timeout)
 		defer timer.Stop()
 		select {
 		case <-g.done:
 		case <-timer.C:
-			return nil, NewTimeoutError("wait() timed out after %g seconds", seconds)
+			return nil, NewTimeoutError("wait() timed out after %g seconds", timeout.Seconds())
 		}
 	} else {
 		<-g.done
 	}
+	return g.outcome()
+}
+
+// outcome returns the finished function's result or error. It must only be
+// called after done has been observed closed.
+func (g *Goblin) outcome() (Object, error) {
 	if g.err != nil {
 		return nil, g.err
 	}
@@ -135,7 +133,30 @@ func (g *Goblin) Wait(args CallArgs) (Object, error) {
 	return g.result, nil
 }
 
-var GoblinConstructorFn = &Function{Name: "Goblin", Fn: GoblinConstructor}
+// Then starts a goblin that waits for this one and calls fn with its result.
+// If this goblin fails, fn is not called and the new handle raises the same
+// error, so a chain of then() calls fails as a whole.
+func (g *Goblin) Then(args CallArgs) (Object, error) {
+	p := NewArgParser("then", args)
+	fn := p.Func("fn")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	return launchGoblin(func() (Object, error) {
+		<-g.done
+		result, err := g.outcome()
+		if err != nil {
+			return nil, err
+		}
+		return fn.Call(CallArgs{Positional: Args{result}})
+	}, nil), nil
+}
+
+var GoblinConstructorFn = &Function{Name: "Goblin", Fn: GoblinConstructor, Members: map[string]Object{
+	"all": &Function{Name: "all", Fn: goblinAll},
+	"any": &Function{Name: "any", Fn: goblinAny},
+	"map": &Function{Name: "map", Fn: goblinMap},
+}}
 
 // GoblinConstructor starts fn in a new goroutine with the remaining positional
 // arguments and returns a handle to it. A Go-level panic inside the function
@@ -148,16 +169,185 @@ func GoblinConstructor(args CallArgs) (Object, error) {
 	if err := p.Finish(); err != nil {
 		return nil, err
 	}
+	if err := fn.CheckGoblin(); err != nil {
+		return nil, err
+	}
+	return launchGoblin(func() (Object, error) {
+		return fn.Call(CallArgs{Positional: rest})
+	}, nil), nil
+}
+
+// launchGoblin runs body in a new goroutine and returns its handle. finish,
+// when non-nil, is called in that goroutine with the finished handle just
+// before done is closed, so anyone who observes done also observes its
+// effects.
+func launchGoblin(body func() (Object, error), finish func(*Goblin)) *Goblin {
 	g := &Goblin{OpaqueBase: MakeOpaqueBase("Goblin"), done: make(chan struct{})}
 	EnterConcurrentMode()
 	go func() {
 		defer close(g.done)
-		defer func() {
-			if r := recover(); r != nil {
-				g.err = NewInternalError("panic in goblin: %v", r)
+		g.result, g.err = runContained(body)
+		if finish != nil {
+			finish(g)
+		}
+	}()
+	return g
+}
+
+// runContained calls body, turning a Go-level panic into an InternalError.
+func runContained(body func() (Object, error)) (result Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, NewInternalError("panic in goblin: %v", r)
+		}
+	}()
+	return body()
+}
+
+// goblinHandles checks that every element of a handles list is a Goblin.
+func goblinHandles(fn string, handles *List) ([]*Goblin, error) {
+	elements := handles.Snapshot()
+	result := make([]*Goblin, len(elements))
+	for i, elem := range elements {
+		g, ok := elem.(*Goblin)
+		if !ok {
+			return nil, NewTypeError("%s() argument 'handles' must contain only Goblin, got %s", fn, elem.TypeName())
+		}
+		result[i] = g
+	}
+	return result, nil
+}
+
+// finishOrder delivers the index of each handle as it finishes. The channel
+// is buffered for every handle, so a caller that stops reading early leaves
+// no goroutine blocked.
+func finishOrder(handles []*Goblin) <-chan int {
+	order := make(chan int, len(handles))
+	for i, g := range handles {
+		go func(i int, g *Goblin) {
+			<-g.done
+			order <- i
+		}(i, g)
+	}
+	return order
+}
+
+// goblinAll waits for every handle and returns their results as a List in
+// the order of handles. It fails fast: the first handle to fail decides the
+// error, without waiting for the rest.
+func goblinAll(args CallArgs) (Object, error) {
+	p := NewArgParser("all", args)
+	list := p.List("handles")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	handles, err := goblinHandles("all", list)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Object, len(handles))
+	order := finishOrder(handles)
+	for range handles {
+		i := <-order
+		result, err := handles[i].outcome()
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return &List{Elements: results}, nil
+}
+
+// goblinAny returns the result of the first handle to succeed. If every
+// handle fails, the first failure is raised with the others suppressed.
+func goblinAny(args CallArgs) (Object, error) {
+	p := NewArgParser("any", args)
+	list := p.List("handles")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	handles, err := goblinHandles("any", list)
+	if err != nil {
+		return nil, err
+	}
+	if len(handles) == 0 {
+		return nil, NewValueError("any() argument 'handles' must not be empty")
+	}
+	var failures []error
+	order := finishOrder(handles)
+	for range handles {
+		result, err := handles[<-order].outcome()
+		if err == nil {
+			return result, nil
+		}
+		failures = append(failures, err)
+	}
+	return nil, WithSuppressed(failures[0], failures[1:])
+}
+
+// goblinMap calls fn on every item with at most workers calls running at
+// once and returns the results as a List in item order. After the first
+// failure no new item is started; calls already running are waited for, and
+// the first failure is raised with any later ones suppressed.
+func goblinMap(args CallArgs) (Object, error) {
+	p := NewArgParser("map", args)
+	fn := p.Func("fn")
+	iterable := p.Any("items")
+	workers := p.IntOr("workers", Integer(runtime.GOMAXPROCS(0)))
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		return nil, NewValueError("map() workers must be positive, got %d", int64(workers))
+	}
+	items, err := iterable.Iter()
+	if err != nil {
+		return nil, NewTypeError("map() argument 'items' is not iterable: %s", err)
+	}
+	if int(workers) > len(items) {
+		workers = Integer(len(items))
+	}
+
+	results := make([]Object, len(items))
+	var (
+		next     atomic.Int64
+		failed   atomic.Bool
+		mu       sync.Mutex
+		failures []error
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < int(workers); w++ {
+		if err := fn.CheckGoblin(); err != nil {
+			return nil, err
+		}
+	}
+	EnterConcurrentMode()
+	for w := 0; w < int(workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(items) {
+					return
+				}
+				result, err := runContained(func() (Object, error) {
+					return fn.Call(CallArgs{Positional: Args{items[i]}})
+				})
+				if err != nil {
+					failed.Store(true)
+					mu.Lock()
+					failures = append(failures, err)
+					mu.Unlock()
+					return
+				}
+				results[i] = result
 			}
 		}()
-		g.result, g.err = fn.Call(CallArgs{Positional: rest})
-	}()
-	return g, nil
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		return nil, WithSuppressed(failures[0], failures[1:])
+	}
+	return &List{Elements: results}, nil
 }
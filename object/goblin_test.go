@@ -139,3 +139,158 @@ func TestGoblinConstructorRequiresFunction(t *testing.T) {
 		t.Fatal("Goblin(1) should fail")
 	}
 }
+
+func handleList(handles ...*Goblin) *List {
+	elements := make([]Object, len(handles))
+	for i, g := range handles {
+		elements[i] = g
+	}
+	return &List{Elements: elements}
+}
+
+func TestGoblinAllReturnsResultsInHandleOrder(t *testing.T) {
+	release := make(chan struct{})
+	slow := startGoblin(t, func(CallArgs) (Object, error) {
+		<-release
+		return Integer(1), nil
+	})
+	fast := startGoblin(t, func(CallArgs) (Object, error) {
+		defer close(release)
+		return Integer(2), nil
+	})
+	got, err := goblinAll(CallArgs{Positional: Args{handleList(slow, fast)}})
+	if err != nil {
+		t.Fatalf("all: %v", err)
+	}
+	if eq, _ := Equals(got, &List{Elements: []Object{Integer(1), Integer(2)}}); !eq {
+		t.Fatalf("all = %v, want [1, 2]", got)
+	}
+}
+
+func TestGoblinAllFailsFast(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blocked := startGoblin(t, func(CallArgs) (Object, error) {
+		<-release
+		return Nil, nil
+	})
+	raised := NewValueError("boom")
+	failing := startGoblin(t, func(CallArgs) (Object, error) { return nil, raised })
+	if _, err := goblinAll(CallArgs{Positional: Args{handleList(blocked, failing)}}); err != raised {
+		t.Fatalf("all error = %v, want the raised error", err)
+	}
+}
+
+func TestGoblinAllRejectsNonHandles(t *testing.T) {
+	_, err := goblinAll(CallArgs{Positional: Args{&List{Elements: []Object{Integer(1)}}}})
+	if !errors.Is(err, TypeError) {
+		t.Fatalf("all([1]) error = %v, want TypeError", err)
+	}
+}
+
+func TestGoblinAnyReturnsFirstSuccess(t *testing.T) {
+	failing := startGoblin(t, func(CallArgs) (Object, error) { return nil, NewValueError("no") })
+	ok := startGoblin(t, func(CallArgs) (Object, error) { return Integer(5), nil })
+	got, err := goblinAny(CallArgs{Positional: Args{handleList(failing, ok)}})
+	if err != nil || got != Integer(5) {
+		t.Fatalf("any = %v, %v, want 5", got, err)
+	}
+}
+
+func TestGoblinAnySuppressesRemainingFailures(t *testing.T) {
+	a := startGoblin(t, func(CallArgs) (Object, error) { return nil, NewValueError("a") })
+	b := startGoblin(t, func(CallArgs) (Object, error) { return nil, NewKeyError("b") })
+	_, err := goblinAny(CallArgs{Positional: Args{handleList(a, b)}})
+	e, ok := err.(*Error)
+	if !ok || len(e.Suppressed) != 1 {
+		t.Fatalf("any error = %#v, want one suppressed failure", err)
+	}
+	if _, err := goblinAny(CallArgs{Positional: Args{&List{}}}); !errors.Is(err, ValueError) {
+		t.Fatalf("any([]) error = %v, want ValueError", err)
+	}
+}
+
+func TestGoblinMapBoundsWorkers(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+	fn := &Function{Name: "fn", Fn: func(args CallArgs) (Object, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		n := args.Positional[0].(Integer)
+		return n * 10, nil
+	}}
+	items := &List{Elements: []Object{Integer(1), Integer(2), Integer(3), Integer(4), Integer(5)}}
+	got, err := goblinMap(CallArgs{
+		Positional: Args{fn, items},
+		Keyword:    Kwargs{"workers": Integer(2)},
+	})
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+	want := &List{Elements: []Object{Integer(10), Integer(20), Integer(30), Integer(40), Integer(50)}}
+	if eq, _ := Equals(got, want); !eq {
+		t.Fatalf("map = %v, want %v", got, want)
+	}
+	if peak > 2 {
+		t.Fatalf("map ran %d calls at once, want at most 2", peak)
+	}
+}
+
+func TestGoblinMapStopsAfterFailure(t *testing.T) {
+	var calls int32
+	var mu sync.Mutex
+	fn := &Function{Name: "fn", Fn: func(args CallArgs) (Object, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil, NewValueError("bad item %v", args.Positional[0])
+	}}
+	items := &List{Elements: []Object{Integer(1), Integer(2), Integer(3), Integer(4)}}
+	_, err := goblinMap(CallArgs{Positional: Args{fn, items}, Keyword: Kwargs{"workers": Integer(1)}})
+	if !errors.Is(err, ValueError) {
+		t.Fatalf("map error = %v, want ValueError", err)
+	}
+	if calls != 1 {
+		t.Fatalf("map made %d calls after the first failure, want 1", calls)
+	}
+	if _, err := goblinMap(CallArgs{Positional: Args{fn, items}, Keyword: Kwargs{"workers": Integer(0)}}); !errors.Is(err, ValueError) {
+		t.Fatalf("map(workers=0) error = %v, want ValueError", err)
+	}
+}
+
+func TestGoblinThenChainsResult(t *testing.T) {
+	g := startGoblin(t, func(CallArgs) (Object, error) { return Integer(3), nil })
+	next, err := g.Then(CallArgs{Positional: Args{&Function{Name: "inc", Fn: func(args CallArgs) (Object, error) {
+		return args.Positional[0].(Integer) + 1, nil
+	}}}})
+	if err != nil {
+		t.Fatalf("then: %v", err)
+	}
+	if got, err := next.(*Goblin).Wait(CallArgs{}); err != nil || got != Integer(4) {
+		t.Fatalf("then().wait() = %v, %v, want 4", got, err)
+	}
+}
+
+func TestGoblinThenSkipsFunctionOnFailure(t *testing.T) {
+	raised := NewValueError("boom")
+	g := startGoblin(t, func(CallArgs) (Object, error) { return nil, raised })
+	called := false
+	next, _ := g.Then(CallArgs{Positional: Args{&Function{Name: "fn", Fn: func(CallArgs) (Object, error) {
+		called = true
+		return Nil, nil
+	}}}})
+	if _, err := next.(*Goblin).Wait(CallArgs{}); err != raised {
+		t.Fatalf("then().wait() error = %v, want the original error", err)
+	}
+	if called {
+		t.Fatal("then() called fn after the goblin failed")
+	}
+}
@@ -0,0 +1,134 @@
+package object
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// FromGo converts a plain Go value to the Goblin value a Goblin function
+// expects, for Go code calling into Goblin, such as the wrappers goblin
+// build-lib generates. nil becomes nil, booleans, integers, floats and strings
+// their Goblin counterparts, *big.Int an Integer, []byte Bytes, slices and
+// arrays a List, and maps with string keys a Dict, recursively. An Object is
+// returned as it is; any other value raises a TypeError.
+func FromGo(v any) (Object, error) {
+	switch x := v.(type) {
+	case nil:
+		return Nil, nil
+	case Object:
+		return x, nil
+	case bool:
+		return Bool(x), nil
+	case string:
+		return String(x), nil
+	case []byte:
+		return NewBytes(x), nil
+	case *big.Int:
+		return NewInt(new(big.Int).Set(x)), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Integer(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > 1<<63-1 {
+			return NewInt(new(big.Int).SetUint64(u)), nil
+		}
+		return Integer(u), nil
+	case reflect.Float32, reflect.Float64:
+		return Float(rv.Float()), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		return FromGo(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return Nil, nil
+		}
+		elements := make([]Object, rv.Len())
+		for i := range elements {
+			e, err := FromGo(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = e
+		}
+		return &List{Elements: elements}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		d := NewDict()
+		iter := rv.MapRange()
+		for iter.Next() {
+			value, err := FromGo(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			if err := d.Set(String(iter.Key().String()), value); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+	return nil, NewTypeError("cannot convert Go value of type %T to a Goblin value", v)
+}
+
+// ToGo converts a Goblin value to a plain Go value, the inverse of FromGo:
+// nil becomes nil, a Bool a bool, an Integer an int64, or a *big.Int when it
+// does not fit, a Float a float64, a Str a string, Bytes a []byte, a List an
+// []any, and a Dict with Str keys a map[string]any, recursively. Other values,
+// such as instances of user-defined types, are returned as they are.
+func ToGo(obj Object) (any, error) {
+	switch x := obj.(type) {
+	case Unit:
+		return nil, nil
+	case Bool:
+		return bool(x), nil
+	case Integer:
+		return int64(x), nil
+	case *BigInt:
+		return new(big.Int).Set(x.Int()), nil
+	case Float:
+		return float64(x), nil
+	case String:
+		return string(x), nil
+	case Bytes:
+		return append([]byte(nil), x...), nil
+	case *List:
+		elements := x.Snapshot()
+		result := make([]any, len(elements))
+		for i, e := range elements {
+			v, err := ToGo(e)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	case *Dict:
+		result := make(map[string]any, x.Len())
+		for _, entry := range x.Entries() {
+			key, ok := entry.Key.(String)
+			if !ok {
+				return nil, NewTypeError("cannot convert Dict with %s keys to a Go map", entry.Key.TypeName())
+			}
+			v, err := ToGo(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			result[string(key)] = v
+		}
+		return result, nil
+	}
+	return obj, nil
+}
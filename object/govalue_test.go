@@ -0,0 +1,85 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestFromGo(t *testing.T) {
+	type celsius float64
+	n := 7
+	cases := []struct {
+		in   any
+		want string
+	}{
+		{nil, "nil"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{uint64(1) << 63, "9223372036854775808"},
+		{celsius(21.5), "21.5"},
+		{"hi", "hi"},
+		{&n, "7"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, `["a", "b"]`},
+		{map[string]any{"k": []any{1, "x"}}, `{"k": [1, "x"]}`},
+		{String("as is"), "as is"},
+	}
+	for _, c := range cases {
+		got, err := FromGo(c.in)
+		if err != nil {
+			t.Fatalf("FromGo(%#v): %v", c.in, err)
+		}
+		if fmt.Sprint(got) != c.want {
+			t.Errorf("FromGo(%#v) = %s, want %s", c.in, got, c.want)
+		}
+	}
+	if got, err := FromGo(uint64(1) << 63); err != nil || got.TypeName() != "Integer" {
+		t.Errorf("FromGo(1<<63) = %v (%s), %v", got, got.TypeName(), err)
+	}
+	for _, bad := range []any{map[int]int{1: 1}, struct{}{}, func() {}, []any{make(chan int)}} {
+		if _, err := FromGo(bad); err == nil {
+			t.Errorf("FromGo(%T) succeeded", bad)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	d := NewDict()
+	if err := d.Set(String("list"), &List{Elements: []Object{Integer(1), Float(2.5), Nil, NewBytes([]byte("b"))}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToGo(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"list": []any{int64(1), 2.5, nil, []byte("b")}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ToGo = %#v, want %#v", got, want)
+	}
+
+	big1, _ := FromGo(new(big.Int).Lsh(big.NewInt(1), 70))
+	if got, err := ToGo(big1); err != nil || got.(*big.Int).BitLen() != 71 {
+		t.Fatalf("ToGo(2**70) = %v, %v", got, err)
+	}
+
+	// What FromGo makes, ToGo turns back into the same Go values.
+	in := map[string]any{"name": "x", "ok": true, "n": int64(3), "tags": []any{"a"}}
+	obj, err := FromGo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back, err := ToGo(obj); err != nil || !reflect.DeepEqual(back, in) {
+		t.Fatalf("ToGo(FromGo(%v)) = %v, %v", in, back, err)
+	}
+
+	bad := NewDict()
+	if err := bad.Set(Integer(1), Integer(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ToGo(bad); err == nil {
+		t.Fatal("ToGo of a Dict with Integer keys succeeded")
+	}
+}
@@ -1,6 +1,8 @@
 package object
 
 import (
+	"math"
+	"math/big"
 	"strconv"
 )
 
@@ -109,16 +111,31 @@ func IntConstructor(args CallArgs) (Object, error) {
 		return nil, err
 	}
 	switch v := value.(type) {
-	case Integer:
+	case Integer, *BigInt:
 		return v, nil
 	case Float:
-		return Integer(int64(v)), nil
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, NewValueError("Int() cannot convert %s to Int", v.String())
+		}
+		// -2^63 is exact in float64 but 2^63 is not, so the bounds are
+		// half-open.
+		if f >= -(1<<63) && f < 1<<63 {
+			return Integer(int64(f)), nil
+		}
+		n, _ := big.NewFloat(f).Int(nil)
+		return NewInt(n), nil
 	case String:
-		n, err := strconv.ParseInt(string(v), 10, 64)
-		if err != nil {
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return Integer(n), nil
+		}
+		// Literals beyond int64 are still integers; anything ParseInt rejected
+		// for another reason is rejected by SetString too.
+		n, ok := new(big.Int).SetString(string(v), 10)
+		if !ok {
 			return nil, NewValueError("Int() invalid literal for Int: %q", string(v))
 		}
-		return Integer(n), nil
+		return NewInt(n), nil
 	case Bool:
 		if bool(v) {
 			return Integer(1), nil
@@ -4,20 +4,101 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 )
 
 type List struct {
 	NoReflectedOps
 	NoAssignment
 	Elements []Object
+	// mu guards Elements once user code runs concurrently; see lock.
+	mu sync.RWMutex
+	// version counts the writes to Elements, so a method that reads a
+	// snapshot and calls Goblin code before writing can tell whether the
+	// list changed meanwhile.
+	version uint64
 }
 
 var _ Object = &List{}
 
+// lock takes the list's write lock, but only in concurrent mode, so
+// single-threaded programs never pay for it, and counts a write. It reports
+// whether it locked; pass that to unlock (`defer l.unlock(l.lock())`) so the
+// mode switching between the two calls cannot unbalance the mutex.
+func (l *List) lock() bool {
+	if !InConcurrentMode() {
+		l.version++
+		return false
+	}
+	l.mu.Lock()
+	l.version++
+	return true
+}
+
+func (l *List) unlock(locked bool) {
+	if locked {
+		l.mu.Unlock()
+	}
+}
+
+// rlock is lock for readers.
+func (l *List) rlock() bool {
+	if !InConcurrentMode() {
+		return false
+	}
+	l.mu.RLock()
+	return true
+}
+
+func (l *List) runlock(locked bool) {
+	if locked {
+		l.mu.RUnlock()
+	}
+}
+
+// Snapshot returns the elements for reading. In concurrent mode it is a copy
+// taken under the lock, so the caller may walk it, and call back into Goblin
+// code while doing so, as other goblins mutate the list. Otherwise it is the
+// backing slice itself, which the caller must not modify.
+func (l *List) Snapshot() []Object {
+	if !InConcurrentMode() {
+		return l.Elements
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Object(nil), l.Elements...)
+}
+
+// versioned is Snapshot, along with the version of the list it was taken
+// at. Once lock returns, the list is unchanged since then if its version is
+// one more.
+func (l *List) versioned() ([]Object, uint64) {
+	if !InConcurrentMode() {
+		return l.Elements, l.version
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Object(nil), l.Elements...), l.version
+}
+
+// Len returns the number of elements.
+func (l *List) Len() int {
+	defer l.runlock(l.rlock())
+	return len(l.Elements)
+}
+
+// Mutate calls fn with the elements while holding the write lock, for Go code
+// that rearranges a list in place. fn must not call back into Goblin code.
+func (l *List) Mutate(fn func(elements []Object)) {
+	defer l.unlock(l.lock())
+	fn(l.Elements)
+}
+
 func (l *List) Size(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("size", args); err != nil {
 		return nil, err
 	}
+	defer l.runlock(l.rlock())
 	return Integer(len(l.Elements)), nil
 }
 
@@ -27,6 +108,7 @@ func (l *List) Push(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
+	defer l.unlock(l.lock())
 	l.Elements = append(l.Elements, values...)
 	return l, nil
 }
@@ -37,6 +119,7 @@ func (l *List) Pop(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
+	defer l.unlock(l.lock())
 	if len(l.Elements) == 0 {
 		return nil, NewIndexError("pop from empty list")
 	}
@@ -54,6 +137,7 @@ func (l *List) First(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("first", args); err != nil {
 		return nil, err
 	}
+	defer l.runlock(l.rlock())
 	if len(l.Elements) == 0 {
 		return nil, NewIndexError("first() called on empty list")
 	}
@@ -64,6 +148,7 @@ func (l *List) Last(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("last", args); err != nil {
 		return nil, err
 	}
+	defer l.runlock(l.rlock())
 	if len(l.Elements) == 0 {
 		return nil, NewIndexError("last() called on empty list")
 	}
@@ -87,8 +172,9 @@ func (l *List) Join(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	elements := make([]string, len(l.Elements))
-	for i, elem := range l.Elements {
+	snapshot := l.Snapshot()
+	elements := make([]string, len(snapshot))
+	for i, elem := range snapshot {
 		s, err := elem.ToString()
 		if err != nil {
 			return nil, err
@@ -104,6 +190,7 @@ func (l *List) Insert(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
+	defer l.unlock(l.lock())
 	i := int(index)
 	if i < 0 {
 		i += len(l.Elements)
@@ -126,7 +213,7 @@ func (l *List) Contains(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	for _, elem := range l.Elements {
+	for _, elem := range l.Snapshot() {
 		eq, err := Equals(elem, value)
 		if err != nil {
 			return nil, err
@@ -145,7 +232,7 @@ func (l *List) Count(args CallArgs) (Object, error) {
 		return nil, err
 	}
 	count := 0
-	for _, elem := range l.Elements {
+	for _, elem := range l.Snapshot() {
 		eq, err := Equals(elem, value)
 		if err != nil {
 			return nil, err
@@ -164,15 +251,16 @@ func (l *List) IndexOf(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
+	elements := l.Snapshot()
 	i := int(start)
 	if i < 0 {
-		i += len(l.Elements)
+		i += len(elements)
 	}
 	if i < 0 {
 		i = 0
 	}
-	for ; i < len(l.Elements); i++ {
-		eq, err := Equals(l.Elements[i], value)
+	for ; i < len(elements); i++ {
+		eq, err := Equals(elements[i], value)
 		if err != nil {
 			return nil, err
 		}
@@ -189,24 +277,57 @@ func (l *List) Remove(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	for i, elem := range l.Elements {
-		eq, err := Equals(elem, value)
-		if err != nil {
-			return nil, err
+	// Equals may run Goblin code, so compare against a snapshot with no
+	// lock held, then remove the element found unless it left the list
+	// meanwhile, and look again if it did.
+	for {
+		elements, version := l.versioned()
+		found := -1
+		for i, elem := range elements {
+			eq, err := Equals(elem, value)
+			if err != nil {
+				return nil, err
+			}
+			if eq {
+				found = i
+				break
+			}
 		}
-		if eq {
-			copy(l.Elements[i:], l.Elements[i+1:])
-			l.Elements = l.Elements[:len(l.Elements)-1]
+		if found < 0 {
+			return False, nil
+		}
+		if l.removeFound(elements[found], found, version) {
 			return True, nil
 		}
 	}
-	return False, nil
+}
+
+// removeFound removes elem, which was at index i when the list was at
+// version, and reports whether it was still in the list.
+func (l *List) removeFound(elem Object, i int, version uint64) bool {
+	defer l.unlock(l.lock())
+	if l.version != version+1 {
+		i = -1
+		for j, e := range l.Elements {
+			if identical(e, elem) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return false
+		}
+	}
+	copy(l.Elements[i:], l.Elements[i+1:])
+	l.Elements = l.Elements[:len(l.Elements)-1]
+	return true
 }
 
 func (l *List) Reverse(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("reverse", args); err != nil {
 		return nil, err
 	}
+	defer l.unlock(l.lock())
 	for i, j := 0, len(l.Elements)-1; i < j; i, j = i+1, j-1 {
 		l.Elements[i], l.Elements[j] = l.Elements[j], l.Elements[i]
 	}
@@ -217,6 +338,7 @@ func (l *List) Clear(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("clear", args); err != nil {
 		return nil, err
 	}
+	defer l.unlock(l.lock())
 	l.Elements = nil
 	return l, nil
 }
@@ -225,23 +347,25 @@ func (l *List) Copy(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("copy", args); err != nil {
 		return nil, err
 	}
-	elements := append([]Object(nil), l.Elements...)
+	elements := append([]Object(nil), l.Snapshot()...)
 	return &List{Elements: elements}, nil
 }
 
 func (l *List) TypeName() string { return "List" }
 
 func (l *List) String() string {
-	elements := make([]string, len(l.Elements))
-	for i, elem := range l.Elements {
+	snapshot := l.Snapshot()
+	elements := make([]string, len(snapshot))
+	for i, elem := range snapshot {
 		elements[i] = literal(elem)
 	}
 	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
 }
 
 func (l *List) ToString() (string, error) {
-	elements := make([]string, len(l.Elements))
-	for i, elem := range l.Elements {
+	snapshot := l.Snapshot()
+	elements := make([]string, len(snapshot))
+	for i, elem := range snapshot {
 		s, err := literalString(elem)
 		if err != nil {
 			return "", err
@@ -251,15 +375,27 @@ func (l *List) ToString() (string, error) {
 	return fmt.Sprintf("[%s]", strings.Join(elements, ", ")), nil
 }
 
-func (l *List) ToBool() (bool, error) { return len(l.Elements) > 0, nil }
+func (l *List) ToBool() (bool, error) {
+	defer l.runlock(l.rlock())
+	return len(l.Elements) > 0, nil
+}
 
 func (l *List) Equals(other Object) (bool, error) {
 	v, ok := other.(*List)
-	if !ok || len(l.Elements) != len(v.Elements) {
+	if !ok {
 		return false, nil
 	}
-	for i, elem := range l.Elements {
-		eq, err := Equals(elem, v.Elements[i])
+	// A list equals itself, which also ends the comparison of a list that
+	// holds itself.
+	if v == l {
+		return true, nil
+	}
+	mine, theirs := l.Snapshot(), v.Snapshot()
+	if len(mine) != len(theirs) {
+		return false, nil
+	}
+	for i, elem := range mine {
+		eq, err := Equals(elem, theirs[i])
 		if err != nil || !eq {
 			return false, err
 		}
@@ -274,9 +410,10 @@ func (l *List) Compare(other Object) (int, error) {
 func (l *List) Add(other Object) (Object, error) {
 	switch v := other.(type) {
 	case *List:
-		newElements := make([]Object, len(l.Elements)+len(v.Elements))
-		copy(newElements, l.Elements)
-		copy(newElements[len(l.Elements):], v.Elements)
+		mine, theirs := l.Snapshot(), v.Snapshot()
+		newElements := make([]Object, len(mine)+len(theirs))
+		copy(newElements, mine)
+		copy(newElements[len(mine):], theirs)
 		return &List{Elements: newElements}, nil
 	default:
 		return nil, NewTypeError("cannot add List and %s", other.TypeName())
@@ -293,11 +430,14 @@ func (l *List) Multiply(other Object) (Object, error) {
 		if int64(v) < 0 {
 			return nil, NewValueError("cannot multiply List by negative number")
 		}
-		newElements := make([]Object, len(l.Elements)*int(v))
+		elements := l.Snapshot()
+		newElements := make([]Object, len(elements)*int(v))
 		for i := 0; i < int(v); i++ {
-			copy(newElements[i*len(l.Elements):], l.Elements)
+			copy(newElements[i*len(elements):], elements)
 		}
 		return &List{Elements: newElements}, nil
+	case *BigInt:
+		return nil, NewValueError("cannot multiply List by %s", v)
 	default:
 		return nil, NewTypeError("cannot multiply List and %s", other.TypeName())
 	}
@@ -306,7 +446,7 @@ func (l *List) Multiply(other Object) (Object, error) {
 // RMultiply repeats the list when it is the right operand, so `2 * [1, 2]`
 // reads the same as `[1, 2] * 2`.
 func (l *List) RMultiply(left Object) (Object, bool, error) {
-	if _, ok := left.(Integer); !ok {
+	if !isInteger(left) {
 		return nil, false, nil
 	}
 	result, err := l.Multiply(left)
@@ -322,18 +462,23 @@ func (l *List) Modulo(other Object) (Object, error) {
 }
 
 func (l *List) Not() (Object, error) {
+	defer l.runlock(l.rlock())
 	return Bool(len(l.Elements) == 0), nil
 }
 
 func (l *List) Iter() ([]Object, error) {
-	return l.Elements, nil
+	return l.Snapshot(), nil
 }
 
 func (l *List) Index(index Object) (Object, error) {
+	if big, ok := index.(*BigInt); ok {
+		return nil, NewIndexError("list index out of range: %s", big)
+	}
 	idx, ok := index.(Integer)
 	if !ok {
 		return nil, NewTypeError("list index must be integer, got %s", index.TypeName())
 	}
+	defer l.runlock(l.rlock())
 	i := int(idx)
 	if i < 0 || i >= len(l.Elements) {
 		return nil, NewIndexError("list index out of range: %d", i)
@@ -342,10 +487,14 @@ func (l *List) Index(index Object) (Object, error) {
 }
 
 func (l *List) SetIndex(index Object, value Object) (bool, error) {
+	if big, ok := index.(*BigInt); ok {
+		return true, NewIndexError("list index out of range: %s", big)
+	}
 	idx, ok := index.(Integer)
 	if !ok {
 		return true, NewTypeError("list index must be integer, got %s", index.TypeName())
 	}
+	defer l.unlock(l.lock())
 	i := int(idx)
 	if i < 0 || i >= len(l.Elements) {
 		return true, NewIndexError("list index out of range: %d", i)
@@ -437,6 +586,10 @@ func (l *List) sortMethod(args CallArgs) (Object, error) {
 		return nil, err
 	}
 
+	// Sort a copy, not the list itself: key functions and comparisons may
+	// run Goblin code, which must not happen under the list's lock.
+	snapshot, version := l.versioned()
+	elements := append([]Object(nil), snapshot...)
 	var sortErr error
 	less := func(i, j int) bool {
 		if sortErr != nil {
@@ -444,16 +597,16 @@ func (l *List) sortMethod(args CallArgs) (Object, error) {
 		}
 		var a, b Object
 		if key != Nil {
-			a, sortErr = Call(key, CallArgs{Positional: []Object{l.Elements[i]}})
+			a, sortErr = Call(key, CallArgs{Positional: []Object{elements[i]}})
 			if sortErr != nil {
 				return false
 			}
-			b, sortErr = Call(key, CallArgs{Positional: []Object{l.Elements[j]}})
+			b, sortErr = Call(key, CallArgs{Positional: []Object{elements[j]}})
 			if sortErr != nil {
 				return false
 			}
 		} else {
-			a, b = l.Elements[i], l.Elements[j]
+			a, b = elements[i], elements[j]
 		}
 
 		res, err := Compare(a, b)
@@ -468,14 +621,19 @@ func (l *List) sortMethod(args CallArgs) (Object, error) {
 	}
 
 	if stable {
-		sort.SliceStable(l.Elements, less)
+		sort.SliceStable(elements, less)
 	} else {
-		sort.Slice(l.Elements, less)
+		sort.Slice(elements, less)
 	}
 
 	if sortErr != nil {
 		return nil, sortErr
 	}
+	defer l.unlock(l.lock())
+	if l.version != version+1 {
+		return nil, NewValueError("list modified during sort")
+	}
+	l.Elements = elements
 	return Nil, nil
 }
 
@@ -485,8 +643,9 @@ func (l *List) mapMethod(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	newElems := make([]Object, len(l.Elements))
-	for i, e := range l.Elements {
+	elements := l.Snapshot()
+	newElems := make([]Object, len(elements))
+	for i, e := range elements {
 		res, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -503,7 +662,7 @@ func (l *List) filterMethod(args CallArgs) (Object, error) {
 		return nil, err
 	}
 	newElems := []Object{}
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		res, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -527,18 +686,19 @@ func (l *List) reduceMethod(args CallArgs) (Object, error) {
 		return nil, err
 	}
 
+	elements := l.Snapshot()
 	acc := initial
 	start := 0
 	if !supplied {
-		if len(l.Elements) == 0 {
+		if len(elements) == 0 {
 			return nil, NewTypeError("reduce() of empty list with no initial value")
 		}
-		acc = l.Elements[0]
+		acc = elements[0]
 		start = 1
 	}
-	for i := start; i < len(l.Elements); i++ {
+	for i := start; i < len(elements); i++ {
 		var err error
-		acc, err = Call(fn, CallArgs{Positional: []Object{acc, l.Elements[i]}})
+		acc, err = Call(fn, CallArgs{Positional: []Object{acc, elements[i]}})
 		if err != nil {
 			return nil, err
 		}
@@ -552,7 +712,7 @@ func (l *List) eachMethod(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		_, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -567,7 +727,7 @@ func (l *List) findMethod(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		res, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -591,7 +751,7 @@ func (l *List) anyMethod(args CallArgs) (Object, error) {
 	}
 
 	if !supplied {
-		for _, e := range l.Elements {
+		for _, e := range l.Snapshot() {
 			b, err := e.ToBool()
 			if err != nil {
 				return nil, err
@@ -603,7 +763,7 @@ func (l *List) anyMethod(args CallArgs) (Object, error) {
 		return False, nil
 	}
 
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		res, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -627,7 +787,7 @@ func (l *List) allMethod(args CallArgs) (Object, error) {
 	}
 
 	if !supplied {
-		for _, e := range l.Elements {
+		for _, e := range l.Snapshot() {
 			b, err := e.ToBool()
 			if err != nil {
 				return nil, err
@@ -639,7 +799,7 @@ func (l *List) allMethod(args CallArgs) (Object, error) {
 		return True, nil
 	}
 
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		res, err := Call(fn, CallArgs{Positional: []Object{e}})
 		if err != nil {
 			return nil, err
@@ -660,7 +820,7 @@ func (l *List) sumMethod(args CallArgs) (Object, error) {
 		return nil, err
 	}
 	var res Object = Integer(0)
-	for _, e := range l.Elements {
+	for _, e := range l.Snapshot() {
 		var err error
 		res, err = res.Add(e)
 		if err != nil {
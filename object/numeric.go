@@ -1,11 +1,16 @@
 package object
 
-import "math"
+import (
+	"math"
+	"math/big"
+)
 
 // The numeric helpers below hold Goblin's rules for the Integer/Float pairs in
 // one place: an all-integer expression stays integer, a mixed one widens to
 // float, and division or modulo by either kind of zero raises
-// ZeroDivisionError. Both
+// ZeroDivisionError. Integer arithmetic that would overflow int64 is redone
+// through math/big and yields a BigInt; pairs involving a BigInt are handled
+// after the int64 and float64 fast paths have declined them. Both
 // the operand types' own methods and the package-level operators call them —
 // the operators would otherwise reach the very same code through an interface
 // call, which is measurable in arithmetic-heavy loops.
@@ -19,7 +24,9 @@ func numericAdd(a, b Object) (Object, bool) {
 	case Integer:
 		switch rhs := b.(type) {
 		case Integer:
-			return lhs + rhs, true
+			if sum := lhs + rhs; (lhs^sum)&(rhs^sum) >= 0 {
+				return sum, true
+			}
 		case Float:
 			return Float(lhs) + rhs, true
 		}
@@ -31,7 +38,7 @@ func numericAdd(a, b Object) (Object, bool) {
 			return lhs + Float(rhs), true
 		}
 	}
-	return nil, false
+	return bigArith(a, b, (*big.Int).Add, func(x, y Float) Float { return x + y })
 }
 
 func numericMinus(a, b Object) (Object, bool) {
@@ -39,7 +46,9 @@ func numericMinus(a, b Object) (Object, bool) {
 	case Integer:
 		switch rhs := b.(type) {
 		case Integer:
-			return lhs - rhs, true
+			if diff := lhs - rhs; (lhs^rhs)&(lhs^diff) >= 0 {
+				return diff, true
+			}
 		case Float:
 			return Float(lhs) - rhs, true
 		}
@@ -51,7 +60,7 @@ func numericMinus(a, b Object) (Object, bool) {
 			return lhs - Float(rhs), true
 		}
 	}
-	return nil, false
+	return bigArith(a, b, (*big.Int).Sub, func(x, y Float) Float { return x - y })
 }
 
 func numericMultiply(a, b Object) (Object, bool) {
@@ -59,7 +68,9 @@ func numericMultiply(a, b Object) (Object, bool) {
 	case Integer:
 		switch rhs := b.(type) {
 		case Integer:
-			return lhs * rhs, true
+			if product, ok := multiplyInt64(lhs, rhs); ok {
+				return product, true
+			}
 		case Float:
 			return Float(lhs) * rhs, true
 		}
@@ -71,7 +82,20 @@ func numericMultiply(a, b Object) (Object, bool) {
 			return lhs * Float(rhs), true
 		}
 	}
-	return nil, false
+	return bigArith(a, b, (*big.Int).Mul, func(x, y Float) Float { return x * y })
+}
+
+// multiplyInt64 multiplies two Integers and reports false if the product does
+// not fit in int64.
+func multiplyInt64(a, b Integer) (Integer, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
 }
 
 func numericDivide(a, b Object) (Object, bool, error) {
@@ -82,7 +106,10 @@ func numericDivide(a, b Object) (Object, bool, error) {
 			if rhs == 0 {
 				return nil, true, NewZeroDivisionError("division by zero")
 			}
-			return lhs / rhs, true, nil
+			// The one quotient that overflows: MinInt64 / -1.
+			if rhs != -1 || lhs != math.MinInt64 {
+				return lhs / rhs, true, nil
+			}
 		case Float:
 			if rhs == 0 {
 				return nil, true, NewZeroDivisionError("division by zero")
@@ -103,7 +130,7 @@ func numericDivide(a, b Object) (Object, bool, error) {
 			return lhs / Float(rhs), true, nil
 		}
 	}
-	return nil, false, nil
+	return bigDivide(a, b, "division by zero", (*big.Int).Quo, func(x, y Float) Float { return x / y })
 }
 
 func numericModulo(a, b Object) (Object, bool, error) {
@@ -135,7 +162,30 @@ func numericModulo(a, b Object) (Object, bool, error) {
 			return Float(math.Mod(float64(lhs), float64(rhs))), true, nil
 		}
 	}
-	return nil, false, nil
+	return bigDivide(a, b, "modulo by zero", (*big.Int).Rem, func(x, y Float) Float {
+		return Float(math.Mod(float64(x), float64(y)))
+	})
+}
+
+// bigDivide is bigArith for division and modulo: it raises ZeroDivisionError
+// for a zero divisor of either kind. Quo and Rem truncate towards zero, which
+// keeps BigInt results consistent with Go's int64 / and %.
+func bigDivide(a, b Object, zeroMessage string, intOp func(z, x, y *big.Int) *big.Int, floatOp func(x, y Float) Float) (Object, bool, error) {
+	if _, ok := bigFloatOperand(a); !ok {
+		return nil, false, nil
+	}
+	switch divisor := b.(type) {
+	case Integer:
+		if divisor == 0 {
+			return nil, true, NewZeroDivisionError(zeroMessage)
+		}
+	case Float:
+		if divisor == 0 {
+			return nil, true, NewZeroDivisionError(zeroMessage)
+		}
+	}
+	result, ok := bigArith(a, b, intOp, floatOp)
+	return result, ok, nil
 }
 
 func numericEquals(a, b Object) (bool, bool) {
@@ -156,6 +206,16 @@ func numericEquals(a, b Object) (bool, bool) {
 			return lhs == Float(rhs), true
 		}
 	}
+	// A BigInt is out of int64 range, so it never equals an Integer; against a
+	// Float it compares as float64, as an Integer does.
+	if isInteger(a) && isInteger(b) {
+		return bigOperand(a).Cmp(bigOperand(b)) == 0, true
+	}
+	fx, fxok := bigFloatOperand(a)
+	fy, fyok := bigFloatOperand(b)
+	if fxok && fyok {
+		return fx == fy, true
+	}
 	return false, false
 }
 
@@ -176,6 +236,14 @@ func numericCompare(a, b Object) (int, bool) {
 			return compareOrdered(lhs, Float(rhs)), true
 		}
 	}
+	if isInteger(a) && isInteger(b) {
+		return bigOperand(a).Cmp(bigOperand(b)), true
+	}
+	fx, fxok := bigFloatOperand(a)
+	fy, fyok := bigFloatOperand(b)
+	if fxok && fyok {
+		return compareOrdered(fx, fy), true
+	}
 	return 0, false
 }
 
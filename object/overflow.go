@@ -0,0 +1,69 @@
+package object
+
+import "math"
+
+// The Checked helpers are int64 arithmetic for the transpiler's native
+// integer code. Each one returns the wrapped result and sets *overflow when
+// the true result does not fit in int64. One flag is shared by a whole native
+// expression, so the generated code tests it once and re-evaluates the
+// expression on boxed Objects — where overflow promotes to a BigInt — only if
+// any step overflowed.
+
+func CheckedAdd(a, b int64, overflow *bool) int64 {
+	sum := a + b
+	if (a^sum)&(b^sum) < 0 {
+		*overflow = true
+	}
+	return sum
+}
+
+func CheckedSub(a, b int64, overflow *bool) int64 {
+	diff := a - b
+	if (a^b)&(a^diff) < 0 {
+		*overflow = true
+	}
+	return diff
+}
+
+func CheckedMul(a, b int64, overflow *bool) int64 {
+	product, ok := multiplyInt64(Integer(a), Integer(b))
+	if !ok {
+		*overflow = true
+	}
+	return int64(product)
+}
+
+// CheckedDiv expects a divisor the caller has already checked for zero.
+func CheckedDiv(a, b int64, overflow *bool) int64 {
+	if a == math.MinInt64 && b == -1 {
+		*overflow = true
+		return a
+	}
+	return a / b
+}
+
+func CheckedNeg(a int64, overflow *bool) int64 {
+	if a == math.MinInt64 {
+		*overflow = true
+	}
+	return -a
+}
+
+// SplitInteger and JoinInteger convert between an Integer or BigInt and the
+// pair a promoted native integer variable of the transpiler stores: an int64
+// holding the value while it fits, and a shadow that is nil then and holds
+// the BigInt otherwise.
+
+func SplitInteger(v Object) (int64, Object) {
+	if i, ok := v.(Integer); ok {
+		return int64(i), nil
+	}
+	return 0, v
+}
+
+func JoinInteger(v int64, big Object) Object {
+	if big != nil {
+		return big
+	}
+	return Integer(v)
+}
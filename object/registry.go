@@ -1,8 +1,85 @@
 package object
 
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
 // ModuleExecutor is the signature of a module's Execute function.
 type ModuleExecutor func() (Object, error)
 
+// ModuleInfo is a module registered with RegisterModule.
+type ModuleInfo struct {
+	Name string
+	// Package is the import path of the Go package declaring the executor.
+	Package string
+	// Func is the executor's name in Package, which programs the
+	// transpiler generates call.
+	Func    string
+	Execute ModuleExecutor
+}
+
+var (
+	modulesMu sync.RWMutex
+	modules   = map[string]ModuleInfo{}
+)
+
+// RegisterModule makes the module name importable in both backends: the
+// interpreter runs executor, and programs the transpiler generates import
+// goImportPath and call it there, so executor must be an exported top-level
+// function of that package. Call it from the init function of the package,
+// as the standard library does in extension/stdlib. It panics when name is
+// already registered or executor is not such a function, so a mistake stops
+// the program that makes it.
+func RegisterModule(name, goImportPath string, executor ModuleExecutor) {
+	if name == "" || executor == nil {
+		panic("object: RegisterModule needs a name and an executor")
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(executor).Pointer())
+	funcName, ok := strings.CutPrefix(fn.Name(), symbolPath(goImportPath)+".")
+	if !ok || !token.IsIdentifier(funcName) || !token.IsExported(funcName) {
+		panic(fmt.Sprintf("object: executor of module %s is %s, not an exported function of %s", name, fn.Name(), goImportPath))
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if _, dup := modules[name]; dup {
+		panic("object: module " + name + " registered twice")
+	}
+	modules[name] = ModuleInfo{Name: name, Package: goImportPath, Func: funcName, Execute: executor}
+}
+
+// symbolPath returns importPath as the names of its functions in the binary
+// spell it, with the dots of its last element escaped.
+func symbolPath(importPath string) string {
+	i := strings.LastIndex(importPath, "/") + 1
+	return importPath[:i] + strings.ReplaceAll(importPath[i:], ".", "%2e")
+}
+
+// LookupModule returns the module name was registered as.
+func LookupModule(name string) (ModuleInfo, bool) {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	info, ok := modules[name]
+	return info, ok
+}
+
+// ModuleNames lists the registered modules, sorted.
+func ModuleNames() []string {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Registry is a module registry that caches loaded modules by path.
 type Registry struct {
 	modules map[string]Object
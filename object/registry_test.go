@@ -102,3 +102,53 @@ func TestRegistry_Get(t *testing.T) {
 		t.Fatalf("expected %v, got %v", expected, mod)
 	}
 }
+
+func ExecuteRegistryTest() (Object, error) { return &Module{}, nil }
+
+func TestRegisterModule(t *testing.T) {
+	RegisterModule("registry_test", "github.com/aisk/goblin/object", ExecuteRegistryTest)
+	info, ok := LookupModule("registry_test")
+	if !ok || info.Package != "github.com/aisk/goblin/object" || info.Func != "ExecuteRegistryTest" || info.Execute == nil {
+		t.Fatalf("LookupModule = %+v, %v", info, ok)
+	}
+	found := false
+	for _, name := range ModuleNames() {
+		found = found || name == "registry_test"
+	}
+	if !found {
+		t.Errorf("ModuleNames() = %v, lacks registry_test", ModuleNames())
+	}
+	if _, ok := LookupModule("registry_missing"); ok {
+		t.Error("LookupModule found an unregistered module")
+	}
+}
+
+func TestRegisterModulePanics(t *testing.T) {
+	tests := []struct {
+		name, pkg string
+		executor  ModuleExecutor
+	}{
+		{"registry_test", "github.com/aisk/goblin/object", ExecuteRegistryTest}, // twice
+		{"registry_closure", "github.com/aisk/goblin/object", func() (Object, error) { return nil, nil }},
+		{"registry_elsewhere", "github.com/aisk/goblin/extension", ExecuteRegistryTest},
+	}
+	if _, ok := LookupModule("registry_test"); !ok {
+		RegisterModule("registry_test", "github.com/aisk/goblin/object", ExecuteRegistryTest)
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterModule(%q, %q) did not panic", tt.name, tt.pkg)
+				}
+			}()
+			RegisterModule(tt.name, tt.pkg, tt.executor)
+		}()
+	}
+}
+
+func TestSymbolPath(t *testing.T) {
+	if got := symbolPath("gopkg.in/yaml.v3"); got != "gopkg.in/yaml%2ev3" {
+		t.Errorf("symbolPath = %q", got)
+	}
+}
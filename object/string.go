@@ -208,7 +208,8 @@ func (s String) Replace(args CallArgs) (Object, error) {
 	return String(strings.Replace(string(s), string(old), string(newValue), int(count))), nil
 }
 
-func stringsList(values []string) Object {
+// StringList returns values as a List of Str.
+func StringList(values []string) Object {
 	elements := make([]Object, len(values))
 	for i, value := range values {
 		elements[i] = String(value)
@@ -224,7 +225,7 @@ func (s String) Split(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	return stringsList(strings.SplitN(string(s), string(sep), int(count))), nil
+	return StringList(strings.SplitN(string(s), string(sep), int(count))), nil
 }
 
 // SplitAfter combines strings.SplitAfter and strings.SplitAfterN.
@@ -235,14 +236,14 @@ func (s String) SplitAfter(args CallArgs) (Object, error) {
 	if err := ap.Finish(); err != nil {
 		return nil, err
 	}
-	return stringsList(strings.SplitAfterN(string(s), string(sep), int(count))), nil
+	return StringList(strings.SplitAfterN(string(s), string(sep), int(count))), nil
 }
 
 func (s String) Fields(args CallArgs) (Object, error) {
 	if err := RequireNoArgs("fields", args); err != nil {
 		return nil, err
 	}
-	return stringsList(strings.Fields(string(s))), nil
+	return StringList(strings.Fields(string(s))), nil
 }
 
 func (s String) Title(args CallArgs) (Object, error) {
@@ -376,6 +377,8 @@ func (s String) Add(other Object) (Object, error) {
 		return String(string(s) + string(v)), nil
 	case Integer:
 		return String(string(s) + v.String()), nil
+	case *BigInt:
+		return String(string(s) + v.String()), nil
 	case Bool:
 		return String(string(s) + v.String()), nil
 	default:
@@ -395,6 +398,11 @@ func (s String) Multiply(other Object) (Object, error) {
 			result += string(s)
 		}
 		return String(result), nil
+	case *BigInt:
+		if v.v.Sign() < 0 {
+			return String(""), nil
+		}
+		return nil, NewValueError("cannot multiply String by %s", v)
 	default:
 		return nil, NewTypeError("cannot multiply String and %s", other.TypeName())
 	}
@@ -403,7 +411,7 @@ func (s String) Multiply(other Object) (Object, error) {
 // RMultiply repeats the string when it is the right operand, so `3 * "ab"`
 // reads the same as `"ab" * 3`.
 func (s String) RMultiply(left Object) (Object, bool, error) {
-	if _, ok := left.(Integer); !ok {
+	if !isInteger(left) {
 		return nil, false, nil
 	}
 	result, err := s.Multiply(left)
@@ -0,0 +1,151 @@
+package object
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TaskGroup scopes a set of goblins to one function call. TaskGroup(body)
+// calls body with the group; goblins started through group.spawn() are all
+// joined before TaskGroup returns, so none outlives the call. The first
+// failure cancels the group and is re-raised once every goblin has finished,
+// with later failures attached as suppressed errors.
+//
+// Cancellation is cooperative, like Go's errgroup: a running goblin cannot be
+// stopped from outside, so it observes cancellation by checking
+// group.cancelled(). The group still waits for it to return.
+type TaskGroup struct {
+	OpaqueBase
+	cancelled atomic.Bool
+	mu        sync.Mutex
+	goblins   []*Goblin
+	failures  []error
+	joined    bool
+}
+
+var _ Object = &TaskGroup{}
+
+func (tg *TaskGroup) String() string { return "<task group>" }
+
+func (tg *TaskGroup) ToString() (string, error) { return tg.String(), nil }
+
+func (tg *TaskGroup) GetAttr(name string) (Object, error) {
+	switch name {
+	case "attributes":
+		return AttributesFunction(tg), nil
+	case "spawn":
+		return &Function{Name: "spawn", Fn: tg.Spawn}, nil
+	case "cancel":
+		return &Function{Name: "cancel", Fn: tg.Cancel}, nil
+	case "cancelled":
+		return &Function{Name: "cancelled", Fn: tg.Cancelled}, nil
+	case "constructor":
+		return TaskGroupConstructorFn, nil
+	default:
+		return nil, NewAttributeError("TaskGroup has no attribute '%s'", name)
+	}
+}
+
+func (tg *TaskGroup) Attributes() []string {
+	return []string{"attributes", "spawn", "cancel", "cancelled", "constructor"}
+}
+
+// Spawn starts fn in a goblin owned by the group and returns its handle.
+// The handle can be waited on as usual; the group records its failure either
+// way. Spawning into a group that has already been joined raises ValueError.
+func (tg *TaskGroup) Spawn(args CallArgs) (Object, error) {
+	p := NewArgParser("spawn", args)
+	fn := p.Func("fn")
+	rest := p.Rest()
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	if tg.joined {
+		return nil, NewValueError("spawn() on a TaskGroup that has already been joined")
+	}
+	if err := fn.CheckGoblin(); err != nil {
+		return nil, err
+	}
+	g := launchGoblin(func() (Object, error) {
+		return fn.Call(CallArgs{Positional: rest})
+	}, tg.finish)
+	tg.goblins = append(tg.goblins, g)
+	return g, nil
+}
+
+// Cancel marks the group cancelled without recording a failure.
+func (tg *TaskGroup) Cancel(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("cancel", args); err != nil {
+		return nil, err
+	}
+	tg.cancelled.Store(true)
+	return Nil, nil
+}
+
+// Cancelled reports whether a goblin in the group has failed or cancel() was
+// called. Long-running goblins should poll it and return early.
+func (tg *TaskGroup) Cancelled(args CallArgs) (Object, error) {
+	if err := RequireNoArgs("cancelled", args); err != nil {
+		return nil, err
+	}
+	return Bool(tg.cancelled.Load()), nil
+}
+
+// finish records a finished goblin's failure and cancels the group.
+func (tg *TaskGroup) finish(g *Goblin) {
+	if g.err == nil {
+		return
+	}
+	tg.cancelled.Store(true)
+	tg.mu.Lock()
+	tg.failures = append(tg.failures, g.err)
+	tg.mu.Unlock()
+}
+
+// join waits for every goblin, including ones spawned by other goblins while
+// the group is being joined, then closes the group to further spawns.
+func (tg *TaskGroup) join() {
+	for i := 0; ; i++ {
+		tg.mu.Lock()
+		if i == len(tg.goblins) {
+			tg.joined = true
+			tg.mu.Unlock()
+			return
+		}
+		g := tg.goblins[i]
+		tg.mu.Unlock()
+		<-g.done
+	}
+}
+
+var TaskGroupConstructorFn = &Function{Name: "TaskGroup", Fn: TaskGroupConstructor}
+
+// TaskGroupConstructor calls body with a new group, joins the group and
+// returns body's result. If body raises, the group is cancelled and body's
+// error is raised once the goblins have finished, with their failures
+// suppressed; otherwise the first goblin failure is raised.
+func TaskGroupConstructor(args CallArgs) (Object, error) {
+	p := NewArgParser("TaskGroup", args)
+	body := p.Func("body")
+	if err := p.Finish(); err != nil {
+		return nil, err
+	}
+	tg := &TaskGroup{OpaqueBase: MakeOpaqueBase("TaskGroup")}
+	result, err := body.Call(CallArgs{Positional: Args{tg}})
+	if err != nil {
+		tg.cancelled.Store(true)
+	}
+	tg.join()
+	if err != nil {
+		return nil, WithSuppressed(err, tg.failures)
+	}
+	if len(tg.failures) > 0 {
+		return nil, WithSuppressed(tg.failures[0], tg.failures[1:])
+	}
+	if result == nil {
+		return Nil, nil
+	}
+	return result, nil
+}
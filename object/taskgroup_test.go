@@ -0,0 +1,125 @@
+package object
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTaskGroup calls TaskGroup with a Go body.
+func runTaskGroup(body func(*TaskGroup) (Object, error)) (Object, error) {
+	return TaskGroupConstructor(CallArgs{Positional: Args{&Function{Name: "body", Fn: func(args CallArgs) (Object, error) {
+		return body(args.Positional[0].(*TaskGroup))
+	}}}})
+}
+
+func spawnIn(t *testing.T, tg *TaskGroup, fn func(CallArgs) (Object, error)) *Goblin {
+	t.Helper()
+	g, err := tg.Spawn(CallArgs{Positional: Args{&Function{Name: "fn", Fn: fn}}})
+	if err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	return g.(*Goblin)
+}
+
+func TestTaskGroupJoinsBeforeReturning(t *testing.T) {
+	var handles []*Goblin
+	got, err := runTaskGroup(func(tg *TaskGroup) (Object, error) {
+		for i := 0; i < 3; i++ {
+			handles = append(handles, spawnIn(t, tg, func(CallArgs) (Object, error) { return Nil, nil }))
+		}
+		return String("done"), nil
+	})
+	if err != nil || got != String("done") {
+		t.Fatalf("TaskGroup = %v, %v, want the body's result", got, err)
+	}
+	for i, g := range handles {
+		if done, _ := g.Done(CallArgs{}); done != True {
+			t.Fatalf("goblin %d still running after TaskGroup returned", i)
+		}
+	}
+}
+
+func TestTaskGroupFailureCancelsSiblings(t *testing.T) {
+	raised := NewValueError("boom")
+	_, err := runTaskGroup(func(tg *TaskGroup) (Object, error) {
+		spawnIn(t, tg, func(CallArgs) (Object, error) {
+			for !tg.cancelled.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			return Nil, nil
+		})
+		spawnIn(t, tg, func(CallArgs) (Object, error) { return nil, raised })
+		return Nil, nil
+	})
+	if err != raised {
+		t.Fatalf("TaskGroup error = %v, want the failure itself", err)
+	}
+}
+
+func TestTaskGroupSuppressesLaterFailures(t *testing.T) {
+	first := NewValueError("first")
+	second := NewKeyError("second")
+	_, err := runTaskGroup(func(tg *TaskGroup) (Object, error) {
+		spawnIn(t, tg, func(CallArgs) (Object, error) {
+			for !tg.cancelled.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			return nil, second
+		})
+		spawnIn(t, tg, func(CallArgs) (Object, error) { return nil, first })
+		return Nil, nil
+	})
+	if !errors.Is(err, first) {
+		t.Fatalf("TaskGroup error = %v, want the first failure", err)
+	}
+	e := err.(*Error)
+	if len(e.Suppressed) != 1 || e.Suppressed[0] != second {
+		t.Fatalf("suppressed = %v, want [second]", e.Suppressed)
+	}
+}
+
+func TestTaskGroupBodyErrorWins(t *testing.T) {
+	bodyErr := NewValueError("body")
+	goblinErr := NewKeyError("goblin")
+	_, err := runTaskGroup(func(tg *TaskGroup) (Object, error) {
+		spawnIn(t, tg, func(CallArgs) (Object, error) { return nil, goblinErr })
+		return nil, bodyErr
+	})
+	if !errors.Is(err, bodyErr) {
+		t.Fatalf("TaskGroup error = %v, want the body's error", err)
+	}
+	if e := err.(*Error); len(e.Suppressed) != 1 || e.Suppressed[0] != goblinErr {
+		t.Fatalf("suppressed = %v, want the goblin's error", e.Suppressed)
+	}
+}
+
+func TestTaskGroupRejectsSpawnAfterJoin(t *testing.T) {
+	var group *TaskGroup
+	if _, err := runTaskGroup(func(tg *TaskGroup) (Object, error) {
+		group = tg
+		return Nil, nil
+	}); err != nil {
+		t.Fatalf("TaskGroup: %v", err)
+	}
+	fn := &Function{Name: "fn", Fn: func(CallArgs) (Object, error) { return Nil, nil }}
+	if _, err := group.Spawn(CallArgs{Positional: Args{fn}}); !errors.Is(err, ValueError) {
+		t.Fatalf("spawn after join error = %v, want ValueError", err)
+	}
+}
+
+func TestWithSuppressedSurvivesFrames(t *testing.T) {
+	err := WithSuppressed(NewValueError("first"), []error{NewKeyError("second")})
+	err = WithFrame(err, Frame{Function: "f", File: "x.goblin", Line: 1})
+	e := err.(*Error)
+	if len(e.Suppressed) != 1 {
+		t.Fatalf("suppressed after WithFrame = %v, want one error", e.Suppressed)
+	}
+	if !errors.Is(err, ValueError) {
+		t.Fatal("WithSuppressed lost the error kind")
+	}
+	if want := "first\n  also failed: second"; !strings.HasSuffix(e.Traceback(), want) {
+		t.Fatalf("traceback = %q, want suffix %q", e.Traceback(), want)
+	}
+}
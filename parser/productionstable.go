@@ -272,23 +272,23 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `ExpressionStatement : StatementRoot "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2]) >>`,
+		String: `ExpressionStatement : StatementRoot "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2], X[3]) >>`,
 		Id:         "ExpressionStatement",
 		NTType:     6,
 		Index:      25,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewIndexExpression(X[0], X[2])
+			return ast.NewIndexExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
-		String: `ExpressionStatement : StatementRoot "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2]) >>`,
+		String: `ExpressionStatement : StatementRoot "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2], X[3]) >>`,
 		Id:         "ExpressionStatement",
 		NTType:     6,
 		Index:      26,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewCallExpression(X[0], X[2])
+			return ast.NewCallExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
@@ -302,23 +302,23 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `ExpressionStatement : ExpressionStatement "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2]) >>`,
+		String: `ExpressionStatement : ExpressionStatement "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2], X[3]) >>`,
 		Id:         "ExpressionStatement",
 		NTType:     6,
 		Index:      28,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewIndexExpression(X[0], X[2])
+			return ast.NewIndexExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
-		String: `ExpressionStatement : ExpressionStatement "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2]) >>`,
+		String: `ExpressionStatement : ExpressionStatement "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2], X[3]) >>`,
 		Id:         "ExpressionStatement",
 		NTType:     6,
 		Index:      29,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewCallExpression(X[0], X[2])
+			return ast.NewCallExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
@@ -582,23 +582,23 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `PostfixExpression : PostfixExpression "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2]) >>`,
+		String: `PostfixExpression : PostfixExpression "[" Expression "]"	<< ast.NewIndexExpression(X[0], X[2], X[3]) >>`,
 		Id:         "PostfixExpression",
 		NTType:     15,
 		Index:      56,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewIndexExpression(X[0], X[2])
+			return ast.NewIndexExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
-		String: `PostfixExpression : PostfixExpression "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2]) >>`,
+		String: `PostfixExpression : PostfixExpression "(" Arguments ")"	<< ast.NewCallExpression(X[0], X[2], X[3]) >>`,
 		Id:         "PostfixExpression",
 		NTType:     15,
 		Index:      57,
 		NumSymbols: 4,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewCallExpression(X[0], X[2])
+			return ast.NewCallExpression(X[0], X[2], X[3])
 		},
 	},
 	ProdTabEntry{
@@ -762,43 +762,43 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `TrueLiteral : "true"	<< ast.NewTrueLiteral() >>`,
+		String: `TrueLiteral : "true"	<< ast.NewTrueLiteral(X[0]) >>`,
 		Id:         "TrueLiteral",
 		NTType:     21,
 		Index:      74,
 		NumSymbols: 1,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewTrueLiteral()
+			return ast.NewTrueLiteral(X[0])
 		},
 	},
 	ProdTabEntry{
-		String: `FalseLiteral : "false"	<< ast.NewFalseLiteral() >>`,
+		String: `FalseLiteral : "false"	<< ast.NewFalseLiteral(X[0]) >>`,
 		Id:         "FalseLiteral",
 		NTType:     22,
 		Index:      75,
 		NumSymbols: 1,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewFalseLiteral()
+			return ast.NewFalseLiteral(X[0])
 		},
 	},
 	ProdTabEntry{
-		String: `NilLiteral : "nil"	<< ast.NewNilLiteral() >>`,
+		String: `NilLiteral : "nil"	<< ast.NewNilLiteral(X[0]) >>`,
 		Id:         "NilLiteral",
 		NTType:     23,
 		Index:      76,
 		NumSymbols: 1,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewNilLiteral()
+			return ast.NewNilLiteral(X[0])
 		},
 	},
 	ProdTabEntry{
-		String: `ListLiteral : "[" ListElements "]"	<< ast.NewListLiteral(X[1]) >>`,
+		String: `ListLiteral : "[" ListElements "]"	<< ast.NewListLiteral(X[0], X[1], X[2]) >>`,
 		Id:         "ListLiteral",
 		NTType:     24,
 		Index:      77,
 		NumSymbols: 3,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewListLiteral(X[1])
+			return ast.NewListLiteral(X[0], X[1], X[2])
 		},
 	},
 	ProdTabEntry{
@@ -842,13 +842,13 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `DictLiteral : "{" DictElements "}"	<< ast.NewDictLiteral(X[1]) >>`,
+		String: `DictLiteral : "{" DictElements "}"	<< ast.NewDictLiteral(X[0], X[1], X[2]) >>`,
 		Id:         "DictLiteral",
 		NTType:     27,
 		Index:      82,
 		NumSymbols: 3,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewDictLiteral(X[1])
+			return ast.NewDictLiteral(X[0], X[1], X[2])
 		},
 	},
 	ProdTabEntry{
@@ -1012,13 +1012,13 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `Block : "{" Statements "}"	<< X[1], nil >>`,
+		String: `Block : "{" Statements "}"	<< ast.NewBlock(X[1], X[2]) >>`,
 		Id:         "Block",
 		NTType:     36,
 		Index:      99,
 		NumSymbols: 3,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return X[1], nil
+			return ast.NewBlock(X[1], X[2])
 		},
 	},
 	ProdTabEntry{
@@ -1072,23 +1072,23 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `Break : "break"	<< ast.NewBreak() >>`,
+		String: `Break : "break"	<< ast.NewBreak(X[0]) >>`,
 		Id:         "Break",
 		NTType:     40,
 		Index:      105,
 		NumSymbols: 1,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewBreak()
+			return ast.NewBreak(X[0])
 		},
 	},
 	ProdTabEntry{
-		String: `Continue : "continue"	<< ast.NewContinue() >>`,
+		String: `Continue : "continue"	<< ast.NewContinue(X[0]) >>`,
 		Id:         "Continue",
 		NTType:     41,
 		Index:      106,
 		NumSymbols: 1,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewContinue()
+			return ast.NewContinue(X[0])
 		},
 	},
 	ProdTabEntry{
@@ -1282,13 +1282,13 @@ var productionsTable = ProdTab{
 		},
 	},
 	ProdTabEntry{
-		String: `TypeDefine : "type" id "(" TypeFields ")" "{" TypeMethods "}"	<< ast.NewTypeDefine(X[1], X[3], X[6]) >>`,
+		String: `TypeDefine : "type" id "(" TypeFields ")" "{" TypeMethods "}"	<< ast.NewTypeDefine(X[1], X[3], X[6], X[7]) >>`,
 		Id:         "TypeDefine",
 		NTType:     51,
 		Index:      126,
 		NumSymbols: 8,
 		ReduceFunc: func(X []Attrib, C interface{}) (Attrib, error) {
-			return ast.NewTypeDefine(X[1], X[3], X[6])
+			return ast.NewTypeDefine(X[1], X[3], X[6], X[7])
 		},
 	},
 	ProdTabEntry{
@@ -0,0 +1,196 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"time"
+)
+
+// The pprof format is a gzipped protocol buffer, described by profile.proto
+// in github.com/google/pprof. The encoder below writes the handful of fields
+// a Goblin profile needs, so the runtime does not depend on a protobuf
+// library.
+
+// Field numbers of the messages written.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+type protobuf struct {
+	buf []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protobuf) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64Field writes a varint field, omitting the zero default.
+func (b *protobuf) uint64Field(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64Field(field int, x int64) {
+	b.uint64Field(field, uint64(x))
+}
+
+func (b *protobuf) stringField(field int, s string) {
+	b.tag(field, 2)
+	b.varint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+// message writes a length-delimited field holding what fill writes.
+func (b *protobuf) message(field int, fill func(*protobuf)) {
+	var inner protobuf
+	fill(&inner)
+	b.tag(field, 2)
+	b.varint(uint64(len(inner.buf)))
+	b.buf = append(b.buf, inner.buf...)
+}
+
+func (b *protobuf) packedUint64s(field int, xs []uint64) {
+	b.message(field, func(inner *protobuf) {
+		for _, x := range xs {
+			inner.varint(x)
+		}
+	})
+}
+
+func (b *protobuf) packedInt64s(field int, xs []int64) {
+	b.message(field, func(inner *protobuf) {
+		for _, x := range xs {
+			inner.varint(uint64(x))
+		}
+	})
+}
+
+type stringTable struct {
+	index map[string]int64
+	list  []string
+}
+
+func (t *stringTable) id(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := int64(len(t.list))
+	t.index[s] = i
+	t.list = append(t.list, s)
+	return i
+}
+
+// write encodes the profile and writes it to p.w.
+func (p *Profiler) write() error {
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+
+	strs := &stringTable{index: map[string]int64{}}
+	strs.id("")
+	var b protobuf
+	valueType := func(field int, typ, unit string) {
+		b.message(field, func(m *protobuf) {
+			m.int64Field(valueTypeType, strs.id(typ))
+			m.int64Field(valueTypeUnit, strs.id(unit))
+		})
+	}
+	valueType(profileSampleType, "samples", "count")
+	valueType(profileSampleType, "cpu", "nanoseconds")
+	valueType(profileSampleType, "calls", "count")
+	valueType(profileSampleType, "time", "nanoseconds")
+
+	// Call counts and times were kept per function rather than per stack,
+	// which would cost a lookup on every call; each becomes one sample at
+	// the function's definition.
+	for _, fn := range p.funcList {
+		if calls := fn.calls.Load(); calls > 0 {
+			var values [valueCount]int64
+			values[valueCalls] = calls
+			values[valueTime] = fn.time.Load()
+			p.add([]locationKey{{fn.id, fn.startLine}}, values)
+		}
+	}
+	for _, s := range p.order {
+		s := s
+		b.message(profileSample, func(m *protobuf) {
+			m.packedUint64s(sampleLocationID, s.locations)
+			m.packedInt64s(sampleValue, s.values[:])
+		})
+	}
+	for i, loc := range p.locList {
+		id, loc := uint64(i+1), loc
+		b.message(profileLocation, func(m *protobuf) {
+			m.uint64Field(locationID, id)
+			m.message(locationLine, func(l *protobuf) {
+				l.uint64Field(lineFunctionID, loc.fn)
+				l.int64Field(lineLine, int64(loc.line))
+			})
+		})
+	}
+	for _, fn := range p.funcList {
+		fn := fn
+		name := fn.name
+		if fn.module != "" {
+			name = fn.module + "." + fn.name
+		}
+		b.message(profileFunction, func(m *protobuf) {
+			m.uint64Field(functionID, fn.id)
+			m.int64Field(functionName, strs.id(name))
+			m.int64Field(functionSystemName, strs.id(name))
+			m.int64Field(functionFilename, strs.id(fn.file))
+			m.int64Field(functionStartLine, int64(fn.startLine))
+		})
+	}
+	b.int64Field(profileTimeNanos, p.start.UnixNano())
+	b.int64Field(profileDurationNanos, time.Since(p.start).Nanoseconds())
+	valueType(profilePeriodType, "cpu", "nanoseconds")
+	b.int64Field(profilePeriod, Period.Nanoseconds())
+	b.int64Field(profileDefaultSampleType, strs.id("cpu"))
+	// The string table comes last so that every string above is in it.
+	for _, s := range strs.list {
+		b.stringField(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(p.w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,386 @@
+// Package profiler records where a Goblin program spends its time, for
+// `goblin run --cpuprofile` and for executables built with build-exe
+// --profile, which honour the GOBLIN_CPUPROFILE environment variable.
+//
+// Both backends keep a shadow call stack of Goblin frames, the same
+// object.Frame data tracebacks are built from: they call Enter and Exit around
+// every Goblin function and module body, and Line before every statement.
+// The interpreter calls the methods of the Profiler its program was given,
+// so programs in one process are profiled apart; a build-exe executable runs
+// one program, and calls the functions of the same names, which record into
+// the process-wide profile Start begins.
+// A sampler reads the stacks a hundred times a second. The result is a pprof
+// profile keyed by Goblin function and line, holding the samples and, for
+// every function, its call count and cumulative time, recorded against the
+// line it is defined on.
+package profiler
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aisk/goblin/internal/goid"
+	"github.com/aisk/goblin/object"
+)
+
+// EnvVar names the environment variable a build-exe executable reads: when
+// it is set, the program writes a CPU profile to the file it names.
+const EnvVar = "GOBLIN_CPUPROFILE"
+
+// Period is the interval between samples.
+const Period = 10 * time.Millisecond
+
+// active is the profile Start began, which the package-level hooks record
+// into.
+var active atomic.Pointer[Profiler]
+
+// Enabled reports whether the process-wide profile is being recorded.
+// Executables test it before calling Enter, Exit, or Line, so a program that
+// is not profiled pays only the check.
+func Enabled() bool {
+	return active.Load() != nil
+}
+
+type function struct {
+	id        uint64
+	module    string
+	name      string
+	file      string
+	startLine int
+
+	calls atomic.Int64
+	// time is the wall-clock time spent in the function, in nanoseconds,
+	// counting each outermost call only.
+	time atomic.Int64
+}
+
+type frame struct {
+	fn    *function
+	line  int
+	start time.Time
+}
+
+// stack is the shadow call stack of one goroutine.
+type stack struct {
+	mu     sync.Mutex
+	frames []frame
+}
+
+type functionKey struct {
+	module, name, file string
+	line               int
+}
+
+type locationKey struct {
+	fn   uint64
+	line int
+}
+
+// Value indices in a sample, in the order of the profile's sample types.
+const (
+	valueSamples = iota
+	valueCPU
+	valueCalls
+	valueTime
+	valueCount
+)
+
+type sample struct {
+	locations []uint64
+	values    [valueCount]int64
+}
+
+// Profiler is one recording in progress.
+type Profiler struct {
+	w     io.Writer
+	start time.Time
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	// stacksMu guards main and stacks. A program runs on main until it
+	// starts its first goblin; from then on each goroutine's stack is found
+	// by its ID, with mainID standing for the goroutine that was running.
+	stacksMu sync.Mutex
+	main     *stack
+	mainID   uint64
+	stacks   map[uint64]*stack
+
+	// dataMu guards everything below. It is never taken while holding a
+	// stack's lock.
+	dataMu    sync.Mutex
+	functions map[functionKey]*function
+	funcList  []*function
+	locations map[locationKey]uint64
+	locList   []locationKey
+	samples   map[string]*sample
+	order     []*sample
+}
+
+// New begins recording a profile, to be written to w by p.Stop. The
+// goroutine that calls it is taken to be the one the program runs on.
+func New(w io.Writer) *Profiler {
+	p := &Profiler{
+		w:         w,
+		start:     time.Now(),
+		done:      make(chan struct{}),
+		main:      &stack{},
+		mainID:    goid.Current(),
+		stacks:    map[uint64]*stack{},
+		functions: map[functionKey]*function{},
+		locations: map[locationKey]uint64{},
+		samples:   map[string]*sample{},
+	}
+	p.wg.Add(1)
+	go p.sampleLoop()
+	return p
+}
+
+// Stop ends the recording and writes the profile.
+func (p *Profiler) Stop() error {
+	close(p.done)
+	p.wg.Wait()
+	return p.write()
+}
+
+// Start begins recording the process-wide profile, to be written to w by
+// Stop. Only one can be recorded at a time; the hooks must not run while
+// Start is called.
+func Start(w io.Writer) {
+	active.Store(New(w))
+}
+
+// Stop ends the process-wide recording and writes the profile. It does
+// nothing when no profile is being recorded, so it is safe to call on every
+// way out of a program.
+func Stop() error {
+	p := active.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	return p.Stop()
+}
+
+// StartFromEnv starts a profile written to the file EnvVar names, if it is
+// set, and returns the function that stops it and closes the file. Executables
+// built with build-exe call it first thing in main.
+func StartFromEnv() (stop func() error, err error) {
+	path := os.Getenv(EnvVar)
+	if path == "" {
+		return func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	Start(f)
+	var once sync.Once
+	var stopErr error
+	return func() error {
+		once.Do(func() {
+			stopErr = Stop()
+			if err := f.Close(); stopErr == nil {
+				stopErr = err
+			}
+		})
+		return stopErr
+	}, nil
+}
+
+// Enter, Line and Exit record into the process-wide profile, as the methods
+// of the same names do into theirs.
+func Enter(f object.Frame) {
+	if p := active.Load(); p != nil {
+		p.Enter(f)
+	}
+}
+
+func Line(line int) {
+	if p := active.Load(); p != nil {
+		p.Line(line)
+	}
+}
+
+func Exit() {
+	if p := active.Load(); p != nil {
+		p.Exit()
+	}
+}
+
+// Enter pushes a frame for a Goblin function or module body about to run.
+// frame.Line is where the function is defined.
+func (p *Profiler) Enter(f object.Frame) {
+	fn := p.function(f)
+	s := p.stack()
+	s.mu.Lock()
+	s.frames = append(s.frames, frame{fn: fn, line: f.Line, start: time.Now()})
+	s.mu.Unlock()
+}
+
+// Line records that the innermost frame has reached a statement on line.
+func (p *Profiler) Line(line int) {
+	s := p.stack()
+	s.mu.Lock()
+	if n := len(s.frames); n > 0 {
+		s.frames[n-1].line = line
+	}
+	s.mu.Unlock()
+}
+
+// Exit pops the frame Enter pushed, counting the call. Its time counts
+// towards the function's cumulative time unless the function is already
+// running further up the stack, so recursion is not counted twice.
+func (p *Profiler) Exit() {
+	s := p.stack()
+	s.mu.Lock()
+	n := len(s.frames)
+	if n == 0 {
+		s.mu.Unlock()
+		return
+	}
+	top := s.frames[n-1]
+	recursive := false
+	for i := n - 2; i >= 0; i-- {
+		if s.frames[i].fn == top.fn {
+			recursive = true
+			break
+		}
+	}
+	s.frames = s.frames[:n-1]
+	s.mu.Unlock()
+
+	top.fn.calls.Add(1)
+	if !recursive {
+		top.fn.time.Add(time.Since(top.start).Nanoseconds())
+	}
+
+	if n == 1 && object.InConcurrentMode() {
+		// A goblin's stack empties when its function returns; forget it so
+		// finished goroutines do not pile up.
+		p.stacksMu.Lock()
+		if id := goid.Current(); id != p.mainID && p.stacks[id] == s {
+			delete(p.stacks, id)
+		}
+		p.stacksMu.Unlock()
+	}
+}
+
+func (p *Profiler) function(f object.Frame) *function {
+	key := functionKey{f.Module, f.Function, f.File, f.Line}
+	p.dataMu.Lock()
+	defer p.dataMu.Unlock()
+	fn := p.functions[key]
+	if fn == nil {
+		fn = &function{id: uint64(len(p.funcList) + 1), module: f.Module, name: f.Function, file: f.File, startLine: f.Line}
+		p.functions[key] = fn
+		p.funcList = append(p.funcList, fn)
+	}
+	return fn
+}
+
+// stack returns the calling goroutine's shadow stack. Until the program
+// starts a goblin every call runs on the main goroutine, and finding its
+// stack costs nothing.
+func (p *Profiler) stack() *stack {
+	if !object.InConcurrentMode() {
+		return p.main
+	}
+	id := goid.Current()
+	if id == p.mainID {
+		return p.main
+	}
+	p.stacksMu.Lock()
+	defer p.stacksMu.Unlock()
+	s := p.stacks[id]
+	if s == nil {
+		s = &stack{}
+		p.stacks[id] = s
+	}
+	return s
+}
+
+func (p *Profiler) sampleLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(Period)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.sample(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// sample records the innermost-first location list of every stack with
+// frames on it, weighted by the time since the last sample, which is longer
+// than Period when the sampler runs late. A goblin blocked on a channel is
+// sampled like a running one, so the samples measure time spent, not only
+// time on a CPU.
+func (p *Profiler) sample(elapsed time.Duration) {
+	p.stacksMu.Lock()
+	stacks := make([]*stack, 0, len(p.stacks)+1)
+	stacks = append(stacks, p.main)
+	for _, s := range p.stacks {
+		stacks = append(stacks, s)
+	}
+	p.stacksMu.Unlock()
+
+	var values [valueCount]int64
+	values[valueSamples] = 1
+	values[valueCPU] = elapsed.Nanoseconds()
+	for _, s := range stacks {
+		s.mu.Lock()
+		keys := make([]locationKey, len(s.frames))
+		for i, f := range s.frames {
+			keys[len(s.frames)-1-i] = locationKey{f.fn.id, f.line}
+		}
+		s.mu.Unlock()
+		if len(keys) == 0 {
+			continue
+		}
+		p.dataMu.Lock()
+		p.add(keys, values)
+		p.dataMu.Unlock()
+	}
+}
+
+// add accumulates values into the sample for a stack of locations. The
+// caller holds dataMu.
+func (p *Profiler) add(keys []locationKey, values [valueCount]int64) {
+	ids := make([]uint64, len(keys))
+	var key []byte
+	for i, k := range keys {
+		id := p.location(k)
+		ids[i] = id
+		key = strconv.AppendUint(key, id, 36)
+		key = append(key, ',')
+	}
+	s := p.samples[string(key)]
+	if s == nil {
+		s = &sample{locations: ids}
+		p.samples[string(key)] = s
+		p.order = append(p.order, s)
+	}
+	for i, v := range values {
+		s.values[i] += v
+	}
+}
+
+// location returns the ID of the location k, adding it if it is new. The
+// caller holds dataMu.
+func (p *Profiler) location(k locationKey) uint64 {
+	id, ok := p.locations[k]
+	if !ok {
+		p.locList = append(p.locList, k)
+		id = uint64(len(p.locList))
+		p.locations[k] = id
+	}
+	return id
+}
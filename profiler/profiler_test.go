@@ -0,0 +1,233 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/aisk/goblin/object"
+)
+
+// decoded is the part of a pprof profile the tests look at. Each sample is
+// its stack, innermost first, as "function:line" strings.
+type decoded struct {
+	sampleTypes []string
+	samples     map[string][]int64
+}
+
+func fields(t *testing.T, b []byte, fn func(field int, varint uint64, data []byte)) {
+	t.Helper()
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(b) == 0 {
+				t.Fatal("truncated varint")
+			}
+			c := b[0]
+			b = b[1:]
+			x |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				return x
+			}
+		}
+	}
+	for len(b) > 0 {
+		tag := varint()
+		switch tag & 7 {
+		case 0:
+			fn(int(tag>>3), varint(), nil)
+		case 2:
+			n := varint()
+			fn(int(tag>>3), 0, b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+}
+
+func packed(t *testing.T, varint uint64, data []byte) []uint64 {
+	if data == nil {
+		return []uint64{varint}
+	}
+	var xs []uint64
+	for len(data) > 0 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			c := data[0]
+			data = data[1:]
+			x |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				break
+			}
+		}
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+func decode(t *testing.T, gz []byte) decoded {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strs []string
+	var types [][2]uint64
+	var samples []struct{ locs, values []uint64 }
+	locations := map[uint64][2]uint64{}
+	functions := map[uint64]uint64{}
+	fields(t, raw, func(field int, _ uint64, data []byte) {
+		switch field {
+		case profileStringTable:
+			strs = append(strs, string(data))
+		case profileSampleType:
+			var vt [2]uint64
+			fields(t, data, func(f int, x uint64, _ []byte) { vt[f-1] = x })
+			types = append(types, vt)
+		case profileSample:
+			var s struct{ locs, values []uint64 }
+			fields(t, data, func(f int, x uint64, d []byte) {
+				if f == sampleLocationID {
+					s.locs = append(s.locs, packed(t, x, d)...)
+				} else {
+					s.values = append(s.values, packed(t, x, d)...)
+				}
+			})
+			samples = append(samples, s)
+		case profileLocation:
+			var id uint64
+			var line [2]uint64
+			fields(t, data, func(f int, x uint64, d []byte) {
+				switch f {
+				case locationID:
+					id = x
+				case locationLine:
+					fields(t, d, func(f int, x uint64, _ []byte) { line[f-1] = x })
+				}
+			})
+			locations[id] = line
+		case profileFunction:
+			var id, name uint64
+			fields(t, data, func(f int, x uint64, _ []byte) {
+				switch f {
+				case functionID:
+					id = x
+				case functionName:
+					name = x
+				}
+			})
+			functions[id] = name
+		}
+	})
+
+	d := decoded{samples: map[string][]int64{}}
+	for _, vt := range types {
+		d.sampleTypes = append(d.sampleTypes, strs[vt[0]]+"/"+strs[vt[1]])
+	}
+	for _, s := range samples {
+		key := ""
+		for _, id := range s.locs {
+			loc := locations[id]
+			key += fmt.Sprintf("%s:%d ", strs[functions[loc[0]]], loc[1])
+		}
+		values := make([]int64, len(s.values))
+		for i, v := range s.values {
+			values[i] = int64(v)
+		}
+		d.samples[key] = values
+	}
+	return d
+}
+
+func TestProfile(t *testing.T) {
+	var buf bytes.Buffer
+	Start(&buf)
+	if !Enabled() {
+		t.Fatal("Enabled() = false while recording")
+	}
+	Enter(object.Frame{Module: "main", Function: "<module>", File: "main.goblin", Line: 1})
+	Line(5)
+	Enter(object.Frame{Module: "main", Function: "f", File: "main.goblin", Line: 2})
+	Line(3)
+	Enter(object.Frame{Module: "main", Function: "f", File: "main.goblin", Line: 2})
+	active.Load().sample(Period)
+	Exit()
+	Exit()
+	Line(6)
+	Exit()
+	if err := Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled() {
+		t.Fatal("Enabled() = true after Stop")
+	}
+
+	d := decode(t, buf.Bytes())
+	wantTypes := []string{"samples/count", "cpu/nanoseconds", "calls/count", "time/nanoseconds"}
+	if !reflect.DeepEqual(d.sampleTypes, wantTypes) {
+		t.Fatalf("sample types = %v, want %v", d.sampleTypes, wantTypes)
+	}
+
+	// The sampler may have run on its own as well.
+	sampled := d.samples["main.f:2 main.f:3 main.<module>:5 "]
+	if len(sampled) != 4 || sampled[valueSamples] < 1 || sampled[valueCPU] < Period.Nanoseconds() {
+		t.Fatalf("sampled stack = %v in %v", sampled, d.samples)
+	}
+	f := d.samples["main.f:2 "]
+	if len(f) != 4 || f[valueCalls] != 2 || f[valueSamples] != 0 {
+		t.Fatalf("f calls = %v in %v", f, d.samples)
+	}
+	module := d.samples["main.<module>:1 "]
+	if len(module) != 4 || module[valueCalls] != 1 || module[valueTime] < f[valueTime] {
+		t.Fatalf("module calls = %v, f = %v", module, f)
+	}
+}
+
+func TestHooksWithoutProfile(t *testing.T) {
+	// The hooks are no-ops when nothing is being recorded.
+	Enter(object.Frame{Function: "f"})
+	Line(1)
+	Exit()
+	if err := Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProfilersAreIndependent(t *testing.T) {
+	var a, b bytes.Buffer
+	p, q := New(&a), New(&b)
+	if Enabled() {
+		t.Fatal("Enabled() = true with no process-wide profile")
+	}
+	p.Enter(object.Frame{Module: "main", Function: "f", File: "main.goblin", Line: 1})
+	q.Enter(object.Frame{Module: "main", Function: "g", File: "main.goblin", Line: 2})
+	q.Exit()
+	p.Exit()
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		buf        *bytes.Buffer
+		has, lacks string
+	}{{&a, "main.f:1 ", "main.g:2 "}, {&b, "main.g:2 ", "main.f:1 "}} {
+		d := decode(t, c.buf.Bytes())
+		if calls := d.samples[c.has]; len(calls) != 4 || calls[valueCalls] != 1 {
+			t.Errorf("%s calls = %v in %v", c.has, calls, d.samples)
+		}
+		if _, ok := d.samples[c.lacks]; ok {
+			t.Errorf("profile records %s from the other profile: %v", c.lacks, d.samples)
+		}
+	}
+}
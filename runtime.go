@@ -0,0 +1,21 @@
+package main
+
+import (
+	"embed"
+
+	"github.com/aisk/goblin/transpiler"
+)
+
+// runtimeFS is the source of the runtime that build-exe, transpile, and
+// test --backend transpiler copy into the Go modules they generate, so those
+// build with -mod=vendor and never fetch anything. _vendor holds the
+// third-party packages the runtime imports, copied from the module cache at
+// the versions go.mod requires; TestEmbeddedRuntimeIsComplete fails when a
+// change to the runtime needs a package that is not embedded.
+//
+//go:embed go.mod LICENSE object extension profiler internal/goid _vendor
+var runtimeFS embed.FS
+
+func init() {
+	transpiler.Runtime = runtimeFS
+}
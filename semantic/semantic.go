@@ -67,6 +67,10 @@ type checker struct {
 	currentScope *scope
 	loopDepth    int
 	funcDepth    int
+	// collect makes the checker record every diagnostic and carry on rather
+	// than stop at the first; see newError.
+	collect     bool
+	diagnostics []Diagnostic
 }
 
 // goReservedNames lists names the transpiler cannot emit as user identifiers.
@@ -113,11 +117,29 @@ func isTranspilerScratchName(name string) bool {
 	return i < len(name)-1 && i > 1 && name[i] == '_'
 }
 
+// CheckModule checks a module and returns the first error found, as an
+// *Error. The backends run it before executing anything.
 func CheckModule(mod *ast.Module) error {
 	c := &checker{
 		currentScope: newScope(nil),
 	}
+	return c.checkModule(mod)
+}
 
+// Check checks a module and returns every diagnostic, in the order the
+// checker found them. After an error in a statement or expression the
+// checker skips the rest of that node and resumes with the next one, so an
+// error is reported once rather than cascading.
+func Check(mod *ast.Module) []Diagnostic {
+	c := &checker{
+		currentScope: newScope(nil),
+		collect:      true,
+	}
+	c.checkModule(mod)
+	return c.diagnostics
+}
+
+func (c *checker) checkModule(mod *ast.Module) error {
 	// Module-level import, function, and type names are hoisted: they are
 	// visible across the whole module regardless of definition order, so
 	// mutually recursive functions work. Calling a function before the
@@ -139,7 +161,9 @@ func CheckModule(mod *ast.Module) error {
 			return err
 		}
 		if !c.currentScope.declare(name) {
-			return c.newError(pos, "duplicate declaration in same scope: %s", name)
+			if err := c.newError(pos, "duplicate declaration in same scope: %s", name); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -184,7 +208,10 @@ func (c *checker) checkStatement(stmt ast.Statement, isModuleScope bool) error {
 				return err
 			}
 			if _, ok := seenFields[field.Name]; ok {
-				return c.newError(field.Pos, "duplicate type field name: %s", field.Name)
+				if err := c.newError(field.Pos, "duplicate type field name: %s", field.Name); err != nil {
+					return err
+				}
+				continue
 			}
 			seenFields[field.Name] = struct{}{}
 
@@ -196,72 +223,15 @@ func (c *checker) checkStatement(stmt ast.Statement, isModuleScope bool) error {
 				continue
 			}
 			if seenDefault {
-				return c.newError(field.Pos, "required type field cannot appear after default field: %s", field.Name)
+				if err := c.newError(field.Pos, "required type field cannot appear after default field: %s", field.Name); err != nil {
+					return err
+				}
 			}
 		}
 
 		seenMethods := make(map[string]struct{}, len(v.Methods))
 		for _, method := range v.Methods {
-			if err := c.checkReservedName(method.Position(), method.Name); err != nil {
-				return err
-			}
-			if _, ok := seenMethods[method.Name]; ok {
-				return c.newError(method.Position(), "duplicate type method name: %s", method.Name)
-			}
-			if _, ok := seenFields[method.Name]; ok {
-				return c.newError(method.Position(), "type method name conflicts with field name: %s", method.Name)
-			}
-			seenMethods[method.Name] = struct{}{}
-
-			if len(method.Parameters) == 0 || method.Parameters[0].Name != "self" || method.Parameters[0].VarArgs || method.Parameters[0].KwArgs || method.Parameters[0].HasDefault() {
-				return c.newError(method.Position(), "type method must declare 'self' as the first parameter")
-			}
-
-			// Protocol methods (operators, comparison, conversion, iteration,
-			// indexing) have fixed arities and no variadic/keyword/default
-			// parameters.
-			if arity, ok := protocolArity[method.Name]; ok {
-				if len(method.Parameters) != arity {
-					return c.newError(method.Position(), "protocol method '%s' must declare exactly %d parameters including self, got %d", method.Name, arity, len(method.Parameters))
-				}
-				for _, param := range method.Parameters {
-					if param.VarArgs || param.KwArgs {
-						return c.newError(param.Pos, "protocol method '%s' cannot use variadic or keyword parameters", method.Name)
-					}
-					if param.HasDefault() {
-						return c.newError(param.Pos, "protocol method '%s' cannot declare default parameter values", method.Name)
-					}
-				}
-			}
-
-			// Default expressions are evaluated in the type's defining scope,
-			// where fields and self are not visible.
-			if err := c.checkParameterDefaults(method.Parameters); err != nil {
-				return err
-			}
-
-			if err := c.withScope(func() error {
-				c.funcDepth++
-				defer func() { c.funcDepth-- }()
-
-				if err := c.checkParameterOrder(method.Parameters); err != nil {
-					return err
-				}
-
-				// Fields are NOT in scope as bare identifiers inside methods;
-				// they must be accessed through self. Declaring only the
-				// parameters here keeps the checker aligned with both backends.
-				for _, param := range method.Parameters {
-					if err := c.checkReservedName(param.Pos, param.Name); err != nil {
-						return err
-					}
-					if !c.currentScope.declare(param.Name) {
-						return c.newError(param.Pos, "duplicate parameter name: %s", param.Name)
-					}
-				}
-
-				return c.checkStatements(method.Body, false)
-			}); err != nil {
+			if err := c.checkMethod(method, seenFields, seenMethods); err != nil {
 				return err
 			}
 		}
@@ -393,6 +363,70 @@ func (c *checker) checkStatement(stmt ast.Statement, isModuleScope bool) error {
 	}
 }
 
+// checkMethod checks one method of a type. An error skips the rest of the
+// method but not the type's other methods.
+func (c *checker) checkMethod(method *ast.FunctionDefine, seenFields, seenMethods map[string]struct{}) error {
+	if err := c.checkReservedName(method.Position(), method.Name); err != nil {
+		return err
+	}
+	if _, ok := seenMethods[method.Name]; ok {
+		return c.newError(method.Position(), "duplicate type method name: %s", method.Name)
+	}
+	if _, ok := seenFields[method.Name]; ok {
+		return c.newError(method.Position(), "type method name conflicts with field name: %s", method.Name)
+	}
+	seenMethods[method.Name] = struct{}{}
+
+	if len(method.Parameters) == 0 || method.Parameters[0].Name != "self" || method.Parameters[0].VarArgs || method.Parameters[0].KwArgs || method.Parameters[0].HasDefault() {
+		return c.newError(method.Position(), "type method must declare 'self' as the first parameter")
+	}
+
+	// Protocol methods (operators, comparison, conversion, iteration,
+	// indexing) have fixed arities and no variadic/keyword/default
+	// parameters.
+	if arity, ok := protocolArity[method.Name]; ok {
+		if len(method.Parameters) != arity {
+			return c.newError(method.Position(), "protocol method '%s' must declare exactly %d parameters including self, got %d", method.Name, arity, len(method.Parameters))
+		}
+		for _, param := range method.Parameters {
+			if param.VarArgs || param.KwArgs {
+				return c.newError(param.Pos, "protocol method '%s' cannot use variadic or keyword parameters", method.Name)
+			}
+			if param.HasDefault() {
+				return c.newError(param.Pos, "protocol method '%s' cannot declare default parameter values", method.Name)
+			}
+		}
+	}
+
+	// Default expressions are evaluated in the type's defining scope,
+	// where fields and self are not visible.
+	if err := c.checkParameterDefaults(method.Parameters); err != nil {
+		return err
+	}
+
+	return c.withScope(func() error {
+		c.funcDepth++
+		defer func() { c.funcDepth-- }()
+
+		if err := c.checkParameterOrder(method.Parameters); err != nil {
+			return err
+		}
+
+		// Fields are NOT in scope as bare identifiers inside methods;
+		// they must be accessed through self. Declaring only the
+		// parameters here keeps the checker aligned with both backends.
+		// checkParameterOrder has reported any duplicate names.
+		for _, param := range method.Parameters {
+			if err := c.checkReservedName(param.Pos, param.Name); err != nil {
+				return err
+			}
+			c.currentScope.declare(param.Name)
+		}
+
+		return c.checkStatements(method.Body, false)
+	})
+}
+
 // checkFunction validates a function's parameter list and body in a fresh
 // scope. It backs both named function definitions and anonymous function
 // literals.
@@ -410,13 +444,12 @@ func (c *checker) checkFunction(params []*ast.Parameter, body []ast.Statement) e
 			return err
 		}
 
+		// checkParameterOrder has reported any duplicate names.
 		for _, param := range params {
 			if err := c.checkReservedName(param.Pos, param.Name); err != nil {
 				return err
 			}
-			if !c.currentScope.declare(param.Name) {
-				return c.newError(param.Pos, "duplicate parameter name: %s", param.Name)
-			}
+			c.currentScope.declare(param.Name)
 		}
 
 		return c.checkStatements(body, false)
@@ -528,14 +561,21 @@ func (c *checker) checkExpression(expr ast.Expression) error {
 	}
 }
 
+// newError reports a diagnostic. Checking the current node stops either way,
+// since every caller returns what newError gives it: an *Error ends the whole
+// walk, while the nil it returns when collecting lets the walk resume at the
+// next sibling.
 func (c *checker) newError(pos token.Pos, format string, args ...any) error {
-	return &Error{
-		Diagnostic: Diagnostic{
-			Pos:     pos,
-			Kind:    "semantic",
-			Message: fmt.Sprintf(format, args...),
-		},
+	diag := Diagnostic{
+		Pos:     pos,
+		Kind:    "semantic",
+		Message: fmt.Sprintf(format, args...),
+	}
+	if c.collect {
+		c.diagnostics = append(c.diagnostics, diag)
+		return nil
 	}
+	return &Error{Diagnostic: diag}
 }
 
 func formatPos(pos token.Pos) string {
@@ -1,6 +1,7 @@
 package semantic
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -397,10 +398,48 @@ func TestCheckModule(t *testing.T) {
 			if tt.wantErr && tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
 				t.Fatalf("expected error containing %q, got %q", tt.errContains, err.Error())
 			}
+			// Check must lead with the error CheckModule stops at.
+			diags := Check(parseModule(t, tt.source))
+			if (len(diags) > 0) != tt.wantErr {
+				t.Fatalf("Check found %v", diags)
+			}
+			if tt.wantErr && diags[0] != err.(*Error).Diagnostic {
+				t.Fatalf("Check starts with %v, CheckModule returned %v", diags[0], err)
+			}
 		})
 	}
 }
 
+func TestCheckReportsEverything(t *testing.T) {
+	source := "print(a)\n" +
+		"var b = 1\n" +
+		"var b = 2\n" +
+		"func f(x, x) {\n" +
+		"  return y + z\n" +
+		"}\n" +
+		"type T(n, n) {\n" +
+		"  func m() {}\n" +
+		"  func k(self) { break }\n" +
+		"}\n"
+	var got []string
+	for _, diag := range Check(parseModule(t, source)) {
+		got = append(got, fmt.Sprintf("%d: %s", diag.Pos.Line, diag.Message))
+	}
+	want := []string{
+		"1: undefined identifier: a",
+		"3: duplicate declaration in same scope: b",
+		"4: duplicate parameter name: x",
+		"5: undefined identifier: y",
+		"5: undefined identifier: z",
+		"7: duplicate type field name: n",
+		"8: type method must declare 'self' as the first parameter",
+		"9: break used outside loop",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
 func parseModule(t *testing.T, source string) *ast.Module {
 	t.Helper()
 
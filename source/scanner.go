@@ -0,0 +1,120 @@
+package source
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aisk/goblin/lexer"
+	"github.com/aisk/goblin/token"
+)
+
+// Comment is one # comment. Text keeps the leading '#' but not the line
+// break that ends it.
+type Comment struct {
+	Text string
+	Pos  token.Pos
+}
+
+// Scanner is a comment-preserving front end for the parser. The generated
+// lexer discards comments, but everything between two tokens is whitespace or
+// comments, so the scanner recovers them from the gaps. It also records the
+// tokens it hands out, for tools that need the source as written.
+type Scanner struct {
+	lexer    *lexer.Lexer
+	src      []byte
+	end      token.Pos // just past the previous token
+	done     bool
+	Tokens   []*token.Token
+	Comments []Comment
+}
+
+// NewScanner wraps NewLexer, so the source gets the same trailing-newline
+// normalization.
+func NewScanner(src []byte) *Scanner {
+	l := NewLexer(src)
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		src = append(src[:len(src):len(src)], '\n')
+	}
+	return &Scanner{lexer: l, src: src, end: token.Pos{Line: 1, Column: 1}}
+}
+
+// NewScannerFile is NewScanner for a file, naming it in token positions.
+func NewScannerFile(path string) (*Scanner, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := NewScanner(src)
+	s.SetFilename(path)
+	return s, nil
+}
+
+// SetFilename names the source in the positions of tokens and comments
+// scanned from then on, and so in parse errors.
+func (s *Scanner) SetFilename(path string) {
+	s.lexer.Context = &lexer.SourceContext{Filepath: path}
+	s.end.Context = s.lexer.Context
+}
+
+// Scan implements the parser's Scanner interface.
+func (s *Scanner) Scan() *token.Token {
+	tok := s.lexer.Scan()
+	if s.done {
+		return tok
+	}
+	s.collect(tok.Pos.Offset)
+	if tok.Type == token.EOF {
+		s.done = true
+		return tok
+	}
+	s.Tokens = append(s.Tokens, tok)
+	s.end = advance(tok.Pos, tok.Lit)
+	return tok
+}
+
+// collect records the comments between the previous token and offset.
+func (s *Scanner) collect(offset int) {
+	if offset > len(s.src) {
+		offset = len(s.src)
+	}
+	pos := s.end
+	for pos.Offset < offset {
+		if s.src[pos.Offset] != '#' {
+			pos = s.step(pos)
+			continue
+		}
+		start := pos
+		for pos.Offset < offset && s.src[pos.Offset] != '\n' {
+			pos = s.step(pos)
+		}
+		text := strings.TrimRight(string(s.src[start.Offset:pos.Offset]), "\r")
+		s.Comments = append(s.Comments, Comment{Text: text, Pos: start})
+	}
+}
+
+// step moves pos past the rune at it.
+func (s *Scanner) step(pos token.Pos) token.Pos {
+	_, size := utf8.DecodeRune(s.src[pos.Offset:])
+	return advance(pos, s.src[pos.Offset:pos.Offset+size])
+}
+
+// advance moves pos past text, counting lines and columns the way the lexer
+// does.
+func advance(pos token.Pos, text []byte) token.Pos {
+	pos.Offset += len(text)
+	for _, r := range string(text) {
+		switch r {
+		case '\n':
+			pos.Line++
+			pos.Column = 1
+		case '\r':
+			pos.Column = 1
+		case '\t':
+			pos.Column += 4
+		default:
+			pos.Column++
+		}
+	}
+	return pos
+}
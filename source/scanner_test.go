@@ -0,0 +1,35 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aisk/goblin/parser"
+)
+
+func TestScannerKeepsComments(t *testing.T) {
+	src := "# first\nvar s = \"# not a comment\"  # après\n\tprint(s) # last"
+	s := NewScanner([]byte(src))
+	if _, err := parser.NewParser().Parse(s); err != nil {
+		t.Fatal(err)
+	}
+	type comment struct {
+		text         string
+		line, column int
+	}
+	var got []comment
+	for _, c := range s.Comments {
+		got = append(got, comment{c.Text, c.Pos.Line, c.Pos.Column})
+	}
+	want := []comment{
+		{"# first", 1, 1},
+		{"# après", 2, 28},
+		{"# last", 3, 14},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("comments = %v, want %v", got, want)
+	}
+	if len(s.Tokens) != 8 {
+		t.Fatalf("got %d tokens, want 8", len(s.Tokens))
+	}
+}
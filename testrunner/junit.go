@@ -0,0 +1,96 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes results as a JUnit XML report, one test suite per file,
+// for CI systems to display. A failure's message attribute is the last line
+// of its traceback, which names the error; the element holds all of it.
+func WriteJUnit(w io.Writer, results []Result) error {
+	report := junitSuites{}
+	var total time.Duration
+	index := map[string]int{}
+	suiteTimes := map[string]time.Duration{}
+	for _, result := range results {
+		i, ok := index[result.File]
+		if !ok {
+			i = len(report.Suites)
+			index[result.File] = i
+			report.Suites = append(report.Suites, junitSuite{Name: result.File})
+		}
+		suite := &report.Suites[i]
+		c := junitCase{Name: result.Name, Classname: result.File, Time: junitTime(result.Duration)}
+		switch result.Status {
+		case Fail:
+			lines := strings.Split(strings.TrimRight(result.Message, "\n"), "\n")
+			c.Failure = &junitMessage{Message: lines[len(lines)-1], Text: result.Message}
+			suite.Failures++
+			report.Failures++
+		case Skip:
+			c.Skipped = &junitMessage{Message: result.Message}
+			suite.Skipped++
+			report.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		report.Tests++
+		suiteTimes[result.File] += result.Duration
+		total += result.Duration
+	}
+	for i := range report.Suites {
+		report.Suites[i].Time = junitTime(suiteTimes[report.Suites[i].Name])
+	}
+	report.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
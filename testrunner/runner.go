@@ -0,0 +1,285 @@
+// Package testrunner runs the tests behind `goblin test`: every module-level
+// `func test_*()` in the `*_test.goblin` files it is given, each in a fresh
+// copy of its module, under the interpreter or as a transpiled binary.
+package testrunner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/extension"
+	"github.com/aisk/goblin/interpreter"
+	"github.com/aisk/goblin/object"
+	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/semantic"
+	"github.com/aisk/goblin/source"
+	"github.com/aisk/goblin/transpiler"
+)
+
+type Backend string
+
+const (
+	Interpreter Backend = "interpreter"
+	Transpiler  Backend = "transpiler"
+)
+
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// ModuleTest names the result of a file that failed before any of its tests
+// could run: one that does not parse, fails the semantic check, or does not
+// build.
+const ModuleTest = "<module>"
+
+type Result struct {
+	File     string
+	Name     string
+	Status   Status
+	Duration time.Duration
+	// Message is the traceback of a failure or the reason for a skip.
+	Message string
+}
+
+type Options struct {
+	// Run selects the tests whose names it matches; nil runs them all.
+	Run *regexp.Regexp
+	// Parallel is how many tests run at once; values below 1 mean 1.
+	Parallel int
+	// Backend defaults to Interpreter.
+	Backend Backend
+	// Stdout receives what transpiled tests print. Interpreted tests print
+	// to the process's standard output, like `goblin run`. Nil means
+	// os.Stdout.
+	Stdout io.Writer
+}
+
+// IsTestFile reports whether path names a test file.
+func IsTestFile(path string) bool {
+	return strings.HasSuffix(filepath.Base(path), "_test.goblin")
+}
+
+// TestNames lists a module's tests in source order: its module-level
+// functions whose names start with "test_".
+func TestNames(mod *ast.Module) []string {
+	var names []string
+	for _, stmt := range mod.Body {
+		if fn, ok := stmt.(*ast.FunctionDefine); ok && strings.HasPrefix(fn.Name, "test_") {
+			names = append(names, fn.Name)
+		}
+	}
+	return names
+}
+
+// job is one test to run, or a file-level failure to report as is.
+type job struct {
+	file   string
+	name   string
+	mod    *ast.Module
+	binary string
+	result *Result
+}
+
+// Run runs the tests in files and returns their results in file and source
+// order. report, if not nil, is called with each result in that same order
+// as soon as it and every result before it are known.
+func Run(files []string, opts Options, report func(Result)) []Result {
+	if opts.Parallel < 1 {
+		opts.Parallel = 1
+	}
+	if opts.Backend == "" {
+		opts.Backend = Interpreter
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+
+	var jobs []job
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	for _, file := range files {
+		start := time.Now()
+		mod, err := load(file)
+		var names []string
+		if err == nil {
+			for _, name := range TestNames(mod) {
+				if opts.Run == nil || opts.Run.MatchString(name) {
+					names = append(names, name)
+				}
+			}
+		}
+		var binary string
+		if err == nil && len(names) > 0 && opts.Backend == Transpiler {
+			var cleanup func()
+			binary, cleanup, err = build(mod, file, TestNames(mod))
+			if cleanup != nil {
+				cleanups = append(cleanups, cleanup)
+			}
+		}
+		if err != nil {
+			jobs = append(jobs, job{file: file, result: &Result{
+				File: file, Name: ModuleTest, Status: Fail, Duration: time.Since(start), Message: errorText(err),
+			}})
+			continue
+		}
+		for _, name := range names {
+			jobs = append(jobs, job{file: file, name: name, mod: mod, binary: binary})
+		}
+	}
+
+	results := make([]Result, len(jobs))
+	done := make([]bool, len(jobs))
+	var mu sync.Mutex
+	next := 0
+	finish := func(i int, result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[i], done[i] = result, true
+		for next < len(jobs) && done[next] {
+			if report != nil {
+				report(results[next])
+			}
+			next++
+		}
+	}
+
+	sem := make(chan struct{}, opts.Parallel)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		if j.result != nil {
+			finish(i, *j.result)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			finish(i, runTest(j, opts))
+		}(i, j)
+	}
+	wg.Wait()
+	return results
+}
+
+func load(file string) (*ast.Module, error) {
+	l, err := source.NewLexerFile(file)
+	if err != nil {
+		return nil, err
+	}
+	st, err := parser.NewParser().Parse(l)
+	if err != nil {
+		return nil, err
+	}
+	mod, ok := st.(*ast.Module)
+	if !ok {
+		return nil, fmt.Errorf("internal error: unexpected AST type")
+	}
+	if err := semantic.CheckModule(mod); err != nil {
+		return nil, err
+	}
+	return mod, nil
+}
+
+func runTest(j job, opts Options) Result {
+	start := time.Now()
+	var err error
+	if j.binary != "" {
+		err = runBinary(j, opts.Stdout)
+	} else {
+		// A registry of its own gives the test fresh modules, and the
+		// preloaded testing module one whose temporary directories go away
+		// with the test.
+		reg := object.NewRegistry()
+		t := extension.NewTesting()
+		if _, err = reg.Load("testing", t.Execute); err == nil {
+			err = interpreter.RunFunction(j.mod, j.file, j.name, reg)
+		}
+		if cleanupErr := t.Cleanup(); err == nil {
+			err = cleanupErr
+		}
+	}
+	result := Result{File: j.file, Name: j.name, Status: Pass, Duration: time.Since(start)}
+	var skip *skipError
+	switch {
+	case err == nil:
+	case errors.As(err, &skip):
+		result.Status, result.Message = Skip, skip.reason
+	case extension.Skipped(err):
+		result.Status, result.Message = Skip, err.Error()
+	default:
+		result.Status, result.Message = Fail, errorText(err)
+	}
+	return result
+}
+
+// errorText renders an error with its traceback when it has one.
+func errorText(err error) string {
+	var e *object.Error
+	if errors.As(err, &e) {
+		return e.Traceback()
+	}
+	return err.Error()
+}
+
+// skipError carries the reason a test binary gave for skipping.
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return e.reason }
+
+// build transpiles a test file into a binary that runs one of its tests per
+// invocation. cleanup removes the build directory.
+func build(mod *ast.Module, file string, tests []string) (binary string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "goblin-test-build-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := transpiler.TranspileTestToDir(mod, file, dir, tests); err != nil {
+		return "", cleanup, err
+	}
+	binary = filepath.Join(dir, "test.bin")
+	var stderr bytes.Buffer
+	goBuild := exec.Command("go", "build", transpiler.ModFlag(dir), "-o", binary, ".")
+	goBuild.Dir = dir
+	goBuild.Stderr = &stderr
+	if err := goBuild.Run(); err != nil {
+		return "", cleanup, fmt.Errorf("go build failed: %v\n%s", err, stderr.String())
+	}
+	return binary, cleanup, nil
+}
+
+func runBinary(j job, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(j.binary, j.name)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		text := strings.TrimSuffix(stderr.String(), "\n")
+		if exit.ExitCode() == transpiler.TestSkipExitCode {
+			return &skipError{reason: text}
+		}
+		return errors.New(text)
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package testrunner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const sampleTests = `import "testing"
+import "./helper"
+
+var calls = []
+
+func test_pass() {
+    calls.push(1)
+    testing.assert_equal(helper.double(2), 4)
+    testing.assert_equal(calls, [1])
+}
+
+func test_fail() {
+    testing.assert_equal(helper.double(2), 5, "doubling")
+}
+
+func test_skip() {
+    testing.skip("not yet")
+}
+
+func test_isolated() {
+    calls.push(2)
+    testing.assert_equal(calls, [2])
+}
+
+func helper_not_a_test() {
+    testing.fail()
+}
+`
+
+func writeSample(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"sample_test.goblin": sampleTests,
+		"helper.goblin":      "func double(x) {\n    return x * 2\n}\nexport double\n",
+		"broken_test.goblin": "func test_x( {\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+type summary struct {
+	Name   string
+	Status Status
+}
+
+func summarize(results []Result) []summary {
+	var out []summary
+	for _, result := range results {
+		out = append(out, summary{result.Name, result.Status})
+	}
+	return out
+}
+
+func checkSample(t *testing.T, dir string, opts Options) {
+	t.Helper()
+	files := []string{filepath.Join(dir, "broken_test.goblin"), filepath.Join(dir, "sample_test.goblin")}
+	var reported []Result
+	results := Run(files, opts, func(result Result) { reported = append(reported, result) })
+	want := []summary{
+		{ModuleTest, Fail},
+		{"test_pass", Pass},
+		{"test_fail", Fail},
+		{"test_skip", Skip},
+		{"test_isolated", Pass},
+	}
+	if got := summarize(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %+v, want %+v\n%+v", got, want, results)
+	}
+	if !reflect.DeepEqual(reported, results) {
+		t.Errorf("reported %+v, want the results in order", summarize(reported))
+	}
+	if msg := results[2].Message; !strings.Contains(msg, "at test_fail") || !strings.HasSuffix(msg, "doubling: expected 5, got 4") {
+		t.Errorf("failure message = %q, want a traceback ending in the assertion", msg)
+	}
+	if results[3].Message != "not yet" {
+		t.Errorf("skip message = %q, want the reason", results[3].Message)
+	}
+}
+
+func TestRunInterpreter(t *testing.T) {
+	dir := writeSample(t)
+	checkSample(t, dir, Options{})
+	checkSample(t, dir, Options{Parallel: 4})
+
+	results := Run([]string{filepath.Join(dir, "sample_test.goblin")}, Options{Run: regexp.MustCompile("skip|isolated")}, nil)
+	if got, want := summarize(results), []summary{{"test_skip", Skip}, {"test_isolated", Pass}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() with a filter = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunTranspiler(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go binary")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	checkSample(t, writeSample(t), Options{Backend: Transpiler, Parallel: 2, Stdout: io.Discard})
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, []Result{
+		{File: "a_test.goblin", Name: "test_ok", Status: Pass},
+		{File: "a_test.goblin", Name: "test_bad", Status: Fail, Message: "Traceback (most recent call last):\n  at test_bad (a_test.goblin:2:5)\nexpected 1, got 2"},
+		{File: "b_test.goblin", Name: "test_later", Status: Skip, Message: "not yet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuites tests="3" failures="1" skipped="1" time="0.000">`,
+		`<testsuite name="a_test.goblin" tests="2" failures="1" errors="0" skipped="0" time="0.000">`,
+		`<failure message="expected 1, got 2"><![CDATA[Traceback (most recent call last):`,
+		`<skipped message="not yet"></skipped>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report lacks %s:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package transpiler
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/aisk/goblin/ast"
+	embedExt "github.com/aisk/goblin/extension/embed"
+	"github.com/aisk/goblin/object"
+	"github.com/dave/jennifer/jen"
+)
+
+// embeddedDir is the package of a generated module holding the files the
+// program embeds, under files/ at their paths relative to the entry point's
+// directory, as moduleKey spells them. Go ignores directories starting with
+// an underscore in ./... patterns, and no path import can be called that.
+const embeddedDir = "_embed"
+
+func (ctx *transpileContext) embeddedPackage() string {
+	return ctx.goModuleName + "/" + embeddedDir
+}
+
+// isEmbedFiles reports whether call is embed.files on the embed module the
+// current module imports.
+func (ctx *transpileContext) isEmbedFiles(call *ast.CallExpression) bool {
+	member, ok := call.Callee.(*ast.MemberExpression)
+	if !ok || member.Property != "files" {
+		return false
+	}
+	ident, ok := member.Object.(*ast.Identifier)
+	return ok && ctx.moduleImports[ident.Name] == "_"+embedModule.varName
+}
+
+// recordEmbed adds the files an embed.files call selects to those the
+// program is built with. The patterns must be string literals, the only
+// arguments whose files can be known before the program runs.
+func (ctx *transpileContext) recordEmbed(call *ast.CallExpression) error {
+	var patterns []string
+	for _, arg := range call.Args {
+		var pattern object.String
+		lit, ok := arg.Expr.(*ast.Literal)
+		if ok {
+			pattern, ok = lit.Value.(object.String)
+		}
+		if !ok || arg.Kind != ast.CallArgumentPositional {
+			return fmt.Errorf("%s: embed.files() takes string literals, so the files can be found when the program is built", call.Position())
+		}
+		patterns = append(patterns, string(pattern))
+	}
+	if len(patterns) == 0 {
+		return fmt.Errorf("%s: embed.files() takes at least one pattern", call.Position())
+	}
+	names, err := embedExt.Expand(os.DirFS(ctx.baseDir), patterns)
+	if err != nil {
+		return fmt.Errorf("%s: %v", call.Position(), err)
+	}
+	if ctx.embedded == nil {
+		ctx.embedded = make(map[string]string)
+	}
+	key := ctx.moduleKey(ctx.baseDir)
+	for _, name := range names {
+		ctx.embedded[path.Join(key, name)] = filepath.Join(ctx.baseDir, filepath.FromSlash(name))
+	}
+	return nil
+}
+
+// embedExecutor emits the executor of the embed module for the module being
+// transpiled: one over the embedded files in directory mode, and over the
+// source directory on disk for a single generated file, which has nowhere to
+// put them.
+func (ctx *transpileContext) embedExecutor() jen.Code {
+	if ctx.outputDir == "" {
+		return jen.Qual(pathExtension+"/embed", "Executor").Call(
+			jen.Qual("os", "DirFS").Call(jen.Lit(ctx.baseDir)), jen.Lit("."),
+		)
+	}
+	return jen.Qual(pathExtension+"/embed", "Executor").Call(
+		jen.Qual(ctx.embeddedPackage(), "Files"),
+		jen.Lit(path.Join("files", ctx.moduleKey(ctx.baseDir))),
+	)
+}
+
+// embedRegistryKey is the registry key of the embed module of the module
+// being transpiled: each source directory has its own.
+func (ctx *transpileContext) embedRegistryKey() string {
+	return "embed " + ctx.moduleKey(ctx.baseDir)
+}
+
+// writeEmbedded writes the embedded package when the program imports embed,
+// replacing what an earlier transpile into outputDir left.
+func (ctx *transpileContext) writeEmbedded() error {
+	dir := filepath.Join(ctx.outputDir, embeddedDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if _, ok := ctx.included["embed"]; !ok {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(ctx.embedded))
+	for name := range ctx.embedded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(ctx.embedded[name])
+		if err != nil {
+			return fmt.Errorf("failed to embed %s: %v", ctx.embedded[name], err)
+		}
+		target := filepath.Join(dir, "files", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	f := jen.NewFile("embedded")
+	f.PackageComment("Package embedded holds the files the program embeds.")
+	if len(names) > 0 {
+		f.Comment("//go:embed all:files")
+	}
+	f.Var().Id("Files").Qual("embed", "FS")
+	return ctx.writeFile(f, filepath.Join(dir, "embedded.go"))
+}
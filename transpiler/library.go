@@ -0,0 +1,266 @@
+package transpiler
+
+import (
+	"fmt"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
+	"github.com/dave/jennifer/jen"
+)
+
+// libExport is a name a library package exports to Go: a function, a type,
+// whose wrapper makes an instance, or any other value.
+type libExport struct {
+	name      string // the Goblin name
+	goName    string
+	kind      string // as doc.Export.Kind
+	params    []doc.Param
+	signature string // how the Goblin doc shows a function or type
+	doc       string
+}
+
+// libExports lists the names mod exports with the Go names of their
+// wrappers, which must not collide with each other or with anything else
+// the package declares.
+func libExports(mod *ast.Module, pkg string) ([]libExport, error) {
+	m := doc.New(mod, "", doc.Options{All: true})
+	funcs := map[string]*doc.Func{}
+	for _, f := range m.Funcs {
+		funcs[f.Name] = f
+	}
+	types := map[string]*doc.Type{}
+	for _, t := range m.Types {
+		types[t.Name] = t
+	}
+	taken := map[string]string{"Execute": "", "Load": "", "main": "", "init": ""}
+	for _, stmt := range mod.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefine:
+			taken[s.Name] = ""
+		case *ast.TypeDefine:
+			taken[s.Name], taken[s.Name+"Constructor"] = "", ""
+		case *ast.Declare:
+			taken[s.Name] = ""
+		case *ast.Import:
+			taken[s.Name] = ""
+		}
+	}
+	for _, name := range []string{"_load", "_call"} {
+		if _, ok := taken[name]; ok {
+			return nil, fmt.Errorf("cannot export to Go: %s is a name exports.go declares", name)
+		}
+	}
+
+	var exports []libExport
+	for _, e := range m.Exports {
+		x := libExport{name: e.Name, kind: e.Kind, goName: exportedName(e.Name), doc: e.Doc}
+		switch e.Kind {
+		case "func":
+			f := funcs[e.Name]
+			x.params, x.signature = f.Params, strings.TrimPrefix(f.Signature(), "func ")
+		case "type":
+			t := types[e.Name]
+			x.params, x.signature = t.Fields, strings.TrimPrefix(t.Signature(), "type ")
+			x.goName = "New" + x.goName
+		}
+		if !gotoken.IsExported(x.goName) {
+			return nil, fmt.Errorf("cannot export %s to Go: its name does not start with a letter", e.Name)
+		}
+		if other, ok := taken[x.goName]; ok {
+			if other != "" {
+				return nil, fmt.Errorf("cannot export both %s and %s to Go: both would be %s.%s", other, e.Name, pkg, x.goName)
+			}
+			return nil, fmt.Errorf("cannot export %s to Go as %s.%s: package %s already declares %s", e.Name, pkg, x.goName, pkg, x.goName)
+		}
+		taken[x.goName] = e.Name
+		exports = append(exports, x)
+	}
+	return exports, nil
+}
+
+// goParamName returns a Go parameter name for a Goblin one. The semantic
+// check already rejects Go keywords and the packages the wrappers use, which
+// leaves the name of their trailing variadic parameter.
+func goParamName(name string) string {
+	if name == "more" {
+		return name + "_"
+	}
+	return name
+}
+
+// docComment adds the doc comment of a wrapper to f: summary, then the
+// Goblin doc comment of what it wraps, if any, as // lines, which jen would
+// otherwise render as a /* */ block.
+func docComment(f *jen.File, summary, goblinDoc string) {
+	text := summary
+	if goblinDoc != "" {
+		text += "\n\n" + goblinDoc
+	}
+	for _, line := range strings.Split(text, "\n") {
+		f.Comment(line)
+	}
+}
+
+// generateExports writes exports.go, the Go API of a library package: Load,
+// which runs the module once, and a wrapper for each name it exports.
+// Functions and types take their parameters, or fields, up to the first one
+// with a default as Go parameters, and any others as trailing variadic
+// arguments.
+func (ctx *transpileContext) generateExports(exports []libExport) error {
+	f := jen.NewFile(ctx.opts.Package)
+	f.HeaderComment("Code generated by goblin build-lib. DO NOT EDIT.")
+
+	f.Var().Id("_load").Struct(
+		jen.Id("once").Qual("sync", "Once"),
+		jen.Id("module").Op("*").Qual(pathObject, "Module"),
+		jen.Id("err").Error(),
+	)
+	docComment(f, "Load runs the module's top-level code the first time it is called and\nreturns the module, whose Members are the values it exports. The functions\nbelow call it, so a program only needs it for dynamic access.", "")
+	f.Func().Id("Load").Params().Parens(jen.List(jen.Op("*").Qual(pathObject, "Module"), jen.Error())).Block(
+		jen.Id("_load").Dot("once").Dot("Do").Call(jen.Func().Params().Block(
+			jen.Var().Id("m").Qual(pathObject, "Object"),
+			jen.List(jen.Id("m"), jen.Id("_load").Dot("err")).Op("=").Id("Execute").Call(),
+			jen.If(jen.Id("_load").Dot("err").Op("==").Nil()).Block(
+				jen.Id("_load").Dot("module").Op("=").Id("m").Assert(jen.Op("*").Qual(pathObject, "Module")),
+			),
+		)),
+		jen.Return(jen.Id("_load").Dot("module"), jen.Id("_load").Dot("err")),
+	)
+	f.Func().Id("_call").Params(jen.Id("name").String(), jen.Id("args").Qual(pathObject, "Args")).Parens(jen.List(jen.Qual(pathObject, "Object"), jen.Error())).Block(
+		jen.List(jen.Id("m"), jen.Err()).Op(":=").Id("Load").Call(),
+		jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err())),
+		jen.Return(jen.Qual(pathObject, "Call").Call(
+			jen.Id("m").Dot("Members").Index(jen.Id("name")),
+			jen.Qual(pathObject, "CallArgs").Values(jen.Id("Positional").Op(":").Id("args")),
+		)),
+	)
+
+	for _, x := range exports {
+		results := jen.Parens(jen.List(jen.Qual(pathObject, "Object"), jen.Error()))
+		switch x.kind {
+		case "func", "type":
+			var params, required []jen.Code
+			variadic, rest := false, "more"
+			for _, p := range x.params {
+				if p.KwArgs {
+					continue
+				}
+				if variadic || p.VarArgs || p.Default != "" {
+					if !variadic && p.VarArgs {
+						rest = goParamName(p.Name)
+					} else {
+						rest = "more"
+					}
+					variadic = true
+					continue
+				}
+				name := goParamName(p.Name)
+				params = append(params, jen.Id(name).Qual(pathObject, "Object"))
+				required = append(required, jen.Id(name))
+			}
+			args := jen.Qual(pathObject, "Args").Values(required...)
+			if variadic {
+				params = append(params, jen.Id(rest).Op("...").Qual(pathObject, "Object"))
+				if len(required) == 0 {
+					args = jen.Id(rest)
+				} else {
+					args = jen.Append(args, jen.Id(rest).Op("..."))
+				}
+			}
+			summary := fmt.Sprintf("%s calls %s, which the Goblin module exports.", x.goName, x.signature)
+			if x.kind == "type" {
+				summary = fmt.Sprintf("%s makes an instance of type %s, which the Goblin\nmodule exports.", x.goName, x.signature)
+			}
+			docComment(f, summary, x.doc)
+			f.Func().Id(x.goName).Params(params...).Add(results).Block(
+				jen.Return(jen.Id("_call").Call(jen.Lit(x.name), args)),
+			)
+		default:
+			docComment(f, fmt.Sprintf("%s returns %s, a value the Goblin module exports.", x.goName, x.name), x.doc)
+			f.Func().Id(x.goName).Params().Add(results).Block(
+				jen.List(jen.Id("m"), jen.Err()).Op(":=").Id("Load").Call(),
+				jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err())),
+				jen.Return(jen.Id("m").Dot("Members").Index(jen.Lit(x.name)), jen.Nil()),
+			)
+		}
+	}
+	return ctx.writeFile(f, filepath.Join(ctx.outputDir, "exports.go"))
+}
+
+// capiDir is the main package generateCAPI writes.
+const capiDir = "capi"
+
+// generateCAPI writes capi/main.go, which exports the functions and values
+// of a library package to C for go build -buildmode=c-shared or c-archive.
+// C has no Goblin values, so arguments and results cross as JSON: each
+// function <package>_<name> takes a JSON array of arguments and returns its
+// result as JSON, or NULL with *err set to the error message. Types are left
+// out, as their instances have no JSON form.
+func (ctx *transpileContext) generateCAPI(exports []libExport) error {
+	pkg := ctx.opts.Package
+	f := jen.NewFile("main")
+	f.HeaderComment("Code generated by goblin build-lib. DO NOT EDIT.")
+	f.CgoPreamble("#include <stdlib.h>")
+	f.ImportAlias(ctx.goModuleName, "lib")
+
+	cstr := func() *jen.Statement { return jen.Op("*").Qual("C", "char") }
+	f.Func().Id("main").Params().Block()
+
+	f.Comment(fmt.Sprintf("%s_free frees a string a function of this library returned.", pkg))
+	f.Comment("//export " + pkg + "_free")
+	f.Func().Id(pkg + "_free").Params(jen.Id("s").Add(cstr())).Block(
+		jen.Qual("C", "free").Call(jen.Qual("unsafe", "Pointer").Call(jen.Id("s"))),
+	)
+
+	for _, x := range exports {
+		cName := pkg + "_" + x.name
+		switch x.kind {
+		case "type":
+			continue
+		case "func":
+			docComment(f, fmt.Sprintf("%s calls %s with the arguments in the JSON array\nargs and returns its result as JSON. On failure it returns NULL and sets\n*err to the error message. Free both with %s_free.", cName, x.signature, pkg), x.doc)
+			f.Comment("//export " + cName)
+			f.Func().Id(cName).Params(jen.Id("args").Add(cstr()), jen.Id("err").Op("*").Add(cstr())).Add(cstr()).Block(
+				jen.Return(jen.Id("_call").Call(jen.Lit(x.name), jen.Id("args"), jen.Id("err"))),
+			)
+		default:
+			docComment(f, fmt.Sprintf("%s returns %s as JSON. On failure it returns NULL and\nsets *err to the error message. Free both with %s_free.", cName, x.name, pkg), x.doc)
+			f.Comment("//export " + cName)
+			f.Func().Id(cName).Params(jen.Id("err").Op("*").Add(cstr())).Add(cstr()).Block(
+				jen.Return(jen.Id("_call").Call(jen.Lit(x.name), jen.Nil(), jen.Id("err"))),
+			)
+		}
+	}
+
+	// _call calls the member name with args, or returns it when args is nil,
+	// as the exported functions above do.
+	f.Func().Id("_call").Params(jen.Id("name").String(), jen.Id("args").Add(cstr()), jen.Id("errOut").Op("*").Add(cstr())).Add(cstr()).Block(
+		jen.List(jen.Id("m"), jen.Err()).Op(":=").Qual(ctx.goModuleName, "Load").Call(),
+		jen.Var().Id("out").Index().Byte(),
+		jen.If(jen.Err().Op("==").Nil().Op("&&").Id("args").Op("!=").Nil()).Block(
+			jen.List(jen.Id("out"), jen.Err()).Op("=").Qual(pathExtension, "CallJSON").Call(
+				jen.Id("m").Dot("Members").Index(jen.Id("name")),
+				jen.Index().Byte().Call(jen.Qual("C", "GoString").Call(jen.Id("args"))),
+			),
+		).Else().If(jen.Err().Op("==").Nil()).Block(
+			jen.List(jen.Id("out"), jen.Err()).Op("=").Qual(pathExtension, "MarshalJSON").Call(jen.Id("m").Dot("Members").Index(jen.Id("name"))),
+		),
+		jen.If(jen.Err().Op("!=").Nil()).Block(
+			jen.If(jen.Id("errOut").Op("!=").Nil()).Block(
+				jen.Op("*").Id("errOut").Op("=").Qual("C", "CString").Call(jen.Err().Dot("Error").Call()),
+			),
+			jen.Return(jen.Nil()),
+		),
+		jen.Return(jen.Qual("C", "CString").Call(jen.String().Call(jen.Id("out")))),
+	)
+
+	dir := filepath.Join(ctx.outputDir, capiDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create package directory %s: %v", dir, err)
+	}
+	return ctx.writeFile(f, filepath.Join(dir, "main.go"))
+}
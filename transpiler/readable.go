@@ -0,0 +1,186 @@
+package transpiler
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	"go/format"
+	goparser "go/parser"
+	gotoken "go/token"
+	"regexp"
+	"strconv"
+)
+
+// temporaryName matches the names localName generates: _<prefix>_<counter>.
+var temporaryName = regexp.MustCompile(`^_([A-Za-z]+)_([0-9]+)$`)
+
+// renumberTemporaries renames the temporaries declared inside each
+// top-level function of a generated file so their counters start from 0 in
+// that function, in order of first appearance, and returns the file
+// gofmt'ed. localName numbers temporaries across a whole module, so without
+// this an edit near the top of a script renames every temporary below it.
+//
+// Only names declared inside a function are renamed. The counter is global
+// while generating, so such a name is never also a package-level name, and
+// renaming every occurrence in the function keeps distinct names distinct.
+func renumberTemporaries(src []byte) ([]byte, error) {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "", src, goparser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// New names must not collide with the package-level names a function
+	// refers to.
+	taken := map[string]bool{}
+	for _, imp := range file.Imports {
+		if imp.Name != nil {
+			taken[imp.Name.Name] = true
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *goast.FuncDecl:
+			if d.Recv == nil {
+				taken[d.Name.Name] = true
+			}
+		case *goast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *goast.ValueSpec:
+					for _, name := range s.Names {
+						taken[name.Name] = true
+					}
+				case *goast.TypeSpec:
+					taken[s.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*goast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		renumberFunc(fn, taken)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renumberFunc(fn *goast.FuncDecl, taken map[string]bool) {
+	declared := map[string]bool{}
+	declare := func(names ...*goast.Ident) {
+		for _, name := range names {
+			if name != nil && temporaryName.MatchString(name.Name) && !taken[name.Name] {
+				declared[name.Name] = true
+			}
+		}
+	}
+	fields := func(list *goast.FieldList) {
+		if list == nil {
+			return
+		}
+		for _, field := range list.List {
+			declare(field.Names...)
+		}
+	}
+	fields(fn.Type.Params)
+	fields(fn.Type.Results)
+	goast.Inspect(fn.Body, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.AssignStmt:
+			if n.Tok == gotoken.DEFINE {
+				for _, lhs := range n.Lhs {
+					if id, ok := lhs.(*goast.Ident); ok {
+						declare(id)
+					}
+				}
+			}
+		case *goast.RangeStmt:
+			if n.Tok == gotoken.DEFINE {
+				for _, e := range []goast.Expr{n.Key, n.Value} {
+					if id, ok := e.(*goast.Ident); ok {
+						declare(id)
+					}
+				}
+			}
+		case *goast.ValueSpec:
+			declare(n.Names...)
+		case *goast.FuncType:
+			fields(n.Params)
+			fields(n.Results)
+		case *goast.LabeledStmt:
+			declare(n.Label)
+		}
+		return true
+	})
+	if len(declared) == 0 {
+		return
+	}
+
+	renamed := map[string]string{}
+	next := map[string]int{}
+	rename := func(id *goast.Ident) {
+		if !declared[id.Name] {
+			return
+		}
+		if name, ok := renamed[id.Name]; ok {
+			id.Name = name
+			return
+		}
+		prefix := temporaryName.FindStringSubmatch(id.Name)[1]
+		var name string
+		for {
+			name = fmt.Sprintf("_%s_%s", prefix, strconv.Itoa(next[prefix]))
+			next[prefix]++
+			if !taken[name] {
+				break
+			}
+		}
+		renamed[id.Name] = name
+		id.Name = name
+	}
+	for _, list := range []*goast.FieldList{fn.Type.Params, fn.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				rename(name)
+			}
+		}
+	}
+	goast.Inspect(fn.Body, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.SelectorExpr:
+			// Only the operand can be a variable; the selector is a field
+			// or method name.
+			goast.Inspect(n.X, func(n goast.Node) bool {
+				if id, ok := n.(*goast.Ident); ok {
+					rename(id)
+				}
+				return true
+			})
+			return false
+		case *goast.KeyValueExpr:
+			// Keys of struct literals are field names; map keys of generated
+			// code are literals.
+			goast.Inspect(n.Value, func(n goast.Node) bool {
+				if id, ok := n.(*goast.Ident); ok {
+					rename(id)
+				}
+				return true
+			})
+			return false
+		case *goast.Ident:
+			rename(n)
+		}
+		return true
+	})
+}
@@ -0,0 +1,202 @@
+package transpiler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Runtime holds the source of the Goblin runtime, so generated programs can
+// be built without downloading it. The goblin command sets it from the files
+// it embeds: go.mod, the runtime packages (object, extension and its
+// subpackages, profiler, internal/goid) at their paths in this repository,
+// and under _vendor/ the packages of the third-party modules they import, at
+// their import paths. When Runtime is nil, generated modules require the runtime
+// from a local checkout or the module proxy.
+var Runtime fs.FS
+
+// runtimeVendorDir is the directory of Runtime holding third-party packages.
+const runtimeVendorDir = "_vendor"
+
+// requirement is a module a generated go.mod requires.
+type requirement struct {
+	path, version string
+}
+
+// ModFlag is the -mod flag to build the module TranspileToDir wrote to dir
+// with: -mod=vendor when the runtime was copied into it, so the build never
+// touches the network, and -mod=mod otherwise, so go can add the runtime's
+// dependencies to go.mod.
+func ModFlag(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "vendor", "modules.txt")); err == nil {
+		return "-mod=vendor"
+	}
+	return "-mod=mod"
+}
+
+// writeVendor copies the runtime packages and their dependencies from Runtime
+// into outputDir/vendor and writes a matching go.mod, as `go mod vendor`
+// would. It copies the Go, assembly and C files of each package, and the
+// LICENSE files. A module's go.mod, when Runtime has it, gives the language
+// version its packages are compiled at, which is otherwise Go 1.16.
+func writeVendor(outputDir, moduleName, runtimeVersion string) error {
+	goMod, err := fs.ReadFile(Runtime, "go.mod")
+	if err != nil {
+		return fmt.Errorf("embedded runtime: %v", err)
+	}
+	goVersion, versions := parseGoMod(goMod)
+
+	vendorDir := filepath.Join(outputDir, "vendor")
+	if err := os.RemoveAll(vendorDir); err != nil {
+		return err
+	}
+	// packages maps each module to the directories of its packages.
+	packages := map[string]map[string]bool{}
+	// goVersions holds the go versions of the modules whose go.mod files
+	// Runtime has, at _vendor/<module>/go.mod.
+	goVersions := map[string]string{}
+	err = fs.WalkDir(Runtime, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "testdata" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if mod, ok := strings.CutPrefix(path.Dir(p), runtimeVendorDir+"/"); ok && path.Base(p) == "go.mod" {
+			data, err := fs.ReadFile(Runtime, p)
+			if err != nil {
+				return err
+			}
+			if v, _ := parseGoMod(data); v != "" {
+				goVersions[mod] = v
+			}
+			return nil
+		}
+		switch path.Ext(p) {
+		case ".go", ".s", ".c", ".h":
+		default:
+			if path.Base(p) != "LICENSE" {
+				return nil
+			}
+		}
+		if strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		target := pathBase + "/" + p
+		if rest, ok := strings.CutPrefix(p, runtimeVendorDir+"/"); ok {
+			target = rest
+		}
+		if strings.HasSuffix(p, ".go") {
+			dir := path.Dir(target)
+			mod := ""
+			for m := range versions {
+				if (dir == m || strings.HasPrefix(dir, m+"/")) && len(m) > len(mod) {
+					mod = m
+				}
+			}
+			if strings.HasPrefix(dir, pathBase+"/") {
+				mod = pathBase
+			}
+			if mod == "" {
+				return fmt.Errorf("%s is not in a module go.mod requires", dir)
+			}
+			if packages[mod] == nil {
+				packages[mod] = map[string]bool{}
+			}
+			packages[mod][dir] = true
+		}
+		data, err := fs.ReadFile(Runtime, p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(vendorDir, filepath.FromSlash(target))
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(out, data, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write the vendored runtime: %v", err)
+	}
+	var deps []requirement
+	for mod := range packages {
+		if mod != pathBase {
+			deps = append(deps, requirement{mod, versions[mod]})
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].path < deps[j].path })
+
+	var modules strings.Builder
+	fmt.Fprintf(&modules, "# %s %s\n## explicit; go %s\n", pathBase, runtimeVersion, goVersion)
+	for _, dir := range sortedKeys(packages[pathBase]) {
+		fmt.Fprintln(&modules, dir)
+	}
+	for _, dep := range deps {
+		fmt.Fprintf(&modules, "# %s %s\n## explicit", dep.path, dep.version)
+		if v := goVersions[dep.path]; v != "" {
+			fmt.Fprintf(&modules, "; go %s", v)
+		}
+		modules.WriteString("\n")
+		for _, dir := range sortedKeys(packages[dep.path]) {
+			fmt.Fprintln(&modules, dir)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(vendorDir, "modules.txt"), []byte(modules.String()), 0644); err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "module %s\n\ngo 1.19\n\nrequire (\n\t%s %s\n", moduleName, pathBase, runtimeVersion)
+	for _, dep := range deps {
+		fmt.Fprintf(&content, "\t%s %s // indirect\n", dep.path, dep.version)
+	}
+	content.WriteString(")\n")
+	return os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(content.String()), 0644)
+}
+
+// parseGoMod returns the go version and the required module versions of a
+// go.mod file.
+func parseGoMod(data []byte) (goVersion string, versions map[string]string) {
+	versions = map[string]string{}
+	inRequire := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inRequire && fields[0] == ")":
+			inRequire = false
+		case inRequire && len(fields) == 2:
+			versions[fields[0]] = fields[1]
+		case fields[0] == "go" && len(fields) == 2:
+			goVersion = fields[1]
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) == 3:
+			versions[fields[1]] = fields[2]
+		}
+	}
+	return goVersion, versions
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,7 +1,9 @@
 package transpiler
 
 import (
+	"bytes"
 	"fmt"
+	gotoken "go/token"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,10 +12,14 @@ import (
 	"strings"
 
 	"github.com/aisk/goblin/ast"
+	"github.com/aisk/goblin/doc"
 	"github.com/aisk/goblin/extension"
+	_ "github.com/aisk/goblin/extension/gobind"
+	_ "github.com/aisk/goblin/extension/stdlib"
 	"github.com/aisk/goblin/source"
 	"github.com/aisk/goblin/object"
 	"github.com/aisk/goblin/parser"
+	"github.com/aisk/goblin/profiler"
 	"github.com/aisk/goblin/semantic"
 	"github.com/aisk/goblin/token"
 	"github.com/dave/jennifer/jen"
@@ -23,6 +29,7 @@ const (
 	pathBase                    = "github.com/aisk/goblin"
 	pathObject                  = pathBase + "/object"
 	pathExtension               = pathBase + "/extension"
+	pathProfiler                = pathBase + "/profiler"
 	defaultGoblinRuntimeVersion = "v0.0.0-20260731160124-eddbfc600c08"
 )
 
@@ -32,58 +39,28 @@ type moduleInfo struct {
 	executorFunc string
 }
 
-var knownModules = map[string]moduleInfo{
-	"os":              {executorPath: pathExtension, varName: "os_module", executorFunc: "ExecuteOs"},
-	"rand":            {executorPath: pathExtension, varName: "rand_module", executorFunc: "ExecuteRand"},
-	"math":            {executorPath: pathExtension, varName: "math_module", executorFunc: "ExecuteMath"},
-	"base64":          {executorPath: pathExtension, varName: "base64_module", executorFunc: "ExecuteBase64"},
-	"http":            {executorPath: pathExtension + "/http", varName: "http_module", executorFunc: "Execute"},
-	"fs":              {executorPath: pathExtension + "/fs", varName: "fs_module", executorFunc: "Execute"},
-	"mime":            {executorPath: pathExtension, varName: "mime_module", executorFunc: "ExecuteMime"},
-	"json":            {executorPath: pathExtension, varName: "json_module", executorFunc: "ExecuteJson"},
-	"uuid":            {executorPath: pathExtension, varName: "uuid_module", executorFunc: "ExecuteUUID"},
-	"path":            {executorPath: pathExtension + "/path", varName: "path_module", executorFunc: "Execute"},
-	"time":            {executorPath: pathExtension + "/time", varName: "time_module", executorFunc: "Execute"},
-	"exec":            {executorPath: pathExtension + "/exec", varName: "exec_module", executorFunc: "Execute"},
-	"regexp":          {executorPath: pathExtension + "/regexp", varName: "regexp_module", executorFunc: "Execute"},
-	"hex":             {executorPath: pathExtension, varName: "hex_module", executorFunc: "ExecuteHex"},
-	"sha256":          {executorPath: pathExtension, varName: "sha256_module", executorFunc: "ExecuteSHA256"},
-	"sha512":          {executorPath: pathExtension, varName: "sha512_module", executorFunc: "ExecuteSHA512"},
-	"url":             {executorPath: pathExtension + "/url", varName: "url_module", executorFunc: "Execute"},
-	"csv":             {executorPath: pathExtension, varName: "csv_module", executorFunc: "ExecuteCSV"},
-	"gzip":            {executorPath: pathExtension, varName: "gzip_module", executorFunc: "ExecuteGzip"},
-	"zlib":            {executorPath: pathExtension, varName: "zlib_module", executorFunc: "ExecuteZlib"},
-	"tar":             {executorPath: pathExtension, varName: "tar_module", executorFunc: "ExecuteTar"},
-	"zip":             {executorPath: pathExtension, varName: "zip_module", executorFunc: "ExecuteZip"},
-	"base32":          {executorPath: pathExtension, varName: "base32_module", executorFunc: "ExecuteBase32"},
-	"ascii85":         {executorPath: pathExtension, varName: "ascii85_module", executorFunc: "ExecuteASCII85"},
-	"html":            {executorPath: pathExtension, varName: "html_module", executorFunc: "ExecuteHTML"},
-	"quotedprintable": {executorPath: pathExtension, varName: "quotedprintable_module", executorFunc: "ExecuteQuotedPrintable"},
-	"md5":             {executorPath: pathExtension, varName: "md5_module", executorFunc: "ExecuteMD5"},
-	"sha1":            {executorPath: pathExtension, varName: "sha1_module", executorFunc: "ExecuteSHA1"},
-	"crc32":           {executorPath: pathExtension, varName: "crc32_module", executorFunc: "ExecuteCRC32"},
-	"adler32":         {executorPath: pathExtension, varName: "adler32_module", executorFunc: "ExecuteAdler32"},
-	"flate":           {executorPath: pathExtension, varName: "flate_module", executorFunc: "ExecuteFlate"},
-	"bzip2":           {executorPath: pathExtension, varName: "bzip2_module", executorFunc: "ExecuteBzip2"},
-	"mail":            {executorPath: pathExtension + "/mail", varName: "mail_module", executorFunc: "Execute"},
-	"hmac":            {executorPath: pathExtension, varName: "hmac_module", executorFunc: "ExecuteHMAC"},
-	"crc64":           {executorPath: pathExtension, varName: "crc64_module", executorFunc: "ExecuteCRC64"},
-	"fnv":             {executorPath: pathExtension, varName: "fnv_module", executorFunc: "ExecuteFNV"},
-	"lzw":             {executorPath: pathExtension, varName: "lzw_module", executorFunc: "ExecuteLZW"},
-	"pem":             {executorPath: pathExtension, varName: "pem_module", executorFunc: "ExecutePEM"},
-	"netip":           {executorPath: pathExtension + "/netip", varName: "netip_module", executorFunc: "Execute"},
-	"utf8":            {executorPath: pathExtension, varName: "utf8_module", executorFunc: "ExecuteUTF8"},
-	"unicode":         {executorPath: pathExtension, varName: "unicode_module", executorFunc: "ExecuteUnicode"},
-}
-
-// KnownModuleNames lists the stdlib modules the transpiler can import, sorted.
-// It is exported so tests can assert the interpreter's builtinModules table
-// stays in sync with this one.
-func KnownModuleNames() []string {
-	names := make([]string, 0, len(knownModules))
-	for name := range knownModules {
-		names = append(names, name)
+// embedModule is the embed module, which is not registered: both backends
+// bind it per source directory, against which its patterns are matched.
+var embedModule = moduleInfo{executorPath: pathExtension + "/embed", varName: "embed_module", executorFunc: "Executor"}
+
+// lookupModule returns how to import the module name: one registered with
+// object.RegisterModule, such as those of the standard library, or embed.
+func lookupModule(name string) (moduleInfo, bool) {
+	if name == "embed" {
+		return embedModule, true
+	}
+	info, ok := object.LookupModule(name)
+	if !ok {
+		return moduleInfo{}, false
 	}
+	return moduleInfo{executorPath: info.Package, varName: name + "_module", executorFunc: info.Func}, true
+}
+
+// KnownModuleNames lists the modules the transpiler can import, sorted: the
+// registered modules and embed. Both backends read the same registry, so the
+// interpreter imports the same ones.
+func KnownModuleNames() []string {
+	names := append(object.ModuleNames(), "embed")
 	sort.Strings(names)
 	return names
 }
@@ -105,10 +82,24 @@ type transpileContext struct {
 	// currently being transpiled (see typeinfer.go). It is swapped on entry to
 	// every function body and restored on exit, so it always describes exactly
 	// one scope. Names absent from it are ordinary object.Object values.
+	// promoted holds its integer variables that may be assigned a BigInt,
+	// which have a boxed shadow next to their int64; see bigName.
 	localTypes map[string]staticType
+	promoted   map[string]bool
 	// For directory mode:
 	goModuleName string
 	outputDir    string
+	opts         Options
+	// modules lists the modules the program imports, for Result; included
+	// holds their keys so each is listed once.
+	modules  []Module
+	included map[string]struct{}
+	// embedded maps the files embed.files calls select, by their path under
+	// the embedded package's files/, to their source. See embed.go.
+	embedded map[string]string
+	// tests lists the test functions a test binary's main can run; it is nil
+	// when transpiling a program. See TranspileTestToDir.
+	tests []string
 	// Import resolution: relative import paths are resolved against the
 	// directory of the module currently being transpiled (baseDir), and
 	// module identifiers are derived relative to the entry point's
@@ -126,6 +117,13 @@ type transpileContext struct {
 	// must agree on it, so it is a whole-body property.
 	rangeNative bool
 
+	// overflowFlag names the Go bool that the checked integer operators of
+	// the native expression being emitted set on overflow, and boxedOnly
+	// forces operators to be emitted on boxed Objects while the fallback for
+	// such an overflow is generated. See emitNative.
+	overflowFlag string
+	boxedOnly    bool
+
 	// directFns holds the module-level functions of the module currently
 	// being transpiled that are eligible for direct-call lowering (see
 	// directcall.go), and moduleScopeIdx is the index of that module's user
@@ -159,7 +157,22 @@ func newTranspileContext() *transpileContext {
 		moduleFuncs:      nil,
 		topDecls:         nil,
 		loadedModuleVars: make(map[string]struct{}),
+		included:         make(map[string]struct{}),
+	}
+}
+
+// include records a module the program imports. file is empty for the
+// standard library.
+func (ctx *transpileContext) include(name, file string) {
+	key := name
+	if file != "" {
+		key = file
+	}
+	if _, ok := ctx.included[key]; ok {
+		return
 	}
+	ctx.included[key] = struct{}{}
+	ctx.modules = append(ctx.modules, Module{Name: name, File: file})
 }
 
 // pushUserScope opens a lexical scope for user-declared names and returns a
@@ -190,17 +203,59 @@ func (ctx *transpileContext) isUserName(name string) bool {
 // body's parameters (and, for a module, its hoisted names) are declared in the
 // user scope: whether `range` still means the builtin here depends on them.
 func (ctx *transpileContext) enterScope(body []ast.Statement) func() {
-	savedTypes := ctx.localTypes
+	savedTypes, savedPromoted := ctx.localTypes, ctx.promoted
 	savedRange := ctx.rangeNative
 	_, rangeImported := ctx.moduleImports["range"]
 	ctx.rangeNative = !rangeImported && !ctx.isUserName("range") && !bodyDeclaresName(body, "range")
-	ctx.localTypes = inferLocals(body, ctx.rangeNative)
+	ctx.localTypes, ctx.promoted = inferLocals(body, ctx.rangeNative)
 	return func() {
-		ctx.localTypes = savedTypes
+		ctx.localTypes, ctx.promoted = savedTypes, savedPromoted
 		ctx.rangeNative = savedRange
 	}
 }
 
+// mayOverflow reports whether a native expression must be emitted with a
+// fallback to boxed evaluation; see emitNative.
+func (ctx *transpileContext) mayOverflow(expr ast.Expression) bool {
+	return mayOverflow(expr, ctx.localTypes, ctx.promoted)
+}
+
+// bigName is the name of the shadow of a promoted integer variable: nil while
+// the variable's int64 holds its value, and the BigInt it overflowed to
+// otherwise.
+func bigName(name string) string {
+	return "_big_" + name
+}
+
+// promotedGuard returns the condition that one of the promoted variables expr
+// reads holds a BigInt, or nil if it reads none.
+func (ctx *transpileContext) promotedGuard(expr ast.Expression) *jen.Statement {
+	var guard *jen.Statement
+	seen := map[string]bool{}
+	var visit func(ast.Expression)
+	visit = func(expr ast.Expression) {
+		switch e := expr.(type) {
+		case *ast.Identifier:
+			if ctx.promoted[e.Name] && !seen[e.Name] {
+				seen[e.Name] = true
+				cond := jen.Id(bigName(e.Name)).Op("!=").Nil()
+				if guard == nil {
+					guard = cond
+				} else {
+					guard = guard.Op("||").Add(cond)
+				}
+			}
+		case *ast.UnaryOperation:
+			visit(e.Operand)
+		case *ast.BinaryOperation:
+			visit(e.LHS)
+			visit(e.RHS)
+		}
+	}
+	visit(expr)
+	return guard
+}
+
 // typeOf reports the specialised native type of a name, or tyDynamic when the
 // name is an ordinary boxed value.
 func (ctx *transpileContext) typeOf(name string) staticType {
@@ -219,6 +274,13 @@ func (ctx *transpileContext) nativeTypeOf(expr ast.Expression) staticType {
 	if ctx.localTypes == nil {
 		return tyDynamic
 	}
+	if ctx.boxedOnly {
+		switch expr.(type) {
+		case *ast.Identifier, *ast.Literal:
+		default:
+			return tyDynamic
+		}
+	}
 	return staticTypeOf(expr, ctx.localTypes)
 }
 
@@ -244,7 +306,107 @@ func box(code *jen.Statement, t staticType) *jen.Statement {
 // and modulo, cannot fail — so they collapse into a single Go expression with
 // no error plumbing. Division and modulo need a zero check, and that is a
 // statement, hence the leading []jen.Code most callers will find empty.
+//
+// Integer arithmetic can overflow int64, where Goblin promotes to a BigInt.
+// An expression that does any, or that reads a promoted variable, is emitted
+// with checked operators, followed by a fallback that re-evaluates it on boxed
+// Objects when one of them overflowed or a variable it reads holds a BigInt;
+// that is sound because native expressions have no side effects. The
+// fallback's result is unboxed again, so it only works for bool and float
+// results. An integer one can only be the value of a promoted variable, which
+// emitPromoted evaluates; the other integer contexts check mayOverflow
+// themselves.
 func (ctx *transpileContext) emitNative(expr ast.Expression, onError errHandler) ([]jen.Code, *jen.Statement, error) {
+	if !ctx.mayOverflow(expr) {
+		return ctx.emitNativeExpr(expr, onError)
+	}
+	t := ctx.nativeTypeOf(expr)
+	if t == tyInt {
+		return nil, nil, fmt.Errorf("internal error: integer expression that may overflow emitted natively")
+	}
+	pre, value, flag, err := ctx.emitChecked(expr, onError)
+	if err != nil {
+		return nil, nil, err
+	}
+	fallbackPre, fallback, err := ctx.emitBoxed(expr, onError)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unboxed *jen.Statement
+	if t == tyBool {
+		unboxed = jen.Bool().Call(fallback.Assert(jen.Qual(pathObject, "Bool")))
+	} else {
+		unboxed = jen.Float64().Call(fallback.Assert(jen.Qual(pathObject, "Float")))
+	}
+	fallbackPre = append(fallbackPre, jen.Id(value).Op("=").Add(unboxed))
+	pre = append(pre, jen.If(jen.Id(flag)).Block(fallbackPre...))
+	return pre, jen.Id(value), nil
+}
+
+// emitChecked emits a native expression that may overflow into a fresh
+// variable, together with the overflow flag its checked operators set. When
+// the expression reads promoted variables, the flag starts out set if one
+// holds a BigInt, and then the expression is not evaluated at all.
+func (ctx *transpileContext) emitChecked(expr ast.Expression, onError errHandler) (pre []jen.Code, value, flag string, err error) {
+	flag = ctx.localName("overflow")
+	value = ctx.localName("native")
+	ctx.overflowFlag = flag
+	pre, code, err := ctx.emitNativeExpr(expr, onError)
+	ctx.overflowFlag = ""
+	if err != nil {
+		return nil, "", "", err
+	}
+	guard := ctx.promotedGuard(expr)
+	if guard == nil {
+		pre = append([]jen.Code{jen.Var().Id(flag).Bool()}, pre...)
+		pre = append(pre, jen.Id(value).Op(":=").Add(code))
+		return pre, value, flag, nil
+	}
+	pre = append(pre, jen.Id(value).Op("=").Add(code))
+	return []jen.Code{
+		jen.Var().Id(value).Id(goTypeOf(ctx.nativeTypeOf(expr))),
+		jen.Id(flag).Op(":=").Add(guard),
+		jen.If(jen.Op("!").Id(flag)).Block(pre...),
+	}, value, flag, nil
+}
+
+// emitPromoted evaluates the value of a promoted integer variable as the pair
+// the variable stores: an int64 holding it while it fits, and a shadow that
+// is nil then and holds the BigInt it overflowed to otherwise.
+func (ctx *transpileContext) emitPromoted(value ast.Expression, onError errHandler) (pre []jen.Code, num, big *jen.Statement, err error) {
+	if !ctx.mayOverflow(value) {
+		pre, code, err := ctx.emitNativeExpr(value, onError)
+		return pre, code, jen.Nil(), err
+	}
+	pre, native, flag, err := ctx.emitChecked(value, onError)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fallbackPre, fallback, err := ctx.emitBoxed(value, onError)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	shadow := ctx.localName("big")
+	fallbackPre = append(fallbackPre, jen.List(jen.Id(native), jen.Id(shadow)).Op("=").Qual(pathObject, "SplitInteger").Call(fallback))
+	pre = append(pre,
+		jen.Var().Id(shadow).Qual(pathObject, "Object"),
+		jen.If(jen.Id(flag)).Block(fallbackPre...),
+	)
+	return pre, jen.Id(native), jen.Id(shadow), nil
+}
+
+// emitBoxed emits an expression with every operator on boxed Objects, the
+// way it would be emitted if none of its variables were specialised.
+func (ctx *transpileContext) emitBoxed(expr ast.Expression, onError errHandler) ([]jen.Code, *jen.Statement, error) {
+	saved := ctx.boxedOnly
+	ctx.boxedOnly = true
+	defer func() { ctx.boxedOnly = saved }()
+	return ctx.transpileExpression(expr, onError)
+}
+
+// emitNativeExpr is emitNative for one node of the expression tree, with any
+// overflow checks reporting to ctx.overflowFlag.
+func (ctx *transpileContext) emitNativeExpr(expr ast.Expression, onError errHandler) ([]jen.Code, *jen.Statement, error) {
 	switch e := expr.(type) {
 	case *ast.Literal:
 		switch v := e.Value.(type) {
@@ -260,18 +422,21 @@ func (ctx *transpileContext) emitNative(expr ast.Expression, onError errHandler)
 		return nil, jen.Id(e.Name), nil
 
 	case *ast.UnaryOperation:
-		pre, operand, err := ctx.emitNative(e.Operand, onError)
+		pre, operand, err := ctx.emitNativeExpr(e.Operand, onError)
 		if err != nil {
 			return nil, nil, err
 		}
+		if overflowingOp(e, ctx.localTypes) {
+			return pre, ctx.checkedCall("CheckedNeg", operand), nil
+		}
 		return pre, jen.Parens(jen.Op(e.Operator).Add(operand)), nil
 
 	case *ast.BinaryOperation:
-		lhsPre, lhs, err := ctx.emitNative(e.LHS, onError)
+		lhsPre, lhs, err := ctx.emitNativeExpr(e.LHS, onError)
 		if err != nil {
 			return nil, nil, err
 		}
-		rhsPre, rhs, err := ctx.emitNative(e.RHS, onError)
+		rhsPre, rhs, err := ctx.emitNativeExpr(e.RHS, onError)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -304,6 +469,9 @@ func (ctx *transpileContext) emitNative(expr ast.Expression, onError errHandler)
 					onError(errVar),
 				),
 			)
+			if overflowingOp(e, ctx.localTypes) {
+				return pre, ctx.checkedCall("CheckedDiv", lhs, jen.Id(divisor)), nil
+			}
 			return pre, jen.Parens(lhs.Op("/").Id(divisor)), nil
 		}
 
@@ -327,6 +495,17 @@ func (ctx *transpileContext) emitNative(expr ast.Expression, onError errHandler)
 			return pre, jen.Parens(jen.Qual("math", "Mod").Call(lhs, jen.Id(divisor))), nil
 		}
 
+		if overflowingOp(e, ctx.localTypes) {
+			switch e.Operator {
+			case "+":
+				return pre, ctx.checkedCall("CheckedAdd", lhs, rhs), nil
+			case "-":
+				return pre, ctx.checkedCall("CheckedSub", lhs, rhs), nil
+			case "*":
+				return pre, ctx.checkedCall("CheckedMul", lhs, rhs), nil
+			}
+		}
+
 		// Goblin's operator spellings for arithmetic, comparison, && and ||
 		// are the same as Go's, so the operator carries over verbatim.
 		return pre, jen.Parens(lhs.Op(e.Operator).Add(rhs)), nil
@@ -335,6 +514,13 @@ func (ctx *transpileContext) emitNative(expr ast.Expression, onError errHandler)
 	return nil, nil, fmt.Errorf("internal error: %T is not a native expression", expr)
 }
 
+// checkedCall emits a call to one of object's Checked integer operators,
+// passing the overflow flag of the expression being emitted.
+func (ctx *transpileContext) checkedCall(name string, args ...jen.Code) *jen.Statement {
+	args = append(args, jen.Op("&").Id(ctx.overflowFlag))
+	return jen.Qual(pathObject, name).Call(args...)
+}
+
 func (ctx *transpileContext) localName(prefix string) string {
 	name := fmt.Sprintf("_%s_%d", prefix, ctx.localNameCounter)
 	ctx.localNameCounter++
@@ -393,6 +579,19 @@ func frameCode(module, function string, pos token.Pos) *jen.Statement {
 	})
 }
 
+// profileEnter opens a function or module body with the profiler's Enter and
+// a deferred Exit, both skipped unless a profile is being recorded. It emits
+// nothing unless Options.Profile asked for an instrumented build.
+func (ctx *transpileContext) profileEnter(module, function string, pos token.Pos) []jen.Code {
+	if !ctx.opts.Profile {
+		return nil
+	}
+	return []jen.Code{jen.If(jen.Qual(pathProfiler, "Enabled").Call()).Block(
+		jen.Qual(pathProfiler, "Enter").Call(frameCode(module, function, pos)),
+		jen.Defer().Qual(pathProfiler, "Exit").Call(),
+	)}
+}
+
 func tracedReturn(errVar, module, function string, pos token.Pos) jen.Code {
 	return jen.Return(jen.Nil(), jen.Qual(pathObject, "WithFrame").Call(
 		jen.Id(errVar), frameCode(module, function, pos),
@@ -469,7 +668,7 @@ func keyToFuncName(key string) string {
 // named after the module itself, which also makes the variable shared when
 // several modules in one generated file import the same module. Path imports
 // are handed to recurse (when non-nil) so dependent modules are transpiled
-// first; stdlib imports are validated against knownModules. importPath is the
+// first; stdlib imports are validated against the module registry. importPath is the
 // import path of the module being collected, empty for the entry module (it
 // only affects error wording).
 func (ctx *transpileContext) collectModuleImports(mod *ast.Module, importPath string, recurse func(string) error) (map[string]string, error) {
@@ -485,13 +684,14 @@ func (ctx *transpileContext) collectModuleImports(mod *ast.Module, importPath st
 				return nil, fmt.Errorf("failed to resolve path %s: %v", imp.Path, err)
 			}
 			imports[imp.Name] = "_mod_" + keyToIdent(ctx.moduleKey(absPath))
+			ctx.include(ctx.moduleKey(absPath), absPath)
 			if recurse != nil {
 				if err := recurse(imp.Path); err != nil {
 					return nil, err
 				}
 			}
 		} else {
-			info, exists := knownModules[imp.Path]
+			info, exists := lookupModule(imp.Path)
 			if !exists {
 				if importPath == "" {
 					return nil, fmt.Errorf("unknown module: %s", imp.Path)
@@ -499,6 +699,7 @@ func (ctx *transpileContext) collectModuleImports(mod *ast.Module, importPath st
 				return nil, fmt.Errorf("unknown module in %s: %s", importPath, imp.Path)
 			}
 			imports[imp.Name] = "_" + info.varName
+			ctx.include(imp.Path, "")
 		}
 	}
 	return imports, nil
@@ -531,12 +732,12 @@ func (ctx *transpileContext) loadPathModule(importPath string, emit func(mod *as
 	ctx.baseDir = filepath.Dir(absPath)
 	defer func() { ctx.baseDir = savedBaseDir }()
 
-	l, err := source.NewLexerFile(absPath)
+	sc, err := source.NewScannerFile(absPath)
 	if err != nil {
 		return fmt.Errorf("failed to read module %s: %v", importPath, err)
 	}
 	p := parser.NewParser()
-	st, err := p.Parse(l)
+	st, err := p.Parse(sc)
 	if err != nil {
 		return fmt.Errorf("parse error in module %s: %v", importPath, err)
 	}
@@ -545,6 +746,7 @@ func (ctx *transpileContext) loadPathModule(importPath string, emit func(mod *as
 	if !ok {
 		return fmt.Errorf("internal error: unexpected AST type for module %s", importPath)
 	}
+	doc.Attach(mod, sc)
 	if err := semantic.CheckModule(mod); err != nil {
 		return fmt.Errorf("semantic error in module %s: %v", importPath, err)
 	}
@@ -599,9 +801,9 @@ func (ctx *transpileContext) emitExecuteBody(mod *ast.Module, imports map[string
 		return nil, err
 	}
 
-	body := []jen.Code{
+	body := append(ctx.profileEnter(sourceModuleName(modulePosition(mod)), "<module>", modulePosition(mod)),
 		jen.Id(exportsVar).Op(":=").Map(jen.String()).Qual(pathObject, "Object").Values(),
-	}
+	)
 
 	// loadModule declares the package-level variable holding a loaded module
 	// (once per generated file — the same module imported elsewhere reuses it)
@@ -626,7 +828,11 @@ func (ctx *transpileContext) emitExecuteBody(mod *ast.Module, imports map[string
 		if !ok || isPathImport(imp.Path) {
 			continue
 		}
-		info := knownModules[imp.Path]
+		if imp.Path == "embed" {
+			loadModule(imports[imp.Name], jen.Lit(ctx.embedRegistryKey()), ctx.embedExecutor())
+			continue
+		}
+		info, _ := lookupModule(imp.Path)
 		loadModule(imports[imp.Name], jen.Lit(imp.Path), jen.Qual(info.executorPath, info.executorFunc))
 	}
 
@@ -657,11 +863,18 @@ func (ctx *transpileContext) emitExecuteBody(mod *ast.Module, imports map[string
 }
 
 func Transpile(mod *ast.Module, output io.Writer) error {
+	return TranspileOptions(mod, output, Options{})
+}
+
+// TranspileOptions is Transpile with the settings in opts that apply to a
+// single generated file: NoLineDirectives and Profile.
+func TranspileOptions(mod *ast.Module, output io.Writer, opts Options) error {
 	if err := semantic.CheckModule(mod); err != nil {
 		return err
 	}
 
 	ctx := newTranspileContext()
+	ctx.opts = opts
 	if cwd, err := os.Getwd(); err == nil {
 		ctx.baseDir = cwd
 		ctx.rootDir = cwd
@@ -705,14 +918,23 @@ func Transpile(mod *ast.Module, output io.Writer) error {
 	f.Func().Id("Execute").Params().Parens(jen.List(
 		jen.Qual(pathObject, "Object"), jen.Error(),
 	)).Block(body...)
-	f.Func().Id("main").Params().Block(mainBody()...)
+	f.Func().Id("main").Params().Block(mainBody(ctx.opts.Profile)...)
 	return f.Render(output)
 }
 
 // mainBody emits the generated main(): run Execute, print failures, and turn a
 // Go-side runtime panic into a clean internal error instead of a Go stack dump.
-func mainBody() []jen.Code {
+// Without profile, a GOBLIN_CPUPROFILE in the environment gets a warning, not
+// silence: the profile it asks for needs the instrumentation of --profile.
+func mainBody(profile bool) []jen.Code {
+	if profile {
+		return profiledMainBody()
+	}
 	return []jen.Code{
+		jen.If(jen.Qual("os", "Getenv").Call(jen.Lit(profiler.EnvVar)).Op("!=").Lit("")).Block(
+			jen.Qual("fmt", "Fprintln").Call(jen.Qual("os", "Stderr"),
+				jen.Lit("warning: "+profiler.EnvVar+" is set, but this executable was built without --profile and writes no profile")),
+		),
 		jen.Defer().Func().Params().Block(
 			jen.If(jen.Id("r").Op(":=").Recover(), jen.Id("r").Op("!=").Nil()).Block(
 				jen.Qual("fmt", "Fprintf").Call(jen.Qual("os", "Stderr"), jen.Lit("internal error: %v\n"), jen.Id("r")),
@@ -727,6 +949,98 @@ func mainBody() []jen.Code {
 	}
 }
 
+// profiledMainBody is mainBody for a profile build: it also starts the
+// profile GOBLIN_CPUPROFILE asks for, and writes it on every way out, os.exit
+// included.
+func profiledMainBody() []jen.Code {
+	stderr := jen.Qual("os", "Stderr")
+	return []jen.Code{
+		jen.List(jen.Id("_stop_0"), jen.Id("err")).Op(":=").Qual(pathProfiler, "StartFromEnv").Call(),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("%v\n"), jen.Id("err")),
+			jen.Qual("os", "Exit").Call(jen.Lit(1)),
+		),
+		jen.Id("_stopProfile_0").Op(":=").Func().Params().Block(
+			jen.If(jen.Id("err").Op(":=").Id("_stop_0").Call(), jen.Id("err").Op("!=").Nil()).Block(
+				jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("%v\n"), jen.Id("err")),
+			),
+		),
+		jen.Qual(pathExtension, "AtExit").Call(jen.Id("_stopProfile_0")),
+		jen.Defer().Func().Params().Block(
+			jen.If(jen.Id("r").Op(":=").Recover(), jen.Id("r").Op("!=").Nil()).Block(
+				jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("internal error: %v\n"), jen.Id("r")),
+				jen.Id("_stopProfile_0").Call(),
+				jen.Qual("os", "Exit").Call(jen.Lit(1)),
+			),
+		).Call(),
+		jen.List(jen.Id("_"), jen.Id("err")).Op("=").Id("Execute").Call(),
+		jen.Id("_stopProfile_0").Call(),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("%+v\n"), jen.Id("err")),
+			jen.Qual("os", "Exit").Call(jen.Lit(1)),
+		),
+	}
+}
+
+// testMainBody emits the body of a test binary's entry point, which returns
+// the exit status. It gives the test a testing module of its own, removed
+// again on the way out, runs the module, and calls the test named by the
+// first argument. The test function is looked up only after Execute has
+// assigned it. Every local uses the reserved _name_N form, so none can
+// shadow a module-level function.
+func testMainBody(tests []string, hasImports bool) []jen.Code {
+	stderr := jen.Qual("os", "Stderr")
+	cases := make([]jen.Code, 0, len(tests)+1)
+	for _, name := range tests {
+		cases = append(cases, jen.Case(jen.Lit(name)).Block(jen.Id("_test_0").Op("=").Id(name)))
+	}
+	cases = append(cases, jen.Default().Block(
+		jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("unknown test: %s\n"), jen.Id("_name_0")),
+		jen.Return(jen.Lit(1)),
+	))
+
+	body := []jen.Code{
+		jen.Id("_testing_0").Op(":=").Qual(pathExtension, "NewTesting").Call(),
+		jen.Defer().Id("_testing_0").Dot("Cleanup").Call(),
+		jen.Defer().Func().Params().Block(
+			jen.If(jen.Id("r").Op(":=").Recover(), jen.Id("r").Op("!=").Nil()).Block(
+				jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("internal error: %v\n"), jen.Id("r")),
+				jen.Id("_code_0").Op("=").Lit(1),
+			),
+		).Call(),
+		jen.If(jen.Len(jen.Qual("os", "Args")).Op("<").Lit(2)).Block(
+			jen.Qual("fmt", "Fprintln").Call(stderr, jen.Lit("usage: test-binary <test name>")),
+			jen.Return(jen.Lit(1)),
+		),
+		jen.Id("_name_0").Op(":=").Qual("os", "Args").Index(jen.Lit(1)),
+	}
+	if hasImports {
+		body = append(body, jen.List(jen.Id("_"), jen.Id("_")).Op("=").Id("_registry").Dot("Load").Call(
+			jen.Lit("testing"), jen.Id("_testing_0").Dot("Execute"),
+		))
+	}
+	body = append(body,
+		jen.List(jen.Id("_"), jen.Id("_err_0")).Op(":=").Id("Execute").Call(),
+		jen.If(jen.Id("_err_0").Op("==").Nil()).Block(
+			jen.Var().Id("_test_0").Qual(pathObject, "Object"),
+			jen.Switch(jen.Id("_name_0")).Block(cases...),
+			jen.List(jen.Id("_"), jen.Id("_err_0")).Op("=").Qual(pathObject, "Call").Call(
+				jen.Id("_test_0"), jen.Qual(pathObject, "CallArgs").Values(),
+			),
+		),
+		jen.If(jen.Id("_err_0").Op("!=").Nil()).Block(
+			jen.If(jen.Qual(pathExtension, "Skipped").Call(jen.Id("_err_0"))).Block(
+				jen.Qual("fmt", "Fprintln").Call(stderr, jen.Id("_err_0").Dot("Error").Call()),
+				jen.Return(jen.Lit(TestSkipExitCode)),
+			),
+			jen.Qual("fmt", "Fprintf").Call(stderr, jen.Lit("%+v\n"), jen.Id("_err_0")),
+			jen.Return(jen.Lit(1)),
+		),
+		jen.Return(jen.Lit(0)),
+	)
+	return body
+}
+
 // transpilePathModule parses and transpiles a .goblin file at the given path,
 // generating a top-level executor function.
 func (ctx *transpileContext) transpilePathModule(importPath string) error {
@@ -769,6 +1083,8 @@ func transpileObject(obj object.Object) (*jen.Statement, error) {
 	case object.Integer:
 		i := jen.Qual(pathObject, "Integer").Call(jen.Lit(int64(v)))
 		return i, nil
+	case *object.BigInt:
+		return jen.Qual(pathObject, "MustParseInt").Call(jen.Lit(v.String())), nil
 	case object.Float:
 		f := jen.Qual(pathObject, "Float").Call(jen.Lit(float64(v)))
 		return f, nil
@@ -862,6 +1178,29 @@ func (ctx *transpileContext) transpileExpression(expr ast.Expression, onError er
 	// operator. Callers that can consume the unboxed value (declarations,
 	// assignments, conditions) check for this themselves before calling here.
 	if t := ctx.nativeTypeOf(expr); t.native() {
+		// A promoted variable on its own is read boxed, shadow and all.
+		if ident, ok := expr.(*ast.Identifier); ok && ctx.promoted[ident.Name] {
+			return nil, jen.Qual(pathObject, "JoinInteger").Call(jen.Id(ident.Name), jen.Id(bigName(ident.Name))), nil
+		}
+		// The result is boxed anyway, so an overflow fallback can hand over
+		// its Object as it is, BigInt included.
+		if ctx.mayOverflow(expr) {
+			pre, value, flag, err := ctx.emitChecked(expr, onError)
+			if err != nil {
+				return nil, nil, err
+			}
+			fallbackPre, fallback, err := ctx.emitBoxed(expr, onError)
+			if err != nil {
+				return nil, nil, err
+			}
+			boxed := ctx.localName("boxed")
+			fallbackPre = append(fallbackPre, jen.Id(boxed).Op("=").Add(fallback))
+			pre = append(pre,
+				jen.Var().Id(boxed).Qual(pathObject, "Object").Op("=").Add(box(jen.Id(value), t)),
+				jen.If(jen.Id(flag)).Block(fallbackPre...),
+			)
+			return pre, jen.Id(boxed), nil
+		}
 		pre, code, err := ctx.emitNative(expr, onError)
 		if err != nil {
 			return nil, nil, err
@@ -1026,6 +1365,17 @@ func isBuiltinFunction(name string) bool {
 }
 
 func (ctx *transpileContext) transpileDeclare(decl *ast.Declare, onError errHandler) ([]jen.Code, error) {
+	if ctx.promoted[decl.Name] {
+		pre, num, big, err := ctx.emitPromoted(decl.Value, onError)
+		if err != nil {
+			return nil, err
+		}
+		ctx.declareUserName(decl.Name)
+		declStmt := jen.Var().Id(decl.Name).Int64().Op("=").Add(num)
+		declStmt.Op(";").Var().Id(bigName(decl.Name)).Qual(pathObject, "Object").Op("=").Add(big)
+		declStmt.Op(";").List(jen.Id("_"), jen.Id("_")).Op("=").List(jen.Id(decl.Name), jen.Id(bigName(decl.Name)))
+		return append(pre, declStmt), nil
+	}
 	if t := ctx.typeOf(decl.Name); t.native() {
 		pre, value, err := ctx.emitNative(decl.Value, onError)
 		if err != nil {
@@ -1048,6 +1398,13 @@ func (ctx *transpileContext) transpileDeclare(decl *ast.Declare, onError errHand
 }
 
 func (ctx *transpileContext) transpileAssign(decl *ast.Assign, onError errHandler) ([]jen.Code, error) {
+	if ctx.promoted[decl.Target] {
+		pre, num, big, err := ctx.emitPromoted(decl.Value, onError)
+		if err != nil {
+			return nil, err
+		}
+		return append(pre, jen.List(jen.Id(decl.Target), jen.Id(bigName(decl.Target))).Op("=").List(num, big)), nil
+	}
 	if ctx.typeOf(decl.Target).native() {
 		pre, value, err := ctx.emitNative(decl.Value, onError)
 		if err != nil {
@@ -1230,7 +1587,8 @@ func (ctx *transpileContext) transpileFor(for_ *ast.For, onError errHandler) ([]
 	}
 
 	iterVar := ctx.localName("iter")
-	elementsVar := ctx.localName("elements")
+	okVar := ctx.localName("ok")
+	cursorVar := ctx.localName("cursor")
 	errVar := ctx.localName("err")
 
 	forLoopBody := []jen.Code{
@@ -1239,10 +1597,19 @@ func (ctx *transpileContext) transpileFor(for_ *ast.For, onError errHandler) ([]
 	}
 	forLoopBody = append(forLoopBody, body...)
 
+	// A cursor rather than a range over Iter(): iterating a Chan receives one
+	// value per step until it is closed, and a channel closed with an error
+	// re-raises it once drained.
+	next := jen.Id(cursorVar).Dot("Next").Call()
 	result := append(iterPreStmts,
-		jen.List(jen.Id(elementsVar), jen.Id(errVar)).Op(":=").Parens(jen.Add(iterator)).Dot("Iter").Call(),
+		jen.List(jen.Id(cursorVar), jen.Id(errVar)).Op(":=").Qual(pathObject, "NewCursor").Call(iterator),
 		jen.If(jen.Id(errVar).Op("!=").Nil()).Block(onError(errVar)),
-		jen.For(jen.List(jen.Id("_"), jen.Id(iterVar)).Op(":=").Op("range").Id(elementsVar)).Block(forLoopBody...),
+		jen.For(
+			jen.List(jen.Id(iterVar), jen.Id(okVar)).Op(":=").Add(next),
+			jen.Id(okVar),
+			jen.List(jen.Id(iterVar), jen.Id(okVar)).Op("=").Add(next),
+		).Block(forLoopBody...),
+		jen.If(jen.Id(errVar).Op(":=").Id(cursorVar).Dot("Err").Call(), jen.Id(errVar).Op("!=").Nil()).Block(onError(errVar)),
 	)
 
 	return []jen.Code{jen.Block(result...)}, nil
@@ -1262,7 +1629,8 @@ func (ctx *transpileContext) transpileRangeFor(for_ *ast.For, startExpr, endExpr
 
 	var pre []jen.Code
 	var startCode *jen.Statement
-	if ctx.nativeTypeOf(startExpr) == tyInt && ctx.nativeTypeOf(endExpr) == tyInt {
+	if ctx.nativeTypeOf(startExpr) == tyInt && ctx.nativeTypeOf(endExpr) == tyInt &&
+		!ctx.mayOverflow(startExpr) && !ctx.mayOverflow(endExpr) {
 		startPre, start, err := ctx.emitNative(startExpr, onError)
 		if err != nil {
 			return nil, err
@@ -1301,7 +1669,13 @@ func (ctx *transpileContext) transpileRangeFor(for_ *ast.For, startExpr, endExpr
 	}
 
 	loopBody := make([]jen.Code, 0, len(body)+2)
-	if ctx.typeOf(for_.Variable) == tyInt {
+	if ctx.promoted[for_.Variable] {
+		loopBody = append(loopBody,
+			jen.Id(for_.Variable).Op(":=").Id(counterVar),
+			jen.Var().Id(bigName(for_.Variable)).Qual(pathObject, "Object"),
+			jen.Id("_").Op("=").Id(bigName(for_.Variable)),
+		)
+	} else if ctx.typeOf(for_.Variable) == tyInt {
 		loopBody = append(loopBody, jen.Id(for_.Variable).Op(":=").Id(counterVar))
 	} else {
 		loopBody = append(loopBody,
@@ -1367,6 +1741,11 @@ func (ctx *transpileContext) transpileCallExpression(call *ast.CallExpression, o
 	}
 
 	if member, ok := call.Callee.(*ast.MemberExpression); ok {
+		if ctx.isEmbedFiles(call) {
+			if err := ctx.recordEmbed(call); err != nil {
+				return nil, nil, err
+			}
+		}
 		objPre, obj, err := ctx.transpileExpression(member.Object, onError)
 		if err != nil {
 			return nil, nil, err
@@ -1559,8 +1938,9 @@ func (ctx *transpileContext) emitParameterBinding(name string, params []*ast.Par
 // buildFunctionValue emits an `&object.Function{...}` expression that wraps a
 // closure binding the given parameters and running the given body. It is shared
 // by named function definitions and anonymous function literals. name is used
-// only for the runtime function's repr and for BindArguments diagnostics.
-func (ctx *transpileContext) buildFunctionValue(name string, pos token.Pos, params []*ast.Parameter, body []ast.Statement) (*jen.Statement, error) {
+// only for the runtime function's repr and for BindArguments diagnostics, and
+// help, if not empty, is the text help() shows for the function.
+func (ctx *transpileContext) buildFunctionValue(name, help string, pos token.Pos, params []*ast.Parameter, body []ast.Statement) (*jen.Statement, error) {
 	// Default expressions belong to the enclosing scope, so they are transpiled
 	// before entering the body's inference scope.
 	defaultsDecl, defaultsName, err := ctx.emitParamDefaults(params)
@@ -1599,7 +1979,7 @@ func (ctx *transpileContext) buildFunctionValue(name string, pos token.Pos, para
 		return nil, err
 	}
 
-	bodyCode = append(argsDefine, bodyCode...)
+	bodyCode = append(append(ctx.profileEnter(module, name, pos), argsDefine...), bodyCode...)
 
 	closure := jen.Func().Params(
 		jen.Id(callArgsName).Qual(pathObject, "CallArgs"),
@@ -1607,10 +1987,20 @@ func (ctx *transpileContext) buildFunctionValue(name string, pos token.Pos, para
 		jen.Qual(pathObject, "Object"), jen.Id("error")),
 	).Block(bodyCode...)
 
-	return jen.Op("&").Qual(pathObject, "Function").Values(
+	return functionValue(name, help, closure), nil
+}
+
+// functionValue emits the `&object.Function{...}` literal for fn, carrying
+// help as its Doc, as the interpreter does, when help is not empty.
+func functionValue(name, help string, fn jen.Code) *jen.Statement {
+	fields := []jen.Code{
 		jen.Id("Name").Op(":").Lit(name),
-		jen.Id("Fn").Op(":").Add(closure),
-	), nil
+		jen.Id("Fn").Op(":").Add(fn),
+	}
+	if help != "" {
+		fields = append(fields, jen.Id("Doc").Op(":").Lit(help))
+	}
+	return jen.Op("&").Qual(pathObject, "Function").Values(fields...)
 }
 
 // buildDirectFunction generates a direct-lowered module-level function: a
@@ -1653,6 +2043,7 @@ func (ctx *transpileContext) buildDirectFunction(info directFn, fn *ast.Function
 	for _, param := range params {
 		paramDecls = append(paramDecls, jen.Id(param.Name).Qual(pathObject, "Object"))
 	}
+	bodyCode = append(ctx.profileEnter(module, name, pos), bodyCode...)
 	ctx.topDecls = append(ctx.topDecls,
 		jen.Func().Id(info.goName).Params(paramDecls...).Parens(jen.List(
 			jen.Qual(pathObject, "Object"), jen.Id("error"),
@@ -1717,10 +2108,7 @@ func (ctx *transpileContext) buildDirectFunction(info directFn, fn *ast.Function
 	)).Block(wrapperBody...)
 
 	return []jen.Code{
-		jen.Id(name).Op("=").Op("&").Qual(pathObject, "Function").Values(
-			jen.Id("Name").Op(":").Lit(name),
-			jen.Id("Fn").Op(":").Add(wrapper),
-		),
+		jen.Id(name).Op("=").Add(functionValue(name, doc.FuncHelp(doc.NewFunc(fn)), wrapper)),
 	}, nil
 }
 
@@ -1728,7 +2116,7 @@ func (ctx *transpileContext) transpileFunctionDefine(fn *ast.FunctionDefine, onE
 	// Declared before the body transpiles so the function can shadow a
 	// built-in even in recursive references to itself.
 	ctx.declareUserName(fn.Name)
-	funcValue, err := ctx.buildFunctionValue(fn.Name, fn.Position(), fn.Parameters, fn.Body)
+	funcValue, err := ctx.buildFunctionValue(fn.Name, doc.FuncHelp(doc.NewFunc(fn)), fn.Position(), fn.Parameters, fn.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -1744,7 +2132,7 @@ func (ctx *transpileContext) transpileFunctionDefine(fn *ast.FunctionDefine, onE
 }
 
 func (ctx *transpileContext) transpileFunctionLiteral(fn *ast.FunctionLiteral) ([]jen.Code, *jen.Statement, error) {
-	funcValue, err := ctx.buildFunctionValue("<lambda>", fn.Position(), fn.Parameters, fn.Body)
+	funcValue, err := ctx.buildFunctionValue("<lambda>", "", fn.Position(), fn.Parameters, fn.Body)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2011,10 +2399,7 @@ func (ctx *transpileContext) transpileTypeDefine(typeDef *ast.TypeDefine, onErro
 		getAttrCases = append(getAttrCases,
 			jen.Case(jen.Lit(method.Name)).Block(
 				jen.Return(
-					jen.Op("&").Qual(pathObject, "Function").Values(
-						jen.Id("Name").Op(":").Lit(method.Name),
-						jen.Id("Fn").Op(":").Id(receiverName).Dot(wrapperName),
-					),
+					functionValue(method.Name, doc.FuncHelp(doc.NewFunc(method)), jen.Id(receiverName).Dot(wrapperName)),
 					jen.Nil(),
 				),
 			),
@@ -2115,7 +2500,7 @@ func (ctx *transpileContext) transpileTypeDefine(typeDef *ast.TypeDefine, onErro
 		savedPos := ctx.errorPos
 		ctx.errorPos = method.Position()
 
-		var bodyPrefix []jen.Code
+		bodyPrefix := ctx.profileEnter(methodModule, qualifiedName, method.Position())
 		defaultsDecl, defaultsName, err := ctx.emitParamDefaults(method.Parameters[1:])
 		if err != nil {
 			return nil, err
@@ -2230,10 +2615,8 @@ func (ctx *transpileContext) transpileTypeDefine(typeDef *ast.TypeDefine, onErro
 		jen.Var().Id(ctorVarName).Qual(pathObject, "Object"),
 	)
 
-	constructor := jen.Id(ctorVarName).Op("=").Op("&").Qual(pathObject, "Function").Values(
-		jen.Id("Name").Op(":").Lit(typeDef.Name),
-		jen.Id("Fn").Op(":").Add(constructorClosure),
-	)
+	constructor := jen.Id(ctorVarName).Op("=").Add(
+		functionValue(typeDef.Name, doc.TypeHelp(doc.NewType(typeDef)), constructorClosure))
 
 	return []jen.Code{constructor}, nil
 }
@@ -2489,8 +2872,14 @@ func (ctx *transpileContext) transpileUnaryOperation(operation *ast.UnaryOperati
 }
 
 func (ctx *transpileContext) transpileExport(export *ast.Export, exportsVar string) ([]jen.Code, error) {
+	// Types and imports live in Go variables of other names, as they do
+	// when an identifier refers to them.
+	name := export.Name
+	if v, ok := ctx.moduleImports[name]; ok {
+		name = v
+	}
 	return []jen.Code{
-		jen.Id(exportsVar).Index(jen.Lit(export.Name)).Op("=").Id(export.Name),
+		jen.Id(exportsVar).Index(jen.Lit(export.Name)).Op("=").Id(name),
 	}, nil
 }
 
@@ -2525,8 +2914,15 @@ func (ctx *transpileContext) transpileStatement(stmt ast.Statement, onError errH
 		return nil, nil
 	}
 
+	// The profiler hook comes before the //line directive, which must stay
+	// directly above the statement it maps.
 	var prelude []jen.Code
-	if d := lineDirective(stmt.Position()); d != nil {
+	if ctx.opts.Profile {
+		prelude = append(prelude, jen.If(jen.Qual(pathProfiler, "Enabled").Call()).Block(
+			jen.Qual(pathProfiler, "Line").Call(jen.Lit(stmt.Position().Line)),
+		))
+	}
+	if d := lineDirective(stmt.Position()); d != nil && !ctx.opts.NoLineDirectives {
 		prelude = append(prelude, d)
 	}
 
@@ -2672,7 +3068,7 @@ func (ctx *transpileContext) transpileModuleStatements(stmts []ast.Statement, on
 				funcAssigns = append(funcAssigns, assigns...)
 				continue
 			}
-			funcValue, err := ctx.buildFunctionValue(v.Name, v.Position(), v.Parameters, v.Body)
+			funcValue, err := ctx.buildFunctionValue(v.Name, doc.FuncHelp(doc.NewFunc(v)), v.Position(), v.Parameters, v.Body)
 			if err != nil {
 				return nil, err
 			}
@@ -2684,6 +3080,18 @@ func (ctx *transpileContext) transpileModuleStatements(stmts []ast.Statement, on
 			// A specialised variable is declared with its native type; it can
 			// never be captured by a closure, since being named inside a nested
 			// scope is exactly what disqualifies it from specialisation.
+			if ctx.promoted[v.Name] {
+				ctx.topDecls = append(ctx.topDecls, jen.Var().Id(v.Name).Int64(), jen.Var().Id(bigName(v.Name)).Qual(pathObject, "Object"))
+
+				pre, num, big, err := ctx.emitPromoted(v.Value, onError)
+				if err != nil {
+					return nil, err
+				}
+				ctx.declareUserName(v.Name)
+				body = append(body, pre...)
+				body = append(body, jen.List(jen.Id(v.Name), jen.Id(bigName(v.Name))).Op("=").List(num, big))
+				continue
+			}
 			if t := ctx.typeOf(v.Name); t.native() {
 				ctx.topDecls = append(ctx.topDecls, jen.Var().Id(v.Name).Id(goTypeOf(t)))
 
@@ -2786,10 +3194,27 @@ func detectGoblinRoot() string {
 	return ""
 }
 
-// generateGoMod writes the go.mod file for the output directory.
-func generateGoMod(outputDir, moduleName string) error {
+// generateGoMod writes the go.mod file for the output directory and fills in
+// the runtime fields of r. Unless GOBLIN_ROOT names a checkout to build
+// against, a CLI that embeds the runtime copies it into the module's vendor
+// directory.
+func generateGoMod(outputDir, moduleName string, r *Result) error {
+	version := goblinRuntimeVersion()
+	if os.Getenv("GOBLIN_ROOT") == "" && Runtime != nil {
+		// A CLI built from a modified checkout reports a version such as
+		// v0.0.0-...+dirty, which go rejects as a requirement.
+		if i := strings.Index(version, "+"); i >= 0 {
+			version = version[:i]
+		}
+		r.RuntimeVersion, r.Vendored = version, true
+		return writeVendor(outputDir, moduleName, version)
+	}
+	if err := os.RemoveAll(filepath.Join(outputDir, "vendor")); err != nil {
+		return err
+	}
 	goblinRoot := detectGoblinRoot()
-	content := generateGoModContent(moduleName, goblinRuntimeVersion(), goblinRoot)
+	r.RuntimeVersion, r.Checkout = version, goblinRoot
+	content := generateGoModContent(moduleName, version, goblinRoot)
 	return os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(content), 0644)
 }
 
@@ -2805,13 +3230,39 @@ func goblinRuntimeVersion() string {
 	return goblinRuntimeVersionFromBuildInfo(info)
 }
 
+// A custom distribution, whose main module is its own, requires the Goblin
+// module like any dependency.
 func goblinRuntimeVersionFromBuildInfo(info *debug.BuildInfo) string {
-	if info != nil && info.Main.Path == pathBase && info.Main.Version != "" && info.Main.Version != "(devel)" {
-		return info.Main.Version
+	if info == nil {
+		return defaultGoblinRuntimeVersion
+	}
+	module := &info.Main
+	if module.Path != pathBase {
+		module = nil
+		for _, dep := range info.Deps {
+			if dep.Path == pathBase {
+				module = dep
+			}
+		}
+	}
+	if module != nil && module.Version != "" && module.Version != "(devel)" {
+		return module.Version
 	}
 	return defaultGoblinRuntimeVersion
 }
 
+// RuntimeSource returns how a Go module outside this repository requires the
+// Goblin module the running CLI was built from: its version, and the local
+// checkout to replace it with, if one is found, as generated modules do
+// without an embedded runtime.
+func RuntimeSource() (version, checkout string) {
+	version = goblinRuntimeVersion()
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	return version, detectGoblinRoot()
+}
+
 func generateGoModContent(moduleName, runtimeVersion, goblinRoot string) string {
 	if goblinRoot != "" {
 		return fmt.Sprintf(
@@ -2829,26 +3280,132 @@ func generateGoModContent(moduleName, runtimeVersion, goblinRoot string) string
 // The entry-point module becomes output/main.go; each imported path module becomes
 // its own package under outputDir.
 func TranspileToDir(mod *ast.Module, sourceFile, outputDir string) error {
+	_, err := transpileToDir(mod, sourceFile, outputDir, nil, Options{})
+	return err
+}
+
+// Options adjust the Go module TranspileToDirOptions writes.
+type Options struct {
+	// Package names the Go package of the entry module. Empty means package
+	// main, whose main function runs the module; any other name makes a
+	// library package, written to <Package>.go, whose exported Execute runs
+	// it.
+	Package string
+	// Module is the Go module path written to go.mod, under which the
+	// packages of path imports are imported. Empty means the source file's
+	// name.
+	Module string
+	// NoLineDirectives leaves out the //line comments that map the
+	// generated code back to the Goblin source for Go's own diagnostics.
+	NoLineDirectives bool
+	// Readable renumbers the generated temporaries, such as _tmp_3 and
+	// _err_4, from 0 in each function, so they stay short and an edit to one
+	// function does not rename those of every function after it.
+	Readable bool
+	// Exports adds exports.go to a library package: Load, which runs the
+	// module once, and a Go wrapper for each name the module exports, for
+	// `goblin build-lib`.
+	Exports bool
+	// CExports adds capi/, a main package exporting the functions and
+	// values of a library package to C for -buildmode=c-shared and
+	// c-archive. It implies Exports.
+	CExports bool
+	// Profile instruments every function and statement for the Goblin
+	// profiler, and makes main start a profile when GOBLIN_CPUPROFILE names a
+	// file. Without it the generated code has no profiler calls at all.
+	Profile bool
+}
+
+// Result describes the Go module TranspileToDirOptions wrote, for build
+// manifests.
+type Result struct {
+	// Modules lists the modules the program imports, directly or through
+	// its path imports, sorted by name.
+	Modules []Module
+	// RuntimeVersion is the version of the Goblin runtime go.mod requires.
+	RuntimeVersion string
+	// Vendored reports that the runtime was copied into vendor/ from
+	// Runtime; otherwise it comes from Checkout through a replace
+	// directive, or from the module proxy when Checkout is empty.
+	Vendored bool
+	Checkout string
+}
+
+// Module is a module a program imports.
+type Module struct {
+	// Name is the standard library module's name, or the path of a path
+	// import relative to the program's directory, without .goblin.
+	Name string
+	// File is the source file of a path import, and empty for the standard
+	// library.
+	File string
+}
+
+// TranspileToDirOptions is TranspileToDir with options, for `goblin
+// transpile` and `goblin build-exe`.
+func TranspileToDirOptions(mod *ast.Module, sourceFile, outputDir string, opts Options) (*Result, error) {
+	if opts.Package != "" && !gotoken.IsIdentifier(opts.Package) {
+		return nil, fmt.Errorf("invalid Go package name %q", opts.Package)
+	}
+	if opts.Package == "main" {
+		opts.Package = ""
+	}
+	if strings.ContainsAny(opts.Module, " \t\n\\") || strings.HasPrefix(opts.Module, "/") || strings.HasSuffix(opts.Module, "/") {
+		return nil, fmt.Errorf("invalid Go module path %q", opts.Module)
+	}
+	if opts.CExports {
+		opts.Exports = true
+	}
+	if opts.Exports && opts.Package == "" {
+		return nil, fmt.Errorf("exports need a library package: set Package")
+	}
+	return transpileToDir(mod, sourceFile, outputDir, nil, opts)
+}
+
+// TestSkipExitCode is the exit status of a test binary whose test raised
+// testing.SkipTest.
+const TestSkipExitCode = 3
+
+// TranspileTestToDir is TranspileToDir for a test file. Instead of only
+// running the module, the generated main runs it and then calls the test
+// function named by its first argument, which must be one of tests. The
+// binary exits 0 when the test passes, TestSkipExitCode with the reason on
+// stderr when it is skipped, and 1 with the traceback on stderr when it fails.
+func TranspileTestToDir(mod *ast.Module, sourceFile, outputDir string, tests []string) error {
+	if tests == nil {
+		tests = []string{}
+	}
+	_, err := transpileToDir(mod, sourceFile, outputDir, tests, Options{})
+	return err
+}
+
+func transpileToDir(mod *ast.Module, sourceFile, outputDir string, tests []string, opts Options) (*Result, error) {
 	if err := semantic.CheckModule(mod); err != nil {
-		return err
+		return nil, err
 	}
 
 	base := filepath.Base(sourceFile)
 	moduleName := strings.TrimSuffix(base, ".goblin")
 
+	if opts.Module != "" {
+		moduleName = opts.Module
+	}
+
 	ctx := newTranspileContext()
 	ctx.goModuleName = moduleName
 	ctx.outputDir = outputDir
+	ctx.tests = tests
+	ctx.opts = opts
 
 	absSource, err := filepath.Abs(sourceFile)
 	if err != nil {
-		return fmt.Errorf("failed to resolve source file %s: %v", sourceFile, err)
+		return nil, fmt.Errorf("failed to resolve source file %s: %v", sourceFile, err)
 	}
 	ctx.baseDir = filepath.Dir(absSource)
 	ctx.rootDir = ctx.baseDir
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
 	}
 
 	// Recursively transpile each path import into its own package file.
@@ -2858,15 +3415,43 @@ func TranspileToDir(mod *ast.Module, sourceFile, outputDir string) error {
 			continue
 		}
 		if err := ctx.transpilePathModuleToFile(imp.Path); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if err := ctx.generateMainFile(mod); err != nil {
-		return err
+		return nil, err
+	}
+	if opts.Exports {
+		exports, err := libExports(mod, opts.Package)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.generateExports(exports); err != nil {
+			return nil, err
+		}
+		if opts.CExports {
+			if err := ctx.generateCAPI(exports); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !opts.CExports {
+		// Drop the C API an earlier transpile into outputDir left.
+		if err := os.RemoveAll(filepath.Join(outputDir, capiDir)); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.writeEmbedded(); err != nil {
+		return nil, fmt.Errorf("failed to write embedded files: %v", err)
 	}
 
-	return generateGoMod(outputDir, moduleName)
+	r := &Result{Modules: ctx.modules}
+	sort.Slice(r.Modules, func(i, j int) bool { return r.Modules[i].Name < r.Modules[j].Name })
+	if err := generateGoMod(outputDir, moduleName, r); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
 // transpilePathModuleToFile parses a .goblin file at importPath and writes it
@@ -2887,6 +3472,7 @@ func (ctx *transpileContext) transpilePathModuleToFile(importPath string) error
 		}
 
 		f := jen.NewFile(pkgName)
+		f.ImportName(ctx.embeddedPackage(), "embedded")
 		f.Var().Id("builtin").Op("=").Qual(pathExtension, "BuiltinsModule")
 
 		// Register import aliases so jennifer uses _pkg_X for sub-path-imports.
@@ -2927,23 +3513,39 @@ func (ctx *transpileContext) transpilePathModuleToFile(importPath string) error
 			jen.Qual(pathObject, "Object"), jen.Error(),
 		)).Block(funcBody...)
 
-		outFile := filepath.Join(pkgDir, pkgName+".go")
-		fh, err := os.Create(outFile)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %v", outFile, err)
-		}
-		defer fh.Close()
+		return ctx.writeFile(f, filepath.Join(pkgDir, pkgName+".go"))
+	})
+}
 
-		if err := f.Render(fh); err != nil {
-			return fmt.Errorf("failed to render file %s: %v", outFile, err)
+// writeFile renders f to path, renumbering its temporaries first when the
+// options ask for readable output.
+func (ctx *transpileContext) writeFile(f *jen.File, path string) error {
+	var buf bytes.Buffer
+	if err := f.Render(&buf); err != nil {
+		return fmt.Errorf("failed to render file %s: %v", path, err)
+	}
+	src := buf.Bytes()
+	if ctx.opts.Readable {
+		var err error
+		if src, err = renumberTemporaries(src); err != nil {
+			return fmt.Errorf("failed to render file %s: %v", path, err)
 		}
-		return nil
-	})
+	}
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		return fmt.Errorf("failed to create file %s: %v", path, err)
+	}
+	return nil
 }
 
-// generateMainFile generates output/main.go for the top-level module.
+// generateMainFile generates output/main.go for the top-level module, or
+// output/<package>.go for a library package.
 func (ctx *transpileContext) generateMainFile(mod *ast.Module) error {
-	f := jen.NewFile("main")
+	pkgName := "main"
+	if ctx.opts.Package != "" {
+		pkgName = ctx.opts.Package
+	}
+	f := jen.NewFile(pkgName)
+	f.ImportName(ctx.embeddedPackage(), "embedded")
 	f.Var().Id("builtin").Op("=").Qual(pathExtension, "BuiltinsModule")
 
 	// Register import aliases for path imports.
@@ -2995,14 +3597,21 @@ func (ctx *transpileContext) generateMainFile(mod *ast.Module) error {
 	f.Func().Id("Execute").Params().Parens(jen.List(
 		jen.Qual(pathObject, "Object"), jen.Error(),
 	)).Block(body...)
-	f.Func().Id("main").Params().Block(mainBody()...)
-
-	outFile := filepath.Join(ctx.outputDir, "main.go")
-	fh, err := os.Create(outFile)
-	if err != nil {
-		return fmt.Errorf("failed to create main.go: %v", err)
+	if ctx.tests != nil {
+		hasImports := false
+		for _, stmt := range mod.Body {
+			if _, ok := stmt.(*ast.Import); ok {
+				hasImports = true
+				break
+			}
+		}
+		f.Func().Id("main").Params().Block(
+			jen.Qual("os", "Exit").Call(jen.Id("_test_main_0").Call()),
+		)
+		f.Func().Id("_test_main_0").Params().Parens(jen.Id("_code_0").Int()).Block(testMainBody(ctx.tests, hasImports)...)
+	} else if pkgName == "main" {
+		f.Func().Id("main").Params().Block(mainBody(ctx.opts.Profile)...)
 	}
-	defer fh.Close()
 
-	return f.Render(fh)
+	return ctx.writeFile(f, filepath.Join(ctx.outputDir, pkgName+".go"))
 }
@@ -2,6 +2,7 @@ package transpiler
 
 import (
 	"bytes"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -9,6 +10,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/aisk/goblin/ast"
 	"github.com/aisk/goblin/lexer"
@@ -48,6 +50,14 @@ func TestGoblinRuntimeVersionFromBuildInfo(t *testing.T) {
 			}},
 			want: defaultGoblinRuntimeVersion,
 		},
+		{
+			name: "custom distribution",
+			info: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/wrapper", Version: "(devel)"},
+				Deps: []*debug.Module{{Path: pathBase, Version: "v1.2.3"}},
+			},
+			want: "v1.2.3",
+		},
 		{
 			name: "missing build info",
 			want: defaultGoblinRuntimeVersion,
@@ -65,6 +75,11 @@ func TestGoblinRuntimeVersionFromBuildInfo(t *testing.T) {
 
 func transpileSource(t *testing.T, source string) string {
 	t.Helper()
+	return transpileSourceOptions(t, source, Options{})
+}
+
+func transpileSourceOptions(t *testing.T, source string, opts Options) string {
+	t.Helper()
 
 	l := lexer.NewLexer([]byte(source))
 
@@ -84,7 +99,7 @@ func transpileSource(t *testing.T, source string) string {
 	}
 
 	var buf bytes.Buffer
-	if err := Transpile(mod, &buf); err != nil {
+	if err := TranspileOptions(mod, &buf, opts); err != nil {
 		t.Fatalf("transpile error: %v", err)
 	}
 
@@ -201,6 +216,26 @@ func TestTranspileFunctionsAttachGoblinFrames(t *testing.T) {
 	}
 }
 
+// Only a profile build pays for the profiler's hooks.
+func TestTranspileProfileInstrumentation(t *testing.T) {
+	src := "type Box(v) {\n  func get(self) { return self.v }\n}\nfunc twice(x) { return x * 2 }\nprint(twice(Box(1).get()))\n"
+	if code := transpileSource(t, src); strings.Contains(code, "profiler") {
+		t.Fatalf("default output references the profiler\n%s", code)
+	}
+	code := transpileSourceOptions(t, src, Options{Profile: true})
+	for _, want := range []string{
+		"profiler.StartFromEnv()",
+		"profiler.Line(5)",
+		`Function: "twice"`,
+		`Function: "Box.get"`,
+		"defer profiler.Exit()",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("expected profile output to contain %q\n%s", want, code)
+		}
+	}
+}
+
 // Frames must carry the module name derived from the source file, matching
 // the interpreter's tracebacks (`at fail [mymod] (mymod.goblin:1:6)`).
 func TestTranspiledFramesCarryModuleName(t *testing.T) {
@@ -378,3 +413,371 @@ print(xs)
 		}
 	})
 }
+
+func TestNativeIntegerOverflow(t *testing.T) {
+	t.Run("integer arithmetic is checked with a boxed fallback", func(t *testing.T) {
+		code := transpileSource(t, `var n = 3037000500
+var limit = 100
+if n * n > limit {
+    print(n * n)
+}
+`)
+		if !strings.Contains(code, "var n int64") {
+			t.Fatalf("expected n to stay a native int64\n%s", code)
+		}
+		if !strings.Contains(code, "object.CheckedMul(") {
+			t.Fatalf("expected a checked multiplication\n%s", code)
+		}
+		if !strings.Contains(code, "object.Multiply(") {
+			t.Fatalf("expected a boxed fallback for the overflow\n%s", code)
+		}
+	})
+
+	t.Run("a variable assigned integer arithmetic is promoted", func(t *testing.T) {
+		code := transpileSource(t, `var total = 0
+total = total + 1
+var copy = total
+print(total, copy)
+`)
+		for _, want := range []string{"var total int64", "var _big_total object.Object", "var copy int64", "object.CheckedAdd(", "object.SplitInteger(", "object.JoinInteger(total, _big_total)"} {
+			if !strings.Contains(code, want) {
+				t.Fatalf("expected %q: total stays a native int64 with a shadow for a BigInt\n%s", want, code)
+			}
+		}
+	})
+
+	t.Run("constant arithmetic is not checked", func(t *testing.T) {
+		code := transpileSource(t, `var seconds = -60 * 60
+print(seconds)
+`)
+		if !strings.Contains(code, "var seconds int64") || strings.Contains(code, "Checked") {
+			t.Fatalf("expected constant arithmetic to stay plain and native\n%s", code)
+		}
+	})
+}
+
+func TestTranspileToDirOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.goblin"), []byte("func double(x) {\n    return x * 2\n}\nexport double\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.goblin")
+	if err := os.WriteFile(path, []byte("import \"./lib\"\nprint(lib.double(21))\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := source.NewLexerFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := parser.NewParser().Parse(l)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	mod := st.(*ast.Module)
+
+	out := filepath.Join(t.TempDir(), "out")
+	opts := Options{Package: "app", Module: "example.com/app", NoLineDirectives: true}
+	result, err := TranspileToDirOptions(mod, path, out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Modules) != 1 || result.Modules[0].Name != "lib" || result.Modules[0].File != filepath.Join(dir, "lib.goblin") {
+		t.Fatalf("modules = %+v", result.Modules)
+	}
+	if _, err := os.Stat(filepath.Join(out, "main.go")); !os.IsNotExist(err) {
+		t.Fatalf("a library package wrote main.go: %v", err)
+	}
+	code, err := os.ReadFile(filepath.Join(out, "app.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"package app\n", `"example.com/app/lib"`, "func Execute() (object.Object, error)"} {
+		if !strings.Contains(string(code), want) {
+			t.Fatalf("app.go lacks %q\n%s", want, code)
+		}
+	}
+	if strings.Contains(string(code), "func main()") || strings.Contains(string(code), "//line") {
+		t.Fatalf("app.go has a main function or line directives\n%s", code)
+	}
+	gomod, err := os.ReadFile(filepath.Join(out, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(gomod), "module example.com/app\n") {
+		t.Fatalf("go.mod =\n%s", gomod)
+	}
+	if _, err := os.Stat(filepath.Join(out, "lib", "lib.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []Options{{Package: "9lives"}, {Package: "a-b"}, {Module: "has space"}, {Module: "/abs"}} {
+		if _, err := TranspileToDirOptions(mod, path, t.TempDir(), bad); err == nil {
+			t.Errorf("TranspileToDirOptions accepted %+v", bad)
+		}
+	}
+}
+
+func TestTranspileEmbed(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"templates/a.html": "a",
+		"lib/data/b.txt":   "b",
+		"secret.txt":       "secret",
+		"lib/util.goblin":  "import \"embed\"\nvar data = embed.files(\"data\")\nexport data\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transpile := func(src string) (string, error) {
+		t.Helper()
+		path := filepath.Join(dir, "app.goblin")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		l, err := source.NewLexerFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := parser.NewParser().Parse(l)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		out := filepath.Join(t.TempDir(), "out")
+		_, err = TranspileToDirOptions(st.(*ast.Module), path, out, Options{})
+		return out, err
+	}
+
+	out, err := transpile("import \"embed\"\nimport \"./lib/util\"\nvar t = embed.files(\"templates/*.html\")\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	filepath.WalkDir(filepath.Join(out, "_embed", "files"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(out, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if want := []string{"_embed/files/lib/data/b.txt", "_embed/files/templates/a.html"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("embedded files = %v, want %v", files, want)
+	}
+	lib, err := os.ReadFile(filepath.Join(out, "lib", "util", "util.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(lib), `embed.Executor(embedded.Files, "files/lib")`) {
+		t.Fatalf("lib/util does not load its own directory's files:\n%s", lib)
+	}
+
+	// A program without embed calls still gets an (empty) package to load.
+	out, err = transpile("import \"embed\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "_embed", "embedded.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	for src, want := range map[string]string{
+		"import \"embed\"\nvar p = \"x\"\nvar f = embed.files(p)\n": "takes string literals",
+		"import \"embed\"\nvar f = embed.files(\"nope/*\")\n":       "matches no files",
+		"import \"embed\"\nvar f = embed.files(\"../x\")\n":         "invalid pattern",
+	} {
+		if _, err := transpile(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("transpile(%q) = %v, want an error containing %q", src, err, want)
+		}
+	}
+}
+
+func TestTranspileExports(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	transpile := func(src string, opts Options) error {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "rules.goblin")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		l, err := source.NewLexerFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := parser.NewParser().Parse(l)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		_, err = TranspileToDirOptions(st.(*ast.Module), path, out, opts)
+		return err
+	}
+
+	src := `import "math"
+var greeting = "hi"
+type Order(total, country = "DE") {}
+func discount(order, more, percent = 10, **opts) {
+    return 0
+}
+func total(*items) {
+    return 0
+}
+export greeting
+export Order
+export discount
+export total
+export math
+`
+	if err := transpile(src, Options{Package: "rules", CExports: true}); err != nil {
+		t.Fatal(err)
+	}
+	code, err := os.ReadFile(filepath.Join(out, "exports.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func Load() (*object.Module, error)",
+		"func Greeting() (object.Object, error)",
+		"func NewOrder(total object.Object, more ...object.Object) (object.Object, error)",
+		"func Discount(order object.Object, more_ object.Object, more ...object.Object) (object.Object, error)",
+		"// Discount calls discount(order, more, percent=10, **opts), which the Goblin module exports.",
+		"func Total(items ...object.Object) (object.Object, error)",
+		`return _call("total", items)`,
+		"func Math() (object.Object, error)",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("exports.go lacks %q\n%s", want, code)
+		}
+	}
+	capi, err := os.ReadFile(filepath.Join(out, "capi", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"//export rules_discount\n", "//export rules_greeting\n", "//export rules_free\n"} {
+		if !strings.Contains(string(capi), want) {
+			t.Errorf("capi/main.go lacks %q\n%s", want, capi)
+		}
+	}
+	if strings.Contains(string(capi), "rules_Order") {
+		t.Errorf("capi/main.go exports a type\n%s", capi)
+	}
+
+	// Without CExports, the C API of an earlier transpile is removed.
+	if err := transpile(src, Options{Package: "rules", Exports: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "capi")); !os.IsNotExist(err) {
+		t.Errorf("capi/ was left behind: %v", err)
+	}
+	if err := transpile(src, Options{Exports: true}); err == nil {
+		t.Error("Exports without a package was accepted")
+	}
+
+	for _, bad := range []string{
+		// Both would be Rate.
+		"var rate = 1\nvar Rate = 2\nexport rate\nexport Rate\n",
+		// The package declares Execute.
+		"func execute() {\n}\nexport execute\n",
+		// NewOrder is also a Goblin name.
+		"type Order() {}\nfunc NewOrder() {\n}\nexport Order\n",
+		// Not a Go exported name.
+		"var _hidden = 1\nexport _hidden\n",
+		// exports.go declares _load.
+		"var _load = 1\nvar x = 2\nexport x\n",
+	} {
+		if err := transpile(bad, Options{Package: "rules", Exports: true}); err == nil {
+			t.Errorf("exports of %q were accepted", bad)
+		}
+	}
+}
+
+func TestRenumberTemporaries(t *testing.T) {
+	src := `package main
+
+var _tmp_9 int
+
+func a() int {
+	_tmp_4, _err_5 := f()
+	_ = _err_5
+	return _tmp_4 + _tmp_9
+}
+
+func b(_arg_7 int) int {
+	_tmp_12 := _arg_7
+	g := func(_arg_13 int) int { return _arg_13 }
+	return g(_tmp_12) + x._tmp_2
+}
+`
+	got, err := renumberTemporaries([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"_tmp_0, _err_0 := f()",
+		"return _tmp_0 + _tmp_9",
+		"func b(_arg_0 int) int",
+		"_tmp_0 := _arg_0",
+		"func(_arg_1 int) int { return _arg_1 }",
+		"x._tmp_2",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("renumbered source lacks %q\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteVendor(t *testing.T) {
+	saved := Runtime
+	defer func() { Runtime = saved }()
+	Runtime = fstest.MapFS{
+		"go.mod":                                 {Data: []byte("module github.com/aisk/goblin\n\ngo 1.20\n\nrequire (\n\texample.com/dep v1.2.0 // indirect\n)\n")},
+		"object/object.go":                       {Data: []byte("package object\n")},
+		"object/object_test.go":                  {Data: []byte("package object\n")},
+		"extension/fs/fs.go":                     {Data: []byte("package fs\n")},
+		"extension/fs/testdata/x.go":             {Data: []byte("package x\n")},
+		"_vendor/example.com/dep/sub/sub.go":     {Data: []byte("package sub\n")},
+		"_vendor/example.com/dep/LICENSE":        {Data: []byte("license\n")},
+		"_vendor/example.com/dep/sub/README.txt": {Data: []byte("skipped\n")},
+		"_vendor/example.com/dep/sub/asm.s":      {Data: []byte("TEXT x(SB)\n")},
+		"_vendor/example.com/dep/go.mod":         {Data: []byte("module example.com/dep\n\ngo 1.21\n")},
+	}
+	t.Setenv("GOBLIN_ROOT", "")
+	dir := t.TempDir()
+	var r Result
+	if err := generateGoMod(dir, "app", &r); err != nil {
+		t.Fatal(err)
+	}
+	version := strings.SplitN(goblinRuntimeVersion(), "+", 2)[0]
+	if !r.Vendored || r.RuntimeVersion != version {
+		t.Fatalf("result = %+v", r)
+	}
+	if ModFlag(dir) != "-mod=vendor" {
+		t.Fatalf("ModFlag = %s", ModFlag(dir))
+	}
+	want := map[string]string{
+		"go.mod":                            "module app\n\ngo 1.19\n\nrequire (\n\tgithub.com/aisk/goblin " + version + "\n\texample.com/dep v1.2.0 // indirect\n)\n",
+		"vendor/modules.txt":                "# github.com/aisk/goblin " + version + "\n## explicit; go 1.20\ngithub.com/aisk/goblin/extension/fs\ngithub.com/aisk/goblin/object\n# example.com/dep v1.2.0\n## explicit; go 1.21\nexample.com/dep/sub\n",
+		"vendor/example.com/dep/LICENSE":    "license\n",
+		"vendor/example.com/dep/sub/sub.go": "package sub\n",
+		"vendor/example.com/dep/sub/asm.s":  "TEXT x(SB)\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s =\n%s\nwant\n%s", name, data, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vendor/example.com/dep/go.mod")); !os.IsNotExist(err) {
+		t.Error("the go.mod of example.com/dep was vendored")
+	}
+	for _, name := range []string{"object/object_test.go", "extension/fs/testdata/x.go"} {
+		if _, err := os.Stat(filepath.Join(dir, "vendor", "github.com/aisk/goblin", name)); !os.IsNotExist(err) {
+			t.Errorf("%s was vendored", name)
+		}
+	}
+}
@@ -11,9 +11,16 @@ package transpiler
 // This pass finds local variables whose type can be *proven* constant across
 // every assignment in the enclosing function body, and lets the code generator
 // declare them as native Go int64/float64/bool. It is deliberately not
-// speculative: there are no runtime guards and no bail-out path, so anything
-// that cannot be proven simply stays an object.Object and is generated exactly
-// as before.
+// speculative about types: anything that cannot be proven simply stays an
+// object.Object and is generated exactly as before.
+//
+// Integer arithmetic is the one runtime guard. An Integer that overflows int64
+// becomes a BigInt, which no int64 can hold. Integer +, -, * and / (and unary
+// -) are therefore emitted with checked operators that fall back to boxed
+// evaluation on overflow (see emitNative), and a native integer variable
+// assigned their result is promoted: it gets a boxed shadow that holds the
+// BigInt once one overflowed, and reads of it take the boxed path while the
+// shadow is set.
 //
 // The analysis is flow-insensitive — it collects *all* assignments to a name
 // and joins their types. `if c { x = 1 } else { x = "s" }` therefore makes x
@@ -81,14 +88,15 @@ func goTypeOf(t staticType) string {
 }
 
 // inferLocals analyses one function body (a module's top level counts as one)
-// and returns the variables that can be declared with a native Go type.
+// and returns the variables that can be declared with a native Go type, and
+// which of the integer ones are promoted, as they may be assigned a BigInt.
 //
 // Only names introduced by `var` in this body are candidates, plus the
 // variables of for-range loops when rangeNative says the range builtin is what
 // `range` resolves to throughout this body. Parameters are excluded: a caller
 // may pass anything, and proving otherwise would need interprocedural
 // analysis.
-func inferLocals(body []ast.Statement, rangeNative bool) map[string]staticType {
+func inferLocals(body []ast.Statement, rangeNative bool) (map[string]staticType, map[string]bool) {
 	types := map[string]staticType{}
 
 	// Candidates: every `var` in this body, at any block depth. Block scoping
@@ -97,7 +105,7 @@ func inferLocals(body []ast.Statement, rangeNative bool) map[string]staticType {
 	// own block scoping keeps the generated declarations correct.
 	collectDeclarations(body, types, rangeNative)
 	if len(types) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// Names a nested function refers to are pinned to dynamic. A nested Go
@@ -132,9 +140,25 @@ func inferLocals(body []ast.Statement, rangeNative bool) map[string]staticType {
 		}
 	}
 	if len(types) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	// Promotion spreads through plain copies, `y = x`, so it needs a fixed
+	// point of its own.
+	promoted := map[string]bool{}
+	for {
+		changed := false
+		walkAssignments(body, func(name string, value ast.Expression) {
+			if types[name] == tyInt && !promoted[name] && mayOverflow(value, types, promoted) {
+				promoted[name] = true
+				changed = true
+			}
+		})
+		if !changed {
+			break
+		}
 	}
-	return types
+	return types, promoted
 }
 
 // collectDeclarations seeds the candidate set with every `var` in this body.
@@ -466,6 +490,98 @@ func staticTypeOf(expr ast.Expression, types map[string]staticType) staticType {
 	return tyDynamic
 }
 
+// mayOverflow reports whether a native-eligible expression tree does integer
+// arithmetic that can overflow int64 anywhere, or reads a promoted variable,
+// which may hold a BigInt.
+func mayOverflow(expr ast.Expression, types map[string]staticType, promoted map[string]bool) bool {
+	if overflowingOp(expr, types) {
+		return true
+	}
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		return promoted[e.Name]
+	case *ast.UnaryOperation:
+		return mayOverflow(e.Operand, types, promoted)
+	case *ast.BinaryOperation:
+		return mayOverflow(e.LHS, types, promoted) || mayOverflow(e.RHS, types, promoted)
+	}
+	return false
+}
+
+// overflowingOp reports whether the operator at the root of expr can overflow
+// int64: +, -, * and / on two integers, or unary minus on one. Modulo cannot,
+// float arithmetic never does, and an operation on constants that evaluates
+// in range cannot either, which keeps `-1` and `60 * 60` native.
+func overflowingOp(expr ast.Expression, types map[string]staticType) bool {
+	if _, ok := constantInt(expr); ok {
+		return false
+	}
+	switch e := expr.(type) {
+	case *ast.UnaryOperation:
+		return e.Operator == "-" && staticTypeOf(e.Operand, types) == tyInt
+	case *ast.BinaryOperation:
+		switch e.Operator {
+		case "+", "-", "*", "/":
+			return staticTypeOf(e.LHS, types) == tyInt && staticTypeOf(e.RHS, types) == tyInt
+		}
+	}
+	return false
+}
+
+// constantInt evaluates an integer expression made only of literals, and
+// reports false if it is not one or if evaluating it overflows or divides by
+// zero.
+func constantInt(expr ast.Expression) (int64, bool) {
+	var overflow bool
+	switch e := expr.(type) {
+	case *ast.Literal:
+		v, ok := e.Value.(object.Integer)
+		return int64(v), ok
+	case *ast.UnaryOperation:
+		v, ok := constantInt(e.Operand)
+		if !ok {
+			return 0, false
+		}
+		switch e.Operator {
+		case "+":
+			return v, true
+		case "-":
+			v = object.CheckedNeg(v, &overflow)
+			return v, !overflow
+		}
+	case *ast.BinaryOperation:
+		lhs, ok := constantInt(e.LHS)
+		if !ok {
+			return 0, false
+		}
+		rhs, ok := constantInt(e.RHS)
+		if !ok {
+			return 0, false
+		}
+		var v int64
+		switch e.Operator {
+		case "+":
+			v = object.CheckedAdd(lhs, rhs, &overflow)
+		case "-":
+			v = object.CheckedSub(lhs, rhs, &overflow)
+		case "*":
+			v = object.CheckedMul(lhs, rhs, &overflow)
+		case "/", "%":
+			if rhs == 0 {
+				return 0, false
+			}
+			if e.Operator == "%" {
+				return lhs % rhs, true
+			}
+			v = object.CheckedDiv(lhs, rhs, &overflow)
+		default:
+			return 0, false
+		}
+		return v, !overflow
+	}
+	return 0, false
+}
+
 // containsZeroCheckedArithmetic reports whether a native-eligible expression
 // tree performs division or modulo, i.e. whether emitNative would need to hoist
 // a guard for it.